@@ -43,6 +43,12 @@ type Func struct {
 	// database might shard by table so that each invocation of Many only has to
 	// fetch rows from a single table.
 	Shard func(arg interface{}) (shard interface{})
+	// Partition optionally splits a batch's inputs by a partition key, such as
+	// a database shard or region, just before invoking Many. Unlike Shard, all
+	// inputs are collected into a single batch subject to MaxSize, WaitInterval,
+	// and MaxDuration, and Many is then invoked once per partition concurrently.
+	// An error from Many only fails the inputs of its partition.
+	Partition func(arg interface{}) (partition interface{})
 	// MaxSize optionally limits the size of a batch. After receiving MaxSize
 	// invocations, Many will be invoked even if some goroutines are stil running.
 	// Zero, the default, means no limit.
@@ -71,6 +77,9 @@ type batchGroup struct {
 	result []interface{}
 	// if err is nil, result is valid. Otherwise, err describes what went wrong.
 	err error
+	// errs is an optional array of len(args) errors for the individual
+	// arguments, set when some but not all partitions failed.
+	errs []error
 }
 
 // funcShard identifies a batchGroup for a given Func and result of Func.Shard.
@@ -124,6 +133,85 @@ func safeInvoke(
 	return f(ctx, args)
 }
 
+// invokeMany invokes f.Many on args, once per partition if f.Partition is set.
+// If only some partitions failed, errs holds the error for each argument.
+//
+// invokeMany recovers panics of f.Partition, so that the waiters of a batch
+// always get a result or an error.
+func (f *Func) invokeMany(ctx context.Context, args []interface{}) (result []interface{}, errs []error, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			result, errs = nil, nil
+			err = fmt.Errorf("Func.Partition panicked: %v", p)
+		}
+	}()
+
+	if f.Partition == nil {
+		result, err = safeInvoke(ctx, f.Many, args)
+		return result, nil, err
+	}
+
+	// Group the arguments by partition, remembering their original positions.
+	var partitions [][]int
+	byKey := make(map[interface{}]int)
+	for i, arg := range args {
+		key := f.Partition(arg)
+		p, ok := byKey[key]
+		if !ok {
+			p = len(partitions)
+			byKey[key] = p
+			partitions = append(partitions, nil)
+		}
+		partitions[p] = append(partitions[p], i)
+	}
+	if len(partitions) == 1 {
+		result, err = safeInvoke(ctx, f.Many, args)
+		return result, nil, err
+	}
+
+	results := make([][]interface{}, len(partitions))
+	partitionErrs := make([]error, len(partitions))
+	var wg sync.WaitGroup
+	for p, indices := range partitions {
+		wg.Add(1)
+		go func(p int, indices []int) {
+			defer wg.Done()
+			partitionArgs := make([]interface{}, len(indices))
+			for j, i := range indices {
+				partitionArgs[j] = args[i]
+			}
+			results[p], partitionErrs[p] = safeInvoke(ctx, f.Many, partitionArgs)
+		}(p, indices)
+	}
+	wg.Wait()
+
+	// Merge the results back into the order of args.
+	result = make([]interface{}, len(args))
+	failed := 0
+	for p, indices := range partitions {
+		if partitionErrs[p] == nil && len(results[p]) != len(indices) {
+			partitionErrs[p] = errors.New("Func.Many returned incorrect number of results")
+		}
+		if partitionErrs[p] != nil {
+			failed++
+			if errs == nil {
+				errs = make([]error, len(args))
+			}
+		}
+		for j, i := range indices {
+			if partitionErrs[p] != nil {
+				errs[i] = partitionErrs[p]
+			} else {
+				result[i] = results[p][j]
+			}
+		}
+	}
+	if failed == len(partitions) {
+		return nil, nil, partitionErrs[0]
+	}
+	return result, errs, nil
+}
+
 // Invoke arranges for the Func's Many to be called with arg as one of its
 // arguments, and returns the corresponding result.
 func (f *Func) Invoke(ctx context.Context, arg interface{}) (interface{}, error) {
@@ -219,7 +307,7 @@ func (f *Func) Invoke(ctx context.Context, arg interface{}) (interface{}, error)
 
 		// Check for the context being canceled.
 		if ctx.Err() == nil {
-			bg.result, bg.err = safeInvoke(ctx, f.Many, bg.args)
+			bg.result, bg.errs, bg.err = f.invokeMany(ctx, bg.args)
 		} else {
 			bg.err = ctx.Err()
 		}
@@ -237,5 +325,8 @@ func (f *Func) Invoke(ctx context.Context, arg interface{}) (interface{}, error)
 	if bg.err != nil {
 		return nil, bg.err
 	}
+	if bg.errs != nil && bg.errs[index] != nil {
+		return nil, bg.errs[index]
+	}
 	return bg.result[index], nil
 }
@@ -193,6 +193,91 @@ func TestShard(t *testing.T) {
 	}
 }
 
+// TestPartition tests that Func.Partition invokes Many once per partition of a
+// batch, and that an error only fails the inputs of its partition.
+func TestPartition(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	f := (&batch.Func{
+		Many: func(ctx context.Context, args []interface{}) ([]interface{}, error) {
+			mu.Lock()
+			calls++
+			mu.Unlock()
+			for _, i := range args {
+				if i.(int)%3 != args[0].(int)%3 {
+					return nil, errors.New("bad partition")
+				}
+			}
+			if args[0].(int)%3 == 2 {
+				return nil, errors.New("partition unavailable")
+			}
+			return args, nil
+		},
+		Partition: func(arg interface{}) interface{} {
+			return arg.(int) % 3
+		},
+		MaxSize: 20,
+		// Wait for all invocations to join the batch.
+		WaitInterval: time.Second,
+	}).Invoke
+
+	ctx := batch.WithBatching(context.Background())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			result, err := f(ctx, i)
+			if i%3 == 2 {
+				if err == nil || err.Error() != "partition unavailable" {
+					t.Error(err, i)
+				}
+			} else if err != nil || result != i {
+				t.Error(err, i)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if calls != 3 {
+		t.Error(calls)
+	}
+}
+
+// TestPartitionPanic tests that a Func.Partition that panics fails the
+// batch instead of leaving its waiters hanging.
+func TestPartitionPanic(t *testing.T) {
+	f := (&batch.Func{
+		Many: func(ctx context.Context, args []interface{}) ([]interface{}, error) {
+			return args, nil
+		},
+		Partition: func(arg interface{}) interface{} {
+			if arg.(int) == 3 {
+				panic("bad arg")
+			}
+			return arg.(int) % 2
+		},
+		MaxSize: 10,
+		// Wait for all invocations to join the batch.
+		WaitInterval: time.Second,
+	}).Invoke
+
+	ctx := batch.WithBatching(context.Background())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := f(ctx, i); err == nil || !strings.Contains(err.Error(), "Func.Partition panicked: bad arg") {
+				t.Error(err, i)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
 // TestMaxSize tests that no more than Func.MaxSize arguments get batched
 // together.
 func TestMaxSize(t *testing.T) {
@@ -7,6 +7,7 @@ import { MutationSpec } from "./spec";
 export const ErrorNotConnected = "not connected";
 export const ErrorMutationTimeout = "mutation timed out";
 export const ErrorConnectionClosed = "connection closed";
+export const ErrorSubscriptionTerminated = "subscription terminated";
 
 export interface GraphQLData<Result> {
   data: GraphQLResult<Result>;
@@ -78,6 +79,12 @@ export interface Subscription<Result, InputVariables>
   observer: (data: GraphQLResult<Result>) => void;
   value: Result | undefined;
   error?: GraphQLError;
+  // seq is the sequence number of the last update received since the
+  // subscription was (re)started.
+  seq: number;
+  // skipInitial is set if the next subscribe asks the server to skip the
+  // initial result, as value is already current.
+  skipInitial: boolean;
 }
 
 export interface Mutation<InputVariables> extends GraphqlQuery<InputVariables> {
@@ -111,21 +118,31 @@ class Connection {
     return (this.nextId++).toString();
   }
 
+  // subscribe starts a subscription. Callers that already hold the current
+  // result of the query, eg. fetched with a mutation, pass it as
+  // initialValue, and the server then skips sending the initial result. The
+  // result is fetched again when the subscription restarts, eg. after a
+  // reconnect, as it may be stale by then.
   subscribe<QueryResult extends object, QueryInputVariables extends object>({
     query,
     variables,
     observer,
+    initialValue,
   }: {
     query: string;
     variables: QueryInputVariables;
     observer: Observer<GraphQLResult<QueryResult>>;
+    initialValue?: QueryResult;
   }) {
     const id = this.makeId();
 
-    const cached = this.past.find({
-      query,
-      variables,
-    }) as QueryResult | undefined;
+    const cached =
+      initialValue !== undefined
+        ? Object.freeze(initialValue)
+        : (this.past.find({
+            query,
+            variables,
+          }) as QueryResult | undefined);
 
     const subscription: Subscription<QueryResult, QueryInputVariables> = {
       state: cached ? "cached" : "loading",
@@ -136,16 +153,14 @@ class Connection {
       observer,
       value: cached,
       error: undefined,
+      seq: 0,
+      skipInitial: initialValue !== undefined,
     };
 
     this.subscriptions.set(id, subscription as Subscription<any, any>);
 
     if (this.socket.state === "connected") {
-      this.send({
-        id,
-        type: "subscribe",
-        message: { query, variables },
-      });
+      this.sendSubscribe(id, subscription);
     }
 
     return {
@@ -156,7 +171,7 @@ class Connection {
         }
 
         if (sub.value !== undefined) {
-          this.past.add({ query, variables }, sub.value);
+          this.past.add({ query, variables: sub.variables }, sub.value);
         }
 
         this.subscriptions.delete(id);
@@ -167,6 +182,24 @@ class Connection {
       data: () => {
         return dataFromSubscription(subscription);
       },
+      // updateVariables reruns the subscription with new variables, without
+      // subscribing again. The server diffs the new result against the
+      // current one.
+      updateVariables: (newVariables: QueryInputVariables) => {
+        const sub = this.subscriptions.get(id);
+        if (!sub) {
+          return;
+        }
+
+        sub.variables = newVariables;
+        if (this.socket.state === "connected") {
+          this.send({
+            id,
+            type: "update_variables",
+            message: { variables: newVariables },
+          });
+        }
+      },
     };
   }
 
@@ -251,11 +284,24 @@ class Connection {
       return;
     }
 
+    this.sendSubscribe(id, subscription);
+  }
+
+  // sendSubscribe (re)starts a subscription. Its updates are numbered, so
+  // dropped updates can be detected, see handleMessage.
+  sendSubscribe(id: string, subscription: Subscription<object, object>) {
+    subscription.seq = 0;
     this.send({
       id,
       type: "subscribe",
-      message: { query: subscription.query, variables: subscription.variables },
+      message: {
+        query: subscription.query,
+        variables: subscription.variables,
+        reliable: true,
+        skipInitial: subscription.skipInitial,
+      },
     });
+    subscription.skipInitial = false;
   }
 
   open() {
@@ -272,14 +318,7 @@ class Connection {
 
   handleOpen() {
     for (const [id, subscription] of this.subscriptions) {
-      this.send({
-        id,
-        type: "subscribe",
-        message: {
-          query: subscription.query,
-          variables: subscription.variables,
-        },
-      });
+      this.sendSubscribe(id, subscription);
       if (subscription.retryHandle) {
         clearTimeout(subscription.retryHandle);
         subscription.retryHandle = undefined;
@@ -309,6 +348,21 @@ class Connection {
       case "update":
         subscription = this.subscriptions.get(envelope.id);
         if (subscription !== undefined) {
+          if (
+            envelope.seq !== undefined &&
+            envelope.seq !== subscription.seq + 1
+          ) {
+            // An update was dropped, so the diff cannot be applied.
+            // Restart the subscription to get a full result.
+            this.send({ id: envelope.id, type: "unsubscribe" });
+            this.sendSubscribe(envelope.id, subscription);
+            break;
+          }
+          if (envelope.seq !== undefined) {
+            subscription.seq = envelope.seq;
+            this.send({ id: envelope.id, type: "ack", message: envelope.seq });
+          }
+
           if (subscription.state !== "subscribed") {
             subscription.state = "subscribed";
             subscription.error = undefined;
@@ -372,6 +426,29 @@ class Connection {
         }
         break;
 
+      case "complete":
+        // The server terminated the subscription, eg. because access to the
+        // data was revoked. Do not retry it.
+        subscription = this.subscriptions.get(envelope.id);
+        if (subscription !== undefined) {
+          subscription.state = "error";
+          subscription.error = new GraphQLError(
+            (envelope.message && envelope.message.reason) ||
+              ErrorSubscriptionTerminated,
+          );
+          this.notify(subscription);
+        }
+        break;
+
+      case "close":
+        // The server terminated the connection, eg. because the user logged
+        // out. Do not reconnect, unless the server asked to, eg. because it
+        // is shutting down.
+        if (!envelope.message || !envelope.message.reconnect) {
+          this.close();
+        }
+        break;
+
       default:
         break;
     }
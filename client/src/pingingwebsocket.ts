@@ -14,7 +14,12 @@ export type OutEnvelope =
   | {
       type: "subscribe";
       id: string;
-      message: { query: string; variables: any };
+      message: {
+        query: string;
+        variables: any;
+        reliable?: boolean;
+        skipInitial?: boolean;
+      };
       extensions?: Record<string, any>;
     }
   | {
@@ -27,6 +32,16 @@ export type OutEnvelope =
       type: "unsubscribe";
       id: string;
     }
+  | {
+      type: "ack";
+      id: string;
+      message: number;
+    }
+  | {
+      type: "update_variables";
+      id: string;
+      message: { variables: any };
+    }
   | {
       type: "echo";
     };
@@ -35,6 +50,7 @@ export type InEnvelope =
   | {
       type: "update";
       id: string;
+      seq?: number;
       message: any;
       metadata?: Record<string, any>;
     }
@@ -50,6 +66,15 @@ export type InEnvelope =
       message: string;
       metadata?: Record<string, any>;
     }
+  | {
+      type: "complete";
+      id: string;
+      message?: { reason?: string };
+    }
+  | {
+      type: "close";
+      message?: { reason?: string; reconnect?: boolean };
+    }
   | {
       type: "echo";
     };
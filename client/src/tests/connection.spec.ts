@@ -0,0 +1,102 @@
+import { Connection } from "../connection";
+import { OutEnvelope } from "../pingingwebsocket";
+
+function makeConnection() {
+  const connection = new Connection(
+    async () => new WebSocket("ws://localhost"),
+  );
+  const sent: OutEnvelope[] = [];
+  connection.socket = {
+    state: "connected",
+    send: (message: OutEnvelope) => sent.push(message),
+    reconnect: () => Promise.resolve(),
+    close: () => {},
+  } as any;
+  return { connection, sent };
+}
+
+describe("Connection", () => {
+  test("skipInitial", () => {
+    const { connection, sent } = makeConnection();
+
+    const subscription = connection.subscribe<
+      { value: number; other: number },
+      {}
+    >({
+      query: "{ value other }",
+      variables: {},
+      observer: () => {},
+      initialValue: { value: 1, other: 1 },
+    });
+    expect(sent).toEqual([
+      {
+        id: "0",
+        type: "subscribe",
+        message: {
+          query: "{ value other }",
+          variables: {},
+          reliable: true,
+          skipInitial: true,
+        },
+      },
+    ]);
+    expect(subscription.data()).toEqual({
+      state: "cached",
+      value: { value: 1, other: 1 },
+      error: undefined,
+    });
+
+    // The server acknowledges with an empty diff, and later diffs are merged
+    // into the initial value.
+    connection.handleMessage({ type: "update", id: "0", seq: 1, message: {} });
+    connection.handleMessage({
+      type: "update",
+      id: "0",
+      seq: 2,
+      message: { value: 2 },
+    });
+    expect(subscription.data()).toEqual({
+      state: "subscribed",
+      value: { value: 2, other: 1 },
+      error: undefined,
+    });
+
+    // Restarted subscriptions fetch the full result.
+    sent.length = 0;
+    connection.handleOpen();
+    expect(sent).toEqual([
+      {
+        id: "0",
+        type: "subscribe",
+        message: {
+          query: "{ value other }",
+          variables: {},
+          reliable: true,
+          skipInitial: false,
+        },
+      },
+    ]);
+  });
+
+  test("without initialValue", () => {
+    const { connection, sent } = makeConnection();
+
+    connection.subscribe({
+      query: "{ value }",
+      variables: {},
+      observer: () => {},
+    });
+    expect(sent).toEqual([
+      {
+        id: "0",
+        type: "subscribe",
+        message: {
+          query: "{ value }",
+          variables: {},
+          reliable: true,
+          skipInitial: false,
+        },
+      },
+    ]);
+  });
+});
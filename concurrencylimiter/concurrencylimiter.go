@@ -39,6 +39,10 @@ type holderKey struct{}
 // is shared between goroutines.
 type holder struct {
 	l *limiter
+	// parent is the holder of the context that the holder's token was
+	// acquired with, such as the holder of a limit attached by With when
+	// acquiring a Lane's token. It is released along with the holder.
+	parent *holder
 
 	// status tracks if the holder currently has an in item in l.ch. Before
 	// modifying l.ch, first status must be modified using an atomic operation.
@@ -61,8 +65,15 @@ func (h *holder) release() {
 	}
 }
 
-// block temporarily gives up the holder's spot in ch while running f.
+// block temporarily gives up the holder's spot in ch, and the spots of its
+// parents, while running f. Parents reacquire their spots first, in the
+// order they were acquired.
 func (h *holder) block(f func()) {
+	if h.parent != nil {
+		inner := f
+		f = func() { h.parent.block(inner) }
+	}
+
 	// If we are currently acquired, temporarily release the token. Otherwise,
 	// we are either blocked or released.
 	if atomic.CompareAndSwapInt64(&h.status, acquired, blocked) {
@@ -95,15 +106,69 @@ func Acquire(ctx context.Context) (context.Context, ReleaseFunc) {
 	if !ok {
 		return ctx, func() {}
 	}
+	return acquire(ctx, l)
+}
+
+// A Lane is a concurrency limit that is shared between contexts, such as all
+// requests of a given priority in a process. Unlike the limit attached by
+// With, a Lane is not inherited by the contexts derived from Acquire.
+type Lane struct {
+	l *limiter
+}
+
+// NewLane creates a Lane with the given limit.
+func NewLane(limit int) *Lane {
+	return &Lane{
+		l: &limiter{
+			ch: make(chan struct{}, limit),
+		},
+	}
+}
 
+// Acquire acquires a token from the lane, waiting for one to become
+// available. If the context is canceled it succeeds immediately and returns a
+// no-op release function.
+//
+// The returned context allows TemporarilyRelease to give up the lane's token.
+// Calls to the package-level Acquire with that context still use the limit
+// attached by With, if any. The returned release function is idempotent.
+func (l *Lane) Acquire(ctx context.Context) (context.Context, ReleaseFunc) {
+	return acquire(ctx, l.l)
+}
+
+// TryAcquire acquires a token from the lane if one is available, without
+// waiting. It returns false if all tokens are in use.
+func (l *Lane) TryAcquire(ctx context.Context) (context.Context, ReleaseFunc, bool) {
+	select {
+	case l.l.ch <- struct{}{}:
+	default:
+		return ctx, func() {}, false
+	}
+	ctx, release := hold(ctx, l.l)
+	return ctx, release, true
+}
+
+// InUse returns the number of tokens currently held in the lane.
+func (l *Lane) InUse() int {
+	return len(l.l.ch)
+}
+
+// acquire acquires a token from l.
+func acquire(ctx context.Context, l *limiter) (context.Context, ReleaseFunc) {
 	select {
 	case l.ch <- struct{}{}:
 	case <-ctx.Done():
 		return ctx, func() {}
 	}
+	return hold(ctx, l)
+}
 
+// hold returns a context holding a token already acquired from l.
+func hold(ctx context.Context, l *limiter) (context.Context, ReleaseFunc) {
+	parent, _ := ctx.Value(holderKey{}).(*holder)
 	h := &holder{
 		l:      l,
+		parent: parent,
 		status: acquired,
 	}
 	ctx = context.WithValue(ctx, holderKey{}, h)
@@ -111,8 +176,9 @@ func Acquire(ctx context.Context) (context.Context, ReleaseFunc) {
 	return ctx, h.release
 }
 
-// TemporarilyRelease temporarily releases a concurrency limiter token (if any)
-// while calling a long-running but no-resource-using function f.
+// TemporarilyRelease temporarily releases the concurrency limiter tokens (if
+// any) held by ctx, such as a Lane's token and a token of the limit attached
+// by With, while calling a long-running but no-resource-using function f.
 func TemporarilyRelease(ctx context.Context, f func()) {
 	if h, ok := ctx.Value(holderKey{}).(*holder); ok {
 		h.block(f)
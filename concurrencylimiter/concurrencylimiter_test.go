@@ -239,3 +239,76 @@ func TestAcquireReleaseNoLimiter(t *testing.T) {
 	ctx, release := concurrencylimiter.Acquire(ctx)
 	release()
 }
+
+// TestLaneTryAcquire tests that TryAcquire fails instead of waiting once the
+// lane is full.
+func TestLaneTryAcquire(t *testing.T) {
+	lane := concurrencylimiter.NewLane(1)
+
+	ctx, release, ok := lane.TryAcquire(context.Background())
+	if !ok {
+		t.Fatal("expected to acquire a token from an empty lane")
+	}
+	if _, _, ok := lane.TryAcquire(context.Background()); ok {
+		t.Error("expected not to acquire a token from a full lane")
+	}
+
+	// The token can be temporarily released like acquired tokens.
+	concurrencylimiter.TemporarilyRelease(ctx, func() {
+		if lane.InUse() != 0 {
+			t.Error("expected token to be temporarily released")
+		}
+	})
+
+	release()
+	if lane.InUse() != 0 {
+		t.Error("expected token to be released")
+	}
+	_, release, ok = lane.TryAcquire(context.Background())
+	if !ok {
+		t.Error("expected to acquire a released token")
+	}
+	release()
+}
+
+// TestTemporarilyReleaseNested tests that TemporarilyRelease releases the
+// tokens of both a lane and the limit attached by With.
+func TestTemporarilyReleaseNested(t *testing.T) {
+	limited := concurrencylimiter.With(context.Background(), 1)
+	ctx, releaseOuter := concurrencylimiter.Acquire(limited)
+	lane := concurrencylimiter.NewLane(1)
+	ctx, releaseLane := lane.Acquire(ctx)
+
+	concurrencylimiter.TemporarilyRelease(ctx, func() {
+		if lane.InUse() != 0 {
+			t.Error("expected lane token to be temporarily released")
+		}
+
+		// The outer token is free for other goroutines.
+		timeout, cancel := context.WithTimeout(limited, time.Second)
+		defer cancel()
+		_, release := concurrencylimiter.Acquire(timeout)
+		if timeout.Err() != nil {
+			t.Error("expected outer token to be temporarily released")
+		}
+		release()
+	})
+	if lane.InUse() != 1 {
+		t.Error("expected lane token to be reacquired")
+	}
+
+	releaseLane()
+	releaseOuter()
+	if lane.InUse() != 0 {
+		t.Error("expected lane token to be released")
+	}
+	// The outer token was reacquired and released.
+	_, release, ok := lane.TryAcquire(limited)
+	assert.True(t, ok)
+	release()
+	timeout, cancel := context.WithTimeout(limited, time.Second)
+	defer cancel()
+	_, release = concurrencylimiter.Acquire(timeout)
+	assert.NoError(t, timeout.Err())
+	release()
+}
@@ -0,0 +1,94 @@
+package federation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// extractKeysResults returns n parent results, each with a list of users with
+// a list of friends, nested on a union.
+func extractKeysResults(n int) []interface{} {
+	results := make([]interface{}, n)
+	for i := range results {
+		users := make([]interface{}, 10)
+		for j := range users {
+			friends := make([]interface{}, 10)
+			for k := range friends {
+				friends[k] = map[string]interface{}{
+					"__typename":    "User",
+					federationField: map[string]interface{}{"id": int64(k)},
+				}
+			}
+			users[j] = map[string]interface{}{
+				"__typename": "User",
+				"friends":    friends,
+			}
+		}
+		results[i] = map[string]interface{}{"users": users}
+	}
+	return results
+}
+
+var extractKeysPath = []PathStep{
+	{Kind: KindField, Name: "users"},
+	{Kind: KindType, Name: "User"},
+	{Kind: KindField, Name: "friends"},
+	{Kind: KindType, Name: "User"},
+}
+
+func benchmarkExtractKeys(b *testing.B, n int) {
+	results := extractKeysResults(n)
+	paths := make([][]interface{}, n)
+	for i := range paths {
+		paths[i] = []interface{}{"parents", i}
+	}
+
+	extractor := (&Plan{Path: extractKeysPath}).keyExtractor()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var metadata pathSubqueryMetadata
+		for j, result := range results {
+			if err := extractor.extract(&metadata, result, paths[j]); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if len(metadata.keys) != n*100 {
+			b.Fatalf("got %d keys", len(metadata.keys))
+		}
+	}
+}
+
+func BenchmarkExtractKeys1Result(b *testing.B) {
+	benchmarkExtractKeys(b, 1)
+}
+
+func BenchmarkExtractKeys100Results(b *testing.B) {
+	benchmarkExtractKeys(b, 100)
+}
+
+func TestKeyExtractor(t *testing.T) {
+	plan := &Plan{Path: extractKeysPath}
+	var metadata pathSubqueryMetadata
+	results := extractKeysResults(2)
+	for i, result := range results {
+		require.NoError(t, plan.keyExtractor().extract(&metadata, result, []interface{}{"parents", i}))
+	}
+
+	require.Len(t, metadata.keys, 200)
+	require.Len(t, metadata.paths, 200)
+	assert.Equal(t, map[string]interface{}{"id": int64(0)}, metadata.keys[0])
+	assert.Equal(t, map[string]interface{}{"id": int64(9)}, metadata.keys[199])
+	// Paths do not share the traversal buffer.
+	assert.Equal(t, []interface{}{"parents", 0, "users", 0, "friends", 0}, metadata.paths[0])
+	assert.Equal(t, []interface{}{"parents", 0, "users", 0, "friends", 1}, metadata.paths[1])
+	assert.Equal(t, []interface{}{"parents", 1, "users", 9, "friends", 9}, metadata.paths[199])
+	assert.Equal(t, 6, cap(metadata.paths[0]))
+
+	missing := &Plan{Path: []PathStep{{Kind: KindField, Name: "missing"}}}
+	err := missing.keyExtractor().extract(&pathSubqueryMetadata{}, results[0], nil)
+	assert.EqualError(t, err, "does not have key missing")
+}
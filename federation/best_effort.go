@@ -0,0 +1,312 @@
+package federation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samsarahq/go/oops"
+
+	"github.com/denkhaus/thunder/graphql"
+)
+
+// BestEffortDirective marks selections resolved by other services as best
+// effort. If the subquery resolving them takes longer than the executor's
+// best effort timeout, the gateway stops waiting for it and returns null for
+// the selections, along with a Warning, instead of slowing down the whole
+// response. Like errors, nulls of non-null selections propagate to the
+// nearest nullable parent, and fail the query if there is none. For example:
+//
+//	{
+//	  users {
+//	    name
+//	    recommendations @bestEffort { title }
+//	  }
+//	}
+const BestEffortDirective = "bestEffort"
+
+// DefaultBestEffortTimeout is the best effort timeout used if
+// CustomExecutorArgs.BestEffortTimeout is not set.
+const DefaultBestEffortTimeout = 100 * time.Millisecond
+
+// isBestEffort returns whether selection has the best effort directive.
+func isBestEffort(selection *graphql.Selection) bool {
+	for _, directive := range selection.Directives {
+		if directive.Name == BestEffortDirective {
+			return true
+		}
+	}
+	return false
+}
+
+// Warning describes a problem with a query that did not fail it, such as
+// dropped best effort selections. Gateways can return warnings in the
+// response extensions.
+type Warning struct {
+	// Path locates the object in the response, as a list of field aliases
+	// (strings) and list indices (ints).
+	Path []interface{} `json:"path"`
+	// Fields lists the aliases of the affected fields on the object.
+	Fields []string `json:"fields"`
+	// Message describes the problem.
+	Message string `json:"message"`
+}
+
+// WarningCollector collects the warnings of queries executed with a context
+// from WithWarningCollector.
+type WarningCollector struct {
+	mu       sync.Mutex
+	warnings []*Warning
+}
+
+// Warnings returns the collected warnings.
+func (c *WarningCollector) Warnings() []*Warning {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*Warning(nil), c.warnings...)
+}
+
+type warningCollectorKey struct{}
+
+// WithWarningCollector makes queries executed with ctx add their warnings to
+// c. Warnings are dropped for queries without a collector.
+func WithWarningCollector(ctx context.Context, c *WarningCollector) context.Context {
+	return context.WithValue(ctx, warningCollectorKey{}, c)
+}
+
+// addWarning adds warning to the collector of ctx, if any.
+func addWarning(ctx context.Context, warning *Warning) {
+	c, ok := ctx.Value(warningCollectorKey{}).(*WarningCollector)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.warnings = append(c.warnings, warning)
+}
+
+// nullTargets collects the objects that must be null in the response because
+// non-null best effort selections on them were dropped.
+type nullTargets struct {
+	mu      sync.Mutex
+	targets []nullTarget
+}
+
+type nullTarget struct {
+	path []interface{}
+	err  error
+}
+
+type nullTargetsKey struct{}
+
+// withNullTargets returns a context collecting the null targets of the
+// queries executed with it.
+func withNullTargets(ctx context.Context) (context.Context, *nullTargets) {
+	nulls := &nullTargets{}
+	return context.WithValue(ctx, nullTargetsKey{}, nulls), nulls
+}
+
+// withoutNullTargets returns a context whose queries do not collect null
+// targets, for deferred subqueries that run after the response was sent.
+func withoutNullTargets(ctx context.Context) context.Context {
+	return context.WithValue(ctx, nullTargetsKey{}, (*nullTargets)(nil))
+}
+
+// propagate sets the null targets in res, the result of plan, to null. Nulls
+// propagate to the nearest nullable parent, and propagate returns the error
+// of the target if there is none.
+func (n *nullTargets) propagate(res interface{}, plan *queryPlan, planner *Planner) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, target := range n.targets {
+		null, err := setNull(res, plan.rootType, plan.selections, target.path, planner.flattener.types)
+		if err != nil {
+			return oops.Wrapf(err, "propagating null")
+		}
+		if null {
+			return target.err
+		}
+	}
+	return nil
+}
+
+// setNull sets the value at path in v, a value of type typ with the flattened
+// selections selectionSet, to null. It returns true if v itself must be null
+// because the value at path is not nullable.
+func setNull(v interface{}, typ graphql.Type, selectionSet *graphql.SelectionSet, path []interface{}, types map[string]graphql.Type) (bool, error) {
+	if v == nil {
+		// A parent was already set to null.
+		return false, nil
+	}
+	if len(path) == 0 {
+		return true, nil
+	}
+	if nonNull, ok := typ.(*graphql.NonNull); ok {
+		typ = nonNull.Type
+	}
+
+	switch typ := typ.(type) {
+	case *graphql.List:
+		list, ok := v.([]interface{})
+		if !ok {
+			return false, fmt.Errorf("not a list: %v", v)
+		}
+		i, ok := path[0].(int)
+		if !ok || i < 0 || i >= len(list) {
+			return false, fmt.Errorf("bad list index %v", path[0])
+		}
+		null, err := setNull(list[i], typ.Type, selectionSet, path[1:], types)
+		if err != nil || !null {
+			return false, err
+		}
+		if _, ok := typ.Type.(*graphql.NonNull); ok {
+			return true, nil
+		}
+		list[i] = nil
+		return false, nil
+
+	case *graphql.Union:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return false, fmt.Errorf("not an object: %v", v)
+		}
+		typename, _ := obj["__typename"].(string)
+		for _, fragment := range selectionSet.Fragments {
+			if fragment.On == typename {
+				return setNull(v, typ.Types[typename], fragment.SelectionSet, path, types)
+			}
+		}
+		return false, fmt.Errorf("unknown type %q in union %s", typename, typ.Name)
+
+	case *graphql.Object:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return false, fmt.Errorf("not an object: %v", v)
+		}
+		alias, ok := path[0].(string)
+		if !ok {
+			return false, fmt.Errorf("bad field %v", path[0])
+		}
+		for _, selection := range selectionSet.Selections {
+			if selection.Alias != alias {
+				continue
+			}
+			field, ok := typ.Fields[selection.Name]
+			if !ok {
+				return false, fmt.Errorf("unknown field %s on type %s", selection.Name, typ.Name)
+			}
+			null, err := setNull(obj[alias], field.Type, selection.SelectionSet, path[1:], types)
+			if err != nil || !null {
+				return false, err
+			}
+			if _, ok := field.Type.(*graphql.NonNull); ok {
+				return true, nil
+			}
+			obj[alias] = nil
+			return false, nil
+		}
+		return false, fmt.Errorf("unknown selection %s on type %s", alias, typ.Name)
+
+	default:
+		return false, fmt.Errorf("bad type %v", typ)
+	}
+}
+
+// nonNullSelections returns the aliases of the selections of p on non-null
+// fields.
+func nonNullSelections(p *Plan, planner *Planner) []string {
+	obj, ok := planner.flattener.types[p.Type].(*graphql.Object)
+	if !ok {
+		return nil
+	}
+	var aliases []string
+	for _, selection := range p.SelectionSet.Selections {
+		if field, ok := obj.Fields[selection.Name]; ok && selection.Alias != federationField {
+			if _, ok := field.Type.(*graphql.NonNull); ok {
+				aliases = append(aliases, selection.Alias)
+			}
+		}
+	}
+	return aliases
+}
+
+// executeBestEffort executes the best effort subplan p like execute, but gives
+// up once the best effort timeout expires. It then returns null results for
+// all the selections of p, and adds a warning for every target. If some of
+// the selections are not nullable, the targets are added to the null targets
+// of ctx, or executeBestEffort fails if ctx does not collect them.
+func (e *Executor) executeBestEffort(ctx context.Context, p *Plan, targets pathSubqueryMetadata, optionalArgs interface{}, planner *Planner) ([]interface{}, []interface{}, error) {
+	timeout := e.bestEffortTimeout
+	if timeout == 0 {
+		timeout = DefaultBestEffortTimeout
+	}
+	subCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		results  []interface{}
+		metadata []interface{}
+		err      error
+	}
+	// The channel is buffered so the subquery can finish after we gave up.
+	done := make(chan result, 1)
+	go func() {
+		results, metadata, err := e.execute(subCtx, p, targets.keys, targets.paths, optionalArgs, planner, nil)
+		done <- result{results: results, metadata: metadata, err: err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err == nil || subCtx.Err() != context.DeadlineExceeded {
+			return r.results, r.metadata, r.err
+		}
+	case <-subCtx.Done():
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var fields []string
+	for _, selection := range p.SelectionSet.Selections {
+		if selection.Alias != federationField {
+			fields = append(fields, selection.Alias)
+		}
+	}
+
+	var nulls *nullTargets
+	var nullErr error
+	if nonNull := nonNullSelections(p, planner); len(nonNull) > 0 {
+		nullErr = fmt.Errorf("%s did not respond within %v for non-null fields %s", p.Service, timeout, strings.Join(nonNull, ", "))
+		nulls, _ = ctx.Value(nullTargetsKey{}).(*nullTargets)
+		if nulls == nil {
+			return nil, nil, nullErr
+		}
+	}
+
+	results := make([]interface{}, len(targets.results))
+	for i := range targets.results {
+		nullFields := make(map[string]interface{}, len(fields))
+		for _, field := range fields {
+			nullFields[field] = nil
+		}
+		results[i] = nullFields
+
+		var path []interface{}
+		if i < len(targets.paths) {
+			path = targets.paths[i]
+		}
+		addWarning(ctx, &Warning{
+			Path:    path,
+			Fields:  fields,
+			Message: fmt.Sprintf("%s did not respond within %v", p.Service, timeout),
+		})
+		if nulls != nil {
+			nulls.mu.Lock()
+			nulls.targets = append(nulls.targets, nullTarget{path: path, err: nullErr})
+			nulls.mu.Unlock()
+		}
+	}
+	return results, nil, nil
+}
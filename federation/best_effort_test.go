@@ -0,0 +1,149 @@
+package federation
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+)
+
+// slowExecutorClient delays every query by delay.
+type slowExecutorClient struct {
+	client ExecutorClient
+	delay  int64
+}
+
+func (c *slowExecutorClient) setDelay(delay time.Duration) {
+	atomic.StoreInt64(&c.delay, int64(delay))
+}
+
+func (c *slowExecutorClient) Execute(ctx context.Context, request *QueryRequest) (*QueryResponse, error) {
+	select {
+	case <-time.After(time.Duration(atomic.LoadInt64(&c.delay))):
+		return c.client.Execute(ctx, request)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestExecutorBestEffort(t *testing.T) {
+	_, s1, s2, s3, err := createExecutorWithFederatedUser()
+	require.NoError(t, err)
+	execs, err := makeExecutors(map[string]*schemabuilder.Schema{
+		"s1": s1,
+		"s2": s2,
+		"s3": s3,
+	})
+	require.NoError(t, err)
+	slow := &slowExecutorClient{client: execs["s3"]}
+	execs["s3"] = slow
+
+	ctx := context.Background()
+	e, err := NewExecutor(ctx, execs, &CustomExecutorArgs{
+		BestEffortTimeout: 20 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	// Slow down s3 once the schemas have been fetched.
+	slow.setDelay(time.Second)
+
+	var warnings WarningCollector
+	res, _, err := e.Execute(WithWarningCollector(ctx, &warnings), graphql.MustParse(`
+		{
+			users {
+				id
+				name
+				deviceWithArgs(id: 1, temp: 70) @bestEffort {
+					temp
+				}
+			}
+		}`, nil), nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"users": []interface{}{
+			map[string]interface{}{"__key": float64(1), "id": float64(1), "name": "testUser", "deviceWithArgs": nil},
+			map[string]interface{}{"__key": float64(2), "id": float64(2), "name": "testUser2", "deviceWithArgs": nil},
+		},
+	}, res)
+	assert.Equal(t, []*Warning{
+		{Path: []interface{}{"users", 0}, Fields: []string{"deviceWithArgs"}, Message: "s3 did not respond within 20ms"},
+		{Path: []interface{}{"users", 1}, Fields: []string{"deviceWithArgs"}, Message: "s3 did not respond within 20ms"},
+	}, warnings.Warnings())
+
+	// Selections without the directive wait for the service.
+	slow.setDelay(50 * time.Millisecond)
+	runAndValidateQueryResults(t, ctx, e, `
+		{
+			users {
+				id
+				privelages
+			}
+		}`, `
+		{
+			"users": [
+				{"__key": 1, "id": 1, "privelages": "all"},
+				{"__key": 2, "id": 2, "privelages": "all"}
+			]
+		}`)
+
+	_, _, err = e.Execute(ctx, graphql.MustParse(`mutation { users @bestEffort { id } }`, nil), nil)
+	assert.Error(t, err)
+}
+
+func TestExecutorBestEffortNonNull(t *testing.T) {
+	_, s1, s2, s3, err := createExecutorWithFederatedUser()
+	require.NoError(t, err)
+	execs, err := makeExecutors(map[string]*schemabuilder.Schema{
+		"s1": s1,
+		"s2": s2,
+		"s3": s3,
+	})
+	require.NoError(t, err)
+	slow := &slowExecutorClient{client: execs["s3"]}
+	execs["s3"] = slow
+
+	ctx := context.Background()
+	e, err := NewExecutor(ctx, execs, &CustomExecutorArgs{
+		BestEffortTimeout: 20 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	slow.setDelay(time.Second)
+
+	// temp is non-null, so the devices holding it are nulled instead.
+	query := `
+		{
+			users {
+				id
+				device {
+					id
+					temp @bestEffort
+				}
+			}
+		}`
+	expected := map[string]interface{}{
+		"users": []interface{}{
+			map[string]interface{}{"__key": float64(1), "id": float64(1), "device": nil},
+			map[string]interface{}{"__key": float64(2), "id": float64(2), "device": nil},
+		},
+	}
+	var warnings WarningCollector
+	res, _, err := e.Execute(WithWarningCollector(ctx, &warnings), graphql.MustParse(query, nil), nil)
+	require.NoError(t, err)
+	assert.Equal(t, expected, res)
+	assert.Len(t, warnings.Warnings(), 2)
+
+	// Users and the list holding them are non-null, so the whole query fails.
+	_, _, err = e.Execute(ctx, graphql.MustParse(`
+		{
+			users {
+				id
+				privelages @bestEffort
+			}
+		}`, nil), nil)
+	assert.EqualError(t, err, "s3 did not respond within 20ms for non-null fields privelages")
+}
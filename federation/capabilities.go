@@ -0,0 +1,193 @@
+package federation
+
+import (
+	"context"
+	"sync"
+
+	"github.com/samsarahq/go/oops"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+)
+
+// ProtocolVersion is the version of the protocol between gateways and
+// services implemented by this package.
+const ProtocolVersion = 1
+
+// capabilitiesField is the root field services expose their Capabilities on.
+const capabilitiesField = "__capabilities"
+
+// Capabilities describes the optional features of the gateway protocol a
+// service supports. Gateways fetch the capabilities of every service when its
+// schema changes, and adapt how they query it. Compression is not part of
+// the protocol, but of the transport of ExecutorClients.
+type Capabilities struct {
+	// ProtocolVersion is the version of the protocol the service implements.
+	ProtocolVersion int64
+	// Batching is true if the service resolves federated objects for many
+	// keys in one request. Otherwise the gateway fetches federated objects
+	// one key at a time.
+	Batching bool
+	// Defer is true if subqueries of the service may be deferred by
+	// ExecuteWithDefer. Otherwise they are always resolved before the initial
+	// response.
+	Defer bool
+}
+
+// DefaultCapabilities are the capabilities of services built with this
+// package.
+var DefaultCapabilities = Capabilities{
+	ProtocolVersion: ProtocolVersion,
+	Batching:        true,
+	Defer:           true,
+}
+
+// legacyCapabilities are the capabilities assumed for services that do not
+// expose their capabilities, which supported batching and defer before
+// capabilities were negotiated.
+var legacyCapabilities = Capabilities{
+	Batching: true,
+	Defer:    true,
+}
+
+// AddCapabilities exposes the capabilities of a service on its schema, so
+// gateways adapt to them. Services without capabilities are assumed to
+// support batching and defer.
+func AddCapabilities(schema *schemabuilder.Schema, capabilities Capabilities) {
+	schema.Object("__Capabilities", Capabilities{})
+	schema.Query().FieldFunc(capabilitiesField, func() *Capabilities {
+		return &capabilities
+	})
+}
+
+// capabilities returns the capabilities of service.
+func (p *Planner) capabilities(service string) Capabilities {
+	if capabilities, ok := p.serviceCapabilities[service]; ok {
+		return capabilities
+	}
+	return legacyCapabilities
+}
+
+// fetchCapabilities fetches the capabilities of all services of planner
+// concurrently. Services whose schema is unchanged since the previous
+// planner, and services whose capabilities cannot be fetched, keep those of
+// the previous planner, if any. It fails if a service implements an
+// unsupported protocol version.
+func (e *Executor) fetchCapabilities(ctx context.Context, planner *Planner, previous *Planner, optionalArgs interface{}) (map[string]Capabilities, error) {
+	var hasCapabilities map[string]bool
+	if field, ok := planner.schema.Schema.Query.(*graphql.Object).Fields[capabilitiesField]; ok {
+		hasCapabilities = planner.schema.Fields[field].Services
+	}
+
+	selectionSet := &graphql.SelectionSet{
+		Selections: []*graphql.Selection{{
+			Name:  capabilitiesField,
+			Alias: capabilitiesField,
+			Args:  map[string]interface{}{},
+			SelectionSet: &graphql.SelectionSet{
+				Selections: []*graphql.Selection{
+					{Name: "protocolVersion", Alias: "protocolVersion", Args: map[string]interface{}{}},
+					{Name: "batching", Alias: "batching", Args: map[string]interface{}{}},
+					{Name: "defer", Alias: "defer", Args: map[string]interface{}{}},
+				},
+			},
+		}},
+	}
+
+	var mu sync.Mutex
+	capabilities := make(map[string]Capabilities, len(hasCapabilities))
+	var g errgroup.Group
+	for service, ok := range hasCapabilities {
+		if !ok {
+			continue
+		}
+		service := service
+		if previous != nil && planner.schemaVersions[service] != "" && planner.schemaVersions[service] == previous.schemaVersions[service] {
+			if c, ok := previous.serviceCapabilities[service]; ok {
+				mu.Lock()
+				capabilities[service] = c
+				mu.Unlock()
+				continue
+			}
+		}
+		g.Go(func() error {
+			res, _, err := e.runOnService(ctx, service, "", nil, queryString, selectionSet, optionalArgs, planner)
+			var c Capabilities
+			if err == nil {
+				c, err = parseCapabilities(res)
+			}
+			if err == nil && (c.ProtocolVersion < 1 || c.ProtocolVersion > ProtocolVersion) {
+				return oops.Errorf("service %s implements unsupported protocol version %d, expected at most %d", service, c.ProtocolVersion, ProtocolVersion)
+			}
+			if err != nil {
+				if previous == nil {
+					return nil
+				}
+				var ok bool
+				if c, ok = previous.serviceCapabilities[service]; !ok {
+					return nil
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			capabilities[service] = c
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return capabilities, nil
+}
+
+// parseCapabilities parses the result of a capabilities query.
+func parseCapabilities(res []interface{}) (Capabilities, error) {
+	if len(res) != 1 {
+		return Capabilities{}, oops.Errorf("expected one result, got %d", len(res))
+	}
+	result, ok := res[0].(map[string]interface{})
+	if !ok {
+		return Capabilities{}, oops.Errorf("result is not an object")
+	}
+	fields, ok := result[capabilitiesField].(map[string]interface{})
+	if !ok {
+		return Capabilities{}, oops.Errorf("%s is not an object", capabilitiesField)
+	}
+
+	var c Capabilities
+	if version, ok := fields["protocolVersion"].(float64); ok {
+		c.ProtocolVersion = int64(version)
+	}
+	c.Batching, _ = fields["batching"].(bool)
+	c.Defer, _ = fields["defer"].(bool)
+	return c, nil
+}
+
+// runOnServiceUnbatched runs a subquery for federated objects on a service
+// that does not support batching, with one request per key. The metadata of
+// the requests is returned as a list.
+func (e *Executor) runOnServiceUnbatched(ctx context.Context, service string, typName string, keys []interface{}, kind string, selectionSet *graphql.SelectionSet, optionalArgs interface{}, planner *Planner) ([]interface{}, interface{}, error) {
+	results := make([]interface{}, len(keys))
+	metadata := make([]interface{}, len(keys))
+	g, ctx := errgroup.WithContext(ctx)
+	for i, key := range keys {
+		i, key := i, key
+		g.Go(func() error {
+			res, m, err := e.runOnService(ctx, service, typName, []interface{}{key}, kind, selectionSet, optionalArgs, planner)
+			if err != nil {
+				return err
+			}
+			if len(res) != 1 {
+				return oops.Errorf("got %d results for 1 key", len(res))
+			}
+			results[i], metadata[i] = res[0], m
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, nil, err
+	}
+	return results, metadata, nil
+}
@@ -0,0 +1,140 @@
+package federation
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+)
+
+// recordingExecutorClient records the keys of the requests for federated
+// objects, and counts the requests for capabilities.
+type recordingExecutorClient struct {
+	ExecutorClient
+
+	mu           sync.Mutex
+	requests     int
+	keys         []int
+	capabilities int
+}
+
+func (c *recordingExecutorClient) Execute(ctx context.Context, request *QueryRequest) (*QueryResponse, error) {
+	c.mu.Lock()
+	c.requests++
+	for _, selection := range request.Query.SelectionSet.Selections {
+		switch selection.Name {
+		case federationField:
+			keys := selection.SelectionSet.Selections[0].UnparsedArgs["keys"].([]interface{})
+			c.keys = append(c.keys, len(keys))
+		case capabilitiesField:
+			c.capabilities++
+		}
+	}
+	c.mu.Unlock()
+	return c.ExecutorClient.Execute(ctx, request)
+}
+
+func TestExecutorCapabilities(t *testing.T) {
+	ctx := context.Background()
+
+	type User struct {
+		Id int64
+	}
+	type userKeys struct {
+		Id int64
+	}
+
+	users := schemabuilder.NewSchemaWithName("users")
+	users.Object("User", User{}, schemabuilder.RootObject).Key("id")
+	users.Query().FieldFunc("users", func() []*User {
+		return []*User{{Id: 1}, {Id: 2}, {Id: 3}}
+	})
+
+	profiles := schemabuilder.NewSchemaWithName("profiles")
+	profiles.FederatedFieldFunc("User", func(args struct{ Keys []userKeys }) []*User {
+		out := make([]*User, 0, len(args.Keys))
+		for _, key := range args.Keys {
+			out = append(out, &User{Id: key.Id})
+		}
+		return out
+	})
+	user := profiles.Object("User", User{})
+	user.Key("id")
+	user.FieldFunc("score", func(u *User) int64 { return u.Id * 10 })
+	AddCapabilities(profiles, Capabilities{
+		ProtocolVersion: ProtocolVersion,
+	})
+	AddCapabilities(users, DefaultCapabilities)
+
+	execs, err := makeExecutors(map[string]*schemabuilder.Schema{
+		"users":    users,
+		"profiles": profiles,
+	})
+	require.NoError(t, err)
+	recording := &recordingExecutorClient{ExecutorClient: execs["profiles"]}
+	execs["profiles"] = recording
+
+	e, err := NewExecutor(ctx, execs, &CustomExecutorArgs{})
+	require.NoError(t, err)
+
+	planner := e.getPlanner()
+	assert.Equal(t, map[string]Capabilities{
+		"users":    DefaultCapabilities,
+		"profiles": {ProtocolVersion: ProtocolVersion},
+	}, planner.serviceCapabilities)
+	assert.Equal(t, legacyCapabilities, planner.capabilities(GatewayServiceName))
+
+	// Services without batching get one request per key.
+	expected := `{
+		"users": [
+			{"__key": 1, "id": 1, "score": 10},
+			{"__key": 2, "id": 2, "score": 20},
+			{"__key": 3, "id": 3, "score": 30}
+		]
+	}`
+	runAndValidateQueryResults(t, ctx, e, `{ users { id score } }`, expected)
+	assert.Equal(t, []int{1, 1, 1}, recording.keys)
+
+	// Services without defer are resolved before the initial response.
+	res, _, patches, err := e.ExecuteWithDefer(ctx, graphql.MustParse(`{ users { id score } }`, nil), nil)
+	require.NoError(t, err)
+	for patch := range patches {
+		t.Errorf("unexpected patch %v", patch)
+	}
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"__key": float64(1), "id": float64(1), "score": float64(10)},
+		map[string]interface{}{"__key": float64(2), "id": float64(2), "score": float64(20)},
+		map[string]interface{}{"__key": float64(3), "id": float64(3), "score": float64(30)},
+	}, res.(map[string]interface{})["users"])
+
+	// Capabilities are not part of the client schema.
+	client := clientSchema(planner.schema.Schema, nil)
+	assert.NotContains(t, client.Query.(*graphql.Object).Fields, capabilitiesField)
+
+	// Capabilities are only fetched again when the schema of a service
+	// changes.
+	assert.Equal(t, 1, recording.capabilities)
+	newPlanner, err := e.syncer.schemaSyncer.FetchPlanner(ctx, nil)
+	require.NoError(t, err)
+	require.NoError(t, e.setPlanner(ctx, newPlanner, nil))
+	assert.Equal(t, 1, recording.capabilities)
+	assert.Equal(t, planner.serviceCapabilities, e.getPlanner().serviceCapabilities)
+}
+
+func TestExecutorProtocolVersion(t *testing.T) {
+	s := schemabuilder.NewSchemaWithName("s")
+	s.Query().FieldFunc("motd", func() string { return "hello" })
+	AddCapabilities(s, Capabilities{ProtocolVersion: ProtocolVersion + 1})
+
+	execs, err := makeExecutors(map[string]*schemabuilder.Schema{"s": s})
+	require.NoError(t, err)
+	_, err = NewExecutor(context.Background(), execs, &CustomExecutorArgs{})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "service s implements unsupported protocol version 2, expected at most 1")
+	}
+}
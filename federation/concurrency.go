@@ -0,0 +1,131 @@
+package federation
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/samsarahq/go/oops"
+
+	"github.com/denkhaus/thunder/concurrencylimiter"
+)
+
+// ConcurrencyLimit bounds the subqueries in flight to a service, so a slow
+// service accumulates a bounded amount of work in the gateway instead of an
+// unbounded number of goroutines and connections. Subqueries beyond the limit
+// wait for earlier subqueries to finish.
+type ConcurrencyLimit struct {
+	// MaxInFlight is the maximum number of concurrent subqueries.
+	MaxInFlight int
+	// MaxQueued is the maximum number of subqueries waiting to be sent.
+	// Subqueries beyond it fail immediately. 0 means no limit.
+	MaxQueued int
+}
+
+// ServiceConcurrency describes the subqueries to a service with a
+// ConcurrencyLimit.
+type ServiceConcurrency struct {
+	Service string `json:"service"`
+	// MaxInFlight is the service's limit, InFlight the number of subqueries
+	// being sent, and Queued the number waiting to be sent.
+	MaxInFlight int `json:"maxInFlight"`
+	InFlight    int `json:"inFlight"`
+	Queued      int `json:"queued"`
+	// Waited counts the subqueries that had to wait, and WaitTime is the
+	// total time they waited.
+	Waited   uint64        `json:"waited"`
+	WaitTime time.Duration `json:"waitTime"`
+	// Rejected counts the subqueries that failed because the queue was full
+	// or their query was canceled while waiting.
+	Rejected uint64 `json:"rejected"`
+}
+
+// concurrencyLimiter enforces the ConcurrencyLimit of a service.
+type concurrencyLimiter struct {
+	lane      *concurrencylimiter.Lane
+	maxQueued int
+
+	mu    sync.Mutex
+	stats ServiceConcurrency
+}
+
+func newConcurrencyLimiters(limits map[string]ConcurrencyLimit) map[string]*concurrencyLimiter {
+	limiters := make(map[string]*concurrencyLimiter, len(limits))
+	for service, limit := range limits {
+		limiters[service] = &concurrencyLimiter{
+			lane:      concurrencylimiter.NewLane(limit.MaxInFlight),
+			maxQueued: limit.MaxQueued,
+			stats: ServiceConcurrency{
+				Service:     service,
+				MaxInFlight: limit.MaxInFlight,
+			},
+		}
+	}
+	return limiters
+}
+
+// acquire waits until a subquery may be sent to the service. The subquery
+// must call the returned function once it is done.
+func (l *concurrencyLimiter) acquire(ctx context.Context) (context.Context, concurrencylimiter.ReleaseFunc, error) {
+	if ctx, release, ok := l.lane.TryAcquire(ctx); ok {
+		return ctx, release, nil
+	}
+
+	l.mu.Lock()
+	if l.maxQueued > 0 && l.stats.Queued >= l.maxQueued {
+		l.stats.Rejected++
+		l.mu.Unlock()
+		return nil, nil, oops.Errorf("service %s has %d subqueries queued", l.stats.Service, l.maxQueued)
+	}
+	l.stats.Queued++
+	l.mu.Unlock()
+
+	start := time.Now()
+	ctx, release := l.lane.Acquire(ctx)
+	waited := time.Since(start)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stats.Queued--
+	l.stats.Waited++
+	l.stats.WaitTime += waited
+	// The lane succeeds without a token once ctx is done.
+	if err := ctx.Err(); err != nil {
+		release()
+		l.stats.Rejected++
+		return nil, nil, oops.Wrapf(err, "waiting for service %s", l.stats.Service)
+	}
+	return ctx, release, nil
+}
+
+// status returns the concurrency of the service.
+func (l *concurrencyLimiter) status() ServiceConcurrency {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stats := l.stats
+	stats.InFlight = l.lane.InUse()
+	return stats
+}
+
+// limitConcurrency waits until a subquery may be sent to service, if it has
+// a ConcurrencyLimit. The subquery must call the returned function once it is
+// done.
+func (e *Executor) limitConcurrency(ctx context.Context, service string) (context.Context, concurrencylimiter.ReleaseFunc, error) {
+	limiter, ok := e.concurrency[service]
+	if !ok {
+		return ctx, func() {}, nil
+	}
+	return limiter.acquire(ctx)
+}
+
+// ServiceConcurrency returns the concurrency of the services with a
+// ConcurrencyLimit, sorted by service.
+func (e *Executor) ServiceConcurrency() []ServiceConcurrency {
+	services := make([]ServiceConcurrency, 0, len(e.concurrency))
+	for _, limiter := range e.concurrency {
+		services = append(services, limiter.status())
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].Service < services[j].Service })
+	return services
+}
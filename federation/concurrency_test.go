@@ -0,0 +1,139 @@
+package federation
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/graphql"
+)
+
+// gatedClient holds requests until its gate is opened, once enabled, and
+// tracks how many requests it handles at once.
+type gatedClient struct {
+	client ExecutorClient
+	gate   chan struct{}
+
+	mu          sync.Mutex
+	enabled     bool
+	inFlight    int
+	maxInFlight int
+}
+
+func (c *gatedClient) Execute(ctx context.Context, request *QueryRequest) (*QueryResponse, error) {
+	c.mu.Lock()
+	enabled, gate := c.enabled, c.gate
+	c.inFlight++
+	if c.inFlight > c.maxInFlight {
+		c.maxInFlight = c.inFlight
+	}
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.inFlight--
+		c.mu.Unlock()
+	}()
+
+	if enabled {
+		<-gate
+	}
+	return c.client.Execute(ctx, request)
+}
+
+func TestServiceConcurrency(t *testing.T) {
+	failover, _, s3 := makeFailoverExecutor(t)
+	execs := failover.Executors
+	s2 := &gatedClient{client: execs["s2"], gate: make(chan struct{})}
+	execs["s2"] = s2
+
+	e, err := NewExecutor(context.Background(), execs, &CustomExecutorArgs{
+		ServiceConcurrency: map[string]ConcurrencyLimit{
+			"s2": {MaxInFlight: 2, MaxQueued: 2},
+		},
+	})
+	require.NoError(t, err)
+	s2.mu.Lock()
+	s2.enabled = true
+	s2.mu.Unlock()
+	s3.mu.Lock()
+	s3.calls = 0
+	s3.mu.Unlock()
+
+	// Two subqueries are sent, two are queued, and the last one fails over
+	// to s3 because the queue is full.
+	results := make(chan interface{}, 5)
+	for i := 0; i < 5; i++ {
+		go func() {
+			res, _, err := e.Execute(context.Background(), graphql.MustParse(`{ motd }`, nil), nil)
+			assert.NoError(t, err)
+			results <- res
+		}()
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		stats := e.ServiceConcurrency()
+		require.Len(t, stats, 1)
+		if stats[0].InFlight == 2 && stats[0].Queued == 2 && stats[0].Rejected == 1 {
+			break
+		}
+		require.True(t, time.Now().Before(deadline), "expected requests to be queued, got %+v", stats[0])
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, map[string]interface{}{"motd": "hello from s3"}, <-results)
+	s3.mu.Lock()
+	assert.Equal(t, 1, s3.calls)
+	s3.mu.Unlock()
+
+	close(s2.gate)
+	for i := 0; i < 4; i++ {
+		assert.Equal(t, map[string]interface{}{"motd": "hello from s2"}, <-results)
+	}
+	s2.mu.Lock()
+	assert.Equal(t, 2, s2.maxInFlight)
+	s2.mu.Unlock()
+
+	stats := e.ServiceConcurrency()[0]
+	assert.Equal(t, "s2", stats.Service)
+	assert.Equal(t, 2, stats.MaxInFlight)
+	assert.Equal(t, 0, stats.InFlight)
+	assert.Equal(t, 0, stats.Queued)
+	assert.Equal(t, uint64(2), stats.Waited)
+	assert.Equal(t, uint64(1), stats.Rejected)
+
+	// Canceled queries stop waiting.
+	gate := make(chan struct{})
+	s2.mu.Lock()
+	s2.gate = gate
+	s2.mu.Unlock()
+	done := make(chan struct{})
+	for i := 0; i < 2; i++ {
+		go func() {
+			e.Execute(context.Background(), graphql.MustParse(`{ motd }`, nil), nil)
+			done <- struct{}{}
+		}()
+	}
+	for e.ServiceConcurrency()[0].InFlight < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err = e.runOnService(ctx, "s2", "Query", nil, queryString, graphql.MustParse(`{ motd }`, nil).SelectionSet, nil, e.getPlanner())
+	assert.Error(t, err)
+	close(gate)
+	<-done
+	<-done
+
+	_, err = NewExecutor(context.Background(), execs, &CustomExecutorArgs{
+		ServiceConcurrency: map[string]ConcurrencyLimit{"s4": {MaxInFlight: 1}},
+	})
+	assert.Error(t, err)
+	_, err = NewExecutor(context.Background(), execs, &CustomExecutorArgs{
+		ServiceConcurrency: map[string]ConcurrencyLimit{"s2": {}},
+	})
+	assert.Error(t, err)
+}
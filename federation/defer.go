@@ -0,0 +1,156 @@
+package federation
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/samsarahq/go/oops"
+
+	"github.com/denkhaus/thunder/graphql"
+)
+
+// DeferredPatch is an incremental result delivered by ExecuteWithDefer after
+// the initial response. Data holds fields resolved by another service that
+// should be merged into the object found at Path in the initial response.
+type DeferredPatch struct {
+	// Path locates the object in the initial response, as a list of field
+	// aliases (strings) and list indices (ints).
+	Path []interface{}
+	// Data holds the fields to merge into the object at Path.
+	Data map[string]interface{}
+	// Metadata holds the response metadata of the services that resolved the
+	// patch. Patches resolved by the same subquery share their metadata.
+	Metadata []interface{}
+	// Err is set if the deferred subquery failed. Path and Data are then empty.
+	Err error
+}
+
+// deferredExecution tracks the deferred subqueries of a single query.
+type deferredExecution struct {
+	ctx     context.Context
+	wg      sync.WaitGroup
+	patches chan *DeferredPatch
+}
+
+// send delivers a patch, giving up if the query has been canceled.
+func (d *deferredExecution) send(patch *DeferredPatch) {
+	select {
+	case d.patches <- patch:
+	case <-d.ctx.Done():
+	}
+}
+
+// executeDeferred executes the subquery p for the given targets in the
+// background, and delivers a patch for every target once it completes.
+func (e *Executor) executeDeferred(d *deferredExecution, p *Plan, targets pathSubqueryMetadata, optionalArgs interface{}, planner *Planner) {
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+
+		// Deferred results cannot null values of the initial response, so
+		// dropped non-null best effort selections fail the patch.
+		ctx := withoutNullTargets(d.ctx)
+		executionResults, metadata, err := e.execute(ctx, p, targets.keys, targets.paths, optionalArgs, planner, nil)
+		if err == nil && len(executionResults) != len(targets.paths) {
+			err = fmt.Errorf("got %d results for %d targets", len(executionResults), len(targets.paths))
+		}
+		if err != nil {
+			d.send(&DeferredPatch{Err: oops.Wrapf(err, "executing deferred sub plan")})
+			return
+		}
+
+		for i, path := range targets.paths {
+			data, ok := executionResults[i].(map[string]interface{})
+			if !ok {
+				d.send(&DeferredPatch{Err: fmt.Errorf("result is not an object: %v", executionResults[i])})
+				continue
+			}
+			deleteKey(data, federationField)
+			d.send(&DeferredPatch{
+				Path:     path,
+				Data:     data,
+				Metadata: metadata,
+			})
+		}
+	}()
+}
+
+// ExecuteWithDefer executes query like Execute, but returns as soon as the
+// services queried at the root have responded. Subqueries that depend on the
+// results of another service run in the background, and their results are
+// delivered as DeferredPatches on the returned channel. The channel is closed
+// once all deferred subqueries have completed or ctx is canceled.
+//
+// Callers must drain the channel or cancel ctx to release the deferred
+// subqueries.
+func (e *Executor) ExecuteWithDefer(ctx context.Context, query *graphql.Query, optionalArgs interface{}) (interface{}, []interface{}, <-chan *DeferredPatch, error) {
+	if e.requirePersisted {
+		return nil, nil, nil, errNotPersisted
+	}
+	if err := e.checkMaintenance(query.Kind); err != nil {
+		return nil, nil, nil, err
+	}
+
+	planner := e.getPlanner()
+	plan, err := planner.planQuery(query)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var localRes map[string]interface{}
+	if plan.local != nil {
+		localRes, err = e.executeGatewaySelections(ctx, planner, plan.local, optionalArgs)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	if plan.remote == nil {
+		// Nothing is deferred for queries resolved by the gateway alone.
+		patches := make(chan *DeferredPatch)
+		close(patches)
+		return localRes, nil, patches, nil
+	}
+	if e.usage != nil {
+		e.usage.record(plan.remote, planner)
+	}
+
+	ctx, cancel := context.WithCancel(startTrace(ctx))
+	deferred := &deferredExecution{
+		ctx:     ctx,
+		patches: make(chan *DeferredPatch),
+	}
+
+	executeCtx := ctx
+	var nulls *nullTargets
+	if plan.bestEffort {
+		executeCtx, nulls = withNullTargets(ctx)
+	}
+	r, responseMetadata, err := e.execute(executeCtx, plan.remote, nil, nil, optionalArgs, planner, deferred)
+	// All deferred subqueries have been started by now, so close the channel
+	// once they are done.
+	go func() {
+		deferred.wg.Wait()
+		cancel()
+		close(deferred.patches)
+	}()
+	if err != nil {
+		cancel()
+		return nil, nil, nil, err
+	}
+
+	if len(r) != 1 {
+		cancel()
+		return nil, nil, nil, oops.Errorf("Multiple results, expected one %v", r)
+	}
+	res := r[0]
+	deleteKey(res, federationField)
+	if nulls != nil {
+		if err := nulls.propagate(res, plan, planner); err != nil {
+			cancel()
+			return nil, nil, nil, err
+		}
+	}
+	mergeGatewayResults(res, localRes)
+	return res, responseMetadata, deferred.patches, nil
+}
@@ -0,0 +1,104 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/graphql"
+)
+
+// applyPatch merges a deferred patch into a response.
+func applyPatch(t *testing.T, res interface{}, patch *DeferredPatch) {
+	node := res
+	for _, step := range patch.Path {
+		switch step := step.(type) {
+		case string:
+			node = node.(map[string]interface{})[step]
+		case int:
+			node = node.([]interface{})[step]
+		default:
+			t.Fatalf("bad path step %v", step)
+		}
+	}
+	obj := node.(map[string]interface{})
+	for k, v := range patch.Data {
+		obj[k] = v
+	}
+}
+
+func TestExecutorWithDefer(t *testing.T) {
+	e, _, _, _, err := createExecutorWithFederatedUser()
+	require.NoError(t, err)
+
+	query := `
+		query Foo {
+			users {
+				id
+				device {
+					id
+					isOn
+					temp
+				}
+			}
+		}`
+
+	ctx := context.Background()
+	res, _, patches, err := e.ExecuteWithDefer(ctx, graphql.MustParse(query, map[string]interface{}{}), nil)
+	require.NoError(t, err)
+
+	// The initial response only holds the fields resolved by s1.
+	var expectedInitial interface{}
+	require.NoError(t, json.Unmarshal([]byte(`
+		{
+			"users":[
+				{
+					"__key":1,
+					"id":1,
+					"device":{"__key":1, "id":1, "isOn":true}
+				},
+				{
+					"__key":2,
+					"id":2,
+					"device":{"__key":1, "id":1, "isOn":true}
+				}
+			]
+		}`), &expectedInitial))
+	assert.Equal(t, expectedInitial, res)
+
+	var paths []string
+	for patch := range patches {
+		require.NoError(t, patch.Err)
+		path, err := json.Marshal(patch.Path)
+		require.NoError(t, err)
+		paths = append(paths, string(path))
+		applyPatch(t, res, patch)
+	}
+	sort.Strings(paths)
+	assert.Equal(t, []string{`["users",0,"device"]`, `["users",1,"device"]`}, paths)
+
+	// After applying all patches, the response matches a non-deferred execution.
+	expected, _, err := e.Execute(ctx, graphql.MustParse(query, map[string]interface{}{}), nil)
+	require.NoError(t, err)
+	assert.Equal(t, expected, res)
+}
+
+func TestExecutorWithDeferSingleService(t *testing.T) {
+	e, _, _, _, err := createExecutorWithFederatedUser()
+	require.NoError(t, err)
+
+	res, _, patches, err := e.ExecuteWithDefer(context.Background(), graphql.MustParse(`{ users { id } }`, map[string]interface{}{}), nil)
+	require.NoError(t, err)
+
+	for patch := range patches {
+		t.Errorf("unexpected patch %v", patch)
+	}
+
+	var expected interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{"users":[{"__key":1,"id":1},{"__key":2,"id":2}]}`), &expected))
+	assert.Equal(t, expected, res)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log"
 	"reflect"
 	"sync"
 	"time"
@@ -11,8 +12,8 @@ import (
 	"github.com/samsarahq/go/oops"
 	"golang.org/x/sync/errgroup"
 
-	"github.com/samsarahq/thunder/graphql"
-	"github.com/samsarahq/thunder/graphql/introspection"
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/introspection"
 )
 
 const keyField = "__key"
@@ -47,6 +48,33 @@ type ExecutorClient interface {
 type Executor struct {
 	Executors map[string]ExecutorClient
 	syncer    *Syncer
+	usage     *UsageRecorder
+
+	bestEffortTimeout time.Duration
+	throttler         *throttler
+	namespaces        map[string]*TypeNamespace
+	plugins           map[string][]ServicePlugin
+
+	maintenanceMu sync.Mutex
+	maintenance   *Maintenance
+
+	// migrations holds the FieldMigrations by type and field name.
+	migrations       map[string]map[string]FieldMigration
+	onShadowMismatch func(mismatch *ShadowMismatch)
+	shadowTimeout    time.Duration
+
+	// concurrency enforces the ServiceConcurrency limits, by service name.
+	concurrency map[string]*concurrencyLimiter
+
+	mergePolicy     MergePolicy
+	onMergeConflict func(conflict *MergeConflict)
+
+	// requirePersisted restricts queries to persisted operations, see
+	// CustomExecutorArgs.RequirePersistedOperations.
+	requirePersisted bool
+
+	gatewayIntrospection bool
+	servicesField        bool
 }
 
 // Syncer checks if there is a new schema available and then updates the planner as needed
@@ -55,6 +83,11 @@ type Syncer struct {
 	schemaSyncer SchemaSyncer
 	plannerMu    *sync.RWMutex
 	planner      *Planner
+
+	// persisted holds the persisted operations, and planned their plans
+	// against planner.
+	persisted PersistedOperations
+	planned   map[string]*persistedOperation
 }
 
 func (e *Executor) getPlanner() *Planner {
@@ -63,10 +96,37 @@ func (e *Executor) getPlanner() *Planner {
 	return e.syncer.planner
 }
 
-func (e *Executor) setPlanner(p *Planner) {
+// getPlans returns the planner and the persisted operations planned with it.
+func (e *Executor) getPlans() (*Planner, map[string]*persistedOperation) {
+	e.syncer.plannerMu.RLock()
+	defer e.syncer.plannerMu.RUnlock()
+	return e.syncer.planner, e.syncer.planned
+}
+
+// setPlanner switches to planner p, unless the persisted operations are
+// invalid for p. It fetches the capabilities of the services of p.
+func (e *Executor) setPlanner(ctx context.Context, p *Planner, optionalArgs interface{}) error {
+	gateway, err := e.makeGatewaySchema(p.schema.Schema)
+	if err != nil {
+		return oops.Wrapf(err, "building gateway fields")
+	}
+	p.gateway = gateway
+	p.migrations = e.migrations
+	p.serviceCapabilities, err = e.fetchCapabilities(ctx, p, e.getPlanner(), optionalArgs)
+	if err != nil {
+		return oops.Wrapf(err, "fetching capabilities")
+	}
+
+	planned, err := planPersistedOperations(p, e.syncer.persisted)
+	if err != nil {
+		return err
+	}
+
 	e.syncer.plannerMu.Lock()
 	defer e.syncer.plannerMu.Unlock()
 	e.syncer.planner = p
+	e.syncer.planned = planned
+	return nil
 }
 
 func NewPlanner(types *SchemaWithFederationInfo) (*Planner, error) {
@@ -99,17 +159,165 @@ type CustomExecutorArgs struct {
 	SchemaSyncer              SchemaSyncer
 	OptionalArgs              interface{}
 	SchemaSyncIntervalSeconds func(ctx context.Context) int64
+	// UsageRecorder, if set, records which fields are requested by queries.
+	UsageRecorder *UsageRecorder
+	// GatewaySchema, if set, holds gateway resolvers: fields that are resolved
+	// in the gateway process, such as fields combining data from multiple
+	// services. It is served as the service GatewayServiceName, and can add
+	// root fields and fields on federated objects like any other service.
+	// Custom SchemaSyncers must include its schema.
+	GatewaySchema *graphql.Schema
+	// BestEffortTimeout is how long the gateway waits for subqueries of
+	// selections marked @bestEffort. It defaults to DefaultBestEffortTimeout.
+	BestEffortTimeout time.Duration
+	// PersistedOperations, if set, are the operations run by
+	// ExecutePersisted. They are planned when the executor starts, which
+	// fails if any of them is invalid, and schema updates that break any of
+	// them are ignored.
+	PersistedOperations PersistedOperations
+	// RequirePersistedOperations rejects all queries of Execute and
+	// ExecuteWithDefer, so that only persisted operations run, through
+	// ExecutePersisted. Gateways that serve untrusted clients set it to
+	// reject all other queries. Queries of gateway resolvers, see
+	// QueryGateway, are still allowed.
+	RequirePersistedOperations bool
+	// ThrottlePolicy controls subqueries to services that asked the gateway
+	// to back off, see ThrottledError. It defaults to FailThrottled.
+	ThrottlePolicy ThrottlePolicy
+	// TypeNamespaces renames the types and fields of services, by service name,
+	// in the gateway schema. Custom SchemaSyncers must rename the schemas they
+	// fetch with TypeNamespace.RenameSchema.
+	TypeNamespaces map[string]*TypeNamespace
+	// GatewayIntrospection answers introspection queries (__schema and
+	// __type) from the merged gateway schema, instead of forwarding them to
+	// a service.
+	GatewayIntrospection bool
+	// ServicesField adds a _services field to the gateway, listing every
+	// service and the ServiceInfo it exposes with AddServiceInfo.
+	ServicesField bool
+	// ServicePlugins rewrite the subqueries sent to services and their
+	// responses, by service name, see ServicePlugin. Requests are rewritten
+	// by the plugins of a service in order, and responses in reverse order.
+	ServicePlugins map[string][]ServicePlugin
+	// SchemaSnapshotPath, if set, is a file the schemas of all services are
+	// written to after every successful schema fetch, see SchemaSnapshot.
+	// Failed writes are logged, and do not fail the fetch. If the schemas
+	// cannot be fetched when the executor starts, for example because a
+	// service is unreachable, the executor starts from the snapshot instead,
+	// and keeps fetching schemas in the background until the services come
+	// back. It requires the default SchemaSyncer.
+	SchemaSnapshotPath string
+	// PaginationContract verifies the pagination types shared by services
+	// whenever their schemas are fetched, see PaginationContract. It
+	// requires the default SchemaSyncer.
+	PaginationContract PaginationContract
+	// Maintenance, if set, starts the executor in read-only mode, see
+	// Executor.SetMaintenance.
+	Maintenance *Maintenance
+	// FieldMigrations are fields moving between services, which are resolved
+	// by both services to compare their results, see FieldMigration. They
+	// require OnShadowMismatch.
+	FieldMigrations []FieldMigration
+	// OnShadowMismatch is called with the results of migrating fields that
+	// differ between services. It is called from background goroutines.
+	OnShadowMismatch func(mismatch *ShadowMismatch)
+	// ShadowTimeout is the timeout of the shadow queries of FieldMigrations.
+	// It defaults to DefaultShadowTimeout.
+	ShadowTimeout time.Duration
+	// ServiceConcurrency bounds the subqueries in flight to services, by
+	// service name, see ConcurrencyLimit.
+	ServiceConcurrency map[string]ConcurrencyLimit
+	// MergePolicy controls how results of different services returning the
+	// same field of an object with different values are merged. It defaults
+	// to FailMergeConflicts.
+	MergePolicy MergePolicy
+	// OnMergeConflict, if set, is called with the conflicting results merged
+	// according to MergePolicy. It is called while merging results, and must
+	// not block.
+	OnMergeConflict func(conflict *MergeConflict)
 }
 
 func NewExecutor(ctx context.Context, executors map[string]ExecutorClient, c *CustomExecutorArgs) (*Executor, error) {
+	var gateway *gatewayExecutorClient
+	if c.GatewaySchema != nil {
+		if _, ok := executors[GatewayServiceName]; ok {
+			return nil, oops.Errorf("service name %s is reserved for the gateway", GatewayServiceName)
+		}
+		var err error
+		gateway, err = newGatewayExecutorClient(c.GatewaySchema)
+		if err != nil {
+			return nil, oops.Wrapf(err, "creating gateway service")
+		}
+
+		withGateway := make(map[string]ExecutorClient, len(executors)+1)
+		for name, client := range executors {
+			withGateway[name] = client
+		}
+		withGateway[GatewayServiceName] = gateway
+		executors = withGateway
+	}
+
+	for service := range c.TypeNamespaces {
+		if _, ok := executors[service]; !ok {
+			return nil, oops.Errorf("type namespace for unknown service %s", service)
+		}
+	}
+	for service := range c.ServicePlugins {
+		if _, ok := executors[service]; !ok {
+			return nil, oops.Errorf("plugins for unknown service %s", service)
+		}
+	}
+	for _, migration := range c.FieldMigrations {
+		for _, service := range []string{migration.From, migration.To} {
+			if _, ok := executors[service]; !ok {
+				return nil, oops.Errorf("migration of %s.%s to unknown service %s", migration.Type, migration.Field, service)
+			}
+		}
+		if migration.From == migration.To {
+			return nil, oops.Errorf("migration of %s.%s to the service owning it", migration.Type, migration.Field)
+		}
+	}
+	for service, limit := range c.ServiceConcurrency {
+		if _, ok := executors[service]; !ok {
+			return nil, oops.Errorf("concurrency limit for unknown service %s", service)
+		}
+		if limit.MaxInFlight <= 0 || limit.MaxQueued < 0 {
+			return nil, oops.Errorf("invalid concurrency limit for service %s", service)
+		}
+	}
+	if len(c.FieldMigrations) > 0 && c.OnShadowMismatch == nil {
+		return nil, oops.Errorf("field migrations require OnShadowMismatch")
+	}
+	shadowTimeout := c.ShadowTimeout
+	if shadowTimeout == 0 {
+		shadowTimeout = DefaultShadowTimeout
+	}
+
+	var introspectionSyncer *IntrospectionSchemaSyncer
 	if c.SchemaSyncer == nil {
-		c.SchemaSyncer = NewIntrospectionSchemaSyncer(ctx, executors, c.OptionalArgs)
+		introspectionSyncer = NewIntrospectionSchemaSyncer(ctx, executors, c.OptionalArgs)
+		introspectionSyncer.namespaces = c.TypeNamespaces
+		introspectionSyncer.snapshotPath = c.SchemaSnapshotPath
+		introspectionSyncer.pagination = c.PaginationContract
+		c.SchemaSyncer = introspectionSyncer
+	} else if c.SchemaSnapshotPath != "" {
+		return nil, oops.Errorf("schema snapshots require the default schema syncer")
+	} else if c.PaginationContract != PaginationUnchecked {
+		return nil, oops.Errorf("pagination contracts require the default schema syncer")
 	}
 	if c.SchemaSyncIntervalSeconds == nil {
 		c.SchemaSyncIntervalSeconds = func(ctx context.Context) int64 { return minSchemaSyncIntervalSeconds }
 	}
 
 	planner, err := c.SchemaSyncer.FetchPlanner(ctx, c.OptionalArgs)
+	if err != nil && c.SchemaSnapshotPath != "" {
+		var snapshotErr error
+		planner, snapshotErr = introspectionSyncer.plannerFromSnapshot()
+		if snapshotErr != nil {
+			return nil, oops.Wrapf(err, "failed to load schema, and failed to start from snapshot (%v)", snapshotErr)
+		}
+		err = nil
+	}
 	if err != nil {
 		return nil, oops.Wrapf(err, "failed to load schema")
 	}
@@ -122,8 +330,31 @@ func NewExecutor(ctx context.Context, executors map[string]ExecutorClient, c *Cu
 			ticker:       time.NewTicker(time.Duration(schemaSyncIntervalSeconds) * time.Second),
 			schemaSyncer: c.SchemaSyncer,
 			plannerMu:    &sync.RWMutex{},
-			planner:      planner,
+			persisted:    c.PersistedOperations,
 		},
+		usage:             c.UsageRecorder,
+		bestEffortTimeout: c.BestEffortTimeout,
+		throttler:         newThrottler(c.ThrottlePolicy),
+		namespaces:        c.TypeNamespaces,
+		plugins:           c.ServicePlugins,
+		maintenance:       c.Maintenance,
+		migrations:        indexMigrations(c.FieldMigrations),
+		onShadowMismatch:  c.OnShadowMismatch,
+		shadowTimeout:     shadowTimeout,
+		concurrency:       newConcurrencyLimiters(c.ServiceConcurrency),
+		mergePolicy:       c.MergePolicy,
+		onMergeConflict:   c.OnMergeConflict,
+		requirePersisted:  c.RequirePersistedOperations,
+
+		gatewayIntrospection: c.GatewayIntrospection,
+		servicesField:        c.ServicesField,
+	}
+	if err := executor.setPlanner(ctx, planner, c.OptionalArgs); err != nil {
+		executor.syncer.ticker.Stop()
+		return nil, err
+	}
+	if gateway != nil {
+		gateway.executor = executor
 	}
 	go executor.poll(ctx, c.OptionalArgs)
 	return executor, nil
@@ -135,7 +366,11 @@ func (e *Executor) poll(ctx context.Context, optionalArgs interface{}) error {
 		case <-e.syncer.ticker.C:
 			newPlanner, err := e.syncer.schemaSyncer.FetchPlanner(ctx, optionalArgs)
 			if err == nil && newPlanner != nil {
-				e.setPlanner(newPlanner)
+				// Keep the current planner if the new schema breaks persisted
+				// operations.
+				if err := e.setPlanner(ctx, newPlanner, optionalArgs); err != nil {
+					log.Printf("keeping the current schema: %v", err)
+				}
 			}
 		case <-ctx.Done():
 			e.syncer.ticker.Stop()
@@ -151,6 +386,11 @@ func (e *Executor) runOnService(ctx context.Context, service string, typName str
 	if !ok {
 		return nil, nil, oops.Errorf("service not recognized")
 	}
+	capabilities := planner.capabilities(service)
+	if len(keys) > 1 && !capabilities.Batching {
+		return e.runOnServiceUnbatched(ctx, service, typName, keys, kind, selectionSet, optionalArgs, planner)
+	}
+	namespace := e.namespaces[service]
 
 	// If it is not a root query, nest the subquery on the federation field
 	// and pass the keys in to find the object that the subquery is nested on
@@ -163,8 +403,14 @@ func (e *Executor) runOnService(ctx context.Context, service string, typName str
 	//   }
 	// }
 	isRoot := keys == nil
-	if !isRoot {
-		federatedName := fmt.Sprintf("%s-%s", typName, service)
+	if isRoot {
+		root := planner.schema.Schema.Query
+		if kind == mutationString {
+			root = planner.schema.Schema.Mutation
+		}
+		selectionSet = namespace.renameSelections(root, selectionSet)
+	} else {
+		federatedName := fmt.Sprintf("%s-%s", namespace.toService(typName), service)
 
 		var rootObject *graphql.Object
 		var ok bool
@@ -179,6 +425,7 @@ func (e *Executor) runOnService(ctx context.Context, service string, typName str
 		if rootObject == nil {
 			return nil, nil, oops.Errorf("root object not found for type %s", typName)
 		}
+		selectionSet = namespace.renameSelections(rootObject, selectionSet)
 
 		// If it is a federated key on that service, add it to the input args
 		// passed in to the federated field func as one of the federated keys
@@ -237,8 +484,22 @@ func (e *Executor) runOnService(ctx context.Context, service string, typName str
 		},
 		Metadata: optionalArgs,
 	}
-	response, err := executorClient.Execute(ctx, request)
+	if err := e.rewriteRequest(ctx, service, request); err != nil {
+		return nil, nil, oops.Wrapf(err, "rewriting request")
+	}
+	if err := e.throttler.wait(ctx, service); err != nil {
+		return nil, nil, err
+	}
+	ctx, release, err := e.limitConcurrency(ctx, service)
+	if err != nil {
+		return nil, nil, err
+	}
+	response, err := executorClient.Execute(injectTrace(ctx), request)
+	release()
 	if err != nil {
+		if d, ok := retryAfter(err); ok {
+			e.throttler.throttle(service, d)
+		}
 		return nil, nil, oops.Wrapf(err, "execute remotely")
 	}
 	// Unmarshal json from results
@@ -246,6 +507,10 @@ func (e *Executor) runOnService(ctx context.Context, service string, typName str
 	if err := json.Unmarshal(response.Result, &res); err != nil {
 		return nil, nil, oops.Wrapf(err, "unmarshal res")
 	}
+	if res, err = e.rewriteResponse(ctx, service, request, res); err != nil {
+		return nil, nil, oops.Wrapf(err, "rewriting response")
+	}
+	namespace.renameTypeNames(res)
 
 	if !isRoot {
 		result, ok := res.(map[string]interface{})
@@ -256,7 +521,7 @@ func (e *Executor) runOnService(ctx context.Context, service string, typName str
 		if !ok {
 			return nil, nil, oops.Errorf("executor res not a map[string]interface{}")
 		}
-		federatedName := fmt.Sprintf("%s-%s", typName, service)
+		federatedName := fmt.Sprintf("%s-%s", namespace.toService(typName), service)
 		r, ok := result[federatedName].([]interface{})
 		if !ok {
 			return nil, nil, fmt.Errorf("root did not have a federation map, got %v", res)
@@ -267,11 +532,53 @@ func (e *Executor) runOnService(ctx context.Context, service string, typName str
 	return []interface{}{res}, response.Metadata, nil
 }
 
-func (pathTargets *pathSubqueryMetadata) extractKeys(node interface{}, path []PathStep) error {
+// keyExtractor extracts the federation keys of the objects a subplan is
+// nested on from the results of its parent plan. It is built once per plan
+// and walks results with a single response path buffer, only copying the
+// paths of objects that have keys.
+type keyExtractor struct {
+	path []PathStep
+	// depth is the number of fields on path, which bounds how much the
+	// response path grows outside of lists.
+	depth int
+}
+
+// keyExtractor returns the cached key extractor of p.
+func (p *Plan) keyExtractor() *keyExtractor {
+	p.extractorOnce.Do(func() {
+		x := &keyExtractor{path: p.Path}
+		for _, step := range p.Path {
+			if step.Kind == KindField {
+				x.depth++
+			}
+		}
+		p.extractor = x
+	})
+	return p.extractor
+}
+
+// extract adds the keys of the objects nested in node, which is located at
+// responsePath, to pathTargets.
+func (x *keyExtractor) extract(pathTargets *pathSubqueryMetadata, node interface{}, responsePath []interface{}) error {
+	// Leave room for a list index per field.
+	buf := make([]interface{}, len(responsePath), len(responsePath)+2*x.depth+1)
+	copy(buf, responsePath)
+	return x.extractPath(pathTargets, node, x.path, buf)
+}
+
+// extractPath flattens nested lists, eg. of a [[User]] field or of lists inside
+// lists of objects, into a single batch of keys, and skips null lists and
+// objects. The subquery results are stitched into the extracted objects in
+// place, so they keep their position in the nested lists.
+func (x *keyExtractor) extractPath(pathTargets *pathSubqueryMetadata, node interface{}, path []PathStep, responsePath []interface{}) error {
+	if node == nil {
+		return nil
+	}
+
 	// Extract key for every element in the slice
 	if slice, ok := node.([]interface{}); ok {
 		for i, elem := range slice {
-			if err := pathTargets.extractKeys(elem, path); err != nil {
+			if err := x.extractPath(pathTargets, elem, path, append(responsePath, i)); err != nil {
 				return oops.Errorf("idx %d: %v", i, err)
 			}
 		}
@@ -281,7 +588,7 @@ func (pathTargets *pathSubqueryMetadata) extractKeys(node interface{}, path []Pa
 	if len(path) == 0 {
 		obj, ok := node.(map[string]interface{})
 		if !ok {
-			return fmt.Errorf("not an object: %v", obj)
+			return fmt.Errorf("not an object: %v", node)
 		}
 		key, ok := obj[federationField]
 		if !ok {
@@ -293,6 +600,17 @@ func (pathTargets *pathSubqueryMetadata) extractKeys(node interface{}, path []Pa
 		// Keys from the "__federation" field func are passed to
 		// the subquery
 		pathTargets.keys = append(pathTargets.keys, key)
+		// Remember where the object lives in the response in case the results
+		// of the subquery are delivered as a deferred patch. The buffer is
+		// reused for siblings, so copy it into the arena.
+		arena := pathTargets.pathArena
+		if cap(arena)-len(arena) < len(responsePath) {
+			arena = make([]interface{}, 0, 2*cap(arena)+len(responsePath))
+		}
+		start := len(arena)
+		arena = append(arena, responsePath...)
+		pathTargets.paths = append(pathTargets.paths, arena[start:len(arena):len(arena)])
+		pathTargets.pathArena = arena
 		return nil
 	}
 
@@ -309,7 +627,7 @@ func (pathTargets *pathSubqueryMetadata) extractKeys(node interface{}, path []Pa
 		if !ok {
 			return fmt.Errorf("does not have key %s", step.Name)
 		}
-		if err := pathTargets.extractKeys(next, path[1:]); err != nil {
+		if err := x.extractPath(pathTargets, next, path[1:], append(responsePath, step.Name)); err != nil {
 			return fmt.Errorf("elem %s: %v", next, err)
 		}
 	case KindType:
@@ -318,7 +636,7 @@ func (pathTargets *pathSubqueryMetadata) extractKeys(node interface{}, path []Pa
 			return fmt.Errorf("does not have string key __typename")
 		}
 		if typ == step.Name {
-			if err := pathTargets.extractKeys(obj, path[1:]); err != nil {
+			if err := x.extractPath(pathTargets, obj, path[1:], responsePath); err != nil {
 				return fmt.Errorf("typ %s: %v", typ, err)
 			}
 		}
@@ -329,7 +647,9 @@ func (pathTargets *pathSubqueryMetadata) extractKeys(node interface{}, path []Pa
 	return nil
 }
 
-func (e *Executor) execute(ctx context.Context, p *Plan, keys []interface{}, optionalArgs interface{}, planner *Planner) ([]interface{}, []interface{}, error) {
+// execute executes the plan p for the objects identified by keys, which are
+// located at paths in the response.
+func (e *Executor) execute(ctx context.Context, p *Plan, keys []interface{}, paths [][]interface{}, optionalArgs interface{}, planner *Planner, deferred *deferredExecution) ([]interface{}, []interface{}, error) {
 	var res []interface{}
 	optionalRespMetadata := make([]interface{}, 0)
 	// var optionalResponseArg interface{}
@@ -338,6 +658,21 @@ func (e *Executor) execute(ctx context.Context, p *Plan, keys []interface{}, opt
 		var err error
 		var optionalRespQueryMetaData interface{}
 		res, optionalRespQueryMetaData, err = e.runOnService(ctx, p.Service, p.Type, keys, p.Kind, p.SelectionSet, optionalArgs, planner)
+		if err == nil {
+			e.shadow(p, p.Service, keys, res, optionalArgs, planner)
+		}
+		// Retry subplans that other services can resolve as well, unless the
+		// query was canceled.
+		for _, alternative := range p.Alternatives {
+			if err == nil || ctx.Err() != nil {
+				break
+			}
+			var retryErr error
+			res, optionalRespQueryMetaData, retryErr = e.runOnService(ctx, alternative, p.Type, keys, p.Kind, p.SelectionSet, optionalArgs, planner)
+			if retryErr == nil {
+				err = nil
+			}
+		}
 		if err != nil {
 			return nil, nil, oops.Wrapf(err, "run on service")
 		}
@@ -349,14 +684,16 @@ func (e *Executor) execute(ctx context.Context, p *Plan, keys []interface{}, opt
 	}
 
 	g, ctx := errgroup.WithContext(ctx)
-	// resMu protects the results (res) as we stitch the results together from seperate goroutines
-	// executing in different parts of the plan on different services
-	var resMu sync.Mutex
+	// merges stitch the results of the nested queries into the response once
+	// they have all completed. They run in the order of p.After, so that
+	// conflicting results are merged the same way whichever service responds
+	// first, see MergePolicy.
+	merges := make([]func() error, len(p.After))
 
 	// For every nested query in the plan, execute it on the specified service and stitch
 	// the results into a response
-	for _, currentSubPlan := range p.After {
-		subPlan := currentSubPlan
+	for index, currentSubPlan := range p.After {
+		index, subPlan := index, currentSubPlan
 		var subPlanMetaData pathSubqueryMetadata
 		if p.Service == gatewayCoordinatorServiceName {
 			subPlanMetaData.keys = nil // On the root query there are no specified keys
@@ -365,43 +702,73 @@ func (e *Executor) execute(ctx context.Context, p *Plan, keys []interface{}, opt
 			subPlanMetaData.results = []map[string]interface{}{
 				res[0].(map[string]interface{}),
 			}
+			subPlanMetaData.paths = [][]interface{}{nil}
 			subPlanMetaData.optionalResponseMetatda = nil
-		} else {
-			if err := subPlanMetaData.extractKeys(res, subPlan.Path); err != nil {
+		} else if deferred != nil && planner.capabilities(subPlan.Service).Defer {
+			// The subquery depends on the results of this service, so deliver its
+			// results as a deferred patch instead of waiting for it. On the root
+			// service there is only one result, located at the root of the response.
+			if err := subPlan.keyExtractor().extract(&subPlanMetaData, res[0], nil); err != nil {
 				return nil, nil, fmt.Errorf("failed to extract keys %v: %v", subPlan.Path, err)
 			}
+			if len(subPlanMetaData.keys) > 0 {
+				e.executeDeferred(deferred, subPlan, subPlanMetaData, optionalArgs, planner)
+			}
+			continue
+		} else {
+			extractor := subPlan.keyExtractor()
+			for i, result := range res {
+				var path []interface{}
+				if i < len(paths) {
+					path = paths[i]
+				}
+				if err := extractor.extract(&subPlanMetaData, result, path); err != nil {
+					return nil, nil, fmt.Errorf("failed to extract keys %v: idx %d: %v", subPlan.Path, i, err)
+				}
+			}
+		}
+
+		// Only the subqueries of the root services are deferred; everything
+		// further down is resolved as part of the deferred patch.
+		var subPlanDeferred *deferredExecution
+		if p.Service == gatewayCoordinatorServiceName {
+			subPlanDeferred = deferred
 		}
 
 		g.Go(func() error {
 			// Execute the subquery on the specified service
-			executionResults, subQueryRespMetadata, err := e.execute(ctx, subPlan, subPlanMetaData.keys, optionalArgs, planner)
+			var executionResults, subQueryRespMetadata []interface{}
+			var err error
+			if subPlan.BestEffort {
+				executionResults, subQueryRespMetadata, err = e.executeBestEffort(ctx, subPlan, subPlanMetaData, optionalArgs, planner)
+			} else {
+				executionResults, subQueryRespMetadata, err = e.execute(ctx, subPlan, subPlanMetaData.keys, subPlanMetaData.paths, optionalArgs, planner, subPlanDeferred)
+			}
 			if err != nil {
 				return oops.Wrapf(err, "executing sub plan: %v", err)
 			}
-			optionalRespMetadata = append(optionalRespMetadata, subQueryRespMetadata...)
 
 			if len(executionResults) != len(subPlanMetaData.results) {
 				return fmt.Errorf("got %d results for %d targets", len(executionResults), len(subPlanMetaData.results))
 			}
 
-			// Acquire mutex lock before modifying results
-			resMu.Lock()
-			defer resMu.Unlock()
-			for i, result := range subPlanMetaData.results {
-				executionResult, ok := executionResults[i].(map[string]interface{})
-				if !ok {
-					return fmt.Errorf("result is not an object: %v", executionResult)
-				}
+			merges[index] = func() error {
+				optionalRespMetadata = append(optionalRespMetadata, subQueryRespMetadata...)
+				for i, result := range subPlanMetaData.results {
+					executionResult, ok := executionResults[i].(map[string]interface{})
+					if !ok {
+						return fmt.Errorf("result is not an object: %v", executionResult)
+					}
 
-				for k, v := range executionResult {
-					if _, ok := result[k]; !ok {
-						result[k] = v
-					} else {
-						if k != keyField || v != result[k] {
-							return oops.Errorf("key already exists in results: %v", k)
-						}
+					var path []interface{}
+					if i < len(subPlanMetaData.paths) {
+						path = subPlanMetaData.paths[i]
+					}
+					if err := e.mergeResult(p, subPlan, path, result, executionResult); err != nil {
+						return err
 					}
 				}
+				return nil
 			}
 			return nil
 		})
@@ -410,6 +777,14 @@ func (e *Executor) execute(ctx context.Context, p *Plan, keys []interface{}, opt
 	if err := g.Wait(); err != nil {
 		return nil, nil, err
 	}
+	for _, merge := range merges {
+		if merge == nil {
+			continue
+		}
+		if err := merge(); err != nil {
+			return nil, nil, err
+		}
+	}
 
 	return res, optionalRespMetadata, nil
 }
@@ -432,17 +807,95 @@ func deleteKey(v interface{}, k string) {
 type pathSubqueryMetadata struct {
 	keys                    []interface{}            // Federated Keys passed into subquery
 	results                 []map[string]interface{} // Results from subquery
+	paths                   [][]interface{}          // Response paths of the results
+	pathArena               []interface{}            // Backs paths, see keyExtractor
 	optionalResponseMetatda []interface{}
 }
 
+// Execute plans query and executes it on the services. It rejects all
+// queries if CustomExecutorArgs.RequirePersistedOperations is set.
 func (e *Executor) Execute(ctx context.Context, query *graphql.Query, optionalArgs interface{}) (interface{}, []interface{}, error) {
+	if e.requirePersisted {
+		return nil, nil, errNotPersisted
+	}
+	return e.executeQuery(ctx, query, optionalArgs)
+}
+
+// executeQuery executes query like Execute, including queries that are not
+// persisted, for the queries of gateway resolvers.
+func (e *Executor) executeQuery(ctx context.Context, query *graphql.Query, optionalArgs interface{}) (interface{}, []interface{}, error) {
+	if err := e.checkMaintenance(query.Kind); err != nil {
+		return nil, nil, err
+	}
+
 	planner := e.getPlanner()
-	plan, err := planner.planRoot(query)
+	plan, err := planner.planQuery(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	return e.executeQueryPlan(ctx, plan, planner, optionalArgs)
+}
+
+// executeQueryPlan executes a plan made by planner.planQuery, merging the
+// results of the gateway selections into the results of the services.
+func (e *Executor) executeQueryPlan(ctx context.Context, plan *queryPlan, planner *Planner, optionalArgs interface{}) (interface{}, []interface{}, error) {
+	if plan.local == nil {
+		return e.executeRemote(ctx, plan, planner, optionalArgs)
+	}
+
+	localRes, err := e.executeGatewaySelections(ctx, planner, plan.local, optionalArgs)
+	if err != nil {
+		return nil, nil, err
+	}
+	if plan.remote == nil {
+		return localRes, nil, nil
+	}
+
+	res, metadata, err := e.executeRemote(ctx, plan, planner, optionalArgs)
+	if err != nil {
+		return nil, nil, err
+	}
+	mergeGatewayResults(res, localRes)
+	return res, metadata, nil
+}
+
+// executeRemote executes the selections of plan resolved by the services,
+// and propagates the nulls of dropped non-null best effort selections.
+func (e *Executor) executeRemote(ctx context.Context, plan *queryPlan, planner *Planner, optionalArgs interface{}) (interface{}, []interface{}, error) {
+	var nulls *nullTargets
+	if plan.bestEffort {
+		ctx, nulls = withNullTargets(ctx)
+	}
+	res, metadata, err := e.executePlan(ctx, plan.remote, planner, optionalArgs)
 	if err != nil {
 		return nil, nil, err
 	}
+	if nulls != nil {
+		if err := nulls.propagate(res, plan, planner); err != nil {
+			return nil, nil, err
+		}
+	}
+	return res, metadata, nil
+}
+
+// mergeGatewayResults merges the results of the gateway selections into the
+// results of the services.
+func mergeGatewayResults(res interface{}, localRes map[string]interface{}) {
+	if obj, ok := res.(map[string]interface{}); ok {
+		for k, v := range localRes {
+			obj[k] = v
+		}
+	}
+}
+
+// executePlan executes the plan of a query made by planner.
+func (e *Executor) executePlan(ctx context.Context, plan *Plan, planner *Planner, optionalArgs interface{}) (interface{}, []interface{}, error) {
+	if e.usage != nil {
+		e.usage.record(plan, planner)
+	}
 
-	r, responseMetadata, err := e.execute(ctx, plan, nil, optionalArgs, planner)
+	ctx = startTrace(ctx)
+	r, responseMetadata, err := e.execute(ctx, plan, nil, nil, optionalArgs, planner, nil)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -6,9 +6,9 @@ import (
 	"strings"
 	"testing"
 
-	"github.com/samsarahq/thunder/batch"
-	"github.com/samsarahq/thunder/graphql"
-	"github.com/samsarahq/thunder/graphql/schemabuilder"
+	"github.com/denkhaus/thunder/batch"
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -0,0 +1,119 @@
+package federation
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+)
+
+type failoverUser struct {
+	Id int64
+}
+
+type failoverUserKeys struct {
+	Id int64
+}
+
+// makeFailoverExecutor builds an executor where s2 and s3 both resolve the
+// rating of users owned by s1, as well as the motd query and bump mutation.
+func makeFailoverExecutor(t *testing.T) (*Executor, *throttlingClient, *throttlingClient) {
+	s1 := schemabuilder.NewSchemaWithName("s1")
+	s1.Query().FieldFunc("users", func() []*failoverUser {
+		return []*failoverUser{{Id: 1}, {Id: 2}}
+	})
+	s1.Object("User", failoverUser{}, schemabuilder.RootObject).Key("id")
+
+	owners := map[string]*schemabuilder.Schema{"s1": s1}
+	for _, name := range []string{"s2", "s3"} {
+		name := name
+		schema := schemabuilder.NewSchemaWithName(name)
+		schema.FederatedFieldFunc("User", func(args struct{ Keys []failoverUserKeys }) []*failoverUser {
+			users := make([]*failoverUser, 0, len(args.Keys))
+			for _, key := range args.Keys {
+				users = append(users, &failoverUser{Id: key.Id})
+			}
+			return users
+		})
+		user := schema.Object("User", failoverUser{})
+		user.Key("id")
+		user.FieldFunc("rating", func(u *failoverUser) string {
+			return name
+		})
+		schema.Query().FieldFunc("motd", func() string {
+			return "hello from " + name
+		})
+		schema.Mutation().FieldFunc("bump", func() string {
+			return name
+		})
+		owners[name] = schema
+	}
+
+	execs, err := makeExecutors(owners)
+	require.NoError(t, err)
+	s2 := &throttlingClient{client: execs["s2"]}
+	s3 := &throttlingClient{client: execs["s3"]}
+	execs["s2"], execs["s3"] = s2, s3
+
+	e, err := NewExecutor(context.Background(), execs, &CustomExecutorArgs{})
+	require.NoError(t, err)
+	return e, s2, s3
+}
+
+func TestPlanAlternatives(t *testing.T) {
+	e, _, _ := makeFailoverExecutor(t)
+	planner, _ := e.getPlans()
+
+	plan, err := planner.planRoot(graphql.MustParse(`{ motd users { id rating } }`, nil))
+	require.NoError(t, err)
+	require.Len(t, plan.After, 2)
+
+	motd, users := plan.After[0], plan.After[1]
+	if motd.Service != "s2" {
+		motd, users = users, motd
+	}
+	assert.Equal(t, "s2", motd.Service)
+	assert.Equal(t, []string{"s3"}, motd.Alternatives)
+	assert.Equal(t, "s1", users.Service)
+	assert.Empty(t, users.Alternatives)
+	require.Len(t, users.After, 1)
+	assert.Equal(t, "s2", users.After[0].Service)
+	assert.Equal(t, []string{"s3"}, users.After[0].Alternatives)
+
+	plan, err = planner.planRoot(graphql.MustParse(`mutation { bump }`, nil))
+	require.NoError(t, err)
+	require.Len(t, plan.After, 1)
+	assert.Empty(t, plan.After[0].Alternatives)
+}
+
+func TestExecutorFailover(t *testing.T) {
+	e, s2, s3 := makeFailoverExecutor(t)
+	ctx := context.Background()
+
+	s2.err = errors.New("s2 is down")
+	res, _, err := e.Execute(ctx, graphql.MustParse(`{ motd users { id rating } }`, nil), nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"motd": "hello from s3",
+		"users": []interface{}{
+			map[string]interface{}{"__key": float64(1), "id": float64(1), "rating": "s3"},
+			map[string]interface{}{"__key": float64(2), "id": float64(2), "rating": "s3"},
+		},
+	}, res)
+
+	// Mutations are not retried.
+	_, _, err = e.Execute(ctx, graphql.MustParse(`mutation { bump }`, nil), nil)
+	assert.Error(t, err)
+
+	// The error of the chosen service is returned if all services fail.
+	s3.err = errors.New("s3 is down")
+	_, _, err = e.Execute(ctx, graphql.MustParse(`{ motd }`, nil), nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "s2 is down")
+	}
+}
@@ -0,0 +1,251 @@
+// Package federationtest runs a federation gateway over in-process fake
+// services for integration tests. Services are built from schemabuilder
+// schemas, and every request the gateway sends them is recorded, so tests can
+// assert on how queries are planned and executed:
+//
+//	gateway := federationtest.NewGateway(t, map[string]*schemabuilder.Schema{
+//		"users":    users,
+//		"profiles": profiles,
+//	})
+//	defer gateway.Close()
+//	gateway.AssertQuery(`{ users { id score } }`, `{"users": [...]}`)
+//	gateway.AssertRequestCount("profiles", 1)
+package federationtest
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/federation"
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+)
+
+// federationField is the root field of requests fetching federated objects.
+const federationField = "__federation"
+
+// Request is a request the gateway sent to a service.
+type Request struct {
+	// Service is the name of the service.
+	Service string
+	// Fields are the names of the root fields selected by the request,
+	// excluding federated object fetches.
+	Fields []string
+	// Keys holds the number of keys of the federated objects fetched by the
+	// request, by object type as named by the service.
+	Keys map[string]int
+	// Query is the query sent to the service.
+	Query *graphql.Query
+	// Metadata is the metadata sent with the query.
+	Metadata interface{}
+}
+
+// Option configures a Gateway.
+type Option func(*config)
+
+type config struct {
+	args *federation.CustomExecutorArgs
+}
+
+// WithExecutorArgs creates the gateway's executor with args.
+func WithExecutorArgs(args *federation.CustomExecutorArgs) Option {
+	return func(c *config) {
+		c.args = args
+	}
+}
+
+// Gateway is a federation gateway over in-process fake services, recording
+// the requests sent to them.
+type Gateway struct {
+	// Executor is the gateway's executor.
+	Executor *federation.Executor
+
+	t      testing.TB
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	requests []*Request
+	failures map[string]error
+}
+
+// service is an ExecutorClient for a fake service, recording its requests.
+type service struct {
+	name    string
+	gateway *Gateway
+	client  federation.ExecutorClient
+}
+
+func (s *service) Execute(ctx context.Context, request *federation.QueryRequest) (*federation.QueryResponse, error) {
+	recorded := &Request{
+		Service:  s.name,
+		Keys:     make(map[string]int),
+		Query:    request.Query,
+		Metadata: request.Metadata,
+	}
+	for _, selection := range request.Query.SelectionSet.Selections {
+		if selection.Name != federationField {
+			recorded.Fields = append(recorded.Fields, selection.Name)
+			continue
+		}
+		// Federated objects are fetched with fields named like
+		// "User-service".
+		for _, object := range selection.SelectionSet.Selections {
+			keys, _ := object.UnparsedArgs["keys"].([]interface{})
+			recorded.Keys[strings.TrimSuffix(object.Name, "-"+s.name)] += len(keys)
+		}
+	}
+
+	s.gateway.mu.Lock()
+	s.gateway.requests = append(s.gateway.requests, recorded)
+	err := s.gateway.failures[s.name]
+	s.gateway.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+	return s.client.Execute(ctx, request)
+}
+
+// NewGateway builds the schemas into fake services, by service name, and
+// creates a gateway over them. It fails t if the services or gateway cannot be
+// created. The gateway polls the services' schemas until it is closed, see
+// Close.
+func NewGateway(t testing.TB, schemas map[string]*schemabuilder.Schema, opts ...Option) *Gateway {
+	c := &config{args: &federation.CustomExecutorArgs{}}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	g := &Gateway{
+		t:        t,
+		failures: make(map[string]error),
+	}
+	clients := make(map[string]federation.ExecutorClient, len(schemas))
+	for name, schema := range schemas {
+		built, err := schema.Build()
+		require.NoError(t, err, "building schema of service %s", name)
+		server, err := federation.NewServer(built)
+		require.NoError(t, err, "creating service %s", name)
+		clients[name] = &service{
+			name:    name,
+			gateway: g,
+			client:  &federation.DirectExecutorClient{Client: server},
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	executor, err := federation.NewExecutor(ctx, clients, c.args)
+	if err != nil {
+		cancel()
+	}
+	require.NoError(t, err, "creating gateway")
+	g.Executor = executor
+	g.cancel = cancel
+
+	// Creating the executor fetches the services' schemas, which tests
+	// should not have to account for.
+	g.ResetRequests()
+	return g
+}
+
+// Close stops the gateway from polling the services' schemas.
+func (g *Gateway) Close() {
+	g.cancel()
+}
+
+// Execute runs query with variables on the gateway.
+func (g *Gateway) Execute(ctx context.Context, query string, variables map[string]interface{}) (interface{}, error) {
+	q, err := graphql.Parse(query, variables)
+	if err != nil {
+		return nil, err
+	}
+	result, _, err := g.Executor.Execute(ctx, q, nil)
+	return result, err
+}
+
+// Query runs query on the gateway and returns its result, failing the test if
+// it fails.
+func (g *Gateway) Query(query string) interface{} {
+	result, err := g.Execute(context.Background(), query, nil)
+	require.NoError(g.t, err, "executing %s", query)
+	return result
+}
+
+// AssertQuery asserts that query returns the JSON expected.
+func (g *Gateway) AssertQuery(query string, expected string) bool {
+	bytes, err := json.Marshal(g.Query(query))
+	require.NoError(g.t, err)
+	return assert.JSONEq(g.t, expected, string(bytes), "result of %s", query)
+}
+
+// FailService makes requests to service fail with err, in addition to being
+// recorded. A nil err restores the service.
+func (g *Gateway) FailService(service string, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err == nil {
+		delete(g.failures, service)
+		return
+	}
+	g.failures[service] = err
+}
+
+// Requests returns the requests sent to services, in the order they were
+// sent.
+func (g *Gateway) Requests() []*Request {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]*Request(nil), g.requests...)
+}
+
+// RequestsTo returns the requests sent to service, in the order they were
+// sent.
+func (g *Gateway) RequestsTo(service string) []*Request {
+	var requests []*Request
+	for _, request := range g.Requests() {
+		if request.Service == service {
+			requests = append(requests, request)
+		}
+	}
+	return requests
+}
+
+// RequestCounts returns the number of requests sent to each service.
+func (g *Gateway) RequestCounts() map[string]int {
+	counts := make(map[string]int)
+	for _, request := range g.Requests() {
+		counts[request.Service]++
+	}
+	return counts
+}
+
+// ResetRequests forgets the requests recorded so far.
+func (g *Gateway) ResetRequests() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.requests = nil
+}
+
+// AssertRequestCount asserts that n requests were sent to service.
+func (g *Gateway) AssertRequestCount(service string, n int) bool {
+	return assert.Len(g.t, g.RequestsTo(service), n, "requests to %s", service)
+}
+
+// AssertServices asserts that requests were sent to exactly services.
+func (g *Gateway) AssertServices(services ...string) bool {
+	got := []string{}
+	for service := range g.RequestCounts() {
+		got = append(got, service)
+	}
+	sort.Strings(got)
+	want := append([]string{}, services...)
+	sort.Strings(want)
+	return assert.Equal(g.t, want, got, "services requested")
+}
@@ -0,0 +1,89 @@
+package federationtest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/federation"
+	"github.com/denkhaus/thunder/federation/federationtest"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+)
+
+type User struct {
+	Id int64
+}
+
+type userKeys struct {
+	Id int64
+}
+
+func newGateway(t *testing.T) *federationtest.Gateway {
+	users := schemabuilder.NewSchemaWithName("users")
+	users.Object("User", User{}, schemabuilder.RootObject).Key("id")
+	users.Query().FieldFunc("users", func() []*User {
+		return []*User{{Id: 1}, {Id: 2}}
+	})
+	federation.AddCapabilities(users, federation.DefaultCapabilities)
+
+	profiles := schemabuilder.NewSchemaWithName("profiles")
+	profiles.FederatedFieldFunc("User", func(args struct{ Keys []userKeys }) []*User {
+		out := make([]*User, 0, len(args.Keys))
+		for _, key := range args.Keys {
+			out = append(out, &User{Id: key.Id})
+		}
+		return out
+	})
+	user := profiles.Object("User", User{})
+	user.Key("id")
+	user.FieldFunc("score", func(u *User) int64 { return u.Id * 10 })
+	federation.AddCapabilities(profiles, federation.DefaultCapabilities)
+
+	return federationtest.NewGateway(t, map[string]*schemabuilder.Schema{
+		"users":    users,
+		"profiles": profiles,
+	})
+}
+
+func TestGateway(t *testing.T) {
+	gateway := newGateway(t)
+	defer gateway.Close()
+	assert.Empty(t, gateway.Requests())
+
+	gateway.AssertQuery(`{ users { id score } }`, `{
+		"users": [
+			{"__key": 1, "id": 1, "score": 10},
+			{"__key": 2, "id": 2, "score": 20}
+		]
+	}`)
+	gateway.AssertServices("users", "profiles")
+	gateway.AssertRequestCount("users", 1)
+	gateway.AssertRequestCount("profiles", 1)
+
+	requests := gateway.Requests()
+	require.Len(t, requests, 2)
+	assert.Equal(t, "users", requests[0].Service)
+	assert.Equal(t, []string{"users"}, requests[0].Fields)
+	assert.Empty(t, requests[0].Keys)
+	assert.Equal(t, "profiles", requests[1].Service)
+	assert.Empty(t, requests[1].Fields)
+	assert.Equal(t, map[string]int{"User": 2}, requests[1].Keys)
+
+	// Fields of a single service are planned into a single request.
+	gateway.ResetRequests()
+	gateway.Query(`{ users { id } }`)
+	assert.Equal(t, map[string]int{"users": 1}, gateway.RequestCounts())
+
+	// Failed services fail the query.
+	gateway.ResetRequests()
+	gateway.FailService("profiles", errors.New("unavailable"))
+	_, err := gateway.Execute(context.Background(), `{ users { score } }`, nil)
+	assert.Error(t, err)
+	gateway.AssertRequestCount("profiles", 1)
+
+	gateway.FailService("profiles", nil)
+	gateway.Query(`{ users { score } }`)
+}
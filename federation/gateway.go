@@ -0,0 +1,57 @@
+package federation
+
+import (
+	"context"
+
+	"github.com/samsarahq/go/oops"
+
+	"github.com/denkhaus/thunder/graphql"
+)
+
+// GatewayServiceName is the service name of the gateway resolvers set in
+// CustomExecutorArgs.GatewaySchema.
+const GatewayServiceName = "gateway"
+
+// gatewayContext is passed to gateway resolvers so they can query the gateway.
+type gatewayContext struct {
+	executor *Executor
+	metadata interface{}
+}
+
+type gatewayContextKey struct{}
+
+// gatewayExecutorClient executes queries on the gateway resolvers in the
+// gateway process.
+type gatewayExecutorClient struct {
+	client   *DirectExecutorClient
+	executor *Executor
+}
+
+func newGatewayExecutorClient(schema *graphql.Schema) (*gatewayExecutorClient, error) {
+	srv, err := NewServer(schema)
+	if err != nil {
+		return nil, err
+	}
+	return &gatewayExecutorClient{client: &DirectExecutorClient{Client: srv}}, nil
+}
+
+func (c *gatewayExecutorClient) Execute(ctx context.Context, request *QueryRequest) (*QueryResponse, error) {
+	ctx = context.WithValue(ctx, gatewayContextKey{}, &gatewayContext{
+		executor: c.executor,
+		metadata: request.Metadata,
+	})
+	return c.client.Execute(ctx, request)
+}
+
+// QueryGateway executes query on the gateway that is resolving a gateway
+// field, with the metadata of the current query. Gateway resolvers use it to
+// combine data from multiple services. Queries must not recurse into the
+// calling gateway field.
+func QueryGateway(ctx context.Context, query *graphql.Query) (interface{}, error) {
+	gateway, ok := ctx.Value(gatewayContextKey{}).(*gatewayContext)
+	if !ok {
+		return nil, oops.Errorf("QueryGateway called outside of a gateway resolver")
+	}
+	res, _, err := gateway.executor.executeQuery(ctx, query, gateway.metadata)
+	return res, err
+}
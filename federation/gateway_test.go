@@ -0,0 +1,83 @@
+package federation
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+)
+
+func TestExecutorGatewayResolvers(t *testing.T) {
+	_, s1, s2, s3, err := createExecutorWithFederatedUser()
+	require.NoError(t, err)
+	execs, err := makeExecutors(map[string]*schemabuilder.Schema{
+		"s1": s1,
+		"s2": s2,
+		"s3": s3,
+	})
+	require.NoError(t, err)
+
+	type User struct {
+		Id int64
+	}
+	type UserKeys struct {
+		Id int64
+	}
+	gateway := schemabuilder.NewSchemaWithName(GatewayServiceName)
+	gateway.FederatedFieldFunc("User", func(args struct{ Keys []UserKeys }) []*User {
+		users := make([]*User, 0, len(args.Keys))
+		for _, key := range args.Keys {
+			users = append(users, &User{Id: key.Id})
+		}
+		return users
+	})
+	user := gateway.Object("User", User{})
+	user.Key("id")
+	user.FieldFunc("displayName", func(user *User) string {
+		return fmt.Sprintf("User #%d", user.Id)
+	})
+	gateway.Query().FieldFunc("userCount", func(ctx context.Context) (int64, error) {
+		// Aggregate data resolved by other services.
+		res, err := QueryGateway(ctx, graphql.MustParse(`{ users { id } }`, nil))
+		if err != nil {
+			return 0, err
+		}
+		return int64(len(res.(map[string]interface{})["users"].([]interface{}))), nil
+	})
+
+	ctx := context.Background()
+	e, err := NewExecutor(ctx, execs, &CustomExecutorArgs{
+		GatewaySchema: gateway.MustBuild(),
+	})
+	require.NoError(t, err)
+
+	runAndValidateQueryResults(t, ctx, e, `
+		{
+			userCount
+			users {
+				id
+				name
+				displayName
+			}
+		}`, `
+		{
+			"userCount": 2,
+			"users": [
+				{"__key": 1, "id": 1, "name": "testUser", "displayName": "User #1"},
+				{"__key": 2, "id": 2, "name": "testUser2", "displayName": "User #2"}
+			]
+		}`)
+
+	_, err = NewExecutor(ctx, map[string]ExecutorClient{GatewayServiceName: execs["s1"]}, &CustomExecutorArgs{
+		GatewaySchema: gateway.MustBuild(),
+	})
+	assert.Error(t, err)
+
+	_, err = QueryGateway(ctx, graphql.MustParse(`{ userCount }`, nil))
+	assert.Error(t, err)
+}
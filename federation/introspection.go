@@ -0,0 +1,300 @@
+package federation
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/samsarahq/go/oops"
+
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/introspection"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+)
+
+// serviceInfoField is the root field services expose their ServiceInfo on.
+const serviceInfoField = "__serviceInfo"
+
+// servicesField is the gateway root field listing the ServiceInfo of all
+// services, see CustomExecutorArgs.ServicesField.
+const servicesField = "_services"
+
+// ServiceInfo describes the deployed version of a service, for operators.
+type ServiceInfo struct {
+	Version string
+	Commit  string
+}
+
+// AddServiceInfo exposes info on a service's schema, so gateways list it in
+// their _services field.
+func AddServiceInfo(schema *schemabuilder.Schema, info ServiceInfo) {
+	schema.Object("__ServiceInfo", ServiceInfo{})
+	schema.Query().FieldFunc(serviceInfoField, func() *ServiceInfo {
+		return &info
+	})
+}
+
+// serviceStatus is an entry of the gateway _services field.
+type serviceStatus struct {
+	Name    string
+	Version string
+	Commit  string
+	// Error is set if the service info could not be fetched.
+	Error *string
+}
+
+// servicesContext is passed to the _services resolver to fetch service
+// infos.
+type servicesContext struct {
+	executor     *Executor
+	planner      *Planner
+	optionalArgs interface{}
+}
+
+type servicesContextKey struct{}
+
+// gatewayIntrospectionFields are the root fields of introspection queries.
+var gatewayIntrospectionFields = []string{"__schema", "__type"}
+
+// makeGatewaySchema returns the schema of the root fields resolved by the
+// gateway for the merged schema, or nil if there are none. With
+// introspection, it removes the services' introspection fields from the
+// merged schema so they are never forwarded.
+func (e *Executor) makeGatewaySchema(schema *graphql.Schema) (*graphql.Schema, error) {
+	if !e.gatewayIntrospection && !e.servicesField {
+		return nil, nil
+	}
+
+	query, ok := schema.Query.(*graphql.Object)
+	if !ok {
+		return nil, oops.Errorf("query is not an object")
+	}
+	if e.gatewayIntrospection {
+		for _, name := range gatewayIntrospectionFields {
+			delete(query.Fields, name)
+		}
+	}
+
+	fields := make(map[string]*graphql.Field)
+	if e.servicesField {
+		builder := schemabuilder.NewSchema()
+		builder.Object("Service", serviceStatus{})
+		builder.Query().FieldFunc(servicesField, func(ctx context.Context) ([]*serviceStatus, error) {
+			services, ok := ctx.Value(servicesContextKey{}).(*servicesContext)
+			if !ok {
+				return nil, oops.Errorf("%s resolved outside of the gateway", servicesField)
+			}
+			return services.executor.fetchServiceInfos(ctx, services.planner, services.optionalArgs), nil
+		})
+		servicesSchema, err := builder.Build()
+		if err != nil {
+			return nil, err
+		}
+		fields[servicesField] = servicesSchema.Query.(*graphql.Object).Fields[servicesField]
+	}
+
+	if e.gatewayIntrospection {
+		client := clientSchema(schema, fields)
+		introspectionQuery := introspection.BareIntrospectionSchema(client).Query.(*graphql.Object)
+		for _, name := range gatewayIntrospectionFields {
+			fields[name] = introspectionQuery.Fields[name]
+		}
+	}
+
+	return &graphql.Schema{
+		Query: &graphql.Object{
+			Name:   "Query",
+			Fields: fields,
+		},
+		Mutation: &graphql.Object{
+			Name:   "Mutation",
+			Fields: map[string]*graphql.Field{},
+		},
+	}, nil
+}
+
+// clientSchema returns a copy of the merged schema as seen by clients,
+// without federation fields and with the gateway root fields.
+func clientSchema(schema *graphql.Schema, gatewayFields map[string]*graphql.Field) *graphql.Schema {
+	copies := make(map[*graphql.Object]*graphql.Object)
+	var copyType func(typ graphql.Type) graphql.Type
+	copyObject := func(obj *graphql.Object) *graphql.Object {
+		if c, ok := copies[obj]; ok {
+			return c
+		}
+		c := &graphql.Object{
+			Name:        obj.Name,
+			Description: obj.Description,
+			KeyField:    obj.KeyField,
+			KeyFields:   obj.KeyFields,
+			Fields:      make(map[string]*graphql.Field, len(obj.Fields)),
+		}
+		copies[obj] = c
+		for name, field := range obj.Fields {
+			if name == federationField || name == serviceInfoField || name == capabilitiesField {
+				continue
+			}
+			fieldCopy := *field
+			fieldCopy.Type = copyType(field.Type)
+			c.Fields[name] = &fieldCopy
+		}
+		return c
+	}
+	copyType = func(typ graphql.Type) graphql.Type {
+		switch typ := typ.(type) {
+		case *graphql.Object:
+			return copyObject(typ)
+		case *graphql.List:
+			return &graphql.List{Type: copyType(typ.Type)}
+		case *graphql.NonNull:
+			return &graphql.NonNull{Type: copyType(typ.Type)}
+		case *graphql.Union:
+			types := make(map[string]*graphql.Object, len(typ.Types))
+			for name, obj := range typ.Types {
+				types[name] = copyObject(obj)
+			}
+			return &graphql.Union{Name: typ.Name, Description: typ.Description, Types: types}
+		default:
+			return typ
+		}
+	}
+
+	client := &graphql.Schema{Query: copyType(schema.Query)}
+	if schema.Mutation != nil {
+		client.Mutation = copyType(schema.Mutation)
+	}
+	query := client.Query.(*graphql.Object)
+	for name, field := range gatewayFields {
+		query.Fields[name] = field
+	}
+	return client
+}
+
+// fetchServiceInfos fetches the ServiceInfo of all services concurrently.
+// Services without a ServiceInfo are listed by name only.
+func (e *Executor) fetchServiceInfos(ctx context.Context, planner *Planner, optionalArgs interface{}) []*serviceStatus {
+	var hasInfo map[string]bool
+	if field, ok := planner.schema.Schema.Query.(*graphql.Object).Fields[serviceInfoField]; ok {
+		hasInfo = planner.schema.Fields[field].Services
+	}
+
+	statuses := make([]*serviceStatus, 0, len(e.Executors))
+	for service := range e.Executors {
+		statuses = append(statuses, &serviceStatus{Name: service})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+
+	selectionSet := &graphql.SelectionSet{
+		Selections: []*graphql.Selection{{
+			Name:  serviceInfoField,
+			Alias: serviceInfoField,
+			Args:  map[string]interface{}{},
+			SelectionSet: &graphql.SelectionSet{
+				Selections: []*graphql.Selection{
+					{Name: "version", Alias: "version", Args: map[string]interface{}{}},
+					{Name: "commit", Alias: "commit", Args: map[string]interface{}{}},
+				},
+			},
+		}},
+	}
+
+	var wg sync.WaitGroup
+	for _, status := range statuses {
+		if !hasInfo[status.Name] {
+			continue
+		}
+		status := status
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, _, err := e.runOnService(ctx, status.Name, "", nil, queryString, selectionSet, optionalArgs, planner)
+			if err == nil {
+				err = status.parse(res)
+			}
+			if err != nil {
+				message := err.Error()
+				status.Error = &message
+			}
+		}()
+	}
+	wg.Wait()
+	return statuses
+}
+
+// parse sets the version and commit of s from the result of a service info
+// query.
+func (s *serviceStatus) parse(res []interface{}) error {
+	if len(res) != 1 {
+		return oops.Errorf("expected one result, got %d", len(res))
+	}
+	result, ok := res[0].(map[string]interface{})
+	if !ok {
+		return oops.Errorf("result is not an object")
+	}
+	info, ok := result[serviceInfoField].(map[string]interface{})
+	if !ok {
+		return oops.Errorf("%s is not an object", serviceInfoField)
+	}
+	s.Version, _ = info["version"].(string)
+	s.Commit, _ = info["commit"].(string)
+	return nil
+}
+
+// splitGatewaySelections splits the root selections of query into the
+// selections resolved by the gateway and a query with the other selections,
+// which is nil if there are none.
+func splitGatewaySelections(gateway *graphql.Schema, query *graphql.Query) ([]*graphql.Selection, *graphql.Query) {
+	if gateway == nil || query.Kind != queryString {
+		return nil, query
+	}
+	fields := gateway.Query.(*graphql.Object).Fields
+
+	var local, remote []*graphql.Selection
+	for _, selection := range query.SelectionSet.Selections {
+		if _, ok := fields[selection.Name]; ok {
+			local = append(local, selection)
+		} else {
+			remote = append(remote, selection)
+		}
+	}
+	if len(local) == 0 {
+		return nil, query
+	}
+	if len(remote) == 0 && len(query.SelectionSet.Fragments) == 0 {
+		return local, nil
+	}
+	return local, &graphql.Query{
+		Name: query.Name,
+		Kind: query.Kind,
+		SelectionSet: &graphql.SelectionSet{
+			Selections: remote,
+			Fragments:  query.SelectionSet.Fragments,
+		},
+	}
+}
+
+// executeGatewaySelections resolves selections on the gateway schema.
+func (e *Executor) executeGatewaySelections(ctx context.Context, planner *Planner, selections []*graphql.Selection, optionalArgs interface{}) (map[string]interface{}, error) {
+	ctx = context.WithValue(ctx, servicesContextKey{}, &servicesContext{
+		executor:     e,
+		planner:      planner,
+		optionalArgs: optionalArgs,
+	})
+	selectionSet := &graphql.SelectionSet{Selections: selections}
+	if err := graphql.PrepareQuery(ctx, planner.gateway.Query, selectionSet); err != nil {
+		return nil, err
+	}
+	executor := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	res, err := executor.Execute(ctx, planner.gateway.Query, nil, &graphql.Query{
+		Kind:         queryString,
+		SelectionSet: selectionSet,
+	})
+	if err != nil {
+		return nil, err
+	}
+	result, ok := res.(map[string]interface{})
+	if !ok {
+		return nil, oops.Errorf("gateway result is not an object")
+	}
+	return result, nil
+}
@@ -0,0 +1,161 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+)
+
+// countingExecutorClient counts the queries forwarded to a service.
+type countingExecutorClient struct {
+	ExecutorClient
+	queries []string
+}
+
+func (c *countingExecutorClient) Execute(ctx context.Context, request *QueryRequest) (*QueryResponse, error) {
+	for _, selection := range request.Query.SelectionSet.Selections {
+		c.queries = append(c.queries, selection.Name)
+	}
+	return c.ExecutorClient.Execute(ctx, request)
+}
+
+func TestGatewayIntrospection(t *testing.T) {
+	ctx := context.Background()
+
+	users := schemabuilder.NewSchemaWithName("users")
+	users.Object("User", namespaceUser{}, schemabuilder.RootObject).Key("id")
+	users.Query().FieldFunc("users", func() []*namespaceUser {
+		return []*namespaceUser{{Id: 1}}
+	})
+	AddServiceInfo(users, ServiceInfo{Version: "1.2.0", Commit: "abc123"})
+
+	orders := schemabuilder.NewSchemaWithName("orders")
+	orders.Query().FieldFunc("orderCount", func() int64 {
+		return 3
+	})
+
+	execs, err := makeExecutors(map[string]*schemabuilder.Schema{
+		"users":  users,
+		"orders": orders,
+	})
+	require.NoError(t, err)
+	counting := make(map[string]ExecutorClient, len(execs))
+	for name, client := range execs {
+		counting[name] = &countingExecutorClient{ExecutorClient: client}
+	}
+
+	e, err := NewExecutor(ctx, counting, &CustomExecutorArgs{
+		GatewayIntrospection: true,
+		ServicesField:        true,
+	})
+	require.NoError(t, err)
+	for _, client := range counting {
+		client.(*countingExecutorClient).queries = nil
+	}
+
+	res, _, err := e.Execute(ctx, graphql.MustParse(`{
+		__schema { queryType { fields { name } } }
+		user: __type(name: "User") { fields { name } }
+		federation: __type(name: "Federation") { name }
+		_services { name version commit error }
+		orderCount
+	}`, nil), nil)
+	require.NoError(t, err)
+
+	var expected interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"__schema": {"queryType": {"fields": [
+			{"name": "_services"},
+			{"name": "orderCount"},
+			{"name": "users"}
+		]}},
+		"user": {"fields": [{"name": "id"}]},
+		"federation": null,
+		"_services": [
+			{"name": "orders", "version": "", "commit": "", "error": null},
+			{"name": "users", "version": "1.2.0", "commit": "abc123", "error": null}
+		],
+		"orderCount": 3
+	}`), &expected))
+	actual, err := json.Marshal(res)
+	require.NoError(t, err)
+	var actualJSON interface{}
+	require.NoError(t, json.Unmarshal(actual, &actualJSON))
+	assert.Equal(t, expected, actualJSON)
+
+	// Introspection was never forwarded to the services.
+	assert.Equal(t, []string{"orderCount"}, counting["orders"].(*countingExecutorClient).queries)
+	assert.Equal(t, []string{serviceInfoField}, counting["users"].(*countingExecutorClient).queries)
+
+	// Introspection inside fragments is not forwarded either.
+	_, _, err = e.Execute(ctx, graphql.MustParse(`{ ... on Query { __schema { queryType { name } } } }`, nil), nil)
+	assert.Error(t, err)
+}
+
+func TestGatewayIntrospectionDeferredAndPersisted(t *testing.T) {
+	ctx := context.Background()
+
+	users := schemabuilder.NewSchemaWithName("users")
+	users.Query().FieldFunc("userCount", func() int64 {
+		return 2
+	})
+	AddServiceInfo(users, ServiceInfo{Version: "1.2.0"})
+
+	execs, err := makeExecutors(map[string]*schemabuilder.Schema{"users": users})
+	require.NoError(t, err)
+	counting := &countingExecutorClient{ExecutorClient: execs["users"]}
+
+	e, err := NewExecutor(ctx, map[string]ExecutorClient{"users": counting}, &CustomExecutorArgs{
+		GatewayIntrospection: true,
+		ServicesField:        true,
+		PersistedOperations: PersistedOperations{
+			"introspection": `{ __type(name: "Query") { name } }`,
+			"mixed":         `{ _services { name version } userCount }`,
+		},
+	})
+	require.NoError(t, err)
+	counting.queries = nil
+
+	res, _, patches, err := e.ExecuteWithDefer(ctx, graphql.MustParse(`{
+		__type(name: "Query") { name }
+		_services { name version }
+		userCount
+	}`, nil), nil)
+	require.NoError(t, err)
+	for patch := range patches {
+		require.NoError(t, patch.Err)
+	}
+	assert.Equal(t, map[string]interface{}{
+		"__type":    map[string]interface{}{"name": "Query"},
+		"_services": []interface{}{map[string]interface{}{"name": "users", "version": "1.2.0"}},
+		"userCount": float64(2),
+	}, res)
+
+	res, _, patches, err = e.ExecuteWithDefer(ctx, graphql.MustParse(`{ __type(name: "Query") { name } }`, nil), nil)
+	require.NoError(t, err)
+	_, open := <-patches
+	assert.False(t, open)
+	assert.Equal(t, map[string]interface{}{"__type": map[string]interface{}{"name": "Query"}}, res)
+
+	res, _, err = e.ExecutePersisted(ctx, "introspection", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"__type": map[string]interface{}{"name": "Query"}}, res)
+
+	res, _, err = e.ExecutePersisted(ctx, "mixed", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"_services": []interface{}{map[string]interface{}{"name": "users", "version": "1.2.0"}},
+		"userCount": float64(2),
+	}, res)
+
+	// Only userCount, and the service info for _services, reached the service.
+	for _, query := range counting.queries {
+		assert.Contains(t, []string{"userCount", serviceInfoField}, query)
+	}
+}
@@ -0,0 +1,109 @@
+package federation
+
+import (
+	"github.com/denkhaus/thunder/graphql"
+)
+
+// DefaultMaintenanceMessage is the error returned for operations rejected
+// during maintenance if Maintenance.Message is not set.
+const DefaultMaintenanceMessage = "the service is in read-only mode for maintenance"
+
+// MaintenanceExtension is the response extension holding the maintenance
+// banner, see Executor.MaintenanceMiddleware.
+const MaintenanceExtension = "maintenance"
+
+// Maintenance puts a gateway in read-only mode, for example during database
+// maintenance windows. Mutations are rejected, while queries are still
+// served.
+type Maintenance struct {
+	// Message is the error returned for rejected operations. It defaults to
+	// DefaultMaintenanceMessage.
+	Message string
+	// RejectSubscriptions also rejects new subscriptions, and reruns of
+	// existing ones.
+	RejectSubscriptions bool
+	// Banner, if set, is added to the MaintenanceExtension of all responses,
+	// so clients can tell their users about the maintenance.
+	Banner string
+}
+
+func (m *Maintenance) err() error {
+	if m.Message == "" {
+		return graphql.NewClientError(DefaultMaintenanceMessage)
+	}
+	return graphql.NewClientError("%s", m.Message)
+}
+
+// SetMaintenance puts the executor in read-only mode as described by m, or
+// leaves it if m is nil. It takes effect for operations started afterwards.
+func (e *Executor) SetMaintenance(m *Maintenance) {
+	e.maintenanceMu.Lock()
+	defer e.maintenanceMu.Unlock()
+	e.maintenance = m
+}
+
+// Maintenance returns the maintenance the executor is in, or nil.
+func (e *Executor) Maintenance() *Maintenance {
+	e.maintenanceMu.Lock()
+	defer e.maintenanceMu.Unlock()
+	return e.maintenance
+}
+
+// checkMaintenance returns an error if operations of kind are rejected by
+// the current maintenance.
+func (e *Executor) checkMaintenance(kind string) error {
+	if m := e.Maintenance(); m != nil && kind == mutationString {
+		return m.err()
+	}
+	return nil
+}
+
+// subscriptionString is the kind of subscriptions reported by operationKind.
+// Subscriptions are parsed as queries, see graphql.Parse.
+const subscriptionString = "subscription"
+
+// operationKind returns the kind of the operation of input: mutationString or
+// queryString as parsed, or subscriptionString for the queries of websocket
+// connections, which the server runs as subscriptions and identifies by an
+// Id.
+func operationKind(input *graphql.ComputationInput) string {
+	if input.ParsedQuery == nil {
+		return ""
+	}
+	if input.ParsedQuery.Kind == queryString && input.Id != "" {
+		return subscriptionString
+	}
+	return input.ParsedQuery.Kind
+}
+
+// MaintenanceMiddleware returns a middleware for servers running the
+// executor. During maintenance, it rejects mutations, and subscriptions if
+// Maintenance.RejectSubscriptions is set, before they reach the executor, and
+// adds the Maintenance.Banner to the response extensions.
+func (e *Executor) MaintenanceMiddleware() graphql.MiddlewareFunc {
+	return func(input *graphql.ComputationInput, next graphql.MiddlewareNextFunc) *graphql.ComputationOutput {
+		m := e.Maintenance()
+		if m == nil {
+			return next(input)
+		}
+
+		var output *graphql.ComputationOutput
+		if kind := operationKind(input); kind == mutationString || (kind == subscriptionString && m.RejectSubscriptions) {
+			output = &graphql.ComputationOutput{
+				Metadata:   make(map[string]interface{}),
+				Extensions: make(map[string]interface{}),
+				Error:      m.err(),
+			}
+		} else {
+			output = next(input)
+		}
+
+		if m.Banner != "" {
+			if output.Extensions == nil {
+				output.Extensions = make(map[string]interface{})
+			}
+			output.Extensions[MaintenanceExtension] = m.Banner
+		}
+		return output
+	}
+}
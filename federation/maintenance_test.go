@@ -0,0 +1,79 @@
+package federation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+)
+
+func TestMaintenance(t *testing.T) {
+	ctx := context.Background()
+
+	var counter int64
+	s := schemabuilder.NewSchemaWithName("counter")
+	s.Query().FieldFunc("counter", func() int64 { return counter })
+	s.Mutation().FieldFunc("increment", func() int64 {
+		counter++
+		return counter
+	})
+
+	execs, err := makeExecutors(map[string]*schemabuilder.Schema{"counter": s})
+	require.NoError(t, err)
+	e, err := NewExecutor(ctx, execs, &CustomExecutorArgs{})
+	require.NoError(t, err)
+
+	query := graphql.MustParse(`{ counter }`, map[string]interface{}{})
+	mutation := graphql.MustParse(`mutation { increment }`, map[string]interface{}{})
+
+	_, _, err = e.Execute(ctx, mutation, nil)
+	require.NoError(t, err)
+
+	e.SetMaintenance(&Maintenance{Banner: "back soon"})
+
+	// Mutations are rejected, while queries are still served.
+	_, _, err = e.Execute(ctx, mutation, nil)
+	assert.EqualError(t, err, DefaultMaintenanceMessage)
+	res, _, err := e.Execute(ctx, query, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"counter": float64(1)}, res)
+	_, _, _, err = e.ExecuteWithDefer(ctx, mutation, nil)
+	assert.EqualError(t, err, DefaultMaintenanceMessage)
+
+	// The middleware adds the banner to all responses, and rejects
+	// subscriptions if asked to.
+	middleware := e.MaintenanceMiddleware()
+	run := func(query *graphql.Query, id string) *graphql.ComputationOutput {
+		return middleware(&graphql.ComputationInput{Ctx: ctx, Id: id, ParsedQuery: query}, func(input *graphql.ComputationInput) *graphql.ComputationOutput {
+			current, _, err := e.Execute(input.Ctx, input.ParsedQuery, nil)
+			return &graphql.ComputationOutput{Current: current, Error: err}
+		})
+	}
+
+	output := run(query, "1")
+	assert.NoError(t, output.Error)
+	assert.Equal(t, map[string]interface{}{MaintenanceExtension: "back soon"}, output.Extensions)
+	output = run(mutation, "")
+	assert.EqualError(t, output.Error, DefaultMaintenanceMessage)
+	output = run(mutation, "2")
+	assert.EqualError(t, output.Error, DefaultMaintenanceMessage)
+	assert.Equal(t, map[string]interface{}{MaintenanceExtension: "back soon"}, output.Extensions)
+
+	e.SetMaintenance(&Maintenance{Message: "down for maintenance", RejectSubscriptions: true})
+	output = run(query, "1")
+	assert.EqualError(t, output.Error, "down for maintenance")
+	assert.Empty(t, output.Extensions)
+	output = run(query, "")
+	assert.NoError(t, output.Error)
+
+	// Leaving maintenance accepts mutations again.
+	e.SetMaintenance(nil)
+	assert.Nil(t, e.Maintenance())
+	_, _, err = e.Execute(ctx, mutation, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), counter)
+}
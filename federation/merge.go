@@ -0,0 +1,127 @@
+package federation
+
+import (
+	"reflect"
+
+	"github.com/samsarahq/go/oops"
+
+	"github.com/denkhaus/thunder/graphql"
+)
+
+// MergePolicy controls how the gateway merges subquery results that return
+// the same field of an object with different values, which happens when
+// services disagree about data they both resolve.
+type MergePolicy int
+
+const (
+	// FailMergeConflicts fails queries with conflicting results.
+	FailMergeConflicts MergePolicy = iota
+	// KeepFirstResult keeps the value that was merged first. The results of
+	// the service returning an object are merged first, followed by the
+	// results of the other services in the order of the query plan.
+	KeepFirstResult
+	// PreferOwnerResult keeps the value of the service owning the field, the
+	// service the gateway planned to resolve it with, over values returned
+	// by other services. Conflicts between other services keep the value
+	// that was merged first.
+	PreferOwnerResult
+)
+
+// MergeConflict describes a field returned with different values by two
+// services, see MergePolicy.
+type MergeConflict struct {
+	// Path is the response path of the field.
+	Path []interface{}
+	// Type is the object type of the field.
+	Type string
+	// Services and Values are the services that returned the field and their
+	// values: the value already in the response, then the conflicting value.
+	Services [2]string
+	Values   [2]interface{}
+	// Kept is the index of the value kept in the response, or -1 if the query
+	// failed.
+	Kept int
+}
+
+// mergeResult merges the results of subPlan for an object, located at path,
+// into the result of the plan p, which returned the object.
+func (e *Executor) mergeResult(p *Plan, subPlan *Plan, path []interface{}, result, subResult map[string]interface{}) error {
+	for k, v := range subResult {
+		existing, ok := result[k]
+		if !ok {
+			result[k] = v
+			continue
+		}
+		if reflect.DeepEqual(existing, v) {
+			continue
+		}
+		if err := e.resolveMergeConflict(p, subPlan, path, k, result, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveMergeConflict reports a conflicting value v of the field k of
+// result, and keeps the value chosen by the MergePolicy.
+func (e *Executor) resolveMergeConflict(p *Plan, subPlan *Plan, path []interface{}, k string, result map[string]interface{}, v interface{}) error {
+	conflict := &MergeConflict{
+		Path:     append(append([]interface{}{}, path...), k),
+		Type:     subPlan.Type,
+		Services: [2]string{existingService(p, subPlan, k), subPlan.Service},
+		Values:   [2]interface{}{result[k], v},
+	}
+
+	// The federation fields are internal to the gateway, and cannot be
+	// merged.
+	internal := k == keyField || k == federationField
+	switch {
+	case internal || e.mergePolicy == FailMergeConflicts:
+		conflict.Kept = -1
+	case e.mergePolicy == PreferOwnerResult && selectsAlias(subPlan.SelectionSet, k) && conflict.Services[0] != subPlan.Service:
+		conflict.Kept = 1
+		result[k] = v
+	}
+
+	if e.onMergeConflict != nil && !internal {
+		e.onMergeConflict(conflict)
+	}
+	if conflict.Kept == -1 {
+		return oops.Errorf("services %s and %s returned conflicting results for %v", conflict.Services[0], conflict.Services[1], conflict.Path)
+	}
+	return nil
+}
+
+// existingService returns the service that returned the field alias of an
+// object merged with the results of subPlan: a sibling of subPlan merged
+// before it selecting the field on the same objects, or otherwise the plan p
+// returning the objects.
+func existingService(p *Plan, subPlan *Plan, alias string) string {
+	for _, sibling := range p.After {
+		if sibling == subPlan {
+			break
+		}
+		if reflect.DeepEqual(sibling.Path, subPlan.Path) && selectsAlias(sibling.SelectionSet, alias) {
+			return sibling.Service
+		}
+	}
+	return p.Service
+}
+
+// selectsAlias returns whether selectionSet selects alias.
+func selectsAlias(selectionSet *graphql.SelectionSet, alias string) bool {
+	if selectionSet == nil {
+		return false
+	}
+	for _, selection := range selectionSet.Selections {
+		if selection.Alias == alias {
+			return true
+		}
+	}
+	for _, fragment := range selectionSet.Fragments {
+		if selectsAlias(fragment.SelectionSet, alias) {
+			return true
+		}
+	}
+	return false
+}
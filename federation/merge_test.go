@@ -0,0 +1,148 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/graphql"
+)
+
+// conflictingClient sets field in all objects with the field on, so that it
+// conflicts with the value returned by another service.
+type conflictingClient struct {
+	client ExecutorClient
+	on     string
+	field  string
+	value  func(object map[string]interface{}) interface{}
+}
+
+func (c *conflictingClient) Execute(ctx context.Context, request *QueryRequest) (*QueryResponse, error) {
+	resp, err := c.client.Execute(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	var result interface{}
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return nil, err
+	}
+	var visit func(v interface{})
+	visit = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if _, ok := v[c.on]; ok {
+				v[c.field] = c.value(v)
+			}
+			for _, e := range v {
+				visit(e)
+			}
+		case []interface{}:
+			for _, e := range v {
+				visit(e)
+			}
+		}
+	}
+	visit(result)
+	resp.Result, err = json.Marshal(result)
+	return resp, err
+}
+
+func TestMergeConflicts(t *testing.T) {
+	failover, _, _ := makeFailoverExecutor(t)
+	execs := failover.Executors
+	s2 := execs["s2"]
+	execs["s2"] = &conflictingClient{client: s2, on: "rating", field: "id", value: func(map[string]interface{}) interface{} {
+		return 42
+	}}
+
+	var mu sync.Mutex
+	var conflicts []*MergeConflict
+	newExecutor := func(policy MergePolicy) *Executor {
+		e, err := NewExecutor(context.Background(), execs, &CustomExecutorArgs{
+			MergePolicy: policy,
+			OnMergeConflict: func(conflict *MergeConflict) {
+				mu.Lock()
+				defer mu.Unlock()
+				conflicts = append(conflicts, conflict)
+			},
+		})
+		require.NoError(t, err)
+		conflicts = nil
+		return e
+	}
+	query := graphql.MustParse(`{ users { id rating } }`, nil)
+	ctx := context.Background()
+
+	// Conflicts fail queries by default.
+	e := newExecutor(FailMergeConflicts)
+	_, _, err := e.Execute(ctx, query, nil)
+	assert.Error(t, err)
+	require.NotEmpty(t, conflicts)
+	assert.Equal(t, -1, conflicts[0].Kept)
+
+	// Otherwise, they are reported and the first results, which are the
+	// owner's, are kept.
+	for _, policy := range []MergePolicy{KeepFirstResult, PreferOwnerResult} {
+		e = newExecutor(policy)
+		res, _, err := e.Execute(ctx, query, nil)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{
+			"users": []interface{}{
+				map[string]interface{}{"__key": float64(1), "id": float64(1), "rating": "s2"},
+				map[string]interface{}{"__key": float64(2), "id": float64(2), "rating": "s2"},
+			},
+		}, res)
+		assert.Equal(t, []*MergeConflict{
+			{
+				Path:     []interface{}{"users", 0, "id"},
+				Type:     "User",
+				Services: [2]string{"s1", "s2"},
+				Values:   [2]interface{}{float64(1), float64(42)},
+			},
+			{
+				Path:     []interface{}{"users", 1, "id"},
+				Type:     "User",
+				Services: [2]string{"s1", "s2"},
+				Values:   [2]interface{}{float64(2), float64(42)},
+			},
+		}, conflicts)
+	}
+
+	// When the service returning the objects also returns a field owned by
+	// another service, KeepFirstResult keeps its value, and
+	// PreferOwnerResult the owner's.
+	s1 := execs["s1"]
+	execs["s1"] = &conflictingClient{client: s1, on: keyField, field: "rating", value: func(map[string]interface{}) interface{} {
+		return "s1"
+	}}
+	execs["s2"] = s2
+	for policy, kept := range map[MergePolicy]int{KeepFirstResult: 0, PreferOwnerResult: 1} {
+		e = newExecutor(policy)
+		res, _, err := e.Execute(ctx, graphql.MustParse(`{ users { rating } }`, nil), nil)
+		require.NoError(t, err)
+		rating := [2]string{"s1", "s2"}[kept]
+		assert.Equal(t, map[string]interface{}{
+			"users": []interface{}{
+				map[string]interface{}{"__key": float64(1), "rating": rating},
+				map[string]interface{}{"__key": float64(2), "rating": rating},
+			},
+		}, res)
+		require.Len(t, conflicts, 2)
+		assert.Equal(t, [2]string{"s1", "s2"}, conflicts[0].Services)
+		assert.Equal(t, kept, conflicts[0].Kept)
+	}
+	execs["s1"] = s1
+
+	// Results agreeing with the owner's are not conflicts.
+	execs["s2"] = &conflictingClient{client: s2, on: "rating", field: "id", value: func(object map[string]interface{}) interface{} {
+		return object["__key"]
+	}}
+	e = newExecutor(FailMergeConflicts)
+	_, _, err = e.Execute(ctx, query, nil)
+	require.NoError(t, err)
+	assert.Empty(t, conflicts)
+}
@@ -5,9 +5,9 @@ import (
 	"testing"
 
 	"github.com/samsarahq/go/oops"
-	"github.com/samsarahq/thunder/graphql"
-	"github.com/samsarahq/thunder/graphql/introspection"
-	"github.com/samsarahq/thunder/thunderpb"
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/introspection"
+	"github.com/denkhaus/thunder/thunderpb"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc/metadata"
 )
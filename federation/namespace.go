@@ -0,0 +1,353 @@
+package federation
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/samsarahq/go/oops"
+
+	"github.com/denkhaus/thunder/graphql"
+)
+
+// TypeNamespace renames the types of a service in the gateway schema, so
+// unrelated types that happen to share a name in different services are not
+// merged into a single type. Queries sent to the service use its own type
+// names, and __typename values in its results are renamed for the gateway.
+//
+// Objects, unions, input objects, and enums are renamed. Scalars, the Query,
+// Mutation, and Federation types, and introspection types keep their names.
+//
+// A TypeNamespace can also rename the fields of the service's objects, see
+// Fields.
+type TypeNamespace struct {
+	// Prefix is prepended to the names of the service's types, eg. "Billing_"
+	// turns Invoice into Billing_Invoice.
+	Prefix string
+	// Renames maps type names of the service to gateway type names, and
+	// takes precedence over Prefix. Types shared with other services, such as
+	// federated objects, should map to themselves to keep their name.
+	Renames map[string]string
+	// Fields renames fields of the service's objects in the gateway schema,
+	// so a public field can be renamed without changing the service in
+	// lockstep. It maps type names of the service to maps from gateway field
+	// names to the service's field names, eg. {"User": {"displayName":
+	// "name"}} exposes the name field of User as displayName.
+	//
+	// Queries sent to the service select its own field under the gateway
+	// name as alias, so its results have the gateway's shape. Fields used as
+	// federation keys cannot be renamed.
+	Fields map[string]map[string]string
+}
+
+// toGateway returns the gateway name of the service type name with kind.
+func (n *TypeNamespace) toGateway(name string, kind string) string {
+	if n == nil {
+		return name
+	}
+	if renamed, ok := n.Renames[name]; ok {
+		return renamed
+	}
+	switch name {
+	case "Query", "Mutation", "Federation":
+		return name
+	}
+	if strings.HasPrefix(name, "__") {
+		return name
+	}
+	switch kind {
+	case "OBJECT", "UNION", "INPUT_OBJECT", "ENUM":
+		return n.Prefix + name
+	}
+	return name
+}
+
+// toService returns the service name of the gateway type name.
+func (n *TypeNamespace) toService(name string) string {
+	if n == nil {
+		return name
+	}
+	for original, renamed := range n.Renames {
+		if renamed == name {
+			return original
+		}
+	}
+	if _, ok := n.Renames[name]; ok {
+		// name is a service type renamed to something else, so it can't be
+		// the result of adding the prefix.
+		return name
+	}
+	if n.Prefix != "" && strings.HasPrefix(name, n.Prefix) {
+		return strings.TrimPrefix(name, n.Prefix)
+	}
+	return name
+}
+
+// toGatewayField returns the gateway name of field on the service type typ.
+func (n *TypeNamespace) toGatewayField(typ string, field string) string {
+	if n == nil {
+		return field
+	}
+	for renamed, original := range n.Fields[typ] {
+		if original == field {
+			return renamed
+		}
+	}
+	return field
+}
+
+// toServiceField returns the service name of the gateway field on the service
+// type typ.
+func (n *TypeNamespace) toServiceField(typ string, field string) string {
+	if n == nil {
+		return field
+	}
+	if original, ok := n.Fields[typ][field]; ok {
+		return original
+	}
+	return field
+}
+
+// RenameSchema renames the types in schema, the result of an introspection
+// query against the service, to their gateway names. Custom SchemaSyncers
+// must rename the schemas of services with a namespace.
+func (n *TypeNamespace) RenameSchema(schema []byte) ([]byte, error) {
+	var iq introspectionQueryResult
+	if err := json.Unmarshal(schema, &iq); err != nil {
+		return nil, oops.Wrapf(err, "unmarshaling schema")
+	}
+	if err := n.renameSchema(&iq, n.federationKeys(&iq)); err != nil {
+		return nil, err
+	}
+	return json.Marshal(&iq)
+}
+
+// federationKeys returns the key fields of the objects that schema, as
+// fetched from the service, resolves in its Federation type, by gateway type
+// name.
+func (n *TypeNamespace) federationKeys(schema *introspectionQueryResult) map[string]map[string]bool {
+	inputTypes := make(map[string]*introspectionType, len(schema.Schema.Types))
+	for i := range schema.Schema.Types {
+		if typ := &schema.Schema.Types[i]; typ.Kind == "INPUT_OBJECT" {
+			inputTypes[typ.Name] = typ
+		}
+	}
+
+	keys := make(map[string]map[string]bool)
+	for _, typ := range schema.Schema.Types {
+		if typ.Name != "Federation" {
+			continue
+		}
+		// Federation fields are named <object>-<service>, and take the keys
+		// of the objects as their keys argument.
+		for _, field := range typ.Fields {
+			name := n.toGateway(strings.SplitN(field.Name, "-", 2)[0], "OBJECT")
+			for _, arg := range field.Args {
+				if arg.Name != "keys" || arg.Type == nil {
+					continue
+				}
+				ref := arg.Type
+				for ref.OfType != nil {
+					ref = ref.OfType
+				}
+				input, ok := inputTypes[ref.Name]
+				if !ok {
+					continue
+				}
+				if keys[name] == nil {
+					keys[name] = make(map[string]bool, len(input.InputFields))
+				}
+				for _, inputField := range input.InputFields {
+					keys[name][inputField.Name] = true
+				}
+			}
+		}
+	}
+	return keys
+}
+
+// renameSchema renames the types in schema, as fetched from service, to
+// their gateway names. keys holds the key fields of federated objects across
+// all services, by gateway type name, which cannot be renamed.
+func (n *TypeNamespace) renameSchema(schema *introspectionQueryResult, keys map[string]map[string]bool) error {
+	if n == nil {
+		return nil
+	}
+
+	names := make(map[string]string, len(schema.Schema.Types))
+	originals := make(map[string]string, len(schema.Schema.Types))
+	for _, typ := range schema.Schema.Types {
+		renamed := n.toGateway(typ.Name, typ.Kind)
+		if original, ok := originals[renamed]; ok {
+			return oops.Errorf("types %s and %s are both renamed to %s", original, typ.Name, renamed)
+		}
+		names[typ.Name] = renamed
+		originals[renamed] = typ.Name
+	}
+	for name := range n.Renames {
+		if _, ok := names[name]; !ok {
+			return oops.Errorf("renamed type %s does not exist", name)
+		}
+	}
+	if err := n.checkFields(schema, keys); err != nil {
+		return err
+	}
+
+	var renameRef func(ref *introspectionTypeRef)
+	renameRef = func(ref *introspectionTypeRef) {
+		for ; ref != nil; ref = ref.OfType {
+			if renamed, ok := names[ref.Name]; ok {
+				ref.Name = renamed
+			}
+		}
+	}
+
+	for i := range schema.Schema.Types {
+		typ := &schema.Schema.Types[i]
+		for j := range typ.Fields {
+			field := &typ.Fields[j]
+			field.Name = n.toGatewayField(typ.Name, field.Name)
+			renameRef(field.Type)
+			for k := range field.Args {
+				renameRef(field.Args[k].Type)
+			}
+			// Federation fields are named <object>-<service>.
+			if typ.Name == "Federation" {
+				parts := strings.SplitN(field.Name, "-", 2)
+				if renamed, ok := names[parts[0]]; ok && len(parts) == 2 {
+					field.Name = fmt.Sprintf("%s-%s", renamed, parts[1])
+				}
+			}
+		}
+		for j := range typ.InputFields {
+			renameRef(typ.InputFields[j].Type)
+		}
+		for _, possibleType := range typ.PossibleTypes {
+			renameRef(possibleType)
+		}
+		typ.Name = names[typ.Name]
+	}
+	return nil
+}
+
+// checkFields checks that the renamed fields exist in schema, as fetched from
+// the service, that their gateway names are unique, and that they are not
+// federation keys. Keys are passed between services by their gateway names,
+// so a renamed key would only fail at query time.
+func (n *TypeNamespace) checkFields(schema *introspectionQueryResult, keys map[string]map[string]bool) error {
+	types := make(map[string]*introspectionType, len(schema.Schema.Types))
+	for i := range schema.Schema.Types {
+		types[schema.Schema.Types[i].Name] = &schema.Schema.Types[i]
+	}
+	for typName, fields := range n.Fields {
+		typ, ok := types[typName]
+		if !ok || typ.Kind != "OBJECT" {
+			return oops.Errorf("type %s with renamed fields is not an object", typName)
+		}
+
+		typKeys := keys[n.toGateway(typName, typ.Kind)]
+		renamedTo := make(map[string]string, len(fields))
+		for renamed, original := range fields {
+			if other, ok := renamedTo[original]; ok {
+				return oops.Errorf("field %s.%s is renamed to both %s and %s", typName, original, other, renamed)
+			}
+			if typKeys[original] || typKeys[renamed] {
+				return oops.Errorf("field %s.%s is a federation key and cannot be renamed to %s", typName, original, renamed)
+			}
+			renamedTo[original] = renamed
+		}
+		originals := make(map[string]string, len(typ.Fields))
+		for _, field := range typ.Fields {
+			renamed := n.toGatewayField(typName, field.Name)
+			if other, ok := originals[renamed]; ok {
+				return oops.Errorf("fields %s.%s and %s are both named %s", typName, other, field.Name, renamed)
+			}
+			originals[renamed] = field.Name
+			delete(renamedTo, field.Name)
+		}
+		for original := range renamedTo {
+			return oops.Errorf("renamed field %s.%s does not exist", typName, original)
+		}
+	}
+	return nil
+}
+
+// renameSelections returns a copy of selectionSet, selected on the gateway
+// type typ, with the service's own type and field names. Renamed fields keep
+// their alias, so that results have the gateway's field names.
+func (n *TypeNamespace) renameSelections(typ graphql.Type, selectionSet *graphql.SelectionSet) *graphql.SelectionSet {
+	if n == nil || selectionSet == nil {
+		return selectionSet
+	}
+
+	for {
+		switch inner := typ.(type) {
+		case *graphql.NonNull:
+			typ = inner.Type
+			continue
+		case *graphql.List:
+			typ = inner.Type
+			continue
+		}
+		break
+	}
+	object, _ := typ.(*graphql.Object)
+	union, _ := typ.(*graphql.Union)
+
+	renamed := &graphql.SelectionSet{
+		Selections: make([]*graphql.Selection, 0, len(selectionSet.Selections)),
+		Fragments:  make([]*graphql.Fragment, 0, len(selectionSet.Fragments)),
+	}
+	for _, selection := range selectionSet.Selections {
+		name := selection.Name
+		var fieldType graphql.Type
+		if object != nil {
+			name = n.toServiceField(n.toService(object.Name), selection.Name)
+			if field, ok := object.Fields[selection.Name]; ok {
+				fieldType = field.Type
+			}
+		}
+		if name != selection.Name || selection.SelectionSet != nil {
+			copied := *selection
+			copied.Name = name
+			copied.SelectionSet = n.renameSelections(fieldType, selection.SelectionSet)
+			selection = &copied
+		}
+		renamed.Selections = append(renamed.Selections, selection)
+	}
+	for _, fragment := range selectionSet.Fragments {
+		fragmentType := typ
+		if union != nil {
+			fragmentType = union.Types[fragment.On]
+		}
+		renamed.Fragments = append(renamed.Fragments, &graphql.Fragment{
+			On:           n.toService(fragment.On),
+			SelectionSet: n.renameSelections(fragmentType, fragment.SelectionSet),
+			Directives:   fragment.Directives,
+		})
+	}
+	return renamed
+}
+
+// renameTypeNames renames the __typename values in a result of the service
+// to their gateway names.
+func (n *TypeNamespace) renameTypeNames(result interface{}) {
+	if n == nil {
+		return
+	}
+	switch result := result.(type) {
+	case map[string]interface{}:
+		for key, value := range result {
+			if name, ok := value.(string); ok && key == "__typename" {
+				// __typename is only selected on objects.
+				result[key] = n.toGateway(name, "OBJECT")
+				continue
+			}
+			n.renameTypeNames(value)
+		}
+	case []interface{}:
+		for _, value := range result {
+			n.renameTypeNames(value)
+		}
+	}
+}
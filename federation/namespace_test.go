@@ -0,0 +1,218 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+)
+
+type namespaceUser struct {
+	Id int64
+}
+
+type namespaceUserKeys struct {
+	Id int64
+}
+
+type shippingInvoice struct {
+	Id    int64
+	Total string
+}
+
+// makeNamespaceExecutors builds services billing and shipping, that both have
+// an unrelated Invoice type.
+func makeNamespaceExecutors(t *testing.T) map[string]ExecutorClient {
+	type Invoice struct {
+		Id    int64
+		Total int64
+	}
+	type Refund struct {
+		Id     int64
+		Reason string
+	}
+
+	billing := schemabuilder.NewSchemaWithName("billing")
+	billing.Object("User", namespaceUser{}, schemabuilder.RootObject).Key("id")
+	billing.Object("Invoice", Invoice{})
+	billing.Object("Refund", Refund{})
+	billing.Query().FieldFunc("users", func() []*namespaceUser {
+		return []*namespaceUser{{Id: 1}}
+	})
+	type Document struct {
+		schemabuilder.Union
+		*Invoice
+		*Refund
+	}
+	billing.Query().FieldFunc("documents", func() []*Document {
+		return []*Document{
+			{Invoice: &Invoice{Id: 1, Total: 100}},
+			{Refund: &Refund{Id: 2, Reason: "damaged"}},
+		}
+	})
+
+	shipping := schemabuilder.NewSchemaWithName("shipping")
+	shipping.FederatedFieldFunc("User", func(args struct{ Keys []namespaceUserKeys }) []*namespaceUser {
+		users := make([]*namespaceUser, 0, len(args.Keys))
+		for _, key := range args.Keys {
+			users = append(users, &namespaceUser{Id: key.Id})
+		}
+		return users
+	})
+	shipping.Object("Invoice", shippingInvoice{})
+	user := shipping.Object("User", namespaceUser{})
+	user.Key("id")
+	user.FieldFunc("invoices", func(u *namespaceUser) []*shippingInvoice {
+		return []*shippingInvoice{{Id: u.Id, Total: "2 parcels"}}
+	})
+
+	execs, err := makeExecutors(map[string]*schemabuilder.Schema{
+		"billing":  billing,
+		"shipping": shipping,
+	})
+	require.NoError(t, err)
+	return execs
+}
+
+func TestTypeNamespaces(t *testing.T) {
+	ctx := context.Background()
+
+	// Without namespaces, the two Invoice types are merged and conflict.
+	_, err := NewExecutor(ctx, makeNamespaceExecutors(t), &CustomExecutorArgs{})
+	require.Error(t, err)
+
+	e, err := NewExecutor(ctx, makeNamespaceExecutors(t), &CustomExecutorArgs{
+		TypeNamespaces: map[string]*TypeNamespace{
+			"billing":  {Prefix: "Billing_", Renames: map[string]string{"User": "User"}},
+			"shipping": {Renames: map[string]string{"Invoice": "Shipment"}},
+		},
+	})
+	require.NoError(t, err)
+
+	res, _, err := e.Execute(ctx, graphql.MustParse(`{
+		documents {
+			__typename
+			... on Billing_Invoice { total }
+			... on Billing_Refund { reason }
+		}
+		users {
+			id
+			invoices { __typename total }
+		}
+	}`, nil), nil)
+	require.NoError(t, err)
+
+	var expected interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"documents": [
+			{"__typename": "Billing_Invoice", "total": 100},
+			{"__typename": "Billing_Refund", "reason": "damaged"}
+		],
+		"users": [
+			{"__key": 1, "id": 1, "invoices": [{"__typename": "Shipment", "total": "2 parcels"}]}
+		]
+	}`), &expected))
+	assert.Equal(t, expected, res)
+
+	_, err = NewExecutor(ctx, makeNamespaceExecutors(t), &CustomExecutorArgs{
+		TypeNamespaces: map[string]*TypeNamespace{
+			"billing": {Renames: map[string]string{"Bogus": "Other"}},
+		},
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "renamed type Bogus does not exist")
+	}
+
+	_, err = NewExecutor(ctx, makeNamespaceExecutors(t), &CustomExecutorArgs{
+		TypeNamespaces: map[string]*TypeNamespace{"bogus": {Prefix: "Bogus_"}},
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "type namespace for unknown service bogus")
+	}
+}
+
+func TestFieldRenames(t *testing.T) {
+	ctx := context.Background()
+
+	e, err := NewExecutor(ctx, makeNamespaceExecutors(t), &CustomExecutorArgs{
+		TypeNamespaces: map[string]*TypeNamespace{
+			"billing": {
+				Prefix:  "Billing_",
+				Renames: map[string]string{"User": "User"},
+				Fields: map[string]map[string]string{
+					"Query":  {"allUsers": "users"},
+					"Refund": {"explanation": "reason"},
+				},
+			},
+			"shipping": {
+				Renames: map[string]string{"Invoice": "Shipment"},
+				Fields:  map[string]map[string]string{"Invoice": {"contents": "total"}},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	res, _, err := e.Execute(ctx, graphql.MustParse(`{
+		documents {
+			... on Billing_Refund { explanation why: explanation }
+		}
+		allUsers {
+			id
+			invoices { contents }
+		}
+	}`, nil), nil)
+	require.NoError(t, err)
+
+	var expected interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"documents": [
+			null,
+			{"__typename": "Billing_Refund", "explanation": "damaged", "why": "damaged"}
+		],
+		"allUsers": [
+			{"__key": 1, "id": 1, "invoices": [{"contents": "2 parcels"}]}
+		]
+	}`), &expected))
+	assert.Equal(t, expected, res)
+
+	// The service names of renamed fields are not exposed.
+	_, _, err = e.Execute(ctx, graphql.MustParse(`{ users { id } }`, nil), nil)
+	assert.Error(t, err)
+
+	for _, testCase := range []struct {
+		fields map[string]map[string]string
+		err    string
+	}{
+		{
+			fields: map[string]map[string]string{"Refund": {"explanation": "bogus"}},
+			err:    "renamed field Refund.bogus does not exist",
+		},
+		{
+			fields: map[string]map[string]string{"Refund": {"id": "reason"}},
+			err:    "fields Refund.id and reason are both named id",
+		},
+		{
+			fields: map[string]map[string]string{"Bogus": {"a": "b"}},
+			err:    "type Bogus with renamed fields is not an object",
+		},
+		{
+			// The keys of User are passed to shipping by their gateway names.
+			fields: map[string]map[string]string{"User": {"userId": "id"}},
+			err:    "field User.id is a federation key and cannot be renamed to userId",
+		},
+	} {
+		_, err = NewExecutor(ctx, makeNamespaceExecutors(t), &CustomExecutorArgs{
+			TypeNamespaces: map[string]*TypeNamespace{
+				"billing": {Prefix: "Billing_", Renames: map[string]string{"User": "User"}, Fields: testCase.fields},
+			},
+		})
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), testCase.err)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package federation
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+)
+
+type nestedListUser struct {
+	Id int64
+}
+
+type nestedListTeam struct {
+	Name    string
+	Members [][]*nestedListUser
+}
+
+type nestedListUserKeys struct {
+	Id int64
+}
+
+type nestedListProfile struct {
+	Id  int64
+	Bio string
+}
+
+func TestExecutorNestedLists(t *testing.T) {
+	ctx := context.Background()
+
+	users := schemabuilder.NewSchemaWithName("users")
+	users.Object("User", nestedListUser{}, schemabuilder.RootObject).Key("id")
+	users.Query().FieldFunc("groups", func() [][]*nestedListUser {
+		return [][]*nestedListUser{{{Id: 1}, nil}, nil, {}, {{Id: 2}, {Id: 3}}}
+	})
+	users.Query().FieldFunc("teams", func() []*nestedListTeam {
+		return []*nestedListTeam{
+			{Name: "a", Members: [][]*nestedListUser{{{Id: 4}}, {nil, {Id: 5}}}},
+			nil,
+			{Name: "b"},
+		}
+	})
+
+	profiles := schemabuilder.NewSchemaWithName("profiles")
+	profiles.Object("User", nestedListProfile{}).Key("id")
+	profiles.FederatedFieldFunc("User", func(args struct{ Keys []nestedListUserKeys }) []*nestedListProfile {
+		out := make([]*nestedListProfile, 0, len(args.Keys))
+		for _, key := range args.Keys {
+			out = append(out, &nestedListProfile{Id: key.Id, Bio: fmt.Sprintf("bio %d", key.Id)})
+		}
+		return out
+	})
+
+	execs, err := makeExecutors(map[string]*schemabuilder.Schema{"users": users, "profiles": profiles})
+	require.NoError(t, err)
+	counting := &countingExecutorClient{ExecutorClient: execs["profiles"]}
+	execs["profiles"] = counting
+	e, err := NewExecutor(ctx, execs, &CustomExecutorArgs{})
+	require.NoError(t, err)
+
+	// Users nested in lists of lists are fetched from profiles in a single
+	// batch, skipping null users, and stitched back in place.
+	counting.queries = nil
+	runAndValidateQueryResults(t, ctx, e, `{
+		groups { id bio }
+	}`, `{
+		"groups": [
+			[{"__key": 1, "id": 1, "bio": "bio 1"}, null],
+			[],
+			[],
+			[{"__key": 2, "id": 2, "bio": "bio 2"}, {"__key": 3, "id": 3, "bio": "bio 3"}]
+		]
+	}`)
+	assert.Equal(t, []string{federationField}, counting.queries)
+
+	// So are users nested in lists of lists inside lists of objects.
+	counting.queries = nil
+	runAndValidateQueryResults(t, ctx, e, `{
+		teams { name members { id bio } }
+	}`, `{
+		"teams": [
+			{"name": "a", "members": [
+				[{"__key": 4, "id": 4, "bio": "bio 4"}],
+				[null, {"__key": 5, "id": 5, "bio": "bio 5"}]
+			]},
+			null,
+			{"name": "b", "members": []}
+		]
+	}`)
+	assert.Equal(t, []string{federationField}, counting.queries)
+
+	// Lists without any users are not fetched at all.
+	counting.queries = nil
+	runAndValidateQueryResults(t, ctx, e, `{
+		teams { name }
+	}`, `{
+		"teams": [{"name": "a"}, null, {"name": "b"}]
+	}`)
+	assert.Empty(t, counting.queries)
+}
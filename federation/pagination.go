@@ -0,0 +1,136 @@
+package federation
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// PaginationContract controls how the gateway verifies the pagination types
+// shared by services: PageInfo, and the connection and edge types of
+// paginated fields, named like UserConnection and UserEdge. Pagination types
+// that differ between services, for example a PageInfo missing a field in
+// one service, otherwise merge without error and only break queries at
+// runtime.
+type PaginationContract int
+
+const (
+	// PaginationUnchecked merges pagination types like any other types.
+	PaginationUnchecked PaginationContract = iota
+	// PaginationStrict requires pagination types shared by services to be
+	// structurally identical: they must have the same fields, with the same
+	// types and arguments.
+	PaginationStrict
+	// PaginationNormalized is like PaginationStrict, but lets types differ
+	// in nullability, such as a nullable endCursor in one service and a
+	// non-null one in another. The merged fields are nullable if they are in
+	// any service.
+	PaginationNormalized
+)
+
+// isPaginationType returns whether name is a pagination type.
+func isPaginationType(name string) bool {
+	return name == "PageInfo" || strings.HasSuffix(name, "Connection") || strings.HasSuffix(name, "Edge")
+}
+
+// checkPaginationContract verifies that the pagination types shared by the
+// services of schemas satisfy contract.
+func checkPaginationContract(schemas map[string]*introspectionQueryResult, contract PaginationContract) error {
+	if contract == PaginationUnchecked {
+		return nil
+	}
+
+	services := make([]string, 0, len(schemas))
+	for service := range schemas {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+
+	// first holds the first definition of every pagination type, and the
+	// service defining it.
+	type definition struct {
+		service string
+		typ     *introspectionType
+	}
+	first := make(map[string]definition)
+	for _, service := range services {
+		for i := range schemas[service].Schema.Types {
+			typ := &schemas[service].Schema.Types[i]
+			if !isPaginationType(typ.Name) {
+				continue
+			}
+			other, ok := first[typ.Name]
+			if !ok {
+				first[typ.Name] = definition{service: service, typ: typ}
+				continue
+			}
+			if err := comparePaginationTypes(other.typ, typ, contract); err != nil {
+				return fmt.Errorf("pagination type %s differs between services %s and %s: %v", typ.Name, other.service, service, err)
+			}
+		}
+	}
+	return nil
+}
+
+// comparePaginationTypes returns an error if a and b are not identical
+// according to contract.
+func comparePaginationTypes(a, b *introspectionType, contract PaginationContract) error {
+	if a.Kind != b.Kind {
+		return fmt.Errorf("kinds %s and %s differ", a.Kind, b.Kind)
+	}
+
+	fields := make(map[string]introspectionField, len(a.Fields))
+	for _, field := range a.Fields {
+		fields[field.Name] = field
+	}
+	for _, field := range b.Fields {
+		other, ok := fields[field.Name]
+		if !ok {
+			return fmt.Errorf("field %s is missing in one service", field.Name)
+		}
+		delete(fields, field.Name)
+
+		if !samePaginationTypeRef(other.Type, field.Type, contract) {
+			return fmt.Errorf("field %s has types %s and %s", field.Name, other.Type, field.Type)
+		}
+		if len(other.Args) != len(field.Args) {
+			return fmt.Errorf("field %s has different arguments", field.Name)
+		}
+		args := make(map[string]*introspectionTypeRef, len(other.Args))
+		for _, arg := range other.Args {
+			args[arg.Name] = arg.Type
+		}
+		for _, arg := range field.Args {
+			otherType, ok := args[arg.Name]
+			if !ok || !samePaginationTypeRef(otherType, arg.Type, contract) {
+				return fmt.Errorf("field %s has different arguments", field.Name)
+			}
+		}
+	}
+	for _, field := range a.Fields {
+		if _, ok := fields[field.Name]; ok {
+			return fmt.Errorf("field %s is missing in one service", field.Name)
+		}
+	}
+	return nil
+}
+
+// samePaginationTypeRef returns whether a and b are identical, ignoring
+// non-null modifiers for PaginationNormalized.
+func samePaginationTypeRef(a, b *introspectionTypeRef, contract PaginationContract) bool {
+	if contract == PaginationNormalized {
+		a, b = stripNonNull(a), stripNonNull(b)
+	}
+	return a.String() == b.String()
+}
+
+// stripNonNull returns t without non-null modifiers.
+func stripNonNull(t *introspectionTypeRef) *introspectionTypeRef {
+	if t == nil {
+		return nil
+	}
+	if t.Kind == "NON_NULL" {
+		return stripNonNull(t.OfType)
+	}
+	return &introspectionTypeRef{Kind: t.Kind, Name: t.Name, OfType: stripNonNull(t.OfType)}
+}
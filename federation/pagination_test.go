@@ -0,0 +1,63 @@
+package federation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func paginationSchema(endCursor *introspectionTypeRef, extraField bool) *introspectionQueryResult {
+	fields := []introspectionField{
+		{Name: "endCursor", Type: endCursor},
+		{Name: "hasNextPage", Type: &introspectionTypeRef{Kind: "NON_NULL", OfType: &introspectionTypeRef{Kind: "SCALAR", Name: "bool"}}},
+	}
+	if extraField {
+		fields = append(fields, introspectionField{Name: "pages", Type: &introspectionTypeRef{Kind: "LIST", OfType: &introspectionTypeRef{Kind: "SCALAR", Name: "string"}}})
+	}
+	return &introspectionQueryResult{Schema: introspectionSchema{Types: []introspectionType{
+		{Name: "PageInfo", Kind: "OBJECT", Fields: fields},
+		// Other types are merged as usual.
+		{Name: "User", Kind: "OBJECT", Fields: fields[:1]},
+	}}}
+}
+
+func TestCheckPaginationContract(t *testing.T) {
+	nullable := &introspectionTypeRef{Kind: "SCALAR", Name: "string"}
+	nonNull := &introspectionTypeRef{Kind: "NON_NULL", OfType: nullable}
+
+	identical := map[string]*introspectionQueryResult{
+		"a": paginationSchema(nonNull, false),
+		"b": paginationSchema(nonNull, false),
+	}
+	assert.NoError(t, checkPaginationContract(identical, PaginationStrict))
+
+	nullability := map[string]*introspectionQueryResult{
+		"a": paginationSchema(nonNull, false),
+		"b": paginationSchema(nullable, false),
+	}
+	assert.NoError(t, checkPaginationContract(nullability, PaginationUnchecked))
+	assert.EqualError(t, checkPaginationContract(nullability, PaginationStrict),
+		"pagination type PageInfo differs between services a and b: field endCursor has types string! and string")
+	assert.NoError(t, checkPaginationContract(nullability, PaginationNormalized))
+
+	missing := map[string]*introspectionQueryResult{
+		"a": paginationSchema(nonNull, true),
+		"b": paginationSchema(nullable, false),
+	}
+	assert.EqualError(t, checkPaginationContract(missing, PaginationNormalized),
+		"pagination type PageInfo differs between services a and b: field pages is missing in one service")
+	missing["a"], missing["b"] = missing["b"], missing["a"]
+	assert.EqualError(t, checkPaginationContract(missing, PaginationNormalized),
+		"pagination type PageInfo differs between services a and b: field pages is missing in one service")
+}
+
+func TestPaginationContractRequiresDefaultSyncer(t *testing.T) {
+	_, err := NewExecutor(context.Background(), map[string]ExecutorClient{}, &CustomExecutorArgs{
+		SchemaSyncer:       &IntrospectionSchemaSyncer{},
+		PaginationContract: PaginationStrict,
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "pagination contracts require the default schema syncer")
+}
@@ -0,0 +1,168 @@
+package federation
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/samsarahq/go/oops"
+
+	"github.com/denkhaus/thunder/graphql"
+)
+
+// PersistedOperations maps the ids of persisted operations to their text.
+//
+// An executor with persisted operations plans all of them when it starts and
+// whenever the schema changes, and ExecutePersisted runs only those
+// operations. Gateways that serve untrusted clients set
+// CustomExecutorArgs.RequirePersistedOperations to reject all other queries.
+type PersistedOperations map[string]string
+
+// LoadPersistedOperations reads a manifest of persisted operations, a JSON
+// object mapping operation ids to operation text.
+func LoadPersistedOperations(r io.Reader) (PersistedOperations, error) {
+	var operations PersistedOperations
+	if err := json.NewDecoder(r).Decode(&operations); err != nil {
+		return nil, oops.Wrapf(err, "decoding persisted operations")
+	}
+	return operations, nil
+}
+
+// persistedPlanCacheSize is the number of plans cached for every persisted
+// operation with variables, see persistedOperation.
+const persistedPlanCacheSize = 64
+
+// errNotPersisted is returned for queries that are not persisted operations
+// by executors that require them.
+var errNotPersisted = graphql.NewClientError("only persisted operations are allowed")
+
+// persistedOperation is a persisted operation checked against a planner.
+type persistedOperation struct {
+	text string
+	// plan is the plan of operations without variables. Operations with
+	// variables are planned for their variables, as variables are
+	// substituted when parsing, and the plans of the most recently used
+	// variables are cached in plans.
+	plan *queryPlan
+
+	mu    sync.Mutex
+	order *list.List
+	plans map[string]*list.Element
+}
+
+type persistedPlan struct {
+	variables string
+	plan      *queryPlan
+}
+
+// planFor returns the plan of the operation for variables.
+func (o *persistedOperation) planFor(planner *Planner, variables map[string]interface{}) (*queryPlan, error) {
+	if o.plan != nil {
+		return o.plan, nil
+	}
+
+	// Maps are marshaled with sorted keys, so equal variables have the same
+	// key.
+	marshaled, err := json.Marshal(variables)
+	if err != nil {
+		return nil, graphql.NewClientError("invalid variables: %v", err)
+	}
+	key := string(marshaled)
+
+	o.mu.Lock()
+	if elem, ok := o.plans[key]; ok {
+		o.order.MoveToFront(elem)
+		o.mu.Unlock()
+		return elem.Value.(*persistedPlan).plan, nil
+	}
+	o.mu.Unlock()
+
+	query, err := graphql.Parse(o.text, variables)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := planner.planQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, ok := o.plans[key]; !ok {
+		o.plans[key] = o.order.PushFront(&persistedPlan{variables: key, plan: plan})
+		if o.order.Len() > persistedPlanCacheSize {
+			oldest := o.order.Back()
+			o.order.Remove(oldest)
+			delete(o.plans, oldest.Value.(*persistedPlan).variables)
+		}
+	}
+	return plan, nil
+}
+
+// planPersistedOperations plans all operations against planner, and fails if
+// any of them is invalid.
+func planPersistedOperations(planner *Planner, operations PersistedOperations) (map[string]*persistedOperation, error) {
+	planned := make(map[string]*persistedOperation, len(operations))
+	for id, text := range operations {
+		hasVariables, err := operationHasVariables(text)
+		if err != nil {
+			return nil, oops.Wrapf(err, "parsing persisted operation %s", id)
+		}
+		query, err := graphql.Parse(text, nil)
+		if err != nil {
+			return nil, oops.Wrapf(err, "parsing persisted operation %s", id)
+		}
+		plan, err := planner.planQuery(query)
+		if err != nil {
+			return nil, oops.Wrapf(err, "planning persisted operation %s", id)
+		}
+
+		operation := &persistedOperation{text: text}
+		if hasVariables {
+			operation.order = list.New()
+			operation.plans = make(map[string]*list.Element)
+		} else {
+			operation.plan = plan
+		}
+		planned[id] = operation
+	}
+	return planned, nil
+}
+
+// operationHasVariables returns true if the operation in text declares
+// variables.
+func operationHasVariables(text string) (bool, error) {
+	document, err := parser.Parse(parser.ParseParams{Source: text})
+	if err != nil {
+		return false, err
+	}
+	for _, definition := range document.Definitions {
+		if operation, ok := definition.(*ast.OperationDefinition); ok && len(operation.VariableDefinitions) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ExecutePersisted executes the persisted operation id with variables. It
+// rejects operations that are not persisted.
+func (e *Executor) ExecutePersisted(ctx context.Context, id string, variables map[string]interface{}, optionalArgs interface{}) (interface{}, []interface{}, error) {
+	planner, operations := e.getPlans()
+	operation, ok := operations[id]
+	if !ok {
+		return nil, nil, graphql.NewClientError("unknown persisted operation %s", id)
+	}
+
+	plan, err := operation.planFor(planner, variables)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := e.checkMaintenance(plan.kind); err != nil {
+		return nil, nil, err
+	}
+	return e.executeQueryPlan(ctx, plan, planner, optionalArgs)
+}
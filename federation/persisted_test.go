@@ -0,0 +1,79 @@
+package federation
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+)
+
+func TestExecutePersisted(t *testing.T) {
+	s1 := schemabuilder.NewSchemaWithName("s1")
+	s1.Query().FieldFunc("greeting", func(args struct{ Name string }) string {
+		return "hello " + args.Name
+	})
+	execs, err := makeExecutors(map[string]*schemabuilder.Schema{"s1": s1})
+	require.NoError(t, err)
+
+	operations, err := LoadPersistedOperations(strings.NewReader(`{
+		"static": "{ greeting(name: \"alice\") }",
+		"withVariables": "query Greeting($name: String!) { greeting(name: $name) }"
+	}`))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	e, err := NewExecutor(ctx, execs, &CustomExecutorArgs{PersistedOperations: operations})
+	require.NoError(t, err)
+
+	_, planned := e.getPlans()
+	require.Len(t, planned, 2)
+	assert.NotNil(t, planned["static"].plan)
+	assert.Nil(t, planned["withVariables"].plan)
+
+	res, _, err := e.ExecutePersisted(ctx, "static", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"greeting": "hello alice"}, res)
+
+	res, _, err = e.ExecutePersisted(ctx, "withVariables", map[string]interface{}{"name": "bob"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"greeting": "hello bob"}, res)
+
+	// Plans of operations with variables are cached by variables.
+	planner, _ := e.getPlans()
+	bob, err := planned["withVariables"].planFor(planner, map[string]interface{}{"name": "bob"})
+	require.NoError(t, err)
+	again, err := planned["withVariables"].planFor(planner, map[string]interface{}{"name": "bob"})
+	require.NoError(t, err)
+	assert.True(t, bob == again)
+	carol, err := planned["withVariables"].planFor(planner, map[string]interface{}{"name": "carol"})
+	require.NoError(t, err)
+	assert.False(t, bob == carol)
+	res, _, err = e.ExecutePersisted(ctx, "withVariables", map[string]interface{}{"name": "carol"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"greeting": "hello carol"}, res)
+
+	_, _, err = e.ExecutePersisted(ctx, "unknown", nil, nil)
+	assert.EqualError(t, err, "unknown persisted operation unknown")
+
+	// Executors requiring persisted operations reject other queries.
+	e, err = NewExecutor(ctx, execs, &CustomExecutorArgs{PersistedOperations: operations, RequirePersistedOperations: true})
+	require.NoError(t, err)
+	_, _, err = e.Execute(ctx, graphql.MustParse(`{ greeting(name: "mallory") }`, nil), nil)
+	assert.EqualError(t, err, "only persisted operations are allowed")
+	_, _, _, err = e.ExecuteWithDefer(ctx, graphql.MustParse(`{ greeting(name: "mallory") }`, nil), nil)
+	assert.EqualError(t, err, "only persisted operations are allowed")
+	res, _, err = e.ExecutePersisted(ctx, "static", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"greeting": "hello alice"}, res)
+
+	// Executors do not start with invalid persisted operations.
+	_, err = NewExecutor(ctx, execs, &CustomExecutorArgs{PersistedOperations: PersistedOperations{
+		"invalid": "{ missing }",
+	}})
+	assert.Error(t, err)
+}
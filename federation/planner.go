@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"sync"
 
 	"github.com/denkhaus/thunder/graphql"
 )
@@ -50,6 +51,11 @@ type Plan struct {
 	Type         string                // Type is the name of the object type each subplan is nested on
 	SelectionSet *graphql.SelectionSet // Selections that will be resolved in this part of the plan
 	After        []*Plan               // Subplans from nested queries on this path
+	BestEffort   bool                  // BestEffort subplans are dropped if they exceed the best effort timeout
+	Alternatives []string              // Alternatives are other services that can resolve the subplan, tried in order if Service fails
+
+	extractorOnce sync.Once     // extractorOnce guards extractor
+	extractor     *keyExtractor // extractor extracts the keys of this subplan from the results of its parent
 }
 
 // Planner is responsible for taking a query created a plan that will be used by the executor.
@@ -58,6 +64,19 @@ type Plan struct {
 type Planner struct {
 	schema    *SchemaWithFederationInfo //schema describes what fields the graphql servers know about along with the services that know how to execute each field
 	flattener *flattener                //flattener knows how to combine all the fragments on a query into a singel query
+	gateway   *graphql.Schema           //gateway holds the root fields resolved by the gateway itself, if any
+
+	// serviceCapabilities holds the capabilities of the services that
+	// expose them, see AddCapabilities.
+	serviceCapabilities map[string]Capabilities
+	// schemaVersions identify the schema of every service, so that the
+	// capabilities of services whose schema is unchanged are not fetched
+	// again. They are set by IntrospectionSchemaSyncer.
+	schemaVersions map[string]string
+
+	// migrations holds the fields migrating between services, by type and
+	// field name, which are resolved by the service they migrate from.
+	migrations map[string]map[string]FieldMigration
 }
 
 // Executing a subquery
@@ -127,6 +146,9 @@ func (e *Planner) planObject(typ *graphql.Object, selectionSet *graphql.Selectio
 
 	var localSelections []*graphql.Selection
 	selectionsByService := make(map[string][]*graphql.Selection)
+	// bestEffortByService holds the selections marked @bestEffort, which are
+	// planned separately so they can be dropped without the other selections.
+	bestEffortByService := make(map[string][]*graphql.Selection)
 
 	// Flattened queries should not have any fragments
 	if len(selectionSet.Fragments) > 0 {
@@ -145,22 +167,33 @@ func (e *Planner) planObject(typ *graphql.Object, selectionSet *graphql.Selectio
 			return nil, fmt.Errorf("typ %s has no field %s", typ.Name, selection.Name)
 		}
 
-		fieldInfo := e.schema.Fields[field]
+		services := e.schema.Fields[field].Services
+		// Migrating fields are resolved by the service they migrate from,
+		// and shadowed on the other, see FieldMigration.
+		if migration, ok := e.migrations[typ.Name][selection.Name]; ok && services[migration.From] {
+			services = map[string]bool{migration.From: true}
+		}
 
 		// Prioritize resolving as many fields as we can in the current service
-		if fieldInfo.Services[service] {
+		if services[service] {
 			localSelections = append(localSelections, selection)
 		} else {
+			// Pick the first capable service, so plans are deterministic
+			// for fields resolvable by several services.
 			serviceWithField := ""
-
-			for service, hasField := range fieldInfo.Services {
-				if hasField {
+			for service, hasField := range services {
+				if hasField && (serviceWithField == "" || service < serviceWithField) {
 					serviceWithField = service
 				}
 			}
 
-			selectionsByService[serviceWithField] = append(
-				selectionsByService[serviceWithField], selection)
+			if isBestEffort(selection) {
+				bestEffortByService[serviceWithField] = append(
+					bestEffortByService[serviceWithField], selection)
+			} else {
+				selectionsByService[serviceWithField] = append(
+					selectionsByService[serviceWithField], selection)
+			}
 		}
 	}
 
@@ -200,25 +233,41 @@ func (e *Planner) planObject(typ *graphql.Object, selectionSet *graphql.Selectio
 
 	// needKey is true for selections on other graphql servers
 	needKey := false
-
-	// List of services with selections in the query
-	var otherServices []string
-	for other := range selectionsByService {
-		otherServices = append(otherServices, other)
-	}
-	sort.Strings(otherServices)
+	// keyServices are the services of subplans and their alternatives, which
+	// need federated keys
+	keyServices := make(map[string]bool)
 
 	// Create a plan for all selections that can be resolved in other graphql queries
-	for _, other := range otherServices {
-		selections := selectionsByService[other]
-		needKey = true
+	for _, bestEffort := range []bool{false, true} {
+		byService := selectionsByService
+		if bestEffort {
+			byService = bestEffortByService
+		}
 
-		subPlan, err := e.plan(typ, &graphql.SelectionSet{Selections: selections}, other)
-		if err != nil {
-			return nil, fmt.Errorf("planning for %s: %v", other, err)
+		// List of services with selections in the query
+		var otherServices []string
+		for other := range byService {
+			otherServices = append(otherServices, other)
 		}
+		sort.Strings(otherServices)
 
-		p.After = append(p.After, subPlan)
+		for _, other := range otherServices {
+			selections := byService[other]
+			needKey = true
+
+			subPlan, err := e.plan(typ, &graphql.SelectionSet{Selections: selections}, other)
+			if err != nil {
+				return nil, fmt.Errorf("planning for %s: %v", other, err)
+			}
+			subPlan.BestEffort = bestEffort
+			subPlan.Alternatives = e.alternativeServices(typ, subPlan)
+
+			keyServices[other] = true
+			for _, alternative := range subPlan.Alternatives {
+				keyServices[alternative] = true
+			}
+			p.After = append(p.After, subPlan)
+		}
 	}
 
 	// knows how to resolve it, and we can take the results from that subquery and stitch it into the final response
@@ -241,7 +290,7 @@ func (e *Planner) planObject(typ *graphql.Object, selectionSet *graphql.Selectio
 			selections := make([]*graphql.Selection, 0, len(typ.Fields))
 			for name, field := range typ.Fields {
 				for service := range field.FederatedKey {
-					if len(selectionsByService[service]) > 0 {
+					if keyServices[service] {
 						selections = append(selections, &graphql.Selection{
 							Name:         name,
 							Alias:        name,
@@ -273,6 +322,91 @@ func (e *Planner) planObject(typ *graphql.Object, selectionSet *graphql.Selectio
 
 }
 
+// alternativeServices returns the services other than subPlan.Service that
+// can resolve all of subPlan's selections on typ, sorted. Subplans nested on
+// an object can only move to services that have the object federated.
+func (e *Planner) alternativeServices(typ *graphql.Object, subPlan *Plan) []string {
+	// Candidates must resolve the first field, and are then checked against
+	// all selections.
+	candidates := make(map[string]bool)
+	for _, selection := range subPlan.SelectionSet.Selections {
+		if selection.Name == "__typename" {
+			continue
+		}
+		for service, ok := range e.schema.Fields[typ.Fields[selection.Name]].Services {
+			if ok && service != subPlan.Service {
+				candidates[service] = true
+			}
+		}
+		break
+	}
+
+	isRoot := typ == e.schema.Schema.Query || typ == e.schema.Schema.Mutation
+	var alternatives []string
+	for service := range candidates {
+		if !isRoot && !isFederatedOn(typ, service) {
+			continue
+		}
+		if e.canResolve(typ, subPlan.SelectionSet, service) {
+			alternatives = append(alternatives, service)
+		}
+	}
+	sort.Strings(alternatives)
+	return alternatives
+}
+
+// isFederatedOn returns true if service can fetch objects of type typ by key.
+func isFederatedOn(typ *graphql.Object, service string) bool {
+	for _, field := range typ.Fields {
+		if field.FederatedKey[service] {
+			return true
+		}
+	}
+	return false
+}
+
+// canResolve returns true if service can resolve all selections in
+// selectionSet on typ, including nested selections.
+func (e *Planner) canResolve(typ graphql.Type, selectionSet *graphql.SelectionSet, service string) bool {
+	switch typ := typ.(type) {
+	case *graphql.NonNull:
+		return e.canResolve(typ.Type, selectionSet, service)
+	case *graphql.List:
+		return e.canResolve(typ.Type, selectionSet, service)
+	case *graphql.Union:
+		for _, fragment := range selectionSet.Fragments {
+			object, ok := typ.Types[fragment.On]
+			if !ok || !e.canResolve(object, fragment.SelectionSet, service) {
+				return false
+			}
+		}
+		return true
+	case *graphql.Object:
+		for _, selection := range selectionSet.Selections {
+			if selection.Name == "__typename" {
+				continue
+			}
+			field, ok := typ.Fields[selection.Name]
+			if !ok {
+				return false
+			}
+			info, ok := e.schema.Fields[field]
+			if !ok || !info.Services[service] {
+				return false
+			}
+			if selection.SelectionSet != nil && !e.canResolve(field.Type, selection.SelectionSet, service) {
+				return false
+			}
+		}
+		return true
+	}
+	return true
+}
+
+// planUnion plans a union owned by service. It always selects __typename, so
+// that the executor can partition the results by member type. Fields of a
+// member type owned by other services become subplans whose path ends with a
+// KindType step, so that they only fetch the keys of results of that type.
 func (e *Planner) planUnion(typ *graphql.Union, selectionSet *graphql.SelectionSet, service string) (*Plan, error) {
 	plan := &Plan{
 		// TODO: only include __typename if needed for dispatching? ie. len(types) > 1 and len(fragments) > 0?
@@ -368,6 +502,13 @@ func reversePaths(p *Plan) {
 }
 
 func (e *Planner) planRoot(query *graphql.Query) (*Plan, error) {
+	p, _, _, err := e.planRootFlattened(query)
+	return p, err
+}
+
+// planRootFlattened plans query like planRoot, and also returns the root
+// type and the flattened selections of query.
+func (e *Planner) planRootFlattened(query *graphql.Query) (*Plan, graphql.Type, *graphql.SelectionSet, error) {
 	var schema graphql.Type
 	switch query.Kind {
 	case queryString:
@@ -375,31 +516,82 @@ func (e *Planner) planRoot(query *graphql.Query) (*Plan, error) {
 	case mutationString:
 		schema = e.schema.Schema.Mutation
 	default:
-		return nil, fmt.Errorf("unknown query kind %s", query.Kind)
+		return nil, nil, nil, fmt.Errorf("unknown query kind %s", query.Kind)
 	}
 
 	flattened, err := e.flattener.flatten(query.SelectionSet, schema)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 
 	p, err := e.plan(schema, flattened, gatewayCoordinatorServiceName)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 
 	if query.Kind == mutationString {
 		if len(p.After) > 1 {
 			// Do now allow multiple mutations in the same query to ensure that
 			// mutations run on seperate graphql servers won't be run out of order
-			return nil, errors.New("only support 1 mutation step to maintain ordering")
+			return nil, nil, nil, errors.New("only support 1 mutation step to maintain ordering")
 		}
 		for _, p := range p.After {
+			if p.BestEffort {
+				return nil, nil, nil, fmt.Errorf("@%s is not supported on mutations", BestEffortDirective)
+			}
 			p.Kind = mutationString
+			// Mutations are not idempotent, so they are never retried.
+			p.Alternatives = nil
 		}
 	}
 
 	reversePaths(p)
 
-	return p, nil
+	return p, schema, flattened, nil
+}
+
+// queryPlan is the plan of a query: the root selections resolved by the
+// gateway itself, such as introspection and _services, and the plan of the
+// selections resolved by the services.
+type queryPlan struct {
+	kind  string
+	local []*graphql.Selection
+	// remote is nil if the query has no selections for the services.
+	remote *Plan
+	// rootType and selections are the root type and the flattened selections
+	// of remote, used to propagate nulls of dropped best effort selections.
+	rootType   graphql.Type
+	selections *graphql.SelectionSet
+	// bestEffort is true if remote has best effort subplans.
+	bestEffort bool
+}
+
+// planQuery splits the gateway selections off query and plans the others.
+// All ways of executing queries plan them with planQuery, so that gateway
+// selections never reach the services.
+func (e *Planner) planQuery(query *graphql.Query) (*queryPlan, error) {
+	local, remote := splitGatewaySelections(e.gateway, query)
+	plan := &queryPlan{kind: query.Kind, local: local}
+	if remote != nil {
+		p, rootType, selections, err := e.planRootFlattened(remote)
+		if err != nil {
+			return nil, err
+		}
+		plan.remote, plan.rootType, plan.selections = p, rootType, selections
+		plan.bestEffort = hasBestEffort(p)
+	}
+	return plan, nil
+}
+
+// hasBestEffort returns whether p or any of its subplans is best effort.
+func hasBestEffort(p *Plan) bool {
+	if p.BestEffort {
+		return true
+	}
+	for _, subPlan := range p.After {
+		if hasBestEffort(subPlan) {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,132 @@
+package federation
+
+import (
+	"context"
+
+	"github.com/denkhaus/thunder/graphql"
+)
+
+// ServicePlugin rewrites the subqueries the gateway sends to a service, and
+// their responses, eg. to adapt legacy field spellings of the service, inject
+// static arguments, or strip fields from its results. Plugins are configured
+// per service, see CustomExecutorArgs.ServicePlugins.
+//
+// Plugins see subqueries as the service sees them, after the TypeNamespace of
+// the service is applied: subqueries for federated objects are nested in the
+// __federation field, and responses still use the service's type names.
+type ServicePlugin interface {
+	// RewriteRequest rewrites request before it is sent to service. The
+	// selections of request are a copy that the plugin may modify in place.
+	RewriteRequest(ctx context.Context, service string, request *QueryRequest) error
+	// RewriteResponse rewrites result, the unmarshaled response of service to
+	// request, and returns the result used by the gateway.
+	RewriteResponse(ctx context.Context, service string, request *QueryRequest, result interface{}) (interface{}, error)
+}
+
+// ServicePluginFuncs is a ServicePlugin calling Request and Response, which
+// can be nil to leave requests or responses unchanged.
+type ServicePluginFuncs struct {
+	Request  func(ctx context.Context, service string, request *QueryRequest) error
+	Response func(ctx context.Context, service string, request *QueryRequest, result interface{}) (interface{}, error)
+}
+
+func (f ServicePluginFuncs) RewriteRequest(ctx context.Context, service string, request *QueryRequest) error {
+	if f.Request == nil {
+		return nil
+	}
+	return f.Request(ctx, service, request)
+}
+
+func (f ServicePluginFuncs) RewriteResponse(ctx context.Context, service string, request *QueryRequest, result interface{}) (interface{}, error) {
+	if f.Response == nil {
+		return result, nil
+	}
+	return f.Response(ctx, service, request, result)
+}
+
+// copySelectionSet deep copies the selections and fragments of selectionSet,
+// including their arguments and directives, so plugins can modify them without
+// changing the plans they come from.
+func copySelectionSet(selectionSet *graphql.SelectionSet) *graphql.SelectionSet {
+	if selectionSet == nil {
+		return nil
+	}
+	copied := &graphql.SelectionSet{
+		Selections: make([]*graphql.Selection, 0, len(selectionSet.Selections)),
+		Fragments:  make([]*graphql.Fragment, 0, len(selectionSet.Fragments)),
+	}
+	for _, selection := range selectionSet.Selections {
+		s := *selection
+		if selection.UnparsedArgs != nil {
+			s.UnparsedArgs = copyArgs(selection.UnparsedArgs).(map[string]interface{})
+		}
+		s.Args = copyArgs(selection.Args)
+		if selection.Directives != nil {
+			s.Directives = make([]*graphql.Directive, 0, len(selection.Directives))
+			for _, directive := range selection.Directives {
+				d := *directive
+				d.Args = copyArgs(directive.Args)
+				s.Directives = append(s.Directives, &d)
+			}
+		}
+		s.SelectionSet = copySelectionSet(selection.SelectionSet)
+		copied.Selections = append(copied.Selections, &s)
+	}
+	for _, fragment := range selectionSet.Fragments {
+		f := *fragment
+		f.SelectionSet = copySelectionSet(fragment.SelectionSet)
+		copied.Fragments = append(copied.Fragments, &f)
+	}
+	return copied
+}
+
+// copyArgs deep copies the JSON objects and lists of args, such as the
+// arguments of a selection.
+func copyArgs(args interface{}) interface{} {
+	switch args := args.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(args))
+		for k, v := range args {
+			copied[k] = copyArgs(v)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(args))
+		for i, v := range args {
+			copied[i] = copyArgs(v)
+		}
+		return copied
+	default:
+		return args
+	}
+}
+
+// rewriteRequest runs the plugins of service on request.
+func (e *Executor) rewriteRequest(ctx context.Context, service string, request *QueryRequest) error {
+	plugins := e.plugins[service]
+	if len(plugins) == 0 {
+		return nil
+	}
+	query := *request.Query
+	query.SelectionSet = copySelectionSet(query.SelectionSet)
+	request.Query = &query
+	for _, plugin := range plugins {
+		if err := plugin.RewriteRequest(ctx, service, request); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewriteResponse runs the plugins of service on result in reverse order, so
+// the last plugin to rewrite the request is the first to see the response.
+func (e *Executor) rewriteResponse(ctx context.Context, service string, request *QueryRequest, result interface{}) (interface{}, error) {
+	plugins := e.plugins[service]
+	for i := len(plugins) - 1; i >= 0; i-- {
+		var err error
+		if result, err = plugins[i].RewriteResponse(ctx, service, request, result); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
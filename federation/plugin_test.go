@@ -0,0 +1,176 @@
+package federation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+)
+
+type pluginUser struct {
+	Id int64
+}
+
+type pluginUserKeys struct {
+	Id int64
+}
+
+type pluginProfile struct {
+	Id  int64
+	Bio string
+}
+
+// upperStrings uppercases the string values of key in the objects of v.
+func upperStrings(v interface{}, key string) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, value := range v {
+			if s, ok := value.(string); ok && k == key {
+				v[k] = strings.ToUpper(s)
+			} else {
+				upperStrings(value, key)
+			}
+		}
+	case []interface{}:
+		for _, value := range v {
+			upperStrings(value, key)
+		}
+	}
+}
+
+func TestServicePlugins(t *testing.T) {
+	ctx := context.Background()
+
+	users := schemabuilder.NewSchemaWithName("users")
+	users.Object("User", pluginUser{}, schemabuilder.RootObject).Key("id")
+	users.Query().FieldFunc("users", func() []*pluginUser {
+		return []*pluginUser{{Id: 1}, {Id: 2}}
+	})
+	users.Query().FieldFunc("greeting", func(args struct{ Greeting *string }) string {
+		if args.Greeting == nil {
+			return "hello"
+		}
+		return *args.Greeting
+	})
+
+	profiles := schemabuilder.NewSchemaWithName("profiles")
+	profiles.Object("User", pluginProfile{}).Key("id")
+	profiles.FederatedFieldFunc("User", func(args struct{ Keys []pluginUserKeys }) []*pluginProfile {
+		out := make([]*pluginProfile, 0, len(args.Keys))
+		for _, key := range args.Keys {
+			out = append(out, &pluginProfile{Id: key.Id, Bio: fmt.Sprintf("bio %d", key.Id)})
+		}
+		return out
+	})
+
+	execs, err := makeExecutors(map[string]*schemabuilder.Schema{"users": users, "profiles": profiles})
+	require.NoError(t, err)
+
+	var calls []string
+	var injected []bool
+	e, err := NewExecutor(ctx, execs, &CustomExecutorArgs{
+		ServicePlugins: map[string][]ServicePlugin{
+			// Inject a static argument into the greeting field.
+			"users": {ServicePluginFuncs{
+				Request: func(ctx context.Context, service string, request *QueryRequest) error {
+					for _, selection := range request.Query.SelectionSet.Selections {
+						if selection.Name == "greeting" {
+							_, ok := selection.UnparsedArgs["greeting"]
+							injected = append(injected, ok)
+							if selection.UnparsedArgs == nil {
+								selection.UnparsedArgs = make(map[string]interface{})
+							}
+							selection.UnparsedArgs["greeting"] = "howdy"
+						}
+					}
+					return nil
+				},
+			}},
+			// Rewrite the responses of federated fetches, recording the
+			// order plugins run in.
+			"profiles": {
+				ServicePluginFuncs{
+					Request: func(ctx context.Context, service string, request *QueryRequest) error {
+						calls = append(calls, "first request")
+						return nil
+					},
+					Response: func(ctx context.Context, service string, request *QueryRequest, result interface{}) (interface{}, error) {
+						calls = append(calls, "first response")
+						upperStrings(result, "bio")
+						return result, nil
+					},
+				},
+				ServicePluginFuncs{
+					Request: func(ctx context.Context, service string, request *QueryRequest) error {
+						calls = append(calls, "second request")
+						return nil
+					},
+					Response: func(ctx context.Context, service string, request *QueryRequest, result interface{}) (interface{}, error) {
+						calls = append(calls, "second response")
+						return result, nil
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	// Plugins rewrite copies of the query's selections, so running a query
+	// twice injects the argument twice.
+	query := graphql.MustParse(`{ greeting }`, map[string]interface{}{})
+	for i := 0; i < 2; i++ {
+		res, _, err := e.Execute(ctx, query, nil)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"greeting": "howdy"}, res)
+	}
+	assert.Equal(t, []bool{false, false}, injected)
+
+	calls = nil
+	runAndValidateQueryResults(t, ctx, e, `{
+		users { id bio }
+	}`, `{
+		"users": [
+			{"__key": 1, "id": 1, "bio": "BIO 1"},
+			{"__key": 2, "id": 2, "bio": "BIO 2"}
+		]
+	}`)
+	assert.Equal(t, []string{"first request", "second request", "second response", "first response"}, calls)
+
+	// Plugins must be configured for known services.
+	_, err = NewExecutor(ctx, execs, &CustomExecutorArgs{
+		ServicePlugins: map[string][]ServicePlugin{"unknown": nil},
+	})
+	assert.Error(t, err)
+}
+
+func TestCopySelectionSet(t *testing.T) {
+	original := &graphql.SelectionSet{
+		Selections: []*graphql.Selection{{
+			Name:         "users",
+			Alias:        "users",
+			UnparsedArgs: map[string]interface{}{"filter": map[string]interface{}{"ids": []interface{}{float64(1)}}},
+			Args:         map[string]interface{}{"filter": map[string]interface{}{"ids": []interface{}{float64(1)}}},
+			Directives:   []*graphql.Directive{{Name: "include", Args: map[string]interface{}{"if": true}}},
+		}},
+		Fragments: []*graphql.Fragment{},
+	}
+
+	copied := copySelectionSet(original)
+	assert.Equal(t, original, copied)
+
+	// Modifying nested arguments of the copy leaves the original unchanged.
+	selection := copied.Selections[0]
+	selection.UnparsedArgs["filter"].(map[string]interface{})["ids"].([]interface{})[0] = float64(2)
+	selection.Args.(map[string]interface{})["filter"].(map[string]interface{})["name"] = "bob"
+	selection.Directives[0].Args.(map[string]interface{})["if"] = false
+
+	assert.Equal(t, map[string]interface{}{"filter": map[string]interface{}{"ids": []interface{}{float64(1)}}}, original.Selections[0].UnparsedArgs)
+	assert.Equal(t, map[string]interface{}{"filter": map[string]interface{}{"ids": []interface{}{float64(1)}}}, original.Selections[0].Args)
+	assert.Equal(t, map[string]interface{}{"if": true}, original.Selections[0].Directives[0].Args)
+}
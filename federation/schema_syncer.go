@@ -2,10 +2,14 @@ package federation
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"log"
+	"time"
 
 	"github.com/samsarahq/go/oops"
-	"github.com/samsarahq/thunder/graphql/introspection"
+	"github.com/denkhaus/thunder/graphql/introspection"
 )
 
 // SchemaSyncer has a function that checks if the schema has changed,
@@ -16,6 +20,15 @@ type SchemaSyncer interface {
 type IntrospectionSchemaSyncer struct {
 	executors    map[string]ExecutorClient
 	optionalArgs interface{}
+	// namespaces rename the types of services, see
+	// CustomExecutorArgs.TypeNamespaces.
+	namespaces map[string]*TypeNamespace
+	// snapshotPath, if set, is where fetched schemas are persisted, see
+	// CustomExecutorArgs.SchemaSnapshotPath.
+	snapshotPath string
+	// pagination verifies the pagination types of services, see
+	// CustomExecutorArgs.PaginationContract.
+	pagination PaginationContract
 }
 
 // Creates a schema syncer that periodically runs an introspection query agaisnt all the federated servers to check for updates.
@@ -28,20 +41,65 @@ func NewIntrospectionSchemaSyncer(ctx context.Context, executors map[string]Exec
 }
 
 func (s *IntrospectionSchemaSyncer) FetchPlanner(ctx context.Context, optionalArgs interface{}) (*Planner, error) {
-	schemas := make(map[string]*introspectionQueryResult)
+	schemas := make(map[string]json.RawMessage)
 	for server, client := range s.executors {
 		resp, err := fetchSchema(ctx, client, optionalArgs)
-		schema := resp.Result
 		if err != nil {
 			return nil, oops.Wrapf(err, "fetching schema %s", server)
 		}
+		schemas[server] = resp.Result
+	}
+
+	planner, err := s.plannerFromSchemas(schemas)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.snapshotPath != "" {
+		snapshot := &SchemaSnapshot{
+			FetchedAt: time.Now(),
+			Services:  schemas,
+		}
+		// The fetched schemas are fine, so a failed write only leaves an
+		// older snapshot behind.
+		if err := snapshot.Write(s.snapshotPath); err != nil {
+			log.Printf("writing schema snapshot: %v", err)
+		}
+	}
+	return planner, nil
+}
+
+// plannerFromSchemas builds a planner from the introspection query results
+// of every service.
+func (s *IntrospectionSchemaSyncer) plannerFromSchemas(raw map[string]json.RawMessage) (*Planner, error) {
+	schemas := make(map[string]*introspectionQueryResult)
+	// Key fields are passed between services, so renames are checked
+	// against the keys of all services.
+	keys := make(map[string]map[string]bool)
+	for server, schema := range raw {
 		var iq introspectionQueryResult
 		if err := json.Unmarshal(schema, &iq); err != nil {
 			return nil, oops.Wrapf(err, "unmarshaling schema %s", server)
 		}
-
+		for typ, fields := range s.namespaces[server].federationKeys(&iq) {
+			if keys[typ] == nil {
+				keys[typ] = make(map[string]bool, len(fields))
+			}
+			for field := range fields {
+				keys[typ][field] = true
+			}
+		}
 		schemas[server] = &iq
 	}
+	for server, iq := range schemas {
+		if err := s.namespaces[server].renameSchema(iq, keys); err != nil {
+			return nil, oops.Wrapf(err, "renaming schema %s", server)
+		}
+	}
+
+	if err := checkPaginationContract(schemas, s.pagination); err != nil {
+		return nil, err
+	}
 
 	types, err := convertSchema(schemas)
 	if err != nil {
@@ -65,5 +123,14 @@ func (s *IntrospectionSchemaSyncer) FetchPlanner(ctx context.Context, optionalAr
 		return nil, oops.Wrapf(err, "converting schemas error")
 	}
 
-	return NewPlanner(types)
+	planner, err := NewPlanner(types)
+	if err != nil {
+		return nil, err
+	}
+	planner.schemaVersions = make(map[string]string, len(raw))
+	for server, schema := range raw {
+		hash := sha256.Sum256(schema)
+		planner.schemaVersions[server] = hex.EncodeToString(hash[:])
+	}
+	return planner, nil
 }
@@ -7,10 +7,10 @@ import (
 	"time"
 
 	"github.com/samsarahq/go/oops"
-	"github.com/samsarahq/thunder/graphql"
-	"github.com/samsarahq/thunder/graphql/introspection"
-	"github.com/samsarahq/thunder/reactive"
-	"github.com/samsarahq/thunder/thunderpb"
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/introspection"
+	"github.com/denkhaus/thunder/reactive"
+	"github.com/denkhaus/thunder/thunderpb"
 )
 
 // DirectExecutorClient is used to execute directly on any of the graphql servers
@@ -52,7 +52,12 @@ func NewServer(schema *graphql.Schema) (*Server, error) {
 }
 
 // ExecuteRequest unmarshals the protobuf query and executes it on the server
+//
+// The trace context sent by the gateway is available to resolvers through
+// TraceContextFromContext.
 func ExecuteRequest(ctx context.Context, req *thunderpb.ExecuteRequest, gqlSchema *graphql.Schema, localExecutor graphql.ExecutorRunner) (*thunderpb.ExecuteResponse, error) {
+	ctx = extractTraceMetadata(ctx)
+
 	query, err := UnmarshalQuery(req.Query)
 	if err != nil {
 		return nil, oops.Wrapf(err, "unmarshaling query")
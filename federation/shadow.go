@@ -0,0 +1,248 @@
+package federation
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"time"
+
+	"github.com/denkhaus/thunder/graphql"
+)
+
+// FieldMigration moves the ownership of a field from one service to another
+// with shadow traffic. While the field is migrating, the gateway resolves it
+// on From and returns From's result, and resolves it on To as well in the
+// background. Results that differ are reported to
+// CustomExecutorArgs.OnShadowMismatch, so To can take over the field once it
+// returns the same results.
+//
+// Both services must expose the field. Fields nested on federated objects are
+// only shadowed if To has the object federated. Mutations are never
+// shadowed.
+type FieldMigration struct {
+	// Type is the name of the object type of the field, such as "Query" or
+	// "User".
+	Type string
+	// Field is the name of the field.
+	Field string
+	// From is the service currently owning the field.
+	From string
+	// To is the service taking over the field.
+	To string
+}
+
+// ShadowMismatch describes a field that To resolved differently from From,
+// see FieldMigration.
+type ShadowMismatch struct {
+	Migration FieldMigration
+	// Key is the federated key of the object the field was resolved on, or
+	// nil for root fields.
+	Key interface{}
+	// Alias is the alias of the field in the query.
+	Alias string
+	// Expected is the result of From, which was returned to the client.
+	Expected interface{}
+	// Actual is the result of To, or nil if Err is set.
+	Actual interface{}
+	// Err is set if the shadow query to To failed.
+	Err error
+}
+
+// DefaultShadowTimeout is the timeout of shadow queries used if
+// CustomExecutorArgs.ShadowTimeout is not set.
+const DefaultShadowTimeout = 10 * time.Second
+
+// shadowSelection is a selection of a plan shadowed on another service.
+type shadowSelection struct {
+	migration FieldMigration
+	selection *graphql.Selection
+}
+
+// shadow resolves the migrating fields of p, which service resolved with
+// results res, on the services taking them over, and reports the results
+// that differ. The shadow queries run in the background; the results they are
+// compared against are copied before they are returned to the caller.
+func (e *Executor) shadow(p *Plan, service string, keys []interface{}, res []interface{}, optionalArgs interface{}, planner *Planner) {
+	if len(e.migrations) == 0 || p.Kind == mutationString {
+		return
+	}
+	migrations := e.migrations[p.Type]
+	if len(migrations) == 0 {
+		return
+	}
+
+	byService := make(map[string][]shadowSelection)
+	for _, selection := range p.SelectionSet.Selections {
+		migration, ok := migrations[selection.Name]
+		if !ok || migration.From != service {
+			continue
+		}
+		byService[migration.To] = append(byService[migration.To], shadowSelection{
+			migration: migration,
+			selection: withoutFederationKeys(selection),
+		})
+	}
+
+	for to, selections := range byService {
+		shadowed := &graphql.SelectionSet{}
+		for _, s := range selections {
+			shadowed.Selections = append(shadowed.Selections, s.selection)
+		}
+		typ := planner.schema.Schema.Query
+		if keys != nil {
+			object, ok := planner.objectType(p.Type)
+			if !ok || !isFederatedOn(object, to) {
+				continue
+			}
+			typ = object
+		}
+		if !planner.canResolve(typ, shadowed, to) {
+			continue
+		}
+
+		// Copy the expected results, which are stitched with the results of
+		// other subplans once this call returns.
+		expected := make([]map[string]interface{}, len(res))
+		for i, result := range res {
+			object, _ := result.(map[string]interface{})
+			expected[i] = make(map[string]interface{}, len(selections))
+			for _, s := range selections {
+				expected[i][s.selection.Alias] = copyShadowResult(object[s.selection.Alias])
+			}
+		}
+
+		go e.runShadow(selections, shadowed, to, p, keys, expected, optionalArgs, planner)
+	}
+}
+
+// runShadow runs a shadow query on service to and reports its mismatches.
+func (e *Executor) runShadow(selections []shadowSelection, selectionSet *graphql.SelectionSet, to string, p *Plan, keys []interface{}, expected []map[string]interface{}, optionalArgs interface{}, planner *Planner) {
+	// Shadow queries outlive the query they shadow, so they do not use its
+	// context.
+	ctx, cancel := context.WithTimeout(context.Background(), e.shadowTimeout)
+	defer cancel()
+
+	actual, _, err := e.runOnService(ctx, to, p.Type, keys, p.Kind, selectionSet, optionalArgs, planner)
+	if err == nil && len(actual) != len(expected) {
+		err = errShadowResults
+	}
+
+	for i := range expected {
+		var key interface{}
+		if keys != nil {
+			key = keys[i]
+		}
+		var object map[string]interface{}
+		if err == nil {
+			object, _ = actual[i].(map[string]interface{})
+		}
+		for _, s := range selections {
+			alias := s.selection.Alias
+			mismatch := &ShadowMismatch{
+				Migration: s.migration,
+				Key:       key,
+				Alias:     alias,
+				Expected:  expected[i][alias],
+				Err:       err,
+			}
+			if err == nil {
+				mismatch.Actual = copyShadowResult(object[alias])
+				if reflect.DeepEqual(mismatch.Expected, mismatch.Actual) {
+					continue
+				}
+			}
+			e.onShadowMismatch(mismatch)
+		}
+		if err != nil {
+			// Report failed queries once per selection, not once per key.
+			break
+		}
+	}
+}
+
+var errShadowResults = errors.New("shadow query returned a different number of results")
+
+// objectType returns the object type named name.
+func (e *Planner) objectType(name string) (*graphql.Object, bool) {
+	for field := range e.schema.Fields {
+		typ := field.Type
+		for {
+			if list, ok := typ.(*graphql.List); ok {
+				typ = list.Type
+			} else if nonNull, ok := typ.(*graphql.NonNull); ok {
+				typ = nonNull.Type
+			} else {
+				break
+			}
+		}
+		if object, ok := typ.(*graphql.Object); ok && object.Name == name {
+			return object, true
+		}
+	}
+	return nil, false
+}
+
+// withoutFederationKeys copies selection without the __federation selections
+// of nested subplans, which are specific to the service being shadowed.
+func withoutFederationKeys(selection *graphql.Selection) *graphql.Selection {
+	copied := *selection
+	if selection.SelectionSet != nil {
+		copied.SelectionSet = withoutFederationKeysSet(selection.SelectionSet)
+	}
+	return &copied
+}
+
+func withoutFederationKeysSet(selectionSet *graphql.SelectionSet) *graphql.SelectionSet {
+	copied := &graphql.SelectionSet{}
+	for _, selection := range selectionSet.Selections {
+		if selection.Name == federationField {
+			continue
+		}
+		copied.Selections = append(copied.Selections, withoutFederationKeys(selection))
+	}
+	for _, fragment := range selectionSet.Fragments {
+		f := *fragment
+		f.SelectionSet = withoutFederationKeysSet(fragment.SelectionSet)
+		copied.Fragments = append(copied.Fragments, &f)
+	}
+	return copied
+}
+
+// copyShadowResult deep copies a result without the keys of nested subplans
+// and federated objects, which depend on the service resolving the result.
+func copyShadowResult(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for k, elem := range v {
+			if k == federationField || k == keyField {
+				continue
+			}
+			copied[k] = copyShadowResult(elem)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, elem := range v {
+			copied[i] = copyShadowResult(elem)
+		}
+		return copied
+	default:
+		return v
+	}
+}
+
+// indexMigrations indexes migrations by type and field name.
+func indexMigrations(migrations []FieldMigration) map[string]map[string]FieldMigration {
+	if len(migrations) == 0 {
+		return nil
+	}
+	byType := make(map[string]map[string]FieldMigration)
+	for _, migration := range migrations {
+		if byType[migration.Type] == nil {
+			byType[migration.Type] = make(map[string]FieldMigration)
+		}
+		byType[migration.Type][migration.Field] = migration
+	}
+	return byType
+}
@@ -0,0 +1,124 @@
+package federation
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/graphql"
+)
+
+// shadowRecorder collects shadow mismatches.
+type shadowRecorder struct {
+	mu         sync.Mutex
+	mismatches []*ShadowMismatch
+}
+
+func (r *shadowRecorder) record(mismatch *ShadowMismatch) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mismatches = append(r.mismatches, mismatch)
+}
+
+func (r *shadowRecorder) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mismatches = nil
+}
+
+// wait waits for n mismatches to be reported.
+func (r *shadowRecorder) wait(t *testing.T, n int) []*ShadowMismatch {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		r.mu.Lock()
+		mismatches := append([]*ShadowMismatch(nil), r.mismatches...)
+		r.mu.Unlock()
+		if len(mismatches) >= n || time.Now().After(deadline) {
+			require.Len(t, mismatches, n)
+			return mismatches
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestFieldMigrationShadowing(t *testing.T) {
+	failover, _, s3 := makeFailoverExecutor(t)
+	execs := failover.Executors
+
+	recorder := &shadowRecorder{}
+	e, err := NewExecutor(context.Background(), execs, &CustomExecutorArgs{
+		FieldMigrations: []FieldMigration{
+			{Type: "Query", Field: "motd", From: "s2", To: "s3"},
+			// Without the migration, s2 resolves the rating of users.
+			{Type: "User", Field: "rating", From: "s3", To: "s2"},
+		},
+		OnShadowMismatch: recorder.record,
+	})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// The services migrated from resolve the fields.
+	res, _, err := e.Execute(ctx, graphql.MustParse(`{ motd users { id rating } }`, nil), nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"motd": "hello from s2",
+		"users": []interface{}{
+			map[string]interface{}{"__key": float64(1), "id": float64(1), "rating": "s3"},
+			map[string]interface{}{"__key": float64(2), "id": float64(2), "rating": "s3"},
+		},
+	}, res)
+
+	// The services migrated to resolve them in the background.
+	mismatches := recorder.wait(t, 3)
+	byAlias := make(map[string][]*ShadowMismatch)
+	for _, mismatch := range mismatches {
+		assert.NoError(t, mismatch.Err)
+		byAlias[mismatch.Alias] = append(byAlias[mismatch.Alias], mismatch)
+	}
+	require.Len(t, byAlias["motd"], 1)
+	assert.Equal(t, &ShadowMismatch{
+		Migration: FieldMigration{Type: "Query", Field: "motd", From: "s2", To: "s3"},
+		Alias:     "motd",
+		Expected:  "hello from s2",
+		Actual:    "hello from s3",
+	}, byAlias["motd"][0])
+	require.Len(t, byAlias["rating"], 2)
+	for _, mismatch := range byAlias["rating"] {
+		assert.Equal(t, "s3", mismatch.Expected)
+		assert.Equal(t, "s2", mismatch.Actual)
+		assert.NotNil(t, mismatch.Key)
+	}
+
+	// Failed shadow queries are reported, but do not fail the query.
+	recorder.reset()
+	s3.err = errors.New("s3 is down")
+	res, _, err = e.Execute(ctx, graphql.MustParse(`{ motd }`, nil), nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"motd": "hello from s2"}, res)
+	mismatches = recorder.wait(t, 1)
+	assert.Error(t, mismatches[0].Err)
+	assert.Equal(t, "hello from s2", mismatches[0].Expected)
+
+	// Mutations are not shadowed.
+	recorder.reset()
+	s3.err = nil
+	_, _, err = e.Execute(ctx, graphql.MustParse(`mutation { bump }`, nil), nil)
+	require.NoError(t, err)
+	time.Sleep(10 * time.Millisecond)
+	recorder.wait(t, 0)
+
+	_, err = NewExecutor(context.Background(), execs, &CustomExecutorArgs{
+		FieldMigrations: []FieldMigration{{Type: "Query", Field: "motd", From: "s2", To: "s3"}},
+	})
+	assert.Error(t, err)
+	_, err = NewExecutor(context.Background(), execs, &CustomExecutorArgs{
+		FieldMigrations:  []FieldMigration{{Type: "Query", Field: "motd", From: "s2", To: "s4"}},
+		OnShadowMismatch: recorder.record,
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,100 @@
+package federation
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/samsarahq/go/oops"
+)
+
+// SchemaSnapshot is a persisted copy of the schemas the gateway merged, so it
+// can start while services are unreachable, see
+// CustomExecutorArgs.SchemaSnapshotPath.
+type SchemaSnapshot struct {
+	// FetchedAt is when the schemas were fetched from the services.
+	FetchedAt time.Time `json:"fetchedAt"`
+	// Services holds the introspection query result of every service, by
+	// service name, before type namespaces are applied.
+	Services map[string]json.RawMessage `json:"services"`
+}
+
+// ReadSchemaSnapshot reads a snapshot written by SchemaSnapshot.Write.
+func ReadSchemaSnapshot(path string) (*SchemaSnapshot, error) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, oops.Wrapf(err, "reading schema snapshot")
+	}
+	var snapshot SchemaSnapshot
+	if err := json.Unmarshal(bytes, &snapshot); err != nil {
+		return nil, oops.Wrapf(err, "unmarshaling schema snapshot")
+	}
+	return &snapshot, nil
+}
+
+// Write writes the snapshot to path. The snapshot is written to a temporary
+// file first and then renamed, so readers never see a partial snapshot.
+func (s *SchemaSnapshot) Write(path string) error {
+	bytes, err := json.Marshal(s)
+	if err != nil {
+		return oops.Wrapf(err, "marshaling schema snapshot")
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return oops.Wrapf(err, "creating schema snapshot")
+	}
+	if _, err := f.Write(bytes); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return oops.Wrapf(err, "writing schema snapshot")
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return oops.Wrapf(err, "writing schema snapshot")
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return oops.Wrapf(err, "writing schema snapshot")
+	}
+	return nil
+}
+
+// services returns the sorted names of the services in the snapshot.
+func (s *SchemaSnapshot) services() []string {
+	names := make([]string, 0, len(s.Services))
+	for name := range s.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// plannerFromSnapshot builds a planner from the snapshot at the syncer's
+// snapshot path. The snapshot must hold the schemas of exactly the syncer's
+// services.
+func (s *IntrospectionSchemaSyncer) plannerFromSnapshot() (*Planner, error) {
+	snapshot, err := ReadSchemaSnapshot(s.snapshotPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range snapshot.services() {
+		if _, ok := s.executors[name]; !ok {
+			return nil, oops.Errorf("schema snapshot has unknown service %s", name)
+		}
+	}
+	for name := range s.executors {
+		if _, ok := snapshot.Services[name]; !ok {
+			return nil, oops.Errorf("schema snapshot is missing service %s", name)
+		}
+	}
+
+	planner, err := s.plannerFromSchemas(snapshot.Services)
+	if err != nil {
+		return nil, oops.Wrapf(err, "loading schema snapshot")
+	}
+	return planner, nil
+}
@@ -0,0 +1,86 @@
+package federation
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+)
+
+func TestSchemaSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schema-snapshot")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "schema.json")
+
+	s1 := schemabuilder.NewSchemaWithName("s1")
+	s1.Query().FieldFunc("motd", func() string {
+		return "hello"
+	})
+	execs, err := makeExecutors(map[string]*schemabuilder.Schema{"s1": s1})
+	require.NoError(t, err)
+	client := &throttlingClient{client: execs["s1"]}
+	execs["s1"] = client
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Without a snapshot, the executor cannot start while s1 is down.
+	client.err = errors.New("s1 is down")
+	_, err = NewExecutor(ctx, execs, &CustomExecutorArgs{SchemaSnapshotPath: path})
+	assert.Error(t, err)
+
+	// A successful start writes the snapshot.
+	client.mu.Lock()
+	client.err = nil
+	client.mu.Unlock()
+	_, err = NewExecutor(ctx, execs, &CustomExecutorArgs{SchemaSnapshotPath: path})
+	require.NoError(t, err)
+	snapshot, err := ReadSchemaSnapshot(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"s1"}, snapshot.services())
+	assert.False(t, snapshot.FetchedAt.IsZero())
+
+	// The executor starts from the snapshot while s1 is down, and serves
+	// queries once s1 comes back.
+	client.mu.Lock()
+	client.err = errors.New("s1 is down")
+	client.mu.Unlock()
+	e, err := NewExecutor(ctx, execs, &CustomExecutorArgs{
+		SchemaSnapshotPath:        path,
+		SchemaSyncIntervalSeconds: func(ctx context.Context) int64 { return 1 },
+	})
+	require.NoError(t, err)
+	client.mu.Lock()
+	client.err = nil
+	client.mu.Unlock()
+	res, _, err := e.Execute(ctx, graphql.MustParse(`{ motd }`, nil), nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"motd": "hello"}, res)
+
+	// Schemas are fetched again in the background.
+	time.Sleep(1500 * time.Millisecond)
+	refreshed, err := ReadSchemaSnapshot(path)
+	require.NoError(t, err)
+	assert.True(t, refreshed.FetchedAt.After(snapshot.FetchedAt))
+
+	// Failing to write the snapshot does not fail the schema fetch.
+	_, err = NewExecutor(ctx, execs, &CustomExecutorArgs{SchemaSnapshotPath: filepath.Join(dir, "missing", "schema.json")})
+	require.NoError(t, err)
+
+	// Snapshots of other services are rejected.
+	other := map[string]ExecutorClient{"s2": &throttlingClient{client: execs["s1"], err: errors.New("s2 is down")}}
+	_, err = NewExecutor(ctx, other, &CustomExecutorArgs{SchemaSnapshotPath: path})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "schema snapshot has unknown service s1")
+	}
+}
@@ -0,0 +1,220 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/samsarahq/go/oops"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Services can ask the gateway to back off, for example when they are
+// overloaded. The gateway then stops sending subqueries to the service for
+// the requested duration, and fails or delays subqueries according to its
+// ThrottlePolicy.
+
+const (
+	// DefaultRetryAfter is how long services are throttled if they do not
+	// say how long.
+	DefaultRetryAfter = time.Second
+	// MaxRetryAfter caps how long services are throttled.
+	MaxRetryAfter = time.Minute
+)
+
+// ThrottledError is returned by ExecutorClients when a service asks the
+// gateway to back off, for example with an HTTP 429 response. Services
+// reached over gRPC can instead return a RESOURCE_EXHAUSTED status, which
+// throttles them for DefaultRetryAfter.
+type ThrottledError struct {
+	// RetryAfter is how long the gateway should back off, or 0 for the
+	// default.
+	RetryAfter time.Duration
+	Message    string
+}
+
+func (e *ThrottledError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("throttled for %s: %s", e.RetryAfter, e.Message)
+	}
+	return fmt.Sprintf("throttled: %s", e.Message)
+}
+
+// ParseRetryAfter parses the value of a Retry-After HTTP header, either a
+// number of seconds or an HTTP date, into a ThrottledError.
+func ParseRetryAfter(value string, now time.Time) *ThrottledError {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil && seconds >= 0 {
+		return &ThrottledError{RetryAfter: time.Duration(seconds) * time.Second, Message: "retry after " + value}
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return &ThrottledError{RetryAfter: date.Sub(now), Message: "retry after " + value}
+	}
+	return &ThrottledError{Message: "retry after " + value}
+}
+
+// retryAfter returns how long a service should be throttled after err, if it
+// asked to be.
+func retryAfter(err error) (time.Duration, bool) {
+	err = oops.Cause(err)
+	var d time.Duration
+	if throttled, ok := err.(*ThrottledError); ok {
+		d = throttled.RetryAfter
+	} else if s, ok := status.FromError(err); !ok || s.Code() != codes.ResourceExhausted {
+		return 0, false
+	}
+
+	if d <= 0 {
+		d = DefaultRetryAfter
+	}
+	if d > MaxRetryAfter {
+		d = MaxRetryAfter
+	}
+	return d, true
+}
+
+// ThrottlePolicy controls subqueries to throttled services.
+type ThrottlePolicy int
+
+const (
+	// FailThrottled fails subqueries to throttled services immediately.
+	FailThrottled ThrottlePolicy = iota
+	// WaitThrottled delays subqueries to throttled services until the
+	// service is no longer throttled, or fails them if the query's context
+	// expires first.
+	WaitThrottled
+)
+
+// ServiceThrottle describes how a service has been throttled.
+type ServiceThrottle struct {
+	Service string `json:"service"`
+	// Until is when the current throttle ends, or zero if the service is not
+	// throttled.
+	Until time.Time `json:"until"`
+	// Throttles counts how often the service asked to be throttled, and
+	// Rejected how many subqueries failed because it was throttled.
+	Throttles uint64 `json:"throttles"`
+	Rejected  uint64 `json:"rejected"`
+}
+
+type throttler struct {
+	policy ThrottlePolicy
+	now    func() time.Time
+
+	mu       sync.Mutex
+	services map[string]*ServiceThrottle
+}
+
+func newThrottler(policy ThrottlePolicy) *throttler {
+	return &throttler{
+		policy:   policy,
+		now:      time.Now,
+		services: make(map[string]*ServiceThrottle),
+	}
+}
+
+func (t *throttler) service(name string) *ServiceThrottle {
+	s, ok := t.services[name]
+	if !ok {
+		s = &ServiceThrottle{Service: name}
+		t.services[name] = s
+	}
+	return s
+}
+
+// throttle backs off service for d.
+func (t *throttler) throttle(service string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.service(service)
+	s.Throttles++
+	if until := t.now().Add(d); until.After(s.Until) {
+		s.Until = until
+	}
+}
+
+// wait returns once service is not throttled, or fails according to the
+// policy.
+func (t *throttler) wait(ctx context.Context, service string) error {
+	for {
+		t.mu.Lock()
+		s, ok := t.services[service]
+		var remaining time.Duration
+		if ok {
+			remaining = s.Until.Sub(t.now())
+		}
+		if remaining <= 0 {
+			t.mu.Unlock()
+			return nil
+		}
+		if t.policy == FailThrottled {
+			s.Rejected++
+			t.mu.Unlock()
+			return oops.Errorf("service %s is throttled for %s", service, remaining)
+		}
+		t.mu.Unlock()
+
+		timer := time.NewTimer(remaining)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			t.mu.Lock()
+			s.Rejected++
+			t.mu.Unlock()
+			return oops.Wrapf(ctx.Err(), "waiting for throttled service %s", service)
+		}
+	}
+}
+
+// status returns the throttle state of all services that have been
+// throttled, sorted by service.
+func (t *throttler) status() []ServiceThrottle {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	services := make([]ServiceThrottle, 0, len(t.services))
+	for _, s := range t.services {
+		copied := *s
+		if !copied.Until.After(now) {
+			copied.Until = time.Time{}
+		}
+		services = append(services, copied)
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].Service < services[j].Service })
+	return services
+}
+
+// ThrottledServices returns the throttle state of all services that have
+// ever been throttled.
+func (e *Executor) ThrottledServices() []ServiceThrottle {
+	return e.throttler.status()
+}
+
+// ThrottleHealthHandler serves the throttle state of the services as JSON.
+// The status is "degraded" while any service is throttled, and "ok"
+// otherwise.
+func (e *Executor) ThrottleHealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		services := e.ThrottledServices()
+		state := "ok"
+		for _, s := range services {
+			if !s.Until.IsZero() {
+				state = "degraded"
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Status   string            `json:"status"`
+			Services []ServiceThrottle `json:"services"`
+		}{
+			Status:   state,
+			Services: services,
+		})
+	})
+}
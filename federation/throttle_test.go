@@ -0,0 +1,112 @@
+package federation
+
+import (
+	"context"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+)
+
+// throttlingClient fails requests with err while it is set.
+type throttlingClient struct {
+	client ExecutorClient
+
+	mu    sync.Mutex
+	err   error
+	calls int
+}
+
+func (c *throttlingClient) Execute(ctx context.Context, request *QueryRequest) (*QueryResponse, error) {
+	c.mu.Lock()
+	c.calls++
+	err := c.err
+	c.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return c.client.Execute(ctx, request)
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, 5*time.Second, ParseRetryAfter("5", now).RetryAfter)
+	assert.Equal(t, 30*time.Second, ParseRetryAfter("Wed, 01 Jan 2020 00:00:30 GMT", now).RetryAfter)
+	assert.Equal(t, time.Duration(0), ParseRetryAfter("soon", now).RetryAfter)
+
+	d, ok := retryAfter(&ThrottledError{RetryAfter: time.Hour})
+	assert.True(t, ok)
+	assert.Equal(t, MaxRetryAfter, d)
+	d, ok = retryAfter(status.Error(codes.ResourceExhausted, "slow down"))
+	assert.True(t, ok)
+	assert.Equal(t, DefaultRetryAfter, d)
+	_, ok = retryAfter(status.Error(codes.Internal, "broken"))
+	assert.False(t, ok)
+}
+
+func TestExecutorThrottling(t *testing.T) {
+	s1 := schemabuilder.NewSchemaWithName("s1")
+	s1.Query().FieldFunc("value", func() int64 { return 1 })
+	execs, err := makeExecutors(map[string]*schemabuilder.Schema{"s1": s1})
+	require.NoError(t, err)
+	client := &throttlingClient{client: execs["s1"]}
+	execs["s1"] = client
+
+	ctx := context.Background()
+	e, err := NewExecutor(ctx, execs, &CustomExecutorArgs{})
+	require.NoError(t, err)
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	e.throttler.now = func() time.Time { return now }
+	client.calls = 0
+
+	query := graphql.MustParse(`{ value }`, nil)
+
+	// The service asks the gateway to back off.
+	client.err = &ThrottledError{RetryAfter: 10 * time.Second, Message: "overloaded"}
+	_, _, err = e.Execute(ctx, query, nil)
+	assert.Error(t, err)
+	client.err = nil
+
+	// Subqueries fail without reaching the service until the throttle ends.
+	_, _, err = e.Execute(ctx, query, nil)
+	assert.Error(t, err)
+	assert.Equal(t, 1, client.calls)
+	assert.Equal(t, []ServiceThrottle{
+		{Service: "s1", Until: now.Add(10 * time.Second), Throttles: 1, Rejected: 1},
+	}, e.ThrottledServices())
+
+	rr := httptest.NewRecorder()
+	e.ThrottleHealthHandler().ServeHTTP(rr, httptest.NewRequest("GET", "/health", nil))
+	assert.JSONEq(t, `{"status": "degraded", "services": [
+		{"service": "s1", "until": "2020-01-01T00:00:10Z", "throttles": 1, "rejected": 1}
+	]}`, rr.Body.String())
+
+	now = now.Add(10 * time.Second)
+	res, _, err := e.Execute(ctx, query, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"value": float64(1)}, res)
+	assert.Equal(t, 2, client.calls)
+}
+
+func TestThrottlerWait(t *testing.T) {
+	throttler := newThrottler(WaitThrottled)
+	throttler.throttle("s1", 20*time.Millisecond)
+
+	start := time.Now()
+	require.NoError(t, throttler.wait(context.Background(), "s1"))
+	assert.True(t, time.Since(start) >= 20*time.Millisecond)
+
+	throttler.throttle("s1", time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Error(t, throttler.wait(ctx, "s1"))
+	assert.Equal(t, uint64(1), throttler.status()[0].Rejected)
+}
@@ -0,0 +1,208 @@
+package federation
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// Trace context propagation follows the W3C Trace Context and Baggage
+// specifications. The gateway forwards the trace context of every query to
+// the services it calls, as gRPC metadata or HTTP headers, starting a new
+// trace if the query did not carry one. Every subquery is a new span, so the
+// spans of all services form a single trace.
+
+const (
+	// TraceParentHeader identifies the trace and the parent span.
+	TraceParentHeader = "traceparent"
+	// TraceStateHeader carries vendor-specific trace state.
+	TraceStateHeader = "tracestate"
+	// BaggageHeader carries application-defined key-value pairs.
+	BaggageHeader = "baggage"
+)
+
+// TraceContext identifies the span of a query in a distributed trace.
+type TraceContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	// Flags holds the trace flags, such as 0x01 for sampled traces.
+	Flags byte
+	// State and Baggage are forwarded as is.
+	State   string
+	Baggage string
+}
+
+// ParseTraceParent parses a traceparent header value into a TraceContext.
+func ParseTraceParent(traceParent string) (TraceContext, error) {
+	var tc TraceContext
+	parts := strings.Split(strings.TrimSpace(traceParent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return tc, fmt.Errorf("malformed traceparent %q", traceParent)
+	}
+	// Versions after 00 may append fields, but must keep the first four.
+	if parts[0] == "00" && len(parts) != 4 {
+		return tc, fmt.Errorf("malformed traceparent %q", traceParent)
+	}
+
+	if err := decodeHex(tc.TraceID[:], parts[1]); err != nil {
+		return tc, fmt.Errorf("bad trace id in traceparent %q", traceParent)
+	}
+	if err := decodeHex(tc.SpanID[:], parts[2]); err != nil {
+		return tc, fmt.Errorf("bad span id in traceparent %q", traceParent)
+	}
+	var flags [1]byte
+	if err := decodeHex(flags[:], parts[3]); err != nil {
+		return tc, fmt.Errorf("bad flags in traceparent %q", traceParent)
+	}
+	tc.Flags = flags[0]
+
+	if tc.TraceID == ([16]byte{}) || tc.SpanID == ([8]byte{}) {
+		return tc, fmt.Errorf("invalid zero id in traceparent %q", traceParent)
+	}
+	return tc, nil
+}
+
+// decodeHex decodes s into dst, requiring s to fill dst exactly.
+func decodeHex(dst []byte, s string) error {
+	if len(s) != hex.EncodedLen(len(dst)) || strings.ToLower(s) != s {
+		return fmt.Errorf("expected %d lowercase hex digits", hex.EncodedLen(len(dst)))
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err
+}
+
+// TraceParent formats the traceparent header value of tc.
+func (tc TraceContext) TraceParent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", hex.EncodeToString(tc.TraceID[:]), hex.EncodeToString(tc.SpanID[:]), tc.Flags)
+}
+
+// child returns a new span in the same trace.
+func (tc TraceContext) child() TraceContext {
+	rand.Read(tc.SpanID[:])
+	return tc
+}
+
+// newTraceContext starts a new sampled trace.
+func newTraceContext() TraceContext {
+	var tc TraceContext
+	rand.Read(tc.TraceID[:])
+	tc.Flags = 0x01
+	return tc.child()
+}
+
+type traceContextKey struct{}
+
+// WithTraceContext returns a context holding tc.
+func WithTraceContext(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, tc)
+}
+
+// TraceContextFromContext returns the trace context of ctx. Resolvers of a
+// federated server can use it to attach their own spans to the gateway's
+// trace.
+func TraceContextFromContext(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(traceContextKey{}).(TraceContext)
+	return tc, ok
+}
+
+// traceContextFromGetter reads a trace context from headers or metadata.
+func traceContextFromGetter(get func(key string) string) (TraceContext, bool) {
+	tc, err := ParseTraceParent(get(TraceParentHeader))
+	if err != nil {
+		return TraceContext{}, false
+	}
+	tc.State = get(TraceStateHeader)
+	tc.Baggage = get(BaggageHeader)
+	return tc, true
+}
+
+// extractTraceMetadata adds the trace context of incoming gRPC metadata to
+// ctx, if any. A trace context already held by ctx, such as for servers
+// called in-process with a DirectExecutorClient, takes precedence.
+func extractTraceMetadata(ctx context.Context) context.Context {
+	if _, ok := TraceContextFromContext(ctx); ok {
+		return ctx
+	}
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+	tc, ok := traceContextFromGetter(func(key string) string {
+		return strings.Join(md.Get(key), ",")
+	})
+	if !ok {
+		return ctx
+	}
+	return WithTraceContext(ctx, tc)
+}
+
+// startTrace makes sure ctx holds a trace context, taking it from incoming
+// gRPC metadata or starting a new trace if needed.
+func startTrace(ctx context.Context) context.Context {
+	ctx = extractTraceMetadata(ctx)
+	if _, ok := TraceContextFromContext(ctx); ok {
+		return ctx
+	}
+	return WithTraceContext(ctx, newTraceContext())
+}
+
+// injectTrace starts a new span for a subquery, and adds it to ctx and the
+// outgoing gRPC metadata of ctx.
+func injectTrace(ctx context.Context) context.Context {
+	tc, ok := TraceContextFromContext(ctx)
+	if !ok {
+		tc = newTraceContext()
+	} else {
+		tc = tc.child()
+	}
+	ctx = WithTraceContext(ctx, tc)
+
+	pairs := []string{TraceParentHeader, tc.TraceParent()}
+	if tc.State != "" {
+		pairs = append(pairs, TraceStateHeader, tc.State)
+	}
+	if tc.Baggage != "" {
+		pairs = append(pairs, BaggageHeader, tc.Baggage)
+	}
+	// Replace the trace context of previous hops.
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	for i := 0; i < len(pairs); i += 2 {
+		md.Set(pairs[i], pairs[i+1])
+	}
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// InjectTraceHeaders sets the trace context headers of ctx on h. Custom
+// ExecutorClients that send queries over HTTP should call it for every
+// request.
+func InjectTraceHeaders(ctx context.Context, h http.Header) {
+	tc, ok := TraceContextFromContext(ctx)
+	if !ok {
+		return
+	}
+	h.Set(TraceParentHeader, tc.TraceParent())
+	if tc.State != "" {
+		h.Set(TraceStateHeader, tc.State)
+	}
+	if tc.Baggage != "" {
+		h.Set(BaggageHeader, tc.Baggage)
+	}
+}
+
+// TraceHandler wraps an HTTP handler, such as a GraphQL endpoint in front of
+// the gateway or a federated server reached over HTTP, and adds the trace
+// context of the request headers to the request context.
+func TraceHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if tc, ok := traceContextFromGetter(r.Header.Get); ok {
+			r = r.WithContext(WithTraceContext(r.Context(), tc))
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,134 @@
+package federation
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+	"github.com/denkhaus/thunder/thunderpb"
+)
+
+// metadataRecordingClient records the outgoing gRPC metadata of requests.
+type metadataRecordingClient struct {
+	client ExecutorClient
+
+	mu       sync.Mutex
+	metadata metadata.MD
+}
+
+func (c *metadataRecordingClient) Execute(ctx context.Context, request *QueryRequest) (*QueryResponse, error) {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	c.mu.Lock()
+	c.metadata = md
+	c.mu.Unlock()
+	return c.client.Execute(ctx, request)
+}
+
+func TestParseTraceParent(t *testing.T) {
+	tc, err := ParseTraceParent("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	require.NoError(t, err)
+	assert.Equal(t, byte(0x01), tc.Flags)
+	assert.Equal(t, "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", tc.TraceParent())
+
+	for _, bad := range []string{
+		"",
+		"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331",
+		"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01-extra",
+		"ff-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		"00-0AF7651916CD43DD8448EB211C80319C-b7ad6b7169203331-01",
+		"00-00000000000000000000000000000000-b7ad6b7169203331-01",
+		"00-0af7651916cd43dd8448eb211c80319c-0000000000000000-01",
+	} {
+		_, err := ParseTraceParent(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestExecutorTracePropagation(t *testing.T) {
+	s1 := schemabuilder.NewSchemaWithName("s1")
+	s1.Query().FieldFunc("traceParent", func(ctx context.Context) string {
+		tc, ok := TraceContextFromContext(ctx)
+		if !ok {
+			return ""
+		}
+		return tc.TraceParent() + " " + tc.Baggage
+	})
+	execs, err := makeExecutors(map[string]*schemabuilder.Schema{"s1": s1})
+	require.NoError(t, err)
+	recorder := &metadataRecordingClient{client: execs["s1"]}
+	execs["s1"] = recorder
+
+	ctx := context.Background()
+	e, err := NewExecutor(ctx, execs, &CustomExecutorArgs{})
+	require.NoError(t, err)
+
+	parent, err := ParseTraceParent("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	require.NoError(t, err)
+	parent.Baggage = "userId=1"
+
+	res, _, err := e.Execute(WithTraceContext(ctx, parent), graphql.MustParse(`{ traceParent }`, nil), nil)
+	require.NoError(t, err)
+
+	// The service sees a new span in the same trace.
+	seen := strings.SplitN(res.(map[string]interface{})["traceParent"].(string), " ", 2)
+	require.Len(t, seen, 2)
+	traceParent, baggage := seen[0], seen[1]
+	child, err := ParseTraceParent(traceParent)
+	require.NoError(t, err)
+	assert.Equal(t, parent.TraceID, child.TraceID)
+	assert.NotEqual(t, parent.SpanID, child.SpanID)
+	assert.Equal(t, "userId=1", baggage)
+
+	// The same span is sent as gRPC metadata.
+	recorder.mu.Lock()
+	md := recorder.metadata
+	recorder.mu.Unlock()
+	assert.Equal(t, []string{traceParent}, md.Get(TraceParentHeader))
+	assert.Equal(t, []string{"userId=1"}, md.Get(BaggageHeader))
+
+	// Queries without a trace context start a new trace.
+	res, _, err = e.Execute(ctx, graphql.MustParse(`{ traceParent }`, nil), nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, "", res.(map[string]interface{})["traceParent"])
+}
+
+func TestServerAcceptsTraceMetadata(t *testing.T) {
+	s1 := schemabuilder.NewSchemaWithName("s1")
+	s1.Query().FieldFunc("traceParent", func(ctx context.Context) string {
+		tc, _ := TraceContextFromContext(ctx)
+		return tc.TraceParent()
+	})
+	srv, err := NewServer(s1.MustBuild())
+	require.NoError(t, err)
+	query, err := MarshalQuery(graphql.MustParse(`{ traceParent }`, nil))
+	require.NoError(t, err)
+
+	const traceParent = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(TraceParentHeader, traceParent))
+	resp, err := srv.Execute(ctx, &thunderpb.ExecuteRequest{Query: query})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"traceParent": "`+traceParent+`"}`, string(resp.Result))
+}
+
+func TestTraceHeaders(t *testing.T) {
+	var seen TraceContext
+	handler := TraceHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = TraceContextFromContext(r.Context())
+	}))
+
+	tc := newTraceContext()
+	tc.State = "vendor=abc"
+	req := httptest.NewRequest("POST", "/graphql", nil)
+	InjectTraceHeaders(WithTraceContext(context.Background(), tc), req.Header)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, tc, seen)
+}
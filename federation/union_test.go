@@ -0,0 +1,122 @@
+package federation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+)
+
+type petKeys struct {
+	Id int64
+}
+
+// TestExecutorUnionMembersAcrossServices checks that fields of union members
+// owned by other services are fetched with one request per member type and
+// service, and merged back into the union's results.
+func TestExecutorUnionMembersAcrossServices(t *testing.T) {
+	ctx := context.Background()
+
+	type Cat struct {
+		Id int64
+	}
+	type Dog struct {
+		Id int64
+	}
+	type Pet struct {
+		schemabuilder.Union
+		*Cat
+		*Dog
+	}
+
+	pets := schemabuilder.NewSchemaWithName("pets")
+	pets.Object("Cat", Cat{}, schemabuilder.RootObject).Key("id")
+	pets.Object("Dog", Dog{}, schemabuilder.RootObject).Key("id")
+	pets.Query().FieldFunc("pets", func() []*Pet {
+		return []*Pet{
+			{Cat: &Cat{Id: 1}},
+			{Dog: &Dog{Id: 2}},
+			{Cat: &Cat{Id: 3}},
+		}
+	})
+
+	cats := schemabuilder.NewSchemaWithName("cats")
+	cats.FederatedFieldFunc("Cat", func(args struct{ Keys []petKeys }) []*Cat {
+		out := make([]*Cat, 0, len(args.Keys))
+		for _, key := range args.Keys {
+			out = append(out, &Cat{Id: key.Id})
+		}
+		return out
+	})
+	cat := cats.Object("Cat", Cat{})
+	cat.Key("id")
+	cat.FieldFunc("lives", func(c *Cat) int64 { return 9 - c.Id })
+
+	dogs := schemabuilder.NewSchemaWithName("dogs")
+	dogs.FederatedFieldFunc("Dog", func(args struct{ Keys []petKeys }) []*Dog {
+		out := make([]*Dog, 0, len(args.Keys))
+		for _, key := range args.Keys {
+			out = append(out, &Dog{Id: key.Id})
+		}
+		return out
+	})
+	dog := dogs.Object("Dog", Dog{})
+	dog.Key("id")
+	dog.FieldFunc("bark", func(d *Dog) string { return "woof" })
+
+	execs, err := makeExecutors(map[string]*schemabuilder.Schema{
+		"pets": pets,
+		"cats": cats,
+		"dogs": dogs,
+	})
+	require.NoError(t, err)
+	counting := make(map[string]ExecutorClient, len(execs))
+	for name, client := range execs {
+		counting[name] = &countingExecutorClient{ExecutorClient: client}
+	}
+	e, err := NewExecutor(ctx, counting, &CustomExecutorArgs{})
+	require.NoError(t, err)
+	for _, client := range counting {
+		client.(*countingExecutorClient).queries = nil
+	}
+
+	runAndValidateQueryResults(t, ctx, e, `{
+		pets {
+			... on Cat { id lives }
+			... on Dog { bark }
+		}
+	}`, `{
+		"pets": [
+			{"__key": 1, "__typename": "Cat", "id": 1, "lives": 8},
+			{"__key": 2, "__typename": "Dog", "bark": "woof"},
+			{"__key": 3, "__typename": "Cat", "id": 3, "lives": 6}
+		]
+	}`)
+
+	// Every member type is fetched once from its service, for all its keys.
+	assert.Equal(t, []string{"pets"}, counting["pets"].(*countingExecutorClient).queries)
+	assert.Len(t, counting["cats"].(*countingExecutorClient).queries, 1)
+	assert.Len(t, counting["dogs"].(*countingExecutorClient).queries, 1)
+
+	// Member types without fields on other services are not fetched again.
+	for _, client := range counting {
+		client.(*countingExecutorClient).queries = nil
+	}
+	runAndValidateQueryResults(t, ctx, e, `{
+		pets {
+			... on Cat { lives }
+			... on Dog { id }
+		}
+	}`, `{
+		"pets": [
+			{"__key": 1, "__typename": "Cat", "lives": 8},
+			{"__key": 2, "__typename": "Dog", "id": 2},
+			{"__key": 3, "__typename": "Cat", "lives": 6}
+		]
+	}`)
+	assert.Len(t, counting["cats"].(*countingExecutorClient).queries, 1)
+	assert.Empty(t, counting["dogs"].(*countingExecutorClient).queries)
+}
@@ -0,0 +1,173 @@
+package federation
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/denkhaus/thunder/graphql"
+)
+
+// FieldUsage is the number of sampled queries that requested a field from a
+// service.
+type FieldUsage struct {
+	Type    string `json:"type"`
+	Field   string `json:"field"`
+	Service string `json:"service"`
+	Count   int64  `json:"count"`
+}
+
+// UsageReport is a snapshot of the field usage recorded by a UsageRecorder.
+type UsageReport struct {
+	// SampleRate is the fraction of queries that were recorded. Divide counts
+	// by SampleRate to estimate the total number of requests.
+	SampleRate float64 `json:"sampleRate"`
+	// SampledQueries is the number of queries that were recorded.
+	SampledQueries int64 `json:"sampledQueries"`
+	// Fields holds the usage of every field requested at least once, sorted by
+	// type, field and service.
+	Fields []FieldUsage `json:"fields"`
+}
+
+type fieldUsageKey struct {
+	typ     string
+	field   string
+	service string
+}
+
+// UsageRecorder records which fields of the federated schema are requested
+// at the gateway, and which services they are requested from. Fields that
+// never show up in the report are candidates for deprecation and removal.
+//
+// A UsageRecorder is also an http.Handler that serves the current
+// UsageReport as JSON.
+type UsageRecorder struct {
+	sampleRate float64
+
+	mu             sync.Mutex
+	sampledQueries int64
+	counts         map[fieldUsageKey]int64
+}
+
+// NewUsageRecorder creates a UsageRecorder that records a random sampleRate
+// fraction of all queries. A sampleRate of 1 records every query.
+func NewUsageRecorder(sampleRate float64) *UsageRecorder {
+	return &UsageRecorder{
+		sampleRate: sampleRate,
+		counts:     make(map[fieldUsageKey]int64),
+	}
+}
+
+// record adds the fields requested by plan to the counts, if the query is
+// sampled.
+func (r *UsageRecorder) record(plan *Plan, planner *Planner) {
+	if r.sampleRate < 1 && rand.Float64() >= r.sampleRate {
+		return
+	}
+
+	counts := make(map[fieldUsageKey]int64)
+	collectPlanUsage(plan, planner.flattener.types, counts)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sampledQueries++
+	for key, count := range counts {
+		r.counts[key] += count
+	}
+}
+
+// collectPlanUsage counts the fields requested by plan and its subplans.
+func collectPlanUsage(plan *Plan, types map[string]graphql.Type, counts map[fieldUsageKey]int64) {
+	if typ, ok := types[plan.Type]; ok {
+		collectSelectionUsage(typ, plan.SelectionSet, plan.Service, counts)
+	}
+	for _, subPlan := range plan.After {
+		collectPlanUsage(subPlan, types, counts)
+	}
+}
+
+// collectSelectionUsage counts the fields in selectionSet on typ, which are
+// resolved by service.
+func collectSelectionUsage(typ graphql.Type, selectionSet *graphql.SelectionSet, service string, counts map[fieldUsageKey]int64) {
+	if selectionSet == nil {
+		return
+	}
+
+	switch typ := typ.(type) {
+	case *graphql.NonNull:
+		collectSelectionUsage(typ.Type, selectionSet, service, counts)
+
+	case *graphql.List:
+		collectSelectionUsage(typ.Type, selectionSet, service, counts)
+
+	case *graphql.Union:
+		for _, fragment := range selectionSet.Fragments {
+			if obj, ok := typ.Types[fragment.On]; ok {
+				collectSelectionUsage(obj, fragment.SelectionSet, service, counts)
+			}
+		}
+
+	case *graphql.Object:
+		for _, selection := range selectionSet.Selections {
+			// Skip the selections added by the planner to fetch keys.
+			if selection.Name == "__typename" || selection.Name == federationField {
+				continue
+			}
+			field, ok := typ.Fields[selection.Name]
+			if !ok {
+				continue
+			}
+			counts[fieldUsageKey{typ: typ.Name, field: selection.Name, service: service}]++
+			collectSelectionUsage(field.Type, selection.SelectionSet, service, counts)
+		}
+	}
+}
+
+// Report returns a snapshot of the recorded usage.
+func (r *UsageRecorder) Report() *UsageReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report := &UsageReport{
+		SampleRate:     r.sampleRate,
+		SampledQueries: r.sampledQueries,
+		Fields:         make([]FieldUsage, 0, len(r.counts)),
+	}
+	for key, count := range r.counts {
+		report.Fields = append(report.Fields, FieldUsage{
+			Type:    key.typ,
+			Field:   key.field,
+			Service: key.service,
+			Count:   count,
+		})
+	}
+	sort.Slice(report.Fields, func(i, j int) bool {
+		a, b := report.Fields[i], report.Fields[j]
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		if a.Field != b.Field {
+			return a.Field < b.Field
+		}
+		return a.Service < b.Service
+	})
+	return report
+}
+
+// Reset clears the recorded usage.
+func (r *UsageRecorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sampledQueries = 0
+	r.counts = make(map[fieldUsageKey]int64)
+}
+
+// ServeHTTP serves the current UsageReport as JSON.
+func (r *UsageRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(r.Report()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,67 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/graphql"
+)
+
+func TestUsageRecorder(t *testing.T) {
+	e, _, _, _, err := createExecutorWithFederatedUser()
+	require.NoError(t, err)
+	e.usage = NewUsageRecorder(1)
+
+	query := `
+		query Foo {
+			users {
+				id
+				device {
+					id
+					temp
+				}
+			}
+		}`
+	for i := 0; i < 2; i++ {
+		_, _, err := e.Execute(context.Background(), graphql.MustParse(query, map[string]interface{}{}), nil)
+		require.NoError(t, err)
+	}
+
+	expected := &UsageReport{
+		SampleRate:     1,
+		SampledQueries: 2,
+		Fields: []FieldUsage{
+			{Type: "Device", Field: "id", Service: "s1", Count: 2},
+			{Type: "Device", Field: "temp", Service: "s3", Count: 2},
+			{Type: "Query", Field: "users", Service: "s1", Count: 2},
+			{Type: "User", Field: "device", Service: "s1", Count: 2},
+			{Type: "User", Field: "id", Service: "s1", Count: 2},
+		},
+	}
+	assert.Equal(t, expected, e.usage.Report())
+
+	rr := httptest.NewRecorder()
+	e.usage.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/usage", nil))
+	var served UsageReport
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &served))
+	assert.Equal(t, expected, &served)
+
+	e.usage.Reset()
+	assert.Equal(t, &UsageReport{SampleRate: 1, Fields: []FieldUsage{}}, e.usage.Report())
+}
+
+func TestUsageRecorderSampling(t *testing.T) {
+	e, _, _, _, err := createExecutorWithFederatedUser()
+	require.NoError(t, err)
+	e.usage = NewUsageRecorder(0)
+
+	_, _, err = e.Execute(context.Background(), graphql.MustParse(`{ users { id } }`, map[string]interface{}{}), nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), e.usage.Report().SampledQueries)
+}
@@ -0,0 +1,63 @@
+package graphql
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/denkhaus/thunder/internal/sqlcomment"
+)
+
+const (
+	// OperationComment is the query comment key of GraphQL operation names,
+	// see WithQueryAttribution.
+	OperationComment = "graphql_operation"
+	// PathComment is the query comment key of GraphQL field paths, see
+	// WithQueryAttribution.
+	PathComment = "graphql_path"
+)
+
+type queryAttributionKey struct{}
+
+// WithQueryAttribution tags the SQL queries run by resolvers with the name of
+// the operation and the path of the field being resolved, such as
+// "users.posts", so slow queries can be attributed to the GraphQL operation
+// issuing them. sqlgen appends the tags to its queries as comments, see
+// sqlgen.WithQueryComment.
+func WithQueryAttribution() ExecutorOption {
+	return func(e *Executor) {
+		e.queryAttribution = true
+	}
+}
+
+// attributeQuery enables query attribution for the resolvers of query, and
+// tags ctx with its name.
+func attributeQuery(ctx context.Context, query *Query) context.Context {
+	ctx = context.WithValue(ctx, queryAttributionKey{}, true)
+	if query.Name != "" {
+		ctx = sqlcomment.With(ctx, OperationComment, query.Name)
+	}
+	return ctx
+}
+
+// attributeResolver tags ctx with the path of dest, omitting list indices, if
+// query attribution is enabled.
+func attributeResolver(ctx context.Context, dest *outputNode) context.Context {
+	if dest == nil || ctx.Value(queryAttributionKey{}) == nil {
+		return ctx
+	}
+
+	// Walk up to, but excluding, the top-level node named after the
+	// operation.
+	var names []string
+	for cur := dest.pathTracker; cur != nil && cur.parent != nil; cur = cur.parent {
+		if _, err := strconv.Atoi(cur.path); err == nil || cur.path == "" {
+			continue
+		}
+		names = append(names, cur.path)
+	}
+	for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+		names[i], names[j] = names[j], names[i]
+	}
+	return sqlcomment.With(ctx, PathComment, strings.Join(names, "."))
+}
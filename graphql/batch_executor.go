@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"reflect"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/denkhaus/thunder/reactive"
 )
@@ -22,6 +24,8 @@ type WorkUnit struct {
 	destinations []*outputNode
 	useBatch     bool
 	objectName   string
+	// keyField is the KeyField of the sources' object, if any.
+	keyField *Field
 }
 
 type nonExpensive struct{}
@@ -46,6 +50,7 @@ func splitWorkUnit(unit *WorkUnit) []*WorkUnit {
 			destinations: []*outputNode{unit.destinations[idx]},
 			useBatch:     unit.useBatch,
 			objectName:   unit.objectName,
+			keyField:     unit.keyField,
 		})
 	}
 	return workUnits
@@ -71,6 +76,7 @@ func splitToNWorkUnits(unit *WorkUnit, numUnits int) []*WorkUnit {
 			destinations: make([]*outputNode, 0, avgUnitSize),
 			useBatch:     unit.useBatch,
 			objectName:   unit.objectName,
+			keyField:     unit.keyField,
 		})
 	}
 
@@ -94,16 +100,165 @@ type WorkScheduler interface {
 	Run(resolver UnitResolver, startingUnits ...*WorkUnit)
 }
 
-func NewExecutor(scheduler WorkScheduler) ExecutorRunner {
-	return &Executor{
-		scheduler: scheduler,
+// DefaultPooledConcurrency is the default number of Pooled fields that can be
+// resolved concurrently in a single query.
+const DefaultPooledConcurrency = 4
+
+// ExecutorOption configures an Executor.
+type ExecutorOption func(*Executor)
+
+// WithPooledConcurrency limits the number of Pooled fields that can be resolved
+// concurrently in a single query. It defaults to DefaultPooledConcurrency.
+func WithPooledConcurrency(n int) ExecutorOption {
+	return func(e *Executor) {
+		e.pooledConcurrency = n
+	}
+}
+
+// WithDeterministicExecution resolves fields one at a time, in the order they
+// appear in the query, instead of using the scheduler passed to NewExecutor.
+// Resolvers run in the same order and queries fail with the same error on
+// every run, which makes golden-file tests of complex queries reproducible.
+// It is slow, so it should only be used in tests.
+func WithDeterministicExecution() ExecutorOption {
+	return func(e *Executor) {
+		e.scheduler = NewSerialScheduler()
+	}
+}
+
+func NewExecutor(scheduler WorkScheduler, opts ...ExecutorOption) ExecutorRunner {
+	e := &Executor{
+		scheduler:         scheduler,
+		pooledConcurrency: DefaultPooledConcurrency,
 	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
 }
 
 // BatchExecutor is a GraphQL executor.  Given a query it can run through the
 // execution of the request.
 type Executor struct {
-	scheduler WorkScheduler
+	scheduler         WorkScheduler
+	pooledConcurrency int
+	watchdog          *watchdog
+	fieldCache        FieldCache
+	circuitBreaker    *CircuitBreaker
+	queryAttribution  bool
+
+	parentBatching             bool
+	parentBatchingWaitInterval time.Duration
+}
+
+// executionLimits holds the concurrency limits shared by all work units of a
+// single query.
+type executionLimits struct {
+	// serialMu is held while resolving a Serial field.
+	serialMu sync.Mutex
+	// pool has a spot for every Pooled field that may be resolved concurrently.
+	pool chan struct{}
+}
+
+type executionLimitsKey struct{}
+
+// acquireFieldLimits blocks until field may be resolved according to its
+// concurrency hints and returns a function that must be called once the
+// field's resolver finishes.
+func acquireFieldLimits(ctx context.Context, field *Field) (func(), error) {
+	limits, ok := ctx.Value(executionLimitsKey{}).(*executionLimits)
+	if !ok {
+		return func() {}, nil
+	}
+
+	switch {
+	case field.Serial:
+		limits.serialMu.Lock()
+		return limits.serialMu.Unlock, nil
+	case field.Pooled && limits.pool != nil:
+		select {
+		case limits.pool <- struct{}{}:
+			return func() { <-limits.pool }, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	default:
+		return func() {}, nil
+	}
+}
+
+// executeResolver calls SafeExecuteResolver for a unit, respecting the
+// field's concurrency hints. dest is the destination of source.
+func executeResolver(ctx context.Context, unit *WorkUnit, source interface{}, dest *outputNode) (interface{}, error) {
+	ctx = attributeResolver(ctx, dest)
+	record := startExecutionEvent(ctx, unit, dest)
+	if result, ok := memoized(ctx, unit, source); ok {
+		record(ExecutionEvent{Sources: 1, Memoized: true})
+		return result, nil
+	}
+	done, open, fallback, err := breakField(ctx, unit)
+	if open {
+		record(ExecutionEvent{Sources: 1, Fallback: err == nil, Error: err})
+		return fallback, err
+	}
+	release, err := acquireFieldLimits(ctx, unit.field)
+	if err != nil {
+		done(err)
+		record(ExecutionEvent{Sources: 1, Error: err})
+		return nil, err
+	}
+	defer release()
+	defer watchResolver(ctx, unit)()
+	resolved := false
+	result, err := cachedResolve(ctx, unit, source, func() (interface{}, error) {
+		resolved = true
+		return SafeExecuteResolver(ctx, unit.field, source, unit.selection.Args, unit.selection.SelectionSet)
+	})
+	done(err)
+	record(ExecutionEvent{Sources: 1, CacheHit: !resolved, Error: err})
+	if err == nil {
+		memoize(ctx, unit, source, result)
+	}
+	return result, err
+}
+
+// executeBatchResolver calls SafeExecuteBatchResolver for a unit, respecting
+// the field's concurrency hints.
+func executeBatchResolver(ctx context.Context, unit *WorkUnit) ([]interface{}, error) {
+	var dest *outputNode
+	if len(unit.destinations) > 0 {
+		dest = unit.destinations[0]
+	}
+	ctx = attributeResolver(ctx, dest)
+	record := startExecutionEvent(ctx, unit, dest)
+	done, open, fallback, err := breakField(ctx, unit)
+	if open {
+		if err != nil {
+			record(ExecutionEvent{Sources: len(unit.sources), Batch: true, Error: err})
+			return nil, err
+		}
+		results := make([]interface{}, len(unit.sources))
+		for i := range results {
+			results[i] = fallback
+		}
+		record(ExecutionEvent{Sources: len(unit.sources), Batch: true, Fallback: true})
+		return results, nil
+	}
+	release, err := acquireFieldLimits(ctx, unit.field)
+	if err != nil {
+		done(err)
+		record(ExecutionEvent{Sources: len(unit.sources), Batch: true, Error: err})
+		return nil, err
+	}
+	defer release()
+	defer watchResolver(ctx, unit)()
+	results, ok, err := resolveParentBatch(ctx, unit)
+	if !ok {
+		results, err = SafeExecuteBatchResolver(ctx, unit.field, unit.sources, unit.selection.Args, unit.selection.SelectionSet)
+	}
+	done(err)
+	record(ExecutionEvent{Sources: len(unit.sources), Batch: true, Error: err})
+	return results, err
 }
 
 // Execute executes a query by traversing the GraphQL query graph and resolving
@@ -116,21 +271,41 @@ func (e *Executor) Execute(ctx context.Context, typ Type, source interface{}, qu
 		return nil, fmt.Errorf("expected query or mutation object for execution, got: %s", typ.String())
 	}
 
-	topLevelSelections, err := Flatten(query.SelectionSet)
+	topLevelSelections, err := includedSelections(query)
 	if err != nil {
 		return nil, err
 	}
+	// Operations whose directives exclude every field resolve nothing.
+	if len(topLevelSelections) == 0 {
+		return map[string]interface{}{}, nil
+	}
+
+	limits := &executionLimits{}
+	if e.pooledConcurrency > 0 {
+		limits.pool = make(chan struct{}, e.pooledConcurrency)
+	}
+	ctx = context.WithValue(ctx, executionLimitsKey{}, limits)
+	ctx = context.WithValue(ctx, pureMemoKey{}, &pureMemo{})
+	if e.watchdog != nil {
+		ctx = context.WithValue(ctx, watchdogKey{}, e.watchdog)
+	}
+	if e.fieldCache != nil {
+		ctx = context.WithValue(ctx, fieldCacheKey{}, e.fieldCache)
+	}
+	if e.circuitBreaker != nil {
+		ctx = context.WithValue(ctx, circuitBreakerKey{}, e.circuitBreaker)
+	}
+	if e.parentBatching {
+		ctx = withParentBatching(ctx, e.parentBatchingWaitInterval)
+	}
+	if e.queryAttribution {
+		ctx = attributeQuery(ctx, query)
+	}
+
 	topLevelRespWriter := newTopLevelOutputNode(query.Name)
 	initialSelectionWorkUnits := make([]*WorkUnit, 0, len(topLevelSelections))
 	writers := make(map[string]*outputNode)
 	for _, selection := range topLevelSelections {
-		ok, err := shouldIncludeNode(selection.Directives)
-		if err != nil {
-			return nil, err
-		}
-		if !ok {
-			continue
-		}
 		field, ok := queryObject.Fields[selection.Name]
 		if !ok {
 			return nil, fmt.Errorf("invalid top-level selection %q", selection.Name)
@@ -168,7 +343,9 @@ func executeWorkUnit(unit *WorkUnit) []*WorkUnit {
 		return executeBatchWorkUnit(unit)
 	}
 
-	if !unit.field.Expensive {
+	// Throttled fields are cached like expensive fields, so that their
+	// invalidations can be coalesced.
+	if !unit.field.Expensive && findDirectiveWithName(unit.selection.Directives, THROTTLE) == nil {
 		return executeNonExpensiveWorkUnit(unit)
 	}
 
@@ -180,7 +357,7 @@ func executeWorkUnit(unit *WorkUnit) []*WorkUnit {
 }
 
 func executeBatchWorkUnit(unit *WorkUnit) []*WorkUnit {
-	results, err := SafeExecuteBatchResolver(unit.Ctx, unit.field, unit.sources, unit.selection.Args, unit.selection.SelectionSet)
+	results, err := executeBatchResolver(unit.Ctx, unit)
 	if err != nil {
 		for _, dest := range unit.destinations {
 			dest.Fail(err)
@@ -207,7 +384,7 @@ func executeNonExpensiveWorkUnit(unit *WorkUnit) []*WorkUnit {
 		if unit.objectName != "Mutation" {
 			ctx = context.WithValue(unit.Ctx, nonExpensive{}, struct{}{})
 		}
-		fieldResult, err := SafeExecuteResolver(ctx, unit.field, src, unit.selection.Args, unit.selection.SelectionSet)
+		fieldResult, err := executeResolver(ctx, unit, src, unit.destinations[idx])
 		if err != nil {
 			// Fail the unit and exit.
 			unit.destinations[idx].Fail(err)
@@ -232,12 +409,26 @@ func executeNonExpensiveWorkUnit(unit *WorkUnit) []*WorkUnit {
 // - We assume that there is no "error-catching" mechanism that will stop an
 //   error from propagating all the way to the top of the request stack.
 func executeNonBatchWorkUnitWithCaching(src interface{}, dest *outputNode, unit *WorkUnit) []*WorkUnit {
+	interval, err := throttleInterval(unit.selection.Directives)
+	if err != nil {
+		dest.Fail(err)
+		return nil
+	}
+
 	var workUnits []*WorkUnit
-	subDestRes, err := reactive.Cache(unit.Ctx, getWorkCacheKey(src, unit.field, unit.selection), func(ctx context.Context) (interface{}, error) {
+	compute := func(ctx context.Context) (interface{}, error) {
 		subDest := newOutputNode(dest, "")
 		workUnits = executeNonBatchWorkUnit(ctx, src, subDest, unit)
 		return subDest.res, nil
-	})
+	}
+	var subDestRes interface{}
+	key := getWorkCacheKey(src, unit.field, unit.selection)
+	if interval > 0 {
+		// Coalesce the invalidations of @throttle fields.
+		subDestRes, err = reactive.CacheThrottled(unit.Ctx, key, interval, compute)
+	} else {
+		subDestRes, err = reactive.Cache(unit.Ctx, key, compute)
+	}
 	if err != nil {
 		dest.Fail(err)
 	}
@@ -262,7 +453,7 @@ func getWorkCacheKey(src interface{}, field *Field, selection *Selection) resolv
 
 // executeNonBatchWorkUnit resolves a non-batch field in our graphql response graph.
 func executeNonBatchWorkUnit(ctx context.Context, src interface{}, dest *outputNode, unit *WorkUnit) []*WorkUnit {
-	fieldResult, err := SafeExecuteResolver(ctx, unit.field, src, unit.selection.Args, unit.selection.SelectionSet)
+	fieldResult, err := executeResolver(ctx, unit, src, dest)
 	if err != nil {
 		dest.Fail(err)
 		return nil
@@ -367,6 +558,9 @@ func resolveListBatch(ctx context.Context, sources []interface{}, typ *List, sel
 func resolveUnionBatch(ctx context.Context, sources []interface{}, typ *Union, selectionSet *SelectionSet, destinations []*outputNode) ([]*WorkUnit, error) {
 	sourcesByType := make(map[string][]interface{}, len(typ.Types))
 	destinationsByType := make(map[string][]*outputNode, len(typ.Types))
+	// srcTypes holds the types in order of appearance, so work units are
+	// created in a stable order.
+	var srcTypes []string
 	for idx, src := range sources {
 		union := reflect.ValueOf(src)
 		if !union.IsValid() || (union.Kind() == reflect.Ptr && union.IsNil()) {
@@ -388,13 +582,17 @@ func resolveUnionBatch(ctx context.Context, sources []interface{}, typ *Union, s
 				return nil, fmt.Errorf("union type field should only return one value, but received: %s %s", srcType, typString)
 			}
 			srcType = typString
+			if _, ok := sourcesByType[srcType]; !ok {
+				srcTypes = append(srcTypes, srcType)
+			}
 			sourcesByType[srcType] = append(sourcesByType[srcType], inner.Interface())
 			destinationsByType[srcType] = append(destinationsByType[srcType], destinations[idx])
 		}
 	}
 
 	var workUnits []*WorkUnit
-	for srcType, sources := range sourcesByType {
+	for _, srcType := range srcTypes {
+		sources := sourcesByType[srcType]
 		gqlType := typ.Types[srcType]
 		for _, fragment := range selectionSet.Fragments {
 			if fragment.On != srcType {
@@ -429,7 +627,7 @@ func resolveObjectBatch(ctx context.Context, sources []interface{}, typ *Object,
 			numNonExpensive++
 			continue
 		}
-		if field.Expensive {
+		if field.Expensive || (field.Pooled && !field.Serial) {
 			numExpensive++
 			continue
 		}
@@ -490,6 +688,7 @@ func resolveObjectBatch(ctx context.Context, sources []interface{}, typ *Object,
 			destinations: destForSelection,
 			selection:    selection,
 			objectName:   typ.Name,
+			keyField:     typ.KeyField,
 		}
 
 		switch {
@@ -500,7 +699,11 @@ func resolveObjectBatch(ctx context.Context, sources []interface{}, typ *Object,
 			} else {
 				workUnits = append(workUnits, unit)
 			}
-		case field.Expensive:
+		case field.Serial:
+			// Serial fields are resolved one at a time, so there is no point in
+			// splitting them into multiple units.
+			workUnits = append(workUnits, unit)
+		case field.Expensive, field.Pooled:
 			// Expensive fields should be executed as multiple "Units".  The scheduler
 			// controls how the units are executed
 			workUnits = append(workUnits, splitWorkUnit(unit)...)
@@ -6,11 +6,14 @@ import (
 	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/denkhaus/thunder/batch"
 	"github.com/denkhaus/thunder/graphql"
 	"github.com/denkhaus/thunder/graphql/schemabuilder"
 	"github.com/denkhaus/thunder/internal"
+	"github.com/denkhaus/thunder/internal/sqlcomment"
+	"github.com/denkhaus/thunder/sqlgen"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -442,3 +445,289 @@ func (q *counterGoroutineScheduler) runEnqueue(resolver graphql.UnitResolver, un
 		}(unit)
 	}
 }
+
+// concurrencyTracker records the maximum number of concurrent calls to run.
+type concurrencyTracker struct {
+	current int64
+	max     int64
+}
+
+func (c *concurrencyTracker) run() {
+	current := atomic.AddInt64(&c.current, 1)
+	for {
+		max := atomic.LoadInt64(&c.max)
+		if current <= max || atomic.CompareAndSwapInt64(&c.max, max, current) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	atomic.AddInt64(&c.current, -1)
+}
+
+func TestConcurrencyHints(t *testing.T) {
+	type Object struct {
+		Key string
+	}
+
+	tests := []struct {
+		name    string
+		option  schemabuilder.FieldFuncOption
+		opts    []graphql.ExecutorOption
+		wantMax int64 // 0 means unbounded
+	}{
+		{
+			name:    "serial",
+			option:  schemabuilder.Serial,
+			wantMax: 1,
+		},
+		{
+			name:    "pooled",
+			option:  schemabuilder.Pooled,
+			opts:    []graphql.ExecutorOption{graphql.WithPooledConcurrency(2)},
+			wantMax: 2,
+		},
+		{
+			name:   "expensive",
+			option: schemabuilder.Expensive,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := &concurrencyTracker{}
+
+			builder := schemabuilder.NewSchema()
+			builder.Query().FieldFunc("objects", func(ctx context.Context) []*Object {
+				objects := make([]*Object, 0, 8)
+				for i := 0; i < 8; i++ {
+					objects = append(objects, &Object{Key: "key"})
+				}
+				return objects
+			})
+			obj := builder.Object("Object", Object{})
+			obj.FieldFunc("a", func(object *Object) string {
+				tracker.run()
+				return object.Key
+			}, tt.option)
+			obj.FieldFunc("b", func(object *Object) string {
+				tracker.run()
+				return object.Key
+			}, tt.option)
+			schema, err := builder.Build()
+			require.NoError(t, err)
+
+			q := graphql.MustParse(`{ objects { a b } }`, nil)
+			require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+
+			e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), tt.opts...)
+			_, err = e.Execute(context.Background(), schema.Query, nil, q)
+			require.NoError(t, err)
+
+			if tt.wantMax == 0 {
+				assert.True(t, tracker.max > 2, "expected unbounded concurrency, got %d", tracker.max)
+			} else {
+				assert.Equal(t, tt.wantMax, tracker.max)
+			}
+		})
+	}
+}
+
+func slowWatchdogResolver(unblock chan struct{}) string {
+	<-unblock
+	return "done"
+}
+
+func TestSlowResolverWatchdog(t *testing.T) {
+	unblock := make(chan struct{})
+
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("fast", func() string {
+		return "done"
+	})
+	builder.Query().FieldFunc("slow", func() string {
+		return slowWatchdogResolver(unblock)
+	})
+	schema, err := builder.Build()
+	require.NoError(t, err)
+
+	reported := make(chan *graphql.SlowResolver, 2)
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithSlowResolverWatchdog(
+		10*time.Millisecond,
+		func(ctx context.Context, slow *graphql.SlowResolver) {
+			reported <- slow
+		},
+		graphql.WithGoroutineDump(),
+	))
+
+	q := graphql.MustParse(`{ fast slow }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+
+	done := make(chan error)
+	go func() {
+		_, err := e.Execute(context.Background(), schema.Query, nil, q)
+		done <- err
+	}()
+
+	// The slow resolver is reported while it is still blocked.
+	slow := <-reported
+	assert.Equal(t, "Query", slow.Type)
+	assert.Equal(t, "slow", slow.Field)
+	assert.Equal(t, 10*time.Millisecond, slow.Threshold)
+	assert.Contains(t, string(slow.Stack), "slowWatchdogResolver")
+
+	close(unblock)
+	require.NoError(t, <-done)
+	assert.Len(t, reported, 0)
+}
+
+func TestDeterministicExecution(t *testing.T) {
+	type Object struct {
+		Key string
+	}
+
+	var mu sync.Mutex
+	var calls []string
+	record := func(call string) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, call)
+	}
+
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("objects", func() []*Object {
+		return []*Object{{Key: "x"}, {Key: "y"}, {Key: "z"}}
+	})
+	obj := builder.Object("Object", Object{})
+	obj.FieldFunc("a", func(object *Object) string {
+		record("a" + object.Key)
+		return object.Key
+	}, schemabuilder.Expensive)
+	obj.FieldFunc("b", func(object *Object) string {
+		record("b" + object.Key)
+		return object.Key
+	})
+	obj.FieldFunc("fail", func(object *Object) (string, error) {
+		return "", errors.New("failed " + object.Key)
+	}, schemabuilder.Expensive)
+	schema, err := builder.Build()
+	require.NoError(t, err)
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithDeterministicExecution())
+	execute := func(query string) error {
+		q := graphql.MustParse(query, nil)
+		require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+		_, err := e.Execute(context.Background(), schema.Query, nil, q)
+		return err
+	}
+
+	for i := 0; i < 20; i++ {
+		calls = nil
+		require.NoError(t, execute(`{ objects { a b } }`))
+		assert.Equal(t, []string{"ax", "ay", "az", "bx", "by", "bz"}, calls)
+
+		err := execute(`{ objects { fail } }`)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "failed x")
+		}
+	}
+}
+
+func TestParentBatching(t *testing.T) {
+	type Object struct {
+		Key string
+	}
+	type Profile struct {
+		Key string
+	}
+
+	var mu sync.Mutex
+	var batches [][]string
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("objects", func() []*Object {
+		return []*Object{{Key: "x"}, {Key: "y"}, {Key: "z"}}
+	})
+	obj := builder.Object("Object", Object{})
+	// Expensive fields are resolved in a work unit per object.
+	obj.FieldFunc("profile", func(object *Object) *Profile {
+		return &Profile{Key: object.Key}
+	}, schemabuilder.Expensive)
+	profile := builder.Object("Profile", Profile{})
+	profile.BatchFieldFunc("name", func(profiles map[batch.Index]*Profile) map[batch.Index]string {
+		var keys []string
+		names := make(map[batch.Index]string, len(profiles))
+		for idx, p := range profiles {
+			keys = append(keys, p.Key)
+			names[idx] = "name " + p.Key
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		batches = append(batches, keys)
+		return names
+	})
+	schema, err := builder.Build()
+	require.NoError(t, err)
+
+	execute := func(e graphql.ExecutorRunner) interface{} {
+		q := graphql.MustParse(`{ objects { profile { name } } }`, nil)
+		require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+		res, err := e.Execute(context.Background(), schema.Query, nil, q)
+		require.NoError(t, err)
+		return res
+	}
+	expected := map[string]interface{}{
+		"objects": []interface{}{
+			map[string]interface{}{"profile": map[string]interface{}{"name": "name x"}},
+			map[string]interface{}{"profile": map[string]interface{}{"name": "name y"}},
+			map[string]interface{}{"profile": map[string]interface{}{"name": "name z"}},
+		},
+	}
+
+	assert.Equal(t, expected, internal.AsJSON(execute(graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler()))))
+	assert.Len(t, batches, 3)
+
+	batches = nil
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithParentBatching(10*time.Millisecond))
+	assert.Equal(t, expected, internal.AsJSON(execute(e)))
+	if assert.Len(t, batches, 1) {
+		assert.ElementsMatch(t, []string{"x", "y", "z"}, batches[0])
+	}
+}
+
+func TestQueryAttribution(t *testing.T) {
+	type Object struct {
+		Key string
+	}
+
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("objects", func(ctx context.Context) []*Object {
+		return []*Object{{Key: sqlcomment.Append(ctx, "SELECT objects")}}
+	})
+	obj := builder.Object("Object", Object{})
+	obj.FieldFunc("query", func(ctx context.Context, object *Object) string {
+		return sqlcomment.Append(ctx, "SELECT query")
+	})
+	schema, err := builder.Build()
+	require.NoError(t, err)
+
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithQueryAttribution())
+	q := graphql.MustParse(`query Objects { objects { key renamed: query } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+
+	ctx := sqlgen.WithQueryComment(context.Background(), sqlgen.RequestIDComment, "r'1")
+	result, err := e.Execute(ctx, schema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"objects": []interface{}{
+			map[string]interface{}{
+				"key":     "SELECT objects /*graphql_operation='Objects',graphql_path='objects',request_id='r%271'*/",
+				"renamed": "SELECT query /*graphql_operation='Objects',graphql_path='objects.renamed',request_id='r%271'*/",
+			},
+		},
+	}, internal.AsJSON(result))
+
+	// Without the option, only explicit tags are added.
+	e = graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	result, err = e.Execute(ctx, schema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT objects /*request_id='r%271'*/", internal.AsJSON(result).(map[string]interface{})["objects"].([]interface{})[0].(map[string]interface{})["key"])
+}
@@ -33,3 +33,21 @@ func (r *immediateGoroutineSchedulerRunner) runEnqueue(resolver UnitResolver, un
 		}(unit)
 	}
 }
+
+// NewSerialScheduler creates a new batch execution scheduler that executes
+// all Units one at a time in the calling goroutine, in the order they are
+// created.
+func NewSerialScheduler() WorkScheduler {
+	return &serialScheduler{}
+}
+
+type serialScheduler struct{}
+
+func (q *serialScheduler) Run(resolver UnitResolver, initialUnits ...*WorkUnit) {
+	queue := append([]*WorkUnit(nil), initialUnits...)
+	for len(queue) > 0 {
+		unit := queue[0]
+		queue = queue[1:]
+		queue = append(queue, resolver(unit)...)
+	}
+}
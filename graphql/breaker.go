@@ -0,0 +1,266 @@
+package graphql
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of the circuit breaker of a field.
+type BreakerState int
+
+const (
+	// BreakerClosed resolves the field as usual.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen short-circuits the field without calling its resolver.
+	BreakerOpen
+	// BreakerHalfOpen lets a single trial call through after the cooldown.
+	// The breaker closes if it succeeds, and opens again if it fails.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreakerPolicy configures a CircuitBreaker.
+type CircuitBreakerPolicy struct {
+	// Window is the period over which the error rate of a field is measured.
+	// Counts are reset at the start of every window.
+	Window time.Duration
+	// MinCalls is the number of calls in a window before the breaker of a
+	// field can open, so that a few errors on a rarely used field do not
+	// open it.
+	MinCalls int
+	// ErrorRate is the fraction of failed calls, between 0 and 1, at which
+	// the breaker of a field opens.
+	ErrorRate float64
+	// Cooldown is how long the breaker of a field stays open before it lets
+	// a trial call through.
+	Cooldown time.Duration
+
+	// Fallback, if set, returns the result of short-circuited calls, which
+	// must have the type returned by the field's resolver. Without it,
+	// short-circuited fields fail with a "circuit open" error.
+	Fallback func(ctx context.Context, typ, field string) interface{}
+	// OnShortCircuit, if set, is called for every call short-circuited by an
+	// open breaker, so it can be logged as a warning.
+	OnShortCircuit func(ctx context.Context, typ, field string)
+	// OnStateChange, if set, is called whenever the breaker of a field
+	// changes state.
+	OnStateChange func(typ, field string, from, to BreakerState)
+}
+
+// CircuitBreaker tracks the error rate of every field, and short-circuits
+// fields that fail too often for a cooldown period, to protect the systems
+// behind them, see WithCircuitBreaker.
+type CircuitBreaker struct {
+	policy CircuitBreakerPolicy
+	now    func() time.Time
+
+	mu     sync.Mutex
+	fields map[breakerKey]*fieldBreaker
+}
+
+type breakerKey struct {
+	typ, field string
+}
+
+// fieldBreaker is the breaker of a single field.
+type fieldBreaker struct {
+	state BreakerState
+
+	windowStart time.Time
+	calls       int
+	failures    int
+
+	openedAt time.Time
+	// trial is set while the trial call of a half-open breaker runs.
+	trial bool
+}
+
+// NewCircuitBreaker creates a CircuitBreaker with policy.
+func NewCircuitBreaker(policy CircuitBreakerPolicy) *CircuitBreaker {
+	return &CircuitBreaker{
+		policy: policy,
+		now:    time.Now,
+		fields: make(map[breakerKey]*fieldBreaker),
+	}
+}
+
+// WithCircuitBreaker short-circuits fields that fail too often according to
+// the policy of b. Short-circuited fields resolve to their fallback without
+// calling their resolver.
+//
+// A batch resolver call counts as a single call. Client errors and calls
+// whose context is done do not count as failures. Mutation fields are never
+// short-circuited.
+func WithCircuitBreaker(b *CircuitBreaker) ExecutorOption {
+	return func(e *Executor) {
+		e.circuitBreaker = b
+	}
+}
+
+type circuitBreakerKey struct{}
+
+// State returns the state of the breaker of field on the object type typ.
+func (b *CircuitBreaker) State(typ, field string) BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if fb, ok := b.fields[breakerKey{typ: typ, field: field}]; ok {
+		return fb.state
+	}
+	return BreakerClosed
+}
+
+// allow reports whether a call of the field key may run, and returns the
+// state the call was allowed in.
+func (b *CircuitBreaker) allow(key breakerKey) (bool, BreakerState) {
+	b.mu.Lock()
+	fb, ok := b.fields[key]
+	if !ok {
+		fb = &fieldBreaker{windowStart: b.now()}
+		b.fields[key] = fb
+	}
+
+	switch fb.state {
+	case BreakerOpen:
+		if b.now().Sub(fb.openedAt) < b.policy.Cooldown {
+			b.mu.Unlock()
+			return false, BreakerOpen
+		}
+		fb.state = BreakerHalfOpen
+		fb.trial = true
+		b.mu.Unlock()
+		b.changed(key, BreakerOpen, BreakerHalfOpen)
+		return true, BreakerHalfOpen
+	case BreakerHalfOpen:
+		if fb.trial {
+			b.mu.Unlock()
+			return false, BreakerHalfOpen
+		}
+		fb.trial = true
+		b.mu.Unlock()
+		return true, BreakerHalfOpen
+	default:
+		b.mu.Unlock()
+		return true, BreakerClosed
+	}
+}
+
+// record records the outcome of a call of the field key allowed in state.
+func (b *CircuitBreaker) record(key breakerKey, state BreakerState, failed bool) {
+	b.mu.Lock()
+	fb := b.fields[key]
+	if fb.state != state {
+		// The breaker changed state while the call ran.
+		b.mu.Unlock()
+		return
+	}
+
+	now := b.now()
+	from := fb.state
+	switch fb.state {
+	case BreakerHalfOpen:
+		fb.trial = false
+		if failed {
+			fb.state = BreakerOpen
+			fb.openedAt = now
+		} else {
+			fb.state = BreakerClosed
+			fb.windowStart, fb.calls, fb.failures = now, 0, 0
+		}
+	case BreakerClosed:
+		if now.Sub(fb.windowStart) >= b.policy.Window {
+			fb.windowStart, fb.calls, fb.failures = now, 0, 0
+		}
+		fb.calls++
+		if failed {
+			fb.failures++
+		}
+		if fb.calls >= b.policy.MinCalls && float64(fb.failures) >= b.policy.ErrorRate*float64(fb.calls) && fb.failures > 0 {
+			fb.state = BreakerOpen
+			fb.openedAt = now
+		}
+	}
+	to := fb.state
+	b.mu.Unlock()
+
+	if from != to {
+		b.changed(key, from, to)
+	}
+}
+
+func (b *CircuitBreaker) changed(key breakerKey, from, to BreakerState) {
+	if b.policy.OnStateChange != nil {
+		b.policy.OnStateChange(key.typ, key.field, from, to)
+	}
+}
+
+// shortCircuit returns the result of a short-circuited call: the fallback if
+// there is one, and an error otherwise, so that the field is never silently
+// null.
+func (b *CircuitBreaker) shortCircuit(ctx context.Context, key breakerKey) (interface{}, error) {
+	if b.policy.OnShortCircuit != nil {
+		b.policy.OnShortCircuit(ctx, key.typ, key.field)
+	}
+	if b.policy.Fallback != nil {
+		return b.policy.Fallback(ctx, key.typ, key.field), nil
+	}
+	return nil, NewSafeError("circuit open")
+}
+
+// abandon forgets a call of the field key allowed in state whose outcome
+// does not count, so that a half-open breaker lets another trial call through.
+func (b *CircuitBreaker) abandon(key breakerKey, state BreakerState) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if fb := b.fields[key]; state == BreakerHalfOpen && fb.state == BreakerHalfOpen {
+		fb.trial = false
+	}
+}
+
+// isBreakerFailure reports whether err counts as a failure of the field.
+func isBreakerFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	if _, ok := ErrorCause(err).(ClientError); ok {
+		return false
+	}
+	return true
+}
+
+// breakField checks the breaker of unit's field. If it is open, breakField
+// returns true and the result of the short-circuited call. Otherwise, it
+// returns a function that must be called with the error of the field's
+// resolver.
+func breakField(ctx context.Context, unit *WorkUnit) (func(error), bool, interface{}, error) {
+	b, ok := ctx.Value(circuitBreakerKey{}).(*CircuitBreaker)
+	if !ok || unit.objectName == "Mutation" {
+		return func(error) {}, false, nil, nil
+	}
+
+	key := breakerKey{typ: unit.objectName, field: unit.selection.Name}
+	allowed, state := b.allow(key)
+	if !allowed {
+		fallback, err := b.shortCircuit(ctx, key)
+		return nil, true, fallback, err
+	}
+	return func(err error) {
+		if ctx.Err() != nil {
+			b.abandon(key, state)
+			return
+		}
+		b.record(key, state, isBreakerFailure(err))
+	}, false, nil, nil
+}
@@ -0,0 +1,130 @@
+package graphql_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var mu sync.Mutex
+	var calls int
+	failing := true
+	var changes, shortCircuits []string
+
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("flaky", func() (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if failing {
+			return "", errors.New("downstream is down")
+		}
+		return "ok", nil
+	})
+	builder.Query().FieldFunc("validated", func() (string, error) {
+		return "", graphql.NewClientError("bad input")
+	})
+	schema := builder.MustBuild()
+
+	breaker := graphql.NewCircuitBreaker(graphql.CircuitBreakerPolicy{
+		Window:    time.Minute,
+		MinCalls:  2,
+		ErrorRate: 0.5,
+		Cooldown:  50 * time.Millisecond,
+		Fallback: func(ctx context.Context, typ, field string) interface{} {
+			return "fallback"
+		},
+		OnShortCircuit: func(ctx context.Context, typ, field string) {
+			shortCircuits = append(shortCircuits, typ+"."+field)
+		},
+		OnStateChange: func(typ, field string, from, to graphql.BreakerState) {
+			changes = append(changes, typ+"."+field+": "+from.String()+" -> "+to.String())
+		},
+	})
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithCircuitBreaker(breaker))
+
+	execute := func(query string) (interface{}, error) {
+		q := graphql.MustParse(query, nil)
+		require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+		return e.Execute(context.Background(), schema.Query, nil, q)
+	}
+
+	// Client errors do not open the breaker.
+	for i := 0; i < 3; i++ {
+		_, err := execute(`{ validated }`)
+		assert.Error(t, err)
+	}
+	assert.Equal(t, graphql.BreakerClosed, breaker.State("Query", "validated"))
+
+	// Failures open the breaker once there are enough calls.
+	_, err := execute(`{ flaky }`)
+	assert.Error(t, err)
+	assert.Equal(t, graphql.BreakerClosed, breaker.State("Query", "flaky"))
+	_, err = execute(`{ flaky }`)
+	assert.Error(t, err)
+	assert.Equal(t, graphql.BreakerOpen, breaker.State("Query", "flaky"))
+
+	// An open breaker short-circuits the field to its fallback.
+	res, err := execute(`{ flaky }`)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"flaky": "fallback"}, res)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, []string{"Query.flaky"}, shortCircuits)
+
+	// After the cooldown, a successful trial call closes the breaker.
+	time.Sleep(60 * time.Millisecond)
+	mu.Lock()
+	failing = false
+	mu.Unlock()
+	res, err = execute(`{ flaky }`)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"flaky": "ok"}, res)
+	assert.Equal(t, graphql.BreakerClosed, breaker.State("Query", "flaky"))
+	assert.Equal(t, []string{
+		"Query.flaky: closed -> open",
+		"Query.flaky: open -> half-open",
+		"Query.flaky: half-open -> closed",
+	}, changes)
+}
+
+func TestCircuitBreakerWithoutFallback(t *testing.T) {
+	var calls int
+	builder := schemabuilder.NewSchema()
+	// flaky returns a non-null string, which must not resolve to null when
+	// short-circuited.
+	builder.Query().FieldFunc("flaky", func() (string, error) {
+		calls++
+		return "", errors.New("downstream is down")
+	})
+	schema := builder.MustBuild()
+
+	breaker := graphql.NewCircuitBreaker(graphql.CircuitBreakerPolicy{
+		Window:    time.Minute,
+		MinCalls:  1,
+		ErrorRate: 0.5,
+		Cooldown:  time.Minute,
+	})
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithCircuitBreaker(breaker))
+
+	q := graphql.MustParse(`{ flaky }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+	_, err := e.Execute(context.Background(), schema.Query, nil, q)
+	assert.EqualError(t, err, "flaky: downstream is down")
+	assert.Equal(t, graphql.BreakerOpen, breaker.State("Query", "flaky"))
+
+	// An open breaker without a fallback fails the field.
+	res, err := e.Execute(context.Background(), schema.Query, nil, q)
+	assert.Nil(t, res)
+	assert.EqualError(t, err, "circuit open")
+	assert.Equal(t, "circuit open", graphql.SanitizeError(err))
+	assert.Equal(t, 1, calls)
+}
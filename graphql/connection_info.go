@@ -0,0 +1,62 @@
+package graphql
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// ConnectionInfo describes the websocket connection an execution runs on, so
+// that resolvers can key caches, rate limits, and logs by connection.
+type ConnectionInfo struct {
+	// ID identifies the connection. It is random, so it is unique across
+	// servers.
+	ID string
+	// ConnectedAt is when the connection was created.
+	ConnectedAt time.Time
+	// Protocol is the websocket subprotocol negotiated with the client, if
+	// any.
+	Protocol string
+	// Auth is the Payload of the current credentials of the connection, see
+	// WithCredentials and WithAuthenticate, or nil if it has none.
+	Auth interface{}
+}
+
+type connectionInfoKey struct{}
+
+// subprotocolSocket is implemented by sockets that negotiate a subprotocol,
+// such as *websocket.Conn.
+type subprotocolSocket interface {
+	Subprotocol() string
+}
+
+// newConnectionID returns a random connection ID.
+func newConnectionID() string {
+	var id [8]byte
+	rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
+
+// ConnectionInfoFromContext returns the info of the connection ctx belongs
+// to. It is available to resolvers, hooks, and loggers of connections created
+// with CreateConnection, and reflects the credentials of the connection at
+// the time of the call.
+func ConnectionInfoFromContext(ctx context.Context) (ConnectionInfo, bool) {
+	c, ok := ctx.Value(connectionInfoKey{}).(*conn)
+	if !ok {
+		return ConnectionInfo{}, false
+	}
+	return c.connectionInfo(), true
+}
+
+// connectionInfo returns the info of c with its current credentials.
+func (c *conn) connectionInfo() ConnectionInfo {
+	info := c.info
+	c.credentialsMu.Lock()
+	if c.credentials != nil {
+		info.Auth = c.credentials.Payload
+	}
+	c.credentialsMu.Unlock()
+	return info
+}
@@ -1552,6 +1552,118 @@ func TestPaginatedSorts(t *testing.T) {
 	}
 }
 
+type TaggedItem struct {
+	Id     int64
+	Name   string `graphql:",filterable"`
+	Rank   int64  `graphql:"position,sortable"`
+	Hidden string
+}
+
+func TestPaginatedTaggedFields(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	item := schema.Object("taggedItem", TaggedItem{})
+	item.Key("id")
+	query.FieldFunc("items", func() []TaggedItem {
+		return []TaggedItem{
+			{Id: 1, Name: "apple", Rank: 3, Hidden: "banana"},
+			{Id: 2, Name: "banana", Rank: 2},
+			{Id: 3, Name: "apricot", Rank: 1},
+			{Id: 4, Name: "cherry", Rank: 4},
+		}
+	}, schemabuilder.Paginated)
+	builtSchema := schema.MustBuild()
+
+	q := graphql.MustParse(`
+	{
+		items(filterText: "ap", sortBy: "position", sortOrder: "asc", first: 1) {
+			totalCount
+			edges { node { id } }
+		}
+		hidden: items(filterText: "banana") {
+			totalCount
+		}
+		desc: items(sortBy: "position", sortOrder: "desc", first: 2) {
+			edges { node { id } }
+		}
+	}`, nil)
+	if err := graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+	e := testgraphql.NewExecutorWrapper(t)
+	val, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"items": map[string]interface{}{
+			"totalCount": float64(2),
+			"edges": []interface{}{
+				map[string]interface{}{
+					"node": map[string]interface{}{"__key": float64(3), "id": float64(3)},
+				},
+			},
+		},
+		"hidden": map[string]interface{}{
+			"totalCount": float64(1),
+		},
+		"desc": map[string]interface{}{
+			"edges": []interface{}{
+				map[string]interface{}{
+					"node": map[string]interface{}{"__key": float64(4), "id": float64(4)},
+				},
+				map[string]interface{}{
+					"node": map[string]interface{}{"__key": float64(1), "id": float64(1)},
+				},
+			},
+		},
+	}, internal.AsJSON(val))
+
+	t.Run("unsupported filter type", func(t *testing.T) {
+		type BadItem struct {
+			Id   int64
+			Rank int64 `graphql:",filterable"`
+		}
+		schema := schemabuilder.NewSchema()
+		schema.Object("badItem", BadItem{}).Key("id")
+		schema.Query().FieldFunc("items", func() []BadItem { return nil }, schemabuilder.Paginated)
+		_, err := schema.Build()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid text filter field rank: unsupported type int64, must be a string")
+	})
+
+	t.Run("duplicate sort field", func(t *testing.T) {
+		schema := schemabuilder.NewSchema()
+		schema.Object("taggedItem", TaggedItem{}).Key("id")
+		schema.Query().FieldFunc("items", func() []TaggedItem { return nil },
+			schemabuilder.Paginated,
+			schemabuilder.SortField("position", func(item TaggedItem) int64 { return item.Rank }),
+		)
+		_, err := schema.Build()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "duplicate sort field position")
+	})
+
+	t.Run("non-paginated field", func(t *testing.T) {
+		schema := schemabuilder.NewSchema()
+		schema.Object("taggedItem", TaggedItem{}).Key("id")
+		schema.Query().FieldFunc("items", func() []TaggedItem { return nil })
+		_, err := schema.Build()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "filterable and sortable fields only apply to the nodes of paginated fields")
+	})
+
+	t.Run("input", func(t *testing.T) {
+		schema := schemabuilder.NewSchema()
+		schema.Query().FieldFunc("item", func(args struct {
+			Name string `graphql:",filterable"`
+		}) string {
+			return args.Name
+		})
+		_, err := schema.Build()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "field name of an input cannot be filterable or sortable")
+	})
+}
+
 func TestConnectionManual(t *testing.T) {
 	schema := schemabuilder.NewSchema()
 	type Inner struct {
@@ -1900,3 +2012,91 @@ func TestConnectionCursor(t *testing.T) {
 	}`)
 
 }
+
+func TestConnectionMaxPageSize(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	schema.SetMaxPageSize(3)
+	type Inner struct {
+	}
+
+	query := schema.Query()
+	query.FieldFunc("inner", func() Inner {
+		return Inner{}
+	})
+
+	inner := schema.Object("inner", Inner{})
+	item := schema.Object("item", Item{})
+	item.Key("id")
+
+	items := []Item{{Id: 1}, {Id: 2}, {Id: 3}, {Id: 4}, {Id: 5}}
+	inner.FieldFunc("innerConnection", func(args Args) []Item {
+		return items
+	}, schemabuilder.Paginated)
+	inner.FieldFunc("largeConnection", func(args Args) []Item {
+		return items
+	}, schemabuilder.Paginated, schemabuilder.MaxPageSize(5))
+	inner.PaginateFieldFunc("embeddedConnection", func(args EmbeddedArgs) ([]Item, schemabuilder.PaginationInfo, schemabuilder.PostProcessOptions, error) {
+		return items, schemabuilder.PaginationInfo{
+			TotalCountFunc: func() int64 { return int64(len(items)) },
+		}, schemabuilder.PostProcessOptions{}, nil
+	})
+	builtSchema := schema.MustBuild()
+
+	for _, tc := range []struct {
+		query string
+		err   string
+	}{
+		{query: `{ inner { innerConnection(first: 3, additional: "") { totalCount } } }`},
+		{query: `{ inner { innerConnection(additional: "") { totalCount } } }`},
+		{query: `{ inner { largeConnection(first: 5, additional: "") { totalCount } } }`},
+		{query: `{ inner { embeddedConnection(last: 2, additional: "") { totalCount } } }`},
+		{
+			query: `{ inner { innerConnection(first: 4, additional: "") { totalCount } } }`,
+			err:   `error parsing args for "innerConnection": first/last cannot exceed 3`,
+		},
+		{
+			query: `{ inner { largeConnection(last: 6, additional: "") { totalCount } } }`,
+			err:   `error parsing args for "largeConnection": first/last cannot exceed 5`,
+		},
+		{
+			query: `{ inner { embeddedConnection(last: 4, additional: "") { totalCount } } }`,
+			err:   `error parsing args for "embeddedConnection": first/last cannot exceed 3`,
+		},
+	} {
+		q := graphql.MustParse(tc.query, nil)
+		err := graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet)
+		if tc.err == "" {
+			assert.NoError(t, err, tc.query)
+		} else {
+			assert.EqualError(t, err, tc.err, tc.query)
+		}
+	}
+}
+
+func TestConnectionDefaultMaxPageSize(t *testing.T) {
+	build := func(maxPageSize *int64) *graphql.Schema {
+		schema := schemabuilder.NewSchema()
+		if maxPageSize != nil {
+			schema.SetMaxPageSize(*maxPageSize)
+		}
+		schema.Query().FieldFunc("items", func(args Args) []Item {
+			return nil
+		}, schemabuilder.Paginated)
+		schema.Object("item", Item{}).Key("id")
+		return schema.MustBuild()
+	}
+	prepare := func(schema *graphql.Schema, first int64) error {
+		q := graphql.MustParse(fmt.Sprintf(`{ items(first: %d, additional: "") { totalCount } }`, first), nil)
+		return graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet)
+	}
+
+	// Page sizes are limited by default.
+	schema := build(nil)
+	assert.NoError(t, prepare(schema, schemabuilder.DefaultMaxPageSize))
+	assert.EqualError(t, prepare(schema, schemabuilder.DefaultMaxPageSize+1),
+		fmt.Sprintf(`error parsing args for "items": first/last cannot exceed %d`, schemabuilder.DefaultMaxPageSize))
+
+	// A max of 0 lifts the limit.
+	unlimited := int64(0)
+	assert.NoError(t, prepare(build(&unlimited), schemabuilder.DefaultMaxPageSize+1))
+}
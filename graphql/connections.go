@@ -0,0 +1,130 @@
+package graphql
+
+import (
+	"context"
+	"sync"
+)
+
+// Connections tracks the websocket connections of a server, so they can be
+// shut down gracefully, see WithConnections.
+type Connections struct {
+	mu           sync.Mutex
+	conns        map[*conn]struct{}
+	shuttingDown bool
+}
+
+// NewConnections creates an empty Connections.
+func NewConnections() *Connections {
+	return &Connections{
+		conns: make(map[*conn]struct{}),
+	}
+}
+
+// add tracks c, unless the server is shutting down.
+func (cs *Connections) add(c *conn) bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.shuttingDown {
+		return false
+	}
+	cs.conns[c] = struct{}{}
+	return true
+}
+
+func (cs *Connections) remove(c *conn) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	delete(cs.conns, c)
+}
+
+// Len returns the number of connections being served.
+func (cs *Connections) Len() int {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return len(cs.conns)
+}
+
+// UnackedUpdates returns the total number of updates of reliable
+// subscriptions that clients have not acknowledged, see conn.UnackedUpdates.
+func (cs *Connections) UnackedUpdates() uint64 {
+	var total uint64
+	for _, c := range cs.list() {
+		for _, unacked := range c.UnackedUpdates() {
+			total += unacked
+		}
+	}
+	return total
+}
+
+// list returns the connections being served.
+func (cs *Connections) list() []*conn {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	conns := make([]*conn, 0, len(cs.conns))
+	for c := range cs.conns {
+		conns = append(conns, c)
+	}
+	return conns
+}
+
+// TerminateSubscriptions stops the subscriptions matching match, and sends
+// their clients a "complete" message with reason, eg. because a user lost
+// access to the subscribed data. match is called with the info of the
+// subscription's connection, see ConnectionInfoFromContext. It returns the
+// number of terminated subscriptions.
+func (cs *Connections) TerminateSubscriptions(reason string, match func(conn ConnectionInfo, info *SubscriptionInfo) bool) int {
+	terminated := 0
+	for _, c := range cs.list() {
+		info := c.connectionInfo()
+		terminated += c.terminateSubscriptions(reason, func(subscription *SubscriptionInfo) bool {
+			return match(info, subscription)
+		})
+	}
+	return terminated
+}
+
+// Terminate terminates the connections matching match: it stops their
+// subscriptions like TerminateSubscriptions, sends their clients a "close"
+// message with reason, and closes their sockets, eg. when a user logs out.
+// Clients should not reconnect with the same credentials. It returns the
+// number of terminated connections.
+func (cs *Connections) Terminate(reason string, match func(conn ConnectionInfo) bool) int {
+	terminated := 0
+	for _, c := range cs.list() {
+		if match(c.connectionInfo()) {
+			c.terminate(reason)
+			terminated++
+		}
+	}
+	return terminated
+}
+
+// Shutdown gracefully shuts down all connections concurrently, see
+// conn.Shutdown, and asks new connections to reconnect elsewhere. Call it
+// when a server stops, after it is removed from its load balancer. It
+// returns ctx.Err() if ctx is done before all connections sent their
+// pending results.
+func (cs *Connections) Shutdown(ctx context.Context) error {
+	cs.mu.Lock()
+	cs.shuttingDown = true
+	conns := make([]*conn, 0, len(cs.conns))
+	for c := range cs.conns {
+		conns = append(conns, c)
+	}
+	cs.mu.Unlock()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(conns))
+	for _, c := range conns {
+		wg.Add(1)
+		go func(c *conn) {
+			defer wg.Done()
+			if err := c.Shutdown(ctx); err != nil {
+				errs <- err
+			}
+		}(c)
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
@@ -0,0 +1,286 @@
+package graphql
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/denkhaus/thunder/concurrencylimiter"
+)
+
+// This file contains a simple static cost analysis for queries. Every selected
+// field costs 1 plus the cost of its subselections. Subselections of fields
+// that take a "first" or "last" argument (such as paginated connections) are
+// multiplied by the requested page size, as they will be resolved once per item.
+//
+// Costs saturate at maxQueryCost, so that huge page sizes or deeply nested
+// connections cannot overflow the cost and slip under a budget.
+
+// maxQueryCost is the largest cost computed for a query.
+const maxQueryCost = math.MaxInt32
+
+// addCost returns a+b, saturating at maxQueryCost.
+func addCost(a, b int) int {
+	if a > maxQueryCost-b {
+		return maxQueryCost
+	}
+	return a + b
+}
+
+// mulCost returns a*b, saturating at maxQueryCost.
+func mulCost(a, b int) int {
+	if a != 0 && b > maxQueryCost/a {
+		return maxQueryCost
+	}
+	return a * b
+}
+
+// CostReport is a breakdown of the estimated cost of a query.
+type CostReport struct {
+	// Total is the estimated cost of the entire query.
+	Total int `json:"total"`
+	// MaxCost is the budget the query was checked against, if any.
+	MaxCost int `json:"maxCost,omitempty"`
+	// Fields holds the estimated cost of each top-level field, keyed by alias.
+	Fields map[string]int `json:"fields"`
+}
+
+// ComputeCost estimates the cost of executing selectionSet against typ. The
+// selectionSet should already have been checked with PrepareQuery.
+func ComputeCost(typ Type, selectionSet *SelectionSet) (*CostReport, error) {
+	report := &CostReport{
+		Fields: make(map[string]int),
+	}
+
+	object, ok := typ.(*Object)
+	if !ok {
+		return nil, NewClientError("cost analysis requires an object root type")
+	}
+
+	selections, err := Flatten(selectionSet)
+	if err != nil {
+		return nil, err
+	}
+	for _, selection := range selections {
+		cost, err := selectionCost(object, selection)
+		if err != nil {
+			return nil, err
+		}
+		report.Fields[selection.Alias] = cost
+		report.Total = addCost(report.Total, cost)
+	}
+	return report, nil
+}
+
+// selectionCost computes the cost of a single selection on an object.
+func selectionCost(object *Object, selection *Selection) (int, error) {
+	if ok, err := shouldIncludeNode(selection.Directives); err != nil || !ok {
+		return 0, err
+	}
+	if selection.Name == "__typename" {
+		return 0, nil
+	}
+
+	field, ok := object.Fields[selection.Name]
+	if !ok {
+		return 0, NewClientError(`unknown field "%s"`, selection.Name)
+	}
+
+	childCost, err := typeCost(field.Type, selection.SelectionSet)
+	if err != nil {
+		return 0, err
+	}
+	return addCost(1, mulCost(childCost, pageSizeMultiplier(selection.UnparsedArgs))), nil
+}
+
+// typeCost computes the cost of a selectionSet on a given type.
+func typeCost(typ Type, selectionSet *SelectionSet) (int, error) {
+	switch typ := typ.(type) {
+	case *Scalar, *Enum:
+		return 0, nil
+
+	case *List:
+		return typeCost(typ.Type, selectionSet)
+
+	case *NonNull:
+		return typeCost(typ.Type, selectionSet)
+
+	case *Union:
+		// Only one of the union's types will be resolved, so charge for the most
+		// expensive fragment.
+		max := 0
+		for _, fragment := range selectionSet.Fragments {
+			ok, err := shouldIncludeNode(fragment.Directives)
+			if err != nil {
+				return 0, err
+			}
+			if !ok {
+				continue
+			}
+			object, ok := typ.Types[fragment.On]
+			if !ok {
+				continue
+			}
+			cost, err := typeCost(object, fragment.SelectionSet)
+			if err != nil {
+				return 0, err
+			}
+			if cost > max {
+				max = cost
+			}
+		}
+		return max, nil
+
+	case *Object:
+		if selectionSet == nil {
+			return 0, nil
+		}
+		selections, err := Flatten(selectionSet)
+		if err != nil {
+			return 0, err
+		}
+		total := 0
+		for _, selection := range selections {
+			cost, err := selectionCost(typ, selection)
+			if err != nil {
+				return 0, err
+			}
+			total = addCost(total, cost)
+		}
+		return total, nil
+
+	default:
+		return 0, fmt.Errorf("unknown type kind %T", typ)
+	}
+}
+
+// pageSizeMultiplier returns the page size requested through a "first" or
+// "last" argument, or 1 if neither is present. Page sizes above
+// maxQueryCost are clamped to it.
+func pageSizeMultiplier(args interface{}) int {
+	m, ok := args.(map[string]interface{})
+	if !ok {
+		return 1
+	}
+	multiplier := 1
+	for _, name := range []string{"first", "last"} {
+		n, ok := m[name].(float64)
+		if !ok {
+			continue
+		}
+		if n > maxQueryCost {
+			n = maxQueryCost
+		}
+		if int(n) > multiplier {
+			multiplier = int(n)
+		}
+	}
+	return multiplier
+}
+
+// computeOperationCost estimates the cost of query against the root type of
+// its kind.
+func computeOperationCost(schema *Schema, query *Query) (*CostReport, error) {
+	typ := schema.Query
+	if query.Kind == "mutation" {
+		typ = schema.Mutation
+	}
+	return ComputeCost(typ, query.SelectionSet)
+}
+
+// costAnalysis configures CostAnalysisMiddleware.
+type costAnalysis struct {
+	includeReport bool
+}
+
+// CostAnalysisOption configures CostAnalysisMiddleware.
+type CostAnalysisOption func(*costAnalysis)
+
+// WithCostReport includes the computed CostReport in the "cost" key of the
+// response extensions, so clients can see how close they are to the budget.
+func WithCostReport() CostAnalysisOption {
+	return func(c *costAnalysis) {
+		c.includeReport = true
+	}
+}
+
+// CostAnalysisMiddleware computes the cost of every query and rejects queries
+// whose total cost exceeds maxCost. A maxCost of 0 disables the limit.
+func CostAnalysisMiddleware(schema *Schema, maxCost int, opts ...CostAnalysisOption) MiddlewareFunc {
+	c := &costAnalysis{}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return func(input *ComputationInput, next MiddlewareNextFunc) *ComputationOutput {
+		report, err := computeOperationCost(schema, input.ParsedQuery)
+		if err != nil {
+			return &ComputationOutput{
+				Metadata:   make(map[string]interface{}),
+				Extensions: make(map[string]interface{}),
+				Error:      err,
+			}
+		}
+		report.MaxCost = maxCost
+
+		var output *ComputationOutput
+		if maxCost > 0 && report.Total > maxCost {
+			output = &ComputationOutput{
+				Metadata:   make(map[string]interface{}),
+				Extensions: make(map[string]interface{}),
+				Error:      NewClientError("query cost %d exceeds maximum cost %d", report.Total, maxCost),
+			}
+		} else {
+			output = next(input)
+		}
+
+		if c.includeReport {
+			if output.Extensions == nil {
+				output.Extensions = make(map[string]interface{})
+			}
+			output.Extensions["cost"] = report
+		}
+		return output
+	}
+}
+
+// CostPriorityMiddleware schedules operations by their estimated cost.
+// Operations costing at most maxFastCost run with a token from the fast lane,
+// and more expensive operations run with a token from the expensive lane. Give
+// the expensive lane a small limit, so that heavy queries (such as dashboards)
+// queue among themselves instead of starving interactive traffic.
+//
+// The token is held for the entire operation. Resolvers that wait on
+// something other than the CPU should use concurrencylimiter.TemporarilyRelease
+// to let other operations in the lane run, which also releases the token of a
+// limit attached to the request by concurrencylimiter.With.
+func CostPriorityMiddleware(schema *Schema, maxFastCost int, fast, expensive *concurrencylimiter.Lane) MiddlewareFunc {
+	return func(input *ComputationInput, next MiddlewareNextFunc) *ComputationOutput {
+		report, err := computeOperationCost(schema, input.ParsedQuery)
+		if err != nil {
+			return &ComputationOutput{
+				Metadata:   make(map[string]interface{}),
+				Extensions: make(map[string]interface{}),
+				Error:      err,
+			}
+		}
+
+		lane := fast
+		if report.Total > maxFastCost {
+			lane = expensive
+		}
+
+		ctx, release := lane.Acquire(input.Ctx)
+		defer release()
+		if err := ctx.Err(); err != nil {
+			return &ComputationOutput{
+				Metadata:   make(map[string]interface{}),
+				Extensions: make(map[string]interface{}),
+				Error:      err,
+			}
+		}
+
+		inputCopy := *input
+		inputCopy.Ctx = ctx
+		return next(&inputCopy)
+	}
+}
@@ -0,0 +1,204 @@
+package graphql_test
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kylelemons/godebug/pretty"
+
+	"github.com/denkhaus/thunder/concurrencylimiter"
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+)
+
+type costItem struct {
+	Name string
+}
+
+func makeCostSchema() *graphql.Schema {
+	schema := schemabuilder.NewSchema()
+
+	query := schema.Query()
+	query.FieldFunc("item", func() *costItem {
+		return &costItem{Name: "a"}
+	})
+	query.FieldFunc("items", func(args struct{ First *int64 }) []*costItem {
+		return []*costItem{{Name: "a"}, {Name: "b"}}
+	})
+	item := schema.Object("costItem", costItem{})
+	item.FieldFunc("child", func(c *costItem) *costItem {
+		return &costItem{Name: c.Name + "!"}
+	})
+	item.FieldFunc("children", func(c *costItem, args struct{ First *int64 }) []*costItem {
+		return nil
+	})
+
+	return schema.MustBuild()
+}
+
+func TestComputeCost(t *testing.T) {
+	schema := makeCostSchema()
+
+	q := graphql.MustParse(`{
+		item { name child { name } }
+		items(first: 10) { name }
+		... on Query { more: item { __typename } }
+		skipped: item @skip(if: true) { name }
+	}`, nil)
+	if err := graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := graphql.ComputeCost(schema.Query, q.SelectionSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := pretty.Compare(report, &graphql.CostReport{
+		Total: 16,
+		Fields: map[string]int{
+			"item":    4,
+			"items":   11,
+			"more":    1,
+			"skipped": 0,
+		},
+	}); diff != "" {
+		t.Errorf("expected report to match, but received %s", diff)
+	}
+}
+
+func TestComputeCostSaturates(t *testing.T) {
+	schema := makeCostSchema()
+
+	nested := `{ name }`
+	for i := 0; i < 7; i++ {
+		nested = `{ children(first: 1000) ` + nested + ` }`
+	}
+	for _, c := range []struct {
+		name  string
+		query string
+	}{
+		{"huge page", `{ items(first: 4611686018427387904) { child { name } } }`},
+		{"max int64 page", `{ items(first: 9223372036854775807) { child { name } } }`},
+		{"nested pages", `{ items(first: 1000) ` + nested + ` }`},
+		{"many fields", `{ a: items(first: 2147483647) { name } b: items(first: 2147483647) { name } }`},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			q := graphql.MustParse(c.query, nil)
+			report, err := graphql.ComputeCost(schema.Query, q.SelectionSet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Total != math.MaxInt32 {
+				t.Errorf("expected saturated cost %d, but received %d", math.MaxInt32, report.Total)
+			}
+		})
+	}
+}
+
+// costUnknownType is a type kind that cost analysis does not know.
+type costUnknownType struct {
+	graphql.Type
+}
+
+func TestComputeCostUnknownType(t *testing.T) {
+	query := &graphql.Object{
+		Name: "Query",
+		Fields: map[string]*graphql.Field{
+			"unknown": {Type: costUnknownType{&graphql.Scalar{Type: "string"}}},
+		},
+	}
+
+	q := graphql.MustParse(`{ unknown }`, nil)
+	_, err := graphql.ComputeCost(query, q.SelectionSet)
+	if err == nil || err.Error() != "unknown type kind graphql_test.costUnknownType" {
+		t.Errorf("expected unknown type kind error, but received %v", err)
+	}
+}
+
+func testCostHTTPRequest(body string, opts ...graphql.CostAnalysisOption) *httptest.ResponseRecorder {
+	schema := makeCostSchema()
+
+	req, err := http.NewRequest("POST", "/graphql", strings.NewReader(body))
+	if err != nil {
+		panic(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := graphql.HTTPHandler(schema, graphql.CostAnalysisMiddleware(schema, 5, opts...))
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestCostAnalysisMiddleware(t *testing.T) {
+	rr := testCostHTTPRequest(`{"query": "{ item { name } }"}`)
+	if diff := pretty.Compare(rr.Body.String(), `{"data":{"item":{"name":"a"}},"errors":null}`); diff != "" {
+		t.Errorf("expected response to match, but received %s", diff)
+	}
+
+	rr = testCostHTTPRequest(`{"query": "{ items(first: 10) { name } }"}`)
+	if diff := pretty.Compare(rr.Body.String(), `{"data":null,"errors":["query cost 11 exceeds maximum cost 5"]}`); diff != "" {
+		t.Errorf("expected response to match, but received %s", diff)
+	}
+}
+
+func TestCostAnalysisMiddlewareReport(t *testing.T) {
+	rr := testCostHTTPRequest(`{"query": "{ item { name } a: item { child { name } } }"}`, graphql.WithCostReport())
+	if diff := pretty.Compare(rr.Body.String(), `{"data":{"a":{"child":{"name":"a!"}},"item":{"name":"a"}},"errors":null,"extensions":{"cost":{"total":5,"maxCost":5,"fields":{"a":3,"item":2}}}}`); diff != "" {
+		t.Errorf("expected response to match, but received %s", diff)
+	}
+
+	rr = testCostHTTPRequest(`{"query": "{ items(first: 10) { name } }"}`, graphql.WithCostReport())
+	if diff := pretty.Compare(rr.Body.String(), `{"data":null,"errors":["query cost 11 exceeds maximum cost 5"],"extensions":{"cost":{"total":11,"maxCost":5,"fields":{"items":11}}}}`); diff != "" {
+		t.Errorf("expected response to match, but received %s", diff)
+	}
+}
+
+func TestCostPriorityMiddleware(t *testing.T) {
+	schema := makeCostSchema()
+	fast := concurrencylimiter.NewLane(1)
+	expensive := concurrencylimiter.NewLane(1)
+	handler := graphql.HTTPHandler(schema, graphql.CostPriorityMiddleware(schema, 5, fast, expensive))
+
+	serve := func(body string) string {
+		req, err := http.NewRequest("POST", "/graphql", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Body.String()
+	}
+
+	// Occupy the expensive lane.
+	_, release := expensive.Acquire(context.Background())
+
+	// Cheap queries still run.
+	if diff := pretty.Compare(serve(`{"query": "{ item { name } }"}`), `{"data":{"item":{"name":"a"}},"errors":null}`); diff != "" {
+		t.Errorf("expected response to match, but received %s", diff)
+	}
+	if fast.InUse() != 0 {
+		t.Errorf("expected fast lane to be released, but %d tokens are in use", fast.InUse())
+	}
+
+	// Expensive queries wait for the expensive lane.
+	done := make(chan string)
+	go func() {
+		done <- serve(`{"query": "{ items(first: 10) { name } }"}`)
+	}()
+	select {
+	case <-done:
+		t.Fatal("expected expensive query to wait for the expensive lane")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release()
+	if diff := pretty.Compare(<-done, `{"data":{"items":[{"name":"a"},{"name":"b"}]},"errors":null}`); diff != "" {
+		t.Errorf("expected response to match, but received %s", diff)
+	}
+}
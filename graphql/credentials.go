@@ -0,0 +1,141 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// CredentialsExpiredReason is the reason sent to clients when their
+// subscriptions are terminated because the credentials of the connection
+// expired, see WithAuthenticate.
+const CredentialsExpiredReason = "credentials expired"
+
+// Credentials are the credentials of a connection, see WithAuthenticate.
+type Credentials struct {
+	// MakeCtx adds the credentials to the context of every execution. It is
+	// called after the connection's MakeCtxFunc.
+	MakeCtx MakeCtxFunc
+	// Expiry is when the credentials lapse. Zero credentials never lapse.
+	Expiry time.Time
+	// Payload describes the credentials to resolvers, eg. the claims of a
+	// token, see ConnectionInfo.
+	Payload interface{}
+}
+
+// AuthenticateFunc verifies the message of a "connection_update" message, eg.
+// a refreshed token, and returns the credentials it grants.
+type AuthenticateFunc func(ctx context.Context, message json.RawMessage) (*Credentials, error)
+
+// WithAuthenticate lets clients refresh the credentials of an established
+// connection with "connection_update" messages, eg. before their token
+// expires. The message is verified by authenticate, and the server replies
+// with a "connection_updated" message. An error is sent to the client and
+// keeps the current credentials.
+//
+// Every later execution, including reruns of existing subscriptions, uses the
+// new credentials. If the credentials lapse before the client refreshes them,
+// all subscriptions are terminated with CredentialsExpiredReason and new
+// operations are rejected until the next "connection_update". The socket
+// stays open.
+func WithAuthenticate(authenticate AuthenticateFunc) ConnectionOption {
+	return func(c *conn) {
+		c.authenticate = authenticate
+	}
+}
+
+// WithCredentials sets the initial credentials of the connection, eg. those
+// of the request that opened the socket, so that they expire like refreshed
+// credentials. See WithAuthenticate.
+func WithCredentials(credentials *Credentials) ConnectionOption {
+	return func(c *conn) {
+		c.credentials = credentials
+	}
+}
+
+// handleConnectionUpdate replaces the credentials of the connection with
+// those granted by the message of in.
+func (c *conn) handleConnectionUpdate(in *inEnvelope) error {
+	if c.authenticate == nil {
+		return NewSafeError("connection updates are not supported")
+	}
+	credentials, err := c.authenticate(c.ctx, in.Message)
+	if err != nil {
+		return err
+	}
+
+	c.setCredentials(credentials)
+	c.writeOrClose(outEnvelope{
+		ID:   in.ID,
+		Type: "connection_updated",
+	})
+	return nil
+}
+
+// setCredentials replaces the credentials of the connection, and expires
+// them at their expiry.
+func (c *conn) setCredentials(credentials *Credentials) {
+	c.credentialsMu.Lock()
+	defer c.credentialsMu.Unlock()
+
+	c.credentials = credentials
+	c.credentialsExpired = false
+	if c.credentialsTimer != nil {
+		c.credentialsTimer.Stop()
+		c.credentialsTimer = nil
+	}
+	if credentials == nil || credentials.Expiry.IsZero() {
+		return
+	}
+	c.credentialsTimer = time.AfterFunc(time.Until(credentials.Expiry), func() {
+		c.expireCredentials(credentials)
+	})
+}
+
+// expireCredentials terminates all subscriptions if credentials are still
+// the credentials of the connection.
+func (c *conn) expireCredentials(credentials *Credentials) {
+	c.credentialsMu.Lock()
+	if c.credentials != credentials {
+		// The client refreshed the credentials in time.
+		c.credentialsMu.Unlock()
+		return
+	}
+	c.credentialsExpired = true
+	c.credentialsMu.Unlock()
+
+	c.terminateSubscriptions(CredentialsExpiredReason, func(*SubscriptionInfo) bool { return true })
+}
+
+// stopCredentials stops expiring the credentials of the connection.
+func (c *conn) stopCredentials() {
+	c.credentialsMu.Lock()
+	defer c.credentialsMu.Unlock()
+	if c.credentialsTimer != nil {
+		c.credentialsTimer.Stop()
+		c.credentialsTimer = nil
+	}
+}
+
+// checkCredentials returns an error if the credentials of the connection
+// expired.
+func (c *conn) checkCredentials() error {
+	c.credentialsMu.Lock()
+	defer c.credentialsMu.Unlock()
+	if c.credentialsExpired {
+		return NewSafeError(CredentialsExpiredReason)
+	}
+	return nil
+}
+
+// withCredentials adds the current credentials of the connection to ctx.
+func (c *conn) withCredentials(ctx context.Context) context.Context {
+	c.credentialsMu.Lock()
+	credentials := c.credentials
+	c.credentialsMu.Unlock()
+
+	if credentials == nil || credentials.MakeCtx == nil {
+		return ctx
+	}
+	return credentials.MakeCtx(ctx)
+}
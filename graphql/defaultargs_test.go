@@ -1,8 +1,12 @@
 package graphql_test
 
 import (
+	"context"
 	"testing"
 
+	"github.com/kylelemons/godebug/pretty"
+
+	"github.com/denkhaus/thunder/graphql"
 	"github.com/denkhaus/thunder/graphql/schemabuilder"
 	"github.com/denkhaus/thunder/internal/testgraphql"
 )
@@ -61,3 +65,84 @@ func TestDefaultArgs(t *testing.T) {
 		}
 	}`)
 }
+
+func TestProvidedArgs(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+
+	type Patch struct {
+		Nickname *string
+		Provided schemabuilder.Provided
+	}
+
+	query := schema.Query()
+	query.FieldFunc("update", func(args struct {
+		Nickname *string
+		Patch    *Patch
+		Provided schemabuilder.Provided
+	}) []string {
+		var updated []string
+		if args.Provided.Has("nickname") {
+			updated = append(updated, "nickname")
+		}
+		if args.Patch != nil && args.Patch.Provided.Has("nickname") {
+			updated = append(updated, "patch.nickname")
+		}
+		return updated
+	})
+	builtSchema := schema.MustBuild()
+
+	cases := []struct {
+		name     string
+		query    string
+		vars     map[string]interface{}
+		expected []string
+	}{
+		{
+			name:     "omitted",
+			query:    `{ update(patch: {}) }`,
+			expected: nil,
+		},
+		{
+			name:     "literal",
+			query:    `{ update(nickname: "bob", patch: {nickname: "bob"}) }`,
+			expected: []string{"nickname", "patch.nickname"},
+		},
+		{
+			name:     "explicit null variable",
+			query:    `query q($n: String) { update(nickname: $n, patch: {nickname: $n}) }`,
+			vars:     map[string]interface{}{"n": nil},
+			expected: []string{"nickname", "patch.nickname"},
+		},
+		{
+			name:     "missing variable",
+			query:    `query q($n: String) { update(nickname: $n, patch: {nickname: $n}) }`,
+			vars:     map[string]interface{}{},
+			expected: nil,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			q, err := graphql.Parse(c.query, c.vars)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet); err != nil {
+				t.Fatal(err)
+			}
+			e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+			result, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var updated []string
+			for _, field := range result.(map[string]interface{})["update"].([]interface{}) {
+				updated = append(updated, field.(string))
+			}
+			if diff := pretty.Compare(updated, c.expected); diff != "" {
+				t.Errorf("expected provided args to match, but received %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// This file reports the deprecated fields and enum values used by a query, so
+// that clients still relying on them can be found before they are removed.
+
+// DeprecationWarning describes a deprecated field or enum value used by a query.
+type DeprecationWarning struct {
+	// Type is the name of the object type of the field, or of the enum type.
+	Type string `json:"type"`
+	// Name is the name of the field or enum value.
+	Name string `json:"name"`
+	// Reason is the deprecation reason.
+	Reason string `json:"reason"`
+}
+
+// deprecationReason returns the deprecation reason of the enum value name, if
+// it is deprecated. Aliases are always deprecated in favor of their value.
+func (e *Enum) deprecationReason(name string) (string, bool) {
+	if reason, ok := e.Deprecations[name]; ok {
+		return reason, true
+	}
+	if value, ok := e.Aliases[name]; ok {
+		return fmt.Sprintf("Use %s.", value), true
+	}
+	return "", false
+}
+
+// FindDeprecations returns the deprecated fields and enum values used by
+// selectionSet on typ, sorted by type and name. The selectionSet should
+// already have been checked with PrepareQuery.
+func FindDeprecations(typ Type, selectionSet *SelectionSet) ([]*DeprecationWarning, error) {
+	found := make(map[DeprecationWarning]struct{})
+	if err := findDeprecations(typ, selectionSet, found); err != nil {
+		return nil, err
+	}
+
+	warnings := make([]*DeprecationWarning, 0, len(found))
+	for warning := range found {
+		warning := warning
+		warnings = append(warnings, &warning)
+	}
+	sort.Slice(warnings, func(i, j int) bool {
+		if warnings[i].Type != warnings[j].Type {
+			return warnings[i].Type < warnings[j].Type
+		}
+		return warnings[i].Name < warnings[j].Name
+	})
+	return warnings, nil
+}
+
+// findDeprecations adds the deprecations used by a selectionSet on typ to found.
+func findDeprecations(typ Type, selectionSet *SelectionSet, found map[DeprecationWarning]struct{}) error {
+	if selectionSet == nil {
+		return nil
+	}
+
+	switch typ := typ.(type) {
+	case *List:
+		return findDeprecations(typ.Type, selectionSet, found)
+
+	case *NonNull:
+		return findDeprecations(typ.Type, selectionSet, found)
+
+	case *Union:
+		for _, fragment := range selectionSet.Fragments {
+			ok, err := shouldIncludeNode(fragment.Directives)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+			if object, ok := typ.Types[fragment.On]; ok {
+				if err := findDeprecations(object, fragment.SelectionSet, found); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+
+	case *Object:
+		selections, err := Flatten(selectionSet)
+		if err != nil {
+			return err
+		}
+		for _, selection := range selections {
+			ok, err := shouldIncludeNode(selection.Directives)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+			field, ok := typ.Fields[selection.Name]
+			if !ok {
+				continue
+			}
+
+			if field.DeprecationReason != "" {
+				found[DeprecationWarning{Type: typ.Name, Name: selection.Name, Reason: field.DeprecationReason}] = struct{}{}
+			}
+			for name, argType := range field.Args {
+				findArgDeprecations(argType, selection.UnparsedArgs[name], found)
+			}
+			if err := findDeprecations(field.Type, selection.SelectionSet, found); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	default:
+		return nil
+	}
+}
+
+// findArgDeprecations adds the deprecated enum values in an argument value of
+// type typ to found.
+func findArgDeprecations(typ Type, value interface{}, found map[DeprecationWarning]struct{}) {
+	switch typ := typ.(type) {
+	case *NonNull:
+		findArgDeprecations(typ.Type, value, found)
+
+	case *List:
+		if values, ok := value.([]interface{}); ok {
+			for _, value := range values {
+				findArgDeprecations(typ.Type, value, found)
+			}
+		} else {
+			findArgDeprecations(typ.Type, value, found)
+		}
+
+	case *InputObject:
+		if fields, ok := value.(map[string]interface{}); ok {
+			for name, fieldType := range typ.InputFields {
+				findArgDeprecations(fieldType, fields[name], found)
+			}
+		}
+
+	case *Enum:
+		if name, ok := value.(string); ok {
+			if reason, ok := typ.deprecationReason(name); ok {
+				found[DeprecationWarning{Type: typ.Type, Name: name, Reason: reason}] = struct{}{}
+			}
+		}
+	}
+}
+
+// deprecationWarnings configures DeprecationMiddleware.
+type deprecationWarnings struct {
+	logger func(ctx context.Context, query *Query, warnings []*DeprecationWarning)
+}
+
+// DeprecationOption configures DeprecationMiddleware.
+type DeprecationOption func(*deprecationWarnings)
+
+// WithDeprecationLogger calls logger for every query that uses deprecated
+// fields or enum values. The ctx is the context of the query, which can be
+// used to identify the client.
+func WithDeprecationLogger(logger func(ctx context.Context, query *Query, warnings []*DeprecationWarning)) DeprecationOption {
+	return func(d *deprecationWarnings) {
+		d.logger = logger
+	}
+}
+
+// DeprecationMiddleware adds a "deprecations" key to the response extensions
+// of every query that uses deprecated fields or enum values, listing them
+// along with their deprecation reasons.
+func DeprecationMiddleware(schema *Schema, opts ...DeprecationOption) MiddlewareFunc {
+	d := &deprecationWarnings{}
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return func(input *ComputationInput, next MiddlewareNextFunc) *ComputationOutput {
+		output := next(input)
+
+		typ := schema.Query
+		if input.ParsedQuery.Kind == "mutation" {
+			typ = schema.Mutation
+		}
+		warnings, err := FindDeprecations(typ, input.ParsedQuery.SelectionSet)
+		if err != nil || len(warnings) == 0 {
+			return output
+		}
+
+		if output.Extensions == nil {
+			output.Extensions = make(map[string]interface{})
+		}
+		output.Extensions["deprecations"] = warnings
+		if d.logger != nil {
+			d.logger(input.Ctx, input.ParsedQuery, warnings)
+		}
+		return output
+	}
+}
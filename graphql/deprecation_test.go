@@ -0,0 +1,101 @@
+package graphql_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+)
+
+type deprecationColor int32
+
+func makeDeprecationSchema() *graphql.Schema {
+	schema := schemabuilder.NewSchema()
+	schema.Enum(deprecationColor(0), map[string]deprecationColor{
+		"RED":   0,
+		"GREEN": 1,
+		"BLUE":  2,
+	},
+		schemabuilder.EnumAlias("red", "RED"),
+		schemabuilder.EnumDeprecated("BLUE", "Blue is no longer supported."),
+	)
+
+	query := schema.Query()
+	query.FieldFunc("color", func(args struct{ Colors []deprecationColor }) deprecationColor {
+		return args.Colors[0]
+	})
+	query.FieldFunc("colour", func() deprecationColor {
+		return 1
+	}, schemabuilder.Deprecated("Use color."))
+
+	return schema.MustBuild()
+}
+
+func TestFindDeprecations(t *testing.T) {
+	schema := makeDeprecationSchema()
+
+	q := graphql.MustParse(`{
+		colour
+		again: colour
+		color(colors: [red, GREEN, BLUE])
+	}`, nil)
+	if err := graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	warnings, err := graphql.FindDeprecations(schema.Query, q.SelectionSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := pretty.Compare(warnings, []*graphql.DeprecationWarning{
+		{Type: "Query", Name: "colour", Reason: "Use color."},
+		{Type: "deprecationColor", Name: "BLUE", Reason: "Blue is no longer supported."},
+		{Type: "deprecationColor", Name: "red", Reason: "Use RED."},
+	}); diff != "" {
+		t.Errorf("expected warnings to match, but received %s", diff)
+	}
+}
+
+func TestDeprecationMiddleware(t *testing.T) {
+	schema := makeDeprecationSchema()
+
+	var logged []*graphql.DeprecationWarning
+	handler := graphql.HTTPHandler(schema, graphql.DeprecationMiddleware(schema,
+		graphql.WithDeprecationLogger(func(ctx context.Context, query *graphql.Query, warnings []*graphql.DeprecationWarning) {
+			logged = append(logged, warnings...)
+		}),
+	))
+
+	serve := func(body string) string {
+		req, err := http.NewRequest("POST", "/graphql", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Body.String()
+	}
+
+	if diff := pretty.Compare(serve(`{"query": "{ color(colors: [GREEN]) }"}`), `{"data":{"color":"GREEN"},"errors":null}`); diff != "" {
+		t.Errorf("expected response to match, but received %s", diff)
+	}
+	if len(logged) != 0 {
+		t.Errorf("expected no warnings to be logged, but received %v", logged)
+	}
+
+	if diff := pretty.Compare(serve(`{"query": "{ colour }"}`), `{"data":{"colour":"GREEN"},"errors":null,"extensions":{"deprecations":[{"type":"Query","name":"colour","reason":"Use color."}]}}`); diff != "" {
+		t.Errorf("expected response to match, but received %s", diff)
+	}
+	if diff := pretty.Compare(logged, []*graphql.DeprecationWarning{
+		{Type: "Query", Name: "colour", Reason: "Use color."},
+	}); diff != "" {
+		t.Errorf("expected logged warnings to match, but received %s", diff)
+	}
+}
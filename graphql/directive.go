@@ -2,12 +2,21 @@ package graphql
 
 import (
 	"reflect"
+	"time"
 )
 
 const (
 	SKIP    = "skip"
 	INCLUDE = "include"
 	IF      = "if"
+
+	// THROTTLE updates a field of a subscription at most every MS
+	// milliseconds, eg. `position @throttle(ms: 1000)`. When a dependency of
+	// the field changes, the rest of the payload keeps updating with the
+	// field's previous value until the interval passed. Only non-batch fields
+	// are throttled, and only as long as their parent object is unchanged.
+	THROTTLE = "throttle"
+	MS       = "ms"
 )
 
 // shouldIncludeNode validates and checks the value of a skip or include directive
@@ -26,6 +35,34 @@ func shouldIncludeNode(directives []*Directive) (bool, error) {
 	return true, nil
 }
 
+// includedSelections flattens the top-level selections of query and returns
+// the selections included by their skip or include directives.
+func includedSelections(query *Query) ([]*Selection, error) {
+	selections, err := Flatten(query.SelectionSet)
+	if err != nil {
+		return nil, err
+	}
+	included := make([]*Selection, 0, len(selections))
+	for _, selection := range selections {
+		ok, err := shouldIncludeNode(selection.Directives)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			included = append(included, selection)
+		}
+	}
+	return included, nil
+}
+
+// isEmptyOperation returns whether the directives of query exclude all of its
+// top-level selections, so executing it would resolve nothing. Queries with
+// invalid directives are not empty, so executing them reports the error.
+func isEmptyOperation(query *Query) bool {
+	selections, err := includedSelections(query)
+	return err == nil && len(selections) == 0
+}
+
 // findDirectiveWithName checks if any of the directives on a field have the sepcified name (eg skip or include)
 func findDirectiveWithName(directives []*Directive, name string) *Directive {
 	for _, directive := range directives {
@@ -49,3 +86,30 @@ func parseIf(d *Directive) (bool, error) {
 
 	return args[IF].(bool), nil
 }
+
+// throttleInterval parses the "ms" argument of a throttle directive, if any,
+// and returns 0 if there is none.
+func throttleInterval(directives []*Directive) (time.Duration, error) {
+	d := findDirectiveWithName(directives, THROTTLE)
+	if d == nil {
+		return 0, nil
+	}
+
+	args, _ := d.Args.(map[string]interface{})
+	var ms float64
+	switch arg := args[MS].(type) {
+	case nil:
+		return 0, NewClientError("required argument in directive not provided: ms")
+	case int64:
+		ms = float64(arg)
+	case float64:
+		// Variables are decoded from JSON as float64.
+		ms = arg
+	default:
+		return 0, NewClientError("expected type number, found type %v in \"ms\" argument", reflect.TypeOf(args[MS]))
+	}
+	if ms <= 0 {
+		return 0, NewClientError("expected a positive \"ms\" argument, found %v", ms)
+	}
+	return time.Duration(ms * float64(time.Millisecond)), nil
+}
@@ -2,12 +2,16 @@ package graphql_test
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/denkhaus/thunder/graphql"
 	"github.com/denkhaus/thunder/graphql/schemabuilder"
 	"github.com/denkhaus/thunder/internal/testgraphql"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func buildSchema() *graphql.Schema {
@@ -253,3 +257,45 @@ func TestDirectivesWithErrors(t *testing.T) {
 	assert.Equal(t, err.Error(), "expected type boolean, found type string in \"if\" argument")
 
 }
+
+func TestEmptyOperation(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	calls := 0
+	schema.Query().FieldFunc("value", func() int64 {
+		calls++
+		return 1
+	})
+	schema.Mutation()
+	builtSchema := schema.MustBuild()
+
+	var empty []bool
+	handler := graphql.HTTPHandler(builtSchema, func(input *graphql.ComputationInput, next graphql.MiddlewareNextFunc) *graphql.ComputationOutput {
+		empty = append(empty, input.EmptyOperation)
+		return next(input)
+	})
+	serve := func(body string) string {
+		req, err := http.NewRequest("POST", "/graphql", strings.NewReader(body))
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Body.String()
+	}
+
+	// Operations whose fields are all skipped are not executed.
+	assert.Equal(t, `{"data":{},"errors":null}`, serve(`{"query": "query($skip: Boolean!) { value @skip(if: $skip) ... on Query @include(if: false) { value } }", "variables": {"skip": true}}`))
+	assert.Equal(t, 0, calls)
+	assert.Equal(t, `{"data":{"value":1},"errors":null}`, serve(`{"query": "query($skip: Boolean!) { value @skip(if: $skip) }", "variables": {"skip": false}}`))
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, []bool{true, false}, empty)
+
+	// Invalid directives are reported by the executor.
+	assert.Contains(t, serve(`{"query": "{ value @skip(if: 1) }"}`), "expected type boolean")
+	assert.Equal(t, []bool{true, false, false}, empty)
+
+	// Executors skip empty operations as well.
+	e := testgraphql.NewExecutorWrapper(t)
+	result, err := e.Execute(context.Background(), builtSchema.Query, nil, graphql.MustParse(`{ value @include(if: false) }`, nil))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{}, result)
+	assert.Equal(t, 1, calls)
+}
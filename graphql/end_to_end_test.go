@@ -212,6 +212,54 @@ func TestEnum(t *testing.T) {
 
 }
 
+func TestEnumAlias(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+
+	type enumType int32
+
+	schema.Enum(enumType(1), map[string]enumType{
+		"FIRST":  enumType(1),
+		"SECOND": enumType(2),
+	}, schemabuilder.EnumAlias("firstField", "FIRST"))
+
+	query := schema.Query()
+	query.FieldFunc("inner", func(args struct {
+		EnumField enumType
+	}) enumType {
+		return args.EnumField
+	})
+
+	builtSchema := schema.MustBuild()
+
+	for _, value := range []string{"firstField", "FIRST"} {
+		q := graphql.MustParse(`{ inner(enumField: `+value+`) }`, nil)
+		if err := graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet); err != nil {
+			t.Error(err)
+		}
+
+		e := testgraphql.NewExecutorWrapper(t)
+		val, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]interface{}{
+			"inner": "FIRST",
+		}, internal.AsJSON(val))
+	}
+
+	schema = schemabuilder.NewSchema()
+	schema.Enum(enumType(1), map[string]enumType{
+		"FIRST": enumType(1),
+	}, schemabuilder.EnumAlias("firstField", "MISSING"))
+	_, err := schema.Build()
+	assert.EqualError(t, err, "bad enum graphql_test.enumType: alias firstField refers to unknown value MISSING")
+
+	schema = schemabuilder.NewSchema()
+	schema.Enum(enumType(1), map[string]enumType{
+		"FIRST": enumType(1),
+	}, schemabuilder.EnumDeprecated("MISSING", "gone"))
+	_, err = schema.Build()
+	assert.EqualError(t, err, "bad enum graphql_test.enumType: deprecated value MISSING is unknown")
+}
+
 // TestEndToEndAwaitAndCache tests that slow fields get run in parallel and cached.
 //
 // The test verifies that the `slow` field on user, which sleeps for 100ms, gets
@@ -125,7 +125,7 @@ func PrepareQuery(ctx context.Context, typ Type, selectionSet *SelectionSet) err
 
 		for _, fragment := range selectionSet.Fragments {
 			for typString, graphqlTyp := range typ.Types {
-				if fragment.On != typString {
+				if fragment.On != typString || !IsTypeVisible(ctx, graphqlTyp) {
 					continue
 				}
 				if err := PrepareQuery(ctx, graphqlTyp, fragment.SelectionSet); err != nil {
@@ -165,10 +165,20 @@ func PrepareQuery(ctx context.Context, typ Type, selectionSet *SelectionSet) err
 			}
 
 			field, ok := typ.Fields[selection.Name]
-			if !ok {
+			if !ok || !IsObjectFieldVisible(ctx, typ, selection.Name) {
 				return NewClientError(`unknown field "%s"`, selection.Name)
 			}
 
+			// Check the input fields of args for every caller, as
+			// selections are shared by the callers of cached queries.
+			if _, ok := ctx.Value(visibilityHookKey{}).(VisibilityHook); ok {
+				for name, argType := range field.Args {
+					if err := checkArgVisibility(ctx, argType, selection.UnparsedArgs[name]); err != nil {
+						return NewClientError(`error parsing args for "%s": %s`, selection.Name, err)
+					}
+				}
+			}
+
 			// Only parse args once for a given selection.
 			if !selection.parsed {
 				selection.parsed = true
@@ -179,6 +189,10 @@ func PrepareQuery(ctx context.Context, typ Type, selectionSet *SelectionSet) err
 				selection.Args = parsed
 			}
 
+			if _, err := throttleInterval(selection.Directives); err != nil {
+				return NewClientError(`error parsing directives for "%s": %s`, selection.Name, err)
+			}
+
 			selection.ParentType = typ.Name
 
 			if err := PrepareQuery(ctx, field.Type, selection.SelectionSet); err != nil {
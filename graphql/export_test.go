@@ -1,5 +1,7 @@
 package graphql
 
+import "time"
+
 func PathErrorInit(inner error, path []string) error {
 	return &pathError{
 		inner: inner,
@@ -8,3 +10,17 @@ func PathErrorInit(inner error, path []string) error {
 }
 
 type PathError = pathError
+
+func SetQuotasClock(q *Quotas, now func() time.Time) {
+	q.now = now
+}
+
+func ChargeQuota(q *Quotas, client string, cost int) (QuotaUsage, bool) {
+	return q.charge(client, cost)
+}
+
+func QuotaClients(q *Quotas) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.clients)
+}
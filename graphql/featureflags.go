@@ -0,0 +1,305 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// FeatureFlags provides the values of feature flags, which resolvers read
+// with BoolFlag and the other typed accessors, and which hide fields with
+// schemabuilder.RequireFeatureFlag.
+//
+// Flags can change at any time, so they are read once per request: the
+// values returned by Values are attached to the request's context by
+// WithFeatureFlags, and the request is validated, planned and executed with
+// them. A field is thus never visible while its resolver sees the flag
+// disabled, or vice versa.
+type FeatureFlags interface {
+	// Values returns the values of the flags for the request of ctx, eg. the
+	// values for the request's user. The returned values must not be
+	// modified afterwards.
+	Values(ctx context.Context) FeatureFlagValues
+}
+
+// FeatureFlagValues are the values of feature flags by name. Values are
+// typically booleans, numbers or strings.
+//
+// FeatureFlagValues are FeatureFlags that never change.
+type FeatureFlagValues map[string]interface{}
+
+// Values returns v.
+func (v FeatureFlagValues) Values(ctx context.Context) FeatureFlagValues {
+	return v
+}
+
+// Bool returns the value of the boolean flag name, or def if the flag is not
+// set or not a boolean.
+func (v FeatureFlagValues) Bool(name string, def bool) bool {
+	if value, ok := v[name].(bool); ok {
+		return value
+	}
+	return def
+}
+
+// Int returns the value of the integer flag name, or def if the flag is not
+// set or not an integer.
+func (v FeatureFlagValues) Int(name string, def int64) int64 {
+	switch value := v[name].(type) {
+	case int:
+		return int64(value)
+	case int32:
+		return int64(value)
+	case int64:
+		return value
+	case float64:
+		if value == math.Trunc(value) && math.Abs(value) < 1<<63 {
+			return int64(value)
+		}
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return i
+		}
+	}
+	return def
+}
+
+// Float returns the value of the numeric flag name, or def if the flag is not
+// set or not a number.
+func (v FeatureFlagValues) Float(name string, def float64) float64 {
+	switch value := v[name].(type) {
+	case int:
+		return float64(value)
+	case int32:
+		return float64(value)
+	case int64:
+		return float64(value)
+	case float64:
+		return value
+	case json.Number:
+		if f, err := value.Float64(); err == nil {
+			return f
+		}
+	}
+	return def
+}
+
+// String returns the value of the string flag name, or def if the flag is not
+// set or not a string.
+func (v FeatureFlagValues) String(name string, def string) string {
+	if value, ok := v[name].(string); ok {
+		return value
+	}
+	return def
+}
+
+type featureFlagsKey struct{}
+
+// WithFeatureFlags returns a context whose requests use the values of flags
+// for ctx. The values are read once, so all reads of a flag with the returned
+// context agree. Handlers attach flags before validating queries, see
+// WithHTTPFeatureFlags and WithConnectionFeatureFlags.
+func WithFeatureFlags(ctx context.Context, flags FeatureFlags) context.Context {
+	return context.WithValue(ctx, featureFlagsKey{}, flags.Values(ctx))
+}
+
+// FeatureFlagsFromContext returns the values of the feature flags of ctx,
+// and whether any were attached with WithFeatureFlags.
+func FeatureFlagsFromContext(ctx context.Context) (FeatureFlagValues, bool) {
+	values, ok := ctx.Value(featureFlagsKey{}).(FeatureFlagValues)
+	return values, ok
+}
+
+// BoolFlag returns the value of the boolean flag name for the request of
+// ctx, or def if it is not set.
+func BoolFlag(ctx context.Context, name string, def bool) bool {
+	values, _ := FeatureFlagsFromContext(ctx)
+	return values.Bool(name, def)
+}
+
+// IntFlag returns the value of the integer flag name for the request of ctx,
+// or def if it is not set.
+func IntFlag(ctx context.Context, name string, def int64) int64 {
+	values, _ := FeatureFlagsFromContext(ctx)
+	return values.Int(name, def)
+}
+
+// FloatFlag returns the value of the numeric flag name for the request of
+// ctx, or def if it is not set.
+func FloatFlag(ctx context.Context, name string, def float64) float64 {
+	values, _ := FeatureFlagsFromContext(ctx)
+	return values.Float(name, def)
+}
+
+// StringFlag returns the value of the string flag name for the request of
+// ctx, or def if it is not set.
+func StringFlag(ctx context.Context, name string, def string) string {
+	values, _ := FeatureFlagsFromContext(ctx)
+	return values.String(name, def)
+}
+
+// FeatureFlagsMiddleware attaches flags to the context of computations that
+// have none yet, for executors and middlewares that read flags. Queries are
+// validated before middlewares run, so servers hiding fields behind flags
+// should attach flags with WithHTTPFeatureFlags or WithConnectionFeatureFlags
+// instead, which this middleware then leaves alone.
+func FeatureFlagsMiddleware(flags FeatureFlags) MiddlewareFunc {
+	return func(input *ComputationInput, next MiddlewareNextFunc) *ComputationOutput {
+		if _, ok := FeatureFlagsFromContext(input.Ctx); !ok {
+			input.Ctx = WithFeatureFlags(input.Ctx, flags)
+		}
+		return next(input)
+	}
+}
+
+// MemoryFeatureFlags are FeatureFlags held in memory, for tests and for
+// flags set by the application.
+type MemoryFeatureFlags struct {
+	mu sync.Mutex
+	// values is replaced rather than modified, so it can be handed out by
+	// Values.
+	values FeatureFlagValues
+}
+
+// NewMemoryFeatureFlags returns MemoryFeatureFlags holding a copy of values.
+func NewMemoryFeatureFlags(values FeatureFlagValues) *MemoryFeatureFlags {
+	f := &MemoryFeatureFlags{}
+	f.Replace(values)
+	return f
+}
+
+// Values returns the current values of the flags.
+func (f *MemoryFeatureFlags) Values(ctx context.Context) FeatureFlagValues {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.values
+}
+
+// Set sets the flag name to value. Requests already running keep the
+// previous value.
+func (f *MemoryFeatureFlags) Set(name string, value interface{}) {
+	f.update(func(values FeatureFlagValues) {
+		values[name] = value
+	})
+}
+
+// Delete unsets the flag name.
+func (f *MemoryFeatureFlags) Delete(name string) {
+	f.update(func(values FeatureFlagValues) {
+		delete(values, name)
+	})
+}
+
+// Replace replaces the values of all flags with a copy of values.
+func (f *MemoryFeatureFlags) Replace(values FeatureFlagValues) {
+	copied := make(FeatureFlagValues, len(values))
+	for name, value := range values {
+		copied[name] = value
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.values = copied
+}
+
+// update replaces the values of the flags with a copy modified by fn.
+func (f *MemoryFeatureFlags) update(fn func(values FeatureFlagValues)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	values := make(FeatureFlagValues, len(f.values)+1)
+	for name, value := range f.values {
+		values[name] = value
+	}
+	fn(values)
+	f.values = values
+}
+
+// HTTPFeatureFlagsConfig configures NewHTTPFeatureFlags.
+type HTTPFeatureFlagsConfig struct {
+	// URL serves the values of the flags as a JSON object, eg.
+	//
+	//	{"newDashboard": true, "maxResults": 100}
+	URL string
+	// Client fetches URL. It defaults to http.DefaultClient.
+	Client *http.Client
+	// Interval is the time between refreshes by Run.
+	Interval time.Duration
+	// OnError, if set, is called with the errors of refreshes by Run. The
+	// flags keep their previous values when a refresh fails.
+	OnError func(err error)
+}
+
+// HTTPFeatureFlags are FeatureFlags fetched from a URL, and refreshed
+// periodically by Run. Until the first refresh succeeds, no flags are set.
+type HTTPFeatureFlags struct {
+	config HTTPFeatureFlagsConfig
+	memory MemoryFeatureFlags
+}
+
+// NewHTTPFeatureFlags returns HTTPFeatureFlags fetched as configured by
+// config.
+func NewHTTPFeatureFlags(config HTTPFeatureFlagsConfig) *HTTPFeatureFlags {
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+	return &HTTPFeatureFlags{config: config}
+}
+
+// Values returns the values of the flags from the last successful refresh.
+func (f *HTTPFeatureFlags) Values(ctx context.Context) FeatureFlagValues {
+	return f.memory.Values(ctx)
+}
+
+// Refresh fetches the values of the flags.
+func (f *HTTPFeatureFlags) Refresh(ctx context.Context) error {
+	req, err := http.NewRequest("GET", f.config.URL, nil)
+	if err != nil {
+		return fmt.Errorf("error fetching feature flags: %s", err)
+	}
+	resp, err := f.config.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("error fetching feature flags: %s", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error fetching feature flags: %s", resp.Status)
+	}
+
+	var values FeatureFlagValues
+	decoder := json.NewDecoder(resp.Body)
+	// Keep integers exact.
+	decoder.UseNumber()
+	if err := decoder.Decode(&values); err != nil {
+		return fmt.Errorf("error decoding feature flags: %s", err)
+	}
+	f.memory.Replace(values)
+	return nil
+}
+
+// Run refreshes the flags immediately, and then every interval. Failed
+// refreshes are reported to OnError, and retried at the next interval.
+//
+// Run blocks until ctx is done.
+func (f *HTTPFeatureFlags) Run(ctx context.Context) error {
+	if f.config.Interval <= 0 {
+		return errors.New("feature flags refresh interval must be positive")
+	}
+
+	ticker := time.NewTicker(f.config.Interval)
+	defer ticker.Stop()
+	for {
+		if err := f.Refresh(ctx); err != nil && ctx.Err() == nil && f.config.OnError != nil {
+			f.config.OnError(err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package graphql_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+)
+
+func TestFeatureFlagValues(t *testing.T) {
+	values := graphql.FeatureFlagValues{
+		"enabled": true,
+		"limit":   json.Number("100"),
+		"ratio":   0.5,
+		"count":   float64(3),
+		"variant": "b",
+	}
+	ctx := graphql.WithFeatureFlags(context.Background(), values)
+
+	assert.True(t, graphql.BoolFlag(ctx, "enabled", false))
+	assert.False(t, graphql.BoolFlag(ctx, "missing", false))
+	assert.True(t, graphql.BoolFlag(ctx, "variant", true))
+	assert.Equal(t, int64(100), graphql.IntFlag(ctx, "limit", 10))
+	assert.Equal(t, int64(3), graphql.IntFlag(ctx, "count", 10))
+	assert.Equal(t, int64(10), graphql.IntFlag(ctx, "ratio", 10))
+	assert.Equal(t, 0.5, graphql.FloatFlag(ctx, "ratio", 1))
+	assert.Equal(t, float64(100), graphql.FloatFlag(ctx, "limit", 1))
+	assert.Equal(t, "b", graphql.StringFlag(ctx, "variant", "a"))
+	assert.Equal(t, "a", graphql.StringFlag(ctx, "enabled", "a"))
+
+	// Requests without flags use the defaults.
+	assert.True(t, graphql.BoolFlag(context.Background(), "enabled", true))
+	_, ok := graphql.FeatureFlagsFromContext(context.Background())
+	assert.False(t, ok)
+}
+
+func TestMemoryFeatureFlags(t *testing.T) {
+	flags := graphql.NewMemoryFeatureFlags(graphql.FeatureFlagValues{"enabled": true})
+	ctx := graphql.WithFeatureFlags(context.Background(), flags)
+
+	// Contexts keep the values they were created with.
+	flags.Set("enabled", false)
+	flags.Set("limit", 5)
+	assert.True(t, graphql.BoolFlag(ctx, "enabled", false))
+	assert.Equal(t, int64(0), graphql.IntFlag(ctx, "limit", 0))
+
+	ctx = graphql.WithFeatureFlags(context.Background(), flags)
+	assert.False(t, graphql.BoolFlag(ctx, "enabled", true))
+	assert.Equal(t, int64(5), graphql.IntFlag(ctx, "limit", 0))
+
+	flags.Delete("limit")
+	assert.Equal(t, graphql.FeatureFlagValues{"enabled": false}, flags.Values(context.Background()))
+}
+
+func TestHTTPFeatureFlags(t *testing.T) {
+	var body atomic.Value
+	body.Store(`{"enabled": true, "limit": 100}`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b := body.Load().(string)
+		if b == "" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(b))
+	}))
+	defer server.Close()
+
+	errs := make(chan error, 10)
+	flags := graphql.NewHTTPFeatureFlags(graphql.HTTPFeatureFlagsConfig{
+		URL:      server.URL,
+		Interval: 10 * time.Millisecond,
+		OnError: func(err error) {
+			select {
+			case errs <- err:
+			default:
+			}
+		},
+	})
+	assert.Empty(t, flags.Values(context.Background()))
+
+	require.NoError(t, flags.Refresh(context.Background()))
+	assert.Equal(t, graphql.FeatureFlagValues{"enabled": true, "limit": json.Number("100")}, flags.Values(context.Background()))
+
+	// Failed refreshes keep the previous values.
+	body.Store("")
+	assert.EqualError(t, flags.Refresh(context.Background()), "error fetching feature flags: 503 Service Unavailable")
+	assert.True(t, flags.Values(context.Background()).Bool("enabled", false))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- flags.Run(ctx)
+	}()
+	assert.Error(t, <-errs)
+
+	body.Store(`{"enabled": false}`)
+	deadline := time.Now().Add(time.Second)
+	for flags.Values(context.Background()).Bool("enabled", true) && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.False(t, flags.Values(context.Background()).Bool("enabled", true))
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestFeatureFlagsHTTPHandler(t *testing.T) {
+	builder := schemabuilder.NewSchema()
+	query := builder.Query()
+	query.FieldFunc("limit", func(ctx context.Context) int64 {
+		return graphql.IntFlag(ctx, "limit", 10)
+	})
+	query.FieldFunc("dashboard", func() string { return "new" }, schemabuilder.RequireFeatureFlag("newDashboard"))
+	schema := builder.MustBuild()
+
+	flags := graphql.NewMemoryFeatureFlags(nil)
+	handler := graphql.NewHTTPHandler(schema, graphql.WithHTTPFeatureFlags(flags))
+	execute := func(query string) string {
+		req, err := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "`+query+`"}`))
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Body.String()
+	}
+
+	assert.JSONEq(t, `{"data": {"limit": 10}, "errors": null}`, execute("{ limit }"))
+	assert.JSONEq(t, `{"data": null, "errors": ["unknown field \"dashboard\""]}`, execute("{ dashboard }"))
+
+	flags.Replace(graphql.FeatureFlagValues{"newDashboard": true, "limit": 20})
+	assert.JSONEq(t, `{"data": {"limit": 20, "dashboard": "new"}, "errors": null}`, execute("{ limit dashboard }"))
+}
+
+func TestFeatureFlagsMiddleware(t *testing.T) {
+	middleware := graphql.FeatureFlagsMiddleware(graphql.FeatureFlagValues{"enabled": true})
+	run := func(ctx context.Context) bool {
+		var enabled bool
+		middleware(&graphql.ComputationInput{Ctx: ctx}, func(input *graphql.ComputationInput) *graphql.ComputationOutput {
+			enabled = graphql.BoolFlag(input.Ctx, "enabled", false)
+			return &graphql.ComputationOutput{}
+		})
+		return enabled
+	}
+
+	assert.True(t, run(context.Background()))
+	// Flags attached before validation are kept.
+	assert.False(t, run(graphql.WithFeatureFlags(context.Background(), graphql.FeatureFlagValues{})))
+}
@@ -0,0 +1,92 @@
+package graphql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// FieldCache stores the results of expensive resolvers across requests, for
+// example in Redis. Results are cached for fields with a CacheTTL, see
+// WithFieldCache.
+//
+// Errors returned by a FieldCache do not fail queries: the field is resolved
+// as if the result was not cached.
+type FieldCache interface {
+	// Get returns the cached value for key, if any.
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	// Set caches value for key for ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// WithFieldCache caches the results of fields with a CacheTTL in cache.
+//
+// Results are cached by parent type, field, arguments, and parent key, so
+// only fields on Query and on objects with a KeyField are cached. Batch
+// fields are never cached. Results are marshaled as JSON and decoded with the
+// field's DecodeCached, so they should not depend on unexported state, and
+// cached results are not reactive dependencies of the queries using them.
+func WithFieldCache(cache FieldCache) ExecutorOption {
+	return func(e *Executor) {
+		e.fieldCache = cache
+	}
+}
+
+type fieldCacheKey struct{}
+
+// cachedResolve calls resolve for unit and source, or returns its cached
+// result if the field is cached.
+func cachedResolve(ctx context.Context, unit *WorkUnit, source interface{}, resolve func() (interface{}, error)) (interface{}, error) {
+	cache, ok := ctx.Value(fieldCacheKey{}).(FieldCache)
+	field := unit.field
+	if !ok || field.CacheTTL <= 0 || field.DecodeCached == nil {
+		return resolve()
+	}
+	key, ok := makeFieldCacheKey(ctx, unit, source)
+	if !ok {
+		return resolve()
+	}
+
+	if cached, ok, err := cache.Get(ctx, key); err == nil && ok {
+		if result, err := field.DecodeCached(cached); err == nil {
+			return result, nil
+		}
+	}
+
+	result, err := resolve()
+	if err != nil {
+		return nil, err
+	}
+	if value, err := json.Marshal(result); err == nil {
+		_ = cache.Set(ctx, key, value, field.CacheTTL)
+	}
+	return result, nil
+}
+
+// makeFieldCacheKey returns the cache key of the result of unit for source,
+// or false if it cannot be cached.
+func makeFieldCacheKey(ctx context.Context, unit *WorkUnit, source interface{}) (string, bool) {
+	var parentKey interface{}
+	switch {
+	case unit.keyField != nil:
+		key, err := SafeExecuteResolver(ctx, unit.keyField, source, nil, nil)
+		if err != nil {
+			return "", false
+		}
+		parentKey = key
+	case unit.objectName != "Query":
+		return "", false
+	}
+
+	// Hash the arguments and parent key to keep keys short.
+	hash := sha256.New()
+	if err := json.NewEncoder(hash).Encode(unit.selection.Args); err != nil {
+		return "", false
+	}
+	if err := json.NewEncoder(hash).Encode(parentKey); err != nil {
+		return "", false
+	}
+	return "graphql:" + unit.objectName + "." + unit.selection.Name + ":" + hex.EncodeToString(hash.Sum(nil)), true
+}
@@ -0,0 +1,111 @@
+package graphql_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+)
+
+type memoryFieldCache struct {
+	mu     sync.Mutex
+	values map[string][]byte
+	ttls   map[string]time.Duration
+}
+
+func (c *memoryFieldCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	value, ok := c.values[key]
+	return value, ok, nil
+}
+
+func (c *memoryFieldCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key] = value
+	c.ttls[key] = ttl
+	return nil
+}
+
+type cachedUser struct {
+	Id   int64 `graphql:",key"`
+	Name string
+}
+
+type cachedProfile struct {
+	Bio string
+}
+
+func TestFieldCache(t *testing.T) {
+	var userCalls, profileCalls int64
+
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("user", func(args struct{ Id int64 }) *cachedUser {
+		atomic.AddInt64(&userCalls, 1)
+		return &cachedUser{Id: args.Id, Name: "user"}
+	}, schemabuilder.Cached(time.Minute))
+	user := builder.Object("User", cachedUser{})
+	user.FieldFunc("profile", func(u *cachedUser) *cachedProfile {
+		atomic.AddInt64(&profileCalls, 1)
+		return &cachedProfile{Bio: "bio"}
+	}, schemabuilder.Cached(time.Second))
+	schema := builder.MustBuild()
+
+	cache := &memoryFieldCache{values: map[string][]byte{}, ttls: map[string]time.Duration{}}
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithFieldCache(cache))
+
+	execute := func(query string) interface{} {
+		q := graphql.MustParse(query, nil)
+		require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+		res, err := e.Execute(context.Background(), schema.Query, nil, q)
+		require.NoError(t, err)
+		return res
+	}
+
+	expected := map[string]interface{}{
+		"user": map[string]interface{}{
+			"__key":   int64(1),
+			"name":    "user",
+			"profile": map[string]interface{}{"bio": "bio"},
+		},
+	}
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, expected, execute(`{ user(id: 1) { name profile { bio } } }`))
+	}
+	assert.Equal(t, int64(1), atomic.LoadInt64(&userCalls))
+	assert.Equal(t, int64(1), atomic.LoadInt64(&profileCalls))
+
+	// Other arguments are cached separately.
+	execute(`{ user(id: 2) { name profile { bio } } }`)
+	assert.Equal(t, int64(2), atomic.LoadInt64(&userCalls))
+	assert.Equal(t, int64(2), atomic.LoadInt64(&profileCalls))
+
+	ttls := map[string]time.Duration{}
+	for key, ttl := range cache.ttls {
+		ttls[key[:strings.LastIndex(key, ":")]] = ttl
+	}
+	assert.Equal(t, map[string]time.Duration{
+		"graphql:Query.user":   time.Minute,
+		"graphql:User.profile": time.Second,
+	}, ttls)
+}
+
+func TestFieldCacheNotCacheable(t *testing.T) {
+	builder := schemabuilder.NewSchema()
+	builder.Query().FieldFunc("noResult", func() error {
+		return nil
+	}, schemabuilder.Cached(time.Minute))
+	_, err := builder.Build()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "only non-batch, non-paginated functions with a result can be cached")
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"log"
 	"net/http"
 	"sync"
+	"sync/atomic"
 
 	"github.com/denkhaus/thunder/batch"
 	"github.com/denkhaus/thunder/reactive"
@@ -27,66 +29,180 @@ type httpHandler struct {
 	schema      *Schema
 	middlewares []MiddlewareFunc
 	executor    ExecutorRunner
+
+	maxBodyBytes      int64
+	strictContentType bool
+	csrfHeaders       []string
+	cors              *CORSConfig
+	queryCache        *QueryCache
+	schemaHash        string
+	withSchemaHash    bool
+	jsonMarshal       bool
+	registry          *SchemaRegistry
+	compression       *compressionConfig
+	featureFlags      FeatureFlags
 }
 
+// errNoSchema is returned for requests that no schema of a SchemaRegistry
+// serves.
+var errNoSchema = errors.New("no schema serves this request")
+
 type httpPostBody struct {
 	Query     string                 `json:"query"`
 	Variables map[string]interface{} `json:"variables"`
 }
 
 type httpResponse struct {
-	Data   interface{} `json:"data"`
-	Errors []string    `json:"errors"`
+	Data       interface{}            `json:"data"`
+	Errors     []string               `json:"errors"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
 }
 
 func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	writeResponse := func(value interface{}, err error) {
+	if h.compression != nil {
+		if cw := h.compression.compress(w, r); cw != nil {
+			defer closeCompression(cw)
+			w = cw
+		}
+	}
+
+	builtSchema, queryCache, schemaHash := h.schema, h.queryCache, h.schemaHash
+	var registered *registeredSchema
+	if h.registry != nil {
+		builtSchema, queryCache, schemaHash = nil, nil, ""
+		registered = h.registry.lookup(r)
+		if registered != nil {
+			atomic.AddInt64(&registered.requests, 1)
+			builtSchema, queryCache = registered.schema, registered.queryCache
+			if h.withSchemaHash {
+				schemaHash = registered.schemaHash()
+			}
+		}
+	}
+
+	writeResponse := func(value interface{}, extensions map[string]interface{}, err error) {
+		if err != nil && registered != nil {
+			atomic.AddInt64(&registered.errors, 1)
+		}
+
 		response := httpResponse{}
+		if schemaHash != "" {
+			withHash := make(map[string]interface{}, len(extensions)+1)
+			for k, v := range extensions {
+				withHash[k] = v
+			}
+			withHash["schemaHash"] = schemaHash
+			extensions = withHash
+		}
+		if len(extensions) > 0 {
+			response.Extensions = extensions
+		}
 		if err != nil {
 			response.Errors = []string{err.Error()}
 		} else {
 			response.Data = value
 		}
 
-		responseJSON, err := json.Marshal(response)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		if h.jsonMarshal {
+			responseJSON, err := json.Marshal(response)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if w.Header().Get("Content-Type") == "" {
+				w.Header().Set("Content-Type", "application/json")
+			}
+			w.Write(responseJSON)
 			return
 		}
+
 		if w.Header().Get("Content-Type") == "" {
 			w.Header().Set("Content-Type", "application/json")
 		}
-		w.Write(responseJSON)
+		if written, err := writeHTTPResponse(w, &response); err != nil {
+			if !written {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			log.Printf("graphql: writing response: %v", err)
+		}
+	}
+	writeError := func(status int, err error) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		writeResponse(nil, nil, err)
+	}
+
+	if schemaHash != "" {
+		w.Header().Set(SchemaHashHeader, schemaHash)
+	}
+
+	if h.cors != nil && h.cors.serveCORS(w, r) {
+		return
+	}
+
+	if builtSchema == nil {
+		writeError(http.StatusNotFound, errNoSchema)
+		return
 	}
 
 	if r.Method != "POST" {
-		writeResponse(nil, errors.New("request must be a POST"))
+		writeResponse(nil, nil, errors.New("request must be a POST"))
 		return
 	}
 
 	if r.Body == nil {
-		writeResponse(nil, errors.New("request must include a query"))
+		writeResponse(nil, nil, errors.New("request must include a query"))
 		return
 	}
 
+	if h.strictContentType {
+		if err := checkContentType(r); err != nil {
+			writeError(http.StatusUnsupportedMediaType, err)
+			return
+		}
+	}
+	if h.csrfHeaders != nil {
+		if err := h.checkCSRF(r); err != nil {
+			writeError(http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	var body *limitedBody
+	if h.maxBodyBytes > 0 {
+		body = &limitedBody{ReadCloser: r.Body, remaining: h.maxBodyBytes}
+		r.Body = body
+	}
+
 	var params httpPostBody
-	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
-		writeResponse(nil, err)
+	if err := parseParams(r, &params); err != nil {
+		if body != nil && body.exceeded {
+			writeError(http.StatusRequestEntityTooLarge, errRequestTooLarge)
+			return
+		}
+		writeResponse(nil, nil, err)
 		return
 	}
 
-	query, err := Parse(params.Query, params.Variables)
+	query, err := parseQuery(queryCache, params.Query, params.Variables)
 	if err != nil {
-		writeResponse(nil, err)
+		writeResponse(nil, nil, err)
 		return
 	}
 
-	schema := h.schema.Query
+	schema := builtSchema.Query
 	if query.Kind == "mutation" {
-		schema = h.schema.Mutation
+		schema = builtSchema.Mutation
 	}
-	if err := PrepareQuery(r.Context(), schema, query.SelectionSet); err != nil {
-		writeResponse(nil, err)
+	// Validate and execute the query with the same flags, so fields hidden by
+	// flags are consistent with their resolvers.
+	ctx := r.Context()
+	if h.featureFlags != nil {
+		ctx = WithFeatureFlags(ctx, h.featureFlags)
+	}
+	if err := PrepareQuery(ctx, schema, query.SelectionSet); err != nil {
+		writeResponse(nil, nil, err)
 		return
 	}
 
@@ -94,7 +210,7 @@ func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	e := h.executor
 
 	wg.Add(1)
-	runner := reactive.NewRerunner(r.Context(), func(ctx context.Context) (interface{}, error) {
+	runner := reactive.NewRerunner(ctx, func(ctx context.Context) (interface{}, error) {
 		defer wg.Done()
 
 		ctx = batch.WithBatching(ctx)
@@ -103,15 +219,20 @@ func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		middlewares = append(middlewares, h.middlewares...)
 		middlewares = append(middlewares, func(input *ComputationInput, next MiddlewareNextFunc) *ComputationOutput {
 			output := next(input)
+			if input.EmptyOperation {
+				output.Current = map[string]interface{}{}
+				return output
+			}
 			output.Current, output.Error = e.Execute(input.Ctx, schema, nil, input.ParsedQuery)
 			return output
 		})
 
 		output := RunMiddlewares(middlewares, &ComputationInput{
-			Ctx:         ctx,
-			ParsedQuery: query,
-			Query:       params.Query,
-			Variables:   params.Variables,
+			Ctx:            ctx,
+			ParsedQuery:    query,
+			Query:          params.Query,
+			Variables:      params.Variables,
+			EmptyOperation: isEmptyOperation(query),
 		})
 		current, err := output.Current, output.Error
 
@@ -120,11 +241,11 @@ func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				return nil, err
 			}
 
-			writeResponse(nil, err)
+			writeResponse(nil, output.Extensions, err)
 			return nil, err
 		}
 
-		writeResponse(current, nil)
+		writeResponse(current, output.Extensions, nil)
 		return nil, nil
 	}, DefaultMinRerunInterval, false)
 
@@ -0,0 +1,278 @@
+package graphql
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// DefaultCompressionMinSize is the minimum size of compressed responses used
+// by WithCompression if minSize is 0.
+const DefaultCompressionMinSize = 1024
+
+// CompressWriter is a compressing writer that can be reused for another
+// response, such as a *gzip.Writer.
+type CompressWriter interface {
+	io.WriteCloser
+	// Reset discards the writer's state and makes it write to w.
+	Reset(w io.Writer)
+}
+
+// A Compressor compresses responses with a content coding, see
+// WithCompression. Its writers are pooled across responses.
+type Compressor struct {
+	// Encoding is the name of the content coding, such as "gzip".
+	Encoding string
+
+	pool sync.Pool
+}
+
+// NewCompressor creates a Compressor for encoding, with writers created by
+// newWriter, to add content codings that this package does not implement.
+func NewCompressor(encoding string, newWriter func(w io.Writer) CompressWriter) *Compressor {
+	c := &Compressor{Encoding: encoding}
+	c.pool.New = func() interface{} {
+		return newWriter(nil)
+	}
+	return c
+}
+
+// GzipCompressor creates a Compressor for the gzip content coding with
+// compression level, such as gzip.DefaultCompression. It panics if level is
+// invalid.
+func GzipCompressor(level int) *Compressor {
+	if _, err := gzip.NewWriterLevel(nil, level); err != nil {
+		panic(err)
+	}
+	return NewCompressor("gzip", func(w io.Writer) CompressWriter {
+		writer, _ := gzip.NewWriterLevel(w, level)
+		return writer
+	})
+}
+
+// DeflateCompressor creates a Compressor for the deflate content coding with
+// compression level, such as flate.DefaultCompression. It panics if level is
+// invalid.
+func DeflateCompressor(level int) *Compressor {
+	if _, err := flate.NewWriter(nil, level); err != nil {
+		panic(err)
+	}
+	return NewCompressor("deflate", func(w io.Writer) CompressWriter {
+		writer, _ := flate.NewWriter(w, level)
+		return writer
+	})
+}
+
+// BrotliCompressor creates a Compressor for the br content coding with
+// compression level, from brotli.BestSpeed to brotli.BestCompression. It
+// panics if level is invalid.
+func BrotliCompressor(level int) *Compressor {
+	if level < brotli.BestSpeed || level > brotli.BestCompression {
+		panic(fmt.Sprintf("invalid brotli compression level: %d", level))
+	}
+	return NewCompressor("br", func(w io.Writer) CompressWriter {
+		return brotli.NewWriterLevel(w, level)
+	})
+}
+
+type compressionConfig struct {
+	minSize     int
+	compressors []*Compressor
+}
+
+// WithCompression compresses responses of at least minSize bytes with the
+// content codings accepted by the client's Accept-Encoding header, so large
+// results are compressed as they are written, without buffering them in a
+// middleware. Smaller responses are not worth compressing, and are sent as
+// is. A minSize of 0 uses DefaultCompressionMinSize.
+//
+// Clients accepting several content codings equally get the first of
+// compressors. If no compressors are given, responses are compressed with
+// brotli, gzip, or deflate.
+func WithCompression(minSize int, compressors ...*Compressor) HTTPOption {
+	if minSize <= 0 {
+		minSize = DefaultCompressionMinSize
+	}
+	if len(compressors) == 0 {
+		compressors = []*Compressor{
+			BrotliCompressor(brotli.DefaultCompression),
+			GzipCompressor(gzip.DefaultCompression),
+			DeflateCompressor(flate.DefaultCompression),
+		}
+	}
+	return func(h *httpHandler) {
+		h.compression = &compressionConfig{
+			minSize:     minSize,
+			compressors: compressors,
+		}
+	}
+}
+
+// negotiate returns the compressor preferred by the Accept-Encoding header
+// of r, or nil if r accepts none of them.
+func (c *compressionConfig) negotiate(r *http.Request) *Compressor {
+	header := strings.Join(r.Header["Accept-Encoding"], ",")
+	if header == "" {
+		return nil
+	}
+
+	// Parse the weights of the codings, such as "gzip;q=0.8, br".
+	weights := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(params[0]))
+		if coding == "" {
+			continue
+		}
+		weight := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") || strings.HasPrefix(param, "Q=") {
+				q, err := strconv.ParseFloat(param[2:], 64)
+				if err != nil {
+					q = 0
+				}
+				weight = q
+			}
+		}
+		weights[coding] = weight
+	}
+
+	var best *Compressor
+	bestWeight := 0.0
+	for _, compressor := range c.compressors {
+		weight, ok := weights[compressor.Encoding]
+		if !ok {
+			weight, ok = weights["*"]
+		}
+		if ok && weight > bestWeight {
+			best, bestWeight = compressor, weight
+		}
+	}
+	return best
+}
+
+// compressResponseWriter compresses the response once it reaches the minimum
+// size. Until then, it holds the response and its status.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	compressor *Compressor
+	minSize    int
+
+	status int
+	buf    *bytes.Buffer
+	// writer is the compressing writer, once the response is compressed.
+	writer CompressWriter
+	// passthrough is true once the response is sent uncompressed.
+	passthrough bool
+}
+
+// compress wraps w to compress the response to r, if r accepts compressed
+// responses. The returned writer must be closed once the response is
+// complete.
+func (c *compressionConfig) compress(w http.ResponseWriter, r *http.Request) *compressResponseWriter {
+	// Responses depend on the Accept-Encoding header, whether or not they
+	// are compressed.
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	compressor := c.negotiate(r)
+	if compressor == nil {
+		return nil
+	}
+	buf := jsonBufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return &compressResponseWriter{
+		ResponseWriter: w,
+		compressor:     compressor,
+		minSize:        c.minSize,
+		status:         http.StatusOK,
+		buf:            buf,
+	}
+}
+
+func (w *compressResponseWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *compressResponseWriter) Write(p []byte) (int, error) {
+	if w.writer != nil {
+		return w.writer.Write(p)
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(p)
+	}
+
+	w.buf.Write(p)
+	if w.buf.Len() >= w.minSize {
+		if err := w.start(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// start sends the header and the held response, compressed unless the
+// response already has a content coding.
+func (w *compressResponseWriter) start() error {
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" {
+		w.passthrough = true
+	} else {
+		header.Set("Content-Encoding", w.compressor.Encoding)
+		header.Del("Content-Length")
+		w.writer = w.compressor.pool.Get().(CompressWriter)
+		w.writer.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+
+	var err error
+	if w.writer != nil {
+		_, err = w.writer.Write(w.buf.Bytes())
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf.Bytes())
+	}
+	w.releaseBuffer()
+	return err
+}
+
+func (w *compressResponseWriter) releaseBuffer() {
+	jsonBufferPool.Put(w.buf)
+	w.buf = nil
+}
+
+// Close completes the response. Responses smaller than the minimum size are
+// sent uncompressed.
+func (w *compressResponseWriter) Close() error {
+	if w.writer != nil {
+		err := w.writer.Close()
+		w.compressor.pool.Put(w.writer)
+		w.writer = nil
+		return err
+	}
+	if w.passthrough {
+		return nil
+	}
+
+	w.passthrough = true
+	w.ResponseWriter.WriteHeader(w.status)
+	_, err := w.ResponseWriter.Write(w.buf.Bytes())
+	w.releaseBuffer()
+	return err
+}
+
+// closeCompression completes a compressed response, logging errors, which
+// can no longer be reported to the client.
+func closeCompression(w *compressResponseWriter) {
+	if err := w.Close(); err != nil {
+		log.Printf("graphql: compressing response: %v", err)
+	}
+}
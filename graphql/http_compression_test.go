@@ -0,0 +1,128 @@
+package graphql_test
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+)
+
+func compressionSchema() *graphql.Schema {
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("values", func(args struct{ N int64 }) []string {
+		values := make([]string, args.N)
+		for i := range values {
+			values[i] = "value"
+		}
+		return values
+	})
+	schema.Mutation()
+	return schema.MustBuild()
+}
+
+func serveCompressed(t *testing.T, handler http.Handler, n int, acceptEncoding string) *httptest.ResponseRecorder {
+	body := `{"query": "{ values(n: ` + strconv.Itoa(n) + `) }"}`
+	req, err := http.NewRequest("POST", "/graphql", strings.NewReader(body))
+	require.NoError(t, err)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestHTTPCompression(t *testing.T) {
+	schema := compressionSchema()
+	handler := graphql.NewHTTPHandler(schema, graphql.WithCompression(0))
+	uncompressed := serveCompressed(t, graphql.NewHTTPHandler(schema), 1000, "gzip").Body.String()
+
+	// Large responses are compressed with the preferred coding.
+	rr := serveCompressed(t, handler, 1000, "gzip, deflate")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", rr.Header().Get("Vary"))
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.True(t, rr.Body.Len() < len(uncompressed))
+	reader, err := gzip.NewReader(rr.Body)
+	require.NoError(t, err)
+	decompressed, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, uncompressed, string(decompressed))
+
+	rr = serveCompressed(t, handler, 1000, "gzip;q=0.5, deflate")
+	assert.Equal(t, "deflate", rr.Header().Get("Content-Encoding"))
+	decompressed, err = ioutil.ReadAll(flate.NewReader(rr.Body))
+	require.NoError(t, err)
+	assert.Equal(t, uncompressed, string(decompressed))
+
+	// Brotli is preferred, and pooled writers are reset between responses.
+	for _, acceptEncoding := range []string{"gzip, deflate, br", "br", "*"} {
+		rr = serveCompressed(t, handler, 1000, acceptEncoding)
+		assert.Equal(t, "br", rr.Header().Get("Content-Encoding"))
+		assert.True(t, rr.Body.Len() < len(uncompressed))
+		decompressed, err = ioutil.ReadAll(brotli.NewReader(rr.Body))
+		require.NoError(t, err)
+		assert.Equal(t, uncompressed, string(decompressed))
+	}
+	for i := 0; i < 3; i++ {
+		rr = serveCompressed(t, handler, 1000, "gzip")
+		assert.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+		reader, err := gzip.NewReader(rr.Body)
+		require.NoError(t, err)
+		decompressed, err := ioutil.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, uncompressed, string(decompressed))
+	}
+
+	// Small responses, and clients that do not accept compression, get
+	// uncompressed responses.
+	for _, c := range []struct {
+		n              int
+		acceptEncoding string
+	}{
+		{1, "gzip"},
+		{1000, ""},
+		{1000, "gzip;q=0, deflate;q=0"},
+		{1000, "identity"},
+	} {
+		rr = serveCompressed(t, handler, c.n, c.acceptEncoding)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "", rr.Header().Get("Content-Encoding"), "Accept-Encoding %q", c.acceptEncoding)
+		assert.Equal(t, "Accept-Encoding", rr.Header().Get("Vary"))
+		assert.True(t, strings.HasPrefix(rr.Body.String(), `{"data":{"values":["value"`))
+	}
+}
+
+func TestHTTPCompressionCustomCompressor(t *testing.T) {
+	custom := graphql.NewCompressor("x-custom", func(w io.Writer) graphql.CompressWriter {
+		return gzip.NewWriter(w)
+	})
+	handler := graphql.NewHTTPHandler(compressionSchema(), graphql.WithCompression(10, custom))
+
+	rr := serveCompressed(t, handler, 10, "gzip, x-custom")
+	assert.Equal(t, "x-custom", rr.Header().Get("Content-Encoding"))
+	reader, err := gzip.NewReader(rr.Body)
+	require.NoError(t, err)
+	decompressed, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(decompressed), `{"data":{"values":["value"`))
+
+	rr = serveCompressed(t, handler, 10, "gzip")
+	assert.Equal(t, "", rr.Header().Get("Content-Encoding"))
+
+	assert.Panics(t, func() { graphql.GzipCompressor(42) })
+	assert.Panics(t, func() { graphql.BrotliCompressor(42) })
+}
@@ -0,0 +1,288 @@
+package graphql
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTPOption configures a handler created with NewHTTPHandler.
+type HTTPOption func(*httpHandler)
+
+// NewHTTPHandler creates a handler like HTTPHandler, configured with opts.
+// Unlike HTTPHandler, it can enforce content types and body size limits,
+// reject cross-site request forgery attempts, and answer CORS requests.
+func NewHTTPHandler(schema *Schema, opts ...HTTPOption) http.Handler {
+	h := &httpHandler{
+		schema:   schema,
+		executor: NewExecutor(NewImmediateGoroutineScheduler()),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.cors != nil && len(h.cors.AllowedHeaders) == 0 {
+		cors := *h.cors
+		cors.AllowedHeaders = append([]string{"Content-Type"}, h.csrfHeaders...)
+		h.cors = &cors
+	}
+	return h
+}
+
+// WithHTTPMiddlewares runs middlewares for every query.
+func WithHTTPMiddlewares(middlewares ...MiddlewareFunc) HTTPOption {
+	return func(h *httpHandler) {
+		h.middlewares = append(h.middlewares, middlewares...)
+	}
+}
+
+// WithHTTPExecutor executes queries with executor.
+func WithHTTPExecutor(executor ExecutorRunner) HTTPOption {
+	return func(h *httpHandler) {
+		h.executor = executor
+	}
+}
+
+// WithHTTPFeatureFlags attaches the values of flags to every query, see
+// WithFeatureFlags.
+func WithHTTPFeatureFlags(flags FeatureFlags) HTTPOption {
+	return func(h *httpHandler) {
+		h.featureFlags = flags
+	}
+}
+
+// WithHTTPQueryCache parses queries with cache, so repeated queries skip
+// parsing, see QueryCache.
+func WithHTTPQueryCache(cache *QueryCache) HTTPOption {
+	return func(h *httpHandler) {
+		h.queryCache = cache
+	}
+}
+
+// WithHTTPJSONMarshal encodes responses with json.Marshal. By default,
+// responses are encoded by a streaming encoder that produces the same output
+// with fewer allocations, and writes large responses in chunks.
+func WithHTTPJSONMarshal() HTTPOption {
+	return func(h *httpHandler) {
+		h.jsonMarshal = true
+	}
+}
+
+// WithSchemaHash sets the SchemaHashHeader header and the schemaHash
+// extension of every response to the hash of the schema, see SchemaHash, so
+// clients can detect schema changes without fetching the schema.
+func WithSchemaHash() HTTPOption {
+	return func(h *httpHandler) {
+		h.withSchemaHash = true
+		if h.schema != nil {
+			h.schemaHash = SchemaHash(h.schema)
+		}
+	}
+}
+
+// WithMaxBodyBytes rejects requests with a body larger than maxBytes with a
+// 413 status.
+func WithMaxBodyBytes(maxBytes int64) HTTPOption {
+	return func(h *httpHandler) {
+		h.maxBodyBytes = maxBytes
+	}
+}
+
+// WithStrictContentType rejects requests with a 415 status unless their
+// Content-Type is application/json, optionally with a utf-8 charset, or
+// multipart/form-data with a boundary.
+func WithStrictContentType() HTTPOption {
+	return func(h *httpHandler) {
+		h.strictContentType = true
+	}
+}
+
+// DefaultCSRFHeaders are the headers accepted by WithCSRFPrevention if none
+// are given.
+var DefaultCSRFHeaders = []string{"GraphQL-Require-Preflight", "X-Requested-With"}
+
+// WithCSRFPrevention rejects requests that a browser would send cross-site
+// without a CORS preflight, with a 400 status. Such "simple requests" have no
+// Content-Type, or one of text/plain, application/x-www-form-urlencoded and
+// multipart/form-data. They are only accepted if they set one of headers,
+// which browsers never allow cross-site without a preflight. If no headers
+// are given, DefaultCSRFHeaders are used.
+func WithCSRFPrevention(headers ...string) HTTPOption {
+	if len(headers) == 0 {
+		headers = DefaultCSRFHeaders
+	}
+	return func(h *httpHandler) {
+		h.csrfHeaders = headers
+	}
+}
+
+// CORSConfig configures the CORS headers set by WithCORS.
+type CORSConfig struct {
+	// AllowedOrigins lists the origins allowed to send requests. An origin
+	// of "*" allows any origin, but only without credentials.
+	AllowedOrigins []string
+	// AllowedHeaders lists the request headers allowed in requests. If empty,
+	// Content-Type and the WithCSRFPrevention headers are allowed.
+	AllowedHeaders []string
+	// AllowCredentials allows requests from the origins listed by name to
+	// include cookies. Origins only allowed by "*" never get credentials, as
+	// any site could then read the responses of its visitors.
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache preflight responses.
+	MaxAge time.Duration
+}
+
+// WithCORS answers CORS preflight requests, and allows the configured origins
+// to read responses.
+func WithCORS(config CORSConfig) HTTPOption {
+	return func(h *httpHandler) {
+		h.cors = &config
+	}
+}
+
+// allowsOrigin returns whether origin may send requests, and whether it is
+// listed by name rather than only allowed by "*".
+func (c *CORSConfig) allowsOrigin(origin string) (allowed bool, named bool) {
+	for _, o := range c.AllowedOrigins {
+		if o == origin {
+			return true, true
+		}
+		if o == "*" {
+			allowed = true
+		}
+	}
+	return allowed, false
+}
+
+// serveCORS sets the CORS headers for r, and returns true if r was a preflight
+// request that has been answered.
+func (c *CORSConfig) serveCORS(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	preflight := r.Method == "OPTIONS" && r.Header.Get("Access-Control-Request-Method") != ""
+
+	w.Header().Add("Vary", "Origin")
+	if allowed, named := c.allowsOrigin(origin); origin != "" && allowed {
+		if named {
+			// Echo the origin rather than "*", which browsers reject for
+			// requests with credentials.
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			if c.AllowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		}
+		if preflight {
+			w.Header().Set("Access-Control-Allow-Methods", "POST")
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(c.AllowedHeaders, ", "))
+			if c.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge/time.Second)))
+			}
+		}
+	}
+
+	if preflight {
+		w.WriteHeader(http.StatusNoContent)
+	}
+	return preflight
+}
+
+// checkCSRF returns an error if r is a simple request without any of the
+// CSRF prevention headers.
+func (h *httpHandler) checkCSRF(r *http.Request) error {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err == nil {
+		switch mediaType {
+		case "text/plain", "application/x-www-form-urlencoded", "multipart/form-data":
+		default:
+			// Browsers preflight other content types.
+			return nil
+		}
+	}
+
+	for _, header := range h.csrfHeaders {
+		if r.Header.Get(header) != "" {
+			return nil
+		}
+	}
+	return errors.New("request must set a Content-Type of application/json or one of the headers " + strings.Join(h.csrfHeaders, ", "))
+}
+
+// checkContentType returns an error unless r has a JSON or multipart
+// Content-Type.
+func checkContentType(r *http.Request) error {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err == nil {
+		switch mediaType {
+		case "application/json":
+			if charset, ok := params["charset"]; !ok || strings.EqualFold(charset, "utf-8") {
+				return nil
+			}
+		case "multipart/form-data":
+			if params["boundary"] != "" {
+				return nil
+			}
+		}
+	}
+	return errors.New("request must have a Content-Type of application/json or multipart/form-data")
+}
+
+// parseParams decodes the query and variables from the body of r. Multipart
+// requests, as sent by GraphQL upload clients, hold them in an "operations"
+// part.
+func parseParams(r *http.Request, params *httpPostBody) error {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return json.NewDecoder(r.Body).Decode(params)
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return err
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return errors.New("multipart request must include an operations part")
+		}
+		if err != nil {
+			return err
+		}
+		if part.FormName() == "operations" {
+			return json.NewDecoder(part).Decode(params)
+		}
+	}
+}
+
+// limitedBody is a request body that fails once more than remaining bytes
+// are read.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	exceeded  bool
+}
+
+var errRequestTooLarge = errors.New("request body too large")
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.exceeded {
+		return 0, errRequestTooLarge
+	}
+	// Read one byte more than allowed to detect bodies that are too large.
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) <= b.remaining {
+		b.remaining -= int64(n)
+		return n, err
+	}
+	n = int(b.remaining)
+	b.remaining = 0
+	b.exceeded = true
+	return n, errRequestTooLarge
+}
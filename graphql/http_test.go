@@ -1,10 +1,13 @@
 package graphql_test
 
 import (
+	"bytes"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/kylelemons/godebug/pretty"
 
@@ -113,3 +116,248 @@ func TestHTTPContentType(t *testing.T) {
 		t.Errorf("expected response to match, but received %s", diff)
 	}
 }
+
+func testHardenedHTTPRequest(req *http.Request, opts ...graphql.HTTPOption) *httptest.ResponseRecorder {
+	schema := schemabuilder.NewSchema()
+
+	query := schema.Query()
+	query.FieldFunc("mirror", func(args struct{ Value int64 }) int64 {
+		return args.Value * -1
+	})
+
+	rr := httptest.NewRecorder()
+	handler := graphql.NewHTTPHandler(schema.MustBuild(), opts...)
+
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestHTTPStrictContentType(t *testing.T) {
+	for _, tc := range []struct {
+		contentType string
+		code        int
+	}{
+		{"application/json", http.StatusOK},
+		{"application/json; charset=UTF-8", http.StatusOK},
+		{"application/json; charset=latin1", http.StatusUnsupportedMediaType},
+		{"text/plain", http.StatusUnsupportedMediaType},
+		{"multipart/form-data", http.StatusUnsupportedMediaType},
+		{"", http.StatusUnsupportedMediaType},
+	} {
+		req, err := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "{ mirror(value: 1) }"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", tc.contentType)
+
+		rr := testHardenedHTTPRequest(req, graphql.WithStrictContentType())
+		if rr.Code != tc.code {
+			t.Errorf("expected %d for %q, but received %d", tc.code, tc.contentType, rr.Code)
+		}
+	}
+}
+
+func TestHTTPMultipart(t *testing.T) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	if err := writer.WriteField("operations", `{"query": "{ mirror(value: 2) }"}`); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("POST", "/graphql", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("GraphQL-Require-Preflight", "true")
+
+	rr := testHardenedHTTPRequest(req, graphql.WithStrictContentType(), graphql.WithCSRFPrevention())
+	if diff := pretty.Compare(rr.Body.String(), "{\"data\":{\"mirror\":-2},\"errors\":null}"); diff != "" {
+		t.Errorf("expected response to match, but received %s", diff)
+	}
+}
+
+func TestHTTPCSRFPrevention(t *testing.T) {
+	for _, tc := range []struct {
+		contentType string
+		header      string
+		code        int
+	}{
+		{"application/json", "", http.StatusOK},
+		{"text/plain", "", http.StatusBadRequest},
+		{"", "", http.StatusBadRequest},
+		{"application/x-www-form-urlencoded", "", http.StatusBadRequest},
+		{"text/plain", "X-Requested-With", http.StatusOK},
+	} {
+		req, err := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "{ mirror(value: 1) }"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.contentType != "" {
+			req.Header.Set("Content-Type", tc.contentType)
+		}
+		if tc.header != "" {
+			req.Header.Set(tc.header, "1")
+		}
+
+		rr := testHardenedHTTPRequest(req, graphql.WithCSRFPrevention())
+		if rr.Code != tc.code {
+			t.Errorf("expected %d for %q with %q, but received %d", tc.code, tc.contentType, tc.header, rr.Code)
+		}
+	}
+}
+
+func TestHTTPMaxBodyBytes(t *testing.T) {
+	body := `{"query": "{ mirror(value: 1) }"}`
+
+	req, err := http.NewRequest("POST", "/graphql", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := testHardenedHTTPRequest(req, graphql.WithMaxBodyBytes(int64(len(body))))
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected 200, but received %d", rr.Code)
+	}
+
+	req, err = http.NewRequest("POST", "/graphql", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = testHardenedHTTPRequest(req, graphql.WithMaxBodyBytes(int64(len(body)-1)))
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413, but received %d", rr.Code)
+	}
+	if diff := pretty.Compare(rr.Body.String(), "{\"data\":null,\"errors\":[\"request body too large\"]}"); diff != "" {
+		t.Errorf("expected response to match, but received %s", diff)
+	}
+}
+
+func TestHTTPCORS(t *testing.T) {
+	cors := graphql.WithCORS(graphql.CORSConfig{
+		AllowedOrigins: []string{"https://example.com"},
+		MaxAge:         time.Hour,
+	})
+
+	req, err := http.NewRequest("OPTIONS", "/graphql", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Origin", "https://example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+
+	rr := testHardenedHTTPRequest(req, cors, graphql.WithCSRFPrevention())
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("expected 204, but received %d", rr.Code)
+	}
+	if diff := pretty.Compare(map[string]string{
+		"origin":  rr.HeaderMap.Get("Access-Control-Allow-Origin"),
+		"methods": rr.HeaderMap.Get("Access-Control-Allow-Methods"),
+		"headers": rr.HeaderMap.Get("Access-Control-Allow-Headers"),
+		"maxAge":  rr.HeaderMap.Get("Access-Control-Max-Age"),
+	}, map[string]string{
+		"origin":  "https://example.com",
+		"methods": "POST",
+		"headers": "Content-Type, GraphQL-Require-Preflight, X-Requested-With",
+		"maxAge":  "3600",
+	}); diff != "" {
+		t.Errorf("expected headers to match, but received %s", diff)
+	}
+
+	req, err = http.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "{ mirror(value: 1) }"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Origin", "https://evil.com")
+	rr = testHardenedHTTPRequest(req, cors)
+	if rr.HeaderMap.Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("expected no allowed origin, but received %q", rr.HeaderMap.Get("Access-Control-Allow-Origin"))
+	}
+	if diff := pretty.Compare(rr.Body.String(), "{\"data\":{\"mirror\":-1},\"errors\":null}"); diff != "" {
+		t.Errorf("expected response to match, but received %s", diff)
+	}
+}
+
+func TestHTTPCORSWildcardCredentials(t *testing.T) {
+	cors := graphql.WithCORS(graphql.CORSConfig{
+		AllowedOrigins:   []string{"https://example.com", "*"},
+		AllowCredentials: true,
+	})
+
+	for origin, expected := range map[string]map[string]string{
+		// Named origins get credentials.
+		"https://example.com": {"origin": "https://example.com", "credentials": "true"},
+		// Any other origin may only send requests without credentials.
+		"https://evil.com": {"origin": "*", "credentials": ""},
+	} {
+		req, err := http.NewRequest("OPTIONS", "/graphql", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", "POST")
+
+		rr := testHardenedHTTPRequest(req, cors, graphql.WithCSRFPrevention())
+		if diff := pretty.Compare(map[string]string{
+			"origin":      rr.HeaderMap.Get("Access-Control-Allow-Origin"),
+			"credentials": rr.HeaderMap.Get("Access-Control-Allow-Credentials"),
+		}, expected); diff != "" {
+			t.Errorf("expected headers of %s to match, but received %s", origin, diff)
+		}
+	}
+}
+
+func TestHTTPSchemaHash(t *testing.T) {
+	req, err := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "{ mirror(value: 1) }"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := testHardenedHTTPRequest(req, graphql.WithSchemaHash())
+
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("mirror", func(args struct{ Value int64 }) int64 {
+		return args.Value * -1
+	})
+	hash := graphql.SchemaHash(schema.MustBuild())
+
+	if rr.HeaderMap.Get(graphql.SchemaHashHeader) != hash {
+		t.Errorf("expected schema hash %q, but received %q", hash, rr.HeaderMap.Get(graphql.SchemaHashHeader))
+	}
+	if diff := pretty.Compare(rr.Body.String(), "{\"data\":{\"mirror\":-1},\"errors\":null,\"extensions\":{\"schemaHash\":\""+hash+"\"}}"); diff != "" {
+		t.Errorf("expected response to match, but received %s", diff)
+	}
+}
+
+func TestHTTPJSONMarshal(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("values", func() []string {
+		return []string{"<a & b>", "\u2028", "plain"}
+	})
+	schema.Query().FieldFunc("when", func() time.Time {
+		return time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	})
+	builtSchema := schema.MustBuild()
+
+	// The streaming encoder and json.Marshal produce the same responses.
+	var bodies []string
+	for _, opts := range [][]graphql.HTTPOption{nil, {graphql.WithHTTPJSONMarshal()}} {
+		req, err := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "{ values when }"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		graphql.NewHTTPHandler(builtSchema, opts...).ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Errorf("expected 200, but received %d", rr.Code)
+		}
+		if contentType := rr.Header().Get("Content-Type"); contentType != "application/json" {
+			t.Errorf("expected application/json, but received %s", contentType)
+		}
+		bodies = append(bodies, rr.Body.String())
+	}
+	if diff := pretty.Compare(bodies[0], bodies[1]); diff != "" {
+		t.Errorf("expected responses to match, but received %s", diff)
+	}
+}
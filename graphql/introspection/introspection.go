@@ -123,6 +123,21 @@ var skipDirective = Directive{
 	},
 }
 
+var throttleDirective = Directive{
+	Description: "Directs the executor to update this field of a subscription at most every `ms` milliseconds.",
+	Locations: []DirectiveLocation{
+		FIELD,
+	},
+	Name: "throttle",
+	Args: []InputValue{
+		InputValue{
+			Name:        "ms",
+			Type:        Type{Inner: &graphql.NonNull{Type: &graphql.Scalar{Type: "int64"}}},
+			Description: "Minimum interval between updates, in milliseconds.",
+		},
+	},
+}
+
 func (s *introspection) registerType(schema *schemabuilder.Schema) {
 	object := schema.Object("__Type", Type{})
 	object.FieldFunc("kind", func(t Type) TypeKind {
@@ -174,13 +189,26 @@ func (s *introspection) registerType(schema *schemabuilder.Schema) {
 		}
 	})
 
+	// keyFields is an extension listing the key fields of objects, see
+	// schemabuilder.Object.Key.
+	object.FieldFunc("keyFields", func(t Type) []string {
+		switch t := t.Inner.(type) {
+		case *graphql.Object:
+			return t.KeyFields
+		default:
+			return nil
+		}
+	})
+
 	object.FieldFunc("interfaces", func() []Type { return nil })
-	object.FieldFunc("possibleTypes", func(t Type) []Type {
+	object.FieldFunc("possibleTypes", func(ctx context.Context, t Type) []Type {
 		switch t := t.Inner.(type) {
 		case *graphql.Union:
 			types := make([]Type, 0, len(t.Types))
 			for _, typ := range t.Types {
-				types = append(types, Type{Inner: typ})
+				if graphql.IsTypeVisible(ctx, typ) {
+					types = append(types, Type{Inner: typ})
+				}
 			}
 
 			sort.Slice(types, func(i, j int) bool { return types[i].Inner.String() < types[j].Inner.String() })
@@ -190,12 +218,15 @@ func (s *introspection) registerType(schema *schemabuilder.Schema) {
 		}
 	})
 
-	object.FieldFunc("inputFields", func(t Type) []InputValue {
+	object.FieldFunc("inputFields", func(ctx context.Context, t Type) []InputValue {
 		var fields []InputValue
 
 		switch t := t.Inner.(type) {
 		case *graphql.InputObject:
 			for name, f := range t.InputFields {
+				if !graphql.IsInputFieldVisible(ctx, t, name) {
+					continue
+				}
 				fields = append(fields, InputValue{
 					Name: name,
 					Type: Type{Inner: f},
@@ -207,7 +238,7 @@ func (s *introspection) registerType(schema *schemabuilder.Schema) {
 		return fields
 	})
 
-	object.FieldFunc("fields", func(t Type, args struct {
+	object.FieldFunc("fields", func(ctx context.Context, t Type, args struct {
 		IncludeDeprecated *bool
 	}) []field {
 		var fields []field
@@ -215,6 +246,9 @@ func (s *introspection) registerType(schema *schemabuilder.Schema) {
 		switch t := t.Inner.(type) {
 		case *graphql.Object:
 			for name, f := range t.Fields {
+				if !graphql.IsObjectFieldVisible(ctx, t, name) {
+					continue
+				}
 				var args []InputValue
 				for name, a := range f.Args {
 					args = append(args, InputValue{
@@ -225,9 +259,11 @@ func (s *introspection) registerType(schema *schemabuilder.Schema) {
 				sort.Slice(args, func(i, j int) bool { return args[i].Name < args[j].Name })
 
 				fields = append(fields, field{
-					Name: name,
-					Type: Type{Inner: f.Type},
-					Args: args,
+					Name:              name,
+					Type:              Type{Inner: f.Type},
+					Args:              args,
+					IsDeprecated:      f.DeprecationReason != "",
+					DeprecationReason: f.DeprecationReason,
 				})
 			}
 		}
@@ -256,8 +292,15 @@ func (s *introspection) registerType(schema *schemabuilder.Schema) {
 			var enumVals []EnumValue
 			for k, v := range t.ReverseMap {
 				val := fmt.Sprintf("%v", k)
+				reason, deprecated := t.Deprecations[v]
 				enumVals = append(enumVals,
-					EnumValue{Name: v, Description: val, IsDeprecated: false, DeprecationReason: ""})
+					EnumValue{Name: v, Description: val, IsDeprecated: deprecated, DeprecationReason: reason})
+			}
+			if args.IncludeDeprecated != nil && *args.IncludeDeprecated {
+				for alias, name := range t.Aliases {
+					enumVals = append(enumVals,
+						EnumValue{Name: alias, Description: name, IsDeprecated: true, DeprecationReason: fmt.Sprintf("Use %s.", name)})
+				}
 			}
 			sort.Slice(enumVals, func(i, j int) bool { return enumVals[i].Name < enumVals[j].Name })
 			return enumVals
@@ -337,11 +380,13 @@ func collectTypes(typ graphql.Type, types map[string]graphql.Type) {
 func (s *introspection) registerQuery(schema *schemabuilder.Schema) {
 	object := schema.Query()
 
-	object.FieldFunc("__schema", func() *Schema {
+	object.FieldFunc("__schema", func(ctx context.Context) *Schema {
 		var types []Type
 
 		for _, typ := range s.types {
-			types = append(types, Type{Inner: typ})
+			if graphql.IsTypeVisible(ctx, typ) {
+				types = append(types, Type{Inner: typ})
+			}
 		}
 		sort.Slice(types, func(i, j int) bool { return types[i].Inner.String() < types[j].Inner.String() })
 
@@ -349,12 +394,12 @@ func (s *introspection) registerQuery(schema *schemabuilder.Schema) {
 			Types:        types,
 			QueryType:    &Type{Inner: s.query},
 			MutationType: &Type{Inner: s.mutation},
-			Directives:   []Directive{includeDirective, skipDirective},
+			Directives:   []Directive{includeDirective, skipDirective, throttleDirective},
 		}
 	})
 
-	object.FieldFunc("__type", func(args struct{ Name string }) *Type {
-		if typ, ok := s.types[args.Name]; ok {
+	object.FieldFunc("__type", func(ctx context.Context, args struct{ Name string }) *Type {
+		if typ, ok := s.types[args.Name]; ok && graphql.IsTypeVisible(ctx, typ) {
 			return &Type{Inner: typ}
 		}
 		return nil
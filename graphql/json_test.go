@@ -0,0 +1,104 @@
+package graphql_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+)
+
+type jsonSettings struct {
+	Name   string
+	Values map[string]interface{}
+}
+
+func TestJSONScalar(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("settings", func() *jsonSettings {
+		return &jsonSettings{
+			Name: "alice",
+			Values: map[string]interface{}{
+				"theme":  "dark",
+				"limits": map[string]int{"daily": 5},
+				"tags":   []string{"a", "b"},
+				"raw":    json.RawMessage(`{"nested":true}`),
+			},
+		}
+	})
+	query.FieldFunc("raw", func() json.RawMessage {
+		return json.RawMessage(`[1, "two", null]`)
+	})
+	query.FieldFunc("ids", func() map[string]interface{} {
+		return map[string]interface{}{
+			"signed":   int64(1<<53 + 1),
+			"unsigned": uint64(1<<64 - 1),
+			"raw":      json.RawMessage(`[9007199254740993, 18446744073709551615, 1.5]`),
+		}
+	})
+	query.FieldFunc("empty", func() map[string]interface{} {
+		return nil
+	})
+	query.FieldFunc("invalid", func() map[string]interface{} {
+		return map[string]interface{}{"ids": map[int]string{1: "one"}}
+	})
+	query.FieldFunc("echo", func(args struct {
+		Object map[string]interface{}
+		Value  json.RawMessage
+	}) map[string]interface{} {
+		return map[string]interface{}{"object": args.Object, "value": args.Value}
+	})
+	handler := graphql.HTTPHandler(schema.MustBuild())
+
+	serve := func(query string) string {
+		body, err := json.Marshal(map[string]interface{}{"query": query})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, err := http.NewRequest("POST", "/graphql", strings.NewReader(string(body)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Body.String()
+	}
+
+	cases := []struct {
+		query    string
+		expected string
+	}{
+		{
+			query:    `{ settings { name values } raw empty }`,
+			expected: `{"data":{"empty":null,"raw":[1,"two",null],"settings":{"name":"alice","values":{"limits":{"daily":5},"raw":{"nested":true},"tags":["a","b"],"theme":"dark"}}},"errors":null}`,
+		},
+		{
+			// Integers above 2^53 keep their precision.
+			query:    `{ ids }`,
+			expected: `{"data":{"ids":{"raw":[9007199254740993,18446744073709551615,1.5],"signed":9007199254740993,"unsigned":18446744073709551615}},"errors":null}`,
+		},
+		{
+			query:    `{ invalid }`,
+			expected: `{"data":null,"errors":["invalid: key ids: JSON object keys must be strings, not int"]}`,
+		},
+		{
+			query:    `{ echo(object: {a: [1, {b: "c"}]}, value: "text") }`,
+			expected: `{"data":{"echo":{"object":{"a":[1,{"b":"c"}]},"value":"text"}},"errors":null}`,
+		},
+		{
+			query:    `{ echo(object: [1], value: 1) }`,
+			expected: `{"data":null,"errors":["error parsing args for \"echo\": object: not a JSON object"]}`,
+		},
+	}
+	for _, c := range cases {
+		if diff := pretty.Compare(serve(c.query), c.expected); diff != "" {
+			t.Errorf("expected response for %s to match, but received %s", c.query, diff)
+		}
+	}
+}
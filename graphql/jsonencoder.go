@@ -0,0 +1,274 @@
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+)
+
+// jsonFlushThreshold is the size above which the JSON encoder writes its
+// buffer to the underlying writer.
+const jsonFlushThreshold = 32 << 10
+
+// jsonBufferPool holds buffers reused by JSON encoders.
+var jsonBufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// jsonEncoder writes execution results as JSON to w, producing the same
+// output as json.Marshal. Unlike json.Marshal, it does not reflect on maps
+// and slices of the types returned by the executor, and writes large results
+// to w in chunks instead of holding all of them in memory.
+type jsonEncoder struct {
+	w       io.Writer
+	buf     *bytes.Buffer
+	scratch [64]byte
+	// flushed is true once part of the output was written to w.
+	flushed bool
+	// keys is reused to sort the keys of maps.
+	keys []string
+}
+
+func newJSONEncoder(w io.Writer) *jsonEncoder {
+	buf := jsonBufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return &jsonEncoder{w: w, buf: buf}
+}
+
+// release returns the encoder's buffer to the pool. The encoder must not be
+// used afterwards.
+func (e *jsonEncoder) release() {
+	// Keep huge buffers from staying alive in the pool.
+	if e.buf.Cap() <= 1<<20 {
+		jsonBufferPool.Put(e.buf)
+	}
+	e.buf = nil
+}
+
+// maybeFlush writes the buffer to w once it exceeds jsonFlushThreshold.
+func (e *jsonEncoder) maybeFlush() error {
+	if e.buf.Len() < jsonFlushThreshold {
+		return nil
+	}
+	return e.flush()
+}
+
+// flush writes the buffer to w.
+func (e *jsonEncoder) flush() error {
+	if e.buf.Len() == 0 {
+		return nil
+	}
+	e.flushed = true
+	_, err := e.w.Write(e.buf.Bytes())
+	e.buf.Reset()
+	return err
+}
+
+func (e *jsonEncoder) encode(v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		e.buf.WriteString("null")
+	case bool:
+		if v {
+			e.buf.WriteString("true")
+		} else {
+			e.buf.WriteString("false")
+		}
+	case string:
+		e.encodeString(v)
+	case int:
+		e.buf.Write(strconv.AppendInt(e.scratch[:0], int64(v), 10))
+	case int8:
+		e.buf.Write(strconv.AppendInt(e.scratch[:0], int64(v), 10))
+	case int16:
+		e.buf.Write(strconv.AppendInt(e.scratch[:0], int64(v), 10))
+	case int32:
+		e.buf.Write(strconv.AppendInt(e.scratch[:0], int64(v), 10))
+	case int64:
+		e.buf.Write(strconv.AppendInt(e.scratch[:0], v, 10))
+	case uint:
+		e.buf.Write(strconv.AppendUint(e.scratch[:0], uint64(v), 10))
+	case uint8:
+		e.buf.Write(strconv.AppendUint(e.scratch[:0], uint64(v), 10))
+	case uint16:
+		e.buf.Write(strconv.AppendUint(e.scratch[:0], uint64(v), 10))
+	case uint32:
+		e.buf.Write(strconv.AppendUint(e.scratch[:0], uint64(v), 10))
+	case uint64:
+		e.buf.Write(strconv.AppendUint(e.scratch[:0], v, 10))
+	case float32:
+		return e.encodeFloat(float64(v), 32)
+	case float64:
+		return e.encodeFloat(v, 64)
+	case map[string]interface{}:
+		return e.encodeMap(v)
+	case []interface{}:
+		e.buf.WriteByte('[')
+		for i, elem := range v {
+			if i > 0 {
+				e.buf.WriteByte(',')
+			}
+			if err := e.encode(elem); err != nil {
+				return err
+			}
+		}
+		e.buf.WriteByte(']')
+	default:
+		// Scalars with custom types, such as time.Time, are rare enough to
+		// be encoded by encoding/json.
+		bytes, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		e.buf.Write(bytes)
+	}
+	return e.maybeFlush()
+}
+
+func (e *jsonEncoder) encodeMap(m map[string]interface{}) error {
+	// Sort the keys like json.Marshal. The keys slice is shared by nested
+	// maps, which append their keys after the window of m.
+	start := len(e.keys)
+	for key := range m {
+		e.keys = append(e.keys, key)
+	}
+	keys := e.keys[start:]
+	sort.Strings(keys)
+
+	e.buf.WriteByte('{')
+	for i, key := range keys {
+		if i > 0 {
+			e.buf.WriteByte(',')
+		}
+		e.encodeString(key)
+		e.buf.WriteByte(':')
+		if err := e.encode(m[key]); err != nil {
+			return err
+		}
+	}
+	e.buf.WriteByte('}')
+	e.keys = e.keys[:start]
+	return nil
+}
+
+// encodeFloat encodes f like encoding/json.
+func (e *jsonEncoder) encodeFloat(f float64, bits int) error {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return &json.UnsupportedValueError{Str: strconv.FormatFloat(f, 'g', -1, bits)}
+	}
+
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+	b := strconv.AppendFloat(e.scratch[:0], f, format, -1, bits)
+	if format == 'e' {
+		// Clean up e-09 to e-9.
+		n := len(b)
+		if n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	e.buf.Write(b)
+	return nil
+}
+
+const hexDigits = "0123456789abcdef"
+
+// encodeString encodes s like encoding/json, escaping HTML characters.
+func (e *jsonEncoder) encodeString(s string) {
+	e.buf.WriteByte('"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' && c != '<' && c != '>' && c != '&' {
+				i++
+				continue
+			}
+			e.buf.WriteString(s[start:i])
+			switch c {
+			case '"', '\\':
+				e.buf.WriteByte('\\')
+				e.buf.WriteByte(c)
+			case '\n':
+				e.buf.WriteString(`\n`)
+			case '\r':
+				e.buf.WriteString(`\r`)
+			case '\t':
+				e.buf.WriteString(`\t`)
+			default:
+				e.buf.WriteString(`\u00`)
+				e.buf.WriteByte(hexDigits[c>>4])
+				e.buf.WriteByte(hexDigits[c&0xf])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			e.buf.WriteString(s[start:i])
+			e.buf.WriteString(`\ufffd`)
+			i += size
+			start = i
+			continue
+		}
+		// U+2028 and U+2029 are valid JSON, but not valid JavaScript.
+		if r == '\u2028' || r == '\u2029' {
+			e.buf.WriteString(s[start:i])
+			e.buf.WriteString(`\u202`)
+			e.buf.WriteByte(hexDigits[r&0xf])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	e.buf.WriteString(s[start:])
+	e.buf.WriteByte('"')
+}
+
+// writeHTTPResponse encodes response to w, and returns whether any output was
+// written to w. Errors that happen before any output was written leave w
+// untouched, so the caller can still send an error response; later errors
+// truncate the response.
+func writeHTTPResponse(w io.Writer, response *httpResponse) (bool, error) {
+	e := newJSONEncoder(w)
+	defer e.release()
+
+	e.buf.WriteString(`{"data":`)
+	if err := e.encode(response.Data); err != nil {
+		return e.flushed, err
+	}
+	e.buf.WriteString(`,"errors":`)
+	if response.Errors == nil {
+		e.buf.WriteString("null")
+	} else {
+		e.buf.WriteByte('[')
+		for i, message := range response.Errors {
+			if i > 0 {
+				e.buf.WriteByte(',')
+			}
+			e.encodeString(message)
+		}
+		e.buf.WriteByte(']')
+	}
+	if len(response.Extensions) > 0 {
+		e.buf.WriteString(`,"extensions":`)
+		if err := e.encodeMap(response.Extensions); err != nil {
+			return e.flushed, err
+		}
+	}
+	e.buf.WriteByte('}')
+	return true, e.flush()
+}
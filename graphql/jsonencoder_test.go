@@ -0,0 +1,161 @@
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONEncoder(t *testing.T) {
+	values := []interface{}{
+		nil,
+		true,
+		"plain",
+		"quotes \" and \\ <html> & \n\r\t\x01 \u2028 \u2029 \u00e9",
+		int64(-42),
+		uint32(42),
+		0.0,
+		1.5,
+		float32(0.1),
+		1e-7,
+		1e21,
+		-123456789.125,
+		time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		[]interface{}{},
+		[]string{"fallback"},
+		map[string]interface{}{},
+		map[string]interface{}{
+			"b": []interface{}{int64(1), map[string]interface{}{"z": nil, "y": "x", "c": map[string]interface{}{"d": 1.0, "a": 2.0}}},
+			"a": "first",
+			"c": map[string]int{"nested": 1},
+		},
+	}
+
+	for _, value := range values {
+		expected, err := json.Marshal(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		e := newJSONEncoder(&buf)
+		if err := e.encode(value); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.flush(); err != nil {
+			t.Fatal(err)
+		}
+		e.release()
+		if buf.String() != string(expected) {
+			t.Errorf("encoding %#v: expected %s, got %s", value, expected, buf.String())
+		}
+	}
+
+	// Invalid UTF-8 is replaced with U+FFFD.
+	var buf bytes.Buffer
+	e := newJSONEncoder(&buf)
+	e.encodeString("a\xffb")
+	if err := e.flush(); err != nil {
+		t.Fatal(err)
+	}
+	e.release()
+	if buf.String() != `"a\ufffdb"` {
+		t.Errorf("expected invalid UTF-8 to be replaced, got %s", buf.String())
+	}
+
+	buf.Reset()
+	if _, err := writeHTTPResponse(&buf, &httpResponse{Data: math.NaN()}); err == nil {
+		t.Error("expected error encoding NaN")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected no output, got %s", buf.String())
+	}
+}
+
+func TestWriteHTTPResponse(t *testing.T) {
+	// Large responses are written in multiple chunks.
+	items := make([]interface{}, 2000)
+	for i := range items {
+		items[i] = map[string]interface{}{"id": int64(i), "name": strings.Repeat("x", 50)}
+	}
+	responses := []*httpResponse{
+		{Data: map[string]interface{}{"items": items}},
+		{Errors: []string{"failed <here>"}, Extensions: map[string]interface{}{"cost": int64(3)}},
+	}
+
+	for _, response := range responses {
+		expected, err := json.Marshal(response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := &chunkWriter{}
+		written, err := writeHTTPResponse(w, response)
+		if err != nil || !written {
+			t.Fatalf("expected response to be written, got %v, %v", written, err)
+		}
+		if w.buf.String() != string(expected) {
+			t.Errorf("expected %s, got %s", expected, w.buf.String())
+		}
+		if len(expected) > jsonFlushThreshold && w.chunks < 2 {
+			t.Errorf("expected response of %d bytes in multiple chunks, got %d", len(expected), w.chunks)
+		}
+	}
+}
+
+type chunkWriter struct {
+	buf    bytes.Buffer
+	chunks int
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	w.chunks++
+	return w.buf.Write(p)
+}
+
+func benchmarkResult(n int) *httpResponse {
+	items := make([]interface{}, n)
+	for i := range items {
+		items[i] = map[string]interface{}{
+			"id":       int64(i),
+			"name":     fmt.Sprintf("item %d", i),
+			"score":    float64(i) / 3,
+			"active":   i%2 == 0,
+			"tags":     []interface{}{"a", "b"},
+			"metadata": map[string]interface{}{"created": "2020-01-02", "owner": nil},
+		}
+	}
+	return &httpResponse{Data: map[string]interface{}{"items": items}}
+}
+
+type discardWriter struct{}
+
+func (discardWriter) Write(p []byte) (int, error) { return len(p), nil }
+
+func BenchmarkHTTPResponseEncoding(b *testing.B) {
+	for _, n := range []int{10, 1000} {
+		response := benchmarkResult(n)
+
+		b.Run(fmt.Sprintf("streaming-%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := writeHTTPResponse(discardWriter{}, response); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("json.Marshal-%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				bytes, err := json.Marshal(response)
+				if err != nil {
+					b.Fatal(err)
+				}
+				discardWriter{}.Write(bytes)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package graphql_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/batch"
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/introspection"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+	"github.com/denkhaus/thunder/internal"
+)
+
+type keyedMembership struct {
+	OrgId  int64
+	UserId int64
+	Role   string
+}
+
+type keyedUser struct {
+	Id   int64 `graphql:",key"`
+	Name string
+}
+
+func TestObjectKeys(t *testing.T) {
+	builder := schemabuilder.NewSchema()
+	builder.Object("Membership", keyedMembership{}).Key("orgId", "userId")
+	builder.Object("User", keyedUser{})
+	builder.Query().FieldFunc("memberships", func() []*keyedMembership {
+		return []*keyedMembership{{OrgId: 1, UserId: 2, Role: "admin"}}
+	})
+	builder.Query().FieldFunc("users", func() []*keyedUser {
+		return []*keyedUser{{Id: 3, Name: "alice"}}
+	})
+	schema := builder.MustBuild()
+	introspection.AddIntrospectionToSchema(schema)
+
+	execute := func(query string) interface{} {
+		q := graphql.MustParse(query, nil)
+		require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+		e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+		res, err := e.Execute(context.Background(), schema.Query, nil, q)
+		require.NoError(t, err)
+		return internal.AsJSON(res)
+	}
+
+	assert.Equal(t, internal.ParseJSON(`{
+		"memberships": [{"__key": "[1,2]", "role": "admin"}],
+		"users": [{"__key": 3, "name": "alice"}]
+	}`), execute(`{ memberships { role } users { name } }`))
+
+	assert.Equal(t, internal.ParseJSON(`{
+		"membership": {"keyFields": ["orgId", "userId"]},
+		"user": {"keyFields": ["id"]},
+		"query": {"keyFields": []}
+	}`), execute(`{
+		membership: __type(name: "Membership") { keyFields }
+		user: __type(name: "User") { keyFields }
+		query: __type(name: "Query") { keyFields }
+	}`))
+}
+
+func TestObjectKeyErrors(t *testing.T) {
+	builder := schemabuilder.NewSchema()
+	builder.Object("Membership", keyedMembership{}).Key("orgId", "orgId")
+	builder.Query().FieldFunc("memberships", func() []*keyedMembership { return nil })
+	_, err := builder.Build()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "duplicate key field orgId")
+	}
+
+	builder = schemabuilder.NewSchema()
+	membership := builder.Object("Membership", keyedMembership{})
+	membership.Key("orgId", "version")
+	membership.FieldFunc("version", func(args struct{ Major bool }) int64 { return 1 })
+	builder.Query().FieldFunc("memberships", func() []*keyedMembership { return nil })
+	_, err = builder.Build()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "key field version cannot take arguments")
+	}
+
+	builder = schemabuilder.NewSchema()
+	membership = builder.Object("Membership", keyedMembership{})
+	membership.Key("orgId", "version")
+	membership.BatchFieldFunc("version", func(memberships map[batch.Index]*keyedMembership) map[batch.Index]int64 {
+		return nil
+	})
+	builder.Query().FieldFunc("memberships", func() []*keyedMembership { return nil })
+	_, err = builder.Build()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "compound key field version cannot be a batch field")
+	}
+
+	builder = schemabuilder.NewSchema()
+	builder.Object("Membership", keyedMembership{}).Key("orgId", "userId")
+	builder.Query().FieldFunc("memberships", func(args struct {
+		First *int64
+		After *string
+	}) []*keyedMembership {
+		return nil
+	}, schemabuilder.Paginated)
+	_, err = builder.Build()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "paginated objects must have a single key field")
+	}
+}
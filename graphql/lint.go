@@ -0,0 +1,307 @@
+package graphql
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// This file checks built schemas against style rules, so teams can enforce
+// schema conventions in tests or at startup.
+
+// LintFinding describes a violation of a LintRule.
+type LintFinding struct {
+	// Rule is the name of the violated rule.
+	Rule string `json:"rule"`
+	// Type is the name of the type with the violation.
+	Type string `json:"type"`
+	// Field is the name of the field with the violation, if any.
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+func (f *LintFinding) String() string {
+	if f.Field != "" {
+		return fmt.Sprintf("%s.%s: %s (%s)", f.Type, f.Field, f.Message, f.Rule)
+	}
+	return fmt.Sprintf("%s: %s (%s)", f.Type, f.Message, f.Rule)
+}
+
+// A LintRule checks the named types of a schema.
+type LintRule struct {
+	Name string
+	// Check is called with every named type of the schema, and calls report
+	// for every violation. field is empty for violations of the type itself.
+	Check func(schema *Schema, typ Type, report func(field, message string))
+}
+
+// DefaultLintRules are the rules used by Lint if no rules are given.
+var DefaultLintRules = []LintRule{
+	TypeNamingRule,
+	FieldNamingRule,
+	EnumNamingRule,
+	FieldDescriptionRule,
+	UnboundedListRule(),
+}
+
+// Lint checks schema against rules, or DefaultLintRules if no rules are
+// given, and returns the findings sorted by type, field, and rule.
+// Introspection types and fields are not checked.
+func Lint(schema *Schema, rules ...LintRule) []*LintFinding {
+	if len(rules) == 0 {
+		rules = DefaultLintRules
+	}
+
+	types := make(map[string]Type)
+	collectNamedTypes(schema.Query, types)
+	collectNamedTypes(schema.Mutation, types)
+
+	var findings []*LintFinding
+	for name, typ := range types {
+		if strings.HasPrefix(name, "__") {
+			continue
+		}
+		for _, rule := range rules {
+			rule := rule
+			rule.Check(schema, typ, func(field, message string) {
+				findings = append(findings, &LintFinding{
+					Rule:    rule.Name,
+					Type:    name,
+					Field:   field,
+					Message: message,
+				})
+			})
+		}
+	}
+
+	sort.Slice(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		if a.Field != b.Field {
+			return a.Field < b.Field
+		}
+		return a.Rule < b.Rule
+	})
+	return findings
+}
+
+// collectNamedTypes adds typ and all named types reachable from it to types.
+func collectNamedTypes(typ Type, types map[string]Type) {
+	switch typ := typ.(type) {
+	case *NonNull:
+		collectNamedTypes(typ.Type, types)
+	case *List:
+		collectNamedTypes(typ.Type, types)
+	case *Scalar:
+		types[typ.Type] = typ
+	case *Enum:
+		types[typ.Type] = typ
+	case *InputObject:
+		if _, ok := types[typ.Name]; ok {
+			return
+		}
+		types[typ.Name] = typ
+		for _, field := range typ.InputFields {
+			collectNamedTypes(field, types)
+		}
+	case *Union:
+		if _, ok := types[typ.Name]; ok {
+			return
+		}
+		types[typ.Name] = typ
+		for _, object := range typ.Types {
+			collectNamedTypes(object, types)
+		}
+	case *Object:
+		if _, ok := types[typ.Name]; ok {
+			return
+		}
+		types[typ.Name] = typ
+		for _, field := range typ.Fields {
+			collectNamedTypes(field.Type, types)
+			for _, arg := range field.Args {
+				collectNamedTypes(arg, types)
+			}
+		}
+	}
+}
+
+// sortedFieldNames returns the names of the public fields of an object,
+// sorted.
+func sortedFieldNames(object *Object) []string {
+	names := make([]string, 0, len(object.Fields))
+	for name := range object.Fields {
+		if !strings.HasPrefix(name, "__") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+var (
+	pascalCase         = regexp.MustCompile(`^[A-Z][A-Za-z0-9]*$`)
+	camelCase          = regexp.MustCompile(`^[a-z][A-Za-z0-9]*$`)
+	screamingSnakeCase = regexp.MustCompile(`^[A-Z][A-Z0-9]*(_[A-Z0-9]+)*$`)
+)
+
+// TypeNamingRule requires object, input object, union, and enum names to be
+// PascalCase.
+var TypeNamingRule = LintRule{
+	Name: "type-naming",
+	Check: func(schema *Schema, typ Type, report func(field, message string)) {
+		switch typ.(type) {
+		case *Object, *InputObject, *Union, *Enum:
+			if !pascalCase.MatchString(typ.String()) {
+				report("", "type names should be PascalCase")
+			}
+		}
+	},
+}
+
+// FieldNamingRule requires field, argument, and input field names to be
+// camelCase.
+var FieldNamingRule = LintRule{
+	Name: "field-naming",
+	Check: func(schema *Schema, typ Type, report func(field, message string)) {
+		switch typ := typ.(type) {
+		case *Object:
+			for _, name := range sortedFieldNames(typ) {
+				if !camelCase.MatchString(name) {
+					report(name, "field names should be camelCase")
+				}
+				args := make([]string, 0, len(typ.Fields[name].Args))
+				for arg := range typ.Fields[name].Args {
+					args = append(args, arg)
+				}
+				sort.Strings(args)
+				for _, arg := range args {
+					if !camelCase.MatchString(arg) {
+						report(name, fmt.Sprintf("argument %s should be camelCase", arg))
+					}
+				}
+			}
+		case *InputObject:
+			names := make([]string, 0, len(typ.InputFields))
+			for name := range typ.InputFields {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				if !camelCase.MatchString(name) {
+					report(name, "input field names should be camelCase")
+				}
+			}
+		}
+	},
+}
+
+// EnumNamingRule requires enum values to be SCREAMING_SNAKE_CASE. The
+// SortOrder enum of paginated fields is exempt.
+var EnumNamingRule = LintRule{
+	Name: "enum-naming",
+	Check: func(schema *Schema, typ Type, report func(field, message string)) {
+		enum, ok := typ.(*Enum)
+		if !ok || enum.Type == "SortOrder" {
+			return
+		}
+		values := append([]string(nil), enum.Values...)
+		sort.Strings(values)
+		for _, value := range values {
+			if !screamingSnakeCase.MatchString(value) {
+				report(value, "enum values should be SCREAMING_SNAKE_CASE")
+			}
+		}
+	},
+}
+
+// FieldDescriptionRule requires the public fields of all objects to have a
+// description. Connections, edges, page infos, and mutation payloads are
+// generated, and exempt.
+var FieldDescriptionRule = LintRule{
+	Name: "field-description",
+	Check: func(schema *Schema, typ Type, report func(field, message string)) {
+		object, ok := typ.(*Object)
+		if !ok || isGeneratedObject(object) {
+			return
+		}
+		for _, name := range sortedFieldNames(object) {
+			if object.Fields[name].Description == "" {
+				report(name, "fields should have a description")
+			}
+		}
+	},
+}
+
+// isGeneratedObject returns true for the objects generated for paginated
+// fields and mutation payloads.
+func isGeneratedObject(object *Object) bool {
+	switch object.Name {
+	case "PageInfo", "UserError", "Federation":
+		return true
+	}
+	_, hasPageInfo := object.Fields["pageInfo"]
+	_, hasUserErrors := object.Fields["userErrors"]
+	_, hasCursor := object.Fields["cursor"]
+	_, hasNode := object.Fields["node"]
+	return hasPageInfo || hasUserErrors || (hasCursor && hasNode && len(object.Fields) == 2)
+}
+
+// UnboundedListRule reports fields returning lists of objects or unions,
+// which should be paginated instead. The edges of connections and the
+// userErrors of mutation payloads are bounded and allowed. Other fields can
+// be allowed as "Type.field".
+func UnboundedListRule(allowed ...string) LintRule {
+	allowedFields := make(map[string]bool, len(allowed))
+	for _, field := range allowed {
+		allowedFields[field] = true
+	}
+
+	return LintRule{
+		Name: "unbounded-list",
+		Check: func(schema *Schema, typ Type, report func(field, message string)) {
+			object, ok := typ.(*Object)
+			if !ok {
+				return
+			}
+			for _, name := range sortedFieldNames(object) {
+				if allowedFields[object.Name+"."+name] || isBoundedListField(object, name) {
+					continue
+				}
+				if isCompositeList(object.Fields[name].Type) {
+					report(name, "lists of objects should be paginated")
+				}
+			}
+		},
+	}
+}
+
+// isBoundedListField returns true for the list fields of generated
+// connection and mutation payload objects.
+func isBoundedListField(object *Object, name string) bool {
+	_, hasPageInfo := object.Fields["pageInfo"]
+	return (name == "edges" && hasPageInfo) || name == "userErrors"
+}
+
+// isCompositeList returns true if typ is a list of objects or unions.
+func isCompositeList(typ Type) bool {
+	if nonNull, ok := typ.(*NonNull); ok {
+		typ = nonNull.Type
+	}
+	list, ok := typ.(*List)
+	if !ok {
+		return false
+	}
+	elem := list.Type
+	if nonNull, ok := elem.(*NonNull); ok {
+		elem = nonNull.Type
+	}
+	switch elem.(type) {
+	case *Object, *Union:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package graphql_test
+
+import (
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+)
+
+type lintStatus int32
+
+type LintUser struct {
+	Name    string `description:"The user's name."`
+	Friends []*LintUser
+}
+
+type lintOrg struct {
+	Members []*LintUser
+}
+
+func TestLint(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	schema.Enum(lintStatus(0), map[string]lintStatus{
+		"ACTIVE":    0,
+		"onLeave":   1,
+		"NOT_FOUND": 2,
+	})
+	schema.Object("User", LintUser{}).Key("name")
+	schema.Object("org_info", lintOrg{})
+
+	query := schema.Query()
+	query.FieldFunc("user", func(args struct{ Name string }) *LintUser {
+		return nil
+	}, schemabuilder.Description("Finds a user by name."))
+	query.FieldFunc("users", func() []*LintUser {
+		return nil
+	})
+	query.FieldFunc("pagedUsers", func() []*LintUser {
+		return nil
+	}, schemabuilder.Paginated, schemabuilder.Description("Lists all users."))
+	query.FieldFunc("org", func() *lintOrg {
+		return nil
+	}, schemabuilder.Description("Returns the organization."))
+	query.FieldFunc("status", func() lintStatus {
+		return 0
+	}, schemabuilder.Description("Returns the status."))
+	schema.Mutation().FieldFunc("create_user", func(args struct{ Name string }) *LintUser {
+		return nil
+	}, schemabuilder.Description("Creates a user."), schemabuilder.MutationPayload("user"))
+
+	built := schema.MustBuild()
+
+	findings := graphql.Lint(built)
+	if diff := pretty.Compare(findings, []*graphql.LintFinding{
+		{Rule: "type-naming", Type: "Create_userPayload", Message: "type names should be PascalCase"},
+		{Rule: "field-naming", Type: "Mutation", Field: "create_user", Message: "field names should be camelCase"},
+		{Rule: "field-description", Type: "Query", Field: "users", Message: "fields should have a description"},
+		{Rule: "unbounded-list", Type: "Query", Field: "users", Message: "lists of objects should be paginated"},
+		{Rule: "field-description", Type: "User", Field: "friends", Message: "fields should have a description"},
+		{Rule: "unbounded-list", Type: "User", Field: "friends", Message: "lists of objects should be paginated"},
+		{Rule: "type-naming", Type: "lintStatus", Message: "type names should be PascalCase"},
+		{Rule: "enum-naming", Type: "lintStatus", Field: "onLeave", Message: "enum values should be SCREAMING_SNAKE_CASE"},
+		{Rule: "type-naming", Type: "org_info", Message: "type names should be PascalCase"},
+		{Rule: "field-description", Type: "org_info", Field: "members", Message: "fields should have a description"},
+		{Rule: "unbounded-list", Type: "org_info", Field: "members", Message: "lists of objects should be paginated"},
+	}); diff != "" {
+		t.Errorf("unexpected findings: %s", diff)
+	}
+
+	findings = graphql.Lint(built, graphql.UnboundedListRule("Query.users", "User.friends"))
+	if diff := pretty.Compare(findings, []*graphql.LintFinding{
+		{Rule: "unbounded-list", Type: "org_info", Field: "members", Message: "lists of objects should be paginated"},
+	}); diff != "" {
+		t.Errorf("unexpected findings: %s", diff)
+	}
+}
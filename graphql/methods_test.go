@@ -0,0 +1,111 @@
+package graphql_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/denkhaus/thunder/batch"
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+	"github.com/denkhaus/thunder/internal"
+	"github.com/denkhaus/thunder/internal/testgraphql"
+)
+
+type methodUser struct {
+	FirstName string
+	LastName  string
+}
+
+type methodUserResolver struct {
+	greeting string
+}
+
+func (r *methodUserResolver) FullName(u *methodUser) string {
+	return u.FirstName + " " + u.LastName
+}
+
+func (r *methodUserResolver) Greeting(ctx context.Context, u *methodUser, args struct{ Punctuation string }) (string, error) {
+	return fmt.Sprintf("%s %s%s", r.greeting, u.FirstName, args.Punctuation), nil
+}
+
+func (r *methodUserResolver) Initials(users map[batch.Index]*methodUser) map[batch.Index]string {
+	initials := make(map[batch.Index]string, len(users))
+	for i, u := range users {
+		initials[i] = u.FirstName[:1] + u.LastName[:1]
+	}
+	return initials
+}
+
+func (r *methodUserResolver) FieldOptions() map[string][]schemabuilder.FieldFuncOption {
+	return map[string][]schemabuilder.FieldFuncOption{
+		"fullName": {schemabuilder.Description("The first and last name.")},
+	}
+}
+
+type methodQueryResolver struct{}
+
+func (methodQueryResolver) Users() []*methodUser {
+	return []*methodUser{{FirstName: "Ada", LastName: "Lovelace"}, {FirstName: "Alan", LastName: "Turing"}}
+}
+
+func TestFieldMethods(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldMethods(methodQueryResolver{})
+	schema.Object("User", methodUser{}).FieldMethods(&methodUserResolver{greeting: "hello"})
+	builtSchema := schema.MustBuild()
+
+	user := builtSchema.Query.(*graphql.Object).Fields["users"].Type.(*graphql.NonNull).Type.(*graphql.List).Type.(*graphql.NonNull).Type.(*graphql.Object)
+	assert.Equal(t, "The first and last name.", user.Fields["fullName"].Description)
+	assert.NotContains(t, user.Fields, "fieldOptions")
+
+	ctx := context.Background()
+	q := graphql.MustParse(`{ users { fullName greeting(punctuation: "!") initials } }`, nil)
+	if err := graphql.PrepareQuery(ctx, builtSchema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+	result, err := testgraphql.NewExecutorWrapper(t).Execute(ctx, builtSchema.Query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := pretty.Compare(internal.AsJSON(result), internal.ParseJSON(`{"users": [
+		{"fullName": "Ada Lovelace", "greeting": "hello Ada!", "initials": "AL"},
+		{"fullName": "Alan Turing", "greeting": "hello Alan!", "initials": "AT"}
+	]}`)); d != "" {
+		t.Errorf("expected did not match result: %s", d)
+	}
+}
+
+type badMethodResolver struct{}
+
+func (badMethodResolver) Name(u *methodUser) string { return u.FirstName }
+
+func (badMethodResolver) FieldOptions() map[string][]schemabuilder.FieldFuncOption {
+	return map[string][]schemabuilder.FieldFuncOption{"nmae": nil}
+}
+
+type wrongSourceResolver struct{}
+
+func (wrongSourceResolver) Name(u *redactUser) string { return u.Name }
+
+func TestFieldMethodsErrors(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	assert.Panics(t, func() {
+		schema.Object("User", methodUser{}).FieldMethods(struct{}{})
+	})
+	assert.Panics(t, func() {
+		schema.Object("User", methodUser{}).FieldMethods(badMethodResolver{})
+	})
+
+	// Signatures are checked when the schema is built.
+	schema = schemabuilder.NewSchema()
+	schema.Query().FieldMethods(methodQueryResolver{})
+	schema.Object("User", methodUser{}).FieldMethods(wrongSourceResolver{})
+	_, err := schema.Build()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "bad method name")
+	}
+}
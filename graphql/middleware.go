@@ -5,7 +5,9 @@ import (
 )
 
 type ComputationInput struct {
-	Id                   string
+	Id string
+	// Query and Variables are the query and variables as sent by the client.
+	// They include the values of sensitive arguments, see RedactQuery.
 	Query                string
 	ParsedQuery          *Query
 	Variables            map[string]interface{}
@@ -13,12 +15,17 @@ type ComputationInput struct {
 	Previous             interface{}
 	IsInitialComputation bool
 	Extensions           map[string]interface{}
+	// EmptyOperation is true if the skip and include directives of
+	// ParsedQuery exclude all of its top-level fields. Empty operations are
+	// not executed, and their result is an empty object.
+	EmptyOperation bool
 }
 
 type ComputationOutput struct {
-	Metadata map[string]interface{}
-	Current  interface{}
-	Error    error
+	Metadata   map[string]interface{}
+	Extensions map[string]interface{}
+	Current    interface{}
+	Error      error
 }
 
 type MiddlewareFunc func(input *ComputationInput, next MiddlewareNextFunc) *ComputationOutput
@@ -29,7 +36,8 @@ func RunMiddlewares(middlewares []MiddlewareFunc, input *ComputationInput) *Comp
 	run = func(index int, middlewares []MiddlewareFunc, input *ComputationInput) *ComputationOutput {
 		if index >= len(middlewares) {
 			return &ComputationOutput{
-				Metadata: make(map[string]interface{}),
+				Metadata:   make(map[string]interface{}),
+				Extensions: make(map[string]interface{}),
 			}
 		}
 
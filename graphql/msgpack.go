@@ -0,0 +1,804 @@
+package graphql
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+)
+
+// This file implements an optional MessagePack transport for websocket
+// connections. Clients opt in by requesting the MessagePackSubprotocol
+// subprotocol; the connection then exchanges binary frames, each holding a
+// single MessagePack encoded message with the same structure as the JSON
+// messages of the default transport. MessagePack is cheaper to encode and
+// smaller than JSON, which matters for subscriptions updating frequently.
+//
+// Values are encoded like encoding/json would encode them: structs as maps
+// keyed by their json field names, and values implementing json.Marshaler
+// through their JSON encoding.
+
+// MessagePackSubprotocol is the websocket subprotocol negotiating the
+// MessagePack transport.
+const MessagePackSubprotocol = "graphql-msgpack"
+
+const (
+	msgpackNil     = 0xc0
+	msgpackFalse   = 0xc2
+	msgpackTrue    = 0xc3
+	msgpackBin8    = 0xc4
+	msgpackBin16   = 0xc5
+	msgpackBin32   = 0xc6
+	msgpackFloat32 = 0xca
+	msgpackFloat64 = 0xcb
+	msgpackUint8   = 0xcc
+	msgpackUint16  = 0xcd
+	msgpackUint32  = 0xce
+	msgpackUint64  = 0xcf
+	msgpackInt8    = 0xd0
+	msgpackInt16   = 0xd1
+	msgpackInt32   = 0xd2
+	msgpackInt64   = 0xd3
+	msgpackStr8    = 0xd9
+	msgpackStr16   = 0xda
+	msgpackStr32   = 0xdb
+	msgpackArray16 = 0xdc
+	msgpackArray32 = 0xdd
+	msgpackMap16   = 0xde
+	msgpackMap32   = 0xdf
+)
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonNumberType    = reflect.TypeOf(json.Number(""))
+)
+
+// MarshalMessagePack returns the MessagePack encoding of v.
+func MarshalMessagePack(v interface{}) ([]byte, error) {
+	e := &msgpackEncoder{}
+	if err := e.encode(reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return e.buf.Bytes(), nil
+}
+
+// UnmarshalMessagePack decodes the MessagePack encoded data into v, following
+// the rules of json.Unmarshal.
+func UnmarshalMessagePack(data []byte, v interface{}) error {
+	if envelope, ok := v.(*inEnvelope); ok {
+		return unmarshalMessagePackEnvelope(data, envelope)
+	}
+	bytes, err := transcodeMessagePack(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bytes, v)
+}
+
+type msgpackEncoder struct {
+	buf     bytes.Buffer
+	scratch [9]byte
+}
+
+func (e *msgpackEncoder) writeHeader(code byte, n uint64, size int) {
+	e.scratch[0] = code
+	switch size {
+	case 1:
+		e.scratch[1] = byte(n)
+	case 2:
+		binary.BigEndian.PutUint16(e.scratch[1:], uint16(n))
+	case 4:
+		binary.BigEndian.PutUint32(e.scratch[1:], uint32(n))
+	case 8:
+		binary.BigEndian.PutUint64(e.scratch[1:], n)
+	}
+	e.buf.Write(e.scratch[:1+size])
+}
+
+func (e *msgpackEncoder) encodeInt(n int64) {
+	switch {
+	case n >= 0:
+		e.encodeUint(uint64(n))
+	case n >= -32:
+		e.buf.WriteByte(byte(n))
+	case n >= math.MinInt8:
+		e.writeHeader(msgpackInt8, uint64(n), 1)
+	case n >= math.MinInt16:
+		e.writeHeader(msgpackInt16, uint64(n), 2)
+	case n >= math.MinInt32:
+		e.writeHeader(msgpackInt32, uint64(n), 4)
+	default:
+		e.writeHeader(msgpackInt64, uint64(n), 8)
+	}
+}
+
+func (e *msgpackEncoder) encodeUint(n uint64) {
+	switch {
+	case n <= 0x7f:
+		e.buf.WriteByte(byte(n))
+	case n <= math.MaxUint8:
+		e.writeHeader(msgpackUint8, n, 1)
+	case n <= math.MaxUint16:
+		e.writeHeader(msgpackUint16, n, 2)
+	case n <= math.MaxUint32:
+		e.writeHeader(msgpackUint32, n, 4)
+	default:
+		e.writeHeader(msgpackUint64, n, 8)
+	}
+}
+
+func (e *msgpackEncoder) encodeString(s string) {
+	n := uint64(len(s))
+	switch {
+	case n < 32:
+		e.buf.WriteByte(0xa0 | byte(n))
+	case n <= math.MaxUint8:
+		e.writeHeader(msgpackStr8, n, 1)
+	case n <= math.MaxUint16:
+		e.writeHeader(msgpackStr16, n, 2)
+	default:
+		e.writeHeader(msgpackStr32, n, 4)
+	}
+	e.buf.WriteString(s)
+}
+
+func (e *msgpackEncoder) encodeBytes(b []byte) {
+	n := uint64(len(b))
+	switch {
+	case n <= math.MaxUint8:
+		e.writeHeader(msgpackBin8, n, 1)
+	case n <= math.MaxUint16:
+		e.writeHeader(msgpackBin16, n, 2)
+	default:
+		e.writeHeader(msgpackBin32, n, 4)
+	}
+	e.buf.Write(b)
+}
+
+func (e *msgpackEncoder) encodeArrayHeader(n int) {
+	switch {
+	case n < 16:
+		e.buf.WriteByte(0x90 | byte(n))
+	case n <= math.MaxUint16:
+		e.writeHeader(msgpackArray16, uint64(n), 2)
+	default:
+		e.writeHeader(msgpackArray32, uint64(n), 4)
+	}
+}
+
+func (e *msgpackEncoder) encodeMapHeader(n int) {
+	switch {
+	case n < 16:
+		e.buf.WriteByte(0x80 | byte(n))
+	case n <= math.MaxUint16:
+		e.writeHeader(msgpackMap16, uint64(n), 2)
+	default:
+		e.writeHeader(msgpackMap32, uint64(n), 4)
+	}
+}
+
+// encodeNumber encodes a json.Number as an integer if possible, and as a
+// float otherwise.
+func (e *msgpackEncoder) encodeNumber(n json.Number) error {
+	if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+		e.encodeInt(i)
+		return nil
+	}
+	f, err := strconv.ParseFloat(string(n), 64)
+	if err != nil {
+		return fmt.Errorf("msgpack: invalid number %q", n)
+	}
+	e.writeHeader(msgpackFloat64, math.Float64bits(f), 8)
+	return nil
+}
+
+// encodeJSON encodes v through its JSON encoding.
+func (e *msgpackEncoder) encodeJSON(v reflect.Value) error {
+	bytes, err := json.Marshal(v.Interface())
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(bytes)))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return err
+	}
+	return e.encode(reflect.ValueOf(value))
+}
+
+func (e *msgpackEncoder) encode(v reflect.Value) error {
+	if !v.IsValid() {
+		e.buf.WriteByte(msgpackNil)
+		return nil
+	}
+
+	if v.Type() == jsonNumberType {
+		return e.encodeNumber(json.Number(v.String()))
+	}
+	if v.Type().Implements(jsonMarshalerType) {
+		if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface || v.Kind() == reflect.Map || v.Kind() == reflect.Slice) && v.IsNil() {
+			e.buf.WriteByte(msgpackNil)
+			return nil
+		}
+		return e.encodeJSON(v)
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			e.buf.WriteByte(msgpackNil)
+			return nil
+		}
+		return e.encode(v.Elem())
+
+	case reflect.Bool:
+		if v.Bool() {
+			e.buf.WriteByte(msgpackTrue)
+		} else {
+			e.buf.WriteByte(msgpackFalse)
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.encodeInt(v.Int())
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.encodeUint(v.Uint())
+
+	case reflect.Float32:
+		e.writeHeader(msgpackFloat32, uint64(math.Float32bits(float32(v.Float()))), 4)
+
+	case reflect.Float64:
+		e.writeHeader(msgpackFloat64, math.Float64bits(v.Float()), 8)
+
+	case reflect.String:
+		e.encodeString(v.String())
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			e.buf.WriteByte(msgpackNil)
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			// encoding/json encodes byte slices as base64 strings;
+			// MessagePack has a native representation.
+			bytes := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(bytes), v)
+			e.encodeBytes(bytes)
+			return nil
+		}
+		e.encodeArrayHeader(v.Len())
+		for i := 0; i < v.Len(); i++ {
+			if err := e.encode(v.Index(i)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		if v.IsNil() {
+			e.buf.WriteByte(msgpackNil)
+			return nil
+		}
+		if v.Type().Key().Kind() != reflect.String {
+			return e.encodeJSON(v)
+		}
+		e.encodeMapHeader(v.Len())
+		for _, key := range v.MapKeys() {
+			e.encodeString(key.String())
+			if err := e.encode(v.MapIndex(key)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Struct:
+		fields, ok := msgpackStructFields(v.Type())
+		if !ok {
+			return e.encodeJSON(v)
+		}
+		n := 0
+		for _, field := range fields {
+			if !field.omitEmpty || !isEmptyValue(v.Field(field.index)) {
+				n++
+			}
+		}
+		e.encodeMapHeader(n)
+		for _, field := range fields {
+			fieldValue := v.Field(field.index)
+			if field.omitEmpty && isEmptyValue(fieldValue) {
+				continue
+			}
+			e.encodeString(field.name)
+			if err := e.encode(fieldValue); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return fmt.Errorf("msgpack: unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// msgpackField describes an encoded struct field.
+type msgpackField struct {
+	index     int
+	name      string
+	omitEmpty bool
+}
+
+// msgpackFieldCache caches the fields of struct types, see
+// msgpackStructFields.
+var msgpackFieldCache sync.Map
+
+type msgpackFieldsEntry struct {
+	fields []msgpackField
+	ok     bool
+}
+
+// msgpackStructFields returns the fields of typ encoded by encoding/json. It
+// returns false if typ has embedded or ",string" fields, which are encoded
+// through JSON instead.
+func msgpackStructFields(typ reflect.Type) ([]msgpackField, bool) {
+	if entry, ok := msgpackFieldCache.Load(typ); ok {
+		return entry.(msgpackFieldsEntry).fields, entry.(msgpackFieldsEntry).ok
+	}
+
+	var fields []msgpackField
+	ok := true
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Anonymous {
+			ok = false
+			break
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		name := parts[0]
+		if name == "" {
+			name = field.Name
+		}
+		f := msgpackField{index: i, name: name}
+		for _, option := range parts[1:] {
+			switch option {
+			case "omitempty":
+				f.omitEmpty = true
+			case "string":
+				ok = false
+			}
+		}
+		fields = append(fields, f)
+	}
+
+	msgpackFieldCache.Store(typ, msgpackFieldsEntry{fields: fields, ok: ok})
+	return fields, ok
+}
+
+// isEmptyValue returns whether v is empty according to the omitempty option
+// of encoding/json.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// msgpackMaxDepth is the deepest nesting of arrays and maps decoded, so
+// malicious messages cannot exhaust the stack.
+const msgpackMaxDepth = 1000
+
+var (
+	errMsgpackShort = errors.New("msgpack: unexpected end of data")
+	errMsgpackDepth = errors.New("msgpack: exceeded max depth")
+)
+
+// msgpackDecoder transcodes MessagePack into JSON, without building
+// intermediate values.
+type msgpackDecoder struct {
+	data  []byte
+	pos   int
+	depth int
+}
+
+func (d *msgpackDecoder) read(n int) ([]byte, error) {
+	if n < 0 || len(d.data)-d.pos < n {
+		return nil, errMsgpackShort
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *msgpackDecoder) readUint(size int) (uint64, error) {
+	b, err := d.read(size)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	default:
+		return binary.BigEndian.Uint64(b), nil
+	}
+}
+
+func (d *msgpackDecoder) readBytes(size int) ([]byte, error) {
+	n, err := d.readUint(size)
+	if err != nil {
+		return nil, err
+	}
+	return d.read(int(n))
+}
+
+// readString reads a string or binary value, for map keys and envelope
+// fields.
+func (d *msgpackDecoder) readString() (string, error) {
+	b, err := d.read(1)
+	if err != nil {
+		return "", err
+	}
+	code := b[0]
+	switch {
+	case code&0xe0 == 0xa0:
+		b, err = d.read(int(code & 0x1f))
+	case code == msgpackStr8 || code == msgpackBin8:
+		b, err = d.readBytes(1)
+	case code == msgpackStr16 || code == msgpackBin16:
+		b, err = d.readBytes(2)
+	case code == msgpackStr32 || code == msgpackBin32:
+		b, err = d.readBytes(4)
+	default:
+		// Encode other keys like fmt.Sprint would.
+		d.pos--
+		var buf bytes.Buffer
+		if err := d.transcodeScalar(&buf); err != nil {
+			return "", err
+		}
+		return strings.Trim(buf.String(), `"`), nil
+	}
+	return string(b), err
+}
+
+func (d *msgpackDecoder) transcodeArray(buf *bytes.Buffer, n int) error {
+	// Every element takes at least a byte.
+	if n > len(d.data)-d.pos {
+		return errMsgpackShort
+	}
+	buf.WriteByte('[')
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if err := d.transcode(buf); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte(']')
+	return nil
+}
+
+func (d *msgpackDecoder) transcodeMap(buf *bytes.Buffer, n int) error {
+	// Every entry takes at least two bytes.
+	if n > (len(d.data)-d.pos)/2 {
+		return errMsgpackShort
+	}
+	buf.WriteByte('{')
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := d.readString()
+		if err != nil {
+			return err
+		}
+		writeJSONString(buf, key)
+		buf.WriteByte(':')
+		if err := d.transcode(buf); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+// transcode writes the next value to buf as JSON.
+func (d *msgpackDecoder) transcode(buf *bytes.Buffer) error {
+	b, err := d.read(1)
+	if err != nil {
+		return err
+	}
+	code := b[0]
+
+	var n uint64
+	switch {
+	case code&0xf0 == 0x90:
+		n = uint64(code & 0x0f)
+	case code == msgpackArray16 || code == msgpackArray32:
+		if n, err = d.readUint(2 << (code - msgpackArray16)); err != nil {
+			return err
+		}
+	case code&0xf0 == 0x80:
+		n = uint64(code & 0x0f)
+	case code == msgpackMap16 || code == msgpackMap32:
+		if n, err = d.readUint(2 << (code - msgpackMap16)); err != nil {
+			return err
+		}
+	default:
+		d.pos--
+		return d.transcodeScalar(buf)
+	}
+
+	if d.depth >= msgpackMaxDepth {
+		return errMsgpackDepth
+	}
+	d.depth++
+	defer func() { d.depth-- }()
+
+	if code&0xf0 == 0x90 || code == msgpackArray16 || code == msgpackArray32 {
+		return d.transcodeArray(buf, int(n))
+	}
+	return d.transcodeMap(buf, int(n))
+}
+
+// transcodeScalar writes the next value, which is not an array or map, to
+// buf as JSON. Binary values are written as base64 strings, like
+// encoding/json writes []byte.
+func (d *msgpackDecoder) transcodeScalar(buf *bytes.Buffer) error {
+	b, err := d.read(1)
+	if err != nil {
+		return err
+	}
+	code := b[0]
+
+	switch {
+	case code <= 0x7f:
+		buf.WriteString(strconv.Itoa(int(code)))
+		return nil
+	case code >= 0xe0:
+		buf.WriteString(strconv.Itoa(int(int8(code))))
+		return nil
+	case code&0xe0 == 0xa0:
+		b, err := d.read(int(code & 0x1f))
+		writeJSONString(buf, string(b))
+		return err
+	}
+
+	switch code {
+	case msgpackNil:
+		buf.WriteString("null")
+	case msgpackFalse:
+		buf.WriteString("false")
+	case msgpackTrue:
+		buf.WriteString("true")
+
+	case msgpackUint8, msgpackUint16, msgpackUint32, msgpackUint64:
+		n, err := d.readUint(1 << (code - msgpackUint8))
+		if err != nil {
+			return err
+		}
+		buf.WriteString(strconv.FormatUint(n, 10))
+
+	case msgpackInt8, msgpackInt16, msgpackInt32, msgpackInt64:
+		size := 1 << (code - msgpackInt8)
+		n, err := d.readUint(size)
+		if err != nil {
+			return err
+		}
+		// Sign-extend n from size bytes.
+		shift := uint(64 - 8*size)
+		buf.WriteString(strconv.FormatInt(int64(n<<shift)>>shift, 10))
+
+	case msgpackFloat32:
+		n, err := d.readUint(4)
+		if err != nil {
+			return err
+		}
+		return writeJSONFloat(buf, float64(math.Float32frombits(uint32(n))), 32)
+	case msgpackFloat64:
+		n, err := d.readUint(8)
+		if err != nil {
+			return err
+		}
+		return writeJSONFloat(buf, math.Float64frombits(n), 64)
+
+	case msgpackStr8, msgpackStr16, msgpackStr32:
+		b, err := d.readBytes(1 << (code - msgpackStr8))
+		if err != nil {
+			return err
+		}
+		writeJSONString(buf, string(b))
+
+	case msgpackBin8, msgpackBin16, msgpackBin32:
+		b, err := d.readBytes(1 << (code - msgpackBin8))
+		if err != nil {
+			return err
+		}
+		buf.WriteByte('"')
+		buf.WriteString(base64.StdEncoding.EncodeToString(b))
+		buf.WriteByte('"')
+
+	case msgpackArray16, msgpackArray32, msgpackMap16, msgpackMap32:
+		return fmt.Errorf("msgpack: unsupported map key type code 0x%x", code)
+
+	default:
+		if code&0xe0 == 0x80 {
+			return fmt.Errorf("msgpack: unsupported map key type code 0x%x", code)
+		}
+		return fmt.Errorf("msgpack: unsupported type code 0x%x", code)
+	}
+	return nil
+}
+
+// writeJSONFloat writes f like encoding/json writes floats of bitSize bits.
+func writeJSONFloat(buf *bytes.Buffer, f float64, bitSize int) error {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return fmt.Errorf("msgpack: unsupported float value %v", f)
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	buf.WriteString(strconv.FormatFloat(f, format, -1, bitSize))
+	return nil
+}
+
+// writeJSONString writes s as a JSON string, replacing invalid UTF-8 like
+// encoding/json.
+func writeJSONString(buf *bytes.Buffer, s string) {
+	const hex = "0123456789abcdef"
+	buf.WriteByte('"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				buf.WriteByte('\\')
+				buf.WriteByte(c)
+			case c < 0x20:
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hex[c>>4])
+				buf.WriteByte(hex[c&0xf])
+			default:
+				buf.WriteByte(c)
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf.WriteString("\ufffd")
+		} else {
+			buf.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	buf.WriteByte('"')
+}
+
+// transcodeMessagePack returns the JSON encoding of the MessagePack encoded
+// data.
+func transcodeMessagePack(data []byte) ([]byte, error) {
+	d := &msgpackDecoder{data: data}
+	var buf bytes.Buffer
+	buf.Grow(len(data) + len(data)/2)
+	if err := d.transcode(&buf); err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.data) {
+		return nil, errors.New("msgpack: trailing data")
+	}
+	return buf.Bytes(), nil
+}
+
+// unmarshalMessagePackEnvelope decodes a MessagePack encoded message into
+// envelope, transcoding only the message and extensions to JSON.
+func unmarshalMessagePackEnvelope(data []byte, envelope *inEnvelope) error {
+	d := &msgpackDecoder{data: data}
+	b, err := d.read(1)
+	if err != nil {
+		return err
+	}
+	var n uint64
+	switch code := b[0]; {
+	case code&0xf0 == 0x80:
+		n = uint64(code & 0x0f)
+	case code == msgpackMap16 || code == msgpackMap32:
+		if n, err = d.readUint(2 << (code - msgpackMap16)); err != nil {
+			return err
+		}
+	default:
+		return errors.New("msgpack: message must be a map")
+	}
+
+	for i := uint64(0); i < n; i++ {
+		key, err := d.readString()
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		if err := d.transcode(&buf); err != nil {
+			return err
+		}
+		// Match fields case-insensitively, like encoding/json.
+		switch strings.ToLower(key) {
+		case "id":
+			err = json.Unmarshal(buf.Bytes(), &envelope.ID)
+		case "type":
+			err = json.Unmarshal(buf.Bytes(), &envelope.Type)
+		case "message":
+			envelope.Message = json.RawMessage(buf.Bytes())
+		case "extensions":
+			err = json.Unmarshal(buf.Bytes(), &envelope.Extensions)
+		}
+		if err != nil {
+			return fmt.Errorf("msgpack: field %s: %v", key, err)
+		}
+	}
+	if d.pos != len(d.data) {
+		return errors.New("msgpack: trailing data")
+	}
+	return nil
+}
+
+// messagePackSocket is a JSONSocket exchanging MessagePack encoded binary
+// frames.
+type messagePackSocket struct {
+	*websocket.Conn
+}
+
+// NewMessagePackSocket returns a JSONSocket encoding messages on conn with
+// MessagePack, for servers that upgrade connections themselves and pass them
+// to CreateConnection. It should be used if conn negotiated
+// MessagePackSubprotocol.
+func NewMessagePackSocket(conn *websocket.Conn) JSONSocket {
+	return &messagePackSocket{Conn: conn}
+}
+
+func (s *messagePackSocket) ReadJSON(value interface{}) error {
+	messageType, data, err := s.ReadMessage()
+	if err != nil {
+		return err
+	}
+	if messageType == websocket.TextMessage {
+		return json.Unmarshal(data, value)
+	}
+	return UnmarshalMessagePack(data, value)
+}
+
+func (s *messagePackSocket) WriteJSON(value interface{}) error {
+	data, err := MarshalMessagePack(value)
+	if err != nil {
+		return err
+	}
+	return s.WriteMessage(websocket.BinaryMessage, data)
+}
@@ -0,0 +1,137 @@
+package graphql_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+)
+
+type msgpackMessage struct {
+	ID      string      `json:"id,omitempty"`
+	Type    string      `json:"type"`
+	Skipped string      `json:"-"`
+	Message interface{} `json:"message,omitempty"`
+}
+
+func TestMessagePackRoundTrip(t *testing.T) {
+	in := map[string]interface{}{
+		"nil":      nil,
+		"bool":     true,
+		"small":    int64(7),
+		"negative": int64(-200),
+		"large":    int64(math.MaxInt64),
+		"float":    1.5,
+		"string":   strings.Repeat("x", 300),
+		"list":     []interface{}{int64(1), "two", false},
+		"struct":   msgpackMessage{Type: "update", Skipped: "skipped", Message: map[string]interface{}{"a": int64(1)}},
+		"raw":      json.RawMessage(`{"b":[1,2.5]}`),
+	}
+	data, err := graphql.MarshalMessagePack(in)
+	require.NoError(t, err)
+
+	var out map[string]interface{}
+	require.NoError(t, graphql.UnmarshalMessagePack(data, &out))
+	assert.Equal(t, map[string]interface{}{
+		"nil":      nil,
+		"bool":     true,
+		"small":    float64(7),
+		"negative": float64(-200),
+		"large":    float64(math.MaxInt64),
+		"float":    1.5,
+		"string":   strings.Repeat("x", 300),
+		"list":     []interface{}{float64(1), "two", false},
+		"struct":   map[string]interface{}{"type": "update", "message": map[string]interface{}{"a": float64(1)}},
+		"raw":      map[string]interface{}{"b": []interface{}{float64(1), 2.5}},
+	}, out)
+
+	// MessagePack is more compact than JSON.
+	jsonData, err := json.Marshal(in)
+	require.NoError(t, err)
+	assert.True(t, len(data) < len(jsonData))
+
+	assert.Error(t, graphql.UnmarshalMessagePack(data[:len(data)-1], &out))
+	assert.Error(t, graphql.UnmarshalMessagePack(append(data, 0), &out))
+}
+
+func TestMessagePackLimits(t *testing.T) {
+	var out interface{}
+	deep := bytes.Repeat([]byte{0x91}, 100000)
+	assert.EqualError(t, graphql.UnmarshalMessagePack(append(deep, 0xc0), &out), "msgpack: exceeded max depth")
+
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("value", func() int64 { return 1 })
+	server := httptest.NewServer(graphql.Handler(schema.MustBuild(), graphql.WithMaxMessageSize(4096)))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	// Deeply nested and oversized messages close the connection.
+	envelope := append([]byte("\x81\xa7message"), deep[:1100]...)
+	for _, message := range [][]byte{envelope, deep} {
+		dialer := &websocket.Dialer{Subprotocols: []string{graphql.MessagePackSubprotocol}}
+		conn, _, err := dialer.Dial(url, nil)
+		require.NoError(t, err)
+		require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, append(message, 0xc0)))
+		_, _, err = conn.ReadMessage()
+		assert.Error(t, err)
+		conn.Close()
+	}
+}
+
+func TestMessagePackSubprotocol(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("value", func() int64 { return 1 })
+	schema.Mutation()
+
+	server := httptest.NewServer(graphql.Handler(schema.MustBuild()))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	subscribe, err := graphql.MarshalMessagePack(map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ value }"},
+	})
+	require.NoError(t, err)
+
+	// Clients requesting the subprotocol exchange binary frames.
+	dialer := &websocket.Dialer{Subprotocols: []string{graphql.MessagePackSubprotocol}}
+	conn, _, err := dialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, graphql.MessagePackSubprotocol, conn.Subprotocol())
+
+	require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, subscribe))
+	messageType, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, websocket.BinaryMessage, messageType)
+	var out map[string]interface{}
+	require.NoError(t, graphql.UnmarshalMessagePack(data, &out))
+	assert.Equal(t, "1", out["id"])
+	assert.Equal(t, "update", out["type"])
+	assert.NotNil(t, out["message"])
+
+	// Other clients still use JSON.
+	jsonConn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer jsonConn.Close()
+	assert.Equal(t, "", jsonConn.Subprotocol())
+
+	require.NoError(t, jsonConn.WriteJSON(map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ value }"},
+	}))
+	out = nil
+	require.NoError(t, jsonConn.ReadJSON(&out))
+	assert.Equal(t, "update", out["type"])
+}
@@ -0,0 +1,109 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/denkhaus/thunder/batch"
+)
+
+// WithParentBatching combines the batch resolver calls of a batch field that
+// are split across work units, for example because the parents of the field
+// are resolved by an Expensive field one at a time, into a single call with
+// all parents. Calls are combined with a batch.Func per field that waits for
+// waitInterval, or batch.DefaultWaitInterval if it is zero, for other calls.
+//
+// Calls are only combined for the same selection, so they share arguments.
+// Fields with a NumParallelInvocationsFunc or Serial fields are never
+// combined. Combining calls only helps schedulers that resolve work units
+// concurrently.
+func WithParentBatching(waitInterval time.Duration) ExecutorOption {
+	return func(e *Executor) {
+		e.parentBatching = true
+		e.parentBatchingWaitInterval = waitInterval
+	}
+}
+
+type parentBatcherKey struct{}
+
+// parentBatcher holds the batch.Func of every batch field resolved in a
+// query.
+type parentBatcher struct {
+	waitInterval time.Duration
+
+	mu    sync.Mutex
+	funcs map[*Field]*batch.Func
+}
+
+// withParentBatching adds a parentBatcher to ctx, and adds batching support if
+// ctx does not have it yet.
+func withParentBatching(ctx context.Context, waitInterval time.Duration) context.Context {
+	if !batch.HasBatching(ctx) {
+		ctx = batch.WithBatching(ctx)
+	}
+	return context.WithValue(ctx, parentBatcherKey{}, &parentBatcher{
+		waitInterval: waitInterval,
+		funcs:        make(map[*Field]*batch.Func),
+	})
+}
+
+// funcFor returns the batch.Func that combines the calls of field's
+// BatchResolver.
+func (b *parentBatcher) funcFor(field *Field) *batch.Func {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if f, ok := b.funcs[field]; ok {
+		return f
+	}
+	f := &batch.Func{
+		Many: func(ctx context.Context, args []interface{}) ([]interface{}, error) {
+			// All units share the selection, see Shard.
+			selection := args[0].(*WorkUnit).selection
+			var sources []interface{}
+			for _, arg := range args {
+				sources = append(sources, arg.(*WorkUnit).sources...)
+			}
+
+			results, err := SafeExecuteBatchResolver(ctx, field, sources, selection.Args, selection.SelectionSet)
+			if err != nil {
+				return nil, err
+			}
+			if len(results) != len(sources) {
+				return nil, fmt.Errorf("batch resolver returned %d results for %d sources", len(results), len(sources))
+			}
+
+			// Split the results back into the results of every unit.
+			unitResults := make([]interface{}, len(args))
+			for i, arg := range args {
+				n := len(arg.(*WorkUnit).sources)
+				unitResults[i] = results[:n:n]
+				results = results[n:]
+			}
+			return unitResults, nil
+		},
+		Shard: func(arg interface{}) interface{} {
+			return arg.(*WorkUnit).selection
+		},
+		WaitInterval: b.waitInterval,
+	}
+	b.funcs[field] = f
+	return f
+}
+
+// resolveParentBatch calls the BatchResolver of unit's field, combined with
+// the calls of other units with the same selection if the executor uses
+// parent batching. It reports false if calls are not combined.
+func resolveParentBatch(ctx context.Context, unit *WorkUnit) ([]interface{}, bool, error) {
+	b, ok := ctx.Value(parentBatcherKey{}).(*parentBatcher)
+	if !ok || unit.field.NumParallelInvocationsFunc != nil || unit.field.Serial {
+		return nil, false, nil
+	}
+	result, err := b.funcFor(unit.field).Invoke(ctx, unit)
+	if err != nil {
+		return nil, true, err
+	}
+	return result.([]interface{}), true, nil
+}
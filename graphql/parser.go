@@ -48,6 +48,9 @@ func valueToJson(value ast.Value, vars map[string]interface{}) (interface{}, err
 			if _, found := obj[name]; found {
 				return nil, NewClientError("duplicate field")
 			}
+			if isMissingVariable(field.Value, vars) {
+				continue
+			}
 			value, err := valueToJson(field.Value, vars)
 			if err != nil {
 				return nil, err
@@ -70,6 +73,19 @@ func valueToJson(value ast.Value, vars map[string]interface{}) (interface{}, err
 	}
 }
 
+// isMissingVariable returns true if value is a variable that was not
+// provided. Arguments and input object fields set to a missing variable are
+// omitted, so resolvers can tell them apart from variables explicitly set to
+// null.
+func isMissingVariable(value ast.Value, vars map[string]interface{}) bool {
+	variable, ok := value.(*ast.Variable)
+	if !ok {
+		return false
+	}
+	_, ok = vars[variable.Name.Value]
+	return !ok
+}
+
 func parseDirectives(directives []*ast.Directive, vars map[string]interface{}) ([]*Directive, error) {
 	d := make([]*Directive, 0, len(directives))
 	for _, directive := range directives {
@@ -95,6 +111,9 @@ func argsToJson(input []*ast.Argument, vars map[string]interface{}) (map[string]
 		if _, found := args[name]; found {
 			return nil, NewClientError("duplicate arg")
 		}
+		if isMissingVariable(arg.Value, vars) {
+			continue
+		}
 		value, err := valueToJson(arg.Value, vars)
 		if err != nil {
 			return nil, err
@@ -332,6 +351,31 @@ type Query struct {
 // does not validate that the query is legal under a given schema, which
 // instead is done by PrepareQuery.
 func Parse(source string, vars map[string]interface{}) (*Query, error) {
+	op, err := parseOperation(source)
+	if err != nil {
+		return nil, err
+	}
+	return op.bind(vars)
+}
+
+// operation is a parsed GraphQL document with a single operation, before
+// its variables are bound. It does not depend on variables, so it can be
+// cached, see QueryCache.
+type operation struct {
+	name string
+	kind string
+
+	definition *ast.OperationDefinition
+	fragments  map[string]*ast.FragmentDefinition
+
+	// defaults holds the default values of nullable variables, by name.
+	defaults map[string]ast.Value
+	// err is the error in the variable definitions, if any.
+	err error
+}
+
+// parseOperation parses source and checks its variable definitions.
+func parseOperation(source string) (*operation, error) {
 	document, err := parser.Parse(parser.ParseParams{Source: source})
 	if err != nil {
 		return nil, NewClientError(err.Error())
@@ -367,54 +411,80 @@ func Parse(source string, vars map[string]interface{}) (*Query, error) {
 		return nil, NewClientError("must have a single query")
 	}
 
-	kind := queryDefinition.Operation
-	var name string
-	if queryDefinition.Name != nil {
-		name = queryDefinition.Name.Value
+	op := &operation{
+		kind:       queryDefinition.Operation,
+		definition: queryDefinition,
+		fragments:  fragmentDefinitions,
 	}
-
-	rv := &Query{
-		Name:         name,
-		Kind:         kind,
-		SelectionSet: nil,
+	if queryDefinition.Name != nil {
+		op.name = queryDefinition.Name.Value
 	}
 
-	// Parse variable definitions, default values, etc.
-	var defaultedVars map[string]interface{}
+	// Check variable definitions, default values, etc.
 	for _, variableDefinition := range queryDefinition.VariableDefinitions {
 		name := variableDefinition.Variable.Name.Value
 
 		if _, ok := variableDefinition.Type.(*ast.NonNull); ok {
 			if variableDefinition.DefaultValue != nil {
-				return rv, NewClientError("required variable cannot provide a default value: $%s", name)
+				op.err = NewClientError("required variable cannot provide a default value: $%s", name)
+				return op, nil
 			}
 
 			continue
 		}
 
 		if variableDefinition.DefaultValue != nil {
-			// Ignore default if the value exists.
-			if vars[name] != nil {
-				continue
+			// TODO: properly implement coerceValue.
+			// See: https://github.com/graphql/graphql-js/blob/17a0bfd5292f39cafe4eec5b3bd0e22514243b68/src/execution/values.js#L84
+			if _, err := valueToJson(variableDefinition.DefaultValue, nil); err != nil {
+				op.err = NewClientError("failed to parse default value: %s", err.Error())
+				return op, nil
 			}
 
-			// Lazily initialize defaultedVars if needed.
-			if defaultedVars == nil {
-				defaultedVars = make(map[string]interface{})
-				for k, v := range vars {
-					defaultedVars[k] = v
-				}
+			if op.defaults == nil {
+				op.defaults = make(map[string]ast.Value)
 			}
+			op.defaults[name] = variableDefinition.DefaultValue
+		}
+	}
 
-			// TODO: properly implement coerceValue.
-			// See: https://github.com/graphql/graphql-js/blob/17a0bfd5292f39cafe4eec5b3bd0e22514243b68/src/execution/values.js#L84
-			val, err := valueToJson(variableDefinition.DefaultValue, nil)
-			if err != nil {
-				return rv, NewClientError("failed to parse default value: %s", err.Error())
+	return op, nil
+}
+
+// bind binds vars to the operation and builds its *Query. On error, the
+// returned *Query only has a name and a kind.
+func (op *operation) bind(vars map[string]interface{}) (*Query, error) {
+	rv := &Query{
+		Name:         op.name,
+		Kind:         op.kind,
+		SelectionSet: nil,
+	}
+	if op.err != nil {
+		return rv, op.err
+	}
+
+	var defaultedVars map[string]interface{}
+	for name, defaultValue := range op.defaults {
+		// Ignore default if the value exists. A variable explicitly set to
+		// null is provided, and overrides its default.
+		if _, ok := vars[name]; ok {
+			continue
+		}
+
+		// Lazily initialize defaultedVars if needed.
+		if defaultedVars == nil {
+			defaultedVars = make(map[string]interface{})
+			for k, v := range vars {
+				defaultedVars[k] = v
 			}
+		}
 
-			defaultedVars[name] = val
+		// Default values were checked by parseOperation.
+		val, err := valueToJson(defaultValue, nil)
+		if err != nil {
+			return rv, NewClientError("failed to parse default value: %s", err.Error())
 		}
+		defaultedVars[name] = val
 	}
 
 	if defaultedVars != nil {
@@ -422,13 +492,13 @@ func Parse(source string, vars map[string]interface{}) (*Query, error) {
 	}
 
 	globalFragments := make(map[string]*Fragment)
-	for name, fragment := range fragmentDefinitions {
+	for name, fragment := range op.fragments {
 		globalFragments[name] = &Fragment{
 			On: fragment.TypeCondition.Name.Value,
 		}
 	}
 
-	for name, fragment := range fragmentDefinitions {
+	for name, fragment := range op.fragments {
 		selectionSet, err := parseSelectionSet(fragment.SelectionSet, globalFragments, vars)
 		if err != nil {
 			return rv, err
@@ -436,7 +506,7 @@ func Parse(source string, vars map[string]interface{}) (*Query, error) {
 		globalFragments[name].SelectionSet = selectionSet
 	}
 
-	selectionSet, err := parseSelectionSet(queryDefinition.SelectionSet, globalFragments, vars)
+	selectionSet, err := parseSelectionSet(op.definition.SelectionSet, globalFragments, vars)
 	if err != nil {
 		return rv, err
 	}
@@ -483,6 +553,9 @@ func MustParse(source string, vars map[string]interface{}) *Query {
 // get flattened out yet.
 func Flatten(selectionSet *SelectionSet) ([]*Selection, error) {
 	grouped := make(map[string][]*Selection)
+	// aliases holds the aliases in order of appearance, so selections are
+	// flattened in a stable order.
+	var aliases []string
 
 	state := make(map[*SelectionSet]visitState)
 	var visit func(*SelectionSet) error
@@ -492,6 +565,9 @@ func Flatten(selectionSet *SelectionSet) ([]*Selection, error) {
 		}
 
 		for _, selection := range selectionSet.Selections {
+			if _, ok := grouped[selection.Alias]; !ok {
+				aliases = append(aliases, selection.Alias)
+			}
 			grouped[selection.Alias] = append(grouped[selection.Alias], selection)
 		}
 
@@ -516,7 +592,8 @@ func Flatten(selectionSet *SelectionSet) ([]*Selection, error) {
 	}
 
 	var flattened []*Selection
-	for _, selections := range grouped {
+	for _, alias := range aliases {
+		selections := grouped[alias]
 		if len(selections) == 1 || selections[0].SelectionSet == nil {
 			flattened = append(flattened, selections[0])
 			continue
@@ -272,3 +272,21 @@ query Operation($x: int64 = 2) {
 		t.Errorf("expected 2, received %v", val)
 	}
 }
+
+func TestParseExplicitNullOverridesDefaultValue(t *testing.T) {
+	// A variable explicitly set to null does not use its default value.
+	query, err := Parse(`
+query Operation($x: int64 = 2) {
+	field(x: $x)
+}	`, map[string]interface{}{"x": nil})
+
+	if err != nil {
+		t.Error("expected explicit null to be accepted, but received", err)
+	}
+
+	args := query.SelectionSet.Selections[0].UnparsedArgs
+
+	if val, ok := args["x"]; !ok || val != nil {
+		t.Errorf("expected explicit null, received %v", val)
+	}
+}
@@ -0,0 +1,115 @@
+package graphql_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+)
+
+type PatchAddress struct {
+	Street string
+	City   string
+}
+
+type PatchUser struct {
+	Name     string
+	Nickname *string
+	Tags     []string
+	Home     PatchAddress
+	Work     *PatchAddress
+}
+
+type PatchUserPatch struct {
+	schemabuilder.Patch
+	PatchUser
+}
+
+func TestPatch(t *testing.T) {
+	var user PatchUser
+	var lastPatch PatchUserPatch
+
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("user", func() PatchUser { return user })
+	schema.Mutation().FieldFunc("update", func(args struct{ Patch PatchUserPatch }) (PatchUser, error) {
+		lastPatch = args.Patch
+		if err := schemabuilder.ApplyPatch(&user, args.Patch); err != nil {
+			return PatchUser{}, err
+		}
+		return user, nil
+	})
+	builtSchema := schema.MustBuild()
+
+	run := func(query string, vars map[string]interface{}) {
+		q, err := graphql.Parse(query, vars)
+		require.NoError(t, err)
+		require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Mutation, q.SelectionSet))
+		e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+		_, err = e.Execute(context.Background(), builtSchema.Mutation, nil, q)
+		require.NoError(t, err)
+	}
+
+	nickname := "bob"
+	user = PatchUser{
+		Name:     "Robert",
+		Nickname: &nickname,
+		Tags:     []string{"a", "b"},
+		Home:     PatchAddress{Street: "Main St", City: "Springfield"},
+	}
+
+	// Omitted fields keep their value.
+	run(`mutation { update(patch: {name: "Bob", tags: ["c"]}) { name } }`, nil)
+	assert.True(t, lastPatch.Has("name"))
+	assert.False(t, lastPatch.Has("nickname"))
+	assert.Equal(t, PatchUser{
+		Name:     "Bob",
+		Nickname: &nickname,
+		Tags:     []string{"c"},
+		Home:     PatchAddress{Street: "Main St", City: "Springfield"},
+	}, user)
+
+	// Nested objects are merged, and nil nested pointers are allocated.
+	run(`mutation { update(patch: {home: {city: "Shelbyville"}, work: {street: "Elm St"}}) { name } }`, nil)
+	assert.Equal(t, PatchUser{
+		Name:     "Bob",
+		Nickname: &nickname,
+		Tags:     []string{"c"},
+		Home:     PatchAddress{Street: "Main St", City: "Shelbyville"},
+		Work:     &PatchAddress{Street: "Elm St"},
+	}, user)
+
+	// Explicit nulls reset fields, while missing variables are omitted.
+	run(`mutation q($nickname: String, $tags: [String!], $work: PatchAddressPatch_InputObject) { update(patch: {nickname: $nickname, tags: $tags, work: $work}) { name } }`,
+		map[string]interface{}{"nickname": nil, "work": nil})
+	assert.Equal(t, PatchUser{
+		Name: "Bob",
+		Tags: []string{"c"},
+		Home: PatchAddress{Street: "Main St", City: "Shelbyville"},
+	}, user)
+
+	assert.EqualError(t, schemabuilder.ApplyPatch(&user, user), "patch should be a struct embedding schemabuilder.Patch, not graphql_test.PatchUser")
+	assert.EqualError(t, schemabuilder.ApplyPatch(user, lastPatch), "dest should be a *graphql_test.PatchUser, not graphql_test.PatchUser")
+}
+
+func TestPatchInputType(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("user", func() PatchUser { return PatchUser{} })
+	schema.Mutation().FieldFunc("update", func(args struct{ Patch *PatchUserPatch }) bool { return true })
+	builtSchema := schema.MustBuild()
+
+	patch := builtSchema.Mutation.(*graphql.Object).Fields["update"].Args["patch"].(*graphql.InputObject)
+	assert.Equal(t, "PatchUserPatch_InputObject", patch.Name)
+	for name, typ := range patch.InputFields {
+		_, nonNull := typ.(*graphql.NonNull)
+		assert.False(t, nonNull, "field %s should be optional", name)
+	}
+
+	home := patch.InputFields["home"].(*graphql.InputObject)
+	assert.Equal(t, "PatchAddressPatch_InputObject", home.Name)
+	assert.Equal(t, home, patch.InputFields["work"])
+	assert.IsType(t, &graphql.Scalar{}, home.InputFields["street"])
+}
@@ -0,0 +1,78 @@
+package graphql_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+)
+
+type payloadUser struct {
+	Email string
+}
+
+func TestMutationPayload(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	schema.Query()
+	mutation := schema.Mutation()
+	mutation.FieldFunc("createUser", func(args struct{ Email string }) (*payloadUser, error) {
+		switch args.Email {
+		case "taken@example.com":
+			return nil, schemabuilder.NewUserError([]string{"email"}, "TAKEN", "%s is taken", args.Email)
+		case "":
+			return nil, schemabuilder.UserErrors{
+				{Field: []string{"email"}, Code: "REQUIRED", Message: "email is required"},
+				{Message: "try again"},
+			}
+		case "broken":
+			return nil, errors.New("database unavailable")
+		}
+		return &payloadUser{Email: args.Email}, nil
+	}, schemabuilder.MutationPayload("user"))
+	handler := graphql.HTTPHandler(schema.MustBuild())
+
+	serve := func(body string) string {
+		req, err := http.NewRequest("POST", "/graphql", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Body.String()
+	}
+
+	const selection = `{ user { email } userErrors { field message code } }`
+	cases := []struct {
+		email    string
+		expected string
+	}{
+		{
+			email:    "alice@example.com",
+			expected: `{"data":{"createUser":{"user":{"email":"alice@example.com"},"userErrors":[]}},"errors":null}`,
+		},
+		{
+			email:    "taken@example.com",
+			expected: `{"data":{"createUser":{"user":null,"userErrors":[{"code":"TAKEN","field":["email"],"message":"taken@example.com is taken"}]}},"errors":null}`,
+		},
+		{
+			email:    "",
+			expected: `{"data":{"createUser":{"user":null,"userErrors":[{"code":"REQUIRED","field":["email"],"message":"email is required"},{"code":"","field":[],"message":"try again"}]}},"errors":null}`,
+		},
+		{
+			email:    "broken",
+			expected: `{"data":null,"errors":["createUser: database unavailable"]}`,
+		},
+	}
+	for _, c := range cases {
+		body := `{"query": "mutation { createUser(email: \"` + c.email + `\") ` + selection + ` }"}`
+		if diff := pretty.Compare(serve(body), c.expected); diff != "" {
+			t.Errorf("expected response for %q to match, but received %s", c.email, diff)
+		}
+	}
+}
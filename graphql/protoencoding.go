@@ -0,0 +1,577 @@
+package graphql
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// This file encodes execution results in the protobuf wire format, as a more
+// compact and cheaper to parse alternative to JSON for calls between services.
+//
+// No field names are sent. Instead, both sides derive the message layout from
+// the schema and the query:
+//
+//   - An object is a message. Its included selections, sorted by alias, are
+//     numbered from 1. Null fields are omitted.
+//   - A list is a message with one field per element, numbered 1 for values
+//     and 2 for nulls.
+//   - A union is a message with a single field holding the object, numbered
+//     after its fragment in the selection set.
+//   - An enum is a string holding the name of its value, as the order of the
+//     values of an enum differs between schema builds.
+//   - Scalars are encoded according to their schema type; scalars without a
+//     native protobuf representation, such as Time, are encoded as JSON.
+//
+// Results must therefore be decoded with the exact query that produced them.
+
+const (
+	protoWireVarint  = 0
+	protoWireFixed64 = 1
+	protoWireBytes   = 2
+	protoWireFixed32 = 5
+)
+
+const (
+	protoListValue = 1
+	protoListNull  = 2
+)
+
+type protoScalarKind int
+
+const (
+	protoJSON protoScalarKind = iota
+	protoBool
+	protoInt
+	protoUint
+	protoFloat32
+	protoFloat64
+	protoString
+	protoBytes
+)
+
+// protoScalarKinds maps the schemabuilder scalar types to their encoding. All
+// other scalars are encoded as JSON.
+var protoScalarKinds = map[string]protoScalarKind{
+	"bool":    protoBool,
+	"int":     protoInt,
+	"int8":    protoInt,
+	"int16":   protoInt,
+	"int32":   protoInt,
+	"int64":   protoInt,
+	"uint":    protoUint,
+	"uint8":   protoUint,
+	"uint16":  protoUint,
+	"uint32":  protoUint,
+	"uint64":  protoUint,
+	"float32": protoFloat32,
+	"float64": protoFloat64,
+	"string":  protoString,
+	"bytes":   protoBytes,
+}
+
+// MarshalProto encodes the result of executing selectionSet on the object typ,
+// as returned by an Executor, in the protobuf wire format.
+func MarshalProto(typ Type, selectionSet *SelectionSet, value interface{}) ([]byte, error) {
+	object, ok := typ.(*Object)
+	if !ok {
+		return nil, fmt.Errorf("expected object type, but received %s", typ)
+	}
+	return appendProtoObject(nil, object, selectionSet, value)
+}
+
+// UnmarshalProto decodes a result encoded by MarshalProto for the same typ and
+// selectionSet. Scalars are decoded as bool, int64, uint64, float32, float64,
+// string or []byte values, or as json.RawMessage for scalars encoded as JSON.
+func UnmarshalProto(typ Type, selectionSet *SelectionSet, data []byte) (interface{}, error) {
+	object, ok := typ.(*Object)
+	if !ok {
+		return nil, fmt.Errorf("expected object type, but received %s", typ)
+	}
+	return decodeProtoObject(object, selectionSet, data)
+}
+
+// protoSelections returns the included selections of selectionSet in field
+// number order.
+func protoSelections(selectionSet *SelectionSet) ([]*Selection, error) {
+	flattened, err := Flatten(selectionSet)
+	if err != nil {
+		return nil, err
+	}
+	selections := make([]*Selection, 0, len(flattened))
+	for _, selection := range flattened {
+		ok, err := shouldIncludeNode(selection.Directives)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			selections = append(selections, selection)
+		}
+	}
+	sort.Slice(selections, func(i, j int) bool {
+		return selections[i].Alias < selections[j].Alias
+	})
+	return selections, nil
+}
+
+// protoFieldType returns the type of selection on typ.
+func protoFieldType(typ *Object, selection *Selection) (Type, error) {
+	if selection.Name == "__typename" {
+		return &Scalar{Type: "string"}, nil
+	}
+	field, ok := typ.Fields[selection.Name]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %s on %s", selection.Name, typ.Name)
+	}
+	return field.Type, nil
+}
+
+// isProtoNull returns whether value is null. Scalar resolvers may return nil
+// pointers, which the executor passes through.
+func isProtoNull(value interface{}) bool {
+	v := reflect.ValueOf(value)
+	return !v.IsValid() || (v.Kind() == reflect.Ptr && v.IsNil())
+}
+
+func appendProtoTag(buf []byte, num int, wireType int) []byte {
+	return appendProtoVarint(buf, uint64(num)<<3|uint64(wireType))
+}
+
+func appendProtoVarint(buf []byte, v uint64) []byte {
+	var scratch [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(scratch[:], v)
+	return append(buf, scratch[:n]...)
+}
+
+func appendProtoBytes(buf []byte, num int, data []byte) []byte {
+	buf = appendProtoTag(buf, num, protoWireBytes)
+	buf = appendProtoVarint(buf, uint64(len(data)))
+	return append(buf, data...)
+}
+
+func appendProtoObject(buf []byte, typ *Object, selectionSet *SelectionSet, value interface{}) ([]byte, error) {
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected object, but received %T", value)
+	}
+	selections, err := protoSelections(selectionSet)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, selection := range selections {
+		fieldValue := fields[selection.Alias]
+		if isProtoNull(fieldValue) {
+			continue
+		}
+		fieldType, err := protoFieldType(typ, selection)
+		if err != nil {
+			return nil, err
+		}
+		buf, err = appendProtoField(buf, i+1, fieldType, selection.SelectionSet, fieldValue)
+		if err != nil {
+			return nil, nestPathError(selection.Alias, err)
+		}
+	}
+	return buf, nil
+}
+
+// appendProtoField appends the non-nil value of type typ as field num.
+func appendProtoField(buf []byte, num int, typ Type, selectionSet *SelectionSet, value interface{}) ([]byte, error) {
+	switch typ := typ.(type) {
+	case *NonNull:
+		return appendProtoField(buf, num, typ.Type, selectionSet, value)
+
+	case *Scalar:
+		return appendProtoScalar(buf, num, typ.Type, value)
+
+	case *Enum:
+		for _, name := range typ.Values {
+			if name == value {
+				return appendProtoBytes(buf, num, []byte(name)), nil
+			}
+		}
+		return nil, fmt.Errorf("unknown value %v for enum %s", value, typ.Type)
+
+	case *List:
+		values, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected list, but received %T", value)
+		}
+		var elements []byte
+		for i, element := range values {
+			var err error
+			if isProtoNull(element) {
+				elements = appendProtoTag(elements, protoListNull, protoWireVarint)
+				elements = appendProtoVarint(elements, 0)
+			} else if elements, err = appendProtoField(elements, protoListValue, typ.Type, selectionSet, element); err != nil {
+				return nil, nestPathError(fmt.Sprint(i), err)
+			}
+		}
+		return appendProtoBytes(buf, num, elements), nil
+
+	case *Object:
+		object, err := appendProtoObject(nil, typ, selectionSet, value)
+		if err != nil {
+			return nil, err
+		}
+		return appendProtoBytes(buf, num, object), nil
+
+	case *Union:
+		union, err := appendProtoUnion(typ, selectionSet, value)
+		if err != nil {
+			return nil, err
+		}
+		return appendProtoBytes(buf, num, union), nil
+
+	default:
+		return nil, fmt.Errorf("unknown type %s", typ)
+	}
+}
+
+func appendProtoUnion(typ *Union, selectionSet *SelectionSet, value interface{}) ([]byte, error) {
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected object, but received %T", value)
+	}
+	i, fragment, err := matchProtoFragment(selectionSet, fields)
+	if err != nil {
+		return nil, err
+	}
+	object, err := appendProtoObject(nil, typ.Types[fragment.On], fragment.SelectionSet, value)
+	if err != nil {
+		return nil, err
+	}
+	return appendProtoBytes(nil, i+1, object), nil
+}
+
+// matchProtoFragment finds the fragment of a union selectionSet that produced
+// fields. The executor does not record the type of union values, so it is
+// taken from __typename if selected, and otherwise from the first fragment
+// whose selections match the fields. Fields that no fragment selects, such as
+// the __key of keyed objects, are ignored.
+func matchProtoFragment(selectionSet *SelectionSet, fields map[string]interface{}) (int, *Fragment, error) {
+	fragmentSelections := make([][]*Selection, len(selectionSet.Fragments))
+	selected := make(map[string]bool)
+	for i, fragment := range selectionSet.Fragments {
+		selections, err := protoSelections(fragment.SelectionSet)
+		if err != nil {
+			return 0, nil, err
+		}
+		fragmentSelections[i] = selections
+		for _, selection := range selections {
+			selected[selection.Alias] = true
+		}
+	}
+
+	for i, fragment := range selectionSet.Fragments {
+		selections := fragmentSelections[i]
+
+		// The fields selected by any fragment must be exactly those of the
+		// fragment.
+		matches := true
+		for alias := range fields {
+			if selected[alias] && !containsProtoAlias(selections, alias) {
+				matches = false
+				break
+			}
+		}
+		for _, selection := range selections {
+			if !matches {
+				break
+			}
+			value, ok := fields[selection.Alias]
+			if !ok || (selection.Name == "__typename" && value != fragment.On) {
+				matches = false
+			}
+		}
+		if matches {
+			return i, fragment, nil
+		}
+	}
+	return 0, nil, errors.New("union value does not match any fragment")
+}
+
+// containsProtoAlias returns true if selections, sorted by alias, contain
+// alias.
+func containsProtoAlias(selections []*Selection, alias string) bool {
+	i := sort.Search(len(selections), func(i int) bool {
+		return selections[i].Alias >= alias
+	})
+	return i < len(selections) && selections[i].Alias == alias
+}
+
+func appendProtoScalar(buf []byte, num int, scalarType string, value interface{}) ([]byte, error) {
+	v := reflect.ValueOf(value)
+	kind, ok := protoScalarKinds[scalarType]
+	if !ok {
+		kind = protoJSON
+	}
+
+	switch kind {
+	case protoBool:
+		if v.Kind() == reflect.Bool {
+			buf = appendProtoTag(buf, num, protoWireVarint)
+			if v.Bool() {
+				return appendProtoVarint(buf, 1), nil
+			}
+			return appendProtoVarint(buf, 0), nil
+		}
+
+	case protoInt:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			// Zigzag encode like sint64, so that small negative numbers stay small.
+			n := v.Int()
+			buf = appendProtoTag(buf, num, protoWireVarint)
+			return appendProtoVarint(buf, uint64(n<<1)^uint64(n>>63)), nil
+		}
+
+	case protoUint:
+		switch v.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			buf = appendProtoTag(buf, num, protoWireVarint)
+			return appendProtoVarint(buf, v.Uint()), nil
+		}
+
+	case protoFloat32:
+		if v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64 {
+			buf = appendProtoTag(buf, num, protoWireFixed32)
+			var scratch [4]byte
+			binary.LittleEndian.PutUint32(scratch[:], math.Float32bits(float32(v.Float())))
+			return append(buf, scratch[:]...), nil
+		}
+
+	case protoFloat64:
+		if v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64 {
+			buf = appendProtoTag(buf, num, protoWireFixed64)
+			var scratch [8]byte
+			binary.LittleEndian.PutUint64(scratch[:], math.Float64bits(v.Float()))
+			return append(buf, scratch[:]...), nil
+		}
+
+	case protoString:
+		if v.Kind() == reflect.String {
+			return appendProtoBytes(buf, num, []byte(v.String())), nil
+		}
+
+	case protoBytes:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			return appendProtoBytes(buf, num, v.Bytes()), nil
+		}
+
+	case protoJSON:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		return appendProtoBytes(buf, num, data), nil
+	}
+
+	return nil, fmt.Errorf("cannot encode %T as %s", value, scalarType)
+}
+
+// protoField is a decoded field of a protobuf message.
+type protoField struct {
+	num      int
+	wireType int
+	// value holds varint and fixed values.
+	value uint64
+	// data holds length-delimited values.
+	data []byte
+}
+
+// readProtoFields decodes the fields of the protobuf message data.
+func readProtoFields(data []byte) ([]protoField, error) {
+	var fields []protoField
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, errors.New("malformed field tag")
+		}
+		data = data[n:]
+
+		field := protoField{num: int(tag >> 3), wireType: int(tag & 7)}
+		switch field.wireType {
+		case protoWireVarint:
+			field.value, n = binary.Uvarint(data)
+			if n <= 0 {
+				return nil, errors.New("malformed varint")
+			}
+			data = data[n:]
+
+		case protoWireFixed64:
+			if len(data) < 8 {
+				return nil, errors.New("truncated fixed64")
+			}
+			field.value = binary.LittleEndian.Uint64(data)
+			data = data[8:]
+
+		case protoWireFixed32:
+			if len(data) < 4 {
+				return nil, errors.New("truncated fixed32")
+			}
+			field.value = uint64(binary.LittleEndian.Uint32(data))
+			data = data[4:]
+
+		case protoWireBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < length {
+				return nil, errors.New("malformed length-delimited field")
+			}
+			field.data = data[n : n+int(length)]
+			data = data[n+int(length):]
+
+		default:
+			return nil, fmt.Errorf("unsupported wire type %d", field.wireType)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+func decodeProtoObject(typ *Object, selectionSet *SelectionSet, data []byte) (map[string]interface{}, error) {
+	selections, err := protoSelections(selectionSet)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := readProtoFields(data)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]interface{}, len(selections))
+	for _, selection := range selections {
+		result[selection.Alias] = nil
+	}
+	for _, field := range fields {
+		if field.num < 1 || field.num > len(selections) {
+			return nil, fmt.Errorf("unexpected field number %d for %s", field.num, typ.Name)
+		}
+		selection := selections[field.num-1]
+		fieldType, err := protoFieldType(typ, selection)
+		if err != nil {
+			return nil, err
+		}
+		value, err := decodeProtoField(fieldType, selection.SelectionSet, field)
+		if err != nil {
+			return nil, nestPathError(selection.Alias, err)
+		}
+		result[selection.Alias] = value
+	}
+	return result, nil
+}
+
+// decodeProtoField decodes a field holding a value of type typ.
+func decodeProtoField(typ Type, selectionSet *SelectionSet, field protoField) (interface{}, error) {
+	if nonNull, ok := typ.(*NonNull); ok {
+		typ = nonNull.Type
+	}
+
+	if scalar, ok := typ.(*Scalar); ok {
+		return decodeProtoScalar(scalar.Type, field)
+	}
+
+	if enum, ok := typ.(*Enum); ok {
+		if field.wireType == protoWireBytes {
+			for _, name := range enum.Values {
+				if name == string(field.data) {
+					return name, nil
+				}
+			}
+		}
+		return nil, fmt.Errorf("malformed value for enum %s", enum.Type)
+	}
+
+	if field.wireType != protoWireBytes {
+		return nil, fmt.Errorf("expected length-delimited field for %s", typ)
+	}
+
+	switch typ := typ.(type) {
+	case *List:
+		elements, err := readProtoFields(field.data)
+		if err != nil {
+			return nil, err
+		}
+		values := make([]interface{}, 0, len(elements))
+		for i, element := range elements {
+			switch element.num {
+			case protoListValue:
+				value, err := decodeProtoField(typ.Type, selectionSet, element)
+				if err != nil {
+					return nil, nestPathError(fmt.Sprint(i), err)
+				}
+				values = append(values, value)
+			case protoListNull:
+				values = append(values, nil)
+			default:
+				return nil, fmt.Errorf("unexpected list field number %d", element.num)
+			}
+		}
+		return values, nil
+
+	case *Object:
+		return decodeProtoObject(typ, selectionSet, field.data)
+
+	case *Union:
+		fields, err := readProtoFields(field.data)
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) != 1 || fields[0].wireType != protoWireBytes || fields[0].num < 1 || fields[0].num > len(selectionSet.Fragments) {
+			return nil, fmt.Errorf("malformed value for union %s", typ.Name)
+		}
+		fragment := selectionSet.Fragments[fields[0].num-1]
+		object, ok := typ.Types[fragment.On]
+		if !ok {
+			return nil, fmt.Errorf("unknown type %s in union %s", fragment.On, typ.Name)
+		}
+		return decodeProtoObject(object, fragment.SelectionSet, fields[0].data)
+
+	default:
+		return nil, fmt.Errorf("unknown type %s", typ)
+	}
+}
+
+func decodeProtoScalar(scalarType string, field protoField) (interface{}, error) {
+	kind, ok := protoScalarKinds[scalarType]
+	if !ok {
+		kind = protoJSON
+	}
+
+	wireType := protoWireBytes
+	switch kind {
+	case protoBool, protoInt, protoUint:
+		wireType = protoWireVarint
+	case protoFloat32:
+		wireType = protoWireFixed32
+	case protoFloat64:
+		wireType = protoWireFixed64
+	}
+	if field.wireType != wireType {
+		return nil, fmt.Errorf("unexpected wire type %d for %s", field.wireType, scalarType)
+	}
+
+	switch kind {
+	case protoBool:
+		return field.value != 0, nil
+	case protoInt:
+		return int64(field.value>>1) ^ -int64(field.value&1), nil
+	case protoUint:
+		return field.value, nil
+	case protoFloat32:
+		return math.Float32frombits(uint32(field.value)), nil
+	case protoFloat64:
+		return math.Float64frombits(field.value), nil
+	case protoString:
+		return string(field.data), nil
+	case protoBytes:
+		return append([]byte(nil), field.data...), nil
+	default:
+		return json.RawMessage(append([]byte(nil), field.data...)), nil
+	}
+}
@@ -0,0 +1,236 @@
+package graphql_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/kylelemons/godebug/pretty"
+
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+	"github.com/denkhaus/thunder/internal/testgraphql"
+)
+
+type protoColor int16
+
+func TestProtoEncodingRoundTrip(t *testing.T) {
+	type Cat struct {
+		Name  string
+		Lives uint8
+	}
+	type Dog struct {
+		Name    string
+		GoodBoy bool
+	}
+	type Pet struct {
+		schemabuilder.Union
+
+		*Cat
+		*Dog
+	}
+	type Owner struct {
+		Name     string
+		Age      int32
+		Balance  float64
+		Ratio    float32
+		Nickname *string
+		Born     time.Time
+		Avatar   []byte
+		Color    protoColor
+		Scores   [][]*int64
+	}
+
+	schema := schemabuilder.NewSchema()
+	schema.Enum(protoColor(0), map[string]protoColor{
+		"RED":   0,
+		"GREEN": 1,
+	})
+	query := schema.Query()
+	query.FieldFunc("owner", func() *Owner {
+		one, two := int64(-1), int64(2)
+		return &Owner{
+			Name:    "alice",
+			Age:     30,
+			Balance: -12.5,
+			Ratio:   0.1,
+			Born:    time.Date(2000, 1, 2, 3, 4, 5, 0, time.UTC),
+			Avatar:  []byte{1, 2, 3},
+			Color:   1,
+			Scores:  [][]*int64{{&one, nil}, {}, {&two}},
+		}
+	})
+	query.FieldFunc("pets", func() []*Pet {
+		return []*Pet{
+			{Cat: &Cat{Name: "tom", Lives: 9}},
+			nil,
+			{Dog: &Dog{Name: "rex", GoodBoy: true}},
+		}
+	})
+	query.FieldFunc("nothing", func() *Owner {
+		return nil
+	})
+	builtSchema := schema.MustBuild()
+
+	ctx := context.Background()
+	q := graphql.MustParse(`{
+		owner { name years: age balance ratio nickname born avatar color scores }
+		pets { ... on Cat { name lives } ... on Dog { __typename name goodBoy } }
+		nothing { name }
+		skipped: owner @skip(if: true) { name }
+	}`, nil)
+	if err := graphql.PrepareQuery(ctx, builtSchema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	e := testgraphql.NewExecutorWrapper(t)
+	result, err := e.Execute(ctx, builtSchema.Query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := graphql.MarshalProto(builtSchema.Query, q.SelectionSet, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := graphql.UnmarshalProto(builtSchema.Query, q.SelectionSet, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, err := json.Marshal(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := pretty.Compare(string(actual), string(expected)); diff != "" {
+		t.Errorf("expected decoded result to match, but received %s", diff)
+	}
+	if len(data) >= len(expected) {
+		t.Errorf("expected encoding to be smaller than JSON, but received %d >= %d bytes", len(data), len(expected))
+	}
+
+	// A different query cannot decode the result.
+	other := graphql.MustParse(`{ nothing { name } }`, nil)
+	if err := graphql.PrepareQuery(ctx, builtSchema.Query, other.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := graphql.UnmarshalProto(builtSchema.Query, other.SelectionSet, data); err == nil {
+		t.Error("expected decoding with a different query to fail")
+	}
+}
+
+func TestProtoEncodingEnumAcrossBuilds(t *testing.T) {
+	type protoLetter int16
+	var names []string
+	for c := 'A'; c <= 'P'; c++ {
+		names = append(names, string(c))
+	}
+
+	// The order of enum values differs between builds of the same schema, as
+	// a sender and receiver build it separately.
+	build := func(letter *protoLetter) *graphql.Schema {
+		schema := schemabuilder.NewSchema()
+		values := make(map[string]protoLetter)
+		for i, name := range names {
+			values[name] = protoLetter(i)
+		}
+		schema.Enum(protoLetter(0), values)
+		schema.Query().FieldFunc("letter", func() protoLetter {
+			return *letter
+		})
+		return schema.MustBuild()
+	}
+	var letter protoLetter
+	sender, receiver := build(&letter), build(&letter)
+
+	ctx := context.Background()
+	q := graphql.MustParse(`{ letter }`, nil)
+	if err := graphql.PrepareQuery(ctx, sender.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+	e := testgraphql.NewExecutorWrapper(t)
+	for i, name := range names {
+		letter = protoLetter(i)
+		result, err := e.Execute(ctx, sender.Query, nil, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := graphql.MarshalProto(sender.Query, q.SelectionSet, result)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := graphql.UnmarshalProto(receiver.Query, q.SelectionSet, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := pretty.Compare(decoded, map[string]interface{}{"letter": name}); diff != "" {
+			t.Errorf("expected %s to round trip, but received %s", name, diff)
+		}
+	}
+}
+
+func TestProtoEncodingKeyedUnion(t *testing.T) {
+	type Cat struct {
+		Id   int64
+		Name string
+	}
+	type Dog struct {
+		Id      int64
+		GoodBoy bool
+	}
+	type Pet struct {
+		schemabuilder.Union
+
+		*Cat
+		*Dog
+	}
+
+	schema := schemabuilder.NewSchema()
+	schema.Object("Cat", Cat{}).Key("id")
+	schema.Object("Dog", Dog{}).Key("id")
+	schema.Query().FieldFunc("pets", func() []*Pet {
+		return []*Pet{
+			{Cat: &Cat{Id: 1, Name: "tom"}},
+			{Dog: &Dog{Id: 2, GoodBoy: true}},
+		}
+	})
+	builtSchema := schema.MustBuild()
+
+	ctx := context.Background()
+	q := graphql.MustParse(`{
+		pets { ... on Cat { id name } ... on Dog { id goodBoy } }
+	}`, nil)
+	if err := graphql.PrepareQuery(ctx, builtSchema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	e := testgraphql.NewExecutorWrapper(t)
+	result, err := e.Execute(ctx, builtSchema.Query, nil, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Keyed objects have a __key field, which is not encoded.
+	data, err := graphql.MarshalProto(builtSchema.Query, q.SelectionSet, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := graphql.UnmarshalProto(builtSchema.Query, q.SelectionSet, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := json.Marshal(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"pets":[{"id":1,"name":"tom"},{"goodBoy":true,"id":2}]}`
+	if diff := pretty.Compare(string(actual), expected); diff != "" {
+		t.Errorf("expected decoded result to match, but received %s", diff)
+	}
+}
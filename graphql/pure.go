@@ -0,0 +1,85 @@
+package graphql
+
+import (
+	"context"
+	"reflect"
+	"sync"
+)
+
+// pureCallKey identifies a call of a pure resolver by its source and
+// arguments.
+type pureCallKey struct {
+	field  *Field
+	source interface{}
+	args   interface{}
+}
+
+// pureMemo holds the results of the pure resolvers of a single query, see
+// Field.Pure.
+type pureMemo struct {
+	mu      sync.Mutex
+	results map[pureCallKey]interface{}
+}
+
+type pureMemoKey struct{}
+
+// makePureCallKey returns the key of a call of the pure resolver of unit for
+// source, or false if the call cannot be memoized because its source or
+// arguments are not comparable.
+func makePureCallKey(ctx context.Context, unit *WorkUnit, source interface{}) (*pureMemo, pureCallKey, bool) {
+	if !unit.field.Pure {
+		return nil, pureCallKey{}, false
+	}
+	memo, ok := ctx.Value(pureMemoKey{}).(*pureMemo)
+	if !ok || !comparable(source) || !comparable(unit.selection.Args) {
+		return nil, pureCallKey{}, false
+	}
+	return memo, pureCallKey{field: unit.field, source: source, args: unit.selection.Args}, true
+}
+
+// comparable returns true if v can be used in a map key. A value of a
+// comparable type can still hold an incomparable value in an interface, such
+// as a struct with an interface{} field holding a slice, so v is compared
+// with itself to find out.
+func comparable(v interface{}) (ok bool) {
+	if v == nil {
+		return true
+	}
+	if !reflect.TypeOf(v).Comparable() {
+		return false
+	}
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	return v == v
+}
+
+// memoized returns the memoized result of the pure resolver of unit for
+// source, if any.
+func memoized(ctx context.Context, unit *WorkUnit, source interface{}) (interface{}, bool) {
+	memo, key, ok := makePureCallKey(ctx, unit, source)
+	if !ok {
+		return nil, false
+	}
+	memo.mu.Lock()
+	defer memo.mu.Unlock()
+	result, ok := memo.results[key]
+	return result, ok
+}
+
+// memoize memoizes result, the result of the pure resolver of unit for
+// source.
+func memoize(ctx context.Context, unit *WorkUnit, source interface{}, result interface{}) {
+	memo, key, ok := makePureCallKey(ctx, unit, source)
+	if !ok {
+		return
+	}
+	memo.mu.Lock()
+	defer memo.mu.Unlock()
+	if memo.results == nil {
+		memo.results = make(map[pureCallKey]interface{})
+	}
+	memo.results[key] = result
+}
@@ -0,0 +1,107 @@
+package graphql_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+	"github.com/denkhaus/thunder/internal"
+)
+
+type pureItem struct {
+	Id int64
+}
+
+func TestPureFieldFunc(t *testing.T) {
+	var calls int64
+
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("items", func() []pureItem {
+		return []pureItem{{Id: 1}, {Id: 1}, {Id: 2}}
+	})
+	item := schema.Object("item", pureItem{})
+	item.FieldFunc("label", func(item pureItem, args struct{ Suffix string }) string {
+		atomic.AddInt64(&calls, 1)
+		return string(rune('a'+item.Id)) + args.Suffix
+	}, schemabuilder.Pure)
+	builtSchema := schema.MustBuild()
+
+	ctx, recorder := graphql.RecordExecution(context.Background(), 100)
+	q := graphql.MustParse(`{ items { x: label(suffix: "x") y: label(suffix: "x") z: label(suffix: "z") } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(ctx, builtSchema.Query, q.SelectionSet))
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithDeterministicExecution())
+	result, err := e.Execute(ctx, builtSchema.Query, nil, q)
+	require.NoError(t, err)
+
+	assert.Equal(t, internal.ParseJSON(`{"items": [
+		{"x": "bx", "y": "bx", "z": "bz"},
+		{"x": "bx", "y": "bx", "z": "bz"},
+		{"x": "cx", "y": "cx", "z": "cz"}
+	]}`), internal.AsJSON(result))
+
+	// Only distinct sources and arguments call the function.
+	assert.Equal(t, int64(4), calls)
+	var memoized int
+	for _, event := range recorder.Events() {
+		if event.Memoized {
+			memoized++
+		}
+	}
+	assert.Equal(t, 5, memoized)
+
+	// Results are not memoized across queries.
+	_, err = e.Execute(context.Background(), builtSchema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), calls)
+}
+
+func TestPureFieldFuncBuild(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("withContext", func(ctx context.Context) string {
+		return ""
+	}, schemabuilder.Pure)
+	_, err := schema.Build()
+	assert.Error(t, err)
+
+	schema = schemabuilder.NewSchema()
+	schema.Query().FieldFunc("expensive", func() string {
+		return ""
+	}, schemabuilder.Pure, schemabuilder.Expensive)
+	_, err = schema.Build()
+	assert.Error(t, err)
+}
+
+// pureTagged is comparable, but holds a slice in an interface.
+type pureTagged struct {
+	Id   int64
+	tags interface{}
+}
+
+func TestPureFieldFuncIncomparableSource(t *testing.T) {
+	var calls int64
+
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("items", func() []pureTagged {
+		return []pureTagged{{Id: 1, tags: []string{"a"}}, {Id: 1, tags: []string{"a"}}}
+	})
+	item := schema.Object("item", pureTagged{})
+	item.FieldFunc("label", func(item pureTagged) string {
+		atomic.AddInt64(&calls, 1)
+		return item.tags.([]string)[0]
+	}, schemabuilder.Pure)
+	builtSchema := schema.MustBuild()
+
+	// Sources that cannot be map keys are resolved without memoization.
+	q := graphql.MustParse(`{ items { label } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet))
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+	result, err := e.Execute(context.Background(), builtSchema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Equal(t, internal.ParseJSON(`{"items": [{"label": "a"}, {"label": "a"}]}`), internal.AsJSON(result))
+	assert.Equal(t, int64(2), calls)
+}
@@ -0,0 +1,100 @@
+package graphql
+
+import (
+	"container/list"
+	"sync"
+)
+
+// QueryCache caches parsed queries by source, so that repeated executions of
+// the same operation, such as persisted operations or subscriptions reused by
+// many clients, skip parsing the document and checking its variable
+// definitions. Only the variables of every execution are bound again, see
+// QueryCache.Parse.
+//
+// The cache holds at most size queries, and evicts the least recently used
+// query when it is full. A QueryCache is safe for concurrent use, and can be
+// shared by handlers with WithHTTPQueryCache and WithQueryCache.
+type QueryCache struct {
+	size int
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type queryCacheEntry struct {
+	source string
+	op     *operation
+}
+
+// NewQueryCache creates a QueryCache holding at most size queries.
+func NewQueryCache(size int) *QueryCache {
+	return &QueryCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Parse parses source like Parse, reusing the parsed document of an earlier
+// call with the same source, and binds vars.
+//
+// Sources that fail to parse are not cached. Errors in the variable
+// definitions are cached, and returned for every call.
+func (c *QueryCache) Parse(source string, vars map[string]interface{}) (*Query, error) {
+	op, err := c.operation(source)
+	if err != nil {
+		return nil, err
+	}
+	return op.bind(vars)
+}
+
+// Len returns the number of cached queries.
+func (c *QueryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// operation returns the cached operation of source, or parses and caches it.
+func (c *QueryCache) operation(source string) (*operation, error) {
+	c.mu.Lock()
+	if elem, ok := c.entries[source]; ok {
+		c.order.MoveToFront(elem)
+		c.mu.Unlock()
+		return elem.Value.(*queryCacheEntry).op, nil
+	}
+	c.mu.Unlock()
+
+	// Parse without holding the lock, so slow parses do not block hits.
+	op, err := parseOperation(source)
+	if err != nil {
+		return nil, err
+	}
+	if c.size <= 0 {
+		return op, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[source]; ok {
+		// Another call parsed source concurrently.
+		c.order.MoveToFront(elem)
+		return elem.Value.(*queryCacheEntry).op, nil
+	}
+	c.entries[source] = c.order.PushFront(&queryCacheEntry{source: source, op: op})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*queryCacheEntry).source)
+	}
+	return op, nil
+}
+
+// parseQuery parses source with cache, or with Parse if cache is nil.
+func parseQuery(cache *QueryCache, source string, vars map[string]interface{}) (*Query, error) {
+	if cache == nil {
+		return Parse(source, vars)
+	}
+	return cache.Parse(source, vars)
+}
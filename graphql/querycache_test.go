@@ -0,0 +1,80 @@
+package graphql_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/graphql"
+)
+
+func TestQueryCache(t *testing.T) {
+	cache := graphql.NewQueryCache(2)
+
+	source := `query Users($first: Int = 10, $name: String) {
+		users(first: $first, name: $name) { ...UserFields }
+	}
+	fragment UserFields on User { id name }`
+	for _, vars := range []map[string]interface{}{
+		nil,
+		{"first": float64(5)},
+		{"first": float64(3), "name": "alice"},
+	} {
+		cached, err := cache.Parse(source, vars)
+		require.NoError(t, err)
+		parsed, err := graphql.Parse(source, vars)
+		require.NoError(t, err)
+		assert.Equal(t, parsed, cached)
+	}
+	assert.Equal(t, 1, cache.Len())
+
+	// Default values are bound for every call.
+	query, err := cache.Parse(source, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"first": float64(10)}, query.Selections[0].UnparsedArgs)
+
+	// Errors in variable definitions are cached, with the operation's name.
+	invalid := `query Invalid($x: Int! = 1) { users(first: $x) { id } }`
+	for i := 0; i < 2; i++ {
+		query, err := cache.Parse(invalid, nil)
+		assert.EqualError(t, err, "required variable cannot provide a default value: $x")
+		require.NotNil(t, query)
+		assert.Equal(t, "Invalid", query.Name)
+	}
+	assert.Equal(t, 2, cache.Len())
+
+	// Sources that fail to parse are not cached.
+	_, err = cache.Parse(`{ users `, nil)
+	assert.Error(t, err)
+	assert.Equal(t, 2, cache.Len())
+
+	// The least recently used query is evicted.
+	_, err = cache.Parse(source, nil)
+	require.NoError(t, err)
+	_, err = cache.Parse(`{ me { id } }`, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, cache.Len())
+	_, err = cache.Parse(invalid, nil)
+	assert.Error(t, err)
+	assert.Equal(t, 2, cache.Len())
+}
+
+func TestQueryCacheConcurrent(t *testing.T) {
+	cache := graphql.NewQueryCache(10)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			query, err := cache.Parse(fmt.Sprintf(`query Q($id: Int = %d) { user(id: $id) { name } }`, i%5), nil)
+			if assert.NoError(t, err) {
+				assert.Equal(t, map[string]interface{}{"id": float64(i % 5)}, query.Selections[0].UnparsedArgs)
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, 5, cache.Len())
+}
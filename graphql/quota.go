@@ -0,0 +1,286 @@
+package graphql
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// QuotaAction is what QuotaMiddleware does with operations of a client over
+// its budget.
+type QuotaAction int
+
+const (
+	// QuotaReject fails operations over budget with a client error.
+	QuotaReject QuotaAction = iota
+	// QuotaDegrade runs operations over budget with a context for which
+	// QuotaExceeded returns true, so resolvers can serve cheaper results, eg.
+	// from caches or without optional data.
+	QuotaDegrade
+)
+
+// QuotaBudget is the cost, see ComputeCost, a client may spend per minute and
+// per day. A zero budget is unlimited.
+type QuotaBudget struct {
+	PerMinute int `json:"perMinute"`
+	PerDay    int `json:"perDay"`
+}
+
+// QuotaPolicy configures Quotas.
+type QuotaPolicy struct {
+	// Identify returns the API client of an operation, eg. from an API key
+	// added to ctx by the HTTP handler. Operations of the empty client are
+	// neither metered nor limited. It is required.
+	Identify func(ctx context.Context) string
+	// Budget is the budget of every client.
+	Budget QuotaBudget
+	// Budgets, if set, returns the budget of a client, eg. from its plan,
+	// instead of Budget.
+	Budgets func(client string) QuotaBudget
+	// Action is what happens to operations over budget.
+	Action QuotaAction
+	// OnExceeded, if set, is called for every operation over budget, with the
+	// usage of its client.
+	OnExceeded func(ctx context.Context, usage QuotaUsage)
+}
+
+// QuotaUsage is the usage of a client in the current minute and day.
+type QuotaUsage struct {
+	Client string `json:"client"`
+	// Operations is the number of operations run today, including degraded
+	// operations but not rejected ones.
+	Operations int `json:"operations"`
+	// MinuteCost and DayCost are the cost spent in the current minute and day.
+	MinuteCost int         `json:"minuteCost"`
+	DayCost    int         `json:"dayCost"`
+	Budget     QuotaBudget `json:"budget"`
+}
+
+// Quotas meters the cost of the operations of every API client, and enforces
+// their budgets, see QuotaMiddleware. Minutes and days are counted in UTC. A
+// Quotas is safe for concurrent use, and is only kept in memory; every
+// server enforces its own quotas. Clients without operations today are
+// forgotten.
+type Quotas struct {
+	policy QuotaPolicy
+	now    func() time.Time
+
+	mu      sync.Mutex
+	clients map[string]*clientQuota
+	// pruned is the day clients without operations were last forgotten.
+	pruned time.Time
+}
+
+// clientQuota is the usage of a single client.
+type clientQuota struct {
+	minute, day         time.Time
+	minuteCost, dayCost int
+	operations          int
+}
+
+// NewQuotas creates Quotas enforcing policy. It panics if policy.Identify is
+// not set.
+func NewQuotas(policy QuotaPolicy) *Quotas {
+	if policy.Identify == nil {
+		panic("QuotaPolicy.Identify must be set")
+	}
+	return &Quotas{
+		policy:  policy,
+		now:     time.Now,
+		clients: make(map[string]*clientQuota),
+	}
+}
+
+// budget returns the budget of client.
+func (q *Quotas) budget(client string) QuotaBudget {
+	if q.policy.Budgets != nil {
+		return q.policy.Budgets(client)
+	}
+	return q.policy.Budget
+}
+
+// windows returns the current minute and day.
+func (q *Quotas) windows() (minute, day time.Time) {
+	now := q.now().UTC()
+	return now.Truncate(time.Minute), time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// advance starts the windows minute and day of c, if they are new.
+func (c *clientQuota) advance(minute, day time.Time) {
+	if !c.minute.Equal(minute) {
+		c.minute, c.minuteCost = minute, 0
+	}
+	if !c.day.Equal(day) {
+		c.day, c.dayCost, c.operations = day, 0, 0
+	}
+}
+
+// current returns the usage of client, starting new windows as needed. Once
+// a day, it forgets the clients without operations on that day. q.mu must
+// be held.
+func (q *Quotas) current(client string) *clientQuota {
+	minute, day := q.windows()
+	if !q.pruned.Equal(day) {
+		for name, c := range q.clients {
+			if !c.day.Equal(day) {
+				delete(q.clients, name)
+			}
+		}
+		q.pruned = day
+	}
+
+	c, ok := q.clients[client]
+	if !ok {
+		c = &clientQuota{}
+		q.clients[client] = c
+	}
+	c.advance(minute, day)
+	return c
+}
+
+// snapshot returns a copy of the usage of client in the current windows,
+// without tracking client. q.mu must be held.
+func (q *Quotas) snapshot(client string) clientQuota {
+	var c clientQuota
+	if existing, ok := q.clients[client]; ok {
+		c = *existing
+	}
+	c.advance(q.windows())
+	return c
+}
+
+// usage returns the usage of client. q.mu must be held.
+func (q *Quotas) usage(client string, c *clientQuota) QuotaUsage {
+	return QuotaUsage{
+		Client:     client,
+		Operations: c.operations,
+		MinuteCost: c.minuteCost,
+		DayCost:    c.dayCost,
+		Budget:     q.budget(client),
+	}
+}
+
+// charge charges cost to client, unless it exceeds the client's budget and
+// the policy rejects such operations. It returns the usage of the client and
+// whether it is over budget. Negative costs are charged as 0, so that no
+// operation refunds the budget of its client, and usage saturates instead of
+// wrapping around.
+func (q *Quotas) charge(client string, cost int) (QuotaUsage, bool) {
+	if cost < 0 {
+		cost = 0
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	c := q.current(client)
+	budget := q.budget(client)
+	minuteCost, dayCost := addCost(c.minuteCost, cost), addCost(c.dayCost, cost)
+	exceeded := (budget.PerMinute > 0 && minuteCost > budget.PerMinute) ||
+		(budget.PerDay > 0 && dayCost > budget.PerDay)
+	if !exceeded || q.policy.Action == QuotaDegrade {
+		c.minuteCost = minuteCost
+		c.dayCost = dayCost
+		c.operations++
+	}
+	return q.usage(client, c), exceeded
+}
+
+// Usage returns the usage of client.
+func (q *Quotas) Usage(client string) QuotaUsage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	c := q.snapshot(client)
+	return q.usage(client, &c)
+}
+
+// AllUsage returns the usage of every client that ran an operation today,
+// sorted by client.
+func (q *Quotas) AllUsage() []QuotaUsage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	_, day := q.windows()
+	clients := make([]string, 0, len(q.clients))
+	for client, c := range q.clients {
+		if c.day.Equal(day) {
+			clients = append(clients, client)
+		}
+	}
+	sort.Strings(clients)
+
+	usages := make([]QuotaUsage, 0, len(clients))
+	for _, client := range clients {
+		c := q.snapshot(client)
+		usages = append(usages, q.usage(client, &c))
+	}
+	return usages
+}
+
+// Reset forgets the usage of client, eg. after upgrading its plan.
+func (q *Quotas) Reset(client string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.clients, client)
+}
+
+type quotaExceededKey struct{}
+
+// QuotaExceeded returns true if ctx is the context of an operation degraded
+// by QuotaMiddleware.
+func QuotaExceeded(ctx context.Context) bool {
+	exceeded, _ := ctx.Value(quotaExceededKey{}).(bool)
+	return exceeded
+}
+
+// QuotaMiddleware charges the cost of every operation, see ComputeCost, to
+// its client, and rejects or degrades operations of clients over their
+// budget according to the policy of quotas. Operations over budget have the
+// usage of their client in the "quota" key of the response extensions.
+//
+// Every run of a subscription is charged, including reruns.
+func QuotaMiddleware(schema *Schema, quotas *Quotas) MiddlewareFunc {
+	return func(input *ComputationInput, next MiddlewareNextFunc) *ComputationOutput {
+		client := quotas.policy.Identify(input.Ctx)
+		if client == "" {
+			return next(input)
+		}
+
+		report, err := computeOperationCost(schema, input.ParsedQuery)
+		if err != nil {
+			return &ComputationOutput{
+				Metadata:   make(map[string]interface{}),
+				Extensions: make(map[string]interface{}),
+				Error:      err,
+			}
+		}
+
+		usage, exceeded := quotas.charge(client, report.Total)
+		if !exceeded {
+			return next(input)
+		}
+		if quotas.policy.OnExceeded != nil {
+			quotas.policy.OnExceeded(input.Ctx, usage)
+		}
+
+		var output *ComputationOutput
+		if quotas.policy.Action == QuotaDegrade {
+			inputCopy := *input
+			inputCopy.Ctx = context.WithValue(input.Ctx, quotaExceededKey{}, true)
+			output = next(&inputCopy)
+		} else {
+			output = &ComputationOutput{
+				Metadata:   make(map[string]interface{}),
+				Extensions: make(map[string]interface{}),
+				Error:      NewClientError("quota exceeded for client %s", client),
+			}
+		}
+
+		if output.Extensions == nil {
+			output.Extensions = make(map[string]interface{})
+		}
+		output.Extensions["quota"] = usage
+		return output
+	}
+}
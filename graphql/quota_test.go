@@ -0,0 +1,197 @@
+package graphql_test
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kylelemons/godebug/pretty"
+
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+)
+
+type quotaClientKey struct{}
+
+func testQuotaHandler(quotas *graphql.Quotas) func(client, body string) string {
+	schema := makeCostSchema()
+	handler := graphql.HTTPHandler(schema, graphql.QuotaMiddleware(schema, quotas))
+
+	return func(client, body string) string {
+		req, err := http.NewRequest("POST", "/graphql", strings.NewReader(body))
+		if err != nil {
+			panic(err)
+		}
+		req = req.WithContext(context.WithValue(req.Context(), quotaClientKey{}, client))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Body.String()
+	}
+}
+
+func identifyQuotaClient(ctx context.Context) string {
+	client, _ := ctx.Value(quotaClientKey{}).(string)
+	return client
+}
+
+func TestQuotaMiddlewareReject(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 30, 0, time.UTC)
+	var exceeded []graphql.QuotaUsage
+	quotas := graphql.NewQuotas(graphql.QuotaPolicy{
+		Identify: identifyQuotaClient,
+		Budget:   graphql.QuotaBudget{PerMinute: 4, PerDay: 6},
+		OnExceeded: func(ctx context.Context, usage graphql.QuotaUsage) {
+			exceeded = append(exceeded, usage)
+		},
+	})
+	graphql.SetQuotasClock(quotas, func() time.Time { return now })
+	serve := testQuotaHandler(quotas)
+
+	const query = `{"query": "{ item { name } }"}`
+	const ok = `{"data":{"item":{"name":"a"}},"errors":null}`
+	const rejected = `{"data":null,"errors":["quota exceeded for client a"],"extensions":{"quota":{"client":"a","operations":2,"minuteCost":4,"dayCost":4,"budget":{"perMinute":4,"perDay":6}}}}`
+
+	// Every query costs 2, so the minute budget allows two queries.
+	for i, expected := range []string{ok, ok, rejected} {
+		if diff := pretty.Compare(serve("a", query), expected); diff != "" {
+			t.Errorf("query %d: expected response to match, but received %s", i, diff)
+		}
+	}
+	if len(exceeded) != 1 {
+		t.Errorf("expected OnExceeded to be called once, but it was called %d times", len(exceeded))
+	}
+
+	// Other clients and anonymous queries have their own budget.
+	if diff := pretty.Compare(serve("b", query), ok); diff != "" {
+		t.Errorf("expected response to match, but received %s", diff)
+	}
+	for i := 0; i < 5; i++ {
+		if diff := pretty.Compare(serve("", query), ok); diff != "" {
+			t.Errorf("expected response to match, but received %s", diff)
+		}
+	}
+
+	// The next minute allows one more query before the day budget runs out.
+	now = now.Add(time.Minute)
+	if diff := pretty.Compare(serve("a", query), ok); diff != "" {
+		t.Errorf("expected response to match, but received %s", diff)
+	}
+	if diff := pretty.Compare(serve("a", query), `{"data":null,"errors":["quota exceeded for client a"],"extensions":{"quota":{"client":"a","operations":3,"minuteCost":2,"dayCost":6,"budget":{"perMinute":4,"perDay":6}}}}`); diff != "" {
+		t.Errorf("expected response to match, but received %s", diff)
+	}
+
+	if diff := pretty.Compare(quotas.AllUsage(), []graphql.QuotaUsage{
+		{Client: "a", Operations: 3, MinuteCost: 2, DayCost: 6, Budget: graphql.QuotaBudget{PerMinute: 4, PerDay: 6}},
+		{Client: "b", Operations: 1, MinuteCost: 0, DayCost: 2, Budget: graphql.QuotaBudget{PerMinute: 4, PerDay: 6}},
+	}); diff != "" {
+		t.Errorf("expected usage to match, but received %s", diff)
+	}
+
+	// The next day resets all budgets.
+	now = now.Add(24 * time.Hour)
+	if diff := pretty.Compare(serve("a", query), ok); diff != "" {
+		t.Errorf("expected response to match, but received %s", diff)
+	}
+	if diff := pretty.Compare(quotas.Usage("a"), graphql.QuotaUsage{
+		Client: "a", Operations: 1, MinuteCost: 2, DayCost: 2, Budget: graphql.QuotaBudget{PerMinute: 4, PerDay: 6},
+	}); diff != "" {
+		t.Errorf("expected usage to match, but received %s", diff)
+	}
+
+	// Clients idle since the previous day are forgotten, and reading the
+	// usage of a client does not track it.
+	if diff := pretty.Compare(quotas.Usage("c"), graphql.QuotaUsage{
+		Client: "c", Budget: graphql.QuotaBudget{PerMinute: 4, PerDay: 6},
+	}); diff != "" {
+		t.Errorf("expected usage to match, but received %s", diff)
+	}
+	if n := graphql.QuotaClients(quotas); n != 1 {
+		t.Errorf("expected 1 tracked client, but got %d", n)
+	}
+	if usages := quotas.AllUsage(); len(usages) != 1 || usages[0].Client != "a" {
+		t.Errorf("expected only the usage of a, but received %v", usages)
+	}
+}
+
+func TestQuotasIgnoreNegativeCosts(t *testing.T) {
+	quotas := graphql.NewQuotas(graphql.QuotaPolicy{
+		Identify: identifyQuotaClient,
+		Budget:   graphql.QuotaBudget{PerMinute: 4},
+	})
+
+	if _, exceeded := graphql.ChargeQuota(quotas, "a", 4); exceeded {
+		t.Error("expected a cost of 4 to be within budget")
+	}
+	// Negative and wrapped costs do not refund the budget.
+	for _, cost := range []int{-4, math.MinInt32} {
+		if usage, exceeded := graphql.ChargeQuota(quotas, "a", cost); exceeded || usage.MinuteCost != 4 {
+			t.Errorf("cost %d: expected usage to stay at 4, but received %+v", cost, usage)
+		}
+	}
+	if usage, exceeded := graphql.ChargeQuota(quotas, "a", 1); !exceeded || usage.MinuteCost != 4 {
+		t.Errorf("expected the budget to stay exhausted, but received %+v", usage)
+	}
+}
+
+func TestQuotasRequireIdentify(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected NewQuotas to panic without Identify")
+		}
+	}()
+	graphql.NewQuotas(graphql.QuotaPolicy{Budget: graphql.QuotaBudget{PerMinute: 1}})
+}
+
+func TestQuotaMiddlewareDegrade(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("value", func(ctx context.Context) string {
+		if graphql.QuotaExceeded(ctx) {
+			return "cached"
+		}
+		return "fresh"
+	})
+	built := schema.MustBuild()
+
+	quotas := graphql.NewQuotas(graphql.QuotaPolicy{
+		Identify: identifyQuotaClient,
+		Budgets: func(client string) graphql.QuotaBudget {
+			if client == "premium" {
+				return graphql.QuotaBudget{}
+			}
+			return graphql.QuotaBudget{PerDay: 1}
+		},
+		Action: graphql.QuotaDegrade,
+	})
+	handler := graphql.HTTPHandler(built, graphql.QuotaMiddleware(built, quotas))
+	serve := func(client string) string {
+		req, err := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "{ value }"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req = req.WithContext(context.WithValue(req.Context(), quotaClientKey{}, client))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Body.String()
+	}
+
+	if diff := pretty.Compare(serve("free"), `{"data":{"value":"fresh"},"errors":null}`); diff != "" {
+		t.Errorf("expected response to match, but received %s", diff)
+	}
+	if diff := pretty.Compare(serve("free"), `{"data":{"value":"cached"},"errors":null,"extensions":{"quota":{"client":"free","operations":2,"minuteCost":2,"dayCost":2,"budget":{"perMinute":0,"perDay":1}}}}`); diff != "" {
+		t.Errorf("expected response to match, but received %s", diff)
+	}
+
+	// Unlimited clients are metered, but never degraded.
+	for i := 0; i < 3; i++ {
+		if diff := pretty.Compare(serve("premium"), `{"data":{"value":"fresh"},"errors":null}`); diff != "" {
+			t.Errorf("expected response to match, but received %s", diff)
+		}
+	}
+	if usage := quotas.Usage("premium"); usage.Operations != 3 || usage.DayCost != 3 {
+		t.Errorf("expected 3 operations costing 3, but received %+v", usage)
+	}
+}
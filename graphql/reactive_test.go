@@ -4,12 +4,14 @@ import (
 	"context"
 	"errors"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/denkhaus/thunder/graphql"
 	"github.com/denkhaus/thunder/graphql/schemabuilder"
 	"github.com/denkhaus/thunder/reactive"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -142,3 +144,72 @@ func (c *concurrencyManager) Dec() (ok bool) {
 func (c *concurrencyManager) Close() {
 	close(c.done)
 }
+
+func TestThrottleDirective(t *testing.T) {
+	fastResource := reactive.NewResource()
+	slowResource := reactive.NewResource()
+	var fast, slow int64
+
+	schema := schemabuilder.NewSchema()
+	query := schema.Query()
+	query.FieldFunc("fast", func(ctx context.Context) int64 {
+		reactive.AddDependency(ctx, fastResource, nil)
+		return atomic.AddInt64(&fast, 1)
+	})
+	query.FieldFunc("slow", func(ctx context.Context) int64 {
+		reactive.AddDependency(ctx, slowResource, nil)
+		return atomic.AddInt64(&slow, 1)
+	})
+	schema.Mutation()
+	builtSchema := schema.MustBuild()
+
+	run := func(q string) (chan interface{}, *reactive.Rerunner) {
+		query := graphql.MustParse(q, nil)
+		require.NoError(t, graphql.PrepareQuery(context.Background(), builtSchema.Query, query.SelectionSet))
+
+		results := make(chan interface{}, 10)
+		rerunner := reactive.NewRerunner(context.Background(), func(ctx context.Context) (interface{}, error) {
+			e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+			result, err := e.Execute(ctx, builtSchema.Query, nil, query)
+			if err != nil {
+				results <- err
+				return nil, err
+			}
+			results <- result
+			return nil, nil
+		}, 0, false)
+		return results, rerunner
+	}
+	expect := func(results chan interface{}) interface{} {
+		select {
+		case result := <-results:
+			return result
+		case <-time.After(2 * time.Second):
+			t.Fatal("expected result")
+			return nil
+		}
+	}
+
+	results, rerunner := run(`{ fast slow @throttle(ms: 500) }`)
+	defer rerunner.Stop()
+	start := time.Now()
+	assert.Equal(t, map[string]interface{}{"fast": int64(1), "slow": int64(1)}, expect(results))
+
+	// The throttled field keeps its value while the rest updates.
+	fastResource.Strobe()
+	slowResource.Strobe()
+	assert.Equal(t, map[string]interface{}{"fast": int64(2), "slow": int64(1)}, expect(results))
+
+	// It updates once the interval passed, rerunning the rest too.
+	assert.Equal(t, map[string]interface{}{"fast": int64(3), "slow": int64(2)}, expect(results))
+	assert.True(t, time.Since(start) >= 500*time.Millisecond)
+
+	// The interval must be a positive number, which is checked when the
+	// query is prepared.
+	for _, q := range []string{`{ slow @throttle(ms: 0) }`, `{ slow @throttle }`, `{ slow @throttle(ms: "1s") }`} {
+		query := graphql.MustParse(q, nil)
+		err := graphql.PrepareQuery(context.Background(), builtSchema.Query, query.SelectionSet)
+		require.Error(t, err, q)
+		assert.Contains(t, err.Error(), `error parsing directives for "slow"`, q)
+	}
+}
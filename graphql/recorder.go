@@ -0,0 +1,144 @@
+package graphql
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ExecutionEvent describes a resolver call of a query, see RecordExecution.
+type ExecutionEvent struct {
+	// Path is the response path of the field, eg. ["users", "0", "name"]. For
+	// batch resolvers, it is the path of the first source.
+	Path []string
+	// Type is the name of the object type of the field.
+	Type string
+	// Field is the name of the field.
+	Field string
+	// Resolver identifies the function resolving the field, see
+	// Field.ResolverName.
+	Resolver string
+	// Duration is how long the resolver ran.
+	Duration time.Duration
+	// Sources is the number of sources resolved by the call.
+	Sources int
+	// Batch is true if the field was resolved by a batch resolver.
+	Batch bool
+	// CacheHit is true if the result was read from the field cache, see
+	// WithFieldCache, instead of calling the resolver.
+	CacheHit bool
+	// Fallback is true if the result is the fallback of an open circuit
+	// breaker, see WithCircuitBreaker.
+	Fallback bool
+	// Memoized is true if the result was reused from an identical call of a
+	// pure resolver in the same query, see Field.Pure.
+	Memoized bool
+	// Error is the error returned by the resolver, if any.
+	Error error
+}
+
+// ExecutionRecorder holds the events of the queries run with the context
+// returned by RecordExecution.
+type ExecutionRecorder struct {
+	maxEvents int
+
+	mu      sync.Mutex
+	events  []ExecutionEvent
+	dropped int
+}
+
+type executionRecorderKey struct{}
+
+// RecordExecution records an ExecutionEvent for every resolver call of the
+// queries executed with ctx, for example to audit resolved fields or to dump
+// the execution of failed queries. At most maxEvents events are kept; later
+// events are only counted as dropped.
+//
+// Fields reused from the previous run of a reactive query are not resolved
+// again, and so are not recorded.
+func RecordExecution(ctx context.Context, maxEvents int) (context.Context, *ExecutionRecorder) {
+	recorder := &ExecutionRecorder{maxEvents: maxEvents}
+	return context.WithValue(ctx, executionRecorderKey{}, recorder), recorder
+}
+
+// Events returns the recorded events, in the order the resolvers finished.
+func (r *ExecutionRecorder) Events() []ExecutionEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]ExecutionEvent(nil), r.events...)
+}
+
+// Dropped returns the number of events dropped after maxEvents events were
+// recorded.
+func (r *ExecutionRecorder) Dropped() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.dropped
+}
+
+func (r *ExecutionRecorder) record(event ExecutionEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.events) >= r.maxEvents {
+		r.dropped++
+		return
+	}
+	r.events = append(r.events, event)
+}
+
+// ExecutionRecorderMiddleware records the execution of every query, see
+// RecordExecution, and calls report with the events once the query finishes.
+// report can log the events of failed queries, or write every query to an
+// audit log. The query and variables passed to report have the values of
+// sensitive arguments of schema redacted, see RedactQuery.
+func ExecutionRecorderMiddleware(schema *Schema, maxEvents int, report func(input *ComputationInput, output *ComputationOutput, recorder *ExecutionRecorder)) MiddlewareFunc {
+	return func(input *ComputationInput, next MiddlewareNextFunc) *ComputationOutput {
+		inputCopy := *input
+		var recorder *ExecutionRecorder
+		inputCopy.Ctx, recorder = RecordExecution(input.Ctx, maxEvents)
+		output := next(&inputCopy)
+
+		redacted := *input
+		redacted.Query, redacted.Variables = RedactQuery(schema, input.Query, input.Variables)
+		report(&redacted, output, recorder)
+		return output
+	}
+}
+
+// startExecutionEvent starts an event for a resolver call of unit if ctx
+// records its execution, and returns a function that records the event once
+// the call finishes. dest is the destination of the first source.
+func startExecutionEvent(ctx context.Context, unit *WorkUnit, dest *outputNode) func(event ExecutionEvent) {
+	recorder, ok := ctx.Value(executionRecorderKey{}).(*ExecutionRecorder)
+	if !ok {
+		return func(ExecutionEvent) {}
+	}
+
+	start := time.Now()
+	return func(event ExecutionEvent) {
+		event.Path = responsePath(dest)
+		event.Type = unit.objectName
+		event.Field = unit.selection.Name
+		event.Resolver = unit.field.ResolverName
+		event.Duration = time.Since(start)
+		recorder.record(event)
+	}
+}
+
+// responsePath returns the response path of dest, from the root of the
+// response.
+func responsePath(dest *outputNode) []string {
+	if dest == nil {
+		return nil
+	}
+	var path []string
+	for cur := dest.pathTracker; cur != nil && cur.parent != nil; cur = cur.parent {
+		if cur.path != "" {
+			path = append(path, cur.path)
+		}
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
@@ -0,0 +1,102 @@
+package graphql_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/batch"
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+)
+
+type recorderItem struct {
+	Id int64
+}
+
+func makeRecorderSchema() *graphql.Schema {
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("items", func() []*recorderItem {
+		return []*recorderItem{{Id: 1}, {Id: 2}}
+	})
+	schema.Query().FieldFunc("broken", func() (string, error) {
+		return "", errors.New("broken")
+	})
+	item := schema.Object("item", recorderItem{})
+	item.BatchFieldFunc("double", func(ctx context.Context, items map[batch.Index]*recorderItem) map[batch.Index]int64 {
+		out := make(map[batch.Index]int64, len(items))
+		for i, item := range items {
+			out[i] = item.Id * 2
+		}
+		return out
+	})
+	return schema.MustBuild()
+}
+
+func TestRecordExecution(t *testing.T) {
+	schema := makeRecorderSchema()
+	ctx, recorder := graphql.RecordExecution(context.Background(), 10)
+
+	q := graphql.MustParse(`{ items { id double } }`, nil)
+	require.NoError(t, graphql.PrepareQuery(ctx, schema.Query, q.SelectionSet))
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler(), graphql.WithDeterministicExecution())
+	_, err := e.Execute(ctx, schema.Query, nil, q)
+	require.NoError(t, err)
+
+	events := recorder.Events()
+	require.Len(t, events, 4)
+	for i := range events {
+		assert.True(t, events[i].Duration >= 0)
+		events[i].Duration = 0
+	}
+
+	assert.True(t, strings.HasPrefix(events[0].Resolver, "github.com/denkhaus/thunder/graphql_test.makeRecorderSchema.func"), events[0].Resolver)
+	assert.True(t, strings.HasPrefix(events[3].Resolver, "github.com/denkhaus/thunder/graphql_test.makeRecorderSchema.func"), events[3].Resolver)
+	events[0].Resolver, events[3].Resolver = "", ""
+	assert.Equal(t, []graphql.ExecutionEvent{
+		{Path: []string{"items"}, Type: "Query", Field: "items", Sources: 1},
+		{Path: []string{"items", "0", "id"}, Type: "item", Field: "id", Sources: 1},
+		{Path: []string{"items", "1", "id"}, Type: "item", Field: "id", Sources: 1},
+		{Path: []string{"items", "0", "double"}, Type: "item", Field: "double", Sources: 2, Batch: true},
+	}, events)
+	assert.Equal(t, 0, recorder.Dropped())
+
+	// Events past the limit are dropped.
+	ctx, recorder = graphql.RecordExecution(context.Background(), 1)
+	_, err = e.Execute(ctx, schema.Query, nil, q)
+	require.NoError(t, err)
+	assert.Len(t, recorder.Events(), 1)
+	assert.Equal(t, 3, recorder.Dropped())
+}
+
+func TestExecutionRecorderMiddleware(t *testing.T) {
+	schema := makeRecorderSchema()
+	e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+
+	var failed []graphql.ExecutionEvent
+	middleware := graphql.ExecutionRecorderMiddleware(schema, 100, func(input *graphql.ComputationInput, output *graphql.ComputationOutput, recorder *graphql.ExecutionRecorder) {
+		if output.Error != nil {
+			failed = recorder.Events()
+		}
+	})
+
+	q := graphql.MustParse(`{ broken }`, nil)
+	require.NoError(t, graphql.PrepareQuery(context.Background(), schema.Query, q.SelectionSet))
+	output := graphql.RunMiddlewares([]graphql.MiddlewareFunc{
+		middleware,
+		func(input *graphql.ComputationInput, next graphql.MiddlewareNextFunc) *graphql.ComputationOutput {
+			output := next(input)
+			output.Current, output.Error = e.Execute(input.Ctx, schema.Query, nil, input.ParsedQuery)
+			return output
+		},
+	}, &graphql.ComputationInput{Ctx: context.Background(), ParsedQuery: q})
+
+	assert.Error(t, output.Error)
+	require.Len(t, failed, 1)
+	assert.Equal(t, []string{"broken"}, failed[0].Path)
+	assert.EqualError(t, failed[0].Error, "broken")
+}
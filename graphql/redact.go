@@ -0,0 +1,352 @@
+package graphql
+
+import (
+	"bytes"
+	"strconv"
+
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+// Arguments such as passwords and tokens can be marked as sensitive with
+// Field.SensitiveArgs. The server logs queries with the values of sensitive
+// arguments redacted, and custom loggers can do the same with RedactQuery.
+//
+// The logging tags of the server and the inputs reported by
+// ExecutionRecorderMiddleware are redacted. Other hooks and middlewares
+// receive the queries and variables they execute, including sensitive values,
+// in SubscriptionInfo and ComputationInput, and must redact them with
+// RedactQuery before logging or storing them.
+//
+// The built-in tracing of the federation gateway only propagates trace ids,
+// trace state, and baggage, and never records queries or variables.
+// Persisted operations are stored as the operation text of their manifest,
+// without variables; the variables of every execution are only held in
+// memory while its plan is cached. Neither needs redaction.
+
+// RedactedValue replaces the values of sensitive arguments.
+const RedactedValue = "[REDACTED]"
+
+// RedactQuery returns the query source and its variables with the values of
+// sensitive arguments replaced by RedactedValue. Variables used in sensitive
+// arguments are replaced in the returned variables, and variables is not
+// modified. Sources with sensitive literal values, including the default
+// values of such variables, are reprinted with the values replaced.
+//
+// If source cannot be parsed, it is returned as is, but all variables are
+// redacted, as their use is unknown.
+func RedactQuery(schema *Schema, source string, variables map[string]interface{}) (string, map[string]interface{}) {
+	var redacted map[string]interface{}
+	if variables != nil {
+		redacted = make(map[string]interface{}, len(variables))
+	}
+
+	document, err := parser.Parse(parser.ParseParams{Source: source})
+	if err != nil {
+		for name := range variables {
+			redacted[name] = RedactedValue
+		}
+		return source, redacted
+	}
+
+	r := &redactor{
+		types:     make(map[string]Type),
+		fragments: make(map[string]*ast.FragmentDefinition),
+		visited:   make(map[string]bool),
+		variables: make(map[string]bool),
+	}
+	collectNamedTypes(schema.Query, r.types)
+	collectNamedTypes(schema.Mutation, r.types)
+	for _, definition := range document.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok {
+			r.fragments[fragment.Name.Value] = fragment
+		}
+	}
+	for _, definition := range document.Definitions {
+		operation, ok := definition.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		root := schema.Query
+		if operation.Operation == "mutation" {
+			root = schema.Mutation
+		}
+		r.selectionSet(operation.SelectionSet, root)
+	}
+	// Redact fragments that no operation spreads as well, as they are
+	// logged with the rest of the source.
+	for _, definition := range document.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok && !r.visited[fragment.Name.Value] {
+			r.visited[fragment.Name.Value] = true
+			r.selectionSet(fragment.SelectionSet, r.types[fragment.TypeCondition.Name.Value])
+		}
+	}
+	// The default values of redacted variables are sensitive as well.
+	for _, definition := range document.Definitions {
+		operation, ok := definition.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		for _, variable := range operation.VariableDefinitions {
+			if variable.DefaultValue != nil && r.variables[variable.Variable.Name.Value] {
+				variable.DefaultValue = ast.NewStringValue(&ast.StringValue{Value: RedactedValue})
+				r.redactedLiterals = true
+			}
+		}
+	}
+
+	for name, value := range variables {
+		if r.variables[name] {
+			value = RedactedValue
+		}
+		redacted[name] = value
+	}
+	if r.redactedLiterals {
+		source = printDocument(document)
+	}
+	return source, redacted
+}
+
+// redactor finds the values of sensitive arguments in a query.
+type redactor struct {
+	types     map[string]Type
+	fragments map[string]*ast.FragmentDefinition
+	// visited holds the fragments already searched.
+	visited map[string]bool
+
+	// variables are the names of the variables to redact, and
+	// redactedLiterals is set if literal values were redacted.
+	variables        map[string]bool
+	redactedLiterals bool
+}
+
+func (r *redactor) selectionSet(selectionSet *ast.SelectionSet, typ Type) {
+	if selectionSet == nil {
+		return
+	}
+	for {
+		if nonNull, ok := typ.(*NonNull); ok {
+			typ = nonNull.Type
+		} else if list, ok := typ.(*List); ok {
+			typ = list.Type
+		} else {
+			break
+		}
+	}
+
+	for _, selection := range selectionSet.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			object, ok := typ.(*Object)
+			if !ok {
+				continue
+			}
+			field, ok := object.Fields[selection.Name.Value]
+			if !ok {
+				continue
+			}
+			for _, arg := range selection.Arguments {
+				if field.SensitiveArgs[arg.Name.Value] {
+					r.redact(arg)
+				}
+			}
+			r.selectionSet(selection.SelectionSet, field.Type)
+
+		case *ast.InlineFragment:
+			on := typ
+			if selection.TypeCondition != nil {
+				on = r.types[selection.TypeCondition.Name.Value]
+			}
+			r.selectionSet(selection.SelectionSet, on)
+
+		case *ast.FragmentSpread:
+			name := selection.Name.Value
+			fragment, ok := r.fragments[name]
+			if !ok || r.visited[name] {
+				continue
+			}
+			r.visited[name] = true
+			r.selectionSet(fragment.SelectionSet, r.types[fragment.TypeCondition.Name.Value])
+		}
+	}
+}
+
+// redact records the variables in value for redaction, and replaces it with
+// RedactedValue unless it is a variable.
+func (r *redactor) redact(arg *ast.Argument) {
+	if variable, ok := arg.Value.(*ast.Variable); ok {
+		r.variables[variable.Name.Value] = true
+		return
+	}
+	r.redactVariables(arg.Value)
+	arg.Value = ast.NewStringValue(&ast.StringValue{Value: RedactedValue})
+	r.redactedLiterals = true
+}
+
+// redactVariables records the variables nested in value for redaction.
+func (r *redactor) redactVariables(value ast.Value) {
+	switch value := value.(type) {
+	case *ast.Variable:
+		r.variables[value.Name.Value] = true
+	case *ast.ObjectValue:
+		for _, field := range value.Fields {
+			r.redactVariables(field.Value)
+		}
+	case *ast.ListValue:
+		for _, item := range value.Values {
+			r.redactVariables(item)
+		}
+	}
+}
+
+// The graphql-go parser cannot print documents, and its locations are not
+// reliable offsets into the source, so queries with redacted literals are
+// printed by printDocument on a single line.
+
+func printDocument(document *ast.Document) string {
+	var buf bytes.Buffer
+	for i, definition := range document.Definitions {
+		if i > 0 {
+			buf.WriteString(" ")
+		}
+		switch definition := definition.(type) {
+		case *ast.OperationDefinition:
+			if definition.Name == nil && len(definition.VariableDefinitions) == 0 && len(definition.Directives) == 0 && definition.Operation == "query" {
+				printSelectionSet(&buf, definition.SelectionSet)
+				continue
+			}
+			buf.WriteString(definition.Operation)
+			if definition.Name != nil {
+				buf.WriteString(" " + definition.Name.Value)
+			}
+			if len(definition.VariableDefinitions) > 0 {
+				buf.WriteString("(")
+				for j, variable := range definition.VariableDefinitions {
+					if j > 0 {
+						buf.WriteString(", ")
+					}
+					buf.WriteString("$" + variable.Variable.Name.Value + ": ")
+					printType(&buf, variable.Type)
+					if variable.DefaultValue != nil {
+						buf.WriteString(" = ")
+						printValue(&buf, variable.DefaultValue)
+					}
+				}
+				buf.WriteString(")")
+			}
+			printDirectives(&buf, definition.Directives)
+			buf.WriteString(" ")
+			printSelectionSet(&buf, definition.SelectionSet)
+		case *ast.FragmentDefinition:
+			buf.WriteString("fragment " + definition.Name.Value + " on " + definition.TypeCondition.Name.Value)
+			printDirectives(&buf, definition.Directives)
+			buf.WriteString(" ")
+			printSelectionSet(&buf, definition.SelectionSet)
+		}
+	}
+	return buf.String()
+}
+
+func printSelectionSet(buf *bytes.Buffer, selectionSet *ast.SelectionSet) {
+	buf.WriteString("{")
+	for _, selection := range selectionSet.Selections {
+		buf.WriteString(" ")
+		switch selection := selection.(type) {
+		case *ast.Field:
+			if selection.Alias != nil {
+				buf.WriteString(selection.Alias.Value + ": ")
+			}
+			buf.WriteString(selection.Name.Value)
+			printArguments(buf, selection.Arguments)
+			printDirectives(buf, selection.Directives)
+			if selection.SelectionSet != nil {
+				buf.WriteString(" ")
+				printSelectionSet(buf, selection.SelectionSet)
+			}
+		case *ast.FragmentSpread:
+			buf.WriteString("..." + selection.Name.Value)
+			printDirectives(buf, selection.Directives)
+		case *ast.InlineFragment:
+			buf.WriteString("...")
+			if selection.TypeCondition != nil {
+				buf.WriteString(" on " + selection.TypeCondition.Name.Value)
+			}
+			printDirectives(buf, selection.Directives)
+			buf.WriteString(" ")
+			printSelectionSet(buf, selection.SelectionSet)
+		}
+	}
+	buf.WriteString(" }")
+}
+
+func printArguments(buf *bytes.Buffer, args []*ast.Argument) {
+	if len(args) == 0 {
+		return
+	}
+	buf.WriteString("(")
+	for i, arg := range args {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(arg.Name.Value + ": ")
+		printValue(buf, arg.Value)
+	}
+	buf.WriteString(")")
+}
+
+func printDirectives(buf *bytes.Buffer, directives []*ast.Directive) {
+	for _, directive := range directives {
+		buf.WriteString(" @" + directive.Name.Value)
+		printArguments(buf, directive.Arguments)
+	}
+}
+
+func printType(buf *bytes.Buffer, typ ast.Type) {
+	switch typ := typ.(type) {
+	case *ast.Named:
+		buf.WriteString(typ.Name.Value)
+	case *ast.List:
+		buf.WriteString("[")
+		printType(buf, typ.Type)
+		buf.WriteString("]")
+	case *ast.NonNull:
+		printType(buf, typ.Type)
+		buf.WriteString("!")
+	}
+}
+
+func printValue(buf *bytes.Buffer, value ast.Value) {
+	switch value := value.(type) {
+	case *ast.Variable:
+		buf.WriteString("$" + value.Name.Value)
+	case *ast.IntValue:
+		buf.WriteString(value.Value)
+	case *ast.FloatValue:
+		buf.WriteString(value.Value)
+	case *ast.EnumValue:
+		buf.WriteString(value.Value)
+	case *ast.BooleanValue:
+		buf.WriteString(strconv.FormatBool(value.Value))
+	case *ast.StringValue:
+		buf.WriteString(mustMarshalJson(value.Value))
+	case *ast.ListValue:
+		buf.WriteString("[")
+		for i, item := range value.Values {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			printValue(buf, item)
+		}
+		buf.WriteString("]")
+	case *ast.ObjectValue:
+		buf.WriteString("{")
+		for i, field := range value.Fields {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(field.Name.Value + ": ")
+			printValue(buf, field.Value)
+		}
+		buf.WriteString("}")
+	}
+}
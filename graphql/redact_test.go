@@ -0,0 +1,130 @@
+package graphql_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+)
+
+type redactUser struct {
+	Name string
+}
+
+type RedactLogin struct {
+	User, Password string
+}
+
+func makeRedactSchema() *schemabuilder.Schema {
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("user", func(args struct{ Token, Name string }) *redactUser {
+		return &redactUser{Name: args.Name}
+	}, schemabuilder.Sensitive("token"))
+	schema.Object("User", redactUser{}).FieldFunc("secret", func(u *redactUser, args struct{ Key []string }) string {
+		return ""
+	}, schemabuilder.Sensitive("key"))
+	schema.Mutation().FieldFunc("login", func(args struct{ Login RedactLogin }) bool {
+		return true
+	}, schemabuilder.Sensitive("login"))
+	return schema
+}
+
+func TestRedactQuery(t *testing.T) {
+	schema := makeRedactSchema().MustBuild()
+
+	cases := []struct {
+		name              string
+		source            string
+		variables         map[string]interface{}
+		redacted          string
+		redactedVariables map[string]interface{}
+	}{
+		{
+			name:     "literals",
+			source:   `{ user(token: "hünter2", name: "bøb") { secret(key: ["a", "b"]) name } }`,
+			redacted: `{ user(token: "[REDACTED]", name: "bøb") { secret(key: "[REDACTED]") name } }`,
+		},
+		{
+			name:              "variables",
+			source:            `query Q($t: string!, $n: string!) { user(token: $t, name: $n) { name } }`,
+			variables:         map[string]interface{}{"t": "hunter2", "n": "bob"},
+			redacted:          `query Q($t: string!, $n: string!) { user(token: $t, name: $n) { name } }`,
+			redactedVariables: map[string]interface{}{"t": graphql.RedactedValue, "n": "bob"},
+		},
+		{
+			name:              "fragments and nested variables",
+			source:            `mutation M($p: string!) { login(login: {user: "bob", password: $p}) } fragment F on User { secret(key: ["a"]) }`,
+			variables:         map[string]interface{}{"p": "hunter2"},
+			redacted:          `mutation M($p: string!) { login(login: "[REDACTED]") } fragment F on User { secret(key: "[REDACTED]") }`,
+			redactedVariables: map[string]interface{}{"p": graphql.RedactedValue},
+		},
+		{
+			name: "spread fragments",
+			source: `query Q($t: string! = "x") @cached { user(token: $t, name: "bob") { alias: name ...F ... on User { name } } }
+fragment F on User { secret(key: ["a"]) @include(if: true) }`,
+			redacted: `query Q($t: string! = "[REDACTED]") @cached { user(token: $t, name: "bob") { alias: name ...F ... on User { name } } } fragment F on User { secret(key: "[REDACTED]") @include(if: true) }`,
+		},
+		{
+			name:              "variable default values",
+			source:            `mutation M($p: string! = "hunter2", $u: string = "bob") { login(login: {user: $u, password: $p}) }`,
+			variables:         map[string]interface{}{},
+			redacted:          `mutation M($p: string! = "[REDACTED]", $u: string = "[REDACTED]") { login(login: "[REDACTED]") }`,
+			redactedVariables: map[string]interface{}{},
+		},
+		{
+			name:              "unparseable",
+			source:            `{ user(`,
+			variables:         map[string]interface{}{"n": "bob"},
+			redacted:          `{ user(`,
+			redactedVariables: map[string]interface{}{"n": graphql.RedactedValue},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			redacted, redactedVariables := graphql.RedactQuery(schema, c.source, c.variables)
+			assert.Equal(t, c.redacted, redacted)
+			if diff := pretty.Compare(redactedVariables, c.redactedVariables); diff != "" {
+				t.Errorf("bad variables: %s", diff)
+			}
+		})
+	}
+}
+
+func TestSensitiveUnknownArg(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("user", func(args struct{ Name string }) string {
+		return args.Name
+	}, schemabuilder.Sensitive("token"))
+	_, err := schema.Build()
+	assert.Error(t, err)
+}
+
+func TestExecutionRecorderMiddlewareRedacts(t *testing.T) {
+	schema := makeRedactSchema().MustBuild()
+	source := `query Q($t: string!) { user(token: $t, name: "bob") { name } }`
+	variables := map[string]interface{}{"t": "hunter2"}
+
+	var reported *graphql.ComputationInput
+	middleware := graphql.ExecutionRecorderMiddleware(schema, 10, func(input *graphql.ComputationInput, output *graphql.ComputationOutput, recorder *graphql.ExecutionRecorder) {
+		reported = input
+	})
+	var executed *graphql.ComputationInput
+	graphql.RunMiddlewares([]graphql.MiddlewareFunc{
+		middleware,
+		func(input *graphql.ComputationInput, next graphql.MiddlewareNextFunc) *graphql.ComputationOutput {
+			executed = input
+			return next(input)
+		},
+	}, &graphql.ComputationInput{Ctx: context.Background(), Query: source, Variables: variables})
+
+	require.NotNil(t, reported)
+	assert.Equal(t, map[string]interface{}{"t": graphql.RedactedValue}, reported.Variables)
+	// The query still executes with its sensitive values.
+	assert.Equal(t, variables, executed.Variables)
+	assert.Equal(t, map[string]interface{}{"t": "hunter2"}, variables)
+}
@@ -0,0 +1,279 @@
+// Package rediscache implements a graphql.FieldCache that stores field results
+// in Redis.
+//
+// It speaks the Redis protocol directly and only uses the GET, SET, and AUTH
+// commands, so it works with Redis and compatible servers without additional
+// dependencies.
+package rediscache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/denkhaus/thunder/graphql"
+)
+
+// DefaultTimeout bounds each command if the context has no earlier deadline.
+const DefaultTimeout = 100 * time.Millisecond
+
+// DefaultMaxIdle is the default number of idle connections kept open.
+const DefaultMaxIdle = 4
+
+// Cache is a graphql.FieldCache storing results in Redis.
+type Cache struct {
+	addr     string
+	prefix   string
+	password string
+	timeout  time.Duration
+	maxIdle  int
+
+	mu   sync.Mutex
+	idle []*conn
+}
+
+var _ graphql.FieldCache = &Cache{}
+
+// Option configures a Cache.
+type Option func(*Cache)
+
+// WithPrefix prepends prefix to all keys, to share a Redis server with other
+// applications.
+func WithPrefix(prefix string) Option {
+	return func(c *Cache) {
+		c.prefix = prefix
+	}
+}
+
+// WithPassword authenticates connections with password.
+func WithPassword(password string) Option {
+	return func(c *Cache) {
+		c.password = password
+	}
+}
+
+// WithTimeout bounds each command to timeout. It defaults to DefaultTimeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Cache) {
+		c.timeout = timeout
+	}
+}
+
+// WithMaxIdle keeps at most n idle connections open. It defaults to
+// DefaultMaxIdle.
+func WithMaxIdle(n int) Option {
+	return func(c *Cache) {
+		c.maxIdle = n
+	}
+}
+
+// New creates a Cache for the Redis server at addr, eg. "localhost:6379".
+// Connections are opened when needed.
+func New(addr string, opts ...Option) *Cache {
+	c := &Cache{
+		addr:    addr,
+		timeout: DefaultTimeout,
+		maxIdle: DefaultMaxIdle,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Get returns the value of key, if any.
+func (c *Cache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := c.do(ctx, "GET", c.prefix+key)
+	if err != nil {
+		return nil, false, err
+	}
+	if reply == nil {
+		return nil, false, nil
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("rediscache: unexpected GET reply %v", reply)
+	}
+	return value, true, nil
+}
+
+// Set sets the value of key, expiring after ttl.
+func (c *Cache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	ms := int64(ttl / time.Millisecond)
+	if ms <= 0 {
+		ms = 1
+	}
+	reply, err := c.do(ctx, "SET", c.prefix+key, string(value), "PX", strconv.FormatInt(ms, 10))
+	if err != nil {
+		return err
+	}
+	if reply != "OK" {
+		return fmt.Errorf("rediscache: unexpected SET reply %v", reply)
+	}
+	return nil
+}
+
+// Close closes all idle connections.
+func (c *Cache) Close() error {
+	c.mu.Lock()
+	idle := c.idle
+	c.idle = nil
+	c.mu.Unlock()
+
+	var err error
+	for _, conn := range idle {
+		if closeErr := conn.Close(); closeErr != nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// do runs a command on an idle or new connection. Connections are only
+// reused if the command succeeded or Redis replied with an error.
+func (c *Cache) do(ctx context.Context, args ...string) (interface{}, error) {
+	deadline := time.Now().Add(c.timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+
+	conn, err := c.get(ctx, deadline)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	reply, err := conn.do(args...)
+	if _, ok := err.(redisError); err != nil && !ok {
+		conn.Close()
+		return nil, err
+	}
+	c.put(conn)
+	return reply, err
+}
+
+func (c *Cache) get(ctx context.Context, deadline time.Time) (*conn, error) {
+	c.mu.Lock()
+	if n := len(c.idle); n > 0 {
+		conn := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mu.Unlock()
+		return conn, nil
+	}
+	c.mu.Unlock()
+
+	dialer := &net.Dialer{Deadline: deadline}
+	netConn, err := dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, err
+	}
+	conn := &conn{Conn: netConn, reader: bufio.NewReader(netConn)}
+	if c.password != "" {
+		if err := conn.SetDeadline(deadline); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if _, err := conn.do("AUTH", c.password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func (c *Cache) put(conn *conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.idle) >= c.maxIdle {
+		conn.Close()
+		return
+	}
+	c.idle = append(c.idle, conn)
+}
+
+// redisError is an error reply from Redis.
+type redisError string
+
+func (e redisError) Error() string {
+	return "rediscache: " + string(e)
+}
+
+// conn is a connection to Redis.
+type conn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+// do sends a command and reads its reply: a string for status replies, an
+// int64 for integer replies, a []byte for bulk replies, or nil.
+func (c *conn) do(args ...string) (interface{}, error) {
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, arg...)
+		buf = append(buf, '\r', '\n')
+	}
+	if _, err := c.Write(buf); err != nil {
+		return nil, err
+	}
+	return c.readReply()
+}
+
+func (c *conn) readReply() (interface{}, error) {
+	line, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("rediscache: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return string(line[1:]), nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(string(line[1:]), 10, 64)
+	case '$':
+		n, err := strconv.Atoi(string(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		value := make([]byte, n+2)
+		if _, err := io.ReadFull(c.reader, value); err != nil {
+			return nil, err
+		}
+		return value[:n], nil
+	default:
+		return nil, fmt.Errorf("rediscache: unsupported reply %q", line)
+	}
+}
+
+func (c *conn) readLine() ([]byte, error) {
+	line, err := c.reader.ReadSlice('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("rediscache: malformed reply %q", line)
+	}
+	return line[:len(line)-2], nil
+}
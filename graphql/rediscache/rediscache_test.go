@@ -0,0 +1,166 @@
+package rediscache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis is a Redis server supporting just enough of GET, SET, and AUTH.
+type fakeRedis struct {
+	listener net.Listener
+	password string
+
+	mu      sync.Mutex
+	values  map[string]string
+	expires map[string]string
+	conns   int
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &fakeRedis{
+		listener: listener,
+		password: password,
+		values:   map[string]string{},
+		expires:  map[string]string{},
+	}
+	go s.serve()
+	return s
+}
+
+func (s *fakeRedis) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns++
+		s.mu.Unlock()
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	authenticated := s.password == ""
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		var reply string
+		switch {
+		case args[0] == "AUTH":
+			authenticated = args[1] == s.password
+			reply = "+OK\r\n"
+			if !authenticated {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case !authenticated:
+			reply = "-NOAUTH Authentication required.\r\n"
+		case args[0] == "GET":
+			if value, ok := s.values[args[1]]; ok {
+				reply = fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+			} else {
+				reply = "$-1\r\n"
+			}
+		case args[0] == "SET" && len(args) == 5 && args[3] == "PX":
+			s.values[args[1]] = args[2]
+			s.expires[args[1]] = args[4]
+			reply = "+OK\r\n"
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+		s.mu.Unlock()
+
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func TestCache(t *testing.T) {
+	server := newFakeRedis(t, "secret")
+	defer server.listener.Close()
+
+	cache := New(server.listener.Addr().String(), WithPrefix("app:"), WithPassword("secret"), WithTimeout(time.Second))
+	defer cache.Close()
+	ctx := context.Background()
+
+	_, ok, err := cache.Get(ctx, "missing")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	value := []byte("{\"name\":\"bob\"}\r\n")
+	require.NoError(t, cache.Set(ctx, "key", value, 1500*time.Millisecond))
+	cached, ok, err := cache.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, value, cached)
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	assert.Equal(t, "1500", server.expires["app:key"])
+	// The connection is reused.
+	assert.Equal(t, 1, server.conns)
+}
+
+func TestCacheErrors(t *testing.T) {
+	server := newFakeRedis(t, "secret")
+	defer server.listener.Close()
+	ctx := context.Background()
+
+	cache := New(server.listener.Addr().String(), WithPassword("wrong"))
+	defer cache.Close()
+	_, _, err := cache.Get(ctx, "key")
+	assert.EqualError(t, err, "rediscache: WRONGPASS invalid password")
+
+	cache = New(server.listener.Addr().String())
+	defer cache.Close()
+	err = cache.Set(ctx, "key", []byte("value"), time.Second)
+	assert.EqualError(t, err, "rediscache: NOAUTH Authentication required.")
+}
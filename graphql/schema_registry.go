@@ -0,0 +1,175 @@
+package graphql
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// SchemaResolver selects the schema serving r, or returns nil if no schema
+// serves r.
+type SchemaResolver func(r *http.Request) *Schema
+
+// HostSchemaResolver selects schemas by the host name of requests, ignoring
+// the port. Requests for other hosts are served by fallback, which may be nil.
+func HostSchemaResolver(schemas map[string]*Schema, fallback *Schema) SchemaResolver {
+	return func(r *http.Request) *Schema {
+		host := r.Host
+		if i := strings.LastIndex(host, ":"); i >= 0 && !strings.Contains(host[i:], "]") {
+			host = host[:i]
+		}
+		if schema, ok := schemas[strings.ToLower(host)]; ok {
+			return schema
+		}
+		return fallback
+	}
+}
+
+// HeaderSchemaResolver selects schemas by the value of header, such as an API
+// version header. Requests without a known value are served by fallback,
+// which may be nil.
+func HeaderSchemaResolver(header string, schemas map[string]*Schema, fallback *Schema) SchemaResolver {
+	return func(r *http.Request) *Schema {
+		if schema, ok := schemas[r.Header.Get(header)]; ok {
+			return schema
+		}
+		return fallback
+	}
+}
+
+// SchemaRegistry serves different schemas from one handler, such as the
+// schemas of different tenants or API versions, see WithSchemaRegistry. Every
+// schema gets its own query cache, so tenants do not evict each other's
+// queries, and its own metrics, until it is unregistered, see Unregister.
+type SchemaRegistry struct {
+	resolve   SchemaResolver
+	cacheSize int
+
+	mu      sync.Mutex
+	schemas map[*Schema]*registeredSchema
+	// order holds the schemas in the order they were first served.
+	order []*registeredSchema
+}
+
+// registeredSchema holds the state of a schema served by a SchemaRegistry.
+type registeredSchema struct {
+	schema     *Schema
+	queryCache *QueryCache
+
+	hashOnce sync.Once
+	hash     string
+
+	requests int64
+	errors   int64
+}
+
+// SchemaStats describes the requests served by a schema of a SchemaRegistry.
+type SchemaStats struct {
+	Schema *Schema
+	// Hash is the SchemaHash of the schema, which identifies it in metrics.
+	Hash string
+	// Requests is the number of requests served by the schema.
+	Requests int64
+	// Errors is the number of requests that failed.
+	Errors int64
+	// CachedQueries is the number of queries in the schema's query cache.
+	CachedQueries int
+}
+
+// NewSchemaRegistry creates a SchemaRegistry serving requests with the
+// schema selected by resolve. Every schema caches up to cacheSize parsed
+// queries; a cacheSize of 0 disables caching.
+func NewSchemaRegistry(resolve SchemaResolver, cacheSize int) *SchemaRegistry {
+	return &SchemaRegistry{
+		resolve:   resolve,
+		cacheSize: cacheSize,
+		schemas:   make(map[*Schema]*registeredSchema),
+	}
+}
+
+// lookup returns the state of the schema serving r, or nil if no schema
+// serves r.
+func (reg *SchemaRegistry) lookup(r *http.Request) *registeredSchema {
+	schema := reg.resolve(r)
+	if schema == nil {
+		return nil
+	}
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	s, ok := reg.schemas[schema]
+	if !ok {
+		s = &registeredSchema{schema: schema}
+		if reg.cacheSize > 0 {
+			s.queryCache = NewQueryCache(reg.cacheSize)
+		}
+		reg.schemas[schema] = s
+		reg.order = append(reg.order, s)
+	}
+	return s
+}
+
+// Unregister forgets schema, its query cache and its stats. Schemas are kept
+// from the first request they serve until they are unregistered, so schemas
+// that the resolver no longer selects, eg. the old schema of a tenant after it
+// was rebuilt, must be unregistered for them to be garbage collected. A
+// schema that serves requests again is registered anew.
+func (reg *SchemaRegistry) Unregister(schema *Schema) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	s, ok := reg.schemas[schema]
+	if !ok {
+		return
+	}
+	delete(reg.schemas, schema)
+	for i, registered := range reg.order {
+		if registered == s {
+			reg.order = append(reg.order[:i:i], reg.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// schemaHash returns the SchemaHash of the schema, computing it once.
+func (s *registeredSchema) schemaHash() string {
+	s.hashOnce.Do(func() {
+		s.hash = SchemaHash(s.schema)
+	})
+	return s.hash
+}
+
+// Stats returns the stats of every schema that served requests, in the order
+// they were first served.
+func (reg *SchemaRegistry) Stats() []SchemaStats {
+	reg.mu.Lock()
+	schemas := append([]*registeredSchema(nil), reg.order...)
+	reg.mu.Unlock()
+
+	stats := make([]SchemaStats, 0, len(schemas))
+	for _, s := range schemas {
+		stat := SchemaStats{
+			Schema:   s.schema,
+			Hash:     s.schemaHash(),
+			Requests: atomic.LoadInt64(&s.requests),
+			Errors:   atomic.LoadInt64(&s.errors),
+		}
+		if s.queryCache != nil {
+			stat.CachedQueries = s.queryCache.Len()
+		}
+		stats = append(stats, stat)
+	}
+	return stats
+}
+
+// WithSchemaRegistry serves every request with the schema selected by
+// registry instead of the handler's schema, which may then be nil. Requests
+// no schema serves are rejected with a 404 status.
+//
+// The schema's query cache replaces WithHTTPQueryCache, and WithSchemaHash
+// reports the hash of the selected schema.
+func WithSchemaRegistry(registry *SchemaRegistry) HTTPOption {
+	return func(h *httpHandler) {
+		h.registry = registry
+	}
+}
@@ -0,0 +1,90 @@
+package graphql_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+)
+
+func versionSchema(version string) *graphql.Schema {
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("version", func() string { return version })
+	return schema.MustBuild()
+}
+
+func TestSchemaRegistry(t *testing.T) {
+	v1, v2 := versionSchema("v1"), versionSchema("v2")
+	registry := graphql.NewSchemaRegistry(graphql.HeaderSchemaResolver("API-Version", map[string]*graphql.Schema{
+		"1": v1,
+		"2": v2,
+	}, nil), 10)
+	handler := graphql.NewHTTPHandler(nil, graphql.WithSchemaRegistry(registry), graphql.WithSchemaHash())
+
+	serve := func(version string, query string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "`+query+`"}`))
+		require.NoError(t, err)
+		if version != "" {
+			req.Header.Set("API-Version", version)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := serve("2", "{ version }")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"data":{"version":"v2"}`)
+	assert.Equal(t, graphql.SchemaHash(v2), rr.Header().Get(graphql.SchemaHashHeader))
+
+	rr = serve("1", "{ version }")
+	assert.Contains(t, rr.Body.String(), `"data":{"version":"v1"}`)
+	assert.Equal(t, graphql.SchemaHash(v1), rr.Header().Get(graphql.SchemaHashHeader))
+	serve("1", "{ version }")
+	serve("1", "{ missing }")
+
+	// Requests without a schema are rejected.
+	rr = serve("", "{ version }")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Contains(t, rr.Body.String(), "no schema serves this request")
+
+	assert.Equal(t, []graphql.SchemaStats{
+		{Schema: v2, Hash: graphql.SchemaHash(v2), Requests: 1, CachedQueries: 1},
+		{Schema: v1, Hash: graphql.SchemaHash(v1), Requests: 3, Errors: 1, CachedQueries: 2},
+	}, registry.Stats())
+
+	// Unregistered schemas are forgotten until they serve requests again.
+	registry.Unregister(v2)
+	registry.Unregister(v2)
+	assert.Equal(t, []graphql.SchemaStats{
+		{Schema: v1, Hash: graphql.SchemaHash(v1), Requests: 3, Errors: 1, CachedQueries: 2},
+	}, registry.Stats())
+	serve("2", "{ version }")
+	assert.Equal(t, []graphql.SchemaStats{
+		{Schema: v1, Hash: graphql.SchemaHash(v1), Requests: 3, Errors: 1, CachedQueries: 2},
+		{Schema: v2, Hash: graphql.SchemaHash(v2), Requests: 1, CachedQueries: 1},
+	}, registry.Stats())
+}
+
+func TestHostSchemaResolver(t *testing.T) {
+	acme, fallback := versionSchema("acme"), versionSchema("fallback")
+	resolve := graphql.HostSchemaResolver(map[string]*graphql.Schema{"acme.example.com": acme}, fallback)
+
+	for host, expected := range map[string]*graphql.Schema{
+		"acme.example.com":      acme,
+		"ACME.example.com:8080": acme,
+		"other.example.com":     fallback,
+		"[::1]:8080":            fallback,
+	} {
+		req, err := http.NewRequest("POST", "/graphql", nil)
+		require.NoError(t, err)
+		req.Host = host
+		assert.True(t, resolve(req) == expected, host)
+	}
+}
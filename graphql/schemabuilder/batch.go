@@ -133,6 +133,9 @@ func (sb *schemaBuilder) buildBatchFunctionAndFuncCtx(typ reflect.Type, m *metho
 		ParseArguments:             argParser.Parse,
 		Expensive:                  m.Expensive,
 		NumParallelInvocationsFunc: m.ConcurrencyArgs.numParallelInvocationsFunc,
+		Serial:                     m.ConcurrencyArgs.serial,
+		Pooled:                     m.ConcurrencyArgs.pooled,
+		ResolverName:               resolverName(callableFunc),
 	}, funcCtx, nil
 }
 
@@ -4,7 +4,7 @@ import (
 	"encoding"
 	"fmt"
 	"reflect"
-	"time"
+	"sort"
 
 	"github.com/denkhaus/thunder/graphql"
 	"github.com/denkhaus/thunder/internal"
@@ -18,7 +18,31 @@ type schemaBuilder struct {
 	typeNames    map[string]reflect.Type
 	objects      map[reflect.Type]*Object
 	enumMappings map[reflect.Type]*EnumMapping
-	typeCache    map[reflect.Type]cachedType // typeCache maps Go types to GraphQL datatypes
+	typeCache    map[reflect.Type]cachedType  // typeCache maps Go types to GraphQL datatypes
+	patchInputs  map[reflect.Type]*patchInput // patchInputs maps patched Go types to their patch input objects
+	maxPageSize  int64                        // maxPageSize is the default limit of first and last, see Schema.SetMaxPageSize
+
+	// taggedObjects holds the object types with filterable or sortable
+	// fields, and paginatedNodes the node types of paginated fields. Tags
+	// only apply to node types, see checkTaggedObjects.
+	taggedObjects  map[reflect.Type]bool
+	paginatedNodes map[reflect.Type]bool
+}
+
+// checkTaggedObjects fails if an object type has filterable or sortable
+// fields, but is not the node type of any paginated field.
+func (sb *schemaBuilder) checkTaggedObjects() error {
+	var unused []string
+	for typ := range sb.taggedObjects {
+		if !sb.paginatedNodes[typ] {
+			unused = append(unused, typ.String())
+		}
+	}
+	if len(unused) == 0 {
+		return nil
+	}
+	sort.Strings(unused)
+	return fmt.Errorf("bad type %s: filterable and sortable fields only apply to the nodes of paginated fields", unused[0])
 }
 
 // EnumMapping is a representation of an enum that includes both the mapping and
@@ -26,6 +50,11 @@ type schemaBuilder struct {
 type EnumMapping struct {
 	Map        map[string]interface{}
 	ReverseMap map[interface{}]string
+	// Aliases maps deprecated names that are still accepted as inputs to the
+	// name they stand for.
+	Aliases map[string]string
+	// Deprecations maps deprecated names to their deprecation reason.
+	Deprecations map[string]string
 }
 
 // cachedType is a container for GraphQL datatype and the list of its fields
@@ -43,7 +72,11 @@ func (sb *schemaBuilder) getType(nodeType reflect.Type) (graphql.Type, error) {
 	// Support scalars and optional scalars. Scalars have precedence over structs
 	// to have eg. time.Time function as a scalar.
 	if typeName, values, ok := sb.getEnum(nodeType); ok {
-		return &graphql.NonNull{Type: &graphql.Enum{Type: typeName, Values: values, ReverseMap: sb.enumMappings[nodeType].ReverseMap}}, nil
+		return &graphql.NonNull{Type: sb.enumMappings[nodeType].graphqlEnum(typeName, values)}, nil
+	}
+
+	if isJSONType(nodeType) || (nodeType.Kind() == reflect.Ptr && isJSONType(nodeType.Elem())) {
+		return getJSONType(), nil
 	}
 
 	if typeName, ok := getScalar(nodeType); ok {
@@ -120,6 +153,11 @@ func (sb *schemaBuilder) getTextMarshalerType(typ reflect.Type) (graphql.Type, e
 	return &graphql.NonNull{Type: scalar}, nil
 }
 
+// graphqlEnum builds the graphql.Enum for the mapping.
+func (m *EnumMapping) graphqlEnum(typeName string, values []string) *graphql.Enum {
+	return &graphql.Enum{Type: typeName, Values: values, ReverseMap: m.ReverseMap, Aliases: m.Aliases, Deprecations: m.Deprecations}
+}
+
 // getEnum gets the Enum type information for the passed in reflect.Type by
 // looking it up in our enum mappings.
 func (sb *schemaBuilder) getEnum(typ reflect.Type) (string, []string, bool) {
@@ -144,21 +182,13 @@ func getScalar(typ reflect.Type) (string, bool) {
 	return "", false
 }
 
-var scalars = map[reflect.Type]string{
-	reflect.TypeOf(bool(false)): "bool",
-	reflect.TypeOf(int(0)):      "int",
-	reflect.TypeOf(int8(0)):     "int8",
-	reflect.TypeOf(int16(0)):    "int16",
-	reflect.TypeOf(int32(0)):    "int32",
-	reflect.TypeOf(int64(0)):    "int64",
-	reflect.TypeOf(uint(0)):     "uint",
-	reflect.TypeOf(uint8(0)):    "uint8",
-	reflect.TypeOf(uint16(0)):   "uint16",
-	reflect.TypeOf(uint32(0)):   "uint32",
-	reflect.TypeOf(uint64(0)):   "uint64",
-	reflect.TypeOf(float32(0)):  "float32",
-	reflect.TypeOf(float64(0)):  "float64",
-	reflect.TypeOf(string("")):  "string",
-	reflect.TypeOf(time.Time{}): "Time",
-	reflect.TypeOf([]byte{}):    "bytes",
+// scalars maps Go types to the names of the scalars exposing them.
+var scalars = make(map[reflect.Type]string)
+
+func init() {
+	for _, scalar := range internal.Scalars {
+		if scalar.Type != nil {
+			scalars[scalar.Type] = scalar.Name
+		}
+	}
 }
@@ -2,8 +2,10 @@ package schemabuilder
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"reflect"
+	"runtime"
 
 	"github.com/denkhaus/thunder/graphql"
 	"github.com/samsarahq/go/oops"
@@ -79,7 +81,16 @@ func (sb *schemaBuilder) buildFunctionAndFuncCtx(typ reflect.Type, m *method) (*
 		return nil, nil, err
 	}
 
-	return &graphql.Field{
+	if m.Pure {
+		if funcCtx.hasContext || funcCtx.hasSelectionSet {
+			return nil, nil, fmt.Errorf("pure %s should not take a context or selection set", funcCtx.funcType)
+		}
+		if m.Expensive {
+			return nil, nil, fmt.Errorf("pure %s cannot be expensive", funcCtx.funcType)
+		}
+	}
+
+	field := &graphql.Field{
 		Resolve: func(ctx context.Context, source, funcRawArgs interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
 			// Set up function arguments.
 			funcInputArgs := funcCtx.prepareResolveArgs(source, funcCtx.hasArgs, funcRawArgs, ctx, selectionSet)
@@ -94,9 +105,29 @@ func (sb *schemaBuilder) buildFunctionAndFuncCtx(typ reflect.Type, m *method) (*
 		Type:                       retType,
 		ParseArguments:             argParser.Parse,
 		Expensive:                  m.Expensive,
+		Pure:                       m.Pure,
 		External:                   true,
 		NumParallelInvocationsFunc: m.ConcurrencyArgs.numParallelInvocationsFunc,
-	}, funcCtx, nil
+		Serial:                     m.ConcurrencyArgs.serial,
+		Pooled:                     m.ConcurrencyArgs.pooled,
+		ResolverName:               resolverName(callableFunc),
+	}
+	if m.CacheTTL > 0 && funcCtx.hasRet {
+		field.CacheTTL = m.CacheTTL
+		field.DecodeCached = makeCachedDecoder(funcCtx.funcType.Out(0))
+	}
+	return field, funcCtx, nil
+}
+
+// makeCachedDecoder decodes cached results of type typ, marshaled as JSON.
+func makeCachedDecoder(typ reflect.Type) func([]byte) (interface{}, error) {
+	return func(value []byte) (interface{}, error) {
+		result := reflect.New(typ)
+		if err := json.Unmarshal(value, result.Interface()); err != nil {
+			return nil, err
+		}
+		return result.Elem().Interface(), nil
+	}
 }
 
 // buildFederatedFunction creates a graphql field that exposes all the fields on the object struct.
@@ -123,6 +154,8 @@ func (sb *schemaBuilder) buildFederatedFunction(typ reflect.Type, m *method) (*g
 		Expensive:                  m.Expensive,
 		External:                   true,
 		NumParallelInvocationsFunc: m.ConcurrencyArgs.numParallelInvocationsFunc,
+		Serial:                     m.ConcurrencyArgs.serial,
+		Pooled:                     m.ConcurrencyArgs.pooled,
 	}
 	return field, nil
 }
@@ -180,10 +213,21 @@ func (sb *schemaBuilder) buildShadowObjectFederationFunction(typ reflect.Type, m
 		Expensive:                  m.Expensive,
 		External:                   true,
 		NumParallelInvocationsFunc: m.ConcurrencyArgs.numParallelInvocationsFunc,
+		Serial:                     m.ConcurrencyArgs.serial,
+		Pooled:                     m.ConcurrencyArgs.pooled,
 	}
 	return field, nil
 }
 
+// resolverName returns the name of the Go function fun, such as
+// "github.com/org/repo/server.(*Server).registerUser.func1".
+func resolverName(fun reflect.Value) string {
+	if f := runtime.FuncForPC(fun.Pointer()); f != nil {
+		return f.Name()
+	}
+	return ""
+}
+
 // funcContext is used to parse the function signature in buildFunction.
 type funcContext struct {
 	hasContext      bool
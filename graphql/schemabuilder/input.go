@@ -77,6 +77,35 @@ func nilParseArguments(args interface{}) (interface{}, error) {
 	return nil, nil
 }
 
+// Provided records which fields of an args struct or input object were
+// provided, keyed by their GraphQL name. Fields explicitly set to null are
+// provided, while omitted fields and fields set to a variable that was not
+// passed are not.
+//
+// Add an exported field of type Provided to a struct to have it filled in; the
+// field is not part of the schema. Together with a pointer field, it tells an
+// explicit null (nil and provided) apart from an omitted argument (nil and not
+// provided), as needed for partial updates:
+//
+//	func(args struct {
+//		Id       int64
+//		Nickname *string
+//		Provided schemabuilder.Provided
+//	}) error {
+//		if args.Provided.Has("nickname") {
+//			// Set the nickname, clearing it if args.Nickname is nil.
+//		}
+//		...
+//	}
+type Provided map[string]bool
+
+// Has returns true if the field with the given GraphQL name was provided.
+func (p Provided) Has(name string) bool {
+	return p[name]
+}
+
+var providedType = reflect.TypeOf(Provided(nil))
+
 // makeStructParser constructs an argParser for the passed in struct type.
 func (sb *schemaBuilder) makeStructParser(typ reflect.Type) (*argParser, graphql.Type, error) {
 	argType, fields, err := sb.getStructObjectFields(typ)
@@ -84,6 +113,13 @@ func (sb *schemaBuilder) makeStructParser(typ reflect.Type) (*argParser, graphql
 		return nil, nil, err
 	}
 
+	var providedIndex []int
+	for i := 0; i < typ.NumField(); i++ {
+		if field := typ.Field(i); field.Type == providedType && field.PkgPath == "" {
+			providedIndex = field.Index
+		}
+	}
+
 	return &argParser{
 		FromJSON: func(value interface{}, dest reflect.Value) error {
 			asMap, ok := value.(map[string]interface{})
@@ -91,6 +127,14 @@ func (sb *schemaBuilder) makeStructParser(typ reflect.Type) (*argParser, graphql
 				return errors.New("not an object")
 			}
 
+			if providedIndex != nil {
+				provided := make(Provided, len(asMap))
+				for name := range asMap {
+					provided[name] = true
+				}
+				dest.FieldByIndex(providedIndex).Set(reflect.ValueOf(provided))
+			}
+
 			for name, field := range fields {
 				value := asMap[name]
 				fieldDest := dest.FieldByName(field.field.Name)
@@ -155,9 +199,12 @@ func (sb *schemaBuilder) collectFields(typ reflect.Type, fields map[string]argFi
 		if err != nil {
 			return fmt.Errorf("bad type %s: %s", typ, err.Error())
 		}
-		if fieldInfo.Skipped {
+		if fieldInfo.Skipped || field.Type == providedType {
 			continue
 		}
+		if fieldInfo.Filterable || fieldInfo.Sortable {
+			return fmt.Errorf("bad arg type %s: field %s of an input cannot be filterable or sortable", typ, fieldInfo.Name)
+		}
 
 		if field.Anonymous {
 			if err := sb.collectFields(field.Type, fields, argType); err != nil {
@@ -217,12 +264,20 @@ func (sb *schemaBuilder) makeArgParserInner(typ reflect.Type) (*argParser, graph
 		return parser, argType, nil
 	}
 
+	if isJSONType(typ) {
+		parser, argType := makeJSONArgParser(typ)
+		return parser, argType, nil
+	}
+
 	if reflect.PtrTo(typ).Implements(textUnmarshalerType) {
 		return sb.makeTextUnmarshalerParser(typ)
 	}
 
 	switch typ.Kind() {
 	case reflect.Struct:
+		if _, _, ok := patchFields(typ); ok {
+			return sb.makePatchParser(typ)
+		}
 		parser, argType, err := sb.makeStructParser(typ)
 		if err != nil {
 			return nil, nil, err
@@ -290,13 +345,16 @@ func (sb *schemaBuilder) getEnumArgParser(typ reflect.Type) (*argParser, graphql
 		if !ok {
 			return errors.New("not a string")
 		}
+		if name, ok := sb.enumMappings[typ].Aliases[asString]; ok {
+			asString = name
+		}
 		val, ok := sb.enumMappings[typ].Map[asString]
 		if !ok {
 			return fmt.Errorf("unknown enum value %v", asString)
 		}
 		dest.Set(reflect.ValueOf(val).Convert(dest.Type()))
 		return nil
-	}, Type: typ}, &graphql.Enum{Type: typ.Name(), Values: values, ReverseMap: sb.enumMappings[typ].ReverseMap}
+	}, Type: typ}, sb.enumMappings[typ].graphqlEnum(typ.Name(), values)
 
 }
 
@@ -0,0 +1,204 @@
+package schemabuilder
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+
+	"github.com/denkhaus/thunder/graphql"
+)
+
+// JSON values hold loosely structured data. Fields and arguments of type
+// map[string]interface{} or json.RawMessage are exposed as the JSON scalar.
+// Maps must hold JSON values: nil, bools, numbers, strings, and slices and
+// string-keyed maps of JSON values.
+
+const jsonScalarName = "JSON"
+
+var (
+	jsonMapType = reflect.TypeOf(map[string]interface{}{})
+	rawJSONType = reflect.TypeOf(json.RawMessage{})
+)
+
+// isJSONType returns true if typ is exposed as the JSON scalar.
+func isJSONType(typ reflect.Type) bool {
+	return typ == jsonMapType || typ == rawJSONType
+}
+
+// getJSONType returns the JSON scalar type. JSON values are nullable, as nil
+// maps and empty raw messages are null.
+func getJSONType() graphql.Type {
+	return &graphql.Scalar{
+		Type:      jsonScalarName,
+		Unwrapper: unwrapJSON,
+	}
+}
+
+// unwrapJSON converts a JSON value to the plain values of json.Unmarshal, so
+// that responses and diffs treat it like any other value.
+func unwrapJSON(source interface{}) (interface{}, error) {
+	switch source := source.(type) {
+	case json.RawMessage:
+		return parseRawJSON(source)
+	case *json.RawMessage:
+		if source == nil {
+			return nil, nil
+		}
+		return parseRawJSON(*source)
+	case *map[string]interface{}:
+		if source == nil {
+			return nil, nil
+		}
+		return normalizeJSON(*source)
+	}
+	return normalizeJSON(source)
+}
+
+func parseRawJSON(raw json.RawMessage) (interface{}, error) {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return nil, nil
+	}
+	// Decode numbers as json.Number, so that large integers keep their
+	// precision.
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %v", err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, errors.New("invalid JSON: data after top-level value")
+	}
+	return normalizeJSON(value)
+}
+
+// normalizeJSON checks that value is JSON-representable, and converts it to
+// the plain values of json.Unmarshal. Integers are kept as int64 or uint64
+// instead of float64, which cannot hold integers above 2^53, such as IDs.
+func normalizeJSON(value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	switch value := value.(type) {
+	case bool, string, float64:
+		if f, ok := value.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			return nil, errors.New("JSON numbers must be finite")
+		}
+		return value, nil
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return i, nil
+		}
+		if u, err := strconv.ParseUint(string(value), 10, 64); err == nil {
+			return u, nil
+		}
+		return value.Float64()
+	case json.RawMessage:
+		return parseRawJSON(value)
+	case map[string]interface{}:
+		if value == nil {
+			return nil, nil
+		}
+		normalized := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			n, err := normalizeJSON(v)
+			if err != nil {
+				return nil, fmt.Errorf("key %s: %v", k, err)
+			}
+			normalized[k] = n
+		}
+		return normalized, nil
+	case []interface{}:
+		normalized := make([]interface{}, len(value))
+		for i, v := range value {
+			n, err := normalizeJSON(v)
+			if err != nil {
+				return nil, fmt.Errorf("index %d: %v", i, err)
+			}
+			normalized[i] = n
+		}
+		return normalized, nil
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint(), nil
+	case reflect.Float32, reflect.Float64:
+		return normalizeJSON(v.Float())
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return normalizeJSON(v.Elem().Interface())
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil, nil
+		}
+		normalized := make([]interface{}, v.Len())
+		for i := range normalized {
+			n, err := normalizeJSON(v.Index(i).Interface())
+			if err != nil {
+				return nil, fmt.Errorf("index %d: %v", i, err)
+			}
+			normalized[i] = n
+		}
+		return normalized, nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("JSON object keys must be strings, not %s", v.Type().Key())
+		}
+		if v.IsNil() {
+			return nil, nil
+		}
+		normalized := make(map[string]interface{}, v.Len())
+		for _, key := range v.MapKeys() {
+			n, err := normalizeJSON(v.MapIndex(key).Interface())
+			if err != nil {
+				return nil, fmt.Errorf("key %s: %v", key.String(), err)
+			}
+			normalized[key.String()] = n
+		}
+		return normalized, nil
+	}
+	return nil, fmt.Errorf("%T is not a JSON value", value)
+}
+
+// makeJSONArgParser parses JSON arguments. Maps accept JSON objects, and raw
+// messages accept any JSON value.
+func makeJSONArgParser(typ reflect.Type) (*argParser, graphql.Type) {
+	return &argParser{
+		FromJSON: func(value interface{}, dest reflect.Value) error {
+			if typ == jsonMapType {
+				if value == nil {
+					return nil
+				}
+				object, ok := value.(map[string]interface{})
+				if !ok {
+					return errors.New("not a JSON object")
+				}
+				dest.Set(reflect.ValueOf(object))
+				return nil
+			}
+
+			raw, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			dest.Set(reflect.ValueOf(json.RawMessage(raw)))
+			return nil
+		},
+		Type: typ,
+	}, &graphql.Scalar{Type: jsonScalarName}
+}
@@ -0,0 +1,98 @@
+package schemabuilder
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/denkhaus/thunder/batch"
+)
+
+// FieldOptioner can be implemented by resolvers passed to FieldMethods to
+// configure the fields of their methods.
+type FieldOptioner interface {
+	// FieldOptions returns the options of fields, by field name.
+	FieldOptions() map[string][]FieldFuncOption
+}
+
+var (
+	fieldOptionerType = reflect.TypeOf((*FieldOptioner)(nil)).Elem()
+	batchIndexType    = reflect.TypeOf(batch.Index{})
+)
+
+// FieldMethods exposes the exported methods of resolver as fields on an
+// object, as an alternative to many individual FieldFunc calls for large
+// types. Every method is passed to FieldFunc, or to BatchFieldFunc if it takes
+// a batch of sources, under its name with the first letter lowercased, and
+// must have a signature accepted by them.
+//
+// For example, the fields fullName and friends of a User object can be
+// implemented by a resolver holding the dependencies they need:
+//
+//	type userResolver struct{ db *DB }
+//
+//	func (r *userResolver) FullName(u *User) string {
+//		return u.FirstName + " " + u.LastName
+//	}
+//
+//	func (r *userResolver) Friends(ctx context.Context, u *User) ([]*User, error) {
+//		return r.db.Friends(ctx, u.Id)
+//	}
+//
+//	user.FieldMethods(&userResolver{db: db})
+//
+// Resolvers can implement FieldOptioner to pass options to their fields.
+func (s *Object) FieldMethods(resolver interface{}) {
+	value := reflect.ValueOf(resolver)
+	typ := value.Type()
+
+	var options map[string][]FieldFuncOption
+	if optioner, ok := resolver.(FieldOptioner); ok {
+		options = optioner.FieldOptions()
+	}
+
+	fields := make(map[string]bool)
+	for i := 0; i < typ.NumMethod(); i++ {
+		method := typ.Method(i)
+		if method.PkgPath != "" || isFieldOptionerMethod(method) {
+			continue
+		}
+		name := makeGraphql(method.Name)
+		fields[name] = true
+
+		fn := value.Method(i).Interface()
+		if takesBatch(method.Type) {
+			s.BatchFieldFunc(name, fn, options[name]...)
+		} else {
+			s.FieldFunc(name, fn, options[name]...)
+		}
+	}
+
+	if len(fields) == 0 {
+		panic(fmt.Sprintf("resolver %s for object %s has no exported methods", typ, s.Name))
+	}
+	for name := range options {
+		if !fields[name] {
+			panic(fmt.Sprintf("resolver %s for object %s has options for unknown field %s", typ, s.Name, name))
+		}
+	}
+}
+
+// isFieldOptionerMethod returns true if method implements FieldOptioner
+// instead of a field.
+func isFieldOptionerMethod(method reflect.Method) bool {
+	optionerMethod, _ := fieldOptionerType.MethodByName("FieldOptions")
+	return method.Name == optionerMethod.Name && method.Type.NumIn() == 1 &&
+		method.Type.NumOut() == 1 && method.Type.Out(0) == optionerMethod.Type.Out(0)
+}
+
+// takesBatch returns true if the method of type typ takes a batch of sources,
+// a map keyed by batch.Index.
+func takesBatch(typ reflect.Type) bool {
+	for i := 0; i < typ.NumIn(); i++ {
+		in := typ.In(i)
+		if in.Kind() == reflect.Map && in.Key() == batchIndexType {
+			return true
+		}
+	}
+	return false
+}
@@ -2,6 +2,7 @@ package schemabuilder
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"sort"
@@ -29,12 +30,12 @@ func (sb *schemaBuilder) buildStruct(typ reflect.Type) error {
 	var name string
 	var description string
 	var methods Methods
-	var objectKey string
+	var objectKeys []string
 	if object, ok := sb.objects[typ]; ok {
 		name = object.Name
 		description = object.Description
 		methods = object.Methods
-		objectKey = object.key
+		objectKeys = object.keys
 	}
 
 	if name == "" {
@@ -64,7 +65,10 @@ func (sb *schemaBuilder) buildStruct(typ reflect.Type) error {
 		
 		if fieldInfo.Skipped {
 			continue
-		}		
+		}
+		if fieldInfo.Filterable || fieldInfo.Sortable {
+			sb.taggedObjects[typ] = true
+		}
 
 		if _, ok := object.Fields[fieldInfo.Name]; ok {
 			return fmt.Errorf("bad type %s: two fields named %s", typ, fieldInfo.Name)
@@ -74,6 +78,7 @@ func (sb *schemaBuilder) buildStruct(typ reflect.Type) error {
 		if err != nil {
 			return fmt.Errorf("bad field %s on type %s: %s", fieldInfo.Name, typ, err)
 		}
+		built.Description = field.Tag.Get("description")
 		object.Fields[fieldInfo.Name] = built
 		if fieldInfo.KeyField {
 			if object.KeyField != nil {
@@ -83,6 +88,7 @@ func (sb *schemaBuilder) buildStruct(typ reflect.Type) error {
 				return fmt.Errorf("bad type %s: key type must be scalar, got %T", typ, built.Type)
 			}
 			object.KeyField = built
+			object.KeyFields = []string{fieldInfo.Name}
 		}
 	}
 
@@ -95,6 +101,13 @@ func (sb *schemaBuilder) buildStruct(typ reflect.Type) error {
 	for _, name := range names {
 		method := methods[name]
 
+		if method.PayloadResultField != "" && (method.Batch || method.Paginated) {
+			return fmt.Errorf("bad method %s on type %s: batch and paginated functions cannot have a mutation payload", name, typ)
+		}
+		if method.Pure && (method.Batch || method.Paginated) {
+			return fmt.Errorf("bad method %s on type %s: batch and paginated functions cannot be pure", name, typ)
+		}
+
 		if method.Batch {
 			if method.BatchArgs.FallbackFunc != nil {
 				batchField, err := sb.buildBatchFunctionWithFallback(typ, method)
@@ -133,24 +146,138 @@ func (sb *schemaBuilder) buildStruct(typ reflect.Type) error {
 		if err != nil {
 			return fmt.Errorf("bad method %s on type %s: %s", name, typ, err)
 		}
+		if method.PayloadResultField != "" {
+			built, err = sb.wrapPayloadField(name, built, method.PayloadResultField)
+			if err != nil {
+				return fmt.Errorf("bad method %s on type %s: %s", name, typ, err)
+			}
+		}
 		object.Fields[name] = built
 	}
 
-	if objectKey != "" {
-		keyPtr, ok := object.Fields[objectKey]
-		if !ok {
-			return fmt.Errorf("key field doesn't exist on object")
+	for _, name := range names {
+		object.Fields[name].DeprecationReason = methods[name].DeprecationReason
+		object.Fields[name].Description = methods[name].Description
+		if err := setSensitiveArgs(object.Fields[name], methods[name].SensitiveArgs); err != nil {
+			return fmt.Errorf("bad method %s on type %s: %s", name, typ, err)
+		}
+		if methods[name].CacheTTL > 0 && object.Fields[name].DecodeCached == nil {
+			return fmt.Errorf("bad method %s on type %s: only non-batch, non-paginated functions with a result can be cached", name, typ)
+		}
+		object.Fields[name].Visible = methods[name].visible()
+	}
+
+	if len(objectKeys) > 0 {
+		keyFields := make([]*graphql.Field, 0, len(objectKeys))
+		seen := make(map[string]bool, len(objectKeys))
+		for _, key := range objectKeys {
+			if seen[key] {
+				return fmt.Errorf("bad type %s: duplicate key field %s", typ, key)
+			}
+			seen[key] = true
+
+			keyPtr, ok := object.Fields[key]
+			if !ok {
+				return fmt.Errorf("key field doesn't exist on object")
+			}
+			if keyPtr.Visible != nil {
+				return fmt.Errorf("bad type %s: key field cannot be hidden", typ)
+			}
+			if !isScalarType(keyPtr.Type) {
+				return fmt.Errorf("bad type %s: key type must be scalar, got %s", typ, keyPtr.Type.String())
+			}
+			// Keys are resolved without arguments, and compound keys call
+			// the Resolve of their fields directly.
+			if len(keyPtr.Args) > 0 {
+				return fmt.Errorf("bad type %s: key field %s cannot take arguments", typ, key)
+			}
+			if len(objectKeys) > 1 && keyPtr.Resolve == nil {
+				return fmt.Errorf("bad type %s: compound key field %s cannot be a batch field", typ, key)
+			}
+			keyFields = append(keyFields, keyPtr)
 		}
 
-		if !isScalarType(keyPtr.Type) {
-			return fmt.Errorf("bad type %s: key type must be scalar, got %s", typ, keyPtr.Type.String())
+		object.KeyFields = objectKeys
+		if len(keyFields) == 1 {
+			object.KeyField = keyFields[0]
+		} else {
+			object.KeyField = compoundKeyField(keyFields)
 		}
-		object.KeyField = keyPtr
 	}
 
 	return nil
 }
 
+// compoundKeyField returns a field that resolves to the values of fields,
+// encoded as a JSON array, so that compound keys are comparable like other
+// keys.
+func compoundKeyField(fields []*graphql.Field) *graphql.Field {
+	return &graphql.Field{
+		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
+			values := make([]interface{}, 0, len(fields))
+			for _, field := range fields {
+				value, err := field.Resolve(ctx, source, nil, nil)
+				if err != nil {
+					return nil, err
+				}
+				typ := field.Type
+				if nonNull, ok := typ.(*graphql.NonNull); ok {
+					typ = nonNull.Type
+				}
+				if scalar, ok := typ.(*graphql.Scalar); ok && scalar.Unwrapper != nil {
+					if value, err = scalar.Unwrapper(value); err != nil {
+						return nil, err
+					}
+				}
+				values = append(values, value)
+			}
+			key, err := json.Marshal(values)
+			if err != nil {
+				return nil, err
+			}
+			return string(key), nil
+		},
+		Type:           &graphql.Scalar{Type: "string"},
+		ParseArguments: nilParseArguments,
+	}
+}
+
+// visible returns the graphql.Field Visible func for m's feature flags and
+// minimum schema version, or nil if m is always visible.
+func (m *method) visible() func(ctx context.Context) bool {
+	if len(m.FeatureFlags) == 0 && m.MinSchemaVersion == 0 {
+		return nil
+	}
+	flags := m.FeatureFlags
+	minVersion := m.MinSchemaVersion
+	return func(ctx context.Context) bool {
+		if graphql.SchemaVersion(ctx) < minVersion {
+			return false
+		}
+		for _, flag := range flags {
+			if !flag(ctx) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// setSensitiveArgs marks args of field as sensitive.
+func setSensitiveArgs(field *graphql.Field, args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+	field.SensitiveArgs = make(map[string]bool, len(args))
+	for _, arg := range args {
+		if _, ok := field.Args[arg]; !ok {
+			return fmt.Errorf("sensitive argument %s doesn't exist", arg)
+		}
+		field.SensitiveArgs[arg] = true
+	}
+	return nil
+}
+
 // hasUnionMarkerEmbedded determines if a struct has an embedded schemabuilder.Union
 // field embedded on the type.
 func hasUnionMarkerEmbedded(typ reflect.Type) bool {
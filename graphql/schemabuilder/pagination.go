@@ -944,6 +944,59 @@ func (c *connectionContext) consumeTextFilters(sb *schemaBuilder, m *method, typ
 	return nil
 }
 
+// consumeTaggedFields adds text filters and sorts for the fields of the node
+// struct tagged as filterable or sortable, next to the ones registered through
+// FilterField and SortField.
+func (c *connectionContext) consumeTaggedFields(sb *schemaBuilder, typ reflect.Type) error {
+	if typ.Kind() != reflect.Struct {
+		return nil
+	}
+	sb.paginatedNodes[typ] = true
+
+	for i := 0; i < typ.NumField(); i++ {
+		structField := typ.Field(i)
+		fieldInfo, err := parseGraphQLFieldInfo(structField)
+		if err != nil {
+			return err
+		}
+		if fieldInfo.Skipped || (!fieldInfo.Filterable && !fieldInfo.Sortable) {
+			continue
+		}
+
+		if fieldInfo.Filterable && structField.Type != typeOfString {
+			return fmt.Errorf("invalid text filter field %s: unsupported type %v, must be a string", fieldInfo.Name, structField.Type)
+		}
+		if fieldInfo.Sortable && !supportedSort(structField.Type) {
+			return fmt.Errorf(
+				"invalid sort field %s: unsupported type %v, must be of kind int, uint, float or string",
+				fieldInfo.Name,
+				structField.Type,
+			)
+		}
+
+		field, err := sb.buildField(structField)
+		if err != nil {
+			return err
+		}
+
+		if fieldInfo.Filterable {
+			if _, ok := c.FilterTextFields[fieldInfo.Name]; ok {
+				return fmt.Errorf("duplicate text filter field %s", fieldInfo.Name)
+			}
+			c.FilterTextFields[fieldInfo.Name] = field
+		}
+
+		if fieldInfo.Sortable {
+			if _, ok := c.SortFields[fieldInfo.Name]; ok {
+				return fmt.Errorf("duplicate sort field %s", fieldInfo.Name)
+			}
+			c.SortFields[fieldInfo.Name] = field
+			c.SortFunctions[fieldInfo.Name] = getSort(structField.Type)
+		}
+	}
+	return nil
+}
+
 func (c *connectionContext) consumePaginatedArgs(sb *schemaBuilder, in []reflect.Type) (*argParser, graphql.Type, []reflect.Type, error) {
 	var argParser *argParser
 	var argType graphql.Type
@@ -983,7 +1036,13 @@ func (sb *schemaBuilder) getKeyFieldOnStruct(nodeType reflect.Type) (string, err
 	if nodeObj == nil {
 		return "", fmt.Errorf("%s must be a struct and registered as an object along with its key", nodeType)
 	}
-	nodeKey := reverseGraphqlFieldName(nodeObj.key)
+	if len(nodeObj.keys) > 1 {
+		return "", fmt.Errorf("paginated objects must have a single key field")
+	}
+	var nodeKey string
+	if len(nodeObj.keys) == 1 {
+		nodeKey = reverseGraphqlFieldName(nodeObj.keys[0])
+	}
 	if nodeKey == "" {
 		return nodeKey, fmt.Errorf("a key field must be registered for paginated objects")
 	}
@@ -1047,6 +1106,7 @@ func (sb *schemaBuilder) buildPaginatedFieldWithFallback(typ reflect.Type, m *me
 		Paginated:         m.Paginated,
 		TextFilterMethods: m.TextFilterMethods,
 		SortMethods:       m.SortMethods,
+		MaxPageSize:       m.MaxPageSize,
 	})
 	if err != nil {
 		return nil, err
@@ -1100,6 +1160,8 @@ func (sb *schemaBuilder) buildPaginatedFieldWithFallback(typ reflect.Type, m *me
 		External:                   manualPaginationField.External,
 		Expensive:                  manualPaginationField.Expensive,
 		NumParallelInvocationsFunc: manualPaginationField.NumParallelInvocationsFunc,
+		Serial:                     manualPaginationField.Serial,
+		Pooled:                     manualPaginationField.Pooled,
 	}
 
 	return field, nil
@@ -1171,6 +1233,10 @@ func (sb *schemaBuilder) buildPaginatedFunctionAndFuncCtx(typ reflect.Type, m *m
 		return nil, nil, err
 	}
 
+	if err := c.consumeTaggedFields(sb, nonPtrNodeType); err != nil {
+		return nil, nil, err
+	}
+
 	c.Key, err = sb.getKeyFieldOnStruct(nodeType)
 	if err != nil {
 		return nil, nil, err
@@ -1178,6 +1244,11 @@ func (sb *schemaBuilder) buildPaginatedFunctionAndFuncCtx(typ reflect.Type, m *m
 
 	args, err := c.argsTypeMap(argType)
 
+	maxPageSize := sb.maxPageSize
+	if m.MaxPageSize > 0 {
+		maxPageSize = m.MaxPageSize
+	}
+
 	ret := &graphql.Field{
 		Resolve: func(ctx context.Context, source, args interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
 
@@ -1201,14 +1272,42 @@ func (sb *schemaBuilder) buildPaginatedFunctionAndFuncCtx(typ reflect.Type, m *m
 		},
 		Args:                       args,
 		Type:                       retType,
-		ParseArguments:             argParser.Parse,
+		ParseArguments:             c.limitPageSize(argParser.Parse, maxPageSize),
 		Expensive:                  m.Expensive,
 		External:                   true,
 		NumParallelInvocationsFunc: m.ConcurrencyArgs.numParallelInvocationsFunc,
+		Serial:                     m.ConcurrencyArgs.serial,
+		Pooled:                     m.ConcurrencyArgs.pooled,
 	}
 	return ret, c, nil
 }
 
+// limitPageSize wraps parse, the argument parser of the connection, to reject
+// first and last arguments above max. A max of 0 does not limit page sizes.
+func (c *connectionContext) limitPageSize(parse func(interface{}) (interface{}, error), max int64) func(interface{}) (interface{}, error) {
+	if max <= 0 {
+		return parse
+	}
+	return func(args interface{}) (interface{}, error) {
+		parsed, err := parse(args)
+		if err != nil {
+			return nil, err
+		}
+
+		var first, last *int64
+		if c.embedsPaginationArgs() {
+			paginationArgs := reflect.ValueOf(parsed).Field(c.PaginationArgsIndex).Interface().(PaginationArgs)
+			first, last = paginationArgs.First, paginationArgs.Last
+		} else if connectionArgs, ok := parsed.(ConnectionArgs); ok {
+			first, last = connectionArgs.First, connectionArgs.Last
+		}
+		if safeInt64Ptr(first) > max || safeInt64Ptr(last) > max {
+			return nil, fmt.Errorf("first/last cannot exceed %d", max)
+		}
+		return parsed, nil
+	}
+}
+
 func (c *connectionContext) extractReturnAndErr(ctx context.Context, out []reflect.Value, args interface{}, retType graphql.Type) (interface{}, error) {
 	var paginationArgs PaginationArgs
 
@@ -0,0 +1,292 @@
+package schemabuilder
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/denkhaus/thunder/graphql"
+)
+
+// Patch is a special marker struct that can be embedded into an input struct
+// next to another struct to denote a partial update of that struct, to be
+// applied with ApplyPatch.
+//
+// For example, a patch of a User might look like:
+//
+//	type UserPatch struct {
+//	  schemabuilder.Patch
+//	  User
+//	}
+//
+// The UserPatch input object has the fields of User, but every field is
+// optional. Struct fields of User are patches themselves, so that a nested
+// object only updates the fields it sets, while lists and scalars are
+// replaced. A field explicitly set to null resets the field to its zero
+// value, eg. nil for pointers.
+type Patch struct {
+	input *patchInput
+	// provided holds the provided fields, by GraphQL name.
+	provided Provided
+	// nested holds the patches of struct fields provided as objects, by
+	// GraphQL name.
+	nested map[string]*Patch
+}
+
+// Has returns true if the field with the given GraphQL name was provided.
+func (p Patch) Has(name string) bool {
+	return p.provided.Has(name)
+}
+
+var patchType = reflect.TypeOf(Patch{})
+
+// patchInput describes the input object of a patch of a struct.
+type patchInput struct {
+	argType *graphql.InputObject
+	fields  map[string]*patchField
+}
+
+// patchField is a field of a patchInput.
+type patchField struct {
+	// index is the index of the field in the patched struct.
+	index []int
+	// parser parses fields that are replaced.
+	parser *argParser
+	// nested is the patch of struct fields, and ptr is true if the field is a
+	// pointer to the struct.
+	nested *patchInput
+	ptr    bool
+}
+
+// patchFields returns the indices of the embedded Patch and patched struct of
+// a patch type, or ok false if typ is not a patch type.
+func patchFields(typ reflect.Type) (patchIndex, sourceIndex int, ok bool) {
+	if typ.Kind() != reflect.Struct || typ.NumField() != 2 {
+		return 0, 0, false
+	}
+	for i := 0; i < 2; i++ {
+		if typ.Field(i).Type == patchType && typ.Field(i).Anonymous {
+			return i, 1 - i, true
+		}
+	}
+	return 0, 0, false
+}
+
+// makePatchParser constructs an argParser for the patch type typ.
+func (sb *schemaBuilder) makePatchParser(typ reflect.Type) (*argParser, graphql.Type, error) {
+	patchIndex, sourceIndex, _ := patchFields(typ)
+	source := typ.Field(sourceIndex)
+	if !source.Anonymous || source.Type.Kind() != reflect.Struct {
+		return nil, nil, fmt.Errorf("bad patch type %s: should embed a struct next to schemabuilder.Patch", typ)
+	}
+
+	input, err := sb.makePatchInput(source.Type, typ.Name())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &argParser{
+		FromJSON: func(value interface{}, dest reflect.Value) error {
+			patch, err := parsePatch(input, value, dest.Field(sourceIndex))
+			if err != nil {
+				return err
+			}
+			dest.Field(patchIndex).Set(reflect.ValueOf(*patch))
+			return nil
+		},
+		Type: typ,
+	}, input.argType, nil
+}
+
+// makePatchInput returns the patchInput of the struct typ, named name.
+func (sb *schemaBuilder) makePatchInput(typ reflect.Type, name string) (*patchInput, error) {
+	if sb.patchInputs == nil {
+		sb.patchInputs = make(map[reflect.Type]*patchInput)
+	}
+	if input, ok := sb.patchInputs[typ]; ok {
+		return input, nil
+	}
+
+	input := &patchInput{
+		argType: &graphql.InputObject{
+			Name:        name + "_InputObject",
+			InputFields: make(map[string]graphql.Type),
+		},
+		fields: make(map[string]*patchField),
+	}
+	// Cache the input ahead of time to catch self-reference.
+	sb.patchInputs[typ] = input
+
+	if err := sb.collectPatchFields(typ, nil, input); err != nil {
+		return nil, err
+	}
+	return input, nil
+}
+
+// collectPatchFields collects the fields of the struct typ, embedded at index
+// in the patched struct, into input.
+func (sb *schemaBuilder) collectPatchFields(typ reflect.Type, index []int, input *patchInput) error {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+
+		fieldInfo, err := parseGraphQLFieldInfo(field)
+		if err != nil {
+			return fmt.Errorf("bad type %s: %s", typ, err.Error())
+		}
+		if fieldInfo.Skipped || field.Type == providedType {
+			continue
+		}
+		if fieldInfo.Filterable || fieldInfo.Sortable {
+			return fmt.Errorf("bad patch type %s: field %s of an input cannot be filterable or sortable", typ, fieldInfo.Name)
+		}
+
+		fieldIndex := append(append([]int{}, index...), i)
+		if field.Anonymous {
+			if field.Type.Kind() != reflect.Struct {
+				return fmt.Errorf("bad patch type %s: embedded %s should be a struct", typ, field.Type)
+			}
+			if err := sb.collectPatchFields(field.Type, fieldIndex, input); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if _, ok := input.fields[fieldInfo.Name]; ok {
+			return fmt.Errorf("bad patch type %s: duplicate field %s", typ, fieldInfo.Name)
+		}
+
+		patchField := &patchField{index: fieldIndex}
+		var fieldArgTyp graphql.Type
+		if elem, ptr := sb.patchableStruct(field.Type); elem != nil {
+			nested, err := sb.makePatchInput(elem, elem.Name()+"Patch")
+			if err != nil {
+				return err
+			}
+			patchField.nested, patchField.ptr = nested, ptr
+			fieldArgTyp = nested.argType
+		} else {
+			parser, argTyp, err := sb.makeArgParser(field.Type)
+			if err != nil {
+				return err
+			}
+			patchField.parser, fieldArgTyp = wrapWithZeroValue(parser, argTyp)
+		}
+
+		input.fields[fieldInfo.Name] = patchField
+		input.argType.InputFields[fieldInfo.Name] = fieldArgTyp
+	}
+	return nil
+}
+
+// patchableStruct returns the struct type of fields of type typ that are
+// patched field by field, and whether typ is a pointer to it, or nil if
+// fields of type typ are replaced.
+func (sb *schemaBuilder) patchableStruct(typ reflect.Type) (reflect.Type, bool) {
+	ptr := typ.Kind() == reflect.Ptr
+	if ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct || typ.Name() == "" || sb.enumMappings[typ] != nil || isJSONType(typ) ||
+		reflect.PtrTo(typ).Implements(textUnmarshalerType) {
+		return nil, false
+	}
+	if _, _, ok := getScalarArgParser(typ); ok {
+		return nil, false
+	}
+	if _, _, ok := patchFields(typ); ok {
+		return nil, false
+	}
+	return typ, ptr
+}
+
+// parsePatch parses value, the input object of a patch, into dest, and
+// returns the parsed Patch.
+func parsePatch(input *patchInput, value interface{}, dest reflect.Value) (*Patch, error) {
+	asMap, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("not an object")
+	}
+
+	patch := &Patch{
+		input:    input,
+		provided: make(Provided, len(asMap)),
+	}
+	for name, value := range asMap {
+		field, ok := input.fields[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown arg %s", name)
+		}
+		patch.provided[name] = true
+		if value == nil {
+			continue
+		}
+
+		fieldDest := dest.FieldByIndex(field.index)
+		if field.nested == nil {
+			if err := field.parser.FromJSON(value, fieldDest); err != nil {
+				return nil, fmt.Errorf("%s: %s", name, err)
+			}
+			continue
+		}
+
+		if field.ptr {
+			fieldDest.Set(reflect.New(fieldDest.Type().Elem()))
+			fieldDest = fieldDest.Elem()
+		}
+		nested, err := parsePatch(field.nested, value, fieldDest)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+		if patch.nested == nil {
+			patch.nested = make(map[string]*Patch)
+		}
+		patch.nested[name] = nested
+	}
+	return patch, nil
+}
+
+// ApplyPatch applies patch, a struct embedding Patch, to dest, a pointer to
+// the patched struct. Only the provided fields of patch are copied to dest;
+// nested patches are applied to the existing values of their fields,
+// allocating nil pointers first.
+func ApplyPatch(dest interface{}, patch interface{}) error {
+	patchValue := reflect.ValueOf(patch)
+	if patchValue.Kind() == reflect.Ptr {
+		patchValue = patchValue.Elem()
+	}
+	patchIndex, sourceIndex, ok := patchFields(patchValue.Type())
+	if !ok {
+		return fmt.Errorf("patch should be a struct embedding schemabuilder.Patch, not %s", patchValue.Type())
+	}
+	source := patchValue.Field(sourceIndex)
+
+	destValue := reflect.ValueOf(dest)
+	if destValue.Kind() != reflect.Ptr || destValue.Type().Elem() != source.Type() {
+		return fmt.Errorf("dest should be a %s, not %s", reflect.PtrTo(source.Type()), destValue.Type())
+	}
+
+	applyPatch(destValue.Elem(), source, patchValue.Field(patchIndex).Interface().(Patch))
+	return nil
+}
+
+// applyPatch copies the fields of source provided in patch to dest.
+func applyPatch(dest, source reflect.Value, patch Patch) {
+	for name := range patch.provided {
+		field := patch.input.fields[name]
+		fieldDest := dest.FieldByIndex(field.index)
+		fieldSource := source.FieldByIndex(field.index)
+
+		nested, ok := patch.nested[name]
+		if !ok {
+			fieldDest.Set(fieldSource)
+			continue
+		}
+		if field.ptr {
+			if fieldDest.IsNil() {
+				fieldDest.Set(reflect.New(fieldDest.Type().Elem()))
+			}
+			fieldDest, fieldSource = fieldDest.Elem(), fieldSource.Elem()
+		}
+		applyPatch(fieldDest, fieldSource, *nested)
+	}
+}
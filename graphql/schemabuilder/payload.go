@@ -0,0 +1,160 @@
+package schemabuilder
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/denkhaus/thunder/graphql"
+)
+
+// UserError is a domain error that is returned to clients as data in a
+// mutation payload rather than as a top-level GraphQL error, for example
+// failed input validation. Return a *UserError or UserErrors from a FieldFunc
+// marked with MutationPayload to fill the payload's userErrors.
+type UserError struct {
+	// Field is the path to the input argument that caused the error, if any,
+	// such as ["input", "email"].
+	Field []string
+	// Message is a human-readable description of the error.
+	Message string
+	// Code is a machine-readable error code, such as "TAKEN".
+	Code string
+}
+
+// NewUserError creates a UserError for the given input field path.
+func NewUserError(field []string, code string, format string, a ...interface{}) *UserError {
+	return &UserError{
+		Field:   field,
+		Message: fmt.Sprintf(format, a...),
+		Code:    code,
+	}
+}
+
+func (e *UserError) Error() string {
+	if len(e.Field) == 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", strings.Join(e.Field, "."), e.Message)
+}
+
+// UserErrors groups several UserErrors, such as all validation failures of a
+// mutation's input.
+type UserErrors []*UserError
+
+func (e UserErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// asUserErrors returns the user errors held by err, if any.
+func asUserErrors(err error) ([]*UserError, bool) {
+	switch err := err.(type) {
+	case *UserError:
+		return []*UserError{err}, true
+	case UserErrors:
+		return err, true
+	default:
+		return nil, false
+	}
+}
+
+// MutationPayload is an option that can be passed to a FieldFunc to wrap its
+// return value in a generated payload object following the "errors as data"
+// convention. The payload of a field named createUser is named
+// CreateUserPayload, and has two fields: resultField, which holds the
+// function's return value, and userErrors, which holds the *UserError or
+// UserErrors returned by the function. Other errors are still returned as
+// top-level GraphQL errors.
+//
+//	mutation.FieldFunc("createUser", func(args struct{ Email string }) (*User, error) {
+//		if taken(args.Email) {
+//			return nil, schemabuilder.NewUserError([]string{"email"}, "TAKEN", "%s is taken", args.Email)
+//		}
+//		...
+//	}, schemabuilder.MutationPayload("user"))
+//
+// exposes
+//
+//	createUser(email: String!): CreateUserPayload!
+//	type CreateUserPayload { user: User, userErrors: [UserError!]! }
+func MutationPayload(resultField string) FieldFuncOption {
+	return fieldFuncOptionFunc(func(m *method) {
+		m.PayloadResultField = resultField
+	})
+}
+
+// mutationPayload is the value resolved for generated payload objects.
+type mutationPayload struct {
+	result     interface{}
+	userErrors []*UserError
+}
+
+var mutationPayloadType = reflect.TypeOf(mutationPayload{})
+
+// wrapPayloadField wraps a built FieldFunc named name so that it returns a
+// generated payload object, see MutationPayload.
+func (sb *schemaBuilder) wrapPayloadField(name string, field *graphql.Field, resultField string) (*graphql.Field, error) {
+	payloadName := reverseGraphqlFieldName(name) + "Payload"
+	if originalType, ok := sb.typeNames[payloadName]; ok {
+		return nil, fmt.Errorf("duplicate name %s: seen both %v and a generated payload", payloadName, originalType)
+	}
+	sb.typeNames[payloadName] = mutationPayloadType
+
+	if resultField == "userErrors" {
+		return nil, fmt.Errorf("payload %s: result field cannot be named userErrors", payloadName)
+	}
+
+	userErrorsType, err := sb.getType(reflect.TypeOf([]*UserError(nil)))
+	if err != nil {
+		return nil, err
+	}
+
+	// The result is null whenever the function returned user errors.
+	resultType := field.Type
+	if nonNull, ok := resultType.(*graphql.NonNull); ok {
+		resultType = nonNull.Type
+	}
+
+	payload := &graphql.Object{
+		Name: payloadName,
+		Fields: map[string]*graphql.Field{
+			resultField: {
+				Resolve: func(ctx context.Context, source, args interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
+					return source.(*mutationPayload).result, nil
+				},
+				Type:           resultType,
+				ParseArguments: nilParseArguments,
+			},
+			"userErrors": {
+				Resolve: func(ctx context.Context, source, args interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
+					userErrors := source.(*mutationPayload).userErrors
+					if userErrors == nil {
+						userErrors = []*UserError{}
+					}
+					return userErrors, nil
+				},
+				Type:           userErrorsType,
+				ParseArguments: nilParseArguments,
+			},
+		},
+	}
+
+	wrapped := *field
+	wrapped.Type = &graphql.NonNull{Type: payload}
+	wrapped.Resolve = func(ctx context.Context, source, args interface{}, selectionSet *graphql.SelectionSet) (interface{}, error) {
+		result, err := field.Resolve(ctx, source, args, selectionSet)
+		if err != nil {
+			if userErrors, ok := asUserErrors(err); ok {
+				return &mutationPayload{userErrors: userErrors}, nil
+			}
+			return nil, err
+		}
+		return &mutationPayload{result: result}, nil
+	}
+	return &wrapped, nil
+}
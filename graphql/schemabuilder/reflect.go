@@ -53,6 +53,14 @@ type graphQLFieldInfo struct {
 	// OptionalInputField indicates that this field should be treated as an optional
 	// field on graphQL input args.
 	OptionalInputField bool
+
+	// Filterable indicates that paginated lists of the struct can be text
+	// filtered on this field.
+	Filterable bool
+
+	// Sortable indicates that paginated lists of the struct can be sorted on
+	// this field.
+	Sortable bool
 }
 
 // parseGraphQLFieldInfo parses a struct field and returns a struct with the
@@ -75,6 +83,8 @@ func parseGraphQLFieldInfo(field reflect.StructField) (*graphQLFieldInfo, error)
 
 	var key bool
 	var optional bool
+	var filterable bool
+	var sortable bool
 
 	if len(tags) > 1 {
 		for _, tag := range tags[1:] {
@@ -82,12 +92,16 @@ func parseGraphQLFieldInfo(field reflect.StructField) (*graphQLFieldInfo, error)
 				key = true
 			} else if tag == "optional" && !optional {
 				optional = true
+			} else if tag == "filterable" && !filterable {
+				filterable = true
+			} else if tag == "sortable" && !sortable {
+				sortable = true
 			} else {
 				return nil, fmt.Errorf("field %s has unexpected tag %s", name, tag)
 			}
 		}
 	}
-	return &graphQLFieldInfo{Name: name, KeyField: key, OptionalInputField: optional}, nil
+	return &graphQLFieldInfo{Name: name, KeyField: key, OptionalInputField: optional, Filterable: filterable, Sortable: sortable}, nil
 }
 
 // Common Types that we will need to perform type assertions against.
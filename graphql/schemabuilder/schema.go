@@ -14,15 +14,24 @@ const federationName = "Federation"
 // can be registered against the "Mutation" and "Query" objects in order to
 // build out a full GraphQL schema.
 type Schema struct {
-	Name      string
-	objects   map[string]*Object
-	enumTypes map[reflect.Type]*EnumMapping
+	Name        string
+	objects     map[string]*Object
+	enumTypes   map[reflect.Type]*EnumMapping
+	maxPageSize int64
+	// err is the first mistake found while registering enums, returned by
+	// Build.
+	err error
 }
 
+// DefaultMaxPageSize is the default limit of the first and last arguments of
+// paginated fields, see Schema.SetMaxPageSize.
+const DefaultMaxPageSize = 1000
+
 // NewSchema creates a new schema.
 func NewSchema() *Schema {
 	schema := &Schema{
-		objects: make(map[string]*Object),
+		objects:     make(map[string]*Object),
+		maxPageSize: DefaultMaxPageSize,
 	}
 
 	// Default registrations.
@@ -37,8 +46,9 @@ func NewSchema() *Schema {
 // NewSchema creates a new schema with a schema name
 func NewSchemaWithName(name string) *Schema {
 	schema := &Schema{
-		Name:    name,
-		objects: make(map[string]*Object),
+		Name:        name,
+		objects:     make(map[string]*Object),
+		maxPageSize: DefaultMaxPageSize,
 	}
 
 	// Default registrations.
@@ -49,6 +59,15 @@ func NewSchemaWithName(name string) *Schema {
 	return schema
 }
 
+// SetMaxPageSize limits the first and last arguments of all paginated fields
+// to max, unless a field sets its own limit with the MaxPageSize option.
+// Queries requesting larger pages fail validation, which stops clients from
+// loading huge pages at once. It defaults to DefaultMaxPageSize, and a max of
+// 0 does not limit page sizes.
+func (s *Schema) SetMaxPageSize(max int64) {
+	s.maxPageSize = max
+}
+
 // Enum registers an enumType in the schema. The val should be any arbitrary value
 // of the enumType to be used for reflection, and the enumMap should be
 // the corresponding map of the enums.
@@ -67,14 +86,75 @@ func NewSchemaWithName(name string) *Schema {
 //     "two":   enumType(2),
 //     "three": enumType(3),
 //   })
-func (s *Schema) Enum(val interface{}, enumMap interface{}) {
+//
+// The names in the enumMap are the external names used on the wire, and need
+// not match the names of the Go constants. To rename an external name without
+// breaking existing clients, register the old name with EnumAlias.
+func (s *Schema) Enum(val interface{}, enumMap interface{}, opts ...EnumOption) {
 	typ := reflect.TypeOf(val)
 	if s.enumTypes == nil {
 		s.enumTypes = make(map[reflect.Type]*EnumMapping)
 	}
 
 	eMap, rMap := getEnumMap(enumMap, typ)
-	s.enumTypes[typ] = &EnumMapping{Map: eMap, ReverseMap: rMap}
+	mapping := &EnumMapping{Map: eMap, ReverseMap: rMap}
+	for _, opt := range opts {
+		if err := opt.apply(mapping); err != nil && s.err == nil {
+			s.err = fmt.Errorf("bad enum %s: %s", typ, err)
+		}
+	}
+	s.enumTypes[typ] = mapping
+}
+
+// EnumOption is an interface for the variadic options that can be passed to
+// Enum for configuring the mapping of an enum.
+type EnumOption interface {
+	apply(*EnumMapping) error
+}
+
+// enumOptionFunc is a helper to define EnumOptions.
+type enumOptionFunc func(*EnumMapping) error
+
+func (f enumOptionFunc) apply(m *EnumMapping) error { return f(m) }
+
+// EnumAlias is an option that can be passed to Enum to accept alias as an
+// input in place of name, for example to keep accepting the old name of a
+// renamed value. Outputs always use name, and introspection lists alias as a
+// deprecated value. Aliases of unknown values fail Build.
+func EnumAlias(alias string, name string) EnumOption {
+	return enumOptionFunc(func(m *EnumMapping) error {
+		val, ok := m.Map[name]
+		if !ok {
+			return fmt.Errorf("alias %s refers to unknown value %s", alias, name)
+		}
+		if _, ok := m.Map[alias]; ok {
+			return fmt.Errorf("alias %s is already a value", alias)
+		}
+		if m.Aliases == nil {
+			m.Aliases = make(map[string]string)
+		}
+		m.Aliases[alias] = name
+		// If several names map to the same value, make sure outputs use name.
+		m.ReverseMap[val] = name
+		return nil
+	})
+}
+
+// EnumDeprecated is an option that can be passed to Enum to mark the value
+// name as deprecated. Queries using it as an input get a deprecation warning,
+// see graphql.DeprecationMiddleware. Deprecating an unknown value fails
+// Build.
+func EnumDeprecated(name string, reason string) EnumOption {
+	return enumOptionFunc(func(m *EnumMapping) error {
+		if _, ok := m.Map[name]; !ok {
+			return fmt.Errorf("deprecated value %s is unknown", name)
+		}
+		if m.Deprecations == nil {
+			m.Deprecations = make(map[string]string)
+		}
+		m.Deprecations[name] = reason
+		return nil
+	})
 }
 
 func getEnumMap(enumMap interface{}, typ reflect.Type) (map[string]interface{}, map[interface{}]string) {
@@ -209,12 +289,20 @@ func (s *Schema) Mutation() *Object {
 // Query and Mutation Objects and ensure that those functions are returning
 // other Objects that we can resolve in our GraphQL graph.
 func (s *Schema) Build() (*graphql.Schema, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
 	sb := &schemaBuilder{
 		types:        make(map[reflect.Type]graphql.Type),
 		typeNames:    make(map[string]reflect.Type),
 		objects:      make(map[reflect.Type]*Object),
 		enumMappings: s.enumTypes,
 		typeCache:    make(map[reflect.Type]cachedType, 0),
+		maxPageSize:  s.maxPageSize,
+
+		taggedObjects:  make(map[reflect.Type]bool),
+		paginatedNodes: make(map[reflect.Type]bool),
 	}
 
 	s.Object("Query", query{})
@@ -241,6 +329,9 @@ func (s *Schema) Build() (*graphql.Schema, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := sb.checkTaggedObjects(); err != nil {
+		return nil, err
+	}
 	return &graphql.Schema{
 		Query:    queryTyp,
 		Mutation: mutationTyp,
@@ -4,6 +4,9 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"time"
+
+	"github.com/denkhaus/thunder/graphql"
 )
 
 // A Object represents a Go type and set of methods to be converted into an
@@ -13,7 +16,7 @@ type Object struct {
 	Description string
 	Type        interface{}
 	Methods     Methods // Deprecated, use FieldFunc instead.
-	key         string
+	keys        []string
 	ServiceName string
 	IsRoot      bool
 	IsShadow    bool
@@ -43,16 +46,123 @@ var NonNullable fieldFuncOptionFunc = func(m *method) {
 
 // Paginated is an option that can be passed to a FieldFunc to indicate that
 // its return value should be paginated.
+//
+// Fields of the node struct tagged `graphql:",filterable"` or
+// `graphql:",sortable"` can be used in the filterText and sortBy arguments,
+// which are applied before the result is sliced. Resolvers that embed
+// PaginationArgs manage the result themselves, and can push the arguments down
+// to the database with sqlgen's SelectOptions.IncludeTextFilter and
+// SelectOptions.IncludeOrderBy instead.
 var Paginated fieldFuncOptionFunc = func(m *method) {
 	m.Paginated = true
 }
 
+// Deprecated is an option that can be passed to a FieldFunc to mark the field
+// as deprecated. The reason is exposed through introspection, and queries
+// using the field get a deprecation warning, see
+// graphql.DeprecationMiddleware.
+func Deprecated(reason string) FieldFuncOption {
+	return fieldFuncOptionFunc(func(m *method) {
+		m.DeprecationReason = reason
+	})
+}
+
+// Description is an option that can be passed to a FieldFunc to document the
+// field. Struct fields are documented with a description tag instead, eg.
+// `description:"The user's name."`.
+func Description(description string) FieldFuncOption {
+	return fieldFuncOptionFunc(func(m *method) {
+		m.Description = description
+	})
+}
+
+// Sensitive is an option that can be passed to a FieldFunc to mark arguments,
+// such as passwords or tokens, as sensitive. Their values are redacted in the
+// queries logged by the server, see graphql.RedactQuery. Hooks and
+// middlewares still receive the values, and must redact queries they log.
+func Sensitive(args ...string) FieldFuncOption {
+	return fieldFuncOptionFunc(func(m *method) {
+		m.SensitiveArgs = append(m.SensitiveArgs, args...)
+	})
+}
+
+// Cached is an option that can be passed to a FieldFunc to cache its results
+// across requests for ttl, if the executor has a graphql.FieldCache. Results
+// are cached as JSON by arguments and parent key, see graphql.WithFieldCache,
+// and only FieldFuncs on Query or on objects with a key can be cached.
+func Cached(ttl time.Duration) FieldFuncOption {
+	return fieldFuncOptionFunc(func(m *method) {
+		m.CacheTTL = ttl
+	})
+}
+
+// MaxPageSize is an option that can be passed to a paginated FieldFunc to
+// limit the first and last arguments to max, overriding the schema's default,
+// see Schema.SetMaxPageSize. Queries requesting larger pages fail validation.
+func MaxPageSize(max int64) FieldFuncOption {
+	return fieldFuncOptionFunc(func(m *method) {
+		m.MaxPageSize = max
+	})
+}
+
+// FeatureFlag is an option that can be passed to a FieldFunc to only expose
+// the field to clients for which flag returns true, for example internal
+// callers. Hidden fields are left out of introspection and cannot be queried,
+// see graphql.IsFieldVisible.
+func FeatureFlag(flag func(ctx context.Context) bool) FieldFuncOption {
+	return fieldFuncOptionFunc(func(m *method) {
+		m.FeatureFlags = append(m.FeatureFlags, flag)
+	})
+}
+
+// RequireFeatureFlag is an option that can be passed to a FieldFunc to only
+// expose the field to requests for which the boolean flag name is enabled,
+// see graphql.WithFeatureFlags. The field is hidden from requests without
+// feature flags.
+func RequireFeatureFlag(name string) FieldFuncOption {
+	return FeatureFlag(func(ctx context.Context) bool {
+		return graphql.BoolFlag(ctx, name, false)
+	})
+}
+
+// MinSchemaVersion is an option that can be passed to a FieldFunc to only
+// expose the field to clients of at least schema version version, see
+// graphql.WithSchemaVersion. Clients without a schema version are treated as
+// version 0.
+func MinSchemaVersion(version int) FieldFuncOption {
+	return fieldFuncOptionFunc(func(m *method) {
+		m.MinSchemaVersion = version
+	})
+}
+
 // Expensive is an option that can be passed to a FieldFunc to indicate that
 // the function is expensive to execute, so it should be parallelized.
 var Expensive fieldFuncOptionFunc = func(m *method) {
 	m.Expensive = true
 }
 
+// Pure is an option that can be passed to a FieldFunc to indicate that the
+// function only depends on its source and arguments. Pure functions cannot take
+// a context or a selection set, and their results are memoized across
+// identical calls within a query.
+var Pure fieldFuncOptionFunc = func(m *method) {
+	m.Pure = true
+}
+
+// Serial is an option that can be passed to a FieldFunc to indicate that the
+// function must not run concurrently with other Serial functions in the same
+// query, for example because it mutates request-scoped state.
+var Serial fieldFuncOptionFunc = func(m *method) {
+	m.ConcurrencyArgs.serial = true
+}
+
+// Pooled is an option that can be passed to a FieldFunc to indicate that the
+// function is expensive to execute, so it should be parallelized on a bounded
+// pool shared by all Pooled functions in the same query.
+var Pooled fieldFuncOptionFunc = func(m *method) {
+	m.ConcurrencyArgs.pooled = true
+}
+
 func FilterField(name string, filter interface{}, options ...FieldFuncOption) FieldFuncOption {
 	textFilterMethod := &method{Fn: filter, Batch: false, MarkedNonNullable: true}
 	for _, opt := range options {
@@ -296,16 +406,23 @@ func (s *Schema) FederatedFieldFunc(name string, f interface{}, options ...Field
 	obj.Methods[federatedMethodName] = m
 }
 
-// Key registers the key field on an object. The field should be specified by the name of the
-// graphql field.
+// Key declares the key fields of an object, which identify objects of its
+// type. The fields should be specified by the name of the graphql field.
 // For example, for an object User:
 //   type struct User {
 //	   UserKey int64
 //   }
 // The key will be registered as:
 // object.Key("userKey")
-func (s *Object) Key(f string) {
-	s.key = f
+//
+// Keys identify objects across services in federation, identify nodes in
+// paginated connections, and are returned as the __key field used by diff to
+// match objects in lists. Objects can have a compound key made of several
+// fields, such as object.Key("orgId", "userId"), whose __key is a JSON array
+// of the field values. Paginated connections require a single key field. The
+// key fields are listed by the keyFields introspection extension of __Type.
+func (s *Object) Key(fields ...string) {
+	s.keys = fields
 }
 
 type method struct {
@@ -318,6 +435,9 @@ type method struct {
 	// Whether or not the FieldFunc has been marked as expensive.
 	Expensive bool
 
+	// Whether or not the FieldFunc has been marked as pure.
+	Pure bool
+
 	// Text filter methods
 	TextFilterMethods map[string]*method
 
@@ -341,10 +461,39 @@ type method struct {
 	// is a shadow object. A shadow object's fields are each of the
 	// field that are sent as args to a federated sunquery.
 	ShadowObjectType reflect.Type
+
+	// DeprecationReason is set if the FieldFunc is deprecated.
+	DeprecationReason string
+
+	// Description documents the FieldFunc.
+	Description string
+
+	// SensitiveArgs are the arguments of the FieldFunc redacted in logs.
+	SensitiveArgs []string
+
+	// CacheTTL is how long results of the FieldFunc may be cached.
+	CacheTTL time.Duration
+
+	// MaxPageSize is the largest page a paginated FieldFunc returns, or 0 to
+	// use the schema's default.
+	MaxPageSize int64
+
+	// FeatureFlags must all return true for the FieldFunc to be visible.
+	FeatureFlags []func(ctx context.Context) bool
+
+	// MinSchemaVersion is the lowest client schema version the FieldFunc is
+	// visible to.
+	MinSchemaVersion int
+
+	// PayloadResultField is set if the FieldFunc returns a generated mutation
+	// payload, and names the payload field holding the function's result.
+	PayloadResultField string
 }
 
 type concurrencyArgs struct {
 	numParallelInvocationsFunc NumParallelInvocationsFunc
+	serial                     bool
+	pooled                     bool
 }
 
 // NumParallelInvocationsFunc is a configuration option for non-expensive and batch
@@ -0,0 +1,184 @@
+package graphql
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PrintSchema prints schema in the GraphQL schema definition language, with
+// types, fields, arguments, and enum values sorted by name. Introspection
+// types and fields are omitted, as are root types without fields, which are
+// not valid SDL.
+func PrintSchema(schema *Schema) string {
+	query, mutation := printedRoot(schema.Query), printedRoot(schema.Mutation)
+	types := make(map[string]Type)
+	collectNamedTypes(query, types)
+	collectNamedTypes(mutation, types)
+
+	names := make([]string, 0, len(types))
+	for name := range types {
+		if !strings.HasPrefix(name, "__") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	buf.WriteString("schema {\n")
+	if query != nil {
+		fmt.Fprintf(&buf, "  query: %s\n", query)
+	}
+	if mutation != nil {
+		fmt.Fprintf(&buf, "  mutation: %s\n", mutation)
+	}
+	buf.WriteString("}\n")
+
+	for _, name := range names {
+		buf.WriteString("\n")
+		switch typ := types[name].(type) {
+		case *Scalar:
+			fmt.Fprintf(&buf, "scalar %s\n", typ.Type)
+		case *Enum:
+			printEnum(&buf, typ)
+		case *InputObject:
+			fmt.Fprintf(&buf, "input %s {\n", typ.Name)
+			for _, field := range sortedKeys(typ.InputFields) {
+				fmt.Fprintf(&buf, "  %s: %s\n", field, typ.InputFields[field])
+			}
+			buf.WriteString("}\n")
+		case *Union:
+			printDescription(&buf, "", typ.Description)
+			members := make([]string, 0, len(typ.Types))
+			for member := range typ.Types {
+				members = append(members, member)
+			}
+			sort.Strings(members)
+			fmt.Fprintf(&buf, "union %s = %s\n", typ.Name, strings.Join(members, " | "))
+		case *Object:
+			printObject(&buf, typ)
+		}
+	}
+	return buf.String()
+}
+
+// printedRoot returns the root type typ, or nil if it has no fields besides
+// introspection fields.
+func printedRoot(typ Type) Type {
+	if object, ok := typ.(*Object); ok && len(sortedFieldNames(object)) == 0 {
+		return nil
+	}
+	return typ
+}
+
+func printObject(buf *bytes.Buffer, object *Object) {
+	printDescription(buf, "", object.Description)
+	fmt.Fprintf(buf, "type %s {\n", object.Name)
+	for _, name := range sortedFieldNames(object) {
+		field := object.Fields[name]
+		printDescription(buf, "  ", field.Description)
+		buf.WriteString("  " + name)
+		if len(field.Args) > 0 {
+			args := make([]string, 0, len(field.Args))
+			for _, arg := range sortedKeys(field.Args) {
+				args = append(args, fmt.Sprintf("%s: %s", arg, field.Args[arg]))
+			}
+			fmt.Fprintf(buf, "(%s)", strings.Join(args, ", "))
+		}
+		fmt.Fprintf(buf, ": %s", field.Type)
+		printDeprecated(buf, field.DeprecationReason)
+		buf.WriteString("\n")
+	}
+	buf.WriteString("}\n")
+}
+
+func printEnum(buf *bytes.Buffer, enum *Enum) {
+	values := append([]string(nil), enum.Values...)
+	sort.Strings(values)
+	fmt.Fprintf(buf, "enum %s {\n", enum.Type)
+	for _, value := range values {
+		buf.WriteString("  " + value)
+		printDeprecated(buf, enum.Deprecations[value])
+		buf.WriteString("\n")
+	}
+	buf.WriteString("}\n")
+}
+
+func printDescription(buf *bytes.Buffer, indent string, description string) {
+	if description != "" {
+		buf.WriteString(indent + strconv.Quote(description) + "\n")
+	}
+}
+
+func printDeprecated(buf *bytes.Buffer, reason string) {
+	if reason != "" {
+		fmt.Fprintf(buf, " @deprecated(reason: %s)", strconv.Quote(reason))
+	}
+}
+
+func sortedKeys(m map[string]Type) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// SchemaHash returns a stable hash of schema, which changes whenever its
+// printed schema does, see PrintSchema. Clients and caches can compare it to
+// cheaply detect schema changes.
+func SchemaHash(schema *Schema) string {
+	sum := sha256.Sum256([]byte(PrintSchema(schema)))
+	return hex.EncodeToString(sum[:])
+}
+
+// SchemaHashHeader is the response header holding the schema hash, see
+// WithSchemaHash.
+const SchemaHashHeader = "X-GraphQL-Schema-Hash"
+
+// NewSchemaHandler creates a handler serving schema in the GraphQL schema
+// definition language, see PrintSchema. The hash of the schema is its ETag,
+// so requests with a matching If-None-Match header are answered with a 304
+// status and no body.
+func NewSchemaHandler(schema *Schema) http.Handler {
+	sdl := PrintSchema(schema)
+	hash := SchemaHash(schema)
+	etag := strconv.Quote(hash)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" && r.Method != "HEAD" {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "request must be a GET", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("ETag", etag)
+		w.Header().Set(SchemaHashHeader, hash)
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if r.Method == "GET" {
+			w.Write([]byte(sdl))
+		}
+	})
+}
+
+// etagMatches returns whether the If-None-Match header ifNoneMatch matches
+// etag, comparing weakly as required for If-None-Match.
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package graphql_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+)
+
+type sdlColor int64
+
+type sdlItem struct {
+	Name  string
+	Color sdlColor
+}
+
+type sdlFilter struct {
+	Name  *string
+	Limit int64
+}
+
+func makeSDLSchema(extraField bool) *graphql.Schema {
+	schema := schemabuilder.NewSchema()
+	schema.Enum(sdlColor(0), map[string]sdlColor{"red": 0, "blue": 1})
+	schema.Object("Item", sdlItem{})
+	schema.Query().FieldFunc("items", func(args struct{ Filter *sdlFilter }) []*sdlItem {
+		return nil
+	}, schemabuilder.Description("Lists items."))
+	schema.Query().FieldFunc("count", func() int64 {
+		return 0
+	}, schemabuilder.Deprecated("use items"))
+	if extraField {
+		schema.Query().FieldFunc("extra", func() string {
+			return ""
+		})
+	}
+	schema.Mutation()
+	return schema.MustBuild()
+}
+
+func TestPrintSchema(t *testing.T) {
+	assert.Equal(t, `schema {
+  query: Query
+}
+
+type Item {
+  color: sdlColor!
+  name: string!
+}
+
+type Query {
+  count: int64! @deprecated(reason: "use items")
+  "Lists items."
+  items(filter: sdlFilter_InputObject): [Item!]!
+}
+
+scalar int64
+
+enum sdlColor {
+  blue
+  red
+}
+
+input sdlFilter_InputObject {
+  limit: int64!
+  name: string
+}
+
+scalar string
+`, graphql.PrintSchema(makeSDLSchema(false)))
+}
+
+func TestPrintSchemaMutation(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	schema.Query()
+	schema.Mutation().FieldFunc("reset", func() bool {
+		return true
+	})
+	assert.Equal(t, `schema {
+  mutation: Mutation
+}
+
+type Mutation {
+  reset: bool!
+}
+
+scalar bool
+`, graphql.PrintSchema(schema.MustBuild()))
+}
+
+func TestSchemaHash(t *testing.T) {
+	hash := graphql.SchemaHash(makeSDLSchema(false))
+	assert.Len(t, hash, 64)
+	assert.Equal(t, hash, graphql.SchemaHash(makeSDLSchema(false)))
+	assert.NotEqual(t, hash, graphql.SchemaHash(makeSDLSchema(true)))
+}
+
+func TestSchemaHandler(t *testing.T) {
+	schema := makeSDLSchema(false)
+	hash := graphql.SchemaHash(schema)
+	handler := graphql.NewSchemaHandler(schema)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/schema.graphql", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, graphql.PrintSchema(schema), rr.Body.String())
+	assert.Equal(t, `"`+hash+`"`, rr.Header().Get("ETag"))
+	assert.Equal(t, hash, rr.Header().Get(graphql.SchemaHashHeader))
+
+	// Clients with the current schema get a 304.
+	req := httptest.NewRequest("GET", "/schema.graphql", nil)
+	req.Header.Set("If-None-Match", `"other", W/"`+hash+`"`)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+	assert.Empty(t, rr.Body.String())
+
+	req = httptest.NewRequest("GET", "/schema.graphql", nil)
+	req.Header.Set("If-None-Match", `"other"`)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/schema.graphql", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+}
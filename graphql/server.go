@@ -19,6 +19,9 @@ import (
 const (
 	DefaultMaxSubscriptions = 200
 	DefaultMinRerunInterval = 5 * time.Second
+	// DefaultMaxMessageSize is the default limit of the size of messages read
+	// from websockets, see WithMaxMessageSize.
+	DefaultMaxMessageSize = 1 << 20
 )
 
 type JSONSocket interface {
@@ -49,6 +52,39 @@ type SubscriptionLogger interface {
 	Unsubscribe(ctx context.Context, id string)
 }
 
+// SubscriptionInfo describes a subscription to SubscriptionHooks.
+type SubscriptionInfo struct {
+	// ID is the client-chosen id of the subscription.
+	ID string
+	// Query is the parsed query. Its Kind and Name identify the operation.
+	Query *Query
+	// QueryText and Variables are the query and variables as sent by the
+	// client. They include the values of sensitive arguments, see
+	// RedactQuery.
+	QueryText string
+	Variables map[string]interface{}
+	// Extensions holds the extensions of the subscribe message.
+	Extensions map[string]interface{}
+	// URL is the last URL reported by the client.
+	URL   string
+	Start time.Time
+}
+
+// SubscriptionHooks are called when subscriptions start and end, so
+// applications can audit, bill, or limit subscriptions. The hooks are called
+// with the connection's context, which carries the connection's
+// authentication. They hold the connection's lock, so they should not block.
+type SubscriptionHooks struct {
+	// BeforeSubscribe is called after a subscription's query has been parsed
+	// and validated, before it first runs. An error rejects the subscription
+	// and is sent to the client. It is called again when the client updates
+	// the subscription's variables, and an error then rejects the update.
+	BeforeSubscribe func(ctx context.Context, info *SubscriptionInfo) error
+	// BeforeUnsubscribe is called before a subscription accepted by
+	// BeforeSubscribe stops, including when the connection closes.
+	BeforeUnsubscribe func(ctx context.Context, info *SubscriptionInfo)
+}
+
 type conn struct {
 	writeMu sync.Mutex
 	socket  JSONSocket
@@ -59,21 +95,44 @@ type conn struct {
 	makeCtx        MakeCtxFunc
 	middlewares    []MiddlewareFunc
 
-	executor ExecutorRunner
+	executor   ExecutorRunner
+	queryCache *QueryCache
 
 	logger             GraphqlLogger
 	subscriptionLogger SubscriptionLogger
 
-	url string
+	url  string
+	info ConnectionInfo
 
 	mutateMu sync.Mutex
 
 	mu            sync.Mutex
 	subscriptions map[string]*reactive.Rerunner
+	// subscriptionInfos holds the subscriptions accepted by hooks.
+	subscriptionInfos map[string]*SubscriptionInfo
+	hooks             SubscriptionHooks
+	// deliveries tracks the updates of reliable subscriptions.
+	deliveries map[string]*delivery
+	// operations holds the queries and variables of subscriptions, see
+	// subscriptionOperation.
+	operations map[string]*subscriptionOperation
+	// shuttingDown is set by Shutdown to reject new operations.
+	shuttingDown bool
+	connections  *Connections
+
+	// authenticate verifies "connection_update" messages, see
+	// WithAuthenticate.
+	authenticate       AuthenticateFunc
+	credentialsMu      sync.Mutex
+	credentials        *Credentials
+	credentialsExpired bool
+	credentialsTimer   *time.Timer
 
 	alwaysSpawnGoroutineFunc AlwaysSpawnGoroutineFunc
 	minRerunIntervalFunc     RerunIntervalFunc
 	maxSubscriptions         int
+	maxMessageSize           int64
+	featureFlags             FeatureFlags
 }
 
 type inEnvelope struct {
@@ -84,15 +143,24 @@ type inEnvelope struct {
 }
 
 type outEnvelope struct {
-	ID       string                 `json:"id,omitempty"`
-	Type     string                 `json:"type"`
-	Message  interface{}            `json:"message,omitempty"`
-	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	ID         string                 `json:"id,omitempty"`
+	Type       string                 `json:"type"`
+	Seq        uint64                 `json:"seq,omitempty"`
+	Message    interface{}            `json:"message,omitempty"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
 }
 
 type subscribeMessage struct {
 	Query     string                 `json:"query"`
 	Variables map[string]interface{} `json:"variables"`
+	// SkipInitial suppresses the initial result, for clients that already
+	// fetched it with a query and only want subsequent changes.
+	SkipInitial bool `json:"skipInitial"`
+	// Reliable numbers the updates of the subscription with increasing
+	// sequence numbers starting at 1, which the client acknowledges with
+	// "ack" messages. Clients detect dropped updates by gaps in the sequence.
+	Reliable bool `json:"reliable"`
 }
 
 type mutateMessage struct {
@@ -120,6 +188,38 @@ func mustMarshalJson(v interface{}) string {
 	return string(bytes)
 }
 
+// queryTags returns the logging tags of a query, with the values of sensitive
+// arguments redacted.
+func (c *conn) queryTags(id string, schema *Schema, query string, variables map[string]interface{}) map[string]string {
+	query, variables = RedactQuery(schema, query, variables)
+	return map[string]string{"url": c.url, "query": query, "queryVariables": mustMarshalJson(variables), "id": id}
+}
+
+// featureFlagValues returns the values of the connection's flags for a new
+// operation, or nil if the connection has no flags.
+func (c *conn) featureFlagValues() FeatureFlags {
+	if c.featureFlags == nil {
+		return nil
+	}
+	return c.featureFlags.Values(c.ctx)
+}
+
+// withFeatureFlags attaches flags to ctx, unless they are nil.
+func withFeatureFlags(ctx context.Context, flags FeatureFlags) context.Context {
+	if flags == nil {
+		return ctx
+	}
+	return WithFeatureFlags(ctx, flags)
+}
+
+// validationCtx returns the context operations with flags are validated
+// with. It carries the same values as the contexts the operations run with,
+// so fields hidden by visibility checks are hidden both from introspection
+// and from validation.
+func (c *conn) validationCtx(flags FeatureFlags) context.Context {
+	return c.withCredentials(withFeatureFlags(c.makeCtx(c.ctx), flags))
+}
+
 func (c *conn) handleSubscribe(in *inEnvelope) error {
 	id := in.ID
 	var subscribe subscribeMessage
@@ -130,6 +230,12 @@ func (c *conn) handleSubscribe(in *inEnvelope) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	// Check for a shutdown while holding c.mu, so that Shutdown stops every
+	// subscription it did not reject.
+	if c.shuttingDown {
+		return NewSafeError(ShutdownReason)
+	}
+
 	if _, ok := c.subscriptions[id]; ok {
 		return NewSafeError("duplicate subscription")
 	}
@@ -138,9 +244,9 @@ func (c *conn) handleSubscribe(in *inEnvelope) error {
 		return NewSafeError("too many subscriptions")
 	}
 
-	tags := map[string]string{"url": c.url, "query": subscribe.Query, "queryVariables": mustMarshalJson(subscribe.Variables), "id": id}
+	tags := c.queryTags(id, c.schema, subscribe.Query, subscribe.Variables)
 
-	query, err := Parse(subscribe.Query, subscribe.Variables)
+	query, err := parseQuery(c.queryCache, subscribe.Query, subscribe.Variables)
 	if query != nil {
 		tags["queryType"] = query.Kind
 		tags["queryName"] = query.Name
@@ -149,11 +255,38 @@ func (c *conn) handleSubscribe(in *inEnvelope) error {
 		c.logger.Error(c.ctx, err, tags)
 		return err
 	}
-	if err := PrepareQuery(context.Background(), c.schema.Query, query.SelectionSet); err != nil {
+	// Every run of the subscription uses the flags it was validated with.
+	flags := c.featureFlagValues()
+	if err := PrepareQuery(c.validationCtx(flags), c.schema.Query, query.SelectionSet); err != nil {
 		c.logger.Error(c.ctx, err, tags)
 		return err
 	}
 
+	info := &SubscriptionInfo{
+		ID:         id,
+		Query:      query,
+		QueryText:  subscribe.Query,
+		Variables:  subscribe.Variables,
+		Extensions: in.Extensions,
+		URL:        c.url,
+		Start:      time.Now(),
+	}
+	if c.hooks.BeforeSubscribe != nil {
+		if err := c.hooks.BeforeSubscribe(c.ctx, info); err != nil {
+			return err
+		}
+	}
+	c.subscriptionInfos[id] = info
+
+	var delivered *delivery
+	if subscribe.Reliable {
+		delivered = &delivery{}
+		c.deliveries[id] = delivered
+	}
+
+	op := newSubscriptionOperation(subscribe.Query, query, subscribe.Variables, tags, flags)
+	c.operations[id] = op
+
 	var previous interface{}
 
 	e := c.executor
@@ -161,7 +294,11 @@ func (c *conn) handleSubscribe(in *inEnvelope) error {
 	initial := true
 	c.subscriptionLogger.Subscribe(c.ctx, id, tags)
 	c.subscriptions[id] = reactive.NewRerunner(c.ctx, func(ctx context.Context) (interface{}, error) {
+		query, variables, tags := op.current(ctx)
+
 		ctx = c.makeCtx(ctx)
+		ctx = withFeatureFlags(ctx, op.featureFlags)
+		ctx = c.withCredentials(ctx)
 		ctx = batch.WithBatching(ctx)
 
 		start := time.Now()
@@ -172,6 +309,10 @@ func (c *conn) handleSubscribe(in *inEnvelope) error {
 		middlewares = append(middlewares, c.middlewares...)
 		middlewares = append(middlewares, func(input *ComputationInput, next MiddlewareNextFunc) *ComputationOutput {
 			output := next(input)
+			if input.EmptyOperation {
+				output.Current = map[string]interface{}{}
+				return output
+			}
 			output.Current, output.Error = e.Execute(input.Ctx, c.schema.Query, nil, input.ParsedQuery)
 			return output
 		})
@@ -183,8 +324,9 @@ func (c *conn) handleSubscribe(in *inEnvelope) error {
 			Previous:             previous,
 			IsInitialComputation: initial,
 			Query:                subscribe.Query,
-			Variables:            subscribe.Variables,
+			Variables:            variables,
 			Extensions:           in.Extensions,
+			EmptyOperation:       isEmptyOperation(query),
 		}
 
 		output := RunMiddlewares(middlewares, computationInput)
@@ -215,10 +357,11 @@ func (c *conn) handleSubscribe(in *inEnvelope) error {
 			}
 
 			c.writeOrClose(outEnvelope{
-				ID:       id,
-				Type:     "error",
-				Message:  SanitizeError(err),
-				Metadata: output.Metadata,
+				ID:         id,
+				Type:       "error",
+				Message:    SanitizeError(err),
+				Metadata:   output.Metadata,
+				Extensions: output.Extensions,
 			})
 			go c.closeSubscription(id)
 
@@ -230,21 +373,30 @@ func (c *conn) handleSubscribe(in *inEnvelope) error {
 
 		d := diff.Diff(computationInput.Previous, current)
 		previous = current
+		if initial && subscribe.SkipInitial {
+			// Only acknowledge the subscription with an empty diff below, and
+			// diff later results against the skipped one.
+			d = nil
+		}
 
 		if d != nil {
 			c.writeOrClose(outEnvelope{
-				ID:       id,
-				Type:     "update",
-				Message:  d,
-				Metadata: output.Metadata,
+				ID:         id,
+				Type:       "update",
+				Seq:        delivered.next(),
+				Message:    d,
+				Metadata:   output.Metadata,
+				Extensions: output.Extensions,
 			})
 		} else if initial {
 			// When a client first subscribes, they expect a response with the new diff (even if the diff is unchanged).
 			c.writeOrClose(outEnvelope{
-				ID:       id,
-				Type:     "update",
-				Message:  struct{}{}, // This is an empty diff for any message, rather than nil which means the new message is empty.
-				Metadata: output.Metadata,
+				ID:         id,
+				Type:       "update",
+				Seq:        delivered.next(),
+				Message:    struct{}{}, // This is an empty diff for any message, rather than nil which means the new message is empty.
+				Metadata:   output.Metadata,
+				Extensions: output.Extensions,
 			})
 		}
 
@@ -266,9 +418,15 @@ func (c *conn) handleMutate(in *inEnvelope) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	tags := map[string]string{"url": c.url, "query": mutate.Query, "queryVariables": mustMarshalJson(mutate.Variables), "id": id}
+	// Check for a shutdown while holding c.mu, so that Shutdown waits for
+	// every mutation it did not reject.
+	if c.shuttingDown {
+		return NewSafeError(ShutdownReason)
+	}
+
+	tags := c.queryTags(id, c.mutationSchema, mutate.Query, mutate.Variables)
 
-	query, err := Parse(mutate.Query, mutate.Variables)
+	query, err := parseQuery(c.queryCache, mutate.Query, mutate.Variables)
 	if query != nil {
 		tags["queryType"] = query.Kind
 		tags["queryName"] = query.Name
@@ -277,7 +435,8 @@ func (c *conn) handleMutate(in *inEnvelope) error {
 		c.logger.Error(c.ctx, err, tags)
 		return err
 	}
-	if err := PrepareQuery(c.ctx, c.mutationSchema.Mutation, query.SelectionSet); err != nil {
+	flags := c.featureFlagValues()
+	if err := PrepareQuery(c.validationCtx(flags), c.mutationSchema.Mutation, query.SelectionSet); err != nil {
 		c.logger.Error(c.ctx, err, tags)
 		return err
 	}
@@ -290,6 +449,8 @@ func (c *conn) handleMutate(in *inEnvelope) error {
 		defer c.mutateMu.Unlock()
 
 		ctx = c.makeCtx(ctx)
+		ctx = withFeatureFlags(ctx, flags)
+		ctx = c.withCredentials(ctx)
 		ctx = batch.WithBatching(ctx)
 
 		start := time.Now()
@@ -299,6 +460,10 @@ func (c *conn) handleMutate(in *inEnvelope) error {
 		middlewares = append(middlewares, c.middlewares...)
 		middlewares = append(middlewares, func(input *ComputationInput, next MiddlewareNextFunc) *ComputationOutput {
 			output := next(input)
+			if input.EmptyOperation {
+				output.Current = map[string]interface{}{}
+				return output
+			}
 			output.Current, output.Error = e.Execute(input.Ctx, c.mutationSchema.Mutation, c.mutationSchema.Mutation, query)
 			return output
 		})
@@ -312,6 +477,7 @@ func (c *conn) handleMutate(in *inEnvelope) error {
 			Query:                mutate.Query,
 			Variables:            mutate.Variables,
 			Extensions:           in.Extensions,
+			EmptyOperation:       isEmptyOperation(query),
 		}
 
 		output := RunMiddlewares(middlewares, computationInput)
@@ -321,10 +487,11 @@ func (c *conn) handleMutate(in *inEnvelope) error {
 
 		if err != nil {
 			c.writeOrClose(outEnvelope{
-				ID:       id,
-				Type:     "error",
-				Message:  SanitizeError(err),
-				Metadata: output.Metadata,
+				ID:         id,
+				Type:       "error",
+				Message:    SanitizeError(err),
+				Metadata:   output.Metadata,
+				Extensions: output.Extensions,
 			})
 
 			go c.closeSubscription(id)
@@ -340,10 +507,11 @@ func (c *conn) handleMutate(in *inEnvelope) error {
 		}
 
 		c.writeOrClose(outEnvelope{
-			ID:       id,
-			Type:     "result",
-			Message:  diff.Diff(nil, current),
-			Metadata: output.Metadata,
+			ID:         id,
+			Type:       "result",
+			Message:    diff.Diff(nil, current),
+			Metadata:   output.Metadata,
+			Extensions: output.Extensions,
 		})
 
 		go c.rerunSubscriptionsImmediately()
@@ -369,11 +537,177 @@ func (c *conn) closeSubscription(id string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if runner, ok := c.subscriptions[id]; ok {
-		runner.Stop()
-		delete(c.subscriptions, id)
-		c.subscriptionLogger.Unsubscribe(c.ctx, id)
+	c.stopSubscription(id)
+}
+
+// stopSubscription stops subscription id, if it exists. c.mu must be held.
+func (c *conn) stopSubscription(id string) bool {
+	runner, ok := c.subscriptions[id]
+	if !ok {
+		return false
+	}
+	c.beforeUnsubscribe(id)
+	runner.Stop()
+	delete(c.subscriptions, id)
+	delete(c.deliveries, id)
+	delete(c.operations, id)
+	c.subscriptionLogger.Unsubscribe(c.ctx, id)
+	return true
+}
+
+// delivery numbers the updates of a reliable subscription and tracks the
+// updates acknowledged by the client.
+type delivery struct {
+	mu    sync.Mutex
+	sent  uint64
+	acked uint64
+}
+
+// next returns the sequence number of the next update, or 0 if d is nil
+// because the subscription is not reliable.
+func (d *delivery) next() uint64 {
+	if d == nil {
+		return 0
 	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sent++
+	return d.sent
+}
+
+// ack records that the client received all updates up to seq.
+func (d *delivery) ack(seq uint64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if seq > d.sent {
+		return NewSafeError("ack of unsent update")
+	}
+	if seq > d.acked {
+		d.acked = seq
+	}
+	return nil
+}
+
+// unacked returns the number of updates the client has not acknowledged.
+func (d *delivery) unacked() uint64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.sent - d.acked
+}
+
+func (c *conn) handleAck(in *inEnvelope) error {
+	var seq uint64
+	if err := json.Unmarshal(in.Message, &seq); err != nil {
+		return oops.Wrapf(err, "failed to parse ack message: %s", in.Message)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	d, ok := c.deliveries[in.ID]
+	if !ok {
+		// The subscription may have stopped since the update was sent.
+		return nil
+	}
+	return d.ack(seq)
+}
+
+// UnackedUpdates returns the number of updates the client has not
+// acknowledged for each reliable subscription, eg. to export the backlog of
+// slow clients as a metric.
+func (c *conn) UnackedUpdates() map[string]uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	unacked := make(map[string]uint64, len(c.deliveries))
+	for id, d := range c.deliveries {
+		unacked[id] = d.unacked()
+	}
+	return unacked
+}
+
+// completeMessage is the message of a "complete" or "close" envelope sent when
+// the server terminates a subscription or connection.
+type completeMessage struct {
+	Reason string `json:"reason,omitempty"`
+	// Reconnect asks the client to reconnect, eg. because the server is
+	// shutting down.
+	Reconnect bool `json:"reconnect,omitempty"`
+}
+
+// terminateSubscriptions stops the subscriptions matching match, and sends
+// the client a "complete" message with reason for each of them. It returns
+// the number of terminated subscriptions.
+func (c *conn) terminateSubscriptions(reason string, match func(info *SubscriptionInfo) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	terminated := 0
+	for id, info := range c.subscriptionInfos {
+		if match(info) && c.stopSubscription(id) {
+			c.writeOrClose(outEnvelope{
+				ID:      id,
+				Type:    "complete",
+				Message: completeMessage{Reason: reason},
+			})
+			terminated++
+		}
+	}
+	return terminated
+}
+
+// terminate terminates all subscriptions, sends the client a "close" message
+// with reason, and closes the socket.
+func (c *conn) terminate(reason string) {
+	c.terminateSubscriptions(reason, func(*SubscriptionInfo) bool { return true })
+	c.writeOrClose(outEnvelope{
+		Type:    "close",
+		Message: completeMessage{Reason: reason},
+	})
+	c.socket.Close()
+}
+
+// ShutdownReason is the reason sent to clients by Shutdown.
+const ShutdownReason = "server shutting down"
+
+// Shutdown gracefully closes the connection, eg. before the server is
+// replaced by a new deploy. It stops accepting new operations and stops the
+// subscriptions, waits for running mutations to send their results, then
+// sends the client a "close" message asking it to reconnect and closes the
+// socket. If ctx is done first, the running mutations are canceled and
+// Shutdown returns ctx.Err().
+func (c *conn) Shutdown(ctx context.Context) error {
+	c.mu.Lock()
+	c.shuttingDown = true
+	var mutations []*reactive.Rerunner
+	for id, runner := range c.subscriptions {
+		if _, ok := c.subscriptionInfos[id]; ok {
+			// Subscriptions are stopped silently, as the client resubscribes
+			// when it reconnects.
+			c.stopSubscription(id)
+		} else {
+			mutations = append(mutations, runner)
+		}
+	}
+	c.mu.Unlock()
+
+	// Mutations stop by themselves once they sent their result.
+	var err error
+	for _, runner := range mutations {
+		if err = runner.Drain(ctx); err != nil {
+			break
+		}
+	}
+	if err != nil {
+		c.closeSubscriptions()
+	}
+
+	c.writeOrClose(outEnvelope{
+		Type:    "close",
+		Message: completeMessage{Reason: ShutdownReason, Reconnect: true},
+	})
+	c.socket.Close()
+	return err
 }
 
 func (c *conn) closeSubscriptions() {
@@ -381,23 +715,54 @@ func (c *conn) closeSubscriptions() {
 	defer c.mu.Unlock()
 
 	for id, runner := range c.subscriptions {
+		c.beforeUnsubscribe(id)
 		runner.Stop()
 		delete(c.subscriptions, id)
+		delete(c.deliveries, id)
+		delete(c.operations, id)
+	}
+}
+
+// beforeUnsubscribe calls the BeforeUnsubscribe hook for subscription id, if
+// it was accepted by the hooks. c.mu must be held.
+func (c *conn) beforeUnsubscribe(id string) {
+	info, ok := c.subscriptionInfos[id]
+	if !ok {
+		return
+	}
+	delete(c.subscriptionInfos, id)
+	if c.hooks.BeforeUnsubscribe != nil {
+		c.hooks.BeforeUnsubscribe(c.ctx, info)
 	}
 }
 
 func (c *conn) handle(e *inEnvelope) error {
 	switch e.Type {
 	case "subscribe":
+		if err := c.checkCredentials(); err != nil {
+			return err
+		}
 		return c.handleSubscribe(e)
 
 	case "unsubscribe":
 		c.closeSubscription(e.ID)
 		return nil
 
+	case "update_variables":
+		return c.handleUpdateVariables(e)
+
+	case "ack":
+		return c.handleAck(e)
+
 	case "mutate":
+		if err := c.checkCredentials(); err != nil {
+			return err
+		}
 		return c.handleMutate(e)
 
+	case "connection_update":
+		return c.handleConnectionUpdate(e)
+
 	case "echo":
 		c.writeOrClose(outEnvelope{
 			ID:       e.ID,
@@ -431,10 +796,14 @@ func (s *simpleLogger) Error(ctx context.Context, err error, tags map[string]str
 	log.Printf("error:%v\n%s", tags, err)
 }
 
-func Handler(schema *Schema) http.Handler {
+// Handler serves schema over websockets. Options configure each connection.
+// Clients requesting MessagePackSubprotocol exchange MessagePack encoded
+// binary frames instead of JSON.
+func Handler(schema *Schema, opts ...ConnectionOption) http.Handler {
 	upgrader := &websocket.Upgrader{
 		ReadBufferSize:  1024,
 		WriteBufferSize: 1024,
+		Subprotocols:    []string{MessagePackSubprotocol},
 		CheckOrigin: func(r *http.Request) bool {
 			return true
 		},
@@ -452,7 +821,13 @@ func Handler(schema *Schema) http.Handler {
 			return ctx
 		}
 
-		ServeJSONSocket(r.Context(), socket, schema, makeCtx, &simpleLogger{})
+		var jsonSocket JSONSocket = socket
+		if socket.Subprotocol() == MessagePackSubprotocol {
+			jsonSocket = NewMessagePackSocket(socket)
+		}
+
+		connOpts := append([]ConnectionOption{WithMakeCtx(makeCtx), WithExecutionLogger(&simpleLogger{})}, opts...)
+		CreateConnection(r.Context(), jsonSocket, schema, connOpts...).ServeJSONSocket()
 	})
 }
 
@@ -499,12 +874,16 @@ func CreateConnection(ctx context.Context, socket JSONSocket, schema *Schema, op
 		mutationSchema:     schema,
 		executor:           NewExecutor(NewImmediateGoroutineScheduler()),
 		subscriptions:      make(map[string]*reactive.Rerunner),
+		subscriptionInfos:  make(map[string]*SubscriptionInfo),
+		deliveries:         make(map[string]*delivery),
+		operations:         make(map[string]*subscriptionOperation),
 		subscriptionLogger: &nopSubscriptionLogger{},
 		logger:             &nopGraphqlLogger{},
 		makeCtx: func(ctx context.Context) context.Context {
 			return ctx
 		},
 		maxSubscriptions:         DefaultMaxSubscriptions,
+		maxMessageSize:           DefaultMaxMessageSize,
 		minRerunIntervalFunc:     func(context.Context, *Query) time.Duration { return DefaultMinRerunInterval },
 		alwaysSpawnGoroutineFunc: func(context.Context, *Query) bool { return false },
 	}
@@ -512,6 +891,15 @@ func CreateConnection(ctx context.Context, socket JSONSocket, schema *Schema, op
 		opt(c)
 	}
 
+	c.info = ConnectionInfo{
+		ID:          newConnectionID(),
+		ConnectedAt: time.Now(),
+	}
+	if socket, ok := socket.(subprotocolSocket); ok {
+		c.info.Protocol = socket.Subprotocol()
+	}
+	c.ctx = context.WithValue(c.ctx, connectionInfoKey{}, c)
+
 	return c
 }
 
@@ -521,6 +909,15 @@ func WithExecutor(executor ExecutorRunner) ConnectionOption {
 	}
 }
 
+// WithQueryCache parses subscriptions and mutations with cache, so repeated
+// operations skip parsing, see QueryCache. The cache can be shared by all
+// connections.
+func WithQueryCache(cache *QueryCache) ConnectionOption {
+	return func(c *conn) {
+		c.queryCache = cache
+	}
+}
+
 func WithExecutionLogger(logger GraphqlLogger) ConnectionOption {
 	return func(c *conn) {
 		c.logger = logger
@@ -545,6 +942,16 @@ func WithMaxSubscriptions(max int) ConnectionOption {
 	}
 }
 
+// WithMaxMessageSize closes connections that send messages larger than size
+// bytes. It applies to sockets that support read limits, such as
+// *websocket.Conn and the sockets of NewMessagePackSocket. A size of 0
+// disables the limit.
+func WithMaxMessageSize(size int64) ConnectionOption {
+	return func(c *conn) {
+		c.maxMessageSize = size
+	}
+}
+
 func WithMutationSchema(schema *Schema) ConnectionOption {
 	return func(c *conn) {
 		c.mutationSchema = schema
@@ -563,6 +970,30 @@ func WithSubscriptionLogger(logger SubscriptionLogger) ConnectionOption {
 	}
 }
 
+// WithSubscriptionHooks sets hooks called when subscriptions start and end.
+func WithSubscriptionHooks(hooks SubscriptionHooks) ConnectionOption {
+	return func(c *conn) {
+		c.hooks = hooks
+	}
+}
+
+// WithConnections tracks the connection in connections while it is served,
+// so it is shut down by Connections.Shutdown.
+func WithConnections(connections *Connections) ConnectionOption {
+	return func(c *conn) {
+		c.connections = connections
+	}
+}
+
+// WithConnectionFeatureFlags attaches the values of flags to subscriptions
+// and mutations, see WithFeatureFlags. A subscription reads the values once,
+// when it starts, and keeps them for all of its runs.
+func WithConnectionFeatureFlags(flags FeatureFlags) ConnectionOption {
+	return func(c *conn) {
+		c.featureFlags = flags
+	}
+}
+
 // WithMinRerunIntervalFunc is deprecated.
 func WithMinRerunIntervalFunc(fn RerunIntervalFunc) ConnectionOption {
 	return func(c *conn) {
@@ -571,8 +1002,28 @@ func WithMinRerunIntervalFunc(fn RerunIntervalFunc) ConnectionOption {
 }
 
 func (c *conn) ServeJSONSocket() {
+	if c.connections != nil {
+		if !c.connections.add(c) {
+			// The server is shutting down; ask the client to reconnect to
+			// another server.
+			c.writeOrClose(outEnvelope{
+				Type:    "close",
+				Message: completeMessage{Reason: ShutdownReason, Reconnect: true},
+			})
+			c.socket.Close()
+			return
+		}
+		defer c.connections.remove(c)
+	}
 	defer c.closeSubscriptions()
 
+	if socket, ok := c.socket.(interface{ SetReadLimit(int64) }); ok && c.maxMessageSize > 0 {
+		socket.SetReadLimit(c.maxMessageSize)
+	}
+
+	c.setCredentials(c.credentials)
+	defer c.stopCredentials()
+
 	for {
 		var envelope inEnvelope
 		if err := c.socket.ReadJSON(&envelope); err != nil {
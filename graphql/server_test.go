@@ -0,0 +1,755 @@
+package graphql_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+	"github.com/denkhaus/thunder/reactive"
+)
+
+// chanSocket is a JSONSocket reading messages from in and writing them to out.
+type chanSocket struct {
+	in  chan interface{}
+	out chan map[string]interface{}
+}
+
+func newChanSocket() *chanSocket {
+	return &chanSocket{
+		in:  make(chan interface{}),
+		out: make(chan map[string]interface{}, 16),
+	}
+}
+
+func (s *chanSocket) ReadJSON(value interface{}) error {
+	message, ok := <-s.in
+	if !ok {
+		return &websocket.CloseError{Code: websocket.CloseNormalClosure}
+	}
+	bytes, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bytes, value)
+}
+
+func (s *chanSocket) WriteJSON(value interface{}) error {
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	var message map[string]interface{}
+	if err := json.Unmarshal(bytes, &message); err != nil {
+		return err
+	}
+	s.out <- message
+	return nil
+}
+
+func (s *chanSocket) Close() error {
+	return nil
+}
+
+type authKey struct{}
+
+func TestSubscriptionHooks(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("value", func() int64 { return 1 })
+	schema.Mutation()
+
+	var mu sync.Mutex
+	var events []string
+	hooks := graphql.SubscriptionHooks{
+		BeforeSubscribe: func(ctx context.Context, info *graphql.SubscriptionInfo) error {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, "subscribe "+info.ID+" "+info.Query.Name+" "+ctx.Value(authKey{}).(string))
+			if info.Query.Name == "Forbidden" {
+				return graphql.NewSafeError("subscription not allowed")
+			}
+			return nil
+		},
+		BeforeUnsubscribe: func(ctx context.Context, info *graphql.SubscriptionInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, "unsubscribe "+info.ID+" "+info.Query.Name)
+		},
+	}
+
+	socket := newChanSocket()
+	ctx := context.WithValue(context.Background(), authKey{}, "alice")
+	conn := graphql.CreateConnection(ctx, socket, schema.MustBuild(), graphql.WithSubscriptionHooks(hooks))
+	done := make(chan struct{})
+	go func() {
+		conn.ServeJSONSocket()
+		close(done)
+	}()
+
+	subscribe := func(id, query string) map[string]interface{} {
+		socket.in <- map[string]interface{}{
+			"id":      id,
+			"type":    "subscribe",
+			"message": map[string]interface{}{"query": query},
+		}
+		return <-socket.out
+	}
+
+	out := subscribe("1", "query Value { value }")
+	assert.Equal(t, "update", out["type"])
+	out = subscribe("2", "query Forbidden { value }")
+	assert.Equal(t, "error", out["type"])
+	assert.Equal(t, "subscription not allowed", out["message"])
+	out = subscribe("3", "query Other { value }")
+	assert.Equal(t, "update", out["type"])
+
+	socket.in <- map[string]interface{}{"id": "1", "type": "unsubscribe"}
+	// Closing the connection ends the remaining subscriptions.
+	close(socket.in)
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, events, 5)
+	assert.Equal(t, []string{
+		"subscribe 1 Value alice",
+		"subscribe 2 Forbidden alice",
+		"subscribe 3 Other alice",
+		"unsubscribe 1 Value",
+		"unsubscribe 3 Other",
+	}, events)
+}
+
+func TestSubscriptionSkipInitial(t *testing.T) {
+	var value int64 = 1
+	resource := reactive.NewResource()
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("value", func(ctx context.Context) int64 {
+		reactive.AddDependency(ctx, resource, nil)
+		return atomic.LoadInt64(&value)
+	})
+	schema.Mutation()
+
+	socket := newChanSocket()
+	conn := graphql.CreateConnection(context.Background(), socket, schema.MustBuild(), graphql.WithMinRerunInterval(0))
+	done := make(chan struct{})
+	go func() {
+		conn.ServeJSONSocket()
+		close(done)
+	}()
+
+	subscribe := func(id string, skipInitial bool) map[string]interface{} {
+		socket.in <- map[string]interface{}{
+			"id":      id,
+			"type":    "subscribe",
+			"message": map[string]interface{}{"query": "{ value }", "skipInitial": skipInitial},
+		}
+		return <-socket.out
+	}
+
+	out := subscribe("1", false)
+	assert.Equal(t, []interface{}{map[string]interface{}{"value": float64(1)}}, out["message"])
+	// Subscriptions skipping the initial result are only acknowledged.
+	out = subscribe("2", true)
+	assert.Equal(t, "update", out["type"])
+	assert.Equal(t, map[string]interface{}{}, out["message"])
+
+	// Both get subsequent changes, diffed against the initial result.
+	atomic.StoreInt64(&value, 2)
+	resource.Invalidate()
+	updates := map[string]interface{}{}
+	for i := 0; i < 2; i++ {
+		out := <-socket.out
+		updates[out["id"].(string)] = out["message"]
+	}
+	assert.Equal(t, map[string]interface{}{
+		"1": map[string]interface{}{"value": float64(2)},
+		"2": map[string]interface{}{"value": float64(2)},
+	}, updates)
+
+	close(socket.in)
+	<-done
+}
+
+func TestTerminateSubscriptions(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("value", func() int64 {
+		return 1
+	})
+	schema.Mutation()
+	built := schema.MustBuild()
+
+	connections := graphql.NewConnections()
+	var mu sync.Mutex
+	var unsubscribed []string
+	serve := func() (*chanSocket, string, chan struct{}) {
+		var connectionID string
+		hooks := graphql.WithSubscriptionHooks(graphql.SubscriptionHooks{
+			BeforeSubscribe: func(ctx context.Context, info *graphql.SubscriptionInfo) error {
+				conn, _ := graphql.ConnectionInfoFromContext(ctx)
+				connectionID = conn.ID
+				return nil
+			},
+			BeforeUnsubscribe: func(ctx context.Context, info *graphql.SubscriptionInfo) {
+				mu.Lock()
+				defer mu.Unlock()
+				unsubscribed = append(unsubscribed, info.ID)
+			},
+		})
+		socket := newChanSocket()
+		conn := graphql.CreateConnection(context.Background(), socket, built, hooks, graphql.WithConnections(connections))
+		done := make(chan struct{})
+		go func() {
+			conn.ServeJSONSocket()
+			close(done)
+		}()
+		for _, id := range []string{"1", "2", "3"} {
+			socket.in <- map[string]interface{}{
+				"id":      id,
+				"type":    "subscribe",
+				"message": map[string]interface{}{"query": "{ value }"},
+			}
+			<-socket.out
+		}
+		return socket, connectionID, done
+	}
+	socket, id, done := serve()
+	other, _, otherDone := serve()
+
+	match := func(connection, subscription string) func(graphql.ConnectionInfo, *graphql.SubscriptionInfo) bool {
+		return func(conn graphql.ConnectionInfo, info *graphql.SubscriptionInfo) bool {
+			return conn.ID == connection && info.ID == subscription
+		}
+	}
+	assert.Equal(t, 1, connections.TerminateSubscriptions("permission revoked", match(id, "1")))
+	assert.Equal(t, map[string]interface{}{
+		"id":      "1",
+		"type":    "complete",
+		"message": map[string]interface{}{"reason": "permission revoked"},
+	}, <-socket.out)
+	assert.Equal(t, 0, connections.TerminateSubscriptions("permission revoked", match(id, "1")))
+	mu.Lock()
+	assert.Equal(t, []string{"1"}, unsubscribed)
+	mu.Unlock()
+
+	assert.Equal(t, 1, connections.Terminate("logged out", func(conn graphql.ConnectionInfo) bool {
+		return conn.ID == id
+	}))
+	completed := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		out := <-socket.out
+		assert.Equal(t, "complete", out["type"])
+		assert.Equal(t, map[string]interface{}{"reason": "logged out"}, out["message"])
+		completed[out["id"].(string)] = true
+	}
+	assert.Equal(t, map[string]bool{"2": true, "3": true}, completed)
+	assert.Equal(t, map[string]interface{}{
+		"type":    "close",
+		"message": map[string]interface{}{"reason": "logged out"},
+	}, <-socket.out)
+	mu.Lock()
+	assert.Len(t, unsubscribed, 3)
+	mu.Unlock()
+	close(socket.in)
+	<-done
+
+	// Other connections are left alone.
+	select {
+	case out := <-other.out:
+		t.Errorf("unexpected message %v", out)
+	default:
+	}
+	close(other.in)
+	<-otherDone
+}
+
+func TestShutdown(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("value", func() int64 {
+		return 1
+	})
+	schema.Mutation().FieldFunc("slow", func() int64 {
+		close(started)
+		<-release
+		return 2
+	})
+	built := schema.MustBuild()
+
+	connections := graphql.NewConnections()
+	socket := newChanSocket()
+	conn := graphql.CreateConnection(context.Background(), socket, built, graphql.WithConnections(connections))
+	done := make(chan struct{})
+	go func() {
+		conn.ServeJSONSocket()
+		close(done)
+	}()
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ value }"},
+	}
+	assert.Equal(t, "update", (<-socket.out)["type"])
+	socket.in <- map[string]interface{}{
+		"id":      "2",
+		"type":    "mutate",
+		"message": map[string]interface{}{"query": "mutation { slow }"},
+	}
+	<-started
+	assert.Equal(t, 1, connections.Len())
+
+	// Shutdown waits for the running mutation to send its result.
+	shutdown := make(chan error)
+	go func() {
+		shutdown <- connections.Shutdown(context.Background())
+	}()
+	close(release)
+	out := <-socket.out
+	assert.Equal(t, "result", out["type"])
+	assert.Equal(t, "2", out["id"])
+	assert.Equal(t, map[string]interface{}{
+		"type":    "close",
+		"message": map[string]interface{}{"reason": graphql.ShutdownReason, "reconnect": true},
+	}, <-socket.out)
+	assert.NoError(t, <-shutdown)
+
+	// New operations and connections are rejected.
+	socket.in <- map[string]interface{}{
+		"id":      "3",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ value }"},
+	}
+	assert.Equal(t, map[string]interface{}{
+		"id":      "3",
+		"type":    "error",
+		"message": graphql.ShutdownReason,
+	}, <-socket.out)
+
+	otherSocket := newChanSocket()
+	graphql.CreateConnection(context.Background(), otherSocket, built, graphql.WithConnections(connections)).ServeJSONSocket()
+	assert.Equal(t, "close", (<-otherSocket.out)["type"])
+
+	close(socket.in)
+	<-done
+	assert.Equal(t, 0, connections.Len())
+}
+
+func TestReliableSubscription(t *testing.T) {
+	var value int64 = 1
+	resource := reactive.NewResource()
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("value", func(ctx context.Context) int64 {
+		reactive.AddDependency(ctx, resource, nil)
+		return atomic.LoadInt64(&value)
+	})
+	schema.Mutation()
+
+	socket := newChanSocket()
+	conn := graphql.CreateConnection(context.Background(), socket, schema.MustBuild(), graphql.WithMinRerunInterval(0))
+	done := make(chan struct{})
+	go func() {
+		conn.ServeJSONSocket()
+		close(done)
+	}()
+
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ value }", "reliable": true},
+	}
+	socket.in <- map[string]interface{}{
+		"id":      "2",
+		"type":    "subscribe",
+		"message": map[string]interface{}{"query": "{ value }"},
+	}
+	seqs := map[string]interface{}{}
+	for i := 0; i < 2; i++ {
+		out := <-socket.out
+		seqs[out["id"].(string)] = out["seq"]
+	}
+	// Only reliable subscriptions number their updates.
+	assert.Equal(t, map[string]interface{}{"1": float64(1), "2": nil}, seqs)
+	assert.Equal(t, map[string]uint64{"1": 1}, conn.UnackedUpdates())
+
+	socket.in <- map[string]interface{}{"id": "1", "type": "ack", "message": 1}
+	atomic.StoreInt64(&value, 2)
+	resource.Invalidate()
+	for i := 0; i < 2; i++ {
+		out := <-socket.out
+		seqs[out["id"].(string)] = out["seq"]
+	}
+	assert.Equal(t, map[string]interface{}{"1": float64(2), "2": nil}, seqs)
+	assert.Equal(t, map[string]uint64{"1": 1}, conn.UnackedUpdates())
+
+	socket.in <- map[string]interface{}{"id": "1", "type": "ack", "message": 2}
+	socket.in <- map[string]interface{}{"id": "1", "type": "ack", "message": 3}
+	assert.Equal(t, map[string]interface{}{
+		"id":      "1",
+		"type":    "error",
+		"message": "ack of unsent update",
+	}, <-socket.out)
+	assert.Equal(t, map[string]uint64{"1": 0}, conn.UnackedUpdates())
+
+	socket.in <- map[string]interface{}{"id": "1", "type": "unsubscribe"}
+	socket.in <- map[string]interface{}{"id": "1", "type": "ack", "message": 2}
+	assert.Equal(t, map[string]uint64{}, conn.UnackedUpdates())
+
+	close(socket.in)
+	<-done
+}
+
+func TestConnectionUpdate(t *testing.T) {
+	resource := reactive.NewResource()
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("user", func(ctx context.Context) string {
+		reactive.AddDependency(ctx, resource, nil)
+		return ctx.Value(authKey{}).(string)
+	})
+	schema.Mutation()
+
+	credentials := func(user string, expiry time.Time) *graphql.Credentials {
+		return &graphql.Credentials{
+			MakeCtx: func(ctx context.Context) context.Context {
+				return context.WithValue(ctx, authKey{}, user)
+			},
+			Expiry: expiry,
+		}
+	}
+	authenticate := func(ctx context.Context, message json.RawMessage) (*graphql.Credentials, error) {
+		var token string
+		if err := json.Unmarshal(message, &token); err != nil {
+			return nil, err
+		}
+		switch token {
+		case "bob":
+			return credentials("bob", time.Time{}), nil
+		case "short":
+			return credentials("short", time.Now().Add(50*time.Millisecond)), nil
+		default:
+			return nil, graphql.NewSafeError("invalid token")
+		}
+	}
+
+	socket := newChanSocket()
+	conn := graphql.CreateConnection(context.Background(), socket, schema.MustBuild(),
+		graphql.WithAuthenticate(authenticate),
+		graphql.WithCredentials(credentials("alice", time.Time{})),
+		graphql.WithMinRerunInterval(0))
+	done := make(chan struct{})
+	go func() {
+		conn.ServeJSONSocket()
+		close(done)
+	}()
+
+	update := func(token string) map[string]interface{} {
+		socket.in <- map[string]interface{}{"id": "u", "type": "connection_update", "message": token}
+		return <-socket.out
+	}
+	subscribe := func(id string) map[string]interface{} {
+		socket.in <- map[string]interface{}{
+			"id":      id,
+			"type":    "subscribe",
+			"message": map[string]interface{}{"query": "{ user }"},
+		}
+		return <-socket.out
+	}
+
+	out := subscribe("1")
+	assert.Equal(t, []interface{}{map[string]interface{}{"user": "alice"}}, out["message"])
+
+	// Reruns use the refreshed credentials.
+	assert.Equal(t, map[string]interface{}{"id": "u", "type": "connection_updated"}, update("bob"))
+	resource.Strobe()
+	out = <-socket.out
+	assert.Equal(t, "1", out["id"])
+	assert.Equal(t, map[string]interface{}{"user": "bob"}, out["message"])
+
+	// Invalid tokens keep the current credentials.
+	out = update("mallory")
+	assert.Equal(t, "error", out["type"])
+	assert.Equal(t, "invalid token", out["message"])
+
+	// Lapsed credentials terminate the subscriptions, but not the socket.
+	assert.Equal(t, "connection_updated", update("short")["type"])
+	assert.Equal(t, map[string]interface{}{
+		"id":      "1",
+		"type":    "complete",
+		"message": map[string]interface{}{"reason": graphql.CredentialsExpiredReason},
+	}, <-socket.out)
+	out = subscribe("3")
+	assert.Equal(t, "error", out["type"])
+	assert.Equal(t, graphql.CredentialsExpiredReason, out["message"])
+
+	assert.Equal(t, "connection_updated", update("bob")["type"])
+	out = subscribe("4")
+	assert.Equal(t, []interface{}{map[string]interface{}{"user": "bob"}}, out["message"])
+
+	close(socket.in)
+	<-done
+}
+
+// protocolSocket is a chanSocket that negotiated a subprotocol.
+type protocolSocket struct {
+	*chanSocket
+}
+
+func (s protocolSocket) Subprotocol() string {
+	return "thunder-v2"
+}
+
+func TestConnectionInfo(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("connection", func(ctx context.Context) (string, error) {
+		info, ok := graphql.ConnectionInfoFromContext(ctx)
+		if !ok {
+			return "", errors.New("no connection")
+		}
+		auth, _ := info.Auth.(string)
+		return info.ID + " " + info.Protocol + " " + auth, nil
+	})
+	schema.Mutation()
+
+	_, ok := graphql.ConnectionInfoFromContext(context.Background())
+	assert.False(t, ok)
+
+	socket := newChanSocket()
+	authenticate := func(ctx context.Context, message json.RawMessage) (*graphql.Credentials, error) {
+		return &graphql.Credentials{Payload: "bob"}, nil
+	}
+	start := time.Now()
+	var hookInfo graphql.ConnectionInfo
+	conn := graphql.CreateConnection(context.Background(), protocolSocket{socket}, schema.MustBuild(),
+		graphql.WithAuthenticate(authenticate),
+		graphql.WithCredentials(&graphql.Credentials{Payload: "alice"}),
+		graphql.WithSubscriptionHooks(graphql.SubscriptionHooks{
+			BeforeSubscribe: func(ctx context.Context, info *graphql.SubscriptionInfo) error {
+				hookInfo, _ = graphql.ConnectionInfoFromContext(ctx)
+				return nil
+			},
+		}))
+	done := make(chan struct{})
+	go func() {
+		conn.ServeJSONSocket()
+		close(done)
+	}()
+
+	subscribe := func(id string) string {
+		socket.in <- map[string]interface{}{
+			"id":      id,
+			"type":    "subscribe",
+			"message": map[string]interface{}{"query": "{ connection }"},
+		}
+		out := <-socket.out
+		require.Equal(t, "update", out["type"], out)
+		return out["message"].([]interface{})[0].(map[string]interface{})["connection"].(string)
+	}
+
+	assert.Len(t, hookInfo.ID, 0)
+	result := subscribe("1")
+	require.Len(t, hookInfo.ID, 16)
+	assert.Equal(t, hookInfo.ID+" thunder-v2 alice", result)
+	assert.False(t, hookInfo.ConnectedAt.Before(start))
+	assert.Equal(t, "alice", hookInfo.Auth)
+
+	// The auth payload follows refreshed credentials.
+	socket.in <- map[string]interface{}{"id": "u", "type": "connection_update", "message": "token"}
+	assert.Equal(t, "connection_updated", (<-socket.out)["type"])
+	assert.Equal(t, hookInfo.ID+" thunder-v2 bob", subscribe("2"))
+
+	close(socket.in)
+	<-done
+}
+
+func TestSubscriptionUpdateVariables(t *testing.T) {
+	var mu sync.Mutex
+	calls := map[string]int{}
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("double", func(args struct{ N int64 }) int64 {
+		mu.Lock()
+		defer mu.Unlock()
+		calls["double"]++
+		return args.N * 2
+	}, schemabuilder.Expensive)
+	schema.Query().FieldFunc("constant", func() int64 {
+		mu.Lock()
+		defer mu.Unlock()
+		calls["constant"]++
+		return 7
+	}, schemabuilder.Expensive)
+	schema.Mutation()
+
+	var variables []interface{}
+	hooks := graphql.SubscriptionHooks{
+		BeforeSubscribe: func(ctx context.Context, info *graphql.SubscriptionInfo) error {
+			variables = append(variables, info.Variables["n"])
+			if info.Variables["n"] == float64(-1) {
+				return graphql.NewSafeError("negative numbers not allowed")
+			}
+			return nil
+		},
+	}
+
+	socket := newChanSocket()
+	conn := graphql.CreateConnection(context.Background(), socket, schema.MustBuild(), graphql.WithMinRerunInterval(0), graphql.WithSubscriptionHooks(hooks))
+	done := make(chan struct{})
+	go func() {
+		conn.ServeJSONSocket()
+		close(done)
+	}()
+	updateVariables := func(id string, n int64) {
+		socket.in <- map[string]interface{}{
+			"id":      id,
+			"type":    "update_variables",
+			"message": map[string]interface{}{"variables": map[string]interface{}{"n": n}},
+		}
+	}
+
+	socket.in <- map[string]interface{}{
+		"id":   "1",
+		"type": "subscribe",
+		"message": map[string]interface{}{
+			"query":     "query Double($n: int64!) { double(n: $n) constant }",
+			"variables": map[string]interface{}{"n": 1},
+		},
+	}
+	out := <-socket.out
+	assert.Equal(t, []interface{}{map[string]interface{}{"double": float64(2), "constant": float64(7)}}, out["message"])
+
+	// The subscription reruns with the new variables, and sends a diff.
+	updateVariables("1", 5)
+	out = <-socket.out
+	assert.Equal(t, "update", out["type"])
+	assert.Equal(t, map[string]interface{}{"double": float64(10)}, out["message"])
+
+	// Fields whose arguments did not change are not resolved again.
+	mu.Lock()
+	assert.Equal(t, map[string]int{"double": 2, "constant": 1}, calls)
+	mu.Unlock()
+
+	// Updates rejected by hooks, with invalid variables, or of unknown
+	// subscriptions fail without affecting the subscription.
+	updateVariables("1", -1)
+	out = <-socket.out
+	assert.Equal(t, "error", out["type"])
+	assert.Equal(t, "negative numbers not allowed", out["message"])
+	socket.in <- map[string]interface{}{
+		"id":      "1",
+		"type":    "update_variables",
+		"message": map[string]interface{}{"variables": map[string]interface{}{"n": "five"}},
+	}
+	out = <-socket.out
+	assert.Equal(t, "error", out["type"])
+	updateVariables("2", 5)
+	out = <-socket.out
+	assert.Equal(t, "error", out["type"])
+	assert.Equal(t, "unknown subscription", out["message"])
+
+	updateVariables("1", 1)
+	out = <-socket.out
+	assert.Equal(t, map[string]interface{}{"double": float64(2)}, out["message"])
+	assert.Equal(t, []interface{}{float64(1), float64(5), float64(-1), float64(1)}, variables)
+
+	close(socket.in)
+	<-done
+}
+
+func TestSubscriptionFieldVisibility(t *testing.T) {
+	internalOnly := schemabuilder.FeatureFlag(func(ctx context.Context) bool {
+		return ctx.Value(internalCallerKey{}) != nil
+	})
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("internal", func() string { return "internal" }, internalOnly)
+	schema.Mutation().FieldFunc("reset", func() bool { return true }, internalOnly)
+	built := schema.MustBuild()
+
+	serve := func(opts ...graphql.ConnectionOption) (*chanSocket, func()) {
+		socket := newChanSocket()
+		conn := graphql.CreateConnection(context.Background(), socket, built, opts...)
+		done := make(chan struct{})
+		go func() {
+			conn.ServeJSONSocket()
+			close(done)
+		}()
+		return socket, func() {
+			close(socket.in)
+			<-done
+		}
+	}
+	send := func(socket *chanSocket, id, typ, query string) map[string]interface{} {
+		socket.in <- map[string]interface{}{
+			"id":      id,
+			"type":    typ,
+			"message": map[string]interface{}{"query": query},
+		}
+		return <-socket.out
+	}
+
+	// Operations are validated with the context they run with, which
+	// makeCtx marks as internal.
+	socket, stop := serve(graphql.WithMakeCtx(func(ctx context.Context) context.Context {
+		return context.WithValue(ctx, internalCallerKey{}, true)
+	}))
+	out := send(socket, "1", "subscribe", "{ internal }")
+	assert.Equal(t, "update", out["type"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"internal": "internal"}}, out["message"])
+	out = send(socket, "2", "mutate", "mutation { reset }")
+	assert.Equal(t, "result", out["type"])
+	stop()
+
+	socket, stop = serve()
+	out = send(socket, "1", "subscribe", "{ internal }")
+	assert.Equal(t, "error", out["type"])
+	assert.Equal(t, `unknown field "internal"`, out["message"])
+	out = send(socket, "2", "mutate", "mutation { reset }")
+	assert.Equal(t, "error", out["type"])
+	stop()
+}
+
+func TestSubscriptionVisibilityHook(t *testing.T) {
+	schema := schemabuilder.NewSchema()
+	schema.Query().FieldFunc("public", func() string { return "public" })
+	schema.Query().FieldFunc("secret", func() string { return "secret" })
+	schema.Mutation()
+
+	hook := func(ctx context.Context, typeName string, fieldName string) bool {
+		return fieldName != "secret"
+	}
+	socket := newChanSocket()
+	conn := graphql.CreateConnection(context.Background(), socket, schema.MustBuild(), graphql.WithMakeCtx(func(ctx context.Context) context.Context {
+		return graphql.WithVisibilityHook(ctx, hook)
+	}))
+	done := make(chan struct{})
+	go func() {
+		conn.ServeJSONSocket()
+		close(done)
+	}()
+	defer func() {
+		close(socket.in)
+		<-done
+	}()
+
+	subscribe := func(id, query string) map[string]interface{} {
+		socket.in <- map[string]interface{}{
+			"id":      id,
+			"type":    "subscribe",
+			"message": map[string]interface{}{"query": query},
+		}
+		return <-socket.out
+	}
+
+	out := subscribe("1", "{ public }")
+	assert.Equal(t, "update", out["type"])
+	out = subscribe("2", "{ secret }")
+	assert.Equal(t, "error", out["type"])
+	assert.Equal(t, `unknown field "secret"`, out["message"])
+}
@@ -0,0 +1,154 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sync"
+
+	"github.com/samsarahq/go/oops"
+
+	"github.com/denkhaus/thunder/reactive"
+)
+
+// subscriptionOperation holds the query and variables of a subscription.
+// Clients can change the variables of a running subscription, for example to
+// change a filter, with an "update_variables" message:
+//
+//	{"id": "1", "type": "update_variables", "message": {"variables": {"teamId": 2}}}
+//
+// The subscription then reruns with the new variables instead of being torn
+// down and subscribed again, so the rerunner keeps the cached results of
+// fields whose arguments did not change, and the client receives a diff
+// against the previous result.
+type subscriptionOperation struct {
+	queryText string
+
+	mu        sync.Mutex
+	query     *Query
+	variables map[string]interface{}
+	tags      map[string]string
+
+	// featureFlags are the flags the subscription was validated with, or nil.
+	featureFlags FeatureFlags
+
+	// changed is strobed when the variables change, rerunning the
+	// subscription.
+	changed *reactive.Resource
+}
+
+type updateVariablesMessage struct {
+	Variables map[string]interface{} `json:"variables"`
+}
+
+func newSubscriptionOperation(queryText string, query *Query, variables map[string]interface{}, tags map[string]string, featureFlags FeatureFlags) *subscriptionOperation {
+	return &subscriptionOperation{
+		queryText:    queryText,
+		query:        query,
+		variables:    variables,
+		tags:         tags,
+		featureFlags: featureFlags,
+		changed:      reactive.NewResource(),
+	}
+}
+
+// current returns the query, variables, and logging tags of the subscription
+// for a run, and reruns the computation of ctx when they change.
+func (o *subscriptionOperation) current(ctx context.Context) (*Query, map[string]interface{}, map[string]string) {
+	// Depend on changes before reading the variables, so changes made after
+	// reading them rerun the subscription.
+	reactive.AddDependency(ctx, o.changed, nil)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.query, o.variables, o.tags
+}
+
+// reuseSelections replaces the selections of next that are unchanged from
+// previous with the selections of previous. The executor caches the results
+// of expensive fields by selection, so reusing selections keeps the cached
+// results of the fields whose arguments did not change.
+func reuseSelections(previous, next *SelectionSet) {
+	if previous == nil || next == nil {
+		return
+	}
+	byAlias := make(map[string]*Selection, len(previous.Selections))
+	for _, selection := range previous.Selections {
+		if _, ok := byAlias[selection.Alias]; !ok {
+			byAlias[selection.Alias] = selection
+		}
+	}
+	for i, selection := range next.Selections {
+		old, ok := byAlias[selection.Alias]
+		if !ok {
+			continue
+		}
+		if reflect.DeepEqual(old, selection) {
+			next.Selections[i] = old
+			continue
+		}
+		reuseSelections(old.SelectionSet, selection.SelectionSet)
+	}
+	for i, fragment := range next.Fragments {
+		if i < len(previous.Fragments) && previous.Fragments[i].On == fragment.On {
+			reuseSelections(previous.Fragments[i].SelectionSet, fragment.SelectionSet)
+		}
+	}
+}
+
+// update swaps the query and variables used by the next run of the
+// subscription.
+func (o *subscriptionOperation) update(query *Query, variables map[string]interface{}, tags map[string]string) {
+	o.mu.Lock()
+	reuseSelections(o.query.SelectionSet, query.SelectionSet)
+	o.query, o.variables, o.tags = query, variables, tags
+	o.mu.Unlock()
+
+	o.changed.Strobe()
+}
+
+func (c *conn) handleUpdateVariables(in *inEnvelope) error {
+	var message updateVariablesMessage
+	if err := json.Unmarshal(in.Message, &message); err != nil {
+		return oops.Wrapf(err, "failed to parse update_variables message: %s", in.Message)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	op, ok := c.operations[in.ID]
+	if !ok {
+		return NewSafeError("unknown subscription")
+	}
+
+	tags := c.queryTags(in.ID, c.schema, op.queryText, message.Variables)
+	query, err := parseQuery(c.queryCache, op.queryText, message.Variables)
+	if err != nil {
+		c.logger.Error(c.ctx, err, tags)
+		return err
+	}
+	tags["queryType"] = query.Kind
+	tags["queryName"] = query.Name
+	if err := PrepareQuery(c.validationCtx(op.featureFlags), c.schema.Query, query.SelectionSet); err != nil {
+		c.logger.Error(c.ctx, err, tags)
+		return err
+	}
+
+	// Hooks accepted the subscription with its previous variables, so they
+	// must accept the new ones as well.
+	if info, ok := c.subscriptionInfos[in.ID]; ok {
+		updated := *info
+		updated.Query = query
+		updated.Variables = message.Variables
+		if c.hooks.BeforeSubscribe != nil {
+			if err := c.hooks.BeforeSubscribe(c.ctx, &updated); err != nil {
+				return err
+			}
+		}
+		*info = updated
+	}
+
+	op.update(query, message.Variables, tags)
+	c.subscriptions[in.ID].RerunImmediately()
+	return nil
+}
@@ -3,6 +3,7 @@ package graphql
 import (
 	"context"
 	"fmt"
+	"time"
 )
 
 // Type represents a GraphQL type, and should be either an Object, a Scalar,
@@ -33,6 +34,11 @@ type Enum struct {
 	Type       string
 	Values     []string
 	ReverseMap map[interface{}]string
+	// Aliases maps deprecated names that are accepted as inputs to the value
+	// name they stand for.
+	Aliases map[string]string
+	// Deprecations maps deprecated value names to their deprecation reason.
+	Deprecations map[string]string
 }
 
 func (e *Enum) isType() {}
@@ -49,8 +55,13 @@ func (e *Enum) enumValues() []string {
 type Object struct {
 	Name        string
 	Description string
-	KeyField    *Field
-	Fields      map[string]*Field
+	// KeyField resolves the __key of objects, which identifies them. It is
+	// a field of the object, or a field combining the values of KeyFields
+	// for compound keys.
+	KeyField *Field
+	// KeyFields are the names of the fields making up the key of the object.
+	KeyFields []string
+	Fields    map[string]*Field
 }
 
 func (o *Object) isType() {}
@@ -140,8 +151,41 @@ type Field struct {
 	// we're executing with so implementers can write custom logic.
 	NumParallelInvocationsFunc func(ctx context.Context, numNodes int) int
 
+	// Serial fields are never resolved concurrently with other serial fields in
+	// the same query, for example because they mutate request-scoped state.
+	Serial bool
+	// Pooled fields are resolved once per source on a bounded pool shared by
+	// all pooled fields in a query, see WithPooledConcurrency.
+	Pooled bool
+
 	// FederatedKey tells us which services need this field as federated key.
 	FederatedKey map[string]bool
+
+	// DeprecationReason is set if the field is deprecated.
+	DeprecationReason string
+	// Description documents the field in introspection.
+	Description string
+	// SensitiveArgs are the arguments whose values are redacted in the logs
+	// of the server, see RedactQuery.
+	SensitiveArgs map[string]bool
+	// Visible reports whether the field is visible to the client of a
+	// context, see IsFieldVisible. Fields with a nil Visible are always
+	// visible.
+	Visible func(ctx context.Context) bool
+
+	// CacheTTL is how long results of Resolve may be cached across requests,
+	// see WithFieldCache. DecodeCached decodes a result marshaled as JSON.
+	CacheTTL     time.Duration
+	DecodeCached func(value []byte) (interface{}, error)
+
+	// Pure fields only depend on their source and arguments. Their results
+	// are memoized across identical calls within a query.
+	Pure bool
+
+	// ResolverName identifies the function resolving the field, such as the
+	// name of the Go function of a schemabuilder field func. It is recorded
+	// in ExecutionEvents.
+	ResolverName string
 }
 
 type Schema struct {
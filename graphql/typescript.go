@@ -0,0 +1,354 @@
+package graphql
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql/language/ast"
+
+	"github.com/denkhaus/thunder/internal"
+)
+
+// DefaultTypeScriptScalars maps the scalars of schemabuilder to the
+// TypeScript types of their JSON encoding. Scalars missing from it, and not
+// set with WithTypeScriptScalar, are typed as any.
+var DefaultTypeScriptScalars = make(map[string]string)
+
+func init() {
+	for _, scalar := range internal.Scalars {
+		DefaultTypeScriptScalars[scalar.Name] = scalar.TypeScript
+	}
+}
+
+// TypeScriptOption configures PrintTypeScript.
+type TypeScriptOption func(*typeScriptPrinter)
+
+// WithTypeScriptScalar types the scalar name as the TypeScript type tsType.
+func WithTypeScriptScalar(name string, tsType string) TypeScriptOption {
+	return func(p *typeScriptPrinter) {
+		p.scalars[name] = tsType
+	}
+}
+
+// WithTypeScriptOperations adds the types of the variables and results of
+// operations, GraphQL documents each holding a single named query or
+// mutation.
+func WithTypeScriptOperations(operations ...string) TypeScriptOption {
+	return func(p *typeScriptPrinter) {
+		p.operations = append(p.operations, operations...)
+	}
+}
+
+type typeScriptPrinter struct {
+	schema     *Schema
+	scalars    map[string]string
+	operations []string
+	types      map[string]Type
+}
+
+// PrintTypeScript prints TypeScript type definitions for the types of
+// schema, sorted by name, so clients can keep their types in sync with the
+// schema. Objects and input objects become interfaces, and enums and unions
+// become union types. With WithTypeScriptOperations, it also prints the
+// types of the variables and results of operations, named after the
+// operation and its kind, as in GetUserQuery and GetUserQueryVariables.
+func PrintTypeScript(schema *Schema, opts ...TypeScriptOption) (string, error) {
+	p := &typeScriptPrinter{
+		schema:  schema,
+		scalars: make(map[string]string, len(DefaultTypeScriptScalars)),
+		types:   make(map[string]Type),
+	}
+	for name, tsType := range DefaultTypeScriptScalars {
+		p.scalars[name] = tsType
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	collectNamedTypes(schema.Query, p.types)
+	collectNamedTypes(schema.Mutation, p.types)
+	names := make([]string, 0, len(p.types))
+	for name, typ := range p.types {
+		if _, ok := typ.(*Scalar); !ok && !strings.HasPrefix(name, "__") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for i, name := range names {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		switch typ := p.types[name].(type) {
+		case *Enum:
+			values := append([]string(nil), typ.Values...)
+			sort.Strings(values)
+			quoted := make([]string, 0, len(values))
+			for _, value := range values {
+				quoted = append(quoted, strconv.Quote(value))
+			}
+			fmt.Fprintf(&buf, "export type %s = %s;\n", typ.Type, strings.Join(quoted, " | "))
+		case *InputObject:
+			fmt.Fprintf(&buf, "export interface %s {\n", typ.Name)
+			for _, field := range sortedKeys(typ.InputFields) {
+				p.printInputField(&buf, field, typ.InputFields[field])
+			}
+			buf.WriteString("}\n")
+		case *Union:
+			printTSDoc(&buf, "", typ.Description, "")
+			members := make([]string, 0, len(typ.Types))
+			for member := range typ.Types {
+				members = append(members, member)
+			}
+			sort.Strings(members)
+			fmt.Fprintf(&buf, "export type %s = %s;\n", typ.Name, strings.Join(members, " | "))
+		case *Object:
+			printTSDoc(&buf, "", typ.Description, "")
+			fmt.Fprintf(&buf, "export interface %s {\n", typ.Name)
+			for _, field := range sortedFieldNames(typ) {
+				printTSDoc(&buf, "  ", typ.Fields[field].Description, typ.Fields[field].DeprecationReason)
+				fmt.Fprintf(&buf, "  %s: %s;\n", field, p.typeName(typ.Fields[field].Type))
+			}
+			buf.WriteString("}\n")
+		}
+	}
+
+	for _, source := range p.operations {
+		if err := p.printOperation(&buf, source); err != nil {
+			return "", err
+		}
+	}
+	return buf.String(), nil
+}
+
+// printInputField prints an input field or variable, which is optional if it
+// is nullable.
+func (p *typeScriptPrinter) printInputField(buf *bytes.Buffer, name string, typ Type) {
+	if _, ok := typ.(*NonNull); ok {
+		fmt.Fprintf(buf, "  %s: %s;\n", name, p.typeName(typ))
+	} else {
+		fmt.Fprintf(buf, "  %s?: %s;\n", name, p.typeName(typ))
+	}
+}
+
+// typeName returns the TypeScript type of values of typ.
+func (p *typeScriptPrinter) typeName(typ Type) string {
+	nonNull, ok := typ.(*NonNull)
+	if !ok {
+		return p.typeName(&NonNull{Type: typ}) + " | null"
+	}
+	switch typ := nonNull.Type.(type) {
+	case *Scalar:
+		return p.scalar(typ.Type)
+	case *List:
+		return "Array<" + p.typeName(typ.Type) + ">"
+	default:
+		return typ.String()
+	}
+}
+
+func (p *typeScriptPrinter) scalar(name string) string {
+	if tsType, ok := p.scalars[name]; ok {
+		return tsType
+	}
+	return "any"
+}
+
+// printOperation prints the types of the variables and result of the
+// operation in source.
+func (p *typeScriptPrinter) printOperation(buf *bytes.Buffer, source string) error {
+	op, err := parseOperation(source)
+	if err != nil {
+		return err
+	}
+	if op.name == "" {
+		return fmt.Errorf("operations must be named")
+	}
+	query, err := op.bind(nil)
+	if err != nil {
+		return fmt.Errorf("operation %s: %s", op.name, err.Error())
+	}
+
+	var root Type = p.schema.Query
+	if op.kind == "mutation" {
+		root = p.schema.Mutation
+	}
+	name := op.name + strings.ToUpper(op.kind[:1]) + op.kind[1:]
+
+	buf.WriteString("\n")
+	fmt.Fprintf(buf, "export interface %sVariables {\n", name)
+	for _, definition := range op.definition.VariableDefinitions {
+		typ, nonNull, err := p.astTypeName(definition.Type)
+		if err != nil {
+			return fmt.Errorf("operation %s: %s", op.name, err.Error())
+		}
+		if nonNull {
+			fmt.Fprintf(buf, "  %s: %s;\n", definition.Variable.Name.Value, typ)
+		} else {
+			fmt.Fprintf(buf, "  %s?: %s | null;\n", definition.Variable.Name.Value, typ)
+		}
+	}
+	buf.WriteString("}\n")
+
+	result, err := p.selectionType(&NonNull{Type: root}, query.SelectionSet, "")
+	if err != nil {
+		return fmt.Errorf("operation %s: %s", op.name, err.Error())
+	}
+	buf.WriteString("\n")
+	fmt.Fprintf(buf, "export type %s = %s;\n", name, result)
+	return nil
+}
+
+// astTypeName returns the TypeScript type of the variable type typ, without
+// null, and whether typ is non-null.
+func (p *typeScriptPrinter) astTypeName(typ ast.Type) (string, bool, error) {
+	switch typ := typ.(type) {
+	case *ast.NonNull:
+		name, _, err := p.astTypeName(typ.Type)
+		return name, true, err
+	case *ast.List:
+		name, nonNull, err := p.astTypeName(typ.Type)
+		if !nonNull {
+			name += " | null"
+		}
+		return "Array<" + name + ">", false, err
+	case *ast.Named:
+		switch named := p.types[typ.Name.Value].(type) {
+		case *Scalar:
+			return p.scalar(named.Type), false, nil
+		case *Enum, *InputObject:
+			return typ.Name.Value, false, nil
+		}
+		return "", false, fmt.Errorf("unknown input type %s", typ.Name.Value)
+	default:
+		return "", false, fmt.Errorf("unsupported variable type %v", typ)
+	}
+}
+
+// selectionType returns the TypeScript type of the values of typ selected
+// by selectionSet, indented by indent.
+func (p *typeScriptPrinter) selectionType(typ Type, selectionSet *SelectionSet, indent string) (string, error) {
+	nonNull, ok := typ.(*NonNull)
+	if !ok {
+		name, err := p.selectionType(&NonNull{Type: typ}, selectionSet, indent)
+		return name + " | null", err
+	}
+
+	switch typ := nonNull.Type.(type) {
+	case *List:
+		name, err := p.selectionType(typ.Type, selectionSet, indent)
+		return "Array<" + name + ">", err
+	case *Object:
+		return p.objectSelectionType(typ, selectionSet, indent)
+	case *Union:
+		members := make([]string, 0, len(typ.Types))
+		for member := range typ.Types {
+			members = append(members, member)
+		}
+		sort.Strings(members)
+		types := make([]string, 0, len(members))
+		for _, member := range members {
+			name, err := p.objectSelectionType(typ.Types[member], selectionSet, indent)
+			if err != nil {
+				return "", err
+			}
+			types = append(types, name)
+		}
+		return strings.Join(types, " | "), nil
+	default:
+		if selectionSet != nil {
+			return "", fmt.Errorf("scalar field %s must have no selection", typ)
+		}
+		return p.typeName(nonNull), nil
+	}
+}
+
+// objectSelectionType returns the TypeScript type of the values of object
+// selected by selectionSet, including the fragments on object.
+func (p *typeScriptPrinter) objectSelectionType(object *Object, selectionSet *SelectionSet, indent string) (string, error) {
+	if selectionSet == nil {
+		return "", fmt.Errorf("object field %s must have selection", object.Name)
+	}
+
+	var aliases []string
+	selections := make(map[string]*Selection)
+	collectTypeScriptSelections(object.Name, selectionSet, &aliases, selections)
+
+	var buf bytes.Buffer
+	buf.WriteString("{\n")
+	for _, alias := range aliases {
+		selection := selections[alias]
+		if selection.Name == "__typename" {
+			fmt.Fprintf(&buf, "%s  %s: %s;\n", indent, alias, strconv.Quote(object.Name))
+			continue
+		}
+		field, ok := object.Fields[selection.Name]
+		if !ok {
+			return "", fmt.Errorf("unknown field %s on %s", selection.Name, object.Name)
+		}
+		name, err := p.selectionType(field.Type, selection.SelectionSet, indent+"  ")
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&buf, "%s  %s: %s;\n", indent, alias, name)
+	}
+	buf.WriteString(indent + "}")
+	return buf.String(), nil
+}
+
+// collectTypeScriptSelections adds the selections of selectionSet and its
+// fragments on typeName to selections by alias, merging the selection sets
+// of selections with the same alias. aliases lists them in query order.
+func collectTypeScriptSelections(typeName string, selectionSet *SelectionSet, aliases *[]string, selections map[string]*Selection) {
+	for _, selection := range selectionSet.Selections {
+		existing, ok := selections[selection.Alias]
+		if !ok {
+			*aliases = append(*aliases, selection.Alias)
+			selections[selection.Alias] = selection
+			continue
+		}
+		if existing.SelectionSet != nil && selection.SelectionSet != nil {
+			selections[selection.Alias] = &Selection{
+				Name:  existing.Name,
+				Alias: existing.Alias,
+				SelectionSet: &SelectionSet{
+					Selections: append(append([]*Selection(nil), existing.SelectionSet.Selections...), selection.SelectionSet.Selections...),
+					Fragments:  append(append([]*Fragment(nil), existing.SelectionSet.Fragments...), selection.SelectionSet.Fragments...),
+				},
+			}
+		}
+	}
+	for _, fragment := range selectionSet.Fragments {
+		if fragment.On == typeName {
+			collectTypeScriptSelections(typeName, fragment.SelectionSet, aliases, selections)
+		}
+	}
+}
+
+// printTSDoc prints a doc comment with description and deprecationReason, if
+// any.
+func printTSDoc(buf *bytes.Buffer, indent string, description string, deprecationReason string) {
+	var lines []string
+	if description != "" {
+		lines = append(lines, strings.Split(description, "\n")...)
+	}
+	if deprecationReason != "" {
+		lines = append(lines, "@deprecated "+deprecationReason)
+	}
+	if len(lines) == 0 {
+		return
+	}
+	if len(lines) == 1 {
+		fmt.Fprintf(buf, "%s/** %s */\n", indent, lines[0])
+		return
+	}
+	fmt.Fprintf(buf, "%s/**\n", indent)
+	for _, line := range lines {
+		fmt.Fprintf(buf, "%s * %s\n", indent, line)
+	}
+	fmt.Fprintf(buf, "%s */\n", indent)
+}
@@ -0,0 +1,64 @@
+package graphql_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/graphql"
+)
+
+func TestPrintTypeScript(t *testing.T) {
+	ts, err := graphql.PrintTypeScript(makeSDLSchema(false),
+		graphql.WithTypeScriptScalar("int64", "bigint"),
+		graphql.WithTypeScriptOperations(`
+			query Items($filter: sdlFilter_InputObject, $ids: [int64!]!) {
+				items(filter: $filter) { ...ItemFields kind: color }
+				total: count
+			}
+			fragment ItemFields on Item { __typename name }`,
+		))
+	require.NoError(t, err)
+	assert.Equal(t, `export interface Item {
+  color: sdlColor;
+  name: string;
+}
+
+export interface Mutation {
+}
+
+export interface Query {
+  /** @deprecated use items */
+  count: bigint;
+  /** Lists items. */
+  items: Array<Item>;
+}
+
+export type sdlColor = "blue" | "red";
+
+export interface sdlFilter_InputObject {
+  limit: bigint;
+  name?: string | null;
+}
+
+export interface ItemsQueryVariables {
+  filter?: sdlFilter_InputObject | null;
+  ids: Array<bigint>;
+}
+
+export type ItemsQuery = {
+  items: Array<{
+    kind: sdlColor;
+    __typename: "Item";
+    name: string;
+  }>;
+  total: bigint;
+};
+`, ts)
+
+	_, err = graphql.PrintTypeScript(makeSDLSchema(false), graphql.WithTypeScriptOperations(`{ count }`))
+	assert.EqualError(t, err, "operations must be named")
+	_, err = graphql.PrintTypeScript(makeSDLSchema(false), graphql.WithTypeScriptOperations(`query Q { items { missing } }`))
+	assert.EqualError(t, err, "operation Q: unknown field missing on Item")
+}
@@ -0,0 +1,148 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+type schemaVersionKey struct{}
+
+// WithSchemaVersion returns a context for a client of schema version
+// version. Fields with a minimum schema version are only visible to clients
+// of at least that version, see schemabuilder.MinSchemaVersion.
+func WithSchemaVersion(ctx context.Context, version int) context.Context {
+	return context.WithValue(ctx, schemaVersionKey{}, version)
+}
+
+// SchemaVersion returns the schema version of the client of ctx, or 0 if it
+// is not set.
+func SchemaVersion(ctx context.Context) int {
+	version, _ := ctx.Value(schemaVersionKey{}).(int)
+	return version
+}
+
+// IsFieldVisible reports whether field is visible to the client of ctx.
+// Hidden fields are left out of introspection, and queries selecting them
+// fail as if they did not exist.
+func IsFieldVisible(ctx context.Context, field *Field) bool {
+	return field.Visible == nil || field.Visible(ctx)
+}
+
+// A VisibilityHook decides per request which types and fields of a schema
+// are visible to the caller of ctx, for example to only show internal fields
+// to employees, while keeping a single schema. It is called with an empty
+// fieldName to decide whether the named type typeName is visible, and with
+// the name of a field of the object typeName otherwise. Introspection types
+// and fields, whose names start with "__", are always visible.
+type VisibilityHook func(ctx context.Context, typeName string, fieldName string) bool
+
+type visibilityHookKey struct{}
+
+// WithVisibilityHook returns a context whose caller only sees the types and
+// fields hook allows. Hidden types and fields are left out of
+// introspection, and queries selecting them fail as if they did not exist.
+func WithVisibilityHook(ctx context.Context, hook VisibilityHook) context.Context {
+	return context.WithValue(ctx, visibilityHookKey{}, hook)
+}
+
+// IsTypeVisible reports whether the named type of typ is visible to the
+// caller of ctx, see WithVisibilityHook.
+func IsTypeVisible(ctx context.Context, typ Type) bool {
+	hook, ok := ctx.Value(visibilityHookKey{}).(VisibilityHook)
+	if !ok {
+		return true
+	}
+	for {
+		switch inner := typ.(type) {
+		case *NonNull:
+			typ = inner.Type
+		case *List:
+			typ = inner.Type
+		default:
+			name := typ.String()
+			return strings.HasPrefix(name, "__") || hook(ctx, name, "")
+		}
+	}
+}
+
+// IsObjectFieldVisible reports whether the field name of object is visible
+// to the caller of ctx. Fields must be visible themselves, see
+// IsFieldVisible, be allowed by the visibility hook of ctx, and only use
+// visible types.
+func IsObjectFieldVisible(ctx context.Context, object *Object, name string) bool {
+	field, ok := object.Fields[name]
+	if !ok || !IsFieldVisible(ctx, field) {
+		return false
+	}
+	hook, ok := ctx.Value(visibilityHookKey{}).(VisibilityHook)
+	if !ok || strings.HasPrefix(name, "__") {
+		return true
+	}
+	if !hook(ctx, object.Name, name) || !IsTypeVisible(ctx, field.Type) {
+		return false
+	}
+	for _, arg := range field.Args {
+		if !IsTypeVisible(ctx, arg) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsInputFieldVisible reports whether the field name of the input object
+// input is visible to the caller of ctx. Fields must be allowed by the
+// visibility hook of ctx, and have a visible type.
+func IsInputFieldVisible(ctx context.Context, input *InputObject, name string) bool {
+	typ, ok := input.InputFields[name]
+	if !ok {
+		return false
+	}
+	hook, ok := ctx.Value(visibilityHookKey{}).(VisibilityHook)
+	if !ok {
+		return true
+	}
+	return hook(ctx, input.Name, name) && IsTypeVisible(ctx, typ)
+}
+
+// checkArgVisibility returns an error if an argument value of type typ sets
+// input fields hidden from the caller of ctx. The error matches the error of
+// unknown input fields, so hidden fields cannot be told apart from fields
+// that do not exist.
+func checkArgVisibility(ctx context.Context, typ Type, value interface{}) error {
+	switch typ := typ.(type) {
+	case *NonNull:
+		return checkArgVisibility(ctx, typ.Type, value)
+
+	case *List:
+		if values, ok := value.([]interface{}); ok {
+			for _, value := range values {
+				if err := checkArgVisibility(ctx, typ.Type, value); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		return checkArgVisibility(ctx, typ.Type, value)
+
+	case *InputObject:
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		for name, value := range fields {
+			fieldType, ok := typ.InputFields[name]
+			if !ok {
+				// Argument parsing reports unknown fields.
+				continue
+			}
+			if !IsInputFieldVisible(ctx, typ, name) {
+				return fmt.Errorf("unknown arg %s", name)
+			}
+			if err := checkArgVisibility(ctx, fieldType, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package graphql_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/introspection"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+	"github.com/denkhaus/thunder/internal"
+)
+
+type internalCallerKey struct{}
+
+func TestFieldVisibility(t *testing.T) {
+	builder := schemabuilder.NewSchema()
+	query := builder.Query()
+	query.FieldFunc("public", func() string { return "public" })
+	query.FieldFunc("internal", func() string { return "internal" }, schemabuilder.FeatureFlag(func(ctx context.Context) bool {
+		return ctx.Value(internalCallerKey{}) != nil
+	}))
+	query.FieldFunc("v2", func() string { return "v2" }, schemabuilder.MinSchemaVersion(2))
+	schema := builder.MustBuild()
+	introspection.AddIntrospectionToSchema(schema)
+
+	execute := func(ctx context.Context, query string) (interface{}, error) {
+		q := graphql.MustParse(query, nil)
+		if err := graphql.PrepareQuery(ctx, schema.Query, q.SelectionSet); err != nil {
+			return nil, err
+		}
+		e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+		res, err := e.Execute(ctx, schema.Query, nil, q)
+		return internal.AsJSON(res), err
+	}
+	fieldNames := func(ctx context.Context) []interface{} {
+		res, err := execute(ctx, `{ __schema { queryType { fields { name } } } }`)
+		require.NoError(t, err)
+		var names []interface{}
+		for _, field := range res.(map[string]interface{})["__schema"].(map[string]interface{})["queryType"].(map[string]interface{})["fields"].([]interface{}) {
+			names = append(names, field.(map[string]interface{})["name"])
+		}
+		return names
+	}
+
+	ctx := context.Background()
+	assert.Equal(t, []interface{}{"public"}, fieldNames(ctx))
+	_, err := execute(ctx, `{ internal }`)
+	assert.EqualError(t, err, `unknown field "internal"`)
+	_, err = execute(ctx, `{ v2 }`)
+	assert.EqualError(t, err, `unknown field "v2"`)
+
+	internalCtx := context.WithValue(ctx, internalCallerKey{}, true)
+	assert.Equal(t, []interface{}{"internal", "public"}, fieldNames(internalCtx))
+	res, err := execute(internalCtx, `{ internal }`)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"internal": "internal"}, res)
+
+	v2Ctx := graphql.WithSchemaVersion(ctx, 2)
+	assert.Equal(t, []interface{}{"public", "v2"}, fieldNames(v2Ctx))
+	res, err = execute(v2Ctx, `{ v2 }`)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"v2": "v2"}, res)
+	_, err = execute(graphql.WithSchemaVersion(ctx, 1), `{ v2 }`)
+	assert.Error(t, err)
+}
+
+type visibilityUser struct {
+	Name   string
+	Salary int64
+}
+
+type visibilityAudit struct {
+	Entry string
+}
+
+func TestVisibilityHook(t *testing.T) {
+	builder := schemabuilder.NewSchema()
+	builder.Object("User", visibilityUser{})
+	builder.Object("Audit", visibilityAudit{})
+	query := builder.Query()
+	query.FieldFunc("user", func() *visibilityUser { return &visibilityUser{Name: "alice", Salary: 100} })
+	query.FieldFunc("audits", func() []*visibilityAudit { return []*visibilityAudit{{Entry: "login"}} })
+	schema := builder.MustBuild()
+	introspection.AddIntrospectionToSchema(schema)
+
+	execute := func(ctx context.Context, query string) (interface{}, error) {
+		q := graphql.MustParse(query, nil)
+		if err := graphql.PrepareQuery(ctx, schema.Query, q.SelectionSet); err != nil {
+			return nil, err
+		}
+		e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+		res, err := e.Execute(ctx, schema.Query, nil, q)
+		return internal.AsJSON(res), err
+	}
+
+	// Employees see everything, others neither salaries nor audits.
+	hook := func(ctx context.Context, typeName string, fieldName string) bool {
+		if ctx.Value(internalCallerKey{}) != nil {
+			return true
+		}
+		return typeName != "Audit" && !(typeName == "User" && fieldName == "salary")
+	}
+	ctx := graphql.WithVisibilityHook(context.Background(), hook)
+	employeeCtx := graphql.WithVisibilityHook(context.WithValue(context.Background(), internalCallerKey{}, true), hook)
+
+	res, err := execute(ctx, `{ user { name } }`)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"user": map[string]interface{}{"name": "alice"}}, res)
+	_, err = execute(ctx, `{ user { salary } }`)
+	assert.EqualError(t, err, `unknown field "salary"`)
+	_, err = execute(ctx, `{ audits { entry } }`)
+	assert.EqualError(t, err, `unknown field "audits"`)
+	res, err = execute(employeeCtx, `{ user { salary } audits { entry } }`)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"user":   map[string]interface{}{"salary": float64(100)},
+		"audits": []interface{}{map[string]interface{}{"entry": "login"}},
+	}, res)
+
+	// Hidden types and fields are left out of introspection.
+	res, err = execute(ctx, `{
+		audit: __type(name: "Audit") { name }
+		user: __type(name: "User") { fields { name } }
+		__schema { queryType { fields { name } } }
+	}`)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"audit": nil,
+		"user":  map[string]interface{}{"fields": []interface{}{map[string]interface{}{"name": "name"}}},
+		"__schema": map[string]interface{}{"queryType": map[string]interface{}{"fields": []interface{}{
+			map[string]interface{}{"name": "user"},
+		}}},
+	}, res)
+	res, err = execute(employeeCtx, `{ audit: __type(name: "Audit") { name } }`)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"audit": map[string]interface{}{"name": "Audit"}}, res)
+}
+
+type visibilityFilter struct {
+	Name           string
+	IncludeDeleted *bool
+}
+
+func TestVisibilityHookInputFields(t *testing.T) {
+	builder := schemabuilder.NewSchema()
+	query := builder.Query()
+	query.FieldFunc("users", func(args struct{ Filter *visibilityFilter }) []string {
+		if args.Filter != nil && args.Filter.IncludeDeleted != nil && *args.Filter.IncludeDeleted {
+			return []string{"alice", "bob"}
+		}
+		return []string{"alice"}
+	})
+	schema := builder.MustBuild()
+	introspection.AddIntrospectionToSchema(schema)
+
+	hook := func(ctx context.Context, typeName string, fieldName string) bool {
+		return ctx.Value(internalCallerKey{}) != nil || fieldName != "includeDeleted"
+	}
+	ctx := graphql.WithVisibilityHook(context.Background(), hook)
+	employeeCtx := graphql.WithVisibilityHook(context.WithValue(context.Background(), internalCallerKey{}, true), hook)
+
+	execute := func(ctx context.Context, query string, variables map[string]interface{}) (interface{}, error) {
+		q, err := graphql.Parse(query, variables)
+		require.NoError(t, err)
+		if err := graphql.PrepareQuery(ctx, schema.Query, q.SelectionSet); err != nil {
+			return nil, err
+		}
+		e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+		res, err := e.Execute(ctx, schema.Query, nil, q)
+		return internal.AsJSON(res), err
+	}
+
+	res, err := execute(ctx, `{ users(filter: {name: "a"}) }`, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"users": []interface{}{"alice"}}, res)
+	_, err = execute(ctx, `{ users(filter: {includeDeleted: true}) }`, nil)
+	assert.EqualError(t, err, `error parsing args for "users": unknown arg includeDeleted`)
+	_, err = execute(ctx, `query Q($filter: visibilityFilter) { users(filter: $filter) }`, map[string]interface{}{
+		"filter": map[string]interface{}{"name": "a", "includeDeleted": true},
+	})
+	assert.EqualError(t, err, `error parsing args for "users": unknown arg includeDeleted`)
+	res, err = execute(employeeCtx, `query Q($filter: visibilityFilter) { users(filter: $filter) }`, map[string]interface{}{
+		"filter": map[string]interface{}{"name": "a", "includeDeleted": true},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"users": []interface{}{"alice", "bob"}}, res)
+
+	// Hidden input fields are left out of introspection.
+	inputFields := func(ctx context.Context) interface{} {
+		res, err := execute(ctx, `{ __type(name: "visibilityFilter_InputObject") { inputFields { name } } }`, nil)
+		require.NoError(t, err)
+		return res
+	}
+	assert.Equal(t, map[string]interface{}{"__type": map[string]interface{}{"inputFields": []interface{}{
+		map[string]interface{}{"name": "name"},
+	}}}, inputFields(ctx))
+	assert.Equal(t, map[string]interface{}{"__type": map[string]interface{}{"inputFields": []interface{}{
+		map[string]interface{}{"name": "includeDeleted"},
+		map[string]interface{}{"name": "name"},
+	}}}, inputFields(employeeCtx))
+}
@@ -0,0 +1,194 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"runtime/pprof"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SlowResolver describes a resolver that has been running for longer than the
+// threshold of a slow resolver watchdog.
+type SlowResolver struct {
+	// Type is the name of the object type of the field.
+	Type string
+	// Field is the name of the field.
+	Field string
+	// Threshold is the watchdog threshold that the resolver exceeded.
+	Threshold time.Duration
+	// Stack is the stack trace of the goroutine running the resolver shortly
+	// after it exceeded the threshold. It is only set with WithGoroutineDump.
+	Stack []byte
+}
+
+// watchdog reports resolvers that exceed a latency threshold. Instead of a
+// timer for every resolver, a single goroutine scans the resolvers in flight
+// while there are any.
+type watchdog struct {
+	threshold     time.Duration
+	report        func(ctx context.Context, slow *SlowResolver)
+	goroutineDump bool
+
+	mu sync.Mutex
+	// calls holds the resolvers in flight, and monitoring is true while a
+	// goroutine scans them.
+	calls      map[*watchedCall]struct{}
+	monitoring bool
+	nextID     uint64
+}
+
+// watchedCall is a resolver in flight.
+type watchedCall struct {
+	ctx   context.Context
+	unit  *WorkUnit
+	start time.Time
+	// label identifies the goroutine running the resolver in goroutine
+	// profiles, if the watchdog dumps goroutines.
+	label    string
+	reported bool
+}
+
+type watchdogKey struct{}
+
+// watchdogLabel is the profiler label identifying the goroutines of watched
+// resolvers.
+const watchdogLabel = "graphql_watchdog"
+
+// WatchdogOption configures WithSlowResolverWatchdog.
+type WatchdogOption func(*watchdog)
+
+// WithGoroutineDump captures the stack trace of slow resolvers, which shows
+// where they are blocked. Resolvers are labeled for the profiler, and the
+// stacks are taken from a single goroutine profile whenever resolvers exceed
+// the threshold. A goroutine profile briefly stops the world, so the
+// threshold should be well above the latency of healthy resolvers.
+func WithGoroutineDump() WatchdogOption {
+	return func(w *watchdog) {
+		w.goroutineDump = true
+	}
+}
+
+// WithSlowResolverWatchdog calls report for every resolver that runs for
+// longer than threshold. The report happens shortly after the threshold is
+// exceeded, while the resolver is still running, so that deadlocked resolvers
+// and hanging external calls are reported too. report can forward slow
+// resolvers to metrics or error reporting. Reports are made one at a time,
+// so report should not block.
+func WithSlowResolverWatchdog(threshold time.Duration, report func(ctx context.Context, slow *SlowResolver), opts ...WatchdogOption) ExecutorOption {
+	w := &watchdog{
+		threshold: threshold,
+		report:    report,
+		calls:     make(map[*watchedCall]struct{}),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return func(e *Executor) {
+		e.watchdog = w
+	}
+}
+
+// watchResolver starts watching the resolver of unit if the executor has a
+// watchdog, and returns a function that must be called once the resolver
+// finishes. It must be called on the goroutine running the resolver.
+func watchResolver(ctx context.Context, unit *WorkUnit) func() {
+	w, ok := ctx.Value(watchdogKey{}).(*watchdog)
+	if !ok {
+		return func() {}
+	}
+
+	call := &watchedCall{ctx: ctx, unit: unit, start: time.Now()}
+	w.mu.Lock()
+	if w.goroutineDump {
+		w.nextID++
+		call.label = strconv.FormatUint(w.nextID, 10)
+	}
+	w.calls[call] = struct{}{}
+	if !w.monitoring {
+		w.monitoring = true
+		go w.monitor()
+	}
+	w.mu.Unlock()
+
+	if call.label != "" {
+		pprof.SetGoroutineLabels(pprof.WithLabels(ctx, pprof.Labels(watchdogLabel, call.label)))
+	}
+	return func() {
+		if call.label != "" {
+			pprof.SetGoroutineLabels(ctx)
+		}
+		w.mu.Lock()
+		delete(w.calls, call)
+		w.mu.Unlock()
+	}
+}
+
+// monitor reports the resolvers in flight that exceed the threshold, until
+// none are in flight.
+func (w *watchdog) monitor() {
+	interval := w.threshold / 10
+	if interval < time.Millisecond {
+		interval = time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		now := time.Now()
+		var slow []*watchedCall
+		w.mu.Lock()
+		if len(w.calls) == 0 {
+			w.monitoring = false
+			w.mu.Unlock()
+			return
+		}
+		for call := range w.calls {
+			if !call.reported && now.Sub(call.start) >= w.threshold {
+				call.reported = true
+				slow = append(slow, call)
+			}
+		}
+		w.mu.Unlock()
+		if len(slow) == 0 {
+			continue
+		}
+
+		var stacks map[string][]byte
+		if w.goroutineDump {
+			stacks = labeledStacks()
+		}
+		for _, call := range slow {
+			w.report(call.ctx, &SlowResolver{
+				Type:      call.unit.objectName,
+				Field:     call.unit.selection.Name,
+				Threshold: w.threshold,
+				Stack:     stacks[call.label],
+			})
+		}
+	}
+}
+
+// labeledStacks returns the stack traces of the goroutines of watched
+// resolvers, by label, from a goroutine profile.
+func labeledStacks() map[string][]byte {
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 1); err != nil {
+		return nil
+	}
+
+	prefix := []byte(strconv.Quote(watchdogLabel) + `:"`)
+	stacks := make(map[string][]byte)
+	for _, stack := range bytes.Split(buf.Bytes(), []byte("\n\n")) {
+		i := bytes.Index(stack, prefix)
+		if i < 0 {
+			continue
+		}
+		label := stack[i+len(prefix):]
+		if end := bytes.IndexByte(label, '"'); end >= 0 {
+			stacks[string(label[:end])] = stack
+		}
+	}
+	return stacks
+}
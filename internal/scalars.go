@@ -0,0 +1,40 @@
+package internal
+
+import (
+	"reflect"
+	"time"
+)
+
+// Scalar is a scalar built in to schemabuilder.
+type Scalar struct {
+	// Name is the name of the scalar in the schema.
+	Name string
+	// Type is the Go type exposed as the scalar, or nil if schemabuilder
+	// matches the scalar's Go types itself, as for JSON.
+	Type reflect.Type
+	// TypeScript is the TypeScript type of the scalar's JSON encoding.
+	TypeScript string
+}
+
+// Scalars are the scalars built in to schemabuilder. Both schemabuilder and
+// graphql.PrintTypeScript derive their scalars from it, so they cannot drift
+// apart.
+var Scalars = []Scalar{
+	{Name: "bool", Type: reflect.TypeOf(bool(false)), TypeScript: "boolean"},
+	{Name: "int", Type: reflect.TypeOf(int(0)), TypeScript: "number"},
+	{Name: "int8", Type: reflect.TypeOf(int8(0)), TypeScript: "number"},
+	{Name: "int16", Type: reflect.TypeOf(int16(0)), TypeScript: "number"},
+	{Name: "int32", Type: reflect.TypeOf(int32(0)), TypeScript: "number"},
+	{Name: "int64", Type: reflect.TypeOf(int64(0)), TypeScript: "number"},
+	{Name: "uint", Type: reflect.TypeOf(uint(0)), TypeScript: "number"},
+	{Name: "uint8", Type: reflect.TypeOf(uint8(0)), TypeScript: "number"},
+	{Name: "uint16", Type: reflect.TypeOf(uint16(0)), TypeScript: "number"},
+	{Name: "uint32", Type: reflect.TypeOf(uint32(0)), TypeScript: "number"},
+	{Name: "uint64", Type: reflect.TypeOf(uint64(0)), TypeScript: "number"},
+	{Name: "float32", Type: reflect.TypeOf(float32(0)), TypeScript: "number"},
+	{Name: "float64", Type: reflect.TypeOf(float64(0)), TypeScript: "number"},
+	{Name: "string", Type: reflect.TypeOf(string("")), TypeScript: "string"},
+	{Name: "Time", Type: reflect.TypeOf(time.Time{}), TypeScript: "string"},
+	{Name: "bytes", Type: reflect.TypeOf([]byte{}), TypeScript: "string"},
+	{Name: "JSON", TypeScript: "any"},
+}
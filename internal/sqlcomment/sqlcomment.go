@@ -0,0 +1,68 @@
+// Package sqlcomment carries the tags of the SQL comments attributing queries
+// to their origin, such as a GraphQL operation, in a context. It is shared by
+// the packages setting tags and sqlgen, which renders them.
+package sqlcomment
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+type tagsKey struct{}
+
+// tag is a key-value pair in a linked list of tags, newest first.
+type tag struct {
+	parent     *tag
+	key, value string
+}
+
+// With returns a context whose queries are tagged with key and value,
+// replacing any earlier value for key.
+func With(ctx context.Context, key, value string) context.Context {
+	parent, _ := ctx.Value(tagsKey{}).(*tag)
+	return context.WithValue(ctx, tagsKey{}, &tag{parent: parent, key: key, value: value})
+}
+
+// Tags returns the tags of ctx, or nil if it has none.
+func Tags(ctx context.Context) map[string]string {
+	t, _ := ctx.Value(tagsKey{}).(*tag)
+	if t == nil {
+		return nil
+	}
+	tags := make(map[string]string)
+	for ; t != nil; t = t.parent {
+		if _, ok := tags[t.key]; !ok {
+			tags[t.key] = t.value
+		}
+	}
+	return tags
+}
+
+// escape URL-encodes s as required by the sqlcommenter format, which also
+// escapes quotes and the end of comments.
+func escape(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
+
+// Append returns query with the tags of ctx appended as a comment in the
+// sqlcommenter format, such as
+//
+//	SELECT * FROM users /*graphql_operation='Users',request_id='1'*/
+//
+// Queries that already have a comment, and queries without tags, are
+// returned unchanged.
+func Append(ctx context.Context, query string) string {
+	tags := Tags(ctx)
+	if len(tags) == 0 || strings.Contains(query, "/*") {
+		return query
+	}
+
+	pairs := make([]string, 0, len(tags))
+	for key, value := range tags {
+		pairs = append(pairs, escape(key)+"='"+escape(value)+"'")
+	}
+	sort.Strings(pairs)
+	return query + " /*" + strings.Join(pairs, ",") + "*/"
+}
@@ -0,0 +1,24 @@
+package sqlcomment_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/denkhaus/thunder/internal/sqlcomment"
+)
+
+func TestAppend(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, "SELECT 1", sqlcomment.Append(ctx, "SELECT 1"))
+
+	ctx = sqlcomment.With(ctx, "route", "old")
+	ctx = sqlcomment.With(ctx, "b key", "it's */ done")
+	ctx = sqlcomment.With(ctx, "route", "Users")
+	assert.Equal(t, map[string]string{"route": "Users", "b key": "it's */ done"}, sqlcomment.Tags(ctx))
+	assert.Equal(t, "SELECT 1 /*b%20key='it%27s%20%2A%2F%20done',route='Users'*/", sqlcomment.Append(ctx, "SELECT 1"))
+
+	// Statements with comments are left alone.
+	assert.Equal(t, "SELECT /* hint */ 1", sqlcomment.Append(ctx, "SELECT /* hint */ 1"))
+}
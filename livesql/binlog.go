@@ -40,6 +40,14 @@ type Binlog struct {
 	columnMaps map[string]*columnMap
 	closed     bool
 
+	// tableAllowlist and columnAllowlist restrict which tables and columns
+	// are decoded. A nil map allows everything.
+	tableAllowlist  map[string]bool
+	columnAllowlist map[string]map[string]bool
+
+	statsMu sync.Mutex
+	stats   map[string]*BinlogTableStats
+
 	logger logger.Logger
 }
 
@@ -137,6 +145,7 @@ func NewBinlogWithSource(ldb *LiveDB, sourceDB *sql.DB, host string, port uint16
 		streamer:      streamer,
 		tableVersions: make(map[string]uint64),
 		columnMaps:    make(map[string]*columnMap),
+		stats:         make(map[string]*BinlogTableStats),
 
 		logger: logger.New(),
 	}, nil
@@ -178,12 +187,19 @@ func fetchColumns(conn *sql.DB, database string, table string) ([]string, error)
 
 // buildColumnMap constructs a columnMap from column information fetched from
 // the database
-func buildColumnMap(conn *sql.DB, database string, table *sqlgen.Table) (*columnMap, error) {
+//
+// If allowed is non-nil, only the columns in allowed are mapped.
+func buildColumnMap(conn *sql.DB, database string, table *sqlgen.Table, allowed map[string]bool) (*columnMap, error) {
 	columns, err := fetchColumns(conn, database, table.Name)
 	if err != nil {
 		return nil, err
 	}
+	return newColumnMap(columns, table, allowed), nil
+}
 
+// newColumnMap constructs a columnMap mapping the MySQL columns to the
+// table's columns
+func newColumnMap(columns []string, table *sqlgen.Table, allowed map[string]bool) *columnMap {
 	columnMap := &columnMap{
 		expectedColumns: len(columns),
 	}
@@ -194,14 +210,15 @@ func buildColumnMap(conn *sql.DB, database string, table *sqlgen.Table) (*column
 	}
 
 	for _, column := range table.Columns {
-		if idx, ok := columnIndex[column.Name]; ok {
+		idx, ok := columnIndex[column.Name]
+		if ok && (allowed == nil || allowed[column.Name]) {
 			columnMap.source = append(columnMap.source, idx)
 		} else {
 			columnMap.source = append(columnMap.source, -1)
 		}
 	}
 
-	return columnMap, nil
+	return columnMap
 }
 
 // parseBinlogRow parses a binlog row into a struct
@@ -225,9 +242,13 @@ func parseBinlogRow(table *sqlgen.Table, binlogRow []interface{}, columnMap *col
 		if field.Kind() != reflect.Ptr {
 			field = field.Addr()
 		}
+		value, err := table.Columns[i].Decrypt(binlogRow[j])
+		if err != nil {
+			return nil, fmt.Errorf("binlog: `%s`.`%s` error: %v", table.Name, table.Columns[i].Name, err)
+		}
 		scanner := scanners[i].(*fields.Scanner)
 		scanner.Target(field)
-		if err := scanner.Scan(binlogRow[j]); err != nil {
+		if err := scanner.Scan(value); err != nil {
 			return nil, fmt.Errorf("binlog: `%s`.`%s` error: %v", table.Name, table.Columns[i].Name, err)
 		}
 	}
@@ -242,7 +263,7 @@ func (b *Binlog) getColumnMap(table *sqlgen.Table) (*columnMap, error) {
 		return columnMap, nil
 	}
 
-	columnMap, err := buildColumnMap(b.db.Conn, b.database, table)
+	columnMap, err := buildColumnMap(b.db.Conn, b.database, table, b.columnAllowlist[table.Name])
 	if err != nil {
 		return nil, err
 	}
@@ -344,6 +365,37 @@ func (b *Binlog) parseBinlogRowsEvent(event *replication.BinlogEvent) (*update,
 	return update, nil
 }
 
+// SetTableAllowlist restricts the binlog to the given tables. Rows events for
+// other tables are skipped before they are decoded into structs or passed on
+// to live queries, which saves work for high-volume tables that no live query
+// depends on. The tables should be registered in the DB's schema.
+//
+// SetTableAllowlist must be called before RunPollLoop.
+func (b *Binlog) SetTableAllowlist(tables ...string) {
+	b.tableAllowlist = make(map[string]bool, len(tables))
+	for _, table := range tables {
+		b.tableAllowlist[table] = true
+	}
+}
+
+// SetColumnAllowlist restricts the decoded columns of table to the given
+// columns. Other columns are left as zero values in the rows passed to live
+// queries, so the allowlist must include all columns that live queries on
+// table filter on.
+//
+// SetColumnAllowlist must be called before RunPollLoop.
+func (b *Binlog) SetColumnAllowlist(table string, columns ...string) {
+	if b.columnAllowlist == nil {
+		b.columnAllowlist = make(map[string]map[string]bool)
+	}
+	allowed := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		allowed[column] = true
+	}
+	b.columnAllowlist[table] = allowed
+	delete(b.columnMaps, table)
+}
+
 // SetOutput sets the destination for the error logger.
 func (b *Binlog) SetLogger(l logger.Logger) {
 	b.logger = l
@@ -365,7 +417,7 @@ func (b *Binlog) RunPollLoop() error {
 	go func() {
 		for du := range updateCh {
 			time.Sleep(du.applyAfter.Sub(time.Now()))
-			b.tracker.processBinlog(du.update)
+			b.tracker.processUpdate(du.update)
 		}
 	}()
 
@@ -386,8 +438,16 @@ func (b *Binlog) RunPollLoop() error {
 				continue
 			}
 
+			table := string(inner.Table.Table)
+			if b.tableAllowlist != nil && !b.tableAllowlist[table] {
+				b.recordSkipped(table)
+				continue
+			}
+
+			start := time.Now()
 			u, err := b.parseBinlogRowsEvent(event)
 			if err == errNoDescriptor {
+				b.recordSkipped(table)
 				continue
 			} else if err != nil && err.Error() == "sql: database is closed" {
 				continue
@@ -395,6 +455,7 @@ func (b *Binlog) RunPollLoop() error {
 				b.logger.Error("livesql: failed to parse rows event", "error", err)
 				continue
 			}
+			b.recordDecoded(table, len(inner.Rows), time.Since(start))
 
 			b.delayMu.Lock()
 			delay := b.delay
@@ -0,0 +1,62 @@
+package livesql
+
+import (
+	"time"
+)
+
+// BinlogTableStats describes the binlog rows events seen for a table.
+type BinlogTableStats struct {
+	// Events is the number of rows events decoded for the table.
+	Events int64
+	// Rows is the number of rows decoded for the table. Updates count as two
+	// rows, one before and one after the update.
+	Rows int64
+	// DecodeTime is the total time spent decoding rows into structs.
+	DecodeTime time.Duration
+	// SkippedEvents is the number of rows events skipped because the table is
+	// not registered or not in the table allowlist.
+	SkippedEvents int64
+}
+
+// recordDecoded adds a decoded rows event to the stats of table.
+func (b *Binlog) recordDecoded(table string, rows int, d time.Duration) {
+	b.statsMu.Lock()
+	defer b.statsMu.Unlock()
+
+	stats := b.tableStats(table)
+	stats.Events++
+	stats.Rows += int64(rows)
+	stats.DecodeTime += d
+}
+
+// recordSkipped adds a skipped rows event to the stats of table.
+func (b *Binlog) recordSkipped(table string) {
+	b.statsMu.Lock()
+	defer b.statsMu.Unlock()
+
+	b.tableStats(table).SkippedEvents++
+}
+
+// tableStats returns the stats of table. b.statsMu must be held.
+func (b *Binlog) tableStats(table string) *BinlogTableStats {
+	stats, ok := b.stats[table]
+	if !ok {
+		stats = &BinlogTableStats{}
+		b.stats[table] = stats
+	}
+	return stats
+}
+
+// Stats returns a snapshot of the decode stats of every table seen in the
+// binlog, keyed by table name. Comparing the stats between two snapshots
+// shows which tables spend the most time in binlog decoding.
+func (b *Binlog) Stats() map[string]BinlogTableStats {
+	b.statsMu.Lock()
+	defer b.statsMu.Unlock()
+
+	stats := make(map[string]BinlogTableStats, len(b.stats))
+	for table, s := range b.stats {
+		stats[table] = *s
+	}
+	return stats
+}
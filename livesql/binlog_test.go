@@ -0,0 +1,37 @@
+package livesql
+
+import (
+	"testing"
+
+	"github.com/denkhaus/thunder/sqlgen"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBinlogRowColumnAllowlist(t *testing.T) {
+	type user struct {
+		Id   int64 `sql:",primary"`
+		Name string
+		Bio  string
+	}
+
+	schema := sqlgen.NewSchema()
+	schema.MustRegisterType("users", sqlgen.AutoIncrement, user{})
+	table := schema.ByName["users"]
+
+	// MySQL orders the columns differently than the struct.
+	columns := []string{"bio", "id", "name"}
+	binlogRow := []interface{}{"long text", int64(1), "alice"}
+
+	row, err := parseBinlogRow(table, binlogRow, newColumnMap(columns, table, nil))
+	require.NoError(t, err)
+	assert.Equal(t, &user{Id: 1, Name: "alice", Bio: "long text"}, row)
+
+	allowed := map[string]bool{"id": true, "name": true}
+	row, err = parseBinlogRow(table, binlogRow, newColumnMap(columns, table, allowed))
+	require.NoError(t, err)
+	assert.Equal(t, &user{Id: 1, Name: "alice"}, row)
+
+	_, err = parseBinlogRow(table, binlogRow[:2], newColumnMap(columns, table, allowed))
+	assert.Error(t, err)
+}
@@ -0,0 +1,206 @@
+package livesql
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// HeartbeatRow is a row of the heartbeat table written by RunHeartbeat. The
+// table must be registered in the schema of the LiveDB with
+// sqlgen.UniqueId, eg.
+//
+//   schema.MustRegisterType("heartbeats", sqlgen.UniqueId, livesql.HeartbeatRow{})
+//
+// and created with
+//
+//   CREATE TABLE heartbeats (
+//     source VARCHAR(255) NOT NULL PRIMARY KEY,
+//     sent   BIGINT NOT NULL
+//   )
+type HeartbeatRow struct {
+	// Source identifies the writer, so that every server has its own row.
+	Source string `sql:",primary"`
+	// Sent is when the row was written, in nanoseconds since the Unix epoch.
+	Sent int64
+}
+
+// HeartbeatConfig configures RunHeartbeat.
+type HeartbeatConfig struct {
+	// Table is the name of the heartbeat table, see HeartbeatRow.
+	Table string
+	// Source is the source of the written rows. It defaults to the hostname.
+	Source string
+	// Interval is the time between heartbeats.
+	Interval time.Duration
+	// Threshold is the latency above which OnSlow is called. Zero disables
+	// alerting.
+	Threshold time.Duration
+	// Report, if set, is called with the latency of every heartbeat, to export
+	// it as a metric.
+	Report func(latency time.Duration)
+	// OnSlow, if set, is called when a heartbeat arrives after Threshold, and
+	// at every interval while a heartbeat is outstanding for longer than
+	// Threshold, eg. because the binlog stalled, with the time since it was
+	// written.
+	OnSlow func(latency time.Duration)
+}
+
+// maxOutstandingHeartbeats bounds the heartbeats tracked by RunHeartbeat
+// while the binlog stalls. The oldest ones are dropped first, but still count
+// towards the latency reported by OnSlow.
+const maxOutstandingHeartbeats = 100
+
+// heartbeat tracks the outstanding heartbeats of RunHeartbeat.
+type heartbeat struct {
+	table  string
+	source string
+	config HeartbeatConfig
+
+	mu sync.Mutex
+	// outstanding are the written heartbeats that did not arrive yet, oldest
+	// first.
+	outstanding []sentHeartbeat
+	// since is when the oldest outstanding heartbeat was written, or zero if
+	// there is none.
+	since time.Time
+	// alerted is true if OnSlow was called for the oldest outstanding
+	// heartbeat.
+	alerted bool
+}
+
+// sentHeartbeat is a heartbeat written by RunHeartbeat.
+type sentHeartbeat struct {
+	sent   int64
+	sentAt time.Time
+}
+
+// next returns the next heartbeat to write at now. It alerts if the oldest
+// outstanding heartbeat is past the threshold.
+func (h *heartbeat) next(now time.Time) *HeartbeatRow {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.since.IsZero() {
+		if latency := now.Sub(h.since); h.config.Threshold > 0 && latency > h.config.Threshold {
+			h.alerted = true
+			if h.config.OnSlow != nil {
+				h.config.OnSlow(latency)
+			}
+		}
+	} else {
+		h.since = now
+		h.alerted = false
+	}
+
+	h.outstanding = append(h.outstanding, sentHeartbeat{sent: now.UnixNano(), sentAt: now})
+	if len(h.outstanding) > maxOutstandingHeartbeats {
+		h.outstanding = h.outstanding[1:]
+	}
+	return &HeartbeatRow{Source: h.source, Sent: now.UnixNano()}
+}
+
+// observe completes an outstanding heartbeat if update invalidates it.
+// Heartbeats written before it are lost, as the binlog is ordered.
+func (h *heartbeat) observe(update *update, now time.Time) {
+	if update.table != h.table {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, d := range update.deltas {
+		row, ok := d.after.(*HeartbeatRow)
+		if !ok || row.Source != h.source {
+			continue
+		}
+
+		for i, sent := range h.outstanding {
+			if sent.sent != row.Sent {
+				continue
+			}
+
+			latency := now.Sub(sent.sentAt)
+			if h.config.Report != nil {
+				h.config.Report(latency)
+			}
+			if h.config.Threshold > 0 && latency > h.config.Threshold && !h.alerted && h.config.OnSlow != nil {
+				h.config.OnSlow(latency)
+			}
+
+			h.outstanding = h.outstanding[i+1:]
+			h.since = time.Time{}
+			if len(h.outstanding) > 0 {
+				h.since = h.outstanding[0].sentAt
+			}
+			h.alerted = false
+			return
+		}
+	}
+}
+
+// RunHeartbeat measures the end-to-end latency of live queries: every
+// interval, it writes a row to the heartbeat table, bypassing the
+// invalidations of writes through LiveDB, and measures the time until the
+// binlog invalidates live queries with the row. The latency includes
+// replication, binlog decoding and the update delay of the binlog.
+//
+// A heartbeat is written every interval, even while earlier ones are
+// outstanding, so that a heartbeat lost by the binlog does not stall the
+// measurement. Heartbeats written before one that arrived are considered
+// lost. While heartbeats are outstanding, OnSlow reports the time since the
+// oldest one was written.
+//
+// RunHeartbeat blocks until ctx is done, or writing a heartbeat fails.
+func (ldb *LiveDB) RunHeartbeat(ctx context.Context, config HeartbeatConfig) error {
+	table, ok := ldb.Schema.ByName[config.Table]
+	if !ok {
+		return fmt.Errorf("heartbeat table %s is not registered", config.Table)
+	}
+	if table.Type != reflect.TypeOf(HeartbeatRow{}) {
+		return fmt.Errorf("heartbeat table %s must be registered with livesql.HeartbeatRow, not %s", config.Table, table.Type)
+	}
+	if config.Interval <= 0 {
+		return fmt.Errorf("heartbeat interval must be positive")
+	}
+
+	source := config.Source
+	if source == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return fmt.Errorf("error reading hostname for heartbeat source: %s", err)
+		}
+		source = hostname
+	}
+
+	h := &heartbeat{
+		table:  config.Table,
+		source: source,
+		config: config,
+	}
+	ldb.tracker.addHeartbeat(h)
+	defer ldb.tracker.removeHeartbeat(h)
+
+	ticker := time.NewTicker(config.Interval)
+	defer ticker.Stop()
+	for {
+		if row := h.next(time.Now()); row != nil {
+			if _, err := ldb.DB.UpsertRow(ctx, row); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return fmt.Errorf("error writing heartbeat: %s", err)
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
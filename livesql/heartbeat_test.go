@@ -0,0 +1,81 @@
+package livesql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeartbeat(t *testing.T) {
+	var latencies, slow []time.Duration
+	h := &heartbeat{
+		table:  "heartbeats",
+		source: "a",
+		config: HeartbeatConfig{
+			Threshold: 5 * time.Second,
+			Report:    func(latency time.Duration) { latencies = append(latencies, latency) },
+			OnSlow:    func(latency time.Duration) { slow = append(slow, latency) },
+		},
+	}
+	tracker := newDbTracker()
+	tracker.addHeartbeat(h)
+
+	start := time.Now()
+	row := h.next(start)
+	assert.Equal(t, &HeartbeatRow{Source: "a", Sent: start.UnixNano()}, row)
+
+	// Heartbeats of other sources and other tables are ignored.
+	h.observe(&update{table: "heartbeats", deltas: []delta{{after: &HeartbeatRow{Source: "b", Sent: row.Sent}}}}, start.Add(time.Second))
+	h.observe(&update{table: "users", deltas: []delta{{after: row}}}, start.Add(time.Second))
+	assert.Empty(t, latencies)
+
+	h.observe(&update{table: "heartbeats", deltas: []delta{{before: &HeartbeatRow{Source: "a"}, after: row}}}, start.Add(2*time.Second))
+	assert.Equal(t, []time.Duration{2 * time.Second}, latencies)
+	assert.Empty(t, slow)
+
+	// Outstanding heartbeats alert at every interval past the threshold of
+	// the oldest one, and once more when it finally arrives only if it did
+	// not alert before.
+	start = start.Add(10 * time.Second)
+	row = h.next(start)
+	h.next(start.Add(4 * time.Second))
+	h.next(start.Add(6 * time.Second))
+	h.next(start.Add(8 * time.Second))
+	assert.Equal(t, []time.Duration{6 * time.Second, 8 * time.Second}, slow)
+
+	tracker.processUpdate(&update{table: "heartbeats", deltas: []delta{{after: row}}})
+	assert.Len(t, latencies, 2)
+	assert.Len(t, slow, 2)
+
+	// Heartbeats keep being written after one is lost. A later heartbeat
+	// completes the lost ones, and the lag is measured from the oldest one
+	// still outstanding.
+	h.outstanding, h.since = nil, time.Time{}
+	start = start.Add(20 * time.Second)
+	lost := h.next(start)
+	row = h.next(start.Add(time.Second))
+	next := h.next(start.Add(2 * time.Second))
+	assert.NotEqual(t, lost.Sent, row.Sent)
+	h.observe(&update{table: "heartbeats", deltas: []delta{{after: row}}}, start.Add(3*time.Second))
+	assert.Equal(t, 2*time.Second, latencies[2])
+	last := h.next(start.Add(8 * time.Second))
+	assert.Equal(t, 6*time.Second, slow[2])
+	assert.NotEqual(t, next.Sent, last.Sent)
+	h.observe(&update{table: "heartbeats", deltas: []delta{{after: last}}}, start.Add(9*time.Second))
+	assert.Equal(t, time.Second, latencies[3])
+	assert.Len(t, slow, 3)
+	assert.Empty(t, h.outstanding)
+
+	start = start.Add(20 * time.Second)
+	row = h.next(start)
+	h.observe(&update{table: "heartbeats", deltas: []delta{{after: row}}}, start.Add(7*time.Second))
+	assert.Equal(t, 7*time.Second, latencies[4])
+	assert.Equal(t, []time.Duration{6 * time.Second, 8 * time.Second, 6 * time.Second, 7 * time.Second}, slow)
+
+	// Removed heartbeats are no longer completed.
+	tracker.removeHeartbeat(h)
+	row = h.next(start.Add(time.Minute))
+	tracker.processUpdate(&update{table: "heartbeats", deltas: []delta{{after: row}}})
+	assert.Len(t, latencies, 5)
+}
@@ -7,6 +7,10 @@ import (
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/denkhaus/thunder/batch"
+	"github.com/denkhaus/thunder/diff"
+	"github.com/denkhaus/thunder/graphql"
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+	"github.com/denkhaus/thunder/internal"
 	"github.com/denkhaus/thunder/internal/testfixtures"
 	"github.com/denkhaus/thunder/livesql"
 	"github.com/denkhaus/thunder/reactive"
@@ -186,3 +190,87 @@ func TestIntegrationFilterCustomType(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, (*User)(nil), <-users)
 }
+
+type OwnedCat struct {
+	Id      int64 `sql:",primary"`
+	OwnerId int64
+	Name    string
+}
+
+func TestIntegrationRowsSubscription(t *testing.T) {
+	config := testfixtures.DefaultDBConfig
+	schema := sqlgen.NewSchema()
+	schema.MustRegisterType("cats", sqlgen.AutoIncrement, OwnedCat{})
+	testDb, err := testfixtures.NewTestDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer testDb.Close()
+
+	_, err = testDb.DB.Exec(`
+               CREATE TABLE cats (
+                       id       BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+                       owner_id BIGINT NOT NULL,
+                       name     VARCHAR(255)
+               )
+       `)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := livesql.Open(config.Hostname, config.Port, config.Username, config.Password, testDb.DBName, schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.InsertRow(context.Background(), &OwnedCat{OwnerId: 1, Name: "tom"})
+	assert.NoError(t, err)
+	_, err = db.InsertRow(context.Background(), &OwnedCat{OwnerId: 2, Name: "garfield"})
+	assert.NoError(t, err)
+
+	gqlSchema := schemabuilder.NewSchema()
+	gqlSchema.Object("Cat", OwnedCat{}).Key("id")
+	gqlSchema.Query().FieldFunc("cats", db.MustRowsSubscription(&OwnedCat{},
+		func(ctx context.Context, args struct{ OwnerId int64 }) (sqlgen.Filter, error) {
+			return sqlgen.Filter{"owner_id": args.OwnerId}, nil
+		}, &sqlgen.SelectOptions{OrderBy: "id"}))
+	gqlSchema.Mutation()
+	builtSchema := gqlSchema.MustBuild()
+
+	q := graphql.MustParse(`{ cats(ownerId: 1) { name } }`, nil)
+	if err := graphql.PrepareQuery(context.Background(), builtSchema.Query, q.SelectionSet); err != nil {
+		t.Fatal(err)
+	}
+
+	// We use a channel to pass around subscription updates for testing.
+	results := make(chan interface{})
+	ctx := batch.WithBatching(context.Background())
+	rerunner := reactive.NewRerunner(ctx, func(ctx context.Context) (interface{}, error) {
+		e := graphql.NewExecutor(graphql.NewImmediateGoroutineScheduler())
+		result, err := e.Execute(ctx, builtSchema.Query, nil, q)
+		if err != nil {
+			t.Error(err)
+		}
+		results <- internal.AsJSON(result)
+		return nil, nil
+	}, 50*time.Millisecond, false)
+	defer rerunner.Stop()
+
+	// The initial result lists the cats of owner 1.
+	initial := <-results
+	assert.Equal(t, internal.ParseJSON(`{"cats": [{"name": "tom"}]}`), diff.StripKey(initial))
+
+	// Inserting a matching row pushes an update.
+	_, err = db.InsertRow(context.Background(), &OwnedCat{OwnerId: 1, Name: "felix"})
+	assert.NoError(t, err)
+	inserted := <-results
+	assert.Equal(t, internal.ParseJSON(`{"cats": [{"name": "tom"}, {"name": "felix"}]}`), diff.StripKey(inserted))
+	assert.NotNil(t, diff.Diff(initial, inserted))
+
+	// Updating a matching row pushes its new name.
+	err = db.UpdateRow(context.Background(), &OwnedCat{Id: 1, OwnerId: 1, Name: "thomas"})
+	assert.NoError(t, err)
+	updated := <-results
+	assert.Equal(t, internal.ParseJSON(`{"cats": [{"name": "thomas"}, {"name": "felix"}]}`), diff.StripKey(updated))
+	assert.NotNil(t, diff.Diff(inserted, updated))
+}
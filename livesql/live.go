@@ -2,7 +2,10 @@ package livesql
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
 	"sync"
+	"time"
 
 	"github.com/denkhaus/thunder/internal"
 	"github.com/denkhaus/thunder/reactive"
@@ -22,6 +25,11 @@ func (r *dbResource) shouldInvalidate(update *update) bool {
 		return false
 	}
 
+	// Without a tester, any change to the table invalidates the resource.
+	if r.tester == nil {
+		return true
+	}
+
 	// If we failed to parse an update we don't know what happened, so we
 	// invalidate.
 	if update.err != nil {
@@ -39,13 +47,15 @@ func (r *dbResource) shouldInvalidate(update *update) bool {
 
 // dbTracker tracks many dbResources
 type dbTracker struct {
-	mu        sync.Mutex
-	resources map[*dbResource]struct{}
+	mu         sync.Mutex
+	resources  map[*dbResource]struct{}
+	heartbeats map[*heartbeat]struct{}
 }
 
 func newDbTracker() *dbTracker {
 	return &dbTracker{
-		resources: make(map[*dbResource]struct{}),
+		resources:  make(map[*dbResource]struct{}),
+		heartbeats: make(map[*heartbeat]struct{}),
 	}
 }
 
@@ -63,8 +73,23 @@ func (t *dbTracker) remove(r *dbResource) {
 	delete(t.resources, r)
 }
 
-// processBinlog processes a set of updates from the MySQL binlog
-func (t *dbTracker) processBinlog(update *update) {
+func (t *dbTracker) addHeartbeat(h *heartbeat) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.heartbeats[h] = struct{}{}
+}
+
+func (t *dbTracker) removeHeartbeat(h *heartbeat) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.heartbeats, h)
+}
+
+// processUpdate invalidates the resources matching an update from the MySQL
+// binlog or from a write through LiveDB
+func (t *dbTracker) processUpdate(update *update) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
@@ -73,6 +98,13 @@ func (t *dbTracker) processBinlog(update *update) {
 			q.resource.Invalidate()
 		}
 	}
+
+	// Complete heartbeats after invalidating live queries, so they measure
+	// the full latency of invalidations.
+	now := time.Now()
+	for h := range t.heartbeats {
+		h.observe(update, now)
+	}
 }
 
 // QueryDependency represents a dependency on SQL query.
@@ -206,6 +238,72 @@ func (ldb *LiveDB) QueryRow(ctx context.Context, result interface{}, filter sqlg
 	return sqlgen.CopySingletonSlice(result, rows)
 }
 
+// QueryExecer returns a QueryExecer for running hand-written SQL. Raw
+// queries cannot be analyzed, so queries run with a reactive rerunner
+// instead depend on the rows declared on their context with
+// sqlgen.WithDependency, for example:
+//
+//	ctx = sqlgen.WithDependency(ctx, "users", sqlgen.Filter{"team_id": teamID})
+//	row := ldb.QueryExecer(ctx).QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE team_id = ?", teamID)
+//
+// Raw queries without dependencies are not live. Dependencies must name
+// tables registered with the schema, and filter on their columns; raw
+// queries declaring other dependencies fail.
+func (ldb *LiveDB) QueryExecer(ctx context.Context) sqlgen.QueryExecer {
+	return &liveQueryExecer{QueryExecer: ldb.DB.QueryExecer(ctx), ldb: ldb}
+}
+
+// liveQueryExecer registers the dependencies declared on the context of
+// queries before running them.
+type liveQueryExecer struct {
+	sqlgen.QueryExecer
+	ldb *LiveDB
+}
+
+func (e *liveQueryExecer) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if err := e.ldb.registerDependencies(ctx); err != nil {
+		return nil, err
+	}
+	return e.QueryExecer.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext panics if a dependency declared on ctx is invalid, as a
+// *sql.Row cannot carry the error.
+func (e *liveQueryExecer) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	if err := e.ldb.registerDependencies(ctx); err != nil {
+		panic(err)
+	}
+	return e.QueryExecer.QueryRowContext(ctx, query, args...)
+}
+
+// registerDependencies registers the dependencies declared on ctx with
+// sqlgen.WithDependency, like query does for the queries it builds.
+//
+// Dependencies on tables or columns missing from the schema are rejected
+// rather than widened to the whole table: the binlog drops the events of
+// unregistered tables, so such a dependency would never invalidate.
+func (ldb *LiveDB) registerDependencies(ctx context.Context) error {
+	dependencies := sqlgen.Dependencies(ctx)
+	testers := make([]sqlgen.Tester, len(dependencies))
+	for i, dep := range dependencies {
+		tester, err := ldb.Schema.MakeTester(dep.Table, dep.Filter)
+		if err != nil {
+			return fmt.Errorf("dependency on %s: %s", dep.Table, err)
+		}
+		testers[i] = tester
+	}
+
+	if !reactive.HasRerunner(ctx) || ldb.HasTx(ctx) {
+		return nil
+	}
+	for i, dep := range dependencies {
+		if err := ldb.tracker.registerDependency(ctx, ldb.Schema, dep.Table, testers[i], dep.Filter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (ldb *LiveDB) Close() error {
 	return ldb.Conn.Close()
 }
@@ -0,0 +1,72 @@
+package livesql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/reactive"
+	"github.com/denkhaus/thunder/sqlgen"
+)
+
+func TestRawQueryDependencies(t *testing.T) {
+	sqlSchema := sqlgen.NewSchema()
+	sqlSchema.MustRegisterType("cats", sqlgen.AutoIncrement, subscriptionCat{})
+	ldb := NewLiveDB(sqlgen.NewDB(nil, sqlSchema))
+
+	// registerDependencies is called by the QueryExecer of raw queries.
+	runs := make(chan struct{}, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reactive.NewRerunner(ctx, func(ctx context.Context) (interface{}, error) {
+		ctx = sqlgen.WithDependency(ctx, "cats", sqlgen.Filter{"owner_id": int64(1)})
+		ctx = sqlgen.WithDependency(ctx, "cats", sqlgen.Filter{"id": int64(7)})
+		if err := ldb.registerDependencies(ctx); err != nil {
+			return nil, err
+		}
+		runs <- struct{}{}
+		return nil, nil
+	}, 0, false)
+	waitRun := func() bool {
+		select {
+		case <-runs:
+			return true
+		case <-time.After(2 * reactive.WriteThenReadDelay):
+			return false
+		}
+	}
+	require.True(t, waitRun())
+
+	// Changes to rows that do not match the declared filter are ignored.
+	ldb.tracker.processUpdate(&update{table: "cats", deltas: []delta{{after: &subscriptionCat{Id: 1, OwnerId: 2}}}})
+	assert.False(t, waitRun())
+	ldb.tracker.processUpdate(&update{table: "cats", deltas: []delta{{after: &subscriptionCat{Id: 1, OwnerId: 1}}}})
+	assert.True(t, waitRun())
+
+	ldb.tracker.processUpdate(&update{table: "cats", deltas: []delta{{before: &subscriptionCat{Id: 7, OwnerId: 3}}}})
+	assert.True(t, waitRun())
+
+	// Dependencies on unregistered tables or columns are rejected, as their
+	// changes are never seen.
+	assert.EqualError(t, ldb.registerDependencies(sqlgen.WithDependency(ctx, "dogs", nil)), "dependency on dogs: unknown table")
+	assert.EqualError(t, ldb.registerDependencies(sqlgen.WithDependency(ctx, "cats", sqlgen.Filter{"color": "black"})), "dependency on cats: unknown column color")
+	_, err := ldb.QueryExecer(ctx).QueryContext(sqlgen.WithDependency(ctx, "dogs", nil), "SELECT 1")
+	assert.EqualError(t, err, "dependency on dogs: unknown table")
+
+	// Outside of a rerunner, declared dependencies are ignored.
+	require.NoError(t, ldb.registerDependencies(sqlgen.WithDependency(context.Background(), "cats", nil)))
+	// The resources of previous runs are released asynchronously.
+	var resources int
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		ldb.tracker.mu.Lock()
+		resources = len(ldb.tracker.resources)
+		ldb.tracker.mu.Unlock()
+		if resources == 2 {
+			break
+		}
+	}
+	assert.Equal(t, 2, resources)
+}
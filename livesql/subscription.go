@@ -0,0 +1,101 @@
+package livesql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/denkhaus/thunder/sqlgen"
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	filterType  = reflect.TypeOf(sqlgen.Filter(nil))
+)
+
+// RowsSubscription returns a resolver, to be registered with
+// schemabuilder.Object.FieldFunc, that lists the rows of model's table
+// matching a filter.
+//
+// model should be a pointer to a struct, and filter should be a function
+// computing the filter from the field's arguments, or nil to list all rows.
+// For example,
+//
+//	schema.Query().FieldFunc("cats", ldb.MustRowsSubscription(&Cat{},
+//		func(ctx context.Context, args struct{ OwnerId int64 }) (sqlgen.Filter, error) {
+//			return sqlgen.Filter{"owner_id": args.OwnerId}, nil
+//		}, &sqlgen.SelectOptions{OrderBy: "id"}))
+//
+// registers a field cats(ownerId: Int!): [Cat!]!.
+//
+// Clients subscribed to a query with the field receive its initial result
+// and a delta whenever a matching row changes. Subscriptions stop tracking
+// the table when they end. Registering a key on the model's object, such as
+// the primary key, makes deltas of the list name the changed rows instead of
+// resending the rows after an insert or delete.
+func (ldb *LiveDB) RowsSubscription(model interface{}, filter interface{}, options *sqlgen.SelectOptions) (interface{}, error) {
+	modelType := reflect.TypeOf(model)
+	if modelType == nil || modelType.Kind() != reflect.Ptr || modelType.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("subscription model should be a pointer to a struct, got %T", model)
+	}
+	resultType := reflect.SliceOf(modelType)
+	if _, err := ldb.Schema.MakeSelect(reflect.New(resultType).Interface(), nil, nil); err != nil {
+		return nil, err
+	}
+
+	// The resolver takes the same arguments as filter.
+	in := []reflect.Type{contextType}
+	filterFunc := reflect.ValueOf(filter)
+	if filter != nil {
+		typ := filterFunc.Type()
+		if typ.Kind() != reflect.Func || typ.NumIn() < 1 || typ.NumIn() > 2 || typ.In(0) != contextType ||
+			typ.NumOut() != 2 || typ.Out(0) != filterType || typ.Out(1) != errorType {
+			return nil, fmt.Errorf("subscription filter should be a func(context.Context[, args]) (sqlgen.Filter, error), got %T", filter)
+		}
+		if typ.NumIn() == 2 {
+			in = append(in, typ.In(1))
+		}
+	}
+	out := []reflect.Type{resultType, errorType}
+
+	fn := reflect.MakeFunc(reflect.FuncOf(in, out, false), func(in []reflect.Value) []reflect.Value {
+		ctx := in[0].Interface().(context.Context)
+		fail := func(err error) []reflect.Value {
+			return []reflect.Value{reflect.Zero(resultType), reflect.ValueOf(&err).Elem()}
+		}
+
+		var rowFilter sqlgen.Filter
+		if filter != nil {
+			res := filterFunc.Call(in)
+			if err, _ := res[1].Interface().(error); err != nil {
+				return fail(err)
+			}
+			rowFilter = res[0].Interface().(sqlgen.Filter)
+		}
+
+		// Queries modify their options, so use a copy for every query.
+		var queryOptions *sqlgen.SelectOptions
+		if options != nil {
+			copied := *options
+			copied.Values = append([]interface{}(nil), options.Values...)
+			queryOptions = &copied
+		}
+
+		result := reflect.New(resultType)
+		if err := ldb.Query(ctx, result.Interface(), rowFilter, queryOptions); err != nil {
+			return fail(err)
+		}
+		return []reflect.Value{result.Elem(), reflect.Zero(errorType)}
+	})
+	return fn.Interface(), nil
+}
+
+// MustRowsSubscription calls RowsSubscription and panics on error.
+func (ldb *LiveDB) MustRowsSubscription(model interface{}, filter interface{}, options *sqlgen.SelectOptions) interface{} {
+	fn, err := ldb.RowsSubscription(model, filter, options)
+	if err != nil {
+		panic(err)
+	}
+	return fn
+}
@@ -0,0 +1,50 @@
+package livesql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/denkhaus/thunder/graphql/schemabuilder"
+	"github.com/denkhaus/thunder/sqlgen"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type subscriptionCat struct {
+	Id      int64 `sql:",primary"`
+	OwnerId int64
+	Name    string
+}
+
+func TestRowsSubscription(t *testing.T) {
+	sqlSchema := sqlgen.NewSchema()
+	sqlSchema.MustRegisterType("cats", sqlgen.AutoIncrement, subscriptionCat{})
+	ldb := NewLiveDB(sqlgen.NewDB(nil, sqlSchema))
+
+	byOwner := func(ctx context.Context, args struct{ OwnerId int64 }) (sqlgen.Filter, error) {
+		return sqlgen.Filter{"owner_id": args.OwnerId}, nil
+	}
+	fn, err := ldb.RowsSubscription(&subscriptionCat{}, byOwner, nil)
+	require.NoError(t, err)
+	_, ok := fn.(func(context.Context, struct{ OwnerId int64 }) ([]*subscriptionCat, error))
+	assert.True(t, ok, "unexpected resolver type %T", fn)
+
+	fn, err = ldb.RowsSubscription(&subscriptionCat{}, nil, nil)
+	require.NoError(t, err)
+	_, ok = fn.(func(context.Context) ([]*subscriptionCat, error))
+	assert.True(t, ok, "unexpected resolver type %T", fn)
+
+	schema := schemabuilder.NewSchema()
+	schema.Object("Cat", subscriptionCat{}).Key("id")
+	schema.Query().FieldFunc("cats", ldb.MustRowsSubscription(&subscriptionCat{}, byOwner, nil))
+	schema.Query().FieldFunc("allCats", fn)
+	_, err = schema.Build()
+	assert.NoError(t, err)
+
+	_, err = ldb.RowsSubscription(subscriptionCat{}, nil, nil)
+	assert.Error(t, err)
+	_, err = ldb.RowsSubscription(&struct{ Id int64 }{}, nil, nil)
+	assert.Error(t, err)
+	_, err = ldb.RowsSubscription(&subscriptionCat{}, func(args struct{ OwnerId int64 }) sqlgen.Filter { return nil }, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,240 @@
+package livesql
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"sync"
+)
+
+// The write methods below write rows through LiveDB, and invalidate the
+// matching live queries of this LiveDB right away instead of waiting for the
+// binlog. Subscriptions of the writing client then see their own writes with
+// minimal latency, while other servers still rely on the binlog, which also
+// invalidates the queries of this LiveDB again.
+//
+// Only the written row is tested against live queries, so queries that
+// matched a row before an update but not after are only invalidated by the
+// binlog. Writes in a transaction are invalidated when the transaction is
+// committed by RunInTx; writes in other transactions rely on the binlog.
+// Writes undone by rolling back to a savepoint with LiveDB's Savepoint,
+// RollbackTo, and RunInSavepoint are never invalidated. Rolling back with
+// sqlgen.DB's methods instead still invalidates them on commit, which only
+// causes spurious reruns.
+//
+// Bulk writes with UpdateWhere and DeleteWhere never load the changed rows,
+// so they are only invalidated by the binlog, which logs every changed row.
+
+// InsertRow inserts a single row into the database, and invalidates the live
+// queries matching it.
+func (ldb *LiveDB) InsertRow(ctx context.Context, row interface{}) (sql.Result, error) {
+	result, err := ldb.DB.InsertRow(ctx, row)
+	if err != nil {
+		return nil, err
+	}
+	ldb.invalidateWrite(ctx, row, delta{after: row})
+	return result, nil
+}
+
+// UpsertRow inserts or updates a single row in the database, and invalidates
+// the live queries matching it.
+func (ldb *LiveDB) UpsertRow(ctx context.Context, row interface{}) (sql.Result, error) {
+	result, err := ldb.DB.UpsertRow(ctx, row)
+	if err != nil {
+		return nil, err
+	}
+	ldb.invalidateWrite(ctx, row, delta{after: row})
+	return result, nil
+}
+
+// UpdateRow updates a single row in the database, and invalidates the live
+// queries matching its new values.
+func (ldb *LiveDB) UpdateRow(ctx context.Context, row interface{}) error {
+	if err := ldb.DB.UpdateRow(ctx, row); err != nil {
+		return err
+	}
+	ldb.invalidateWrite(ctx, row, delta{after: row})
+	return nil
+}
+
+// DeleteRow deletes a single row from the database, and invalidates the live
+// queries matching it.
+func (ldb *LiveDB) DeleteRow(ctx context.Context, row interface{}) error {
+	if err := ldb.DB.DeleteRow(ctx, row); err != nil {
+		return err
+	}
+	ldb.invalidateWrite(ctx, row, delta{before: row})
+	return nil
+}
+
+// RunInTx runs f in a transaction like sqlgen.DB.RunInTx, and invalidates the
+// live queries matching the rows written through LiveDB in f once the
+// transaction is committed.
+func (ldb *LiveDB) RunInTx(ctx context.Context, f func(ctx context.Context) error) error {
+	var pending *pendingWrites
+	if err := ldb.DB.RunInTx(ctx, func(ctx context.Context) error {
+		// Forget the writes of failed attempts.
+		pending = &pendingWrites{}
+		return f(context.WithValue(ctx, pendingWritesKey{ldb: ldb}, pending))
+	}); err != nil {
+		return err
+	}
+	for _, update := range pending.updates {
+		ldb.tracker.processUpdate(update)
+	}
+	return nil
+}
+
+// pendingWrites holds the updates written in a RunInTx transaction.
+type pendingWrites struct {
+	mu      sync.Mutex
+	updates []*update
+	// savepoints are the savepoints of the transaction, in the order they
+	// were created.
+	savepoints []pendingSavepoint
+}
+
+// pendingSavepoint remembers the updates written before a savepoint.
+type pendingSavepoint struct {
+	name    string
+	updates int
+}
+
+// pendingMark is the state of pendingWrites at some point of a transaction.
+type pendingMark struct {
+	updates    int
+	savepoints int
+}
+
+func (p *pendingWrites) mark() pendingMark {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return pendingMark{updates: len(p.updates), savepoints: len(p.savepoints)}
+}
+
+// reset forgets the updates and savepoints since mark.
+func (p *pendingWrites) reset(mark pendingMark) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.updates = p.updates[:mark.updates]
+	p.savepoints = p.savepoints[:mark.savepoints]
+}
+
+// findSavepoint returns the index of the savepoint called name, or -1.
+func (p *pendingWrites) findSavepoint(name string) int {
+	for i := len(p.savepoints) - 1; i >= 0; i-- {
+		if p.savepoints[i].name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (p *pendingWrites) savepoint(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if i := p.findSavepoint(name); i >= 0 {
+		p.savepoints = append(p.savepoints[:i], p.savepoints[i+1:]...)
+	}
+	p.savepoints = append(p.savepoints, pendingSavepoint{name: name, updates: len(p.updates)})
+}
+
+// rollbackTo forgets the updates since the savepoint called name, and the
+// savepoints created after it.
+func (p *pendingWrites) rollbackTo(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if i := p.findSavepoint(name); i >= 0 {
+		p.updates = p.updates[:p.savepoints[i].updates]
+		p.savepoints = p.savepoints[:i+1]
+	}
+}
+
+// release forgets the savepoint called name, and the savepoints created
+// after it, keeping their updates.
+func (p *pendingWrites) release(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if i := p.findSavepoint(name); i >= 0 {
+		p.savepoints = p.savepoints[:i]
+	}
+}
+
+type pendingWritesKey struct {
+	ldb *LiveDB
+}
+
+// invalidateWrite invalidates the live queries matching d, a write of row, or
+// defers it until the transaction of ctx is committed.
+func (ldb *LiveDB) invalidateWrite(ctx context.Context, row interface{}, d delta) {
+	table, ok := ldb.Schema.ByType[reflect.TypeOf(row).Elem()]
+	if !ok {
+		return
+	}
+	update := &update{table: table.Name, deltas: []delta{d}}
+
+	if !ldb.HasTx(ctx) {
+		ldb.tracker.processUpdate(update)
+		return
+	}
+	if pending, ok := ctx.Value(pendingWritesKey{ldb: ldb}).(*pendingWrites); ok {
+		pending.mu.Lock()
+		defer pending.mu.Unlock()
+		pending.updates = append(pending.updates, update)
+	}
+}
+
+// Savepoint creates a savepoint like sqlgen.DB.Savepoint, remembering the
+// writes through LiveDB made before it.
+func (ldb *LiveDB) Savepoint(ctx context.Context, name string) error {
+	if err := ldb.DB.Savepoint(ctx, name); err != nil {
+		return err
+	}
+	if pending, ok := ctx.Value(pendingWritesKey{ldb: ldb}).(*pendingWrites); ok {
+		pending.savepoint(name)
+	}
+	return nil
+}
+
+// RollbackTo rolls back to a savepoint like sqlgen.DB.RollbackTo, and drops
+// the invalidations of the writes through LiveDB it undid.
+func (ldb *LiveDB) RollbackTo(ctx context.Context, name string) error {
+	if err := ldb.DB.RollbackTo(ctx, name); err != nil {
+		return err
+	}
+	if pending, ok := ctx.Value(pendingWritesKey{ldb: ldb}).(*pendingWrites); ok {
+		pending.rollbackTo(name)
+	}
+	return nil
+}
+
+// ReleaseSavepoint removes a savepoint like sqlgen.DB.ReleaseSavepoint.
+func (ldb *LiveDB) ReleaseSavepoint(ctx context.Context, name string) error {
+	if err := ldb.DB.ReleaseSavepoint(ctx, name); err != nil {
+		return err
+	}
+	if pending, ok := ctx.Value(pendingWritesKey{ldb: ldb}).(*pendingWrites); ok {
+		pending.release(name)
+	}
+	return nil
+}
+
+// RunInSavepoint runs f in a savepoint like sqlgen.DB.RunInSavepoint, and
+// drops the invalidations of the writes through LiveDB in f if it fails.
+func (ldb *LiveDB) RunInSavepoint(ctx context.Context, f func(ctx context.Context) error) error {
+	pending, ok := ctx.Value(pendingWritesKey{ldb: ldb}).(*pendingWrites)
+	if !ok {
+		return ldb.DB.RunInSavepoint(ctx, f)
+	}
+	mark := pending.mark()
+	var failed bool
+	err := ldb.DB.RunInSavepoint(ctx, func(ctx context.Context) error {
+		err := f(ctx)
+		failed = err != nil
+		return err
+	})
+	if failed {
+		pending.reset(mark)
+	}
+	return err
+}
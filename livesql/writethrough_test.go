@@ -0,0 +1,97 @@
+package livesql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/reactive"
+	"github.com/denkhaus/thunder/sqlgen"
+)
+
+func TestInvalidateWrite(t *testing.T) {
+	sqlSchema := sqlgen.NewSchema()
+	sqlSchema.MustRegisterType("cats", sqlgen.AutoIncrement, subscriptionCat{})
+	ldb := NewLiveDB(sqlgen.NewDB(nil, sqlSchema))
+
+	runs := make(chan struct{}, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reactive.NewRerunner(ctx, func(ctx context.Context) (interface{}, error) {
+		require.NoError(t, ldb.AddDependency(ctx, QueryDependency{Table: "cats", Filter: sqlgen.Filter{"owner_id": int64(1)}}))
+		runs <- struct{}{}
+		return nil, nil
+	}, 0, false)
+	waitRun := func() bool {
+		select {
+		case <-runs:
+			return true
+		case <-time.After(2 * reactive.WriteThenReadDelay):
+			return false
+		}
+	}
+	require.True(t, waitRun())
+
+	// Writes of other rows do not invalidate the query.
+	ldb.invalidateWrite(context.Background(), &subscriptionCat{Id: 1, OwnerId: 2}, delta{after: &subscriptionCat{Id: 1, OwnerId: 2}})
+	assert.False(t, waitRun())
+
+	cat := &subscriptionCat{Id: 2, OwnerId: 1}
+	ldb.invalidateWrite(context.Background(), cat, delta{after: cat})
+	assert.True(t, waitRun())
+	ldb.invalidateWrite(context.Background(), cat, delta{before: cat})
+	assert.True(t, waitRun())
+
+	// Writes in a RunInTx transaction are only invalidated on commit.
+	pending := &pendingWrites{}
+	txCtx := context.WithValue(context.Background(), pendingWritesKey{ldb: ldb}, pending)
+	txCtx, err := ldb.WithExistingTx(txCtx, nil)
+	require.NoError(t, err)
+	ldb.invalidateWrite(txCtx, cat, delta{after: cat})
+	assert.False(t, waitRun())
+	require.Len(t, pending.updates, 1)
+	ldb.tracker.processUpdate(pending.updates[0])
+	assert.True(t, waitRun())
+}
+
+func TestPendingWritesSavepoints(t *testing.T) {
+	pending := &pendingWrites{}
+	write := func(table string) {
+		pending.updates = append(pending.updates, &update{table: table})
+	}
+	tables := func() []string {
+		var tables []string
+		for _, update := range pending.updates {
+			tables = append(tables, update.table)
+		}
+		return tables
+	}
+
+	write("a")
+	pending.savepoint("first")
+	write("b")
+	pending.savepoint("second")
+	write("c")
+
+	// Rolling back forgets the writes and savepoints since the savepoint.
+	pending.rollbackTo("first")
+	assert.Equal(t, []string{"a"}, tables())
+	pending.rollbackTo("second")
+	assert.Equal(t, []string{"a"}, tables())
+
+	write("d")
+	mark := pending.mark()
+	pending.savepoint("third")
+	write("e")
+	pending.release("third")
+	pending.rollbackTo("third")
+	assert.Equal(t, []string{"a", "d", "e"}, tables())
+
+	pending.reset(mark)
+	assert.Equal(t, []string{"a", "d"}, tables())
+	pending.rollbackTo("first")
+	assert.Equal(t, []string{"a"}, tables())
+}
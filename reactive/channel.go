@@ -0,0 +1,157 @@
+package reactive
+
+import (
+	"context"
+	"reflect"
+	"time"
+)
+
+// BufferPolicy controls how a channel Resource handles values that arrive
+// faster than they are handled, see NewChannelResource.
+type BufferPolicy int
+
+const (
+	// StrobeEach strobes the resource once for every value received.
+	StrobeEach BufferPolicy = iota
+	// StrobeCoalesced drains the values already buffered in the channel after
+	// receiving a value, and strobes the resource once for all of them. It
+	// suits bursty sources with buffered channels.
+	StrobeCoalesced
+)
+
+// ClosePolicy controls what a channel Resource does when its channel is
+// closed, see NewChannelResource.
+type ClosePolicy int
+
+const (
+	// StrobeOnClose strobes the resource a final time when the channel is
+	// closed, so computations observe the end of the event source.
+	StrobeOnClose ClosePolicy = iota
+	// IgnoreClose stops watching the channel without strobing the resource.
+	IgnoreClose
+)
+
+type channelConfig struct {
+	buffer BufferPolicy
+	close  ClosePolicy
+	name   string
+}
+
+// ChannelOption configures NewChannelResource.
+type ChannelOption func(*channelConfig)
+
+// WithBufferPolicy sets the BufferPolicy of a channel Resource. It defaults
+// to StrobeEach.
+func WithBufferPolicy(policy BufferPolicy) ChannelOption {
+	return func(c *channelConfig) {
+		c.buffer = policy
+	}
+}
+
+// WithClosePolicy sets the ClosePolicy of a channel Resource. It defaults to
+// StrobeOnClose.
+func WithClosePolicy(policy ClosePolicy) ChannelOption {
+	return func(c *channelConfig) {
+		c.close = policy
+	}
+}
+
+// WithResourceName names a channel Resource in invalidation traces, see
+// NewNamedResource.
+func WithResourceName(name string) ChannelOption {
+	return func(c *channelConfig) {
+		c.name = name
+	}
+}
+
+// NewChannelResource returns a Resource that is strobed whenever a value is
+// received from ch, which must be a channel that can be received from, such
+// as a chan *Event or a <-chan struct{}. Computations depending on the
+// resource with AddDependency are invalidated by every received value, which
+// integrates arbitrary event sources into live queries:
+//
+//	events := reactive.NewChannelResource(ctx, bus.Subscribe("users"))
+//	...
+//	reactive.AddDependency(ctx, events, nil)
+//
+// The resource takes ownership of receiving from ch. It watches ch until ctx
+// is done or ch is closed; see ClosePolicy.
+func NewChannelResource(ctx context.Context, ch interface{}, opts ...ChannelOption) *Resource {
+	value := reflect.ValueOf(ch)
+	if value.Kind() != reflect.Chan || value.Type().ChanDir()&reflect.RecvDir == 0 {
+		panic("reactive: NewChannelResource requires a channel to receive from")
+	}
+
+	config := &channelConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	r := NewResource()
+	if config.name != "" {
+		r = NewNamedResource(config.name)
+	}
+	go watchChannel(ctx, r, value, config)
+	return r
+}
+
+// watchChannel strobes r for the values received from ch.
+func watchChannel(ctx context.Context, r *Resource, ch reflect.Value, config *channelConfig) {
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+		{Dir: reflect.SelectRecv, Chan: ch},
+	}
+
+	for {
+		chosen, _, ok := reflect.Select(cases)
+		if chosen == 0 {
+			return
+		}
+		if !ok {
+			if config.close == StrobeOnClose {
+				r.Strobe()
+			}
+			return
+		}
+
+		if config.buffer == StrobeCoalesced {
+			closed := false
+			for {
+				value, ok := ch.TryRecv()
+				if !ok {
+					// TryRecv returns a zero Value if it would block, and
+					// the element type's zero value if ch is closed.
+					closed = value.IsValid()
+					break
+				}
+			}
+			if closed {
+				if config.close == StrobeOnClose {
+					r.Strobe()
+				}
+				return
+			}
+		}
+		r.Strobe()
+	}
+}
+
+// NewTickerResource returns a Resource that is strobed every d until ctx is
+// done, for computations that should be refreshed periodically. Ticks missed
+// by slow computations are dropped.
+func NewTickerResource(ctx context.Context, d time.Duration) *Resource {
+	r := NewResource()
+	ticker := time.NewTicker(d)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.Strobe()
+			}
+		}
+	}()
+	return r
+}
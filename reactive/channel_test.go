@@ -0,0 +1,141 @@
+package reactive
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// runsOf starts a rerunner depending on r, and returns a channel receiving
+// the number of every run.
+func runsOf(r *Resource) (chan int64, func()) {
+	var count int64
+	runs := make(chan int64, 16)
+	runner := NewRerunner(context.Background(), func(ctx context.Context) (interface{}, error) {
+		AddDependency(ctx, r, nil)
+		runs <- atomic.AddInt64(&count, 1)
+		return nil, nil
+	}, 0, false)
+	return runs, runner.Stop
+}
+
+func expectRun(t *testing.T, runs chan int64, expected int64) {
+	select {
+	case run := <-runs:
+		if run != expected {
+			t.Errorf("expected run %d, got %d", expected, run)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("expected run %d", expected)
+	}
+}
+
+func expectNoRun(t *testing.T, runs chan int64) {
+	select {
+	case run := <-runs:
+		t.Errorf("unexpected run %d", run)
+	case <-time.After(2 * WriteThenReadDelay):
+	}
+}
+
+func TestChannelResource(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := make(chan string)
+	r := NewChannelResource(ctx, events)
+	runs, stop := runsOf(r)
+	defer stop()
+
+	expectRun(t, runs, 1)
+	events <- "a"
+	expectRun(t, runs, 2)
+	events <- "b"
+	expectRun(t, runs, 3)
+
+	// Closing the channel strobes the resource a final time.
+	close(events)
+	expectRun(t, runs, 4)
+	expectNoRun(t, runs)
+}
+
+func TestChannelResourceCoalesced(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := make(chan int, 10)
+	for i := 0; i < 10; i++ {
+		events <- i
+	}
+	r := NewChannelResource(ctx, (<-chan int)(events), WithBufferPolicy(StrobeCoalesced), WithClosePolicy(IgnoreClose))
+
+	// The buffered values are drained at once.
+	deadline := time.Now().Add(2 * time.Second)
+	for len(events) > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if len(events) != 0 {
+		t.Errorf("expected channel to be drained, %d values left", len(events))
+	}
+
+	runs, stop := runsOf(r)
+	defer stop()
+	expectRun(t, runs, 1)
+	events <- 1
+	expectRun(t, runs, 2)
+
+	// Closing the channel is ignored.
+	close(events)
+	expectNoRun(t, runs)
+}
+
+func TestChannelResourceContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	events := make(chan struct{}, 1)
+	r := NewChannelResource(ctx, events)
+	runs, stop := runsOf(r)
+	defer stop()
+	expectRun(t, runs, 1)
+
+	// Once ctx is done, the channel is no longer watched.
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	events <- struct{}{}
+	expectNoRun(t, runs)
+	if len(events) != 1 {
+		t.Error("expected value to stay in the channel")
+	}
+}
+
+func TestChannelResourceInvalidChannel(t *testing.T) {
+	for _, ch := range []interface{}{nil, 1, make(chan<- int)} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic for %T", ch)
+				}
+			}()
+			NewChannelResource(context.Background(), ch)
+		}()
+	}
+}
+
+func TestTickerResource(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := NewTickerResource(ctx, 10*time.Millisecond)
+	runs, stop := runsOf(r)
+	defer stop()
+
+	expectRun(t, runs, 1)
+	expectRun(t, runs, 2)
+	expectRun(t, runs, 3)
+
+	cancel()
+	time.Sleep(2 * WriteThenReadDelay)
+	for len(runs) > 0 {
+		<-runs
+	}
+	expectNoRun(t, runs)
+}
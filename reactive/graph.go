@@ -14,11 +14,13 @@ import "sync"
 // computation can still keep a potentially expensive resource that has not yet
 // been invalidated around and cached.
 //
-// Both releases and invalidations happen asynchronously, running in a Goroutine
-// that triggered the initial release or invalidation.
+// Both releases and invalidations happen asynchronously, running on the graph
+// maintenance worker that picked up the initial release or invalidation.
 //
 // To interact with computations, nodes have an optional afterInvalidate and
-// afterRelease handler.
+// afterRelease handler. afterInvalidate runs on the graph maintenance worker
+// and must not block. afterRelease and the invalidateHandlers are user code,
+// and run on the compute pool, see SetComputeWorkPool.
 //
 // One tricky aspect of the reference counting is that releases will happen
 // only on a transition from 1->0 out. That's because nodes start with empty
@@ -44,8 +46,16 @@ type node struct {
 	invalidated bool
 	released    bool
 
+	// label describes the node in invalidation traces, and invalidatedBy is
+	// the node whose invalidation invalidated this node, if any.
+	label         interface{}
+	invalidatedBy *node
+
 	afterInvalidate func()
 	afterRelease    func()
+
+	// invalidateHandlers are called after afterInvalidate, see onInvalidate.
+	invalidateHandlers []func()
 }
 
 // Invalidated returns if the node has been invalidated
@@ -67,12 +77,18 @@ func (n *node) strobe() {
 	n.mu.Unlock()
 
 	for _, to := range out {
-		to.invalidate()
+		to.invalidateFrom(n)
 	}
 }
 
 // invalidate invalidates node if it has not yet been invalidated
 func (n *node) invalidate() {
+	n.invalidateFrom(nil)
+}
+
+// invalidateFrom invalidates node if it has not yet been invalidated, recording
+// cause as the node that triggered the invalidation
+func (n *node) invalidateFrom(cause *node) {
 	// check if we should invalidate, and figure out who we should invalidate
 	n.mu.Lock()
 	if n.invalidated {
@@ -81,6 +97,7 @@ func (n *node) invalidate() {
 	}
 
 	n.invalidated = true
+	n.invalidatedBy = cause
 	// Copy out to safely strobe without holding mu. We keep out around for
 	// reference counting even after we are invalidated, but no new nodes will
 	// be added so taking a snapshot is a safe operation.
@@ -91,19 +108,37 @@ func (n *node) invalidate() {
 	for to := range n.out {
 		out = append(out, to)
 	}
+	handlers := n.invalidateHandlers
+	n.invalidateHandlers = nil
 	n.mu.Unlock()
 
 	if n.afterInvalidate != nil {
 		n.afterInvalidate()
 	}
+	for _, f := range handlers {
+		runHandler(f, nil)
+	}
 
 	// recursively invalidate dependencies
 	for _, to := range out {
-		to.invalidate()
+		to.invalidateFrom(n)
 	}
 }
 
+// cause returns the node whose invalidation invalidated n, if any
+func (n *node) cause() *node {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.invalidatedBy
+}
+
 func (n *node) release() {
+	n.releaseTracked(nil)
+}
+
+// releaseTracked releases n like release. If handlers is not nil, it tracks
+// the afterRelease handlers of the released nodes until they return.
+func (n *node) releaseTracked(handlers *sync.WaitGroup) {
 	n.invalidate()
 
 	// check if we should release
@@ -117,7 +152,7 @@ func (n *node) release() {
 	n.mu.Unlock()
 
 	if n.afterRelease != nil {
-		n.afterRelease()
+		runHandler(n.afterRelease, handlers)
 	}
 
 	// we can access in safely as it will no longer be modified after we set
@@ -130,7 +165,7 @@ func (n *node) release() {
 		from.mu.Unlock()
 
 		if shouldRelease {
-			from.release()
+			from.releaseTracked(handlers)
 		}
 	}
 	// set in to nil to help garbage collection
@@ -166,17 +201,17 @@ func (n *node) addOut(to *node) {
 	n.mu.Unlock()
 
 	if shouldInvalidate {
-		go to.invalidate()
+		async(func() { to.invalidateFrom(n) })
 	}
 	if shouldRelease {
-		go n.release()
+		async(n.release)
 	}
 }
 
 func (n *node) handleInvalidate(f func()) {
 	n.mu.Lock()
 	if n.invalidated {
-		go f()
+		async(f)
 	} else {
 		if n.afterInvalidate != nil {
 			panic(n)
@@ -189,7 +224,7 @@ func (n *node) handleInvalidate(f func()) {
 func (n *node) handleRelease(f func()) {
 	n.mu.Lock()
 	if n.released {
-		go f()
+		runHandler(f, nil)
 	} else {
 		if n.afterRelease != nil {
 			panic(n)
@@ -198,3 +233,15 @@ func (n *node) handleRelease(f func()) {
 	}
 	n.mu.Unlock()
 }
+
+// onInvalidate registers f to be called once n is invalidated. Unlike
+// handleInvalidate, any number of handlers can be registered.
+func (n *node) onInvalidate(f func()) {
+	n.mu.Lock()
+	if n.invalidated {
+		runHandler(f, nil)
+	} else {
+		n.invalidateHandlers = append(n.invalidateHandlers, f)
+	}
+	n.mu.Unlock()
+}
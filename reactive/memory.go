@@ -0,0 +1,153 @@
+package reactive
+
+import (
+	"context"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// liveCaches holds the caches of all running Rerunners, so they can be purged
+// under memory pressure.
+var liveCaches = struct {
+	mu     sync.Mutex
+	caches map[*cache]struct{}
+}{
+	caches: make(map[*cache]struct{}),
+}
+
+func registerCache(c *cache) {
+	liveCaches.mu.Lock()
+	defer liveCaches.mu.Unlock()
+	liveCaches.caches[c] = struct{}{}
+}
+
+func unregisterCache(c *cache) {
+	liveCaches.mu.Lock()
+	defer liveCaches.mu.Unlock()
+	delete(liveCaches.caches, c)
+}
+
+// touch marks the computation as used now.
+func (c *computation) touch() {
+	atomic.StoreInt64(&c.lastUsed, time.Now().UnixNano())
+}
+
+// cachedComputation identifies a computation in a cache.
+type cachedComputation struct {
+	cache       *cache
+	key         interface{}
+	computation *computation
+	lastUsed    int64
+}
+
+// PurgeLeastRecentlyUsed drops the given fraction, from 0 to 1, of the cached
+// computations of all running Rerunners, starting with the least recently
+// used. Dropped computations are recomputed the next time a Rerunner needs
+// them. It returns the number of dropped computations.
+func PurgeLeastRecentlyUsed(fraction float64) int {
+	liveCaches.mu.Lock()
+	caches := make([]*cache, 0, len(liveCaches.caches))
+	for c := range liveCaches.caches {
+		caches = append(caches, c)
+	}
+	liveCaches.mu.Unlock()
+
+	return purgeLeastRecentlyUsed(caches, fraction)
+}
+
+func purgeLeastRecentlyUsed(caches []*cache, fraction float64) int {
+	if fraction <= 0 {
+		return 0
+	}
+
+	var all []cachedComputation
+	for _, c := range caches {
+		c.mu.Lock()
+		for key, computation := range c.computations {
+			all = append(all, cachedComputation{
+				cache:       c,
+				key:         key,
+				computation: computation,
+				lastUsed:    atomic.LoadInt64(&computation.lastUsed),
+			})
+		}
+		c.mu.Unlock()
+	}
+
+	n := int(float64(len(all))*fraction + 0.5)
+	if n > len(all) {
+		n = len(all)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].lastUsed < all[j].lastUsed
+	})
+
+	dropped := 0
+	for _, cached := range all[:n] {
+		cached.cache.mu.Lock()
+		// Skip computations that were replaced in the meantime.
+		if cached.cache.computations[cached.key] == cached.computation {
+			delete(cached.cache.computations, cached.key)
+			dropped++
+		}
+		cached.cache.mu.Unlock()
+	}
+	return dropped
+}
+
+// MemoryPressureFunc reports the fraction of cached computations, from 0 to 1,
+// that should be dropped to relieve memory pressure.
+type MemoryPressureFunc func() float64
+
+// HeapLimitPressure returns a MemoryPressureFunc based on the runtime's heap
+// statistics. Pressure starts once the heap reaches 90% of limit bytes, and
+// grows until all cached computations are dropped at the limit.
+func HeapLimitPressure(limit uint64) MemoryPressureFunc {
+	return func() float64 {
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		return heapPressure(stats.HeapAlloc, limit)
+	}
+}
+
+// heapPressure computes the pressure of a heap of the given size.
+func heapPressure(heap, limit uint64) float64 {
+	soft := float64(limit) * 0.9
+	if float64(heap) < soft {
+		return 0
+	}
+	pressure := (float64(heap) - soft) / (float64(limit) - soft)
+	if pressure > 1 {
+		return 1
+	}
+	return pressure
+}
+
+// PurgeOnMemoryPressure checks pressure every interval until ctx is canceled,
+// and drops the least recently used cached computations of all running
+// Rerunners accordingly. report, if non-nil, is called with the number of
+// computations dropped by every purge.
+func PurgeOnMemoryPressure(ctx context.Context, interval time.Duration, pressure MemoryPressureFunc, report func(dropped int)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		fraction := pressure()
+		if fraction <= 0 {
+			continue
+		}
+		dropped := PurgeLeastRecentlyUsed(fraction)
+		if report != nil {
+			report(dropped)
+		}
+	}
+}
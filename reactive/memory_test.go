@@ -0,0 +1,64 @@
+package reactive
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPurgeLeastRecentlyUsed(t *testing.T) {
+	c := &cache{
+		computations: make(map[interface{}]*computation),
+		locker:       newLocker(),
+	}
+	for i := 0; i < 4; i++ {
+		c.set(i, &computation{lastUsed: int64(i)})
+	}
+	// Using key 0 makes it the most recently used.
+	c.get(0).lastUsed = 10
+
+	assert.Equal(t, 2, purgeLeastRecentlyUsed([]*cache{c}, 0.5))
+	assert.Nil(t, c.get(1))
+	assert.Nil(t, c.get(2))
+	assert.NotNil(t, c.get(0))
+	assert.NotNil(t, c.get(3))
+
+	assert.Equal(t, 0, purgeLeastRecentlyUsed([]*cache{c}, 0))
+	assert.Equal(t, 2, purgeLeastRecentlyUsed([]*cache{c}, 1))
+}
+
+func TestPurgeLeastRecentlyUsedRerunner(t *testing.T) {
+	dep := NewResource()
+	run := NewExpect()
+	innerRun := NewExpect()
+
+	r := NewRerunner(context.Background(), func(ctx context.Context) (interface{}, error) {
+		AddDependency(ctx, dep, nil)
+		Cache(ctx, 0, func(ctx context.Context) (interface{}, error) {
+			innerRun.Trigger()
+			return nil, nil
+		})
+		run.Trigger()
+		return nil, nil
+	}, 0, false)
+	defer r.Stop()
+
+	run.Expect(t, "expected run")
+	innerRun.Expect(t, "expected inner run")
+
+	assert.True(t, PurgeLeastRecentlyUsed(1) >= 1)
+
+	// The purged computation reruns.
+	run = NewExpect()
+	innerRun = NewExpect()
+	dep.Strobe()
+	run.Expect(t, "expected rerun")
+	innerRun.Expect(t, "expected inner rerun")
+}
+
+func TestHeapPressure(t *testing.T) {
+	assert.Equal(t, 0.0, heapPressure(80, 100))
+	assert.InDelta(t, 0.5, heapPressure(95, 100), 0.001)
+	assert.Equal(t, 1.0, heapPressure(120, 100))
+}
@@ -0,0 +1,125 @@
+package reactive
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// A TenantQuota shares rerun executions fairly between the tenants of a
+// process, such as the clients of a server. Each tenant may rerun its
+// Rerunners at most Rate times per second, with bursts of up to Burst reruns,
+// so one tenant's hot subscriptions cannot starve the reactivity of others.
+// Reruns over the quota are delayed, and coalesce with later invalidations.
+//
+// Rerunners are assigned to a tenant with WithTenant. Their first run is
+// never delayed.
+type TenantQuota struct {
+	rate  float64
+	burst float64
+
+	mu      sync.Mutex
+	tenants map[interface{}]*tenantBucket
+}
+
+// tenantBucket is the token bucket of a tenant, shared by its rerunners.
+type tenantBucket struct {
+	rerunners int
+	tokens    float64
+	last      time.Time
+}
+
+// NewTenantQuota creates a TenantQuota allowing each tenant rate reruns per
+// second, with bursts of up to burst reruns.
+func NewTenantQuota(rate float64, burst int) *TenantQuota {
+	if burst < 1 {
+		burst = 1
+	}
+	return &TenantQuota{
+		rate:    rate,
+		burst:   float64(burst),
+		tenants: make(map[interface{}]*tenantBucket),
+	}
+}
+
+type tenantKey struct{}
+
+// tenant identifies the tenant of a rerunner, and the quota it shares.
+type tenant struct {
+	quota *TenantQuota
+	key   interface{}
+}
+
+// WithTenant configures Rerunners created with ctx to belong to the tenant
+// key, sharing the reruns quota allows it with the tenant's other Rerunners.
+// key must be comparable.
+func WithTenant(ctx context.Context, quota *TenantQuota, key interface{}) context.Context {
+	return context.WithValue(ctx, tenantKey{}, &tenant{quota: quota, key: key})
+}
+
+// tenantFromContext returns the tenant of ctx, if any.
+func tenantFromContext(ctx context.Context) *tenant {
+	t, _ := ctx.Value(tenantKey{}).(*tenant)
+	return t
+}
+
+// register adds a rerunner to the tenant.
+func (t *tenant) register() {
+	q := t.quota
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	bucket, ok := q.tenants[t.key]
+	if !ok {
+		bucket = &tenantBucket{tokens: q.burst, last: time.Now()}
+		q.tenants[t.key] = bucket
+	}
+	bucket.rerunners++
+}
+
+// unregister removes a rerunner from the tenant, forgetting the tenant once
+// it has no rerunners left.
+func (t *tenant) unregister() {
+	q := t.quota
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	bucket, ok := q.tenants[t.key]
+	if !ok {
+		return
+	}
+	bucket.rerunners--
+	if bucket.rerunners <= 0 {
+		delete(q.tenants, t.key)
+	}
+}
+
+// reserve takes a rerun from the tenant's quota, and returns how long the
+// rerun must wait for it.
+func (t *tenant) reserve() time.Duration {
+	q := t.quota
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	bucket, ok := q.tenants[t.key]
+	if !ok || q.rate <= 0 {
+		return 0
+	}
+
+	now := time.Now()
+	bucket.tokens += now.Sub(bucket.last).Seconds() * q.rate
+	if bucket.tokens > q.burst {
+		bucket.tokens = q.burst
+	}
+	bucket.last = now
+
+	bucket.tokens--
+	if bucket.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-bucket.tokens / q.rate * float64(time.Second))
+}
+
+// TenantCount returns the number of tenants with Rerunners.
+func (q *TenantQuota) TenantCount() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.tenants)
+}
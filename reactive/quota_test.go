@@ -0,0 +1,165 @@
+package reactive
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTenantQuotaReserve(t *testing.T) {
+	quota := NewTenantQuota(10, 2)
+	tenant := &tenant{quota: quota, key: "a"}
+	tenant.register()
+
+	// The burst is available right away, and later reruns wait for tokens.
+	if delay := tenant.reserve(); delay != 0 {
+		t.Errorf("expected no delay, got %s", delay)
+	}
+	if delay := tenant.reserve(); delay != 0 {
+		t.Errorf("expected no delay, got %s", delay)
+	}
+	if delay := tenant.reserve(); delay < 90*time.Millisecond || delay > 100*time.Millisecond {
+		t.Errorf("expected a delay of 100ms, got %s", delay)
+	}
+	if delay := tenant.reserve(); delay < 190*time.Millisecond || delay > 200*time.Millisecond {
+		t.Errorf("expected a delay of 200ms, got %s", delay)
+	}
+
+	tenant.unregister()
+	if count := quota.TenantCount(); count != 0 {
+		t.Errorf("expected no tenants, got %d", count)
+	}
+}
+
+// TestTenantQuota tests that one tenant's hot rerunner does not delay the
+// reruns of another tenant.
+func TestTenantQuota(t *testing.T) {
+	quota := NewTenantQuota(2, 1)
+
+	hotDep := NewResource()
+	hotRuns := make(chan struct{}, 100)
+	hot := NewRerunner(WithTenant(context.Background(), quota, "hot"), func(ctx context.Context) (interface{}, error) {
+		AddDependency(ctx, hotDep, nil)
+		hotRuns <- struct{}{}
+		return nil, nil
+	}, 0, true)
+
+	coldDep := NewResource()
+	coldRuns := make(chan struct{}, 100)
+	cold := NewRerunner(WithTenant(context.Background(), quota, "cold"), func(ctx context.Context) (interface{}, error) {
+		AddDependency(ctx, coldDep, nil)
+		coldRuns <- struct{}{}
+		return nil, nil
+	}, 0, true)
+
+	<-hotRuns
+	<-coldRuns
+	if count := quota.TenantCount(); count != 2 {
+		t.Errorf("expected 2 tenants, got %d", count)
+	}
+
+	// Invalidate the hot rerunner continuously for a second.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		deadline := time.Now().Add(time.Second)
+		for time.Now().Before(deadline) {
+			hotDep.Strobe()
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+
+	// The cold tenant still reruns promptly.
+	time.Sleep(200 * time.Millisecond)
+	start := time.Now()
+	coldDep.Strobe()
+	select {
+	case <-coldRuns:
+		if elapsed := time.Since(start); elapsed > WriteThenReadDelay+150*time.Millisecond {
+			t.Errorf("expected a prompt rerun, took %s", elapsed)
+		}
+	case <-time.After(time.Second):
+		t.Error("expected a rerun")
+	}
+
+	<-done
+	hot.Stop()
+	cold.Stop()
+
+	// The hot tenant reran at most about 2 times per second, with a burst of
+	// one, instead of after every WriteThenReadDelay.
+	if runs := len(hotRuns); runs > 4 {
+		t.Errorf("expected at most 4 hot reruns, got %d", runs)
+	}
+	if throttled := hot.Stats().Throttled; throttled == 0 {
+		t.Error("expected throttled reruns")
+	}
+	if count := quota.TenantCount(); count != 0 {
+		t.Errorf("expected no tenants, got %d", count)
+	}
+}
+
+func TestTenantQuotaOnGraphWorkPool(t *testing.T) {
+	quota := NewTenantQuota(0.1, 1)
+
+	// Throttle more rerunners of a tenant than there are graph workers,
+	// with rerunners that do not spawn goroutines.
+	var deps []*Resource
+	var rerunners []*Rerunner
+	var firstRuns sync.WaitGroup
+	for i := 0; i < DefaultGraphWorkers+8; i++ {
+		dep := NewResource()
+		deps = append(deps, dep)
+		firstRuns.Add(1)
+		first := true
+		rerunners = append(rerunners, NewRerunner(WithTenant(context.Background(), quota, "hot"), func(ctx context.Context) (interface{}, error) {
+			AddDependency(ctx, dep, nil)
+			if first {
+				first = false
+				firstRuns.Done()
+			}
+			return nil, nil
+		}, 0, false))
+	}
+	defer func() {
+		for _, r := range rerunners {
+			r.Stop()
+		}
+	}()
+	firstRuns.Wait()
+	for _, dep := range deps {
+		dep.Strobe()
+	}
+
+	coldDep := NewResource()
+	coldRuns := make(chan struct{}, 10)
+	cold := NewRerunner(WithTenant(context.Background(), quota, "cold"), func(ctx context.Context) (interface{}, error) {
+		AddDependency(ctx, coldDep, nil)
+		coldRuns <- struct{}{}
+		return nil, nil
+	}, 0, false)
+	defer cold.Stop()
+	<-coldRuns
+
+	// The cold tenant reruns promptly while the hot tenant waits for its
+	// quota.
+	start := time.Now()
+	coldDep.Strobe()
+	select {
+	case <-coldRuns:
+		if elapsed := time.Since(start); elapsed > WriteThenReadDelay+time.Second {
+			t.Errorf("expected a prompt rerun, took %s", elapsed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("throttled rerunners starved the cold tenant")
+	}
+
+	var throttled int64
+	for _, r := range rerunners {
+		throttled += r.Stats().Throttled
+	}
+	if throttled == 0 {
+		t.Error("expected throttled reruns")
+	}
+}
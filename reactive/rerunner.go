@@ -64,6 +64,14 @@ func (l *locker) Unlock(k interface{}) {
 }
 
 type computation struct {
+	// lastUsed is when the computation was last used from a cache, in Unix
+	// nanoseconds. It is accessed atomically, and comes first to be 64-bit
+	// aligned.
+	lastUsed int64
+	// finished is set once the computation returned, and is accessed
+	// atomically, see WithStrictDependencies.
+	finished int32
+
 	node  node
 	value interface{}
 }
@@ -137,18 +145,29 @@ func NewResource() *Resource {
 	}
 }
 
+// NewNamedResource creates a new Resource that is identified by name in
+// invalidation traces, see WithInvalidationTracing.
+func NewNamedResource(name string) *Resource {
+	return &Resource{
+		node: node{label: resourceName(name)},
+	}
+}
+
 // Invalidate permanently invalidates r
 func (r *Resource) Invalidate() {
-	go r.invalidate()
+	async(r.invalidate)
 }
 
 // Store invalidates all computations currently depending on r
 func (r *Resource) Strobe() {
-	go r.strobe()
+	async(r.strobe)
 }
 
 // Cleanup registers a handler to be called when all computations using r stop
 //
+// f runs on the compute pool shared with reruns, if enabled, so it should
+// not block.
+//
 // NOTE: For f to be called, at least one computation must AddDependency r!
 func (r *Resource) Cleanup(f func()) {
 	r.node.handleRelease(f)
@@ -189,6 +208,7 @@ func AddDependency(ctx context.Context, r *Resource, dep Dependency) {
 	}
 
 	computation := ctx.Value(computationKey{}).(*computation)
+	checkLate(ctx, computation, "AddDependency")
 	r.node.addOut(&computation.node)
 
 	if dep != nil {
@@ -202,6 +222,29 @@ func AddDependency(ctx context.Context, r *Resource, dep Dependency) {
 	}
 }
 
+// OnInvalidate registers f to be called once the current computation is
+// invalidated or released, whichever happens first. Resolvers use it to clean
+// up resources they allocate for the lifetime of a cached computation, such
+// as watchers, file handles, or subscriptions to other services:
+//
+//	watcher := startWatching(path)
+//	reactive.OnInvalidate(ctx, watcher.Close)
+//
+// f runs on the compute pool shared with reruns, if enabled, so it should
+// not block.
+// Outside of a Rerunner nothing is cached, so like the resources of
+// AddDependency, f is released right away and called asynchronously.
+func OnInvalidate(ctx context.Context, f func()) {
+	if !HasRerunner(ctx) {
+		runHandler(f, nil)
+		return
+	}
+
+	computation := ctx.Value(computationKey{}).(*computation)
+	checkLate(ctx, computation, "OnInvalidate")
+	computation.node.onInvalidate(f)
+}
+
 // WithDependencyCallback registers a callback that is invoked when
 // AddDependency is called with non-nil serializable dependency.
 func WithDependencyCallback(ctx context.Context, f DependencyCallbackFunc) context.Context {
@@ -218,21 +261,32 @@ func Dependencies(ctx context.Context) []Dependency {
 
 type ComputeFunc func(context.Context) (interface{}, error)
 
-func run(ctx context.Context, f ComputeFunc) (*computation, error) {
+func run(ctx context.Context, label interface{}, f ComputeFunc) (*computation, error) {
+	c, err := runComputation(ctx, label, f)
+	if err != nil {
+		async(c.node.release)
+		return nil, err
+	}
+	return c, nil
+}
+
+// runComputation computes f. It returns the computation even if f fails, and
+// the caller must release it in that case.
+func runComputation(ctx context.Context, label interface{}, f ComputeFunc) (*computation, error) {
 	// build result computation and local computation Ctx
 	c := &computation{
 		// this node will be freed either when the computation fails, or by our
 		// caller
-		node: node{},
+		node: node{label: label},
 	}
 
 	childCtx := context.WithValue(ctx, computationKey{}, c)
 
 	// Compute f and write the results to the c
 	value, err := f(childCtx)
+	c.finish()
 	if err != nil {
-		go c.node.release()
-		return nil, err
+		return c, err
 	}
 
 	c.value = value
@@ -248,6 +302,7 @@ func Cache(ctx context.Context, key interface{}, f ComputeFunc) (interface{}, er
 
 	cache := ctx.Value(cacheKey{}).(*cache)
 	computation := ctx.Value(computationKey{}).(*computation)
+	checkLate(ctx, computation, "Cache")
 
 	if err := cache.locker.Lock(ctx, key); err != nil {
 		return nil, err
@@ -255,20 +310,37 @@ func Cache(ctx context.Context, key interface{}, f ComputeFunc) (interface{}, er
 	defer cache.locker.Unlock(key)
 
 	if child := cache.get(key); child != nil {
+		child.touch()
 		child.node.addOut(&computation.node)
 		return child.value, nil
 	}
 
-	child, err := run(ctx, f)
+	child, err := run(ctx, key, f)
 	if err != nil {
 		return nil, err
 	}
+	child.touch()
 	cache.set(key, child)
 
 	child.node.addOut(&computation.node)
 	return child.value, nil
 }
 
+// CacheWithTTL is like Cache, but the cached value also expires ttl after it
+// is computed, even if none of its dependencies changed. Use it for
+// computations that read data sources without Resources, such as external
+// caches or services, to keep them reasonably fresh.
+func CacheWithTTL(ctx context.Context, key interface{}, ttl time.Duration, f ComputeFunc) (interface{}, error) {
+	if !HasRerunner(ctx) {
+		return f(ctx)
+	}
+
+	return Cache(ctx, key, func(ctx context.Context) (interface{}, error) {
+		InvalidateAfter(ctx, ttl)
+		return f(ctx)
+	})
+}
+
 // Rerunner automatically reruns a computation whenever its dependencies
 // change.
 //
@@ -279,27 +351,50 @@ type Rerunner struct {
 	ctx       context.Context
 	cancelCtx context.CancelFunc
 
-	f                    ComputeFunc
-	cache                *cache
-	minRerunInterval     time.Duration
-	retryDelay           time.Duration
+	f                ComputeFunc
+	cache            *cache
+	minRerunInterval time.Duration
+	retryDelay       time.Duration
+	// alwaysSpawnGoroutine runs computations on their own goroutines instead
+	// of the compute pool.
 	alwaysSpawnGoroutine bool
 
 	// flushed tracks if the next computation should run without delay. It is set
-	// to false as soon as the next computation starts. flushCh is closed when
-	// flushed is set to true.
+	// to false as soon as the next computation starts. pending is the timer of
+	// a rerun waiting for its rerun interval, if any.
 	flushMu sync.Mutex
-	flushCh chan struct{}
 	flushed bool
+	pending *time.Timer
 
 	mu          sync.Mutex
 	computation *computation
 	stop        bool
 
+	// stopped is closed once the rerunner stops, and releases tracks the
+	// computations being released, for Drain.
+	stopped  chan struct{}
+	releases sync.WaitGroup
+
 	lastRun time.Time
+
+	// tenant shares the reruns quota of the rerunner's tenant, if any, see
+	// WithTenant.
+	tenant *tenant
+
+	// maxTraces is the number of invalidation traces to keep, if tracing is
+	// enabled.
+	maxTraces int
+	statsMu   sync.Mutex
+	stats     RerunnerStats
 }
 
-// NewRerunner runs f continuously
+// NewRerunner runs f continuously, until it is stopped or ctx is canceled.
+//
+// Reruns happen at most once per minRerunInterval. The first run happens on
+// its own goroutine. Reruns happen on the compute pool if it is enabled, see
+// SetComputeWorkPool, unless alwaysSpawnGoroutine is set, in which case each
+// rerun runs on its own goroutine. Set it for computations that block for
+// long, so they do not hold up a worker.
 func NewRerunner(ctx context.Context, f ComputeFunc, minRerunInterval time.Duration, alwaysSpawnGoroutine bool) *Rerunner {
 	ctx, cancelCtx := context.WithCancel(ctx)
 
@@ -315,10 +410,22 @@ func NewRerunner(ctx context.Context, f ComputeFunc, minRerunInterval time.Durat
 		minRerunInterval:     minRerunInterval,
 		retryDelay:           minRerunInterval,
 		alwaysSpawnGoroutine: alwaysSpawnGoroutine,
+		maxTraces:            invalidationTracing(ctx),
+		tenant:               tenantFromContext(ctx),
 
-		flushCh: make(chan struct{}, 0),
+		stopped: make(chan struct{}),
+	}
+	registerCache(r.cache)
+	if r.tenant != nil {
+		r.tenant.register()
 	}
 	go r.run()
+	go func() {
+		// Release the computation promptly once ctx is canceled, instead of
+		// waiting for the next rerun.
+		<-r.ctx.Done()
+		r.Stop()
+	}()
 	return r
 }
 
@@ -327,40 +434,98 @@ func (r *Rerunner) RerunImmediately() {
 	r.flushMu.Lock()
 	defer r.flushMu.Unlock()
 
-	if !r.flushed {
-		close(r.flushCh)
-		r.flushed = true
+	if r.pending != nil {
+		r.pending.Stop()
+		r.pending = nil
+		r.spawn(r.run)
+		return
 	}
+	r.flushed = true
 }
 
-// run performs an actual computation
-func (r *Rerunner) run() {
-	// Wait for the minimum rerun interval. Exit early if the computation is stopped.
-	delta := r.retryDelay - time.Now().Sub(r.lastRun)
+// spawn runs f on the compute pool, or on its own goroutine if
+// alwaysSpawnGoroutine is set or the compute pool is disabled.
+func (r *Rerunner) spawn(f func()) {
+	if r.alwaysSpawnGoroutine {
+		go f()
+		return
+	}
+	computeWork.run(f)
+}
 
-	t := time.NewTimer(delta)
-	select {
-	case <-r.ctx.Done():
-	case <-t.C:
-	case <-r.flushCh:
+// scheduleRun schedules a rerun once delay has passed since lastRun, or right
+// away after RerunImmediately. The rerun waits on a timer, so that reruns
+// waiting for their rerun interval do not hold up a worker.
+func (r *Rerunner) scheduleRun(lastRun time.Time, delay time.Duration) {
+	r.flushMu.Lock()
+	defer r.flushMu.Unlock()
+
+	wait := delay - time.Since(lastRun)
+	if r.flushed || wait <= 0 {
+		r.spawn(r.run)
+		return
 	}
-	t.Stop()
+
+	var t *time.Timer
+	t = time.AfterFunc(wait, func() {
+		r.flushMu.Lock()
+		defer r.flushMu.Unlock()
+		// Skip the rerun if RerunImmediately or Stop took it over.
+		if r.pending == t {
+			r.pending = nil
+			r.spawn(r.run)
+		}
+	})
+	r.pending = t
+}
+
+// run performs an actual computation, once its rerun interval passed.
+func (r *Rerunner) run() {
 	if r.ctx.Err() != nil {
 		return
 	}
 
 	r.flushMu.Lock()
-	if r.flushed {
-		r.flushCh = make(chan struct{}, 0)
-		r.flushed = false
-	}
+	r.flushed = false
 	r.flushMu.Unlock()
 
+	// Warm up the cache before the first run. This happens outside of r.mu,
+	// so that warming a large snapshot does not block Stop.
+	if r.lastRun.IsZero() {
+		r.compute(r.warmStart(r.ctx))
+		return
+	}
+
+	// Wait for the quota of the tenant. The rerun is scheduled once the quota allows it, rather than holding a
+	// goroutine while the tenant is throttled.
+	if r.tenant != nil {
+		if delay := r.tenant.reserve(); delay > 0 {
+			r.statsMu.Lock()
+			r.stats.Throttled++
+			r.statsMu.Unlock()
+
+			time.AfterFunc(delay, func() {
+				r.spawn(func() { r.compute(nil) })
+			})
+			return
+		}
+	}
+
+	r.compute(nil)
+}
+
+// compute reruns the computation, once run waited for the rerun interval and
+// the tenant's quota. warmed holds the computations warmed up for the first
+// run, if any, and is released after it.
+func (r *Rerunner) compute(warmed *computation) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	// Bail out if the computation has been stopped.
-	if r.stop {
+	if r.stop || r.ctx.Err() != nil {
+		if warmed != nil {
+			r.release(warmed)
+		}
 		return
 	}
 
@@ -378,12 +543,25 @@ func (r *Rerunner) run() {
 	ctx = context.WithValue(ctx, cacheKey{}, r.cache)
 	ctx = context.WithValue(ctx, dependencySetKey{}, &dependencySet{})
 
-	currentComputation, err := run(ctx, r.f)
+	currentComputation, err := runComputation(ctx, nil, r.f)
+	if err != nil {
+		r.release(currentComputation)
+		currentComputation = nil
+	}
+	if warmed != nil {
+		// Release the warmed computations the first run did not use.
+		r.release(warmed)
+	}
 	r.lastRun = time.Now()
+	r.statsMu.Lock()
+	r.stats.Runs++
+	r.stats.LastRun = r.lastRun
+	r.statsMu.Unlock()
 	if err != nil {
 		if err != RetrySentinelError {
 			// If we encountered an error that is not the retry sentinel,
 			// we should stop the rerunner.
+			r.stopLocked()
 			return
 		}
 		// Reset the cache for sentinel errors so we get a clean slate.
@@ -395,12 +573,12 @@ func (r *Rerunner) run() {
 		if r.retryDelay > time.Minute {
 			r.retryDelay = time.Minute
 		}
-		go r.run()
+		r.scheduleRun(r.lastRun, r.retryDelay)
 	} else {
 		// If we succeeded in the computation, we can release the old computation
 		// and reset the retry delay.
 		if r.computation != nil {
-			go r.computation.node.release()
+			r.release(r.computation)
 			r.computation = nil
 		}
 
@@ -409,12 +587,12 @@ func (r *Rerunner) run() {
 
 		// Schedule a rerun whenever our node becomes invalidated (which might already
 		// have happened!)
+		lastRun, delay := r.lastRun, r.retryDelay
 		currentComputation.node.handleInvalidate(func() {
-			if r.alwaysSpawnGoroutine {
-				go r.run()
-			} else {
-				r.run()
+			if r.maxTraces > 0 {
+				r.recordTrace(traceInvalidation(currentComputation))
 			}
+			r.scheduleRun(lastRun, delay)
 		})
 	}
 }
@@ -424,12 +602,69 @@ func (r *Rerunner) Stop() {
 	r.cancelCtx()
 
 	r.mu.Lock()
+	r.stopLocked()
+	r.mu.Unlock()
+}
+
+// stopLocked stops the rerunner and releases its computation and cache. r.mu
+// must be held.
+func (r *Rerunner) stopLocked() {
+	if r.stop {
+		return
+	}
 	r.stop = true
+	r.cancelCtx()
+	r.flushMu.Lock()
+	if r.pending != nil {
+		r.pending.Stop()
+		r.pending = nil
+	}
+	r.flushMu.Unlock()
+	unregisterCache(r.cache)
+	if r.tenant != nil {
+		r.tenant.unregister()
+	}
+	r.cache.purgeCache()
 	if r.computation != nil {
-		go r.computation.node.release()
+		r.release(r.computation)
 		r.computation = nil
 	}
-	r.mu.Unlock()
+	close(r.stopped)
+}
+
+// release releases c asynchronously, running the Cleanup handlers of the
+// resources only c depends on. r.mu must be held.
+func (r *Rerunner) release(c *computation) {
+	r.releases.Add(1)
+	async(func() {
+		defer r.releases.Done()
+		c.node.releaseTracked(&r.releases)
+	})
+}
+
+// Drain blocks until the rerunner has stopped, because of Stop, an error, or
+// its context being canceled, and the Cleanup handlers of the resources
+// only it depended on have run. It returns ctx.Err() if ctx is done first.
+//
+// Use it for graceful shutdown, after canceling the rerunners' context.
+func (r *Rerunner) Drain(ctx context.Context) error {
+	select {
+	case <-r.stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	released := make(chan struct{})
+	go func() {
+		r.releases.Wait()
+		close(released)
+	}()
+	select {
+	case <-released:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 func HasRerunner(ctx context.Context) bool {
@@ -3,6 +3,7 @@ package reactive
 import (
 	"context"
 	"errors"
+	"reflect"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -38,6 +39,17 @@ func (e *Expect) Expect(t *testing.T, s string) {
 	}
 }
 
+// stopAndDrain stops r and waits for it to release its computations, so that
+// no rerun outlives the test.
+func stopAndDrain(t *testing.T, r *Rerunner) {
+	r.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := r.Drain(ctx); err != nil {
+		t.Errorf("expected rerunner to drain: %v", err)
+	}
+}
+
 // TestRerun tests that a computation is rerun after it is invalidated.
 func TestRerun(t *testing.T) {
 	released := NewExpect()
@@ -142,6 +154,110 @@ func TestRerunCache(t *testing.T) {
 	run.Expect(t, "expected rerun")
 }
 
+// TestOnInvalidate tests that finalizers run once their computation is
+// invalidated or released, but not while it stays cached.
+func TestOnInvalidate(t *testing.T) {
+	outer := NewResource()
+	inner := NewResource()
+
+	run := NewExpect()
+	var innerFinalized, rootFinalized int32
+	innerFinalizer := NewExpect()
+	rootFinalizer := NewExpect()
+
+	runner := NewRerunner(context.Background(), func(ctx context.Context) (interface{}, error) {
+		AddDependency(ctx, outer, nil)
+		OnInvalidate(ctx, func() {
+			if atomic.AddInt32(&rootFinalized, 1) == 2 {
+				rootFinalizer.Trigger()
+			}
+		})
+
+		Cache(ctx, 0, func(ctx context.Context) (interface{}, error) {
+			AddDependency(ctx, inner, nil)
+			OnInvalidate(ctx, func() {
+				if atomic.AddInt32(&innerFinalized, 1) == 1 {
+					innerFinalizer.Trigger()
+				}
+			})
+			return nil, nil
+		})
+
+		run.Trigger()
+		return nil, nil
+	}, 0, false)
+
+	run.Expect(t, "expected run")
+
+	// Rerunning the root computation finalizes it, but keeps the cached
+	// computation.
+	run = NewExpect()
+	outer.Strobe()
+	run.Expect(t, "expected rerun")
+	if n := atomic.LoadInt32(&innerFinalized); n != 0 {
+		t.Errorf("expected cached computation to stay alive, but it was finalized %d times", n)
+	}
+
+	// Invalidating the cached computation finalizes it once.
+	run = NewExpect()
+	inner.Strobe()
+	innerFinalizer.Expect(t, "expected cached computation to be finalized")
+	run.Expect(t, "expected rerun")
+
+	// Stopping the rerunner finalizes the current computation.
+	runner.Stop()
+	rootFinalizer.Expect(t, "expected root computation to be finalized")
+
+	// Outside of a rerunner, finalizers are called right away.
+	called := NewExpect()
+	OnInvalidate(context.Background(), called.Trigger)
+	called.Expect(t, "expected finalizer without rerunner to be called")
+}
+
+// TestCacheWithTTL tests that a cached computation is reused until its TTL
+// expires, and is then rerun.
+func TestCacheWithTTL(t *testing.T) {
+	dep := NewResource()
+
+	var innerRuns int64
+	runs := make(chan int64, 10)
+
+	runner := NewRerunner(context.Background(), func(ctx context.Context) (interface{}, error) {
+		AddDependency(ctx, dep, nil)
+
+		value, err := CacheWithTTL(ctx, 0, 200*time.Millisecond, func(ctx context.Context) (interface{}, error) {
+			return atomic.AddInt64(&innerRuns, 1), nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		runs <- value.(int64)
+		return nil, nil
+	}, 0, false)
+	defer runner.Stop()
+
+	expect := func(expected int64, s string) {
+		select {
+		case value := <-runs:
+			if value != expected {
+				t.Errorf("%s: expected cached value %d, got %d", s, expected, value)
+			}
+		case <-time.After(2 * time.Second):
+			t.Error(s)
+		}
+	}
+
+	expect(1, "expected run")
+
+	// Before the TTL, the cached value is reused.
+	dep.Strobe()
+	expect(1, "expected rerun")
+
+	// After the TTL, the cached computation and its parent run again.
+	expect(2, "expected rerun after ttl")
+}
+
 // TestStop tests that a runner stops recomputating after Stop is called.
 func TestStop(t *testing.T) {
 	dep := NewResource()
@@ -162,6 +278,54 @@ func TestStop(t *testing.T) {
 	// run is supposed to stop; if it runs, it will panic in calling Trigger
 }
 
+// TestCancelDrain tests that canceling a runner's context releases its
+// dependencies, and that Drain waits for their cleanup.
+func TestCancelDrain(t *testing.T) {
+	var cleanedUp int32
+	dep := NewResource()
+	dep.Cleanup(func() {
+		// Slow cleanup handlers delay Drain.
+		time.Sleep(50 * time.Millisecond)
+		atomic.StoreInt32(&cleanedUp, 1)
+	})
+	cached := NewResource()
+	cached.Cleanup(func() {})
+
+	run := NewExpect()
+	ctx, cancel := context.WithCancel(context.Background())
+	runner := NewRerunner(ctx, func(ctx context.Context) (interface{}, error) {
+		AddDependency(ctx, dep, nil)
+		if _, err := Cache(ctx, "key", func(ctx context.Context) (interface{}, error) {
+			AddDependency(ctx, cached, nil)
+			return nil, nil
+		}); err != nil {
+			return nil, err
+		}
+		run.Trigger()
+		return nil, nil
+	}, 0, false)
+	run.Expect(t, "expected run")
+
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), time.Millisecond)
+	if err := runner.Drain(drainCtx); err != context.DeadlineExceeded {
+		t.Errorf("expected Drain to time out before cancel, got %v", err)
+	}
+	drainCancel()
+
+	cancel()
+	drainCtx, drainCancel = context.WithTimeout(context.Background(), 2*time.Second)
+	defer drainCancel()
+	if err := runner.Drain(drainCtx); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&cleanedUp) != 1 {
+		t.Error("expected cleanup before Drain returns")
+	}
+	if n := len(runner.cache.computations); n != 0 {
+		t.Errorf("expected cache to be released, got %d computations", n)
+	}
+}
+
 func TestError(t *testing.T) {
 	dep := NewResource()
 
@@ -355,7 +519,7 @@ func TestMinRerunInterval(t *testing.T) {
 	r := NewResource()
 	var ran time.Time
 
-	NewRerunner(context.Background(), func(ctx context.Context) (interface{}, error) {
+	runner := NewRerunner(context.Background(), func(ctx context.Context) (interface{}, error) {
 		AddDependency(ctx, r, nil)
 		run.Trigger()
 
@@ -370,6 +534,7 @@ func TestMinRerunInterval(t *testing.T) {
 
 		return nil, nil
 	}, 1*time.Second, false)
+	defer stopAndDrain(t, runner)
 
 	run.Expect(t, "expected run")
 
@@ -401,6 +566,7 @@ func TestRerunImmediately(t *testing.T) {
 
 		return nil, nil
 	}, 30*time.Second, false)
+	defer stopAndDrain(t, runner)
 
 	run.Expect(t, "expected run")
 
@@ -409,3 +575,131 @@ func TestRerunImmediately(t *testing.T) {
 	r.Invalidate()
 	run.Expect(t, "expected rerun")
 }
+
+// TestInvalidationTracing tests that rerunners record why they reran.
+func TestInvalidationTracing(t *testing.T) {
+	table := NewNamedResource("users")
+	other := NewResource()
+
+	runs := make(chan struct{}, 10)
+	runner := NewRerunner(WithInvalidationTracing(context.Background(), 1), func(ctx context.Context) (interface{}, error) {
+		AddDependency(ctx, other, nil)
+		Cache(ctx, "allUsers", func(ctx context.Context) (interface{}, error) {
+			AddDependency(ctx, table, nil)
+			return nil, nil
+		})
+		runs <- struct{}{}
+		return nil, nil
+	}, 0, false)
+	defer runner.Stop()
+
+	expectRun := func() {
+		select {
+		case <-runs:
+		case <-time.After(2 * time.Second):
+			t.Fatal("expected (re-)run")
+		}
+	}
+
+	expectRun()
+	if stats := runner.Stats(); stats.Runs < 1 || len(stats.Traces) != 0 {
+		t.Errorf("unexpected stats after first run: %+v", stats)
+	}
+
+	table.Strobe()
+	expectRun()
+	traces := runner.Stats().Traces
+	if len(traces) != 1 || !reflect.DeepEqual(traces[0].Chain, []string{"resource users", "cache allUsers"}) {
+		t.Errorf("unexpected traces: %+v", traces)
+	}
+
+	other.Strobe()
+	expectRun()
+	traces = runner.Stats().Traces
+	if len(traces) != 1 || !reflect.DeepEqual(traces[0].Chain, []string{"resource"}) {
+		t.Errorf("unexpected traces: %+v", traces)
+	}
+}
+
+// TestStrictDependencies tests that dependencies added after a computation
+// returned are reported.
+func TestStrictDependencies(t *testing.T) {
+	dep := NewResource()
+	late := make(chan context.Context, 1)
+	reports := make(chan *LateDependencyError, 2)
+
+	ctx := WithStrictDependencies(context.Background(), func(err *LateDependencyError) {
+		reports <- err
+	})
+	runner := NewRerunner(ctx, func(ctx context.Context) (interface{}, error) {
+		AddDependency(ctx, dep, nil)
+		select {
+		case late <- ctx:
+		default:
+		}
+		return nil, nil
+	}, 0, false)
+	defer runner.Stop()
+
+	var computationCtx context.Context
+	select {
+	case computationCtx = <-late:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected run")
+	}
+	// Wait for the computation to return.
+	runner.mu.Lock()
+	runner.mu.Unlock()
+
+	AddDependency(computationCtx, NewResource(), nil)
+	Cache(computationCtx, "key", func(ctx context.Context) (interface{}, error) {
+		return nil, nil
+	})
+	for _, fn := range []string{"AddDependency", "Cache"} {
+		select {
+		case err := <-reports:
+			if err.Func != fn || len(err.Stack) == 0 {
+				t.Errorf("unexpected report: %v", err)
+			}
+		default:
+			t.Errorf("expected late %s to be reported", fn)
+		}
+	}
+
+	// Dependencies added while the computation runs are not reported, and
+	// without strict mode late dependencies are not reported either.
+	runner2 := NewRerunner(context.Background(), func(ctx context.Context) (interface{}, error) {
+		AddDependency(ctx, dep, nil)
+		late <- ctx
+		return nil, nil
+	}, 0, false)
+	defer runner2.Stop()
+	computationCtx = <-late
+	runner2.mu.Lock()
+	runner2.mu.Unlock()
+	AddDependency(computationCtx, NewResource(), nil)
+	if len(reports) != 0 {
+		t.Errorf("unexpected reports: %d", len(reports))
+	}
+}
+
+// TestStrictDependenciesPanics tests that late dependencies panic without a
+// report function.
+func TestStrictDependenciesPanics(t *testing.T) {
+	late := make(chan context.Context, 1)
+	runner := NewRerunner(WithStrictDependencies(context.Background(), nil), func(ctx context.Context) (interface{}, error) {
+		late <- ctx
+		return nil, nil
+	}, 0, false)
+	defer runner.Stop()
+	ctx := <-late
+	runner.mu.Lock()
+	runner.mu.Unlock()
+
+	defer func() {
+		if _, ok := recover().(*LateDependencyError); !ok {
+			t.Error("expected late dependency panic")
+		}
+	}()
+	AddDependency(ctx, NewResource(), nil)
+}
@@ -0,0 +1,101 @@
+package reactive
+
+import (
+	"context"
+	"sync"
+)
+
+// Snapshot describes the cached computations of a Rerunner, so that a new
+// Rerunner, eg. after a restart, can be warmed up with them, see
+// WithWarmStart.
+type Snapshot struct {
+	// Keys are the keys passed to Cache of the valid cached computations, in
+	// no particular order.
+	Keys []interface{}
+}
+
+// Snapshot returns the keys of the valid cached computations of r accepted by
+// filter, or all of them if filter is nil. Keys are typically filtered to
+// those the caller can serialize, eg. to store them with the client's session
+// during a deploy.
+func (r *Rerunner) Snapshot(filter func(key interface{}) bool) Snapshot {
+	r.cache.mu.Lock()
+	defer r.cache.mu.Unlock()
+
+	var snapshot Snapshot
+	for key, computation := range r.cache.computations {
+		if computation.node.Invalidated() || (filter != nil && !filter(key)) {
+			continue
+		}
+		snapshot.Keys = append(snapshot.Keys, key)
+	}
+	return snapshot
+}
+
+// WarmFunc computes the value of a cached computation with key for a warm
+// start, see WithWarmStart. Like the function passed to Cache, it must add
+// the dependencies of the value with AddDependency.
+type WarmFunc func(ctx context.Context, key interface{}) (interface{}, error)
+
+type warmStartKey struct{}
+
+type warmStart struct {
+	snapshot Snapshot
+	warm     WarmFunc
+}
+
+// WithWarmStart warms up the cache of Rerunners created with ctx with the
+// keys of snapshot before their first run. Every key is computed with warm,
+// which can read from a shared or persistent cache instead of recomputing the
+// value from scratch, so that clients reconnecting after a restart do not all
+// hit the underlying data sources at once. Calls to Cache in the first run
+// then reuse the warmed values.
+//
+// Keys that warm fails for are skipped, and warmed values that the first run
+// does not use are released after it.
+func WithWarmStart(ctx context.Context, snapshot Snapshot, warm WarmFunc) context.Context {
+	return context.WithValue(ctx, warmStartKey{}, &warmStart{snapshot: snapshot, warm: warm})
+}
+
+// warmStartConcurrency is the maximum number of keys warmed up at once.
+const warmStartConcurrency = 8
+
+// warmStart computes the keys of the WithWarmStart snapshot of ctx into the
+// cache of r, up to warmStartConcurrency at a time. It returns a computation
+// holding the warmed computations, to be released after the first run, or
+// nil if ctx has no snapshot.
+func (r *Rerunner) warmStart(ctx context.Context) *computation {
+	ws, ok := ctx.Value(warmStartKey{}).(*warmStart)
+	if !ok || len(ws.snapshot.Keys) == 0 {
+		return nil
+	}
+
+	holder := &computation{node: node{label: "warm start"}}
+	ctx = context.WithValue(ctx, cacheKey{}, r.cache)
+	ctx = context.WithValue(ctx, dependencySetKey{}, &dependencySet{})
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, warmStartConcurrency)
+	for _, key := range ws.snapshot.Keys {
+		if ctx.Err() != nil {
+			break
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(key interface{}) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			child, err := run(ctx, key, func(ctx context.Context) (interface{}, error) {
+				return ws.warm(ctx, key)
+			})
+			if err != nil {
+				return
+			}
+			r.cache.set(key, child)
+			child.node.addOut(&holder.node)
+		}(key)
+	}
+	wg.Wait()
+	return holder
+}
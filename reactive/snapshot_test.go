@@ -0,0 +1,131 @@
+package reactive
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+type snapshotTestKey struct{}
+
+// TestSnapshot tests that a snapshot lists the keys of valid cached
+// computations accepted by the filter.
+func TestSnapshot(t *testing.T) {
+	run := NewExpect()
+	dep := NewResource()
+	runner := NewRerunner(context.Background(), func(ctx context.Context) (interface{}, error) {
+		for _, key := range []interface{}{"a", "b", snapshotTestKey{}} {
+			Cache(ctx, key, func(ctx context.Context) (interface{}, error) {
+				AddDependency(ctx, dep, nil)
+				return nil, nil
+			})
+		}
+		run.Trigger()
+		return nil, nil
+	}, 0, false)
+	defer stopAndDrain(t, runner)
+	run.Expect(t, "expected run")
+
+	snapshot := runner.Snapshot(func(key interface{}) bool {
+		_, ok := key.(string)
+		return ok
+	})
+	keys := make([]string, 0, len(snapshot.Keys))
+	for _, key := range snapshot.Keys {
+		keys = append(keys, key.(string))
+	}
+	sort.Strings(keys)
+	if !reflect.DeepEqual(keys, []string{"a", "b"}) {
+		t.Errorf("expected keys a and b, got %v", keys)
+	}
+	if all := runner.Snapshot(nil); len(all.Keys) != 3 {
+		t.Errorf("expected 3 keys, got %v", all.Keys)
+	}
+}
+
+// TestWarmStart tests that the first run reuses warmed computations, and that
+// unused warmed computations are released.
+func TestWarmStart(t *testing.T) {
+	depA := NewResource()
+	depB := NewResource()
+	releasedB := NewExpect()
+	depB.Cleanup(releasedB.Trigger)
+
+	warm := func(ctx context.Context, key interface{}) (interface{}, error) {
+		switch key {
+		case "a":
+			AddDependency(ctx, depA, nil)
+		case "b":
+			AddDependency(ctx, depB, nil)
+		}
+		return "warm " + key.(string), nil
+	}
+	ctx := WithWarmStart(context.Background(), Snapshot{Keys: []interface{}{"a", "b"}}, warm)
+
+	values := make(chan interface{}, 2)
+	runner := NewRerunner(ctx, func(ctx context.Context) (interface{}, error) {
+		value, _ := Cache(ctx, "a", func(ctx context.Context) (interface{}, error) {
+			AddDependency(ctx, depA, nil)
+			return "computed a", nil
+		})
+		values <- value
+		return nil, nil
+	}, 0, false)
+	defer stopAndDrain(t, runner)
+
+	if value := <-values; value != "warm a" {
+		t.Errorf("expected warmed value, got %v", value)
+	}
+	releasedB.Expect(t, "expected unused warmed computation to be released")
+
+	// Warmed computations are invalidated by their dependencies.
+	depA.Strobe()
+	if value := <-values; value != "computed a" {
+		t.Errorf("expected recomputed value, got %v", value)
+	}
+}
+
+// TestWarmStartConcurrent tests that keys are warmed up concurrently, and that
+// the rerunner can be stopped while they are warming up.
+func TestWarmStartConcurrent(t *testing.T) {
+	started := make(chan struct{}, 2)
+	unblock := make(chan struct{})
+	warm := func(ctx context.Context, key interface{}) (interface{}, error) {
+		started <- struct{}{}
+		<-unblock
+		return key, nil
+	}
+	ctx := WithWarmStart(context.Background(), Snapshot{Keys: []interface{}{"a", "b"}}, warm)
+
+	ran := make(chan struct{}, 1)
+	runner := NewRerunner(ctx, func(ctx context.Context) (interface{}, error) {
+		ran <- struct{}{}
+		return nil, nil
+	}, 0, false)
+
+	// Both keys start warming before either finishes.
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatal("expected keys to warm up concurrently")
+		}
+	}
+
+	stopped := NewExpect()
+	go func() {
+		runner.Stop()
+		stopped.Trigger()
+	}()
+	stopped.Expect(t, "expected Stop not to wait for the warm start")
+
+	close(unblock)
+	select {
+	case <-ran:
+		t.Error("expected no run after Stop")
+	case <-time.After(100 * time.Millisecond):
+	}
+	stopAndDrain(t, runner)
+}
@@ -0,0 +1,56 @@
+package reactive
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
+)
+
+// LateDependencyError describes a call of AddDependency or Cache with the
+// context of a computation that already returned, eg. from a goroutine that
+// outlived the computation. The dependency is not tracked, so changes to it
+// silently do not rerun the computation.
+type LateDependencyError struct {
+	// Func is the late function, AddDependency or Cache.
+	Func string
+	// Stack is the stack of the late call.
+	Stack []byte
+}
+
+func (e *LateDependencyError) Error() string {
+	return fmt.Sprintf("reactive: %s called after its computation returned\n%s", e.Func, e.Stack)
+}
+
+type strictDependenciesKey struct{}
+
+// WithStrictDependencies configures computations run with ctx to detect calls
+// of AddDependency and Cache after the computation returned. Each late call
+// is passed to report, or panics if report is nil.
+//
+// Detection is meant for debugging and tests: it only catches calls that
+// happen after the computation returned, not calls racing with its return.
+func WithStrictDependencies(ctx context.Context, report func(err *LateDependencyError)) context.Context {
+	if report == nil {
+		report = func(err *LateDependencyError) {
+			panic(err)
+		}
+	}
+	return context.WithValue(ctx, strictDependenciesKey{}, report)
+}
+
+// finish marks c as returned.
+func (c *computation) finish() {
+	atomic.StoreInt32(&c.finished, 1)
+}
+
+// checkLate reports a call of fn with the context of c, if c already returned
+// and ctx is strict.
+func checkLate(ctx context.Context, c *computation, fn string) {
+	if atomic.LoadInt32(&c.finished) == 0 {
+		return
+	}
+	if report, ok := ctx.Value(strictDependenciesKey{}).(func(err *LateDependencyError)); ok {
+		report(&LateDependencyError{Func: fn, Stack: debug.Stack()})
+	}
+}
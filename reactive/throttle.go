@@ -0,0 +1,54 @@
+package reactive
+
+import (
+	"context"
+	"time"
+)
+
+type throttledCacheKey struct {
+	key interface{}
+}
+
+// CacheThrottled is like Cache, but coalesces the invalidations of the cached
+// value so that it is recomputed at most once every interval. When one of its
+// dependencies changes, reruns keep using the stale value until interval has
+// passed since it was computed, and only then invalidate the computations
+// using it. Use it for values that change often but need not be updated as
+// often, while the rest of a computation updates freely.
+func CacheThrottled(ctx context.Context, key interface{}, interval time.Duration, f ComputeFunc) (interface{}, error) {
+	if !HasRerunner(ctx) {
+		return f(ctx)
+	}
+
+	return Cache(ctx, throttledCacheKey{key: key}, func(ctx context.Context) (interface{}, error) {
+		computed := time.Now()
+
+		// The value is computed in its own computation, which the cached
+		// computation does not depend on. Instead, the cached computation
+		// depends on throttled, which is invalidated once the value changed
+		// and interval has passed.
+		throttled := NewResource()
+		AddDependency(ctx, throttled, nil)
+
+		inner, err := run(ctx, key, f)
+		if err != nil {
+			return nil, err
+		}
+
+		// watcher keeps inner alive until the cached computation is released,
+		// and schedules the invalidation when inner is invalidated.
+		watcher := &node{label: key}
+		watcher.handleInvalidate(func() {
+			delay := interval - time.Since(computed)
+			if delay <= 0 {
+				throttled.Invalidate()
+				return
+			}
+			time.AfterFunc(delay, throttled.Invalidate)
+		})
+		inner.node.addOut(watcher)
+		throttled.Cleanup(func() { async(watcher.release) })
+
+		return inner.value, nil
+	})
+}
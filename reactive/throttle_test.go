@@ -0,0 +1,60 @@
+package reactive
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestCacheThrottled tests that a throttled cached value is reused until the
+// interval passed, while the rest of the computation reruns freely.
+func TestCacheThrottled(t *testing.T) {
+	throttledDep := NewResource()
+	otherDep := NewResource()
+
+	var innerRuns int64
+	runs := make(chan int64, 10)
+	interval := 500 * time.Millisecond
+
+	start := time.Now()
+	runner := NewRerunner(context.Background(), func(ctx context.Context) (interface{}, error) {
+		AddDependency(ctx, otherDep, nil)
+
+		value, err := CacheThrottled(ctx, 0, interval, func(ctx context.Context) (interface{}, error) {
+			AddDependency(ctx, throttledDep, nil)
+			return atomic.AddInt64(&innerRuns, 1), nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		runs <- value.(int64)
+		return nil, nil
+	}, 0, false)
+	defer runner.Stop()
+
+	expect := func(expected int64, s string) {
+		select {
+		case value := <-runs:
+			if value != expected {
+				t.Errorf("%s: expected throttled value %d, got %d", s, expected, value)
+			}
+		case <-time.After(2 * time.Second):
+			t.Error(s)
+		}
+	}
+
+	expect(1, "expected run")
+
+	// Reruns before the interval reuse the stale value.
+	throttledDep.Strobe()
+	otherDep.Strobe()
+	expect(1, "expected rerun")
+
+	// Once the interval passed, the value is recomputed.
+	expect(2, "expected rerun after interval")
+	if elapsed := time.Since(start); elapsed < interval {
+		t.Errorf("expected recompute after %s, got %s", interval, elapsed)
+	}
+}
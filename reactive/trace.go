@@ -0,0 +1,100 @@
+package reactive
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// InvalidationTrace describes why a Rerunner reran its computation.
+type InvalidationTrace struct {
+	// Time is when the computation was invalidated.
+	Time time.Time
+	// Chain lists the invalidated nodes, starting with the Resource that
+	// triggered the invalidation and followed by the cached computations that
+	// depended on it, up to the rerunner's computation. If several resources
+	// changed at once, only the first invalidation is traced.
+	Chain []string
+}
+
+// RerunnerStats describes the runs of a Rerunner.
+type RerunnerStats struct {
+	// Runs is the number of times the computation ran.
+	Runs int64
+	// LastRun is when the computation last ran.
+	LastRun time.Time
+	// Throttled is the number of reruns delayed by the quota of the
+	// Rerunner's tenant, see WithTenant.
+	Throttled int64
+	// Traces holds the most recent invalidation traces, oldest first. It is
+	// only populated if the Rerunner was created with WithInvalidationTracing.
+	Traces []InvalidationTrace
+}
+
+type invalidationTracingKey struct{}
+
+// WithInvalidationTracing configures Rerunners created with ctx to trace why
+// they rerun, keeping the last maxTraces traces in their Stats. Tracing helps
+// debug spurious reruns, such as subscription updates without any changes.
+func WithInvalidationTracing(ctx context.Context, maxTraces int) context.Context {
+	return context.WithValue(ctx, invalidationTracingKey{}, maxTraces)
+}
+
+// invalidationTracing returns the number of traces to keep for ctx.
+func invalidationTracing(ctx context.Context) int {
+	maxTraces, _ := ctx.Value(invalidationTracingKey{}).(int)
+	return maxTraces
+}
+
+// describe returns a human-readable description of n for traces.
+func (n *node) describe() string {
+	switch label := n.label.(type) {
+	case nil:
+		return "resource"
+	case resourceName:
+		return fmt.Sprintf("resource %s", string(label))
+	default:
+		return fmt.Sprintf("cache %v", label)
+	}
+}
+
+// resourceName labels named resources.
+type resourceName string
+
+// traceInvalidation builds a trace for an invalidated computation.
+func traceInvalidation(c *computation) InvalidationTrace {
+	var chain []string
+	for n := c.node.cause(); n != nil; n = n.cause() {
+		chain = append(chain, n.describe())
+	}
+	// Reverse the chain to start at the resource.
+	for i := 0; i < len(chain)/2; i++ {
+		j := len(chain) - 1 - i
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return InvalidationTrace{
+		Time:  time.Now(),
+		Chain: chain,
+	}
+}
+
+// recordTrace adds a trace to the stats, dropping the oldest trace if needed.
+func (r *Rerunner) recordTrace(trace InvalidationTrace) {
+	r.statsMu.Lock()
+	defer r.statsMu.Unlock()
+
+	r.stats.Traces = append(r.stats.Traces, trace)
+	if len(r.stats.Traces) > r.maxTraces {
+		r.stats.Traces = r.stats.Traces[len(r.stats.Traces)-r.maxTraces:]
+	}
+}
+
+// Stats returns a snapshot of the rerunner's stats.
+func (r *Rerunner) Stats() RerunnerStats {
+	r.statsMu.Lock()
+	defer r.statsMu.Unlock()
+
+	stats := r.stats
+	stats.Traces = append([]InvalidationTrace(nil), r.stats.Traces...)
+	return stats
+}
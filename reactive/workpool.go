@@ -0,0 +1,214 @@
+package reactive
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// Node graph maintenance (invalidations, strobes, and releases) runs
+// asynchronously. Instead of spawning a goroutine for every invalidation,
+// which explodes under bursty invalidation, it runs on a bounded pool of
+// workers. Work that arrives while all workers are busy waits in the pool's
+// bounded queue. Callers never block on a full pool, as they may hold locks
+// that the work needs, so work that arrives while the queue is full runs on
+// its own goroutine instead, and is counted as overflowed.
+//
+// Code of the package's users, reruns of Rerunners and Cleanup and
+// OnInvalidate handlers, never runs on the graph maintenance pool, as slow
+// computations and handlers would hold up the invalidations of other
+// Rerunners. By default it runs on its own goroutines. SetComputeWorkPool
+// opts into a second bounded pool, the compute pool, which caps the number of
+// concurrent reruns and handlers. The first run of a Rerunner always runs on
+// its own goroutine, so that the compute pool never holds up new queries.
+
+const (
+	// DefaultGraphWorkers is the default number of graph maintenance workers.
+	DefaultGraphWorkers = 64
+	// DefaultComputeWorkers is the default number of compute workers. With
+	// no workers, the compute pool is disabled.
+	DefaultComputeWorkers = 0
+	// MaxQueueDepth is the number of pieces of work a pool queues at most.
+	MaxQueueDepth = 4096
+)
+
+// WorkPoolStats describes the work of a worker pool.
+type WorkPoolStats struct {
+	// Workers is the maximum number of workers, and Running the number of
+	// workers started so far.
+	Workers int
+	Running int
+	// QueueDepth is the amount of work waiting for a worker, and
+	// MaxQueueDepth the largest QueueDepth so far.
+	QueueDepth    int
+	MaxQueueDepth int
+	// Overflowed is the amount of work that ran on its own goroutine, as the
+	// queue was full or the pool is disabled.
+	Overflowed uint64
+	// Completed is the amount of work run so far.
+	Completed uint64
+}
+
+// workPool runs work on at most workers goroutines, started as work arrives,
+// and queues at most maxQueue pieces of work. A pool without workers runs
+// all work on its own goroutines.
+type workPool struct {
+	// completed and overflowed are accessed atomically.
+	completed  uint64
+	overflowed uint64
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	workers  int
+	maxQueue int
+	// started is set once p ran work.
+	started bool
+	running int
+	// idle is the number of workers waiting for work that have not been
+	// signaled yet.
+	idle     int
+	queue    []func()
+	maxDepth int
+}
+
+func newWorkPool(workers int) *workPool {
+	p := &workPool{workers: workers, maxQueue: MaxQueueDepth}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+var (
+	graphWork   = newWorkPool(DefaultGraphWorkers)
+	computeWork = newWorkPool(DefaultComputeWorkers)
+)
+
+var (
+	errGraphWorkStarted   = errors.New("graph work pool already started")
+	errComputeWorkStarted = errors.New("compute work pool already started")
+)
+
+// SetGraphWorkPool sets the number of graph maintenance workers. It must be
+// called before the first invalidation, and fails otherwise.
+func SetGraphWorkPool(workers int) error {
+	return graphWork.resize(workers, errGraphWorkStarted)
+}
+
+// SetComputeWorkPool enables the compute pool with the given number of
+// workers, which then run reruns and Cleanup and OnInvalidate handlers. It
+// must be called before the first rerun or handler, and fails otherwise.
+func SetComputeWorkPool(workers int) error {
+	return computeWork.resize(workers, errComputeWorkStarted)
+}
+
+// ReadGraphWorkStats returns statistics of the graph maintenance pool, such
+// as its queue depth.
+func ReadGraphWorkStats() WorkPoolStats {
+	return graphWork.stats()
+}
+
+// ReadComputeWorkStats returns statistics of the compute pool, such as its
+// queue depth.
+func ReadComputeWorkStats() WorkPoolStats {
+	return computeWork.stats()
+}
+
+// resize sets the number of workers of p, or returns started if p already
+// ran work.
+func (p *workPool) resize(workers int, started error) error {
+	if workers < 1 {
+		return errors.New("work pool needs at least one worker")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.started {
+		return started
+	}
+	p.workers = workers
+	return nil
+}
+
+func (p *workPool) stats() WorkPoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return WorkPoolStats{
+		Workers:       p.workers,
+		Running:       p.running,
+		QueueDepth:    len(p.queue),
+		MaxQueueDepth: p.maxDepth,
+		Overflowed:    atomic.LoadUint64(&p.overflowed),
+		Completed:     atomic.LoadUint64(&p.completed),
+	}
+}
+
+// run queues f to run on a worker, starting a worker if all workers are busy
+// and fewer than p.workers are running. If p has no workers or its queue is
+// full, f runs on its own goroutine.
+func (p *workPool) run(f func()) {
+	p.mu.Lock()
+	p.started = true
+	if p.workers == 0 || (p.idle == 0 && p.running >= p.workers && len(p.queue) >= p.maxQueue) {
+		p.mu.Unlock()
+		atomic.AddUint64(&p.overflowed, 1)
+		go func() {
+			f()
+			atomic.AddUint64(&p.completed, 1)
+		}()
+		return
+	}
+
+	p.queue = append(p.queue, f)
+	if len(p.queue) > p.maxDepth {
+		p.maxDepth = len(p.queue)
+	}
+	if p.idle > 0 {
+		p.idle--
+		p.cond.Signal()
+	} else if p.running < p.workers {
+		p.running++
+		go p.work()
+	}
+	p.mu.Unlock()
+}
+
+func (p *workPool) work() {
+	p.mu.Lock()
+	for {
+		for len(p.queue) == 0 {
+			p.idle++
+			p.cond.Wait()
+		}
+		f := p.queue[0]
+		p.queue[0] = nil
+		p.queue = p.queue[1:]
+		if len(p.queue) == 0 {
+			// Let the backing array of a burst be garbage collected.
+			p.queue = nil
+		}
+		p.mu.Unlock()
+
+		f()
+		atomic.AddUint64(&p.completed, 1)
+		p.mu.Lock()
+	}
+}
+
+// async runs graph maintenance work f asynchronously.
+func async(f func()) {
+	graphWork.run(f)
+}
+
+// runHandler runs the user handler f on the compute pool, or on its own
+// goroutine if the compute pool is disabled. If handlers is not nil, it
+// tracks f until f returns.
+func runHandler(f func(), handlers *sync.WaitGroup) {
+	if handlers != nil {
+		handlers.Add(1)
+	}
+	computeWork.run(func() {
+		if handlers != nil {
+			defer handlers.Done()
+		}
+		f()
+	})
+}
@@ -0,0 +1,293 @@
+package reactive
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkPoolQueues(t *testing.T) {
+	p := newWorkPool(1)
+
+	// Block the only worker, and queue more work.
+	block := make(chan struct{})
+	started := make(chan struct{})
+	p.run(func() {
+		close(started)
+		<-block
+	})
+	<-started
+
+	var wg sync.WaitGroup
+	var ran int64
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		p.run(func() {
+			atomic.AddInt64(&ran, 1)
+			wg.Done()
+		})
+	}
+
+	// The work waits in the queue instead of running on new goroutines.
+	stats := p.stats()
+	assert.Equal(t, 1, stats.Running)
+	assert.Equal(t, 3, stats.QueueDepth)
+	assert.Equal(t, 3, stats.MaxQueueDepth)
+	assert.Equal(t, int64(0), atomic.LoadInt64(&ran))
+
+	close(block)
+	wg.Wait()
+	assert.Equal(t, int64(3), atomic.LoadInt64(&ran))
+	assert.Equal(t, 0, p.stats().QueueDepth)
+}
+
+func TestWorkPoolCapsGoroutines(t *testing.T) {
+	const workers = 4
+	p := newWorkPool(workers)
+
+	// Submit more blocking work than there are workers.
+	before := runtime.NumGoroutine()
+	block := make(chan struct{})
+	var wg sync.WaitGroup
+	var running, maxRunning int64
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		p.run(func() {
+			defer wg.Done()
+			n := atomic.AddInt64(&running, 1)
+			for {
+				max := atomic.LoadInt64(&maxRunning)
+				if n <= max || atomic.CompareAndSwapInt64(&maxRunning, max, n) {
+					break
+				}
+			}
+			<-block
+			atomic.AddInt64(&running, -1)
+		})
+	}
+
+	for atomic.LoadInt64(&running) < workers {
+		time.Sleep(time.Millisecond)
+	}
+	stats := p.stats()
+	assert.Equal(t, workers, stats.Running)
+	assert.Equal(t, 100-workers, stats.QueueDepth)
+	assert.True(t, runtime.NumGoroutine()-before <= workers, "expected at most %d new goroutines", workers)
+
+	close(block)
+	wg.Wait()
+	assert.Equal(t, int64(workers), atomic.LoadInt64(&maxRunning))
+	assert.Equal(t, workers, p.stats().Running)
+}
+
+func TestWorkPoolBoundsQueue(t *testing.T) {
+	p := newWorkPool(1)
+	p.maxQueue = 2
+
+	// Block the only worker, and fill the queue.
+	block := make(chan struct{})
+	started := make(chan struct{})
+	p.run(func() {
+		close(started)
+		<-block
+	})
+	<-started
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		p.run(func() {
+			defer wg.Done()
+			<-block
+		})
+	}
+
+	// Work beyond the queue runs on its own goroutine.
+	overflowed := make(chan struct{})
+	p.run(func() { close(overflowed) })
+	<-overflowed
+
+	stats := p.stats()
+	assert.Equal(t, 2, stats.QueueDepth)
+	assert.Equal(t, uint64(1), stats.Overflowed)
+
+	close(block)
+	wg.Wait()
+}
+
+func TestWorkPoolDisabled(t *testing.T) {
+	p := newWorkPool(0)
+
+	block := make(chan struct{})
+	var started sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		started.Add(1)
+		p.run(func() {
+			started.Done()
+			<-block
+		})
+	}
+	started.Wait()
+	close(block)
+
+	stats := p.stats()
+	assert.Equal(t, 0, stats.Running)
+	assert.Equal(t, uint64(10), stats.Overflowed)
+}
+
+func TestSetWorkPoolAfterStart(t *testing.T) {
+	r := NewResource()
+	r.Invalidate()
+	assert.Equal(t, errGraphWorkStarted, SetGraphWorkPool(1))
+
+	stats := ReadGraphWorkStats()
+	assert.Equal(t, DefaultGraphWorkers, stats.Workers)
+
+	done := make(chan struct{})
+	runHandler(func() { close(done) }, nil)
+	<-done
+	assert.Equal(t, errComputeWorkStarted, SetComputeWorkPool(1))
+	assert.Equal(t, DefaultComputeWorkers, ReadComputeWorkStats().Workers)
+}
+
+// useComputeWorkPool enables a compute pool with workers for a test, and
+// returns a function restoring the previous pool.
+func useComputeWorkPool(workers int) func() {
+	previous := computeWork
+	computeWork = newWorkPool(workers)
+	return func() { computeWork = previous }
+}
+
+// TestFirstRunsOffComputePool tests that the first runs of Rerunners do not
+// wait for compute workers, even when all of them are busy.
+func TestFirstRunsOffComputePool(t *testing.T) {
+	const workers = 4
+	defer useComputeWorkPool(workers)()
+
+	block := make(chan struct{})
+	var started sync.WaitGroup
+	var rerunners []*Rerunner
+	for i := 0; i < 3*workers; i++ {
+		started.Add(1)
+		rerunners = append(rerunners, NewRerunner(context.Background(), func(ctx context.Context) (interface{}, error) {
+			started.Done()
+			<-block
+			return nil, nil
+		}, 0, false))
+	}
+	started.Wait()
+	close(block)
+	for _, r := range rerunners {
+		r.Stop()
+	}
+}
+
+// TestBurstyRerunsOnComputePool tests that reruns of more invalidated
+// Rerunners than there are compute workers queue up instead of running on
+// new goroutines.
+func TestBurstyRerunsOnComputePool(t *testing.T) {
+	const workers = 8
+	defer useComputeWorkPool(workers)()
+	ctx := context.Background()
+
+	dep := NewResource()
+	block := make(chan struct{})
+	var firstRuns, reruns sync.WaitGroup
+	var running, maxRunning int64
+	var rerunners []*Rerunner
+	for i := 0; i < workers+8; i++ {
+		firstRuns.Add(1)
+		reruns.Add(1)
+		first := true
+		rerunners = append(rerunners, NewRerunner(ctx, func(ctx context.Context) (interface{}, error) {
+			AddDependency(ctx, dep, nil)
+			if first {
+				first = false
+				firstRuns.Done()
+				return nil, nil
+			}
+			n := atomic.AddInt64(&running, 1)
+			if n > atomic.LoadInt64(&maxRunning) {
+				atomic.StoreInt64(&maxRunning, n)
+			}
+			<-block
+			atomic.AddInt64(&running, -1)
+			reruns.Done()
+			return nil, nil
+		}, 0, false))
+	}
+	defer func() {
+		for _, r := range rerunners {
+			r.Stop()
+		}
+	}()
+	firstRuns.Wait()
+
+	dep.Strobe()
+	deadline := time.Now().Add(5 * time.Second)
+	for ReadComputeWorkStats().QueueDepth == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected reruns to queue up")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(block)
+	reruns.Wait()
+
+	assert.True(t, atomic.LoadInt64(&maxRunning) <= workers)
+	assert.True(t, ReadComputeWorkStats().Running <= workers)
+}
+
+func TestSlowRerunsDoNotBlockGraphWork(t *testing.T) {
+	ctx := context.Background()
+
+	// Rerunners waiting for their rerun interval, invalidated by more
+	// strobes than there are workers.
+	var slow []*Resource
+	var slowRuns sync.WaitGroup
+	var rerunners []*Rerunner
+	for i := 0; i < DefaultGraphWorkers+8; i++ {
+		slow = append(slow, NewResource())
+		slowRuns.Add(1)
+		slow := slow[i]
+		first := true
+		rerunners = append(rerunners, NewRerunner(ctx, func(ctx context.Context) (interface{}, error) {
+			AddDependency(ctx, slow, nil)
+			if first {
+				first = false
+				slowRuns.Done()
+			}
+			return nil, nil
+		}, time.Minute, false))
+	}
+	defer func() {
+		for _, r := range rerunners {
+			r.Stop()
+		}
+	}()
+	slowRuns.Wait()
+
+	fast := NewResource()
+	runs := make(chan struct{}, 2)
+	r := NewRerunner(ctx, func(ctx context.Context) (interface{}, error) {
+		AddDependency(ctx, fast, nil)
+		runs <- struct{}{}
+		return nil, nil
+	}, 0, false)
+	defer r.Stop()
+	<-runs
+
+	for _, r := range slow {
+		r.Strobe()
+	}
+	fast.Strobe()
+	select {
+	case <-runs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("slow reruns blocked the invalidation of another rerunner")
+	}
+}
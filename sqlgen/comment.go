@@ -0,0 +1,25 @@
+package sqlgen
+
+import (
+	"context"
+
+	"github.com/denkhaus/thunder/internal/sqlcomment"
+)
+
+// RequestIDComment is the conventional query comment key of request IDs, see
+// WithQueryComment.
+const RequestIDComment = "request_id"
+
+// WithQueryComment tags the queries run with ctx with key and value, so that
+// slow queries in the MySQL slow log and process list can be attributed to
+// their origin. Tags are appended to queries as a comment in the sqlcommenter
+// format, for example
+//
+//	SELECT id, name FROM users WHERE id = ? /*graphql_operation='User',request_id='a1b2'*/
+//
+// A graphql.Executor created with graphql.WithQueryAttribution additionally
+// tags the queries of resolvers with the GraphQL operation name and field
+// path.
+func WithQueryComment(ctx context.Context, key, value string) context.Context {
+	return sqlcomment.With(ctx, key, value)
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 
 	"github.com/denkhaus/thunder/batch"
+	"github.com/denkhaus/thunder/internal/sqlcomment"
 )
 
 // DB uses a *sql.DB connection that is established by its owner. DB assumes the
@@ -20,6 +21,8 @@ type DB struct {
 	shardLimit Filter
 
 	dynamicLimit DynamicLimit
+
+	multiStatements bool
 }
 
 type DynamicLimitFilterCallback func(context.Context, string) Filter
@@ -76,13 +79,16 @@ func NewDB(conn *sql.DB, schema *Schema) *DB {
 			clause, args = selectQuery.ToSQL()
 
 			// Then, run the SQL query.
-			res, err := db.Conn.QueryContext(ctx, clause, args...)
-			if err != nil {
-				return nil, err
-			}
-			defer res.Close()
-			rows, err := db.Schema.ParseRows(selectQuery, res)
-			if err != nil {
+			var rows []interface{}
+			if err := db.retry(ctx, func() error {
+				res, err := db.Conn.QueryContext(ctx, sqlcomment.Append(ctx, clause), args...)
+				if err != nil {
+					return err
+				}
+				defer res.Close()
+				rows, err = db.Schema.ParseRows(selectQuery, res)
+				return err
+			}); err != nil {
 				return nil, err
 			}
 
@@ -241,6 +247,9 @@ func (db *DB) BaseQuery(ctx context.Context, query *BaseSelectQuery) ([]interfac
 	if err := db.checkFilterAgainstLimits(ctx, selectQuery, query.Filter, query.Table); err != nil {
 		return nil, err
 	}
+	if err := db.checkPool(ctx); err != nil {
+		return nil, err
+	}
 
 	if query.Options == nil && !db.HasTx(ctx) && batch.HasBatching(ctx) {
 		rows, err := db.batchFetch.Invoke(ctx, query)
@@ -252,19 +261,32 @@ func (db *DB) BaseQuery(ctx context.Context, query *BaseSelectQuery) ([]interfac
 
 	clause, args := selectQuery.ToSQL()
 
-	res, err := db.QueryExecer(ctx).QueryContext(ctx, clause, args...)
-	if err != nil {
-		return nil, err
-	}
-	defer res.Close()
-
-	return db.Schema.ParseRows(selectQuery, res)
+	var rows []interface{}
+	err = db.retry(ctx, func() error {
+		res, err := db.QueryExecer(ctx).QueryContext(ctx, sqlcomment.Append(ctx, clause), args...)
+		if err != nil {
+			return err
+		}
+		defer res.Close()
+		rows, err = db.Schema.ParseRows(selectQuery, res)
+		return err
+	})
+	return rows, err
 }
 
 func (db *DB) execWithTrace(ctx context.Context, query SQLQuery, operationName string) (sql.Result, error) {
+	if err := db.checkPool(ctx); err != nil {
+		return nil, err
+	}
 	clause, args := query.ToSQL()
 
-	return db.QueryExecer(ctx).ExecContext(ctx, clause, args...)
+	var result sql.Result
+	err := db.retry(ctx, func() error {
+		var err error
+		result, err = db.QueryExecer(ctx).ExecContext(ctx, sqlcomment.Append(ctx, clause), args...)
+		return err
+	})
+	return result, err
 }
 
 // Count counts the number of relevant rows in a database, matching options in filter
@@ -288,10 +310,15 @@ func (db *DB) Count(ctx context.Context, model interface{}, filter Filter) (int6
 	if err := db.checkFilterAgainstLimits(ctx, countQuery, filter, query.Table); err != nil {
 		return 0, err
 	}
+	if err := db.checkPool(ctx); err != nil {
+		return 0, err
+	}
 
 	clause, args := countQuery.ToSQL()
 	var count int64
-	err = db.QueryExecer(ctx).QueryRowContext(ctx, clause, args...).Scan(&count)
+	err = db.retry(ctx, func() error {
+		return db.QueryExecer(ctx).QueryRowContext(ctx, sqlcomment.Append(ctx, clause), args...).Scan(&count)
+	})
 	if err != nil {
 		return 0, err
 	}
@@ -349,6 +376,9 @@ func (db *DB) QueryRow(ctx context.Context, result interface{}, filter Filter, o
 //   if err := db.InsertRow(ctx, user); err != nil {
 //
 func (db *DB) InsertRow(ctx context.Context, row interface{}) (sql.Result, error) {
+	if err := db.Schema.runHooks(ctx, BeforeInsert, row); err != nil {
+		return nil, err
+	}
 	query, err := db.Schema.MakeInsertRow(row)
 	if err != nil {
 		return nil, err
@@ -358,7 +388,11 @@ func (db *DB) InsertRow(ctx context.Context, row interface{}) (sql.Result, error
 		return nil, err
 	}
 
-	return db.execWithTrace(ctx, query, "InsertRow")
+	result, err := db.execWithTrace(ctx, query, "InsertRow")
+	if err != nil {
+		return nil, err
+	}
+	return result, db.Schema.runHooks(ctx, AfterInsert, row)
 }
 
 // UpsertRow inserts a single row into the database
@@ -369,6 +403,9 @@ func (db *DB) InsertRow(ctx context.Context, row interface{}) (sql.Result, error
 //   if err := db.UpsertRow(ctx, user); err != nil {
 //
 func (db *DB) UpsertRow(ctx context.Context, row interface{}) (sql.Result, error) {
+	if err := db.Schema.runHooks(ctx, BeforeUpsert, row); err != nil {
+		return nil, err
+	}
 	query, err := db.Schema.MakeUpsertRow(row)
 	if err != nil {
 		return nil, err
@@ -378,7 +415,11 @@ func (db *DB) UpsertRow(ctx context.Context, row interface{}) (sql.Result, error
 		return nil, err
 	}
 
-	return db.execWithTrace(ctx, query, "UpsertRow")
+	result, err := db.execWithTrace(ctx, query, "UpsertRow")
+	if err != nil {
+		return nil, err
+	}
+	return result, db.Schema.runHooks(ctx, AfterUpsert, row)
 }
 
 // UpdateRow updates a single row in the database, identified by the row's primary key
@@ -389,6 +430,9 @@ func (db *DB) UpsertRow(ctx context.Context, row interface{}) (sql.Result, error
 //   if err := db.UpdateRow(ctx, user); err != nil {
 //
 func (db *DB) UpdateRow(ctx context.Context, row interface{}) error {
+	if err := db.Schema.runHooks(ctx, BeforeUpdate, row); err != nil {
+		return err
+	}
 	query, err := db.Schema.MakeUpdateRow(row)
 	if err != nil {
 		return err
@@ -402,8 +446,10 @@ func (db *DB) UpdateRow(ctx context.Context, row interface{}) error {
 		return err
 	}
 
-	_, err = db.execWithTrace(ctx, query, "UpsertRow")
-	return err
+	if _, err := db.execWithTrace(ctx, query, "UpsertRow"); err != nil {
+		return err
+	}
+	return db.Schema.runHooks(ctx, AfterUpdate, row)
 }
 
 // DeleteRow deletes a single row from the database, identified by the row's primary key
@@ -414,6 +460,9 @@ func (db *DB) UpdateRow(ctx context.Context, row interface{}) error {
 //   if err := db.DeleteRow(ctx, user); err != nil {
 //
 func (db *DB) DeleteRow(ctx context.Context, row interface{}) error {
+	if err := db.Schema.runHooks(ctx, BeforeDelete, row); err != nil {
+		return err
+	}
 	query, err := db.Schema.MakeDeleteRow(row)
 	if err != nil {
 		return err
@@ -423,8 +472,64 @@ func (db *DB) DeleteRow(ctx context.Context, row interface{}) error {
 		return err
 	}
 
-	_, err = db.execWithTrace(ctx, query, "DeleteRow")
-	return err
+	if _, err := db.execWithTrace(ctx, query, "DeleteRow"); err != nil {
+		return err
+	}
+	return db.Schema.runHooks(ctx, AfterDelete, row)
+}
+
+// UpdateWhere sets the columns in set on all rows matching filter with a
+// single UPDATE statement, and returns the number of changed rows. Unlike
+// UpdateRow, it runs no hooks, as the updated rows are never loaded.
+//
+// model should be a pointer to a struct, and filter must not be empty, for
+// example:
+//
+//   n, err := db.UpdateWhere(ctx, &User{}, map[string]interface{}{"active": false}, Filter{"org_id": 10})
+//   if err != nil { ... }
+//
+func (db *DB) UpdateWhere(ctx context.Context, model interface{}, set map[string]interface{}, filter Filter) (int64, error) {
+	query, err := db.Schema.MakeUpdateWhere(model, set, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := db.checkColumnValuesAgainstLimits(ctx, query, query.Where.Columns, query.Where.Values, query.Table); err != nil {
+		return 0, err
+	}
+
+	result, err := db.execWithTrace(ctx, query, "UpdateWhere")
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// DeleteWhere deletes all rows matching filter with a single DELETE statement,
+// and returns the number of deleted rows. Unlike DeleteRow, it runs no hooks,
+// as the deleted rows are never loaded.
+//
+// model should be a pointer to a struct, and filter must not be empty, for
+// example:
+//
+//   n, err := db.DeleteWhere(ctx, &User{}, Filter{"org_id": 10})
+//   if err != nil { ... }
+//
+func (db *DB) DeleteWhere(ctx context.Context, model interface{}, filter Filter) (int64, error) {
+	query, err := db.Schema.MakeDeleteWhere(model, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := db.checkColumnValuesAgainstLimits(ctx, query, query.Where.Columns, query.Where.Values, query.Table); err != nil {
+		return 0, err
+	}
+
+	result, err := db.execWithTrace(ctx, query, "DeleteWhere")
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
 // txKey is used as a key for a context.Context to hold a transaction.
@@ -0,0 +1,36 @@
+package sqlgen
+
+import "context"
+
+// A Dependency describes the rows of a table that a query reads.
+type Dependency struct {
+	Table  string
+	Filter Filter
+}
+
+type dependenciesKey struct{}
+
+// WithDependency declares that the queries run with ctx read the rows of
+// table matching filter. Queries built by the DB track the rows they read
+// themselves, but hand-written SQL run through QueryExecer cannot be
+// analyzed, so a livesql.LiveDB only invalidates the results of raw queries
+// when the rows they declare change:
+//
+//	ctx = sqlgen.WithDependency(ctx, "users", sqlgen.Filter{"team_id": teamID})
+//	rows, err := ldb.QueryExecer(ctx).QueryContext(ctx, "SELECT COUNT(*) FROM users WHERE team_id = ? AND ...", teamID)
+//
+// A nil filter depends on every row of table. Dependencies accumulate, so
+// a query joining several tables declares a dependency on each of them.
+func WithDependency(ctx context.Context, table string, filter Filter) context.Context {
+	existing := Dependencies(ctx)
+	dependencies := make([]Dependency, len(existing), len(existing)+1)
+	copy(dependencies, existing)
+	dependencies = append(dependencies, Dependency{Table: table, Filter: filter})
+	return context.WithValue(ctx, dependenciesKey{}, dependencies)
+}
+
+// Dependencies returns the dependencies declared on ctx with WithDependency.
+func Dependencies(ctx context.Context) []Dependency {
+	dependencies, _ := ctx.Value(dependenciesKey{}).([]Dependency)
+	return dependencies
+}
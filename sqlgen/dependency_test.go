@@ -0,0 +1,24 @@
+package sqlgen
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithDependency(t *testing.T) {
+	ctx := context.Background()
+	assert.Nil(t, Dependencies(ctx))
+
+	users := WithDependency(ctx, "users", Filter{"team_id": int64(1)})
+	teams := WithDependency(users, "teams", nil)
+	assert.Equal(t, []Dependency{
+		{Table: "users", Filter: Filter{"team_id": int64(1)}},
+		{Table: "teams"},
+	}, Dependencies(teams))
+
+	// Declaring a dependency does not affect the parent context.
+	WithDependency(users, "groups", nil)
+	assert.Equal(t, []Dependency{{Table: "users", Filter: Filter{"team_id": int64(1)}}}, Dependencies(users))
+}
@@ -0,0 +1,222 @@
+package sqlgen
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/denkhaus/thunder/internal/fields"
+)
+
+// Columns tagged `sql:",encrypted"` are encrypted at rest, eg. for personal
+// data:
+//
+//	type User struct {
+//		Id    int64 `sql:",primary"`
+//		Email string `sql:",encrypted"`
+//	}
+//
+// Their values are encoded with the ColumnCodec of the schema, see
+// Schema.SetColumnCodec, before they are inserted or updated, and decoded
+// when rows are read from queries or the binlog. Encrypted columns must hold
+// strings or bytes, and should be stored in a binary column type such as
+// VARBINARY or BLOB. NULL values are not encrypted.
+//
+// Encrypted values cannot be compared by the database, so encrypted columns
+// cannot be filtered on, and live queries never match on them.
+
+// ColumnCodec encodes the values of encrypted columns before they are
+// written, and decodes them after they are read.
+type ColumnCodec interface {
+	Encode(table, column string, plaintext []byte) ([]byte, error)
+	Decode(table, column string, ciphertext []byte) ([]byte, error)
+}
+
+// SetColumnCodec sets the codec of the encrypted columns of s. It must be
+// set before registering types with encrypted columns.
+func (s *Schema) SetColumnCodec(codec ColumnCodec) {
+	s.codec = codec
+}
+
+// KeyProvider provides the keys of an AES-GCM codec, see NewAESGCMCodec.
+type KeyProvider interface {
+	// CurrentKey returns the key new values are encrypted with, and its ID.
+	CurrentKey() (id string, key []byte, err error)
+	// Key returns the key with id, to decrypt values encrypted with it,
+	// including keys that have since been rotated out.
+	Key(id string) ([]byte, error)
+}
+
+// StaticKeys is a KeyProvider with a fixed set of keys.
+type StaticKeys struct {
+	// CurrentID is the ID of the key new values are encrypted with.
+	CurrentID string
+	// Keys holds the keys by ID.
+	Keys map[string][]byte
+}
+
+func (k StaticKeys) CurrentKey() (string, []byte, error) {
+	key, err := k.Key(k.CurrentID)
+	if err != nil {
+		return "", nil, err
+	}
+	return k.CurrentID, key, nil
+}
+
+func (k StaticKeys) Key(id string) ([]byte, error) {
+	key, ok := k.Keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", id)
+	}
+	return key, nil
+}
+
+// aesGCMCodec encrypts values with AES-GCM. Encoded values hold the length
+// of the key ID, the key ID, the nonce, and the sealed value, which is
+// authenticated with the table and column name so that it cannot be moved to
+// another column.
+type aesGCMCodec struct {
+	keys KeyProvider
+}
+
+// NewAESGCMCodec returns a codec encrypting values with AES-GCM, using the
+// AES-128, AES-192 or AES-256 keys of keys. Values remember the ID of the key
+// they were encrypted with, so keys can be rotated by changing the current key
+// while keeping the old ones available.
+func NewAESGCMCodec(keys KeyProvider) ColumnCodec {
+	return &aesGCMCodec{keys: keys}
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (c *aesGCMCodec) Encode(table, column string, plaintext []byte) ([]byte, error) {
+	id, key, err := c.keys.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+	if len(id) > 255 {
+		return nil, fmt.Errorf("key ID %q is too long", id)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, 1+len(id)+gcm.NonceSize()+len(plaintext)+gcm.Overhead())
+	out = append(out, byte(len(id)))
+	out = append(out, id...)
+	nonce := out[len(out) : len(out)+gcm.NonceSize()]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	out = out[:len(out)+len(nonce)]
+	return gcm.Seal(out, nonce, plaintext, []byte(table+"."+column)), nil
+}
+
+var errMalformedCiphertext = errors.New("malformed encrypted value")
+
+func (c *aesGCMCodec) Decode(table, column string, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) == 0 {
+		return nil, errMalformedCiphertext
+	}
+	// Compute the length as an int, as 1+n overflows a byte for 255 byte ids.
+	n := int(ciphertext[0])
+	if len(ciphertext) < 1+n {
+		return nil, errMalformedCiphertext
+	}
+	id, ciphertext := string(ciphertext[1:1+n]), ciphertext[1+n:]
+	key, err := c.keys.Key(id)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errMalformedCiphertext
+	}
+	return gcm.Open(nil, ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():], []byte(table+"."+column))
+}
+
+// checkEncryptable checks that the SQL values of d can be encrypted.
+func checkEncryptable(d *fields.Descriptor) error {
+	var val reflect.Value
+	if d.Ptr {
+		val = reflect.New(d.Type)
+	} else {
+		val = reflect.Zero(d.Type)
+	}
+	sqlVal, err := d.Valuer(val).Value()
+	if err != nil {
+		return err
+	}
+	switch sqlVal.(type) {
+	case nil, string, []byte:
+		return nil
+	default:
+		return fmt.Errorf("encrypted column must hold strings or bytes, not %T", sqlVal)
+	}
+}
+
+// encrypt encodes value, a SQL value of column, if column is encrypted.
+func (c *Column) encrypt(value driver.Value) (driver.Value, error) {
+	if !c.Encrypted || value == nil {
+		return value, nil
+	}
+	var plaintext []byte
+	switch value := value.(type) {
+	case string:
+		plaintext = []byte(value)
+	case []byte:
+		plaintext = value
+	default:
+		return nil, fmt.Errorf("cannot encrypt %T", value)
+	}
+	return c.codec.Encode(c.table, c.Name, plaintext)
+}
+
+// Decrypt decodes value, a SQL value of c read from the database, if c is
+// encrypted.
+func (c *Column) Decrypt(value interface{}) (interface{}, error) {
+	if !c.Encrypted || value == nil {
+		return value, nil
+	}
+	var ciphertext []byte
+	switch value := value.(type) {
+	case string:
+		ciphertext = []byte(value)
+	case []byte:
+		ciphertext = value
+	default:
+		return nil, fmt.Errorf("cannot decrypt %T", value)
+	}
+	return c.codec.Decode(c.table, c.Name, ciphertext)
+}
+
+// decryptingScanner decrypts the values of an encrypted column before
+// scanning them.
+type decryptingScanner struct {
+	column  *Column
+	scanner *fields.Scanner
+}
+
+func (s *decryptingScanner) Scan(src interface{}) error {
+	value, err := s.column.Decrypt(src)
+	if err != nil {
+		s.scanner.Target(reflect.Value{})
+		return err
+	}
+	return s.scanner.Scan(value)
+}
@@ -0,0 +1,117 @@
+package sqlgen
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type encryptedUser struct {
+	Id    int64 `sql:",primary"`
+	Name  string
+	Email string  `sql:",encrypted"`
+	Phone *string `sql:",encrypted"`
+}
+
+func TestEncryptedColumns(t *testing.T) {
+	keys := StaticKeys{
+		CurrentID: "k1",
+		Keys:      map[string][]byte{"k1": []byte("0123456789abcdef")},
+	}
+	s := NewSchema()
+	s.SetColumnCodec(NewAESGCMCodec(&keys))
+	s.MustRegisterType("users", AutoIncrement, encryptedUser{})
+
+	// Encrypted values are written encoded, and NULLs are left as is.
+	insert, err := s.MakeInsertRow(&encryptedUser{Name: "bob", Email: "bob@example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name", "email", "phone"}, insert.Columns)
+	assert.Equal(t, "bob", insert.Values[0])
+	require.IsType(t, []byte{}, insert.Values[1])
+	assert.NotContains(t, string(insert.Values[1].([]byte)), "bob@example.com")
+	assert.Nil(t, insert.Values[2])
+
+	// They are decoded when read, even after the current key is rotated.
+	keys.Keys["k2"] = []byte("fedcba9876543210")
+	keys.CurrentID = "k2"
+	phone := "555-1234"
+	update, err := s.MakeUpdateRow(&encryptedUser{Id: 1, Name: "bob", Phone: &phone})
+	require.NoError(t, err)
+
+	row, err := s.BuildStruct("users", []driver.Value{int64(1), "bob", insert.Values[1], update.Values[2]})
+	require.NoError(t, err)
+	assert.Equal(t, &encryptedUser{Id: 1, Name: "bob", Email: "bob@example.com", Phone: &phone}, row)
+
+	// Values cannot be moved between columns.
+	_, err = s.BuildStruct("users", []driver.Value{int64(1), "bob", update.Values[2], nil})
+	assert.Error(t, err)
+
+	// Encrypted columns are set by bulk updates, but never filtered on.
+	updateWhere, err := s.MakeUpdateWhere(&encryptedUser{}, map[string]interface{}{"email": "alice@example.com"}, Filter{"name": "bob"})
+	require.NoError(t, err)
+	assert.IsType(t, []byte{}, updateWhere.Values[0])
+
+	sel, err := s.MakeSelect(new([]*encryptedUser), Filter{"email": "bob@example.com"}, nil)
+	require.NoError(t, err)
+	_, err = sel.MakeSelectQuery()
+	assert.Error(t, err)
+	_, err = s.MakeTester("users", Filter{"email": "bob@example.com"})
+	assert.Error(t, err)
+	_, err = NewFilterMapper(s.ByName["users"], struct{ Email *StringFilter }{}, "email")
+	assert.Error(t, err)
+}
+
+func TestEncryptedColumnsRegistration(t *testing.T) {
+	// Encrypted columns need a codec.
+	assert.Error(t, NewSchema().RegisterType("users", AutoIncrement, encryptedUser{}))
+
+	s := NewSchema()
+	s.SetColumnCodec(NewAESGCMCodec(StaticKeys{}))
+
+	type encryptedPrimary struct {
+		Id string `sql:",primary,encrypted"`
+	}
+	assert.Error(t, s.RegisterType("primary", UniqueId, encryptedPrimary{}))
+
+	type encryptedInt struct {
+		Id  int64 `sql:",primary"`
+		Age int64 `sql:",encrypted"`
+	}
+	assert.Error(t, s.RegisterType("ints", AutoIncrement, encryptedInt{}))
+
+	type encryptedJSON struct {
+		Id   int64             `sql:",primary"`
+		Tags map[string]string `sql:",json,encrypted"`
+	}
+	assert.NoError(t, s.RegisterType("json", AutoIncrement, encryptedJSON{}))
+}
+
+func TestAESGCMCodecKeyIDs(t *testing.T) {
+	longID := strings.Repeat("k", 255)
+	codec := NewAESGCMCodec(StaticKeys{
+		CurrentID: longID,
+		Keys:      map[string][]byte{longID: []byte("0123456789abcdef")},
+	})
+
+	// Key ids of up to 255 bytes round trip.
+	encoded, err := codec.Encode("users", "email", []byte("bob@example.com"))
+	require.NoError(t, err)
+	decoded, err := codec.Decode("users", "email", encoded)
+	require.NoError(t, err)
+	assert.Equal(t, "bob@example.com", string(decoded))
+
+	// Malformed values fail without panicking.
+	for _, value := range [][]byte{
+		nil,
+		{0xff},
+		append([]byte{0xff}, "plaintext"...),
+		encoded[:len(encoded)-1],
+		encoded[:200],
+	} {
+		_, err := codec.Decode("users", "email", value)
+		assert.Error(t, err)
+	}
+}
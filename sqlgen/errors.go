@@ -1,9 +1,87 @@
 package sqlgen
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Persistence errors returned by ClassifyError, so that callers can handle
+// failed queries, eg. translate them to client errors, without matching
+// driver-specific error messages.
+var (
+	// ErrNotFound is the class of queries for a single row that found none.
+	ErrNotFound = errors.New("sqlgen: not found")
+	// ErrDuplicateKey is the class of writes that violate a primary key or
+	// unique index.
+	ErrDuplicateKey = errors.New("sqlgen: duplicate key")
+	// ErrForeignKey is the class of writes that violate a foreign key
+	// constraint, either by referencing a missing row or by deleting or
+	// updating a referenced row.
+	ErrForeignKey = errors.New("sqlgen: foreign key constraint violated")
 )
 
+// MySQL error numbers of persistence errors, see ClassifyError.
+const (
+	mysqlErrDupKey              = 1022
+	mysqlErrDupEntry            = 1062
+	mysqlErrDupEntryWithKeyName = 1586
+	mysqlErrNoReferencedRow     = 1216
+	mysqlErrRowIsReferenced     = 1217
+	mysqlErrRowIsReferenced2    = 1451
+	mysqlErrNoReferencedRow2    = 1452
+)
+
+// ClassifyError returns the class of err, returned by a query, as one of
+// ErrNotFound, ErrDuplicateKey, and ErrForeignKey. It returns nil for errors
+// of any other class. For example, a resolver can report conflicts as
+//
+//	if sqlgen.ClassifyError(err) == sqlgen.ErrDuplicateKey {
+//		return nil, graphql.NewClientError("user %s already exists", name)
+//	}
+func ClassifyError(err error) error {
+	for err != nil {
+		switch e := err.(type) {
+		case *mysql.MySQLError:
+			switch e.Number {
+			case mysqlErrDupKey, mysqlErrDupEntry, mysqlErrDupEntryWithKeyName:
+				return ErrDuplicateKey
+			case mysqlErrNoReferencedRow, mysqlErrRowIsReferenced, mysqlErrRowIsReferenced2, mysqlErrNoReferencedRow2:
+				return ErrForeignKey
+			}
+			return nil
+		case *ErrorWithQuery:
+			err = e.Unwrap()
+			continue
+		}
+		switch err {
+		case sql.ErrNoRows:
+			return ErrNotFound
+		case ErrNotFound, ErrDuplicateKey, ErrForeignKey:
+			return err
+		}
+		return nil
+	}
+	return nil
+}
+
+// IsNotFound reports whether the class of err is ErrNotFound.
+func IsNotFound(err error) bool {
+	return ClassifyError(err) == ErrNotFound
+}
+
+// IsDuplicateKey reports whether the class of err is ErrDuplicateKey.
+func IsDuplicateKey(err error) bool {
+	return ClassifyError(err) == ErrDuplicateKey
+}
+
+// IsForeignKey reports whether the class of err is ErrForeignKey.
+func IsForeignKey(err error) bool {
+	return ClassifyError(err) == ErrForeignKey
+}
+
 // ErrorWithQuery is an error wrapper that includes
 // the clause and arguments of a sqlgen query.
 type ErrorWithQuery struct {
@@ -1,9 +1,11 @@
 package sqlgen
 
 import (
+	"database/sql"
 	"fmt"
 	"testing"
 
+	"github.com/go-sql-driver/mysql"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -141,3 +143,30 @@ func TestErrorWithQuery_Reason(t *testing.T) {
 		})
 	}
 }
+
+func TestClassifyError(t *testing.T) {
+	testcases := []struct {
+		err   error
+		class error
+	}{
+		{err: sql.ErrNoRows, class: ErrNotFound},
+		{err: &mysql.MySQLError{Number: 1062, Message: "Duplicate entry '1' for key 'PRIMARY'"}, class: ErrDuplicateKey},
+		{err: &mysql.MySQLError{Number: 1586, Message: "Duplicate entry '1' for key 'name'"}, class: ErrDuplicateKey},
+		{err: &mysql.MySQLError{Number: 1451, Message: "Cannot delete or update a parent row"}, class: ErrForeignKey},
+		{err: &mysql.MySQLError{Number: 1452, Message: "Cannot add or update a child row"}, class: ErrForeignKey},
+		{err: &ErrorWithQuery{err: &mysql.MySQLError{Number: 1062}, clause: "INSERT INTO users"}, class: ErrDuplicateKey},
+		{err: &ErrorWithQuery{err: sql.ErrNoRows}, class: ErrNotFound},
+		{err: ErrForeignKey, class: ErrForeignKey},
+		{err: &mysql.MySQLError{Number: 1213, Message: "Deadlock found"}, class: nil},
+		{err: fmt.Errorf("Duplicate entry"), class: nil},
+		{err: nil, class: nil},
+	}
+	for _, testcase := range testcases {
+		assert.Equal(t, testcase.class, ClassifyError(testcase.err), "%v", testcase.err)
+	}
+
+	assert.True(t, IsNotFound(sql.ErrNoRows))
+	assert.True(t, IsDuplicateKey(&mysql.MySQLError{Number: 1062}))
+	assert.True(t, IsForeignKey(&mysql.MySQLError{Number: 1452}))
+	assert.False(t, IsNotFound(&mysql.MySQLError{Number: 1062}))
+}
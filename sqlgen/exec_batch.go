@@ -0,0 +1,256 @@
+package sqlgen
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/denkhaus/thunder/internal/sqlcomment"
+)
+
+// batchOp is a single operation in an ExecBatch.
+type batchOp struct {
+	query SQLQuery
+	// check verifies the operation against the DB's limits.
+	check func(ctx context.Context) error
+	// row is the written row, and before and after are the events of its
+	// hooks, see RegisterHook.
+	row           interface{}
+	before, after HookEvent
+}
+
+// ExecBatch groups insert, upsert, update and delete operations so that they
+// execute in a single transaction. Create one with DB.NewExecBatch.
+//
+// livesql invalidates live queries from the binlog, which only holds
+// committed transactions, so live queries observe all operations of a batch
+// at once after it commits. Use AfterCommit to invalidate other caches only
+// once the batch has committed.
+type ExecBatch struct {
+	db          *DB
+	ops         []batchOp
+	afterCommit []func()
+}
+
+// NewExecBatch creates an empty ExecBatch for db.
+func (db *DB) NewExecBatch() *ExecBatch {
+	return &ExecBatch{db: db}
+}
+
+// WithMultiStatements makes ExecBatch send all statements of a batch to the
+// database in a single round trip. The connection must accept multiple
+// statements in one query; for MySQL, set the multiStatements=true and
+// interpolateParams=true DSN parameters.
+func (db *DB) WithMultiStatements() *DB {
+	dbCopy := *db
+	dbCopy.multiStatements = true
+	return &dbCopy
+}
+
+// InsertRow adds an insert of row to the batch. See DB.InsertRow.
+func (b *ExecBatch) InsertRow(row interface{}) error {
+	return b.add(BeforeInsert, row)
+}
+
+// UpsertRow adds an upsert of row to the batch. See DB.UpsertRow.
+func (b *ExecBatch) UpsertRow(row interface{}) error {
+	return b.add(BeforeUpsert, row)
+}
+
+// UpdateRow adds an update of row to the batch. See DB.UpdateRow.
+func (b *ExecBatch) UpdateRow(row interface{}) error {
+	return b.add(BeforeUpdate, row)
+}
+
+// DeleteRow adds a delete of row to the batch. See DB.DeleteRow.
+func (b *ExecBatch) DeleteRow(row interface{}) error {
+	return b.add(BeforeDelete, row)
+}
+
+// add adds the write of row with the before hook event to the batch.
+func (b *ExecBatch) add(before HookEvent, row interface{}) error {
+	op, err := b.makeOp(before, row)
+	if err != nil {
+		return err
+	}
+	b.ops = append(b.ops, op)
+	return nil
+}
+
+// makeOp builds the operation writing row with the before hook event.
+func (b *ExecBatch) makeOp(before HookEvent, row interface{}) (batchOp, error) {
+	op := batchOp{row: row, before: before}
+	switch before {
+	case BeforeInsert:
+		query, err := b.db.Schema.MakeInsertRow(row)
+		if err != nil {
+			return op, err
+		}
+		op.query, op.after = query, AfterInsert
+		op.check = func(ctx context.Context) error {
+			return b.db.checkColumnValuesAgainstLimits(ctx, query, query.Columns, query.Values, query.Table)
+		}
+	case BeforeUpsert:
+		query, err := b.db.Schema.MakeUpsertRow(row)
+		if err != nil {
+			return op, err
+		}
+		op.query, op.after = query, AfterUpsert
+		op.check = func(ctx context.Context) error {
+			return b.db.checkColumnValuesAgainstLimits(ctx, query, query.Columns, query.Values, query.Table)
+		}
+	case BeforeUpdate:
+		query, err := b.db.Schema.MakeUpdateRow(row)
+		if err != nil {
+			return op, err
+		}
+		op.query, op.after = query, AfterUpdate
+		op.check = func(ctx context.Context) error {
+			return b.db.checkColumnValuesAgainstLimits(
+				ctx,
+				query,
+				append(query.Where.Columns, query.Columns...),
+				append(query.Where.Values, query.Values...), query.Table)
+		}
+	case BeforeDelete:
+		query, err := b.db.Schema.MakeDeleteRow(row)
+		if err != nil {
+			return op, err
+		}
+		op.query, op.after = query, AfterDelete
+		op.check = func(ctx context.Context) error {
+			return b.db.checkColumnValuesAgainstLimits(ctx, query, query.Where.Columns, query.Where.Values, query.Table)
+		}
+	}
+	return op, nil
+}
+
+// runBeforeHooks runs the before hooks of all operations, and rebuilds the
+// operations of rows with hooks, which may have changed them.
+func (b *ExecBatch) runBeforeHooks(ctx context.Context) error {
+	for i, op := range b.ops {
+		if !b.db.Schema.hasHooks(op.before, op.row) {
+			continue
+		}
+		if err := b.db.Schema.runHooks(ctx, op.before, op.row); err != nil {
+			return err
+		}
+		rebuilt, err := b.makeOp(op.before, op.row)
+		if err != nil {
+			return err
+		}
+		b.ops[i] = rebuilt
+	}
+	return nil
+}
+
+// runAfterHooks runs the after hooks of all operations.
+func (b *ExecBatch) runAfterHooks(ctx context.Context) error {
+	for _, op := range b.ops {
+		if err := b.db.Schema.runHooks(ctx, op.after, op.row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AfterCommit registers f to be called after the batch has been committed by
+// Exec.
+func (b *ExecBatch) AfterCommit(f func()) {
+	b.afterCommit = append(b.afterCommit, f)
+}
+
+// Len returns the number of operations in the batch.
+func (b *ExecBatch) Len() int {
+	return len(b.ops)
+}
+
+// Exec executes all operations of the batch in a transaction, and returns the
+// result of every operation. If the DB uses multi statements, the operations
+// are sent in a single round trip and no results are returned.
+//
+// Hooks registered with RegisterHook run for every operation: before hooks
+// before the transaction starts, and after hooks once it committed.
+//
+// If ctx already holds a transaction, the operations run in it and committing
+// is left to the caller. AfterCommit functions are then not called, and after
+// hooks run once all operations succeeded.
+// Otherwise, the transaction is retried on transient errors if ctx has a
+// retry policy, see WithRetries.
+func (b *ExecBatch) Exec(ctx context.Context) ([]sql.Result, error) {
+	if len(b.ops) == 0 {
+		return nil, nil
+	}
+
+	if err := b.runBeforeHooks(ctx); err != nil {
+		return nil, err
+	}
+
+	// Check all limits before starting the transaction.
+	for _, op := range b.ops {
+		if err := op.check(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.db.HasTx(ctx) {
+		results, err := b.exec(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return results, b.runAfterHooks(ctx)
+	}
+	if err := b.db.checkPool(ctx); err != nil {
+		return nil, err
+	}
+
+	var results []sql.Result
+	if err := b.db.RunInTx(ctx, func(txCtx context.Context) error {
+		var err error
+		results, err = b.exec(txCtx)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	for _, f := range b.afterCommit {
+		f()
+	}
+	return results, b.runAfterHooks(ctx)
+}
+
+// exec executes the operations using the transaction in ctx.
+func (b *ExecBatch) exec(ctx context.Context) ([]sql.Result, error) {
+	execer := b.db.QueryExecer(ctx)
+
+	if b.db.multiStatements {
+		clause, args := b.multiStatement()
+		if _, err := execer.ExecContext(ctx, sqlcomment.Append(ctx, clause), args...); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	results := make([]sql.Result, 0, len(b.ops))
+	for _, op := range b.ops {
+		clause, args := op.query.ToSQL()
+		result, err := execer.ExecContext(ctx, sqlcomment.Append(ctx, clause), args...)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// multiStatement joins all operations into a single query.
+func (b *ExecBatch) multiStatement() (string, []interface{}) {
+	clauses := make([]string, 0, len(b.ops))
+	var args []interface{}
+	for _, op := range b.ops {
+		clause, opArgs := op.query.ToSQL()
+		clauses = append(clauses, clause)
+		args = append(args, opArgs...)
+	}
+	return strings.Join(clauses, "; "), args
+}
@@ -0,0 +1,87 @@
+package sqlgen
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecBatch(t *testing.T) {
+	tdb, db, err := setup()
+	require.NoError(t, err)
+	defer tdb.Close()
+	ctx := context.Background()
+
+	committed := false
+	batch := db.NewExecBatch()
+	require.NoError(t, batch.InsertRow(&User{Name: "Alice"}))
+	require.NoError(t, batch.InsertRow(&User{Name: "Bob"}))
+	batch.AfterCommit(func() { committed = true })
+
+	results, err := batch.Exec(ctx)
+	require.NoError(t, err)
+	assert.Len(t, results, 2)
+	assert.True(t, committed)
+
+	aliceID, err := results[0].LastInsertId()
+	require.NoError(t, err)
+
+	count, err := db.Count(ctx, &User{}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	// A failing batch rolls back all its operations.
+	committed = false
+	batch = db.NewExecBatch()
+	require.NoError(t, batch.DeleteRow(&User{Id: aliceID}))
+	require.NoError(t, batch.InsertRow(&JustId{Id: 1}))
+	require.NoError(t, batch.InsertRow(&JustId{Id: 1}))
+	batch.AfterCommit(func() { committed = true })
+
+	_, err = batch.Exec(ctx)
+	assert.Error(t, err)
+	assert.False(t, committed)
+
+	count, err = db.Count(ctx, &User{}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestExecBatchLimit(t *testing.T) {
+	tdb, db, err := setup()
+	require.NoError(t, err)
+	defer tdb.Close()
+	ctx := context.Background()
+
+	aliceDb, err := db.WithShardLimit(Filter{"name": "Alice"})
+	require.NoError(t, err)
+
+	batch := aliceDb.NewExecBatch()
+	require.NoError(t, batch.InsertRow(&User{Name: "Alice"}))
+	require.NoError(t, batch.InsertRow(&User{Name: "Bob"}))
+	_, err = batch.Exec(ctx)
+	assert.Contains(t, err.Error(), "db requies name = Alice, but query has name = Bob")
+
+	// No operation ran, as the limits are checked up front.
+	count, err := db.Count(ctx, &User{}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestExecBatchMultiStatement(t *testing.T) {
+	s := NewSchema()
+	s.MustRegisterType("users", AutoIncrement, user{})
+	db := NewDB(nil, s).WithMultiStatements()
+
+	batch := db.NewExecBatch()
+	require.NoError(t, batch.InsertRow(&user{Name: "Alice", Age: 10}))
+	require.NoError(t, batch.DeleteRow(&user{Id: 3}))
+	assert.Equal(t, 2, batch.Len())
+
+	clause, args := batch.multiStatement()
+	assert.Equal(t, "INSERT INTO users (name, age, optional, uuid) VALUES (?, ?, ?, ?); DELETE FROM users WHERE id = ?", clause)
+	assert.Len(t, args, 5)
+	assert.Equal(t, int64(3), args[4])
+}
@@ -0,0 +1,59 @@
+package sqlgen
+
+import (
+	"fmt"
+)
+
+// Expression is a read-only SQL expression over a table's columns that can be
+// used as a key in filters, such as "LOWER(email)". Filtering on expressions
+// lets queries use expression indexes while the struct still maps to the
+// table's plain columns.
+type Expression struct {
+	// Name is the filter key of the expression.
+	Name string
+	// SQL is the expression compared against filter values. It is inserted
+	// into queries as is, so it must not contain user input.
+	SQL string
+	// Eval computes the expression for a row, a pointer to the table's struct.
+	// It is required to match rows in memory, such as in livesql.
+	Eval func(row interface{}) interface{}
+}
+
+// RegisterExpression registers a filter expression on a registered table.
+//
+// For example, after registering
+//
+//	schema.RegisterExpression("users", &sqlgen.Expression{
+//		Name: "email_lower",
+//		SQL:  "LOWER(email)",
+//		Eval: func(row interface{}) interface{} {
+//			return strings.ToLower(row.(*User).Email)
+//		},
+//	})
+//
+// the filter sqlgen.Filter{"email_lower": "alice@example.com"} selects rows
+// WHERE LOWER(email) = ?.
+func (s *Schema) RegisterExpression(table string, expression *Expression) error {
+	t, ok := s.ByName[table]
+	if !ok {
+		return fmt.Errorf("unknown table %s", table)
+	}
+	if expression.Name == "" || expression.SQL == "" {
+		return fmt.Errorf("expression on table %s needs a name and SQL", table)
+	}
+	if _, ok := t.ColumnsByName[expression.Name]; ok {
+		return fmt.Errorf("expression %s on table %s shadows a column", expression.Name, table)
+	}
+	if _, ok := t.Expressions[expression.Name]; ok {
+		return fmt.Errorf("expression %s on table %s registered twice", expression.Name, table)
+	}
+	t.Expressions[expression.Name] = expression
+	return nil
+}
+
+// MustRegisterExpression calls RegisterExpression and panics on error.
+func (s *Schema) MustRegisterExpression(table string, expression *Expression) {
+	if err := s.RegisterExpression(table, expression); err != nil {
+		panic(err)
+	}
+}
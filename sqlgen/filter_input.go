@@ -0,0 +1,342 @@
+package sqlgen
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// Filter inputs are GraphQL input objects that filter rows column by column,
+// such as
+//
+//	type UserFilter struct {
+//		Name *sqlgen.StringFilter
+//		Age  *sqlgen.IntFilter
+//	}
+//
+// Every field holds the operators applied to a column, named after the field
+// like the columns of a table struct, or by a `sql:"column"` tag. Operators
+// are pointers or slices, and are ignored if nil. A FilterMapper checks a
+// filter input type against a table once, and then converts filter inputs into
+// WHERE clauses.
+
+// StringFilter holds the operators of a string column. Contains matches
+// values containing the string.
+type StringFilter struct {
+	Eq       *string
+	In       []string
+	Gt       *string
+	Lt       *string
+	Contains *string
+}
+
+// IntFilter holds the operators of an integer column.
+type IntFilter struct {
+	Eq *int64
+	In []int64
+	Gt *int64
+	Lt *int64
+}
+
+// FloatFilter holds the operators of a floating point column.
+type FloatFilter struct {
+	Eq *float64
+	In []float64
+	Gt *float64
+	Lt *float64
+}
+
+// BoolFilter holds the operators of a boolean column.
+type BoolFilter struct {
+	Eq *bool
+}
+
+// filterOperators maps the supported operator fields to their SQL operator.
+var filterOperators = map[string]string{
+	"Eq":       "=",
+	"In":       "IN",
+	"Gt":       ">",
+	"Lt":       "<",
+	"Contains": "LIKE",
+}
+
+// FilterMapper converts filter inputs into WHERE clauses on a table.
+type FilterMapper struct {
+	table     *Table
+	inputType reflect.Type
+	fields    []*filterField
+}
+
+// filterField is a field of a filter input, filtering column.
+type filterField struct {
+	index     []int
+	column    *Column
+	operators []filterOperator
+}
+
+type filterOperator struct {
+	index int
+	sql   string
+}
+
+// NewFilterMapper checks the filter input type of input, a struct or pointer
+// to a struct, against table. Its fields may only filter the columns in
+// allowed, and their operators must hold values that can be converted to the
+// column's type.
+func NewFilterMapper(table *Table, input interface{}, allowed ...string) (*FilterMapper, error) {
+	inputType := reflect.TypeOf(input)
+	if inputType != nil && inputType.Kind() == reflect.Ptr {
+		inputType = inputType.Elem()
+	}
+	if inputType == nil || inputType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("bad filter input type %v: not a struct", inputType)
+	}
+
+	allowedColumns := make(map[string]bool, len(allowed))
+	for _, name := range allowed {
+		column, ok := table.ColumnsByName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown column %s", name)
+		}
+		if column.Encrypted {
+			return nil, fmt.Errorf("cannot filter on encrypted column %s", name)
+		}
+		allowedColumns[name] = true
+	}
+
+	m := &FilterMapper{table: table, inputType: inputType}
+	for i := 0; i < inputType.NumField(); i++ {
+		field := inputType.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("sql"), ",")[0]
+		if name == "" {
+			name = makeSnake(field.Name)
+		}
+		if name == "-" {
+			continue
+		}
+		if !allowedColumns[name] {
+			return nil, fmt.Errorf("bad filter input type %s: column %s is not filterable", inputType, name)
+		}
+
+		operators, err := makeFilterOperators(field.Type, table.ColumnsByName[name])
+		if err != nil {
+			return nil, fmt.Errorf("bad filter input type %s: field %s: %v", inputType, field.Name, err)
+		}
+		m.fields = append(m.fields, &filterField{
+			index:     field.Index,
+			column:    table.ColumnsByName[name],
+			operators: operators,
+		})
+	}
+	return m, nil
+}
+
+// makeFilterOperators checks the operators in typ against column.
+func makeFilterOperators(typ reflect.Type, column *Column) ([]filterOperator, error) {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%s is not a struct of operators", typ)
+	}
+
+	var operators []filterOperator
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		sql, ok := filterOperators[field.Name]
+		if !ok {
+			return nil, fmt.Errorf("unknown operator %s", field.Name)
+		}
+
+		kind := reflect.Ptr
+		if sql == "IN" {
+			kind = reflect.Slice
+		}
+		if field.Type.Kind() != kind {
+			return nil, fmt.Errorf("operator %s should be a %s", field.Name, kind)
+		}
+		valueType := field.Type.Elem()
+
+		if sql == "LIKE" {
+			if valueType.Kind() != reflect.String || column.Descriptor.Kind != reflect.String {
+				return nil, fmt.Errorf("operator %s needs a string column and value", field.Name)
+			}
+		} else if !canCoerceFilterValue(valueType, column.Descriptor.Type) {
+			return nil, fmt.Errorf("operator %s of type %s cannot filter column %s of type %s", field.Name, valueType, column.Name, column.Descriptor.Type)
+		}
+		operators = append(operators, filterOperator{index: i, sql: sql})
+	}
+	return operators, nil
+}
+
+// canCoerceFilterValue returns true if values of type from can be converted
+// to column type to, without converting between numbers and strings. Values
+// that do not fit the column's type are rejected by convertFilterValue.
+func canCoerceFilterValue(from, to reflect.Type) bool {
+	if from == to {
+		return true
+	}
+	if !from.ConvertibleTo(to) {
+		return false
+	}
+	return filterKind(from.Kind()) != "" && filterKind(from.Kind()) == filterKind(to.Kind())
+}
+
+func filterKind(kind reflect.Kind) string {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "bool"
+	}
+	return ""
+}
+
+// Where builds the WHERE clause of input, a value of the mapper's filter
+// input type, combining all set operators with AND. It returns an empty
+// clause if no operators are set.
+func (m *FilterMapper) Where(input interface{}) (string, []interface{}, error) {
+	if input == nil {
+		return "", nil, fmt.Errorf("nil filter input, expected %s", m.inputType)
+	}
+	value := reflect.ValueOf(input)
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return "", nil, nil
+		}
+		value = value.Elem()
+	}
+	if value.Type() != m.inputType {
+		return "", nil, fmt.Errorf("bad filter input type %s, expected %s", value.Type(), m.inputType)
+	}
+
+	var clauses []string
+	var values []interface{}
+	for _, field := range m.fields {
+		operators := value.FieldByIndex(field.index)
+		if operators.Kind() == reflect.Ptr {
+			if operators.IsNil() {
+				continue
+			}
+			operators = operators.Elem()
+		}
+
+		for _, operator := range field.operators {
+			operand := operators.Field(operator.index)
+			if operand.IsNil() {
+				continue
+			}
+
+			switch operator.sql {
+			case "IN":
+				if operand.Len() == 0 {
+					// Nothing is in an empty list.
+					clauses = append(clauses, "1=0")
+					continue
+				}
+				placeholders := make([]string, operand.Len())
+				for i := range placeholders {
+					v, err := m.coerce(field.column, operand.Index(i))
+					if err != nil {
+						return "", nil, err
+					}
+					placeholders[i] = "?"
+					values = append(values, v)
+				}
+				clauses = append(clauses, fmt.Sprintf("%s IN (%s)", field.column.Name, strings.Join(placeholders, ", ")))
+			case "LIKE":
+				clauses = append(clauses, field.column.Name+" LIKE ?")
+				values = append(values, "%"+likeEscaper.Replace(operand.Elem().String())+"%")
+			default:
+				v, err := m.coerce(field.column, operand.Elem())
+				if err != nil {
+					return "", nil, err
+				}
+				clauses = append(clauses, fmt.Sprintf("%s %s ?", field.column.Name, operator.sql))
+				values = append(values, v)
+			}
+		}
+	}
+	return strings.Join(clauses, " AND "), values, nil
+}
+
+// coerce converts value to the column's type, and then to its SQL value.
+func (m *FilterMapper) coerce(column *Column, value reflect.Value) (interface{}, error) {
+	converted, err := convertFilterValue(value, column.Descriptor.Type)
+	if err == nil {
+		var v interface{}
+		v, err = column.Descriptor.Valuer(converted).Value()
+		if err == nil {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("sqlgen: filter error for `%s`.`%s`: %v", m.table.Name, column.Name, err)
+}
+
+// convertFilterValue converts value to type to like reflect.Value.Convert, but
+// fails instead of truncating fractions or wrapping values out of range.
+func convertFilterValue(value reflect.Value, to reflect.Type) (reflect.Value, error) {
+	target := reflect.New(to).Elem()
+	var ok bool
+	switch to.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch value.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			ok = !target.OverflowInt(value.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			ok = value.Uint() <= math.MaxInt64 && !target.OverflowInt(int64(value.Uint()))
+		case reflect.Float32, reflect.Float64:
+			f := value.Float()
+			ok = f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 && !target.OverflowInt(int64(f))
+		default:
+			ok = true
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		switch value.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			ok = value.Int() >= 0 && !target.OverflowUint(uint64(value.Int()))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			ok = !target.OverflowUint(value.Uint())
+		case reflect.Float32, reflect.Float64:
+			f := value.Float()
+			ok = f == math.Trunc(f) && f >= 0 && f < math.MaxUint64 && !target.OverflowUint(uint64(f))
+		default:
+			ok = true
+		}
+	case reflect.Float32:
+		switch value.Kind() {
+		case reflect.Float32, reflect.Float64:
+			ok = !target.OverflowFloat(value.Float())
+		default:
+			ok = true
+		}
+	default:
+		ok = true
+	}
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("%v does not fit in %s", value.Interface(), to)
+	}
+	return value.Convert(to), nil
+}
+
+// IncludeFilterInput restricts s to rows matching input, a filter input of
+// mapper.
+func (s *SelectOptions) IncludeFilterInput(mapper *FilterMapper, input interface{}) error {
+	where, values, err := mapper.Where(input)
+	if err != nil {
+		return err
+	}
+	s.includeWhere(where, values)
+	return nil
+}
@@ -0,0 +1,132 @@
+package sqlgen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type userFilter struct {
+	Name *StringFilter
+	Age  *IntFilter
+	Nick *StringFilter `sql:"optional"`
+}
+
+func TestFilterMapper(t *testing.T) {
+	s := NewSchema()
+	if err := s.RegisterType("users", AutoIncrement, user{}); err != nil {
+		t.Fatal(err)
+	}
+	table := s.ByName["users"]
+
+	mapper, err := NewFilterMapper(table, userFilter{}, "name", "age", "optional")
+	require.NoError(t, err)
+
+	name, nick, age := "bob", "50%_off", int64(18)
+	options := &SelectOptions{Where: "id > ?", Values: []interface{}{int64(3)}}
+	require.NoError(t, options.IncludeFilterInput(mapper, &userFilter{
+		Name: &StringFilter{Eq: &name, In: []string{"alice", "bob"}},
+		Age:  &IntFilter{Gt: &age, Lt: &age},
+		Nick: &StringFilter{Contains: &nick},
+	}))
+	assert.Equal(t, &SelectOptions{
+		Where:  "(name = ? AND name IN (?, ?) AND age > ? AND age < ? AND optional LIKE ?) AND (id > ?)",
+		Values: []interface{}{"bob", "alice", "bob", int64(18), int64(18), `%50\%\_off%`, int64(3)},
+	}, options)
+
+	options = &SelectOptions{}
+	require.NoError(t, options.IncludeFilterInput(mapper, &userFilter{Age: &IntFilter{}}))
+	require.NoError(t, options.IncludeFilterInput(mapper, (*userFilter)(nil)))
+	assert.Equal(t, &SelectOptions{}, options)
+
+	require.NoError(t, options.IncludeFilterInput(mapper, userFilter{Age: &IntFilter{In: []int64{}}}))
+	assert.Equal(t, &SelectOptions{Where: "1=0"}, options)
+
+	assert.EqualError(t, options.IncludeFilterInput(mapper, &IntFilter{}),
+		"bad filter input type sqlgen.IntFilter, expected sqlgen.userFilter")
+}
+
+func TestFilterMapperErrors(t *testing.T) {
+	s := NewSchema()
+	if err := s.RegisterType("users", AutoIncrement, user{}); err != nil {
+		t.Fatal(err)
+	}
+	table := s.ByName["users"]
+
+	_, err := NewFilterMapper(table, userFilter{}, "name", "age")
+	assert.EqualError(t, err, "bad filter input type sqlgen.userFilter: column optional is not filterable")
+
+	_, err = NewFilterMapper(table, userFilter{}, "bogus")
+	assert.EqualError(t, err, "unknown column bogus")
+
+	_, err = NewFilterMapper(table, "name")
+	assert.EqualError(t, err, "bad filter input type string: not a struct")
+
+	_, err = NewFilterMapper(table, struct{ Age *StringFilter }{}, "age")
+	assert.EqualError(t, err, "bad filter input type struct { Age *sqlgen.StringFilter }: field Age: operator Eq of type string cannot filter column age of type int64")
+
+	_, err = NewFilterMapper(table, struct{ Name *struct{ Like *string } }{}, "name")
+	assert.EqualError(t, err, "bad filter input type struct { Name *struct { Like *string } }: field Name: unknown operator Like")
+
+	_, err = NewFilterMapper(table, struct{ Name *struct{ In *string } }{}, "name")
+	assert.EqualError(t, err, "bad filter input type struct { Name *struct { In *string } }: field Name: operator In should be a slice")
+
+	// Operators may hold any value convertible to the column's type.
+	mapper, err := NewFilterMapper(table, struct{ Age *struct{ Eq *int32 } }{}, "age")
+	require.NoError(t, err)
+	age := int32(20)
+	where, values, err := mapper.Where(struct{ Age *struct{ Eq *int32 } }{Age: &struct{ Eq *int32 }{Eq: &age}})
+	require.NoError(t, err)
+	assert.Equal(t, "age = ?", where)
+	assert.Equal(t, []interface{}{int64(20)}, values)
+}
+
+func TestFilterMapperConversions(t *testing.T) {
+	type score struct {
+		Id    int64 `sql:",primary"`
+		Small int8
+		Count uint16
+	}
+	s := NewSchema()
+	if err := s.RegisterType("scores", AutoIncrement, score{}); err != nil {
+		t.Fatal(err)
+	}
+	table := s.ByName["scores"]
+
+	type scoreFilter struct {
+		Small *struct {
+			Eq *float64
+			In []int64
+		}
+		Count *struct{ Eq *int64 }
+	}
+	mapper, err := NewFilterMapper(table, scoreFilter{}, "small", "count")
+	require.NoError(t, err)
+
+	where := func(small *float64, in []int64, count *int64) error {
+		filter := scoreFilter{Count: &struct{ Eq *int64 }{Eq: count}}
+		filter.Small = &struct {
+			Eq *float64
+			In []int64
+		}{Eq: small, In: in}
+		_, _, err := mapper.Where(filter)
+		return err
+	}
+	float, count := 12.0, int64(65535)
+	require.NoError(t, where(&float, []int64{-128, 127}, &count))
+
+	// Fractions are not truncated.
+	float = 1.5
+	assert.EqualError(t, where(&float, nil, nil), "sqlgen: filter error for `scores`.`small`: 1.5 does not fit in int8")
+
+	// Values out of range do not wrap.
+	assert.EqualError(t, where(nil, []int64{128}, nil), "sqlgen: filter error for `scores`.`small`: 128 does not fit in int8")
+	count = -1
+	assert.EqualError(t, where(nil, nil, &count), "sqlgen: filter error for `scores`.`count`: -1 does not fit in uint16")
+	count = 65536
+	assert.EqualError(t, where(nil, nil, &count), "sqlgen: filter error for `scores`.`count`: 65536 does not fit in uint16")
+
+	_, _, err = mapper.Where(nil)
+	assert.EqualError(t, err, "nil filter input, expected sqlgen.scoreFilter")
+}
@@ -0,0 +1,122 @@
+package sqlgen
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// HookEvent is a point in the lifecycle of a row written through a DB, see
+// RegisterHook.
+type HookEvent int
+
+const (
+	BeforeInsert HookEvent = iota
+	AfterInsert
+	BeforeUpsert
+	AfterUpsert
+	BeforeUpdate
+	AfterUpdate
+	BeforeDelete
+	AfterDelete
+)
+
+func (e HookEvent) String() string {
+	switch e {
+	case BeforeInsert:
+		return "BeforeInsert"
+	case AfterInsert:
+		return "AfterInsert"
+	case BeforeUpsert:
+		return "BeforeUpsert"
+	case AfterUpsert:
+		return "AfterUpsert"
+	case BeforeUpdate:
+		return "BeforeUpdate"
+	case AfterUpdate:
+		return "AfterUpdate"
+	case BeforeDelete:
+		return "BeforeDelete"
+	case AfterDelete:
+		return "AfterDelete"
+	default:
+		return fmt.Sprintf("HookEvent(%d)", int(e))
+	}
+}
+
+// Hook is called with the context and the row of a write, a pointer to a
+// struct of the table's type.
+type Hook func(ctx context.Context, row interface{}) error
+
+// RegisterHook registers hook to be called on event for every row of table
+// written with DB.InsertRow, UpsertRow, UpdateRow, DeleteRow, or an ExecBatch.
+// Hooks run in the order they are registered, and must be registered before
+// the schema is used.
+//
+// Before hooks run before the write's query is built, so they can modify the
+// row, for example to maintain denormalized columns. An error from a before
+// hook cancels the write and is returned by it.
+//
+// After hooks run once the write succeeded, for example to write an audit
+// log or bust caches. An error from an after hook is returned by the write,
+// which has already happened; writes in a transaction are not committed yet,
+// so the caller can roll them back. ExecBatch runs the after hooks of its
+// operations once it committed, see ExecBatch.Exec.
+//
+// For example, after registering
+//
+//	schema.RegisterHook("users", sqlgen.BeforeUpdate, func(ctx context.Context, row interface{}) error {
+//		row.(*User).UpdatedAt = time.Now()
+//		return nil
+//	})
+//
+// every update of a User sets its UpdatedAt column.
+func (s *Schema) RegisterHook(table string, event HookEvent, hook Hook) error {
+	t, ok := s.ByName[table]
+	if !ok {
+		return fmt.Errorf("unknown table %s", table)
+	}
+	if event < BeforeInsert || event > AfterDelete {
+		return fmt.Errorf("unknown hook event %s", event)
+	}
+	if t.hooks == nil {
+		t.hooks = make(map[HookEvent][]Hook)
+	}
+	t.hooks[event] = append(t.hooks[event], hook)
+	return nil
+}
+
+// MustRegisterHook calls RegisterHook and panics on error.
+func (s *Schema) MustRegisterHook(table string, event HookEvent, hook Hook) {
+	if err := s.RegisterHook(table, event, hook); err != nil {
+		panic(err)
+	}
+}
+
+// hooksFor returns the hooks registered for event on the table of row.
+func (s *Schema) hooksFor(event HookEvent, row interface{}) []Hook {
+	typ := reflect.TypeOf(row)
+	if typ == nil || typ.Kind() != reflect.Ptr {
+		return nil
+	}
+	if table, ok := s.ByType[typ.Elem()]; ok {
+		return table.hooks[event]
+	}
+	return nil
+}
+
+// hasHooks reports whether hooks are registered for event on the table of
+// row.
+func (s *Schema) hasHooks(event HookEvent, row interface{}) bool {
+	return len(s.hooksFor(event, row)) > 0
+}
+
+// runHooks calls the hooks registered for event on the table of row.
+func (s *Schema) runHooks(ctx context.Context, event HookEvent, row interface{}) error {
+	for _, hook := range s.hooksFor(event, row) {
+		if err := hook(ctx, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package sqlgen
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterHook(t *testing.T) {
+	schema := NewSchema()
+	schema.MustRegisterType("users", AutoIncrement, User{})
+
+	var events []string
+	record := func(name string) Hook {
+		return func(ctx context.Context, row interface{}) error {
+			events = append(events, name+" "+row.(*User).Name)
+			return nil
+		}
+	}
+	schema.MustRegisterHook("users", BeforeInsert, record("first"))
+	schema.MustRegisterHook("users", BeforeInsert, record("second"))
+
+	assert.EqualError(t, schema.RegisterHook("unknown", BeforeInsert, record("")), "unknown table unknown")
+	assert.EqualError(t, schema.RegisterHook("users", HookEvent(42), record("")), "unknown hook event HookEvent(42)")
+
+	ctx := context.Background()
+	require.NoError(t, schema.runHooks(ctx, BeforeInsert, &User{Name: "Alice"}))
+	require.NoError(t, schema.runHooks(ctx, AfterInsert, &User{Name: "Alice"}))
+	require.NoError(t, schema.runHooks(ctx, BeforeInsert, &JustId{Id: 1}))
+	assert.Equal(t, []string{"first Alice", "second Alice"}, events)
+
+	failed := errors.New("failed")
+	schema.MustRegisterHook("users", BeforeDelete, func(ctx context.Context, row interface{}) error {
+		return failed
+	})
+	assert.Equal(t, failed, schema.runHooks(ctx, BeforeDelete, &User{}))
+}
+
+func TestExecBatchBeforeHooks(t *testing.T) {
+	schema := NewSchema()
+	schema.MustRegisterType("users", AutoIncrement, User{})
+	schema.MustRegisterHook("users", BeforeInsert, func(ctx context.Context, row interface{}) error {
+		row.(*User).Name = "Bob"
+		return nil
+	})
+
+	batch := (&DB{Schema: schema}).NewExecBatch()
+	require.NoError(t, batch.InsertRow(&User{Name: "Alice"}))
+	require.NoError(t, batch.runBeforeHooks(context.Background()))
+
+	_, args := batch.ops[0].query.ToSQL()
+	assert.Contains(t, args, "Bob")
+	assert.Equal(t, AfterInsert, batch.ops[0].after)
+}
+
+func TestHooks(t *testing.T) {
+	tdb, db, err := setup()
+	require.NoError(t, err)
+	defer tdb.Close()
+	ctx := context.Background()
+
+	var events []string
+	for _, event := range []HookEvent{BeforeInsert, AfterInsert, BeforeUpdate, AfterUpdate, BeforeDelete, AfterDelete} {
+		event := event
+		db.Schema.MustRegisterHook("users", event, func(ctx context.Context, row interface{}) error {
+			events = append(events, event.String()+" "+row.(*User).Name)
+			return nil
+		})
+	}
+	db.Schema.MustRegisterHook("users", BeforeUpdate, func(ctx context.Context, row interface{}) error {
+		row.(*User).Name += "!"
+		return nil
+	})
+
+	user := &User{Name: "Alice"}
+	res, err := db.InsertRow(ctx, user)
+	require.NoError(t, err)
+	user.Id, err = res.LastInsertId()
+	require.NoError(t, err)
+
+	require.NoError(t, db.UpdateRow(ctx, user))
+	var updated *User
+	require.NoError(t, db.QueryRow(ctx, &updated, Filter{"id": user.Id}, nil))
+	assert.Equal(t, "Alice!", updated.Name)
+
+	require.NoError(t, db.DeleteRow(ctx, user))
+	assert.Equal(t, []string{
+		"BeforeInsert Alice",
+		"AfterInsert Alice",
+		"BeforeUpdate Alice",
+		"AfterUpdate Alice!",
+		"BeforeDelete Alice!",
+		"AfterDelete Alice!",
+	}, events)
+
+	// Failing before hooks cancel writes.
+	failed := errors.New("failed")
+	db.Schema.MustRegisterHook("users", BeforeInsert, func(ctx context.Context, row interface{}) error {
+		return failed
+	})
+	_, err = db.InsertRow(ctx, &User{Name: "Bob"})
+	assert.Equal(t, failed, err)
+	count, err := db.Count(ctx, &User{}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+}
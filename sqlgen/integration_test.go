@@ -329,3 +329,49 @@ func BenchmarkSql(b *testing.B) {
 		})
 	}
 }
+
+func TestQueryIter(t *testing.T) {
+	tdb, db, err := setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tdb.Close()
+
+	ctx := context.Background()
+	for _, name := range []string{"Alice", "Bob", "Carol"} {
+		if _, err := db.InsertRow(ctx, &User{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	iter, err := db.QueryIter(ctx, &User{}, nil, &SelectOptions{OrderBy: "id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer iter.Close()
+
+	var names []string
+	for iter.Next() {
+		var user User
+		if err := iter.Scan(&user); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, user.Name)
+	}
+	assert.NoError(t, iter.Err())
+	assert.Equal(t, []string{"Alice", "Bob", "Carol"}, names)
+
+	_, err = db.QueryIter(ctx, []*User{}, nil, nil)
+	assert.Equal(t, errBadIterModelType, err)
+
+	// Canceling the context stops the iteration.
+	cancelCtx, cancel := context.WithCancel(ctx)
+	iter, err = db.QueryIter(cancelCtx, &User{}, Filter{"name": "Bob"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer iter.Close()
+	cancel()
+	assert.False(t, iter.Next())
+	assert.Equal(t, context.Canceled, iter.Err())
+}
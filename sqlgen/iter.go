@@ -0,0 +1,162 @@
+package sqlgen
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/denkhaus/thunder/internal/sqlcomment"
+)
+
+var errBadIterModelType = errors.New("iter model value should be a pointer to a struct")
+
+// RowIter is a cursor over the rows of a query. Rows are scanned one at a
+// time, so large results are never held in memory at once.
+//
+// A RowIter holds a database connection until it is closed, so it must
+// always be closed, even if Next was called until it returned false.
+type RowIter struct {
+	ctx   context.Context
+	table *Table
+	rows  *sql.Rows
+	err   error
+	// peeked is true if rows was advanced to the first row by QueryIter,
+	// and Next has not been called yet.
+	peeked bool
+}
+
+// QueryIter starts a query and returns a cursor over its rows, for results
+// too large to fetch at once with Query, such as exports.
+//
+// model should be a pointer to a struct, for example:
+//
+//	iter, err := db.QueryIter(ctx, &User{}, Filter{}, nil)
+//	if err != nil { ... }
+//	defer iter.Close()
+//
+//	for iter.Next() {
+//		var user User
+//		if err := iter.Scan(&user); err != nil { ... }
+//	}
+//	if err := iter.Err(); err != nil { ... }
+//
+// Unlike Query, QueryIter never batches queries. Like Query, it retries
+// transient errors with the retry policy of ctx, see WithRetries, but only
+// until the first row is read. Errors while iterating are not retried.
+func (db *DB) QueryIter(ctx context.Context, model interface{}, filter Filter, options *SelectOptions) (*RowIter, error) {
+	typ := reflect.TypeOf(model)
+	if typ == nil || typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
+		return nil, errBadIterModelType
+	}
+
+	query, err := db.Schema.makeSelect(typ.Elem(), filter, options)
+	if err != nil {
+		return nil, err
+	}
+	selectQuery, err := query.MakeSelectQuery()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.checkFilterAgainstLimits(ctx, selectQuery, query.Filter, query.Table); err != nil {
+		return nil, err
+	}
+	if err := db.checkPool(ctx); err != nil {
+		return nil, err
+	}
+
+	clause, args := selectQuery.ToSQL()
+	var rows *sql.Rows
+	var peeked bool
+	if err := db.retry(ctx, func() error {
+		res, err := db.QueryExecer(ctx).QueryContext(ctx, sqlcomment.Append(ctx, clause), args...)
+		if err != nil {
+			return err
+		}
+		// Read the first row, so that errors before it are retried along
+		// with the query.
+		peeked = res.Next()
+		if !peeked {
+			if err := res.Err(); err != nil {
+				res.Close()
+				return err
+			}
+		}
+		rows = res
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return &RowIter{
+		ctx:    ctx,
+		table:  query.Table,
+		rows:   rows,
+		peeked: peeked,
+	}, nil
+}
+
+// Next advances to the next row. It returns false when there are no more
+// rows, or when the query failed or its context was canceled; Err tells
+// these apart.
+func (it *RowIter) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	if err := it.ctx.Err(); err != nil {
+		it.err = err
+		it.rows.Close()
+		return false
+	}
+	if it.peeked {
+		it.peeked = false
+		return true
+	}
+	return it.rows.Next()
+}
+
+// Scan scans the current row into dest, a pointer to a struct of the model
+// type.
+func (it *RowIter) Scan(dest interface{}) error {
+	ptr := reflect.ValueOf(dest)
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() || ptr.Type().Elem() != it.table.Type {
+		return fmt.Errorf("sqlgen: scan destination should be a *%s, got %T", it.table.Type, dest)
+	}
+	return it.scanErr(scanQueryRow(it.table, it.rows, ptr.Elem()))
+}
+
+// Row scans the current row into a new struct of the model type, and returns
+// a pointer to it.
+func (it *RowIter) Row() (interface{}, error) {
+	row, err := parseQueryRow(it.table, it.rows)
+	if err != nil {
+		return nil, it.scanErr(err)
+	}
+	return row, nil
+}
+
+// scanErr reports the context's error instead of err if the context was
+// canceled while scanning, as database/sql then closes the rows and only
+// reports that they are closed.
+func (it *RowIter) scanErr(err error) error {
+	if err != nil && it.ctx.Err() != nil {
+		return it.ctx.Err()
+	}
+	return err
+}
+
+// Err returns the error, if any, that stopped the iteration.
+func (it *RowIter) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.rows.Err()
+}
+
+// Close releases the connection held by the iterator. It is safe to call
+// Close more than once.
+func (it *RowIter) Close() error {
+	return it.rows.Close()
+}
@@ -0,0 +1,181 @@
+package sqlgen
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// TableMetadata describes a registered table to external tools, such as
+// schema documentation, data catalogs, and migration linters.
+type TableMetadata struct {
+	Name string `json:"name"`
+	// GoType is the registered struct type, including its package path.
+	GoType string `json:"goType"`
+	// PrimaryKeyType is "autoIncrement" or "uniqueId".
+	PrimaryKeyType string `json:"primaryKeyType"`
+	// PrimaryKey holds the primary key columns, in column order.
+	PrimaryKey []string          `json:"primaryKey"`
+	Columns    []*ColumnMetadata `json:"columns"`
+	// Expressions holds the registered filter expressions, sorted by name.
+	Expressions []*ExpressionMetadata `json:"expressions,omitempty"`
+}
+
+// ColumnMetadata describes a column of a registered table.
+type ColumnMetadata struct {
+	Name string `json:"name"`
+	// Field is the name of the struct field holding the column.
+	Field  string `json:"field"`
+	GoType string `json:"goType"`
+	// SQLType is the kind of value stored in the database: "int", "float",
+	// "bool", "string", "bytes", or "time", or "unknown" for custom
+	// driver.Valuers that store NULL for zero values.
+	SQLType string `json:"sqlType"`
+	// Encoding is the encoding of values stored as "binary", "json", or
+	// "string", if any.
+	Encoding string `json:"encoding,omitempty"`
+	// Nullable is set for pointer and `implicitnull` columns.
+	Nullable  bool `json:"nullable"`
+	Primary   bool `json:"primary,omitempty"`
+	Generated bool `json:"generated,omitempty"`
+}
+
+// ExpressionMetadata describes a filter expression of a registered table.
+type ExpressionMetadata struct {
+	Name string `json:"name"`
+	SQL  string `json:"sql"`
+}
+
+// Metadata describes all registered tables, sorted by name.
+func (s *Schema) Metadata() []*TableMetadata {
+	names := make([]string, 0, len(s.ByName))
+	for name := range s.ByName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tables := make([]*TableMetadata, 0, len(names))
+	for _, name := range names {
+		tables = append(tables, s.ByName[name].metadata())
+	}
+	return tables
+}
+
+// WriteMetadata writes the metadata of all registered tables to w as JSON.
+func (s *Schema) WriteMetadata(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(s.Metadata())
+}
+
+func (t *Table) metadata() *TableMetadata {
+	metadata := &TableMetadata{
+		Name:           t.Name,
+		GoType:         t.Type.PkgPath() + "." + t.Type.Name(),
+		PrimaryKeyType: "autoIncrement",
+		PrimaryKey:     []string{},
+		Columns:        make([]*ColumnMetadata, 0, len(t.Columns)),
+	}
+	if t.PrimaryKeyType == UniqueId {
+		metadata.PrimaryKeyType = "uniqueId"
+	}
+
+	for _, column := range t.Columns {
+		d := column.Descriptor
+		field := t.Type.FieldByIndex(column.Index)
+		columnMetadata := &ColumnMetadata{
+			Name:      column.Name,
+			Field:     field.Name,
+			GoType:    field.Type.String(),
+			SQLType:   column.sqlType(),
+			Encoding:  column.encoding(),
+			Nullable:  d.Ptr,
+			Primary:   column.Primary,
+			Generated: column.Generated,
+		}
+		if d.Tags.Contains("implicitnull") {
+			columnMetadata.Nullable = true
+		}
+		metadata.Columns = append(metadata.Columns, columnMetadata)
+		if column.Primary {
+			metadata.PrimaryKey = append(metadata.PrimaryKey, column.Name)
+		}
+	}
+
+	for _, expression := range t.Expressions {
+		metadata.Expressions = append(metadata.Expressions, &ExpressionMetadata{
+			Name: expression.Name,
+			SQL:  expression.SQL,
+		})
+	}
+	sort.Slice(metadata.Expressions, func(i, j int) bool {
+		return metadata.Expressions[i].Name < metadata.Expressions[j].Name
+	})
+	return metadata
+}
+
+// encoding returns the tag overriding how the column is stored, if any.
+func (c *Column) encoding() string {
+	for _, encoding := range []string{"binary", "json", "string"} {
+		if c.Descriptor.Tags.Contains(encoding) {
+			return encoding
+		}
+	}
+	return ""
+}
+
+// sqlType returns the kind of value the column stores in the database, from
+// the value of a zero field, or from the field's kind if that value is NULL.
+func (c *Column) sqlType() string {
+	d := c.Descriptor
+	var val reflect.Value
+	if d.Ptr {
+		val = reflect.New(d.Type)
+	} else {
+		val = reflect.Zero(d.Type)
+	}
+	value, err := d.Valuer(val).Value()
+	if err != nil {
+		return "unknown"
+	}
+
+	switch value.(type) {
+	case int64:
+		return "int"
+	case float64:
+		return "float"
+	case bool:
+		return "bool"
+	case string:
+		return "string"
+	case []byte:
+		return "bytes"
+	case time.Time:
+		return "time"
+	case nil:
+		if c.encoding() != "" {
+			return "bytes"
+		}
+		switch d.Kind {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return "int"
+		case reflect.Float32, reflect.Float64:
+			return "float"
+		case reflect.Bool:
+			return "bool"
+		case reflect.String:
+			return "string"
+		case reflect.Slice:
+			if d.Type.Elem().Kind() == reflect.Uint8 {
+				return "bytes"
+			}
+		}
+		if d.Type == reflect.TypeOf(time.Time{}) {
+			return "time"
+		}
+	}
+	return "unknown"
+}
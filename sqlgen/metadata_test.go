@@ -0,0 +1,73 @@
+package sqlgen
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type metadataUser struct {
+	Id        int64 `sql:",primary"`
+	Name      string
+	Nickname  string `sql:",implicitnull"`
+	Score     *float64
+	Tags      []string `sql:"tag_list,json"`
+	CreatedAt time.Time
+	Upper     string `sql:",generated"`
+	Ignored   bool   `sql:"-"`
+}
+
+type metadataMembership struct {
+	UserId  int64 `sql:",primary"`
+	OrgId   int64 `sql:",primary"`
+	IsAdmin bool
+}
+
+func TestSchemaMetadata(t *testing.T) {
+	schema := NewSchema()
+	schema.MustRegisterType("users", AutoIncrement, metadataUser{})
+	schema.MustRegisterType("memberships", UniqueId, metadataMembership{})
+	require.NoError(t, schema.RegisterExpression("users", &Expression{Name: "name_length", SQL: "CHAR_LENGTH(name)"}))
+
+	tables := schema.Metadata()
+	require.Len(t, tables, 2)
+	assert.Equal(t, &TableMetadata{
+		Name:           "memberships",
+		GoType:         "github.com/denkhaus/thunder/sqlgen.metadataMembership",
+		PrimaryKeyType: "uniqueId",
+		PrimaryKey:     []string{"user_id", "org_id"},
+		Columns: []*ColumnMetadata{
+			{Name: "user_id", Field: "UserId", GoType: "int64", SQLType: "int", Primary: true},
+			{Name: "org_id", Field: "OrgId", GoType: "int64", SQLType: "int", Primary: true},
+			{Name: "is_admin", Field: "IsAdmin", GoType: "bool", SQLType: "bool"},
+		},
+	}, tables[0])
+	assert.Equal(t, &TableMetadata{
+		Name:           "users",
+		GoType:         "github.com/denkhaus/thunder/sqlgen.metadataUser",
+		PrimaryKeyType: "autoIncrement",
+		PrimaryKey:     []string{"id"},
+		Columns: []*ColumnMetadata{
+			{Name: "id", Field: "Id", GoType: "int64", SQLType: "int", Primary: true},
+			{Name: "name", Field: "Name", GoType: "string", SQLType: "string"},
+			{Name: "nickname", Field: "Nickname", GoType: "string", SQLType: "string", Nullable: true},
+			{Name: "score", Field: "Score", GoType: "*float64", SQLType: "float", Nullable: true},
+			{Name: "tag_list", Field: "Tags", GoType: "[]string", SQLType: "bytes", Encoding: "json"},
+			{Name: "created_at", Field: "CreatedAt", GoType: "time.Time", SQLType: "time"},
+			{Name: "upper", Field: "Upper", GoType: "string", SQLType: "string", Generated: true},
+		},
+		Expressions: []*ExpressionMetadata{
+			{Name: "name_length", SQL: "CHAR_LENGTH(name)"},
+		},
+	}, tables[1])
+
+	var buf bytes.Buffer
+	require.NoError(t, schema.WriteMetadata(&buf))
+	var decoded []*TableMetadata
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, tables, decoded)
+}
@@ -0,0 +1,67 @@
+package sqlgen
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrPoolSaturated is returned for fail-fast queries if all connections of
+// the pool are in use. See WithFailFast.
+var ErrPoolSaturated = errors.New("sqlgen: connection pool saturated")
+
+type failFastKey struct{}
+
+// WithFailFast makes queries run with ctx fail with ErrPoolSaturated instead
+// of waiting for a connection when the pool is saturated. Latency-critical
+// resolvers can use it to return an error, or fall back to cached data,
+// rather than queue behind slower queries.
+//
+// Saturation is only detected if the pool has a maximum number of open
+// connections, set with sql.DB.SetMaxOpenConns, and only when built with Go
+// 1.11 or later, whose sql.DBStats count the connections in use. Queries in a
+// transaction already hold their connection and never fail fast.
+func WithFailFast(ctx context.Context) context.Context {
+	return context.WithValue(ctx, failFastKey{}, true)
+}
+
+// checkPool returns ErrPoolSaturated if ctx asks to fail fast and the pool
+// has no connection available.
+func (db *DB) checkPool(ctx context.Context) error {
+	if failFast, _ := ctx.Value(failFastKey{}).(bool); !failFast || db.HasTx(ctx) {
+		return nil
+	}
+	if poolSaturated(db.Conn.Stats()) {
+		return ErrPoolSaturated
+	}
+	return nil
+}
+
+// PoolStats returns the statistics of the connection pool, such as the number
+// of connections in use and idle, and how often and long queries waited for
+// a connection.
+func (db *DB) PoolStats() sql.DBStats {
+	return db.Conn.Stats()
+}
+
+// ReportPoolStats calls report with the pool statistics every interval, to
+// export them as metrics, until ctx is done.
+func (db *DB) ReportPoolStats(ctx context.Context, interval time.Duration, report func(stats sql.DBStats)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			report(db.Conn.Stats())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// HealthCheck pings the database, and returns an error if it cannot be
+// reached before ctx is done.
+func (db *DB) HealthCheck(ctx context.Context) error {
+	return db.Conn.PingContext(ctx)
+}
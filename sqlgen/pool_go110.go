@@ -0,0 +1,12 @@
+//go:build !go1.11
+// +build !go1.11
+
+package sqlgen
+
+import "database/sql"
+
+// poolSaturated returns false, as sql.DBStats do not count the connections in
+// use before Go 1.11.
+func poolSaturated(stats sql.DBStats) bool {
+	return false
+}
@@ -0,0 +1,11 @@
+//go:build go1.11
+// +build go1.11
+
+package sqlgen
+
+import "database/sql"
+
+// poolSaturated returns true if all connections of the pool are in use.
+func poolSaturated(stats sql.DBStats) bool {
+	return stats.MaxOpenConnections > 0 && stats.InUse >= stats.MaxOpenConnections
+}
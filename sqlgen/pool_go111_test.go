@@ -0,0 +1,44 @@
+//go:build go1.11
+// +build go1.11
+
+package sqlgen
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolFailFast(t *testing.T) {
+	tdb, db, err := setup()
+	require.NoError(t, err)
+	defer tdb.Close()
+	ctx := context.Background()
+
+	require.NoError(t, db.HealthCheck(ctx))
+
+	db.Conn.SetMaxOpenConns(1)
+	_, err = db.InsertRow(WithFailFast(ctx), &User{Name: "Alice"})
+	require.NoError(t, err)
+
+	// Hold the only connection in a transaction.
+	txCtx, tx, err := db.WithTx(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, db.PoolStats().InUse)
+
+	var users []*User
+	assert.Equal(t, ErrPoolSaturated, db.Query(WithFailFast(ctx), &users, nil, nil))
+	_, err = db.Count(WithFailFast(ctx), &User{}, nil)
+	assert.Equal(t, ErrPoolSaturated, err)
+	_, err = db.InsertRow(WithFailFast(ctx), &User{Name: "Bob"})
+	assert.Equal(t, ErrPoolSaturated, err)
+
+	// Queries in the transaction use its connection.
+	require.NoError(t, db.Query(WithFailFast(txCtx), &users, nil, nil))
+	assert.Len(t, users, 1)
+	require.NoError(t, tx.Commit())
+
+	require.NoError(t, db.Query(WithFailFast(ctx), &users, nil, nil))
+}
@@ -0,0 +1,37 @@
+package sqlgen
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportPoolStats(t *testing.T) {
+	tdb, db, err := setup()
+	require.NoError(t, err)
+	defer tdb.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	reported := make(chan sql.DBStats, 1)
+	done := make(chan struct{})
+	go func() {
+		db.ReportPoolStats(ctx, time.Millisecond, func(stats sql.DBStats) {
+			select {
+			case reported <- stats:
+			default:
+			}
+		})
+		close(done)
+	}()
+
+	stats := <-reported
+	assert.Equal(t, db.PoolStats().OpenConnections, stats.OpenConnections)
+
+	// Reporting stops once ctx is done.
+	cancel()
+	<-done
+}
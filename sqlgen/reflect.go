@@ -13,6 +13,7 @@ import (
 	"unicode"
 
 	"github.com/denkhaus/thunder/internal/fields"
+	"github.com/denkhaus/thunder/internal/filter"
 )
 
 type Filter map[string]interface{}
@@ -30,20 +31,77 @@ func (s *SelectOptions) IncludeFilter(table *Table, filter Filter) error {
 	if err != nil {
 		return err
 	}
-	filterWhere, filterValues := simpleWhere.ToSQL()
+	s.includeWhere(simpleWhere.ToSQL())
+	return nil
+}
+
+// includeWhere ANDs where, with its values, into the WHERE clause of s.
+func (s *SelectOptions) includeWhere(where string, values []interface{}) {
+	if where == "" {
+		return
+	}
+	if s.Where != "" {
+		s.Where = fmt.Sprintf("(%s) AND (%s)", where, s.Where)
+		s.Values = append(values, s.Values...)
+	} else {
+		s.Where, s.Values = where, values
+	}
+}
+
+// IncludeTextFilter restricts s to rows where any of columns contains any of
+// the terms in text. Terms are parsed like the filterText argument of paginated
+// GraphQL fields, so resolvers can push text filters down to the database
+// instead of filtering in memory. Case sensitivity follows the column collation.
+func (s *SelectOptions) IncludeTextFilter(table *Table, columns []string, text string) error {
+	matchStrings := filter.GetMatchStrings(text)
+	if len(matchStrings) == 0 {
+		return nil
+	}
 
-	if filterWhere != "" {
-		if s.Where != "" {
-			s.Where = fmt.Sprintf("(%s) AND (%s)", filterWhere, s.Where)
-			s.Values = append(filterValues, s.Values...)
-		} else {
-			s.Where, s.Values = filterWhere, filterValues
+	var clauses []string
+	var values []interface{}
+	for _, name := range columns {
+		if _, ok := table.ColumnsByName[name]; !ok {
+			return fmt.Errorf("unknown column %s", name)
+		}
+		for _, matchString := range matchStrings {
+			if matchString == "" {
+				continue
+			}
+			clauses = append(clauses, name+" LIKE ?")
+			values = append(values, "%"+likeEscaper.Replace(matchString)+"%")
 		}
 	}
 
+	// Empty terms never match, just like in graphql text filters.
+	filterWhere := "1=0"
+	if len(clauses) > 0 {
+		filterWhere = strings.Join(clauses, " OR ")
+	}
+	s.includeWhere(filterWhere, values)
 	return nil
 }
 
+// IncludeOrderBy appends column to the ORDER BY clause of s.
+func (s *SelectOptions) IncludeOrderBy(table *Table, column string, descending bool) error {
+	if _, ok := table.ColumnsByName[column]; !ok {
+		return fmt.Errorf("unknown column %s", column)
+	}
+	orderBy := column
+	if descending {
+		orderBy += " DESC"
+	}
+	if s.OrderBy != "" {
+		s.OrderBy += ", " + orderBy
+	} else {
+		s.OrderBy = orderBy
+	}
+	return nil
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
 type PrimaryKeyType int
 
 const (
@@ -54,11 +112,25 @@ const (
 // parseQueryRow parses a row from a sql.DB query into a struct
 func parseQueryRow(table *Table, scanner *sql.Rows) (interface{}, error) {
 	ptr := reflect.New(table.Type)
-	elem := ptr.Elem()
+	if err := scanQueryRow(table, scanner, ptr.Elem()); err != nil {
+		return nil, err
+	}
+	return ptr.Interface(), nil
+}
 
+// scanQueryRow scans a row from a sql.DB query into elem, a struct of the
+// table's type
+func scanQueryRow(table *Table, scanner *sql.Rows, elem reflect.Value) error {
 	scanners := table.Scanners.Get().([]interface{})
 	defer table.Scanners.Put(scanners)
 
+	// Encrypted columns are decrypted before they are scanned.
+	targets := scanners
+	if table.encrypted {
+		targets = make([]interface{}, len(scanners))
+		copy(targets, scanners)
+	}
+
 	// Descriptor Scanner is instantiated with a reference to our struct fields.
 	// It scans directly into our struct.
 	for i, column := range table.Columns {
@@ -68,14 +140,16 @@ func parseQueryRow(table *Table, scanner *sql.Rows) (interface{}, error) {
 		}
 		// Scan into field.
 		scanners[i].(*fields.Scanner).Target(field)
+		if column.Encrypted {
+			targets[i] = &decryptingScanner{column: column, scanner: scanners[i].(*fields.Scanner)}
+		}
 	}
 
-	if err := scanner.Scan(scanners...); err != nil {
+	if err := scanner.Scan(targets...); err != nil {
 		columns, _ := scanner.Columns()
-		return nil, fmt.Errorf("sqlgen: parsing error for `%s`.(%v): %v", table.Name, columns, err)
+		return fmt.Errorf("sqlgen: parsing error for `%s`.(%v): %v", table.Name, columns, err)
 	}
-
-	return ptr.Interface(), nil
+	return nil
 }
 
 func CopySlice(result interface{}, rows []interface{}) error {
@@ -120,9 +194,18 @@ func makeSnake(s string) string {
 type Column struct {
 	Name    string
 	Primary bool
+	// Generated columns are computed by the database. They are read and can be
+	// filtered on, but are never written.
+	Generated bool
+	// Encrypted columns are encoded with the codec of the schema, see
+	// Schema.SetColumnCodec. They cannot be filtered on.
+	Encrypted bool
 
 	Descriptor *fields.Descriptor
 
+	table string
+	codec ColumnCodec
+
 	Index []int
 	Order int
 }
@@ -135,7 +218,21 @@ type Table struct {
 	Columns       []*Column
 	ColumnsByName map[string]*Column
 
+	// Expressions holds the filter expressions registered with
+	// Schema.RegisterExpression, by name.
+	Expressions map[string]*Expression
+
+	// Relationships holds the relationships registered with
+	// Schema.RegisterRelationship, by name.
+	Relationships map[string]*Relationship
+
 	Scanners *sync.Pool
+
+	// encrypted is true if any column is encrypted.
+	encrypted bool
+
+	// hooks holds the hooks registered with Schema.RegisterHook, by event.
+	hooks map[HookEvent][]Hook
 }
 
 func (s *Schema) buildDescriptor(table string, primaryKeyType PrimaryKeyType, typ reflect.Type) (*Table, error) {
@@ -168,12 +265,18 @@ func (s *Schema) buildDescriptor(table string, primaryKeyType PrimaryKeyType, ty
 		}
 
 		primary := false
+		generated := false
+		encrypted := false
 
 		if len(tags) > 1 {
 			for _, tag := range tags[1:] {
 				switch tag {
 				case "primary":
 					primary = true
+				case "generated":
+					generated = true
+				case "encrypted":
+					encrypted = true
 				case "binary", "json", "string":
 					// Do nothing, fields will handle these.
 				case "implicitnull":
@@ -195,14 +298,36 @@ func (s *Schema) buildDescriptor(table string, primaryKeyType PrimaryKeyType, ty
 			return nil, fmt.Errorf("bad type %s: %s %v", typ, column, err)
 		}
 
+		if primary && generated {
+			return nil, fmt.Errorf("bad type %s: primary column %s cannot be generated", typ, column)
+		}
+		if encrypted {
+			if primary {
+				return nil, fmt.Errorf("bad type %s: primary column %s cannot be encrypted", typ, column)
+			}
+			if s.codec == nil {
+				return nil, fmt.Errorf("bad type %s: column %s is encrypted, but the schema has no column codec", typ, column)
+			}
+			if err := checkEncryptable(d); err != nil {
+				return nil, fmt.Errorf("bad type %s: %s %v", typ, column, err)
+			}
+		}
+
 		descriptor := &Column{
-			Name:    column,
-			Primary: primary,
+			Name:      column,
+			Primary:   primary,
+			Generated: generated,
+			Encrypted: encrypted,
 
 			Index: field.Index,
 			Order: len(columns),
 
 			Descriptor: d,
+
+			table: table,
+		}
+		if encrypted {
+			descriptor.codec = s.codec
 		}
 
 		columns = append(columns, descriptor)
@@ -210,10 +335,13 @@ func (s *Schema) buildDescriptor(table string, primaryKeyType PrimaryKeyType, ty
 	}
 
 	hasPrimary := false
+	hasEncrypted := false
 	for _, column := range columns {
 		if column.Primary {
 			hasPrimary = true
-			break
+		}
+		if column.Encrypted {
+			hasEncrypted = true
 		}
 	}
 	if !hasPrimary {
@@ -237,8 +365,12 @@ func (s *Schema) buildDescriptor(table string, primaryKeyType PrimaryKeyType, ty
 
 		Columns:       columns,
 		ColumnsByName: columnsByName,
+		Expressions:   make(map[string]*Expression),
+		Relationships: make(map[string]*Relationship),
 
 		Scanners: scanners,
+
+		encrypted: hasEncrypted,
 	}, nil
 }
 
@@ -251,6 +383,9 @@ func (table *Table) unbuildStruct(strct interface{}) ([]interface{}, error) {
 		val := elem.FieldByIndex(column.Index)
 		var err error
 		values[i], err = column.Descriptor.Valuer(val).Value()
+		if err == nil {
+			values[i], err = column.encrypt(values[i])
+		}
 		if err != nil {
 			return nil, fmt.Errorf("sqlgen: serialization error for `%s`.`%s`: %v", table.Name, column.Name, err)
 		}
@@ -262,6 +397,8 @@ func (table *Table) unbuildStruct(strct interface{}) ([]interface{}, error) {
 type Schema struct {
 	ByName map[string]*Table
 	ByType map[reflect.Type]*Table
+
+	codec ColumnCodec
 }
 
 func NewSchema() *Schema {
@@ -327,39 +464,55 @@ func (s *Schema) ParseRows(query *SelectQuery, res *sql.Rows) ([]interface{}, er
 // whereElem is a sortable part of a WHERE clause used to build
 // deterministically-ordered WHERE clauses
 type whereElem struct {
-	column *Column
-	value  interface{}
+	// sql is the column name or expression compared to value.
+	sql   string
+	order int
+	value interface{}
 }
 
-// whereElemsByIndex sorts whereElems by column order
+// whereElemsByIndex sorts whereElems by column order, followed by
+// expressions in name order
 type whereElemsByIndex []whereElem
 
-func (l whereElemsByIndex) Len() int           { return len(l) }
-func (l whereElemsByIndex) Less(a, b int) bool { return l[a].column.Order < l[b].column.Order }
-func (l whereElemsByIndex) Swap(a, b int)      { l[a], l[b] = l[b], l[a] }
+func (l whereElemsByIndex) Len() int { return len(l) }
+func (l whereElemsByIndex) Less(a, b int) bool {
+	if l[a].order != l[b].order {
+		return l[a].order < l[b].order
+	}
+	return l[a].sql < l[b].sql
+}
+func (l whereElemsByIndex) Swap(a, b int) { l[a], l[b] = l[b], l[a] }
 
 // makeWhere builds a new SimpleWhere for table from filter
 func makeWhere(table *Table, filter Filter) (*SimpleWhere, error) {
 	var l whereElemsByIndex
 
 	for name, value := range filter {
+		if expression, ok := table.Expressions[name]; ok {
+			l = append(l, whereElem{sql: expression.SQL, order: len(table.Columns), value: value})
+			continue
+		}
+
 		column, ok := table.ColumnsByName[name]
 		if !ok {
 			return nil, fmt.Errorf("unknown column %s", name)
 		}
+		if column.Encrypted {
+			return nil, fmt.Errorf("cannot filter on encrypted column %s", name)
+		}
 
 		v, err := column.Descriptor.Valuer(reflect.ValueOf(value)).Value()
 		if err != nil {
 			return nil, fmt.Errorf("sqlgen: filter error for `%s`.`%s`: %v", table.Name, column.Name, err)
 		}
-		l = append(l, whereElem{column: column, value: v})
+		l = append(l, whereElem{sql: column.Name, order: column.Order, value: v})
 	}
 
 	sort.Sort(l)
 	columns := []string{}
 	values := []interface{}{}
 	for _, elem := range l {
-		columns = append(columns, elem.column.Name)
+		columns = append(columns, elem.sql)
 		values = append(values, elem.value)
 	}
 
@@ -551,7 +704,7 @@ func (s *Schema) MakeInsertRow(row interface{}) (*InsertQuery, error) {
 	var values []interface{}
 
 	for i, column := range table.Columns {
-		if column.Primary && table.PrimaryKeyType == AutoIncrement {
+		if column.Generated || (column.Primary && table.PrimaryKeyType == AutoIncrement) {
 			continue
 		}
 		columns = append(columns, column.Name)
@@ -582,13 +735,18 @@ func (s *Schema) MakeUpsertRow(row interface{}) (*UpsertQuery, error) {
 		return nil, errors.New("upsert only supports unique value primary keys")
 	}
 
-	values, err := table.unbuildStruct(row)
+	allValues, err := table.unbuildStruct(row)
 	if err != nil {
 		return nil, err
 	}
 	var columns []string
-	for _, column := range table.Columns {
+	var values []interface{}
+	for i, column := range table.Columns {
+		if column.Generated {
+			continue
+		}
 		columns = append(columns, column.Name)
+		values = append(values, allValues[i])
 	}
 
 	return &UpsertQuery{
@@ -622,7 +780,7 @@ func (s *Schema) MakeUpdateRow(row interface{}) (*UpdateQuery, error) {
 		if column.Primary {
 			whereColumns = append(whereColumns, column.Name)
 			whereValues = append(whereValues, allValues[i])
-		} else {
+		} else if !column.Generated {
 			columns = append(columns, column.Name)
 			values = append(values, allValues[i])
 		}
@@ -675,6 +833,93 @@ func (s *Schema) MakeDeleteRow(row interface{}) (*DeleteQuery, error) {
 	}, nil
 }
 
+var errEmptyMutateFilter = errors.New("filter of a bulk update or delete should not be empty")
+
+// makeMutateWhere builds the WHERE clause of a bulk update or delete of the
+// rows of model matching filter.
+func (s *Schema) makeMutateWhere(model interface{}, filter Filter) (*Table, *SimpleWhere, error) {
+	typ, err := checkCountModelTypeShape(reflect.TypeOf(model))
+	if err != nil {
+		return nil, nil, err
+	}
+	table, err := s.get(typ)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// An empty filter would update or delete the whole table, which is
+	// almost certainly a bug.
+	if len(filter) == 0 {
+		return nil, nil, errEmptyMutateFilter
+	}
+	where, err := makeWhere(table, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	return table, where, nil
+}
+
+// MakeUpdateWhere builds a new UpdateQuery to set the columns in set on all
+// rows of model matching filter. Primary and generated columns cannot be set.
+func (s *Schema) MakeUpdateWhere(model interface{}, set map[string]interface{}, filter Filter) (*UpdateQuery, error) {
+	table, where, err := s.makeMutateWhere(model, filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(set) == 0 {
+		return nil, errors.New("bulk update should set at least one column")
+	}
+
+	var l whereElemsByIndex
+	for name, value := range set {
+		column, ok := table.ColumnsByName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown column %s", name)
+		}
+		if column.Primary || column.Generated {
+			return nil, fmt.Errorf("cannot set primary or generated column %s", name)
+		}
+
+		v, err := column.Descriptor.Valuer(reflect.ValueOf(value)).Value()
+		if err == nil {
+			v, err = column.encrypt(v)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("sqlgen: set error for `%s`.`%s`: %v", table.Name, column.Name, err)
+		}
+		l = append(l, whereElem{sql: column.Name, order: column.Order, value: v})
+	}
+	sort.Sort(l)
+
+	var columns []string
+	var values []interface{}
+	for _, elem := range l {
+		columns = append(columns, elem.sql)
+		values = append(values, elem.value)
+	}
+
+	return &UpdateQuery{
+		Table:   table.Name,
+		Columns: columns,
+		Values:  values,
+		Where:   where,
+	}, nil
+}
+
+// MakeDeleteWhere builds a new DeleteQuery to delete all rows of model
+// matching filter.
+func (s *Schema) MakeDeleteWhere(model interface{}, filter Filter) (*DeleteQuery, error) {
+	table, where, err := s.makeMutateWhere(model, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DeleteQuery{
+		Table: table.Name,
+		Where: where,
+	}, nil
+}
+
 type Tester interface {
 	Test(row interface{}) bool
 }
@@ -683,6 +928,9 @@ type Tester interface {
 type tester struct {
 	columns []*Column
 	values  []interface{}
+
+	expressions      []*Expression
+	expressionValues []interface{}
 }
 
 // coerce coerces some types for more idiomatic comparisons
@@ -732,6 +980,12 @@ func (t *tester) Test(row interface{}) bool {
 		}
 	}
 
+	for i, expression := range t.expressions {
+		if !driverValuesEqual(coerce(reflect.ValueOf(t.expressionValues[i])), coerce(reflect.ValueOf(expression.Eval(row)))) {
+			return false
+		}
+	}
+
 	return true
 }
 
@@ -741,22 +995,33 @@ func (s *Schema) MakeTester(table string, filter Filter) (Tester, error) {
 		return nil, errors.New("unknown table")
 	}
 
-	columns := []*Column{}
-	values := []interface{}{}
+	tester := &tester{
+		columns: []*Column{},
+		values:  []interface{}{},
+	}
 
 	for name, value := range filter {
+		if expression, ok := t.Expressions[name]; ok {
+			if expression.Eval == nil {
+				return nil, fmt.Errorf("expression %s cannot be tested without Eval", name)
+			}
+			tester.expressions = append(tester.expressions, expression)
+			tester.expressionValues = append(tester.expressionValues, value)
+			continue
+		}
+
 		column, ok := t.ColumnsByName[name]
 		if !ok {
 			return nil, fmt.Errorf("unknown column %s", name)
 		}
-		columns = append(columns, column)
-		values = append(values, value)
+		if column.Encrypted {
+			return nil, fmt.Errorf("cannot filter on encrypted column %s", name)
+		}
+		tester.columns = append(tester.columns, column)
+		tester.values = append(tester.values, value)
 	}
 
-	return &tester{
-		columns: columns,
-		values:  values,
-	}, nil
+	return tester, nil
 }
 
 func (t *Table) extractRow(row interface{}) Filter {
@@ -838,9 +1103,13 @@ func (s *Schema) BuildStruct(tableName string, row []driver.Value) (interface{},
 		if field.Kind() != reflect.Ptr {
 			field = field.Addr()
 		}
+		value, err := table.Columns[i].Decrypt(row[i])
+		if err != nil {
+			return nil, fmt.Errorf("`%s`.`%s` error: %v", table.Name, table.Columns[i].Name, err)
+		}
 		scanner := scanners[i].(*fields.Scanner)
 		scanner.Target(field)
-		if err := scanner.Scan(row[i]); err != nil {
+		if err := scanner.Scan(value); err != nil {
 			return nil, fmt.Errorf("`%s`.`%s` error: %v", table.Name, table.Columns[i].Name, err)
 		}
 	}
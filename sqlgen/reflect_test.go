@@ -316,6 +316,34 @@ func TestSelectOptions(t *testing.T) {
 	}
 }
 
+func TestSelectOptionsPushdown(t *testing.T) {
+	s := NewSchema()
+	if err := s.RegisterType("users", AutoIncrement, user{}); err != nil {
+		t.Fatal(err)
+	}
+	table := s.ByName["users"]
+
+	options := &SelectOptions{Where: "age > ?", Values: []interface{}{18}}
+	require.NoError(t, options.IncludeTextFilter(table, []string{"name"}, `bob "50%_off"`))
+	require.NoError(t, options.IncludeOrderBy(table, "age", true))
+	require.NoError(t, options.IncludeOrderBy(table, "name", false))
+	assert.Equal(t, &SelectOptions{
+		Where:   "(name LIKE ? OR name LIKE ?) AND (age > ?)",
+		Values:  []interface{}{"%bob%", `%50\%\_off%`, 18},
+		OrderBy: "age DESC, name",
+	}, options)
+
+	options = &SelectOptions{}
+	require.NoError(t, options.IncludeTextFilter(table, []string{"name"}, ""))
+	assert.Equal(t, &SelectOptions{}, options)
+
+	require.NoError(t, options.IncludeTextFilter(table, []string{"name"}, `""`))
+	assert.Equal(t, &SelectOptions{Where: "1=0"}, options)
+
+	assert.EqualError(t, options.IncludeTextFilter(table, []string{"bogus"}, "bob"), "unknown column bogus")
+	assert.EqualError(t, options.IncludeOrderBy(table, "bogus", false), "unknown column bogus")
+}
+
 func TestMakeSelectRow(t *testing.T) {
 	s := NewSchema()
 	if err := s.RegisterType("users", AutoIncrement, user{}); err != nil {
@@ -603,3 +631,57 @@ func TestBuildStruct(t *testing.T) {
 		Uuid: testfixtures.CustomTypeFromString("bar"),
 	}, u)
 }
+
+func TestGeneratedColumnsAndExpressions(t *testing.T) {
+	type account struct {
+		Id         int64 `sql:",primary"`
+		Email      string
+		EmailLower string `sql:",generated"`
+	}
+
+	s := NewSchema()
+	s.MustRegisterType("accounts", UniqueId, account{})
+	s.MustRegisterExpression("accounts", &Expression{
+		Name: "email_domain",
+		SQL:  "SUBSTRING_INDEX(email, '@', -1)",
+		Eval: func(row interface{}) interface{} {
+			email := row.(*account).Email
+			return email[strings.Index(email, "@")+1:]
+		},
+	})
+	s.MustRegisterExpression("accounts", &Expression{Name: "email_length", SQL: "LENGTH(email)"})
+
+	// Generated columns are read and filtered on, but never written.
+	query, err := s.MakeInsertRow(&account{Id: 1, Email: "Bob@Example.com", EmailLower: "ignored"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id", "email"}, query.Columns)
+
+	upsert, err := s.MakeUpsertRow(&account{Id: 1, Email: "Bob@Example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id", "email"}, upsert.Columns)
+	assert.Len(t, upsert.Values, 2)
+
+	update, err := s.MakeUpdateRow(&account{Id: 1, Email: "Bob@Example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"email"}, update.Columns)
+
+	sel, err := s.MakeSelect(new([]*account), Filter{"email_domain": "example.com", "email_lower": "bob@example.com"}, nil)
+	require.NoError(t, err)
+	selectQuery, err := sel.MakeSelectQuery()
+	require.NoError(t, err)
+	clause, args := selectQuery.ToSQL()
+	assert.Equal(t, "SELECT id, email, email_lower FROM accounts WHERE email_lower = ? AND SUBSTRING_INDEX(email, '@', -1) = ?", clause)
+	assert.Equal(t, []interface{}{"bob@example.com", "example.com"}, args)
+
+	tester, err := s.MakeTester("accounts", Filter{"email_domain": "example.com"})
+	require.NoError(t, err)
+	assert.True(t, tester.Test(&account{Email: "bob@example.com"}))
+	assert.False(t, tester.Test(&account{Email: "bob@example.org"}))
+
+	_, err = s.MakeTester("accounts", Filter{"email_length": int64(3)})
+	assert.Error(t, err)
+
+	assert.Error(t, s.RegisterExpression("accounts", &Expression{Name: "email", SQL: "email"}))
+	assert.Error(t, s.RegisterExpression("accounts", &Expression{Name: "email_length", SQL: "LENGTH(email)"}))
+	assert.Error(t, s.RegisterExpression("unknown", &Expression{Name: "x", SQL: "x"}))
+}
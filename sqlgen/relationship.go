@@ -0,0 +1,318 @@
+package sqlgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// RelationshipKind is the kind of a Relationship.
+type RelationshipKind int
+
+const (
+	// HasOne relates a row to the single row of another table whose foreign
+	// key references it, such as a user's settings.
+	HasOne RelationshipKind = iota
+	// HasMany relates a row to the rows of another table whose foreign key
+	// references it, such as a user's posts.
+	HasMany
+	// BelongsTo relates a row to the row of another table its foreign key
+	// references, such as a post's author.
+	BelongsTo
+)
+
+func (k RelationshipKind) String() string {
+	switch k {
+	case HasOne:
+		return "has-one"
+	case HasMany:
+		return "has-many"
+	case BelongsTo:
+		return "belongs-to"
+	default:
+		return fmt.Sprintf("RelationshipKind(%d)", int(k))
+	}
+}
+
+// A Relationship relates the rows of a registered table to the rows of
+// another table, see Schema.RegisterRelationship.
+type Relationship struct {
+	// Name identifies the relationship on its table.
+	Name string
+	Kind RelationshipKind
+	// To is the name of the related table.
+	To string
+	// ForeignKey is the column holding the reference. It is a column of the
+	// related table for HasOne and HasMany relationships, and of the
+	// relationship's own table for BelongsTo relationships.
+	ForeignKey string
+	// References is the column the foreign key references, on the other side
+	// of the relationship. It defaults to the primary key.
+	References string
+
+	// from and to are the tables of the relationship, and parentColumn and
+	// childColumn the columns matching their rows.
+	from, to                  *Table
+	parentColumn, childColumn *Column
+}
+
+// RegisterRelationship registers a relationship on a registered table, so
+// related rows can be loaded with DB.Load and DB.LoadAll.
+//
+// For example, after registering
+//
+//	schema.RegisterRelationship("users", &sqlgen.Relationship{
+//		Name:       "posts",
+//		Kind:       sqlgen.HasMany,
+//		To:         "posts",
+//		ForeignKey: "author_id",
+//	})
+//
+// the posts of users are the rows of the posts table WHERE author_id IN the
+// ids of the users.
+func (s *Schema) RegisterRelationship(table string, relationship *Relationship) error {
+	from, ok := s.ByName[table]
+	if !ok {
+		return fmt.Errorf("unknown table %s", table)
+	}
+	to, ok := s.ByName[relationship.To]
+	if !ok {
+		return fmt.Errorf("relationship %s on table %s: unknown table %s", relationship.Name, table, relationship.To)
+	}
+	if relationship.Name == "" || relationship.ForeignKey == "" {
+		return fmt.Errorf("relationship on table %s needs a name and foreign key", table)
+	}
+	if _, ok := from.Relationships[relationship.Name]; ok {
+		return fmt.Errorf("relationship %s on table %s registered twice", relationship.Name, table)
+	}
+
+	// The foreign key of has-one and has-many relationships is on the
+	// related table, and references the relationship's own table.
+	keyTable, referencedTable := to, from
+	switch relationship.Kind {
+	case HasOne, HasMany:
+	case BelongsTo:
+		keyTable, referencedTable = from, to
+	default:
+		return fmt.Errorf("relationship %s on table %s has unknown kind %v", relationship.Name, table, relationship.Kind)
+	}
+
+	foreignKey, ok := keyTable.ColumnsByName[relationship.ForeignKey]
+	if !ok {
+		return fmt.Errorf("relationship %s on table %s: unknown column %s on table %s", relationship.Name, table, relationship.ForeignKey, keyTable.Name)
+	}
+	var references *Column
+	if relationship.References == "" {
+		for _, column := range referencedTable.Columns {
+			if column.Primary {
+				if references != nil {
+					return fmt.Errorf("relationship %s on table %s: table %s has a composite primary key, specify References", relationship.Name, table, referencedTable.Name)
+				}
+				references = column
+			}
+		}
+	} else if references, ok = referencedTable.ColumnsByName[relationship.References]; !ok {
+		return fmt.Errorf("relationship %s on table %s: unknown column %s on table %s", relationship.Name, table, relationship.References, referencedTable.Name)
+	}
+	if foreignKey.Encrypted || references.Encrypted {
+		return fmt.Errorf("relationship %s on table %s: encrypted columns cannot be related", relationship.Name, table)
+	}
+
+	registered := *relationship
+	registered.References = references.Name
+	registered.from, registered.to = from, to
+	if relationship.Kind == BelongsTo {
+		registered.parentColumn, registered.childColumn = foreignKey, references
+	} else {
+		registered.parentColumn, registered.childColumn = references, foreignKey
+	}
+	from.Relationships[relationship.Name] = &registered
+	return nil
+}
+
+// MustRegisterRelationship calls RegisterRelationship and panics on error.
+func (s *Schema) MustRegisterRelationship(table string, relationship *Relationship) {
+	if err := s.RegisterRelationship(table, relationship); err != nil {
+		panic(err)
+	}
+}
+
+// relationship returns the relationship named name on the table of rows of
+// type typ, a pointer to a struct.
+func (s *Schema) relationship(typ reflect.Type, name string) (*Relationship, error) {
+	if typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("expected pointer to struct, got %v", typ)
+	}
+	table, err := s.get(typ.Elem())
+	if err != nil {
+		return nil, err
+	}
+	relationship, ok := table.Relationships[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown relationship %s on table %s", name, table.Name)
+	}
+	return relationship, nil
+}
+
+// parentKey returns the value of row matched against the related rows, or nil
+// if it is NULL.
+func (r *Relationship) parentKey(row reflect.Value) interface{} {
+	return coerce(row.Elem().FieldByIndex(r.parentColumn.Index))
+}
+
+// relationKey returns a comparable key for the column of row, so the rows
+// of both sides of a relationship can be matched in a map.
+func relationKey(column *Column, row reflect.Value) (interface{}, error) {
+	value, err := column.Descriptor.Valuer(row.Elem().FieldByIndex(column.Index)).Value()
+	if err != nil {
+		return nil, err
+	}
+	if bytes, ok := value.([]byte); ok {
+		return string(bytes), nil
+	}
+	return value, nil
+}
+
+var errBadLoadResultType = errors.New("load result should be a pointer to a slice of pointers to structs for has-many relationships, and a pointer to a pointer to a struct otherwise")
+
+// Load loads the rows related to parent, a pointer to a struct, into result.
+// result should be a pointer to a slice of pointers to structs for HasMany
+// relationships, and a pointer to a pointer to a struct otherwise, which is
+// set to nil if there is no related row. For example:
+//
+//	var posts []*Post
+//	if err := db.Load(ctx, user, "posts", &posts); err != nil {
+//
+// Load runs a filtered query, so concurrent calls with a batching context
+// (see batch.WithBatching), such as from GraphQL resolvers, are combined
+// into a single query instead of querying once per parent.
+func (db *DB) Load(ctx context.Context, parent interface{}, relationship string, result interface{}) error {
+	rel, err := db.Schema.relationship(reflect.TypeOf(parent), relationship)
+	if err != nil {
+		return err
+	}
+
+	ptr := reflect.ValueOf(result)
+	elemType := reflect.PtrTo(rel.to.Type)
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() {
+		return errBadLoadResultType
+	}
+	if rel.Kind == HasMany {
+		if ptr.Elem().Type() != reflect.SliceOf(elemType) {
+			return errBadLoadResultType
+		}
+	} else if ptr.Elem().Type() != elemType {
+		return errBadLoadResultType
+	}
+
+	var rows []interface{}
+	if key := rel.parentKey(reflect.ValueOf(parent)); key != nil {
+		query, err := db.Schema.makeSelect(rel.to.Type, Filter{rel.childColumn.Name: key}, nil)
+		if err != nil {
+			return err
+		}
+		if rows, err = db.BaseQuery(ctx, query); err != nil {
+			return err
+		}
+	}
+
+	if rel.Kind == HasMany {
+		return CopySlice(result, rows)
+	}
+	switch len(rows) {
+	case 0:
+		ptr.Elem().Set(reflect.Zero(elemType))
+	case 1:
+		ptr.Elem().Set(reflect.ValueOf(rows[0]))
+	default:
+		return fmt.Errorf("relationship %s on table %s: expected no more than 1 related row, got %d", rel.Name, rel.from.Name, len(rows))
+	}
+	return nil
+}
+
+// LoadAll loads the rows related to each of parents, a slice of pointers to
+// structs, with a single query. It returns the related rows of every parent,
+// in the order of parents:
+//
+//	posts, err := db.LoadAll(ctx, users, "posts")
+//	...
+//	for i, user := range users {
+//		for _, post := range posts[i] {
+//			post.(*Post) ...
+//
+// The rows of HasOne and BelongsTo relationships are returned as lists of at
+// most one row.
+func (db *DB) LoadAll(ctx context.Context, parents interface{}, relationship string) ([][]interface{}, error) {
+	slice := reflect.ValueOf(parents)
+	if slice.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("expected slice of pointers to structs, got %T", parents)
+	}
+	rel, err := db.Schema.relationship(slice.Type().Elem(), relationship)
+	if err != nil {
+		return nil, err
+	}
+
+	// Query the distinct keys of parents at once.
+	seen := make(map[interface{}]bool)
+	var filters []Filter
+	for i := 0; i < slice.Len(); i++ {
+		parent := slice.Index(i)
+		if parent.IsNil() {
+			continue
+		}
+		key := rel.parentKey(parent)
+		if key == nil {
+			continue
+		}
+		mapKey, err := relationKey(rel.parentColumn, parent)
+		if err != nil {
+			return nil, err
+		}
+		if seen[mapKey] {
+			continue
+		}
+		seen[mapKey] = true
+		filters = append(filters, Filter{rel.childColumn.Name: key})
+	}
+
+	related := make([][]interface{}, slice.Len())
+	if len(filters) == 0 {
+		return related, nil
+	}
+
+	clause, args := makeBatchQuery(filters)
+	query, err := db.Schema.makeSelect(rel.to.Type, nil, &SelectOptions{Where: clause, Values: args})
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.BaseQuery(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	byKey := make(map[interface{}][]interface{})
+	for _, row := range rows {
+		key, err := relationKey(rel.childColumn, reflect.ValueOf(row))
+		if err != nil {
+			return nil, err
+		}
+		byKey[key] = append(byKey[key], row)
+	}
+
+	for i := range related {
+		parent := slice.Index(i)
+		if parent.IsNil() || rel.parentKey(parent) == nil {
+			continue
+		}
+		key, err := relationKey(rel.parentColumn, parent)
+		if err != nil {
+			return nil, err
+		}
+		related[i] = byKey[key]
+		if rel.Kind != HasMany && len(related[i]) > 1 {
+			return nil, fmt.Errorf("relationship %s on table %s: expected no more than 1 related row, got %d", rel.Name, rel.from.Name, len(related[i]))
+		}
+	}
+	return related, nil
+}
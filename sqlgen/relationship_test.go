@@ -0,0 +1,167 @@
+package sqlgen
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/batch"
+	"github.com/denkhaus/thunder/internal/testfixtures"
+)
+
+type relAuthor struct {
+	Id   int64 `sql:",primary"`
+	Name string
+}
+
+type relPost struct {
+	Id       int64 `sql:",primary"`
+	AuthorId *int64
+	Title    string
+}
+
+type relProfile struct {
+	Id       int64 `sql:",primary"`
+	AuthorId int64
+	Bio      string
+}
+
+func relationshipSchema() *Schema {
+	s := NewSchema()
+	s.MustRegisterType("authors", AutoIncrement, relAuthor{})
+	s.MustRegisterType("posts", AutoIncrement, relPost{})
+	s.MustRegisterType("profiles", AutoIncrement, relProfile{})
+	s.MustRegisterRelationship("authors", &Relationship{Name: "posts", Kind: HasMany, To: "posts", ForeignKey: "author_id"})
+	s.MustRegisterRelationship("authors", &Relationship{Name: "profile", Kind: HasOne, To: "profiles", ForeignKey: "author_id"})
+	s.MustRegisterRelationship("posts", &Relationship{Name: "author", Kind: BelongsTo, To: "authors", ForeignKey: "author_id"})
+	return s
+}
+
+func TestRegisterRelationship(t *testing.T) {
+	s := relationshipSchema()
+
+	posts := s.ByName["authors"].Relationships["posts"]
+	assert.Equal(t, "id", posts.References)
+	assert.Equal(t, "id", posts.parentColumn.Name)
+	assert.Equal(t, "author_id", posts.childColumn.Name)
+
+	belongsTo := s.ByName["posts"].Relationships["author"]
+	assert.Equal(t, "id", belongsTo.References)
+	assert.Equal(t, "author_id", belongsTo.parentColumn.Name)
+	assert.Equal(t, "id", belongsTo.childColumn.Name)
+
+	assert.Error(t, s.RegisterRelationship("unknown", &Relationship{Name: "x", To: "posts", ForeignKey: "author_id"}))
+	assert.Error(t, s.RegisterRelationship("authors", &Relationship{Name: "x", To: "unknown", ForeignKey: "author_id"}))
+	assert.Error(t, s.RegisterRelationship("authors", &Relationship{Name: "x", To: "posts"}))
+	assert.Error(t, s.RegisterRelationship("authors", &Relationship{Name: "posts", To: "posts", ForeignKey: "author_id"}))
+	// The foreign key of a has-many relationship is on the related table.
+	assert.Error(t, s.RegisterRelationship("posts", &Relationship{Name: "x", Kind: HasMany, To: "authors", ForeignKey: "author_id"}))
+	assert.Error(t, s.RegisterRelationship("posts", &Relationship{Name: "x", Kind: BelongsTo, To: "authors", ForeignKey: "author_id", References: "unknown"}))
+	assert.Error(t, s.RegisterRelationship("posts", &Relationship{Name: "x", Kind: RelationshipKind(7), To: "authors", ForeignKey: "author_id"}))
+
+	db := NewDB(nil, s)
+	ctx := context.Background()
+	var result []*relPost
+	assert.Error(t, db.Load(ctx, &relAuthor{Id: 1}, "unknown", &result))
+	// Has-one relationships load into a pointer, not a slice.
+	assert.Error(t, db.Load(ctx, &relAuthor{Id: 1}, "profile", &result))
+	_, err := db.LoadAll(ctx, []*relAuthor{{Id: 1}}, "unknown")
+	assert.Error(t, err)
+	_, err = db.LoadAll(ctx, &relAuthor{Id: 1}, "posts")
+	assert.Error(t, err)
+
+	// Parents without keys need no query.
+	var author *relAuthor
+	require.NoError(t, db.Load(ctx, &relPost{Id: 1}, "author", &author))
+	assert.Nil(t, author)
+	related, err := db.LoadAll(ctx, []*relPost{{Id: 1}, nil}, "author")
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{nil, nil}, related)
+}
+
+func TestLoadRelationships(t *testing.T) {
+	tdb, err := testfixtures.NewTestDatabase()
+	require.NoError(t, err)
+	defer tdb.Close()
+
+	for _, query := range []string{
+		`CREATE TABLE authors (id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY, name VARCHAR(255))`,
+		`CREATE TABLE posts (id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY, author_id BIGINT, title VARCHAR(255))`,
+		`CREATE TABLE profiles (id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY, author_id BIGINT, bio VARCHAR(255))`,
+	} {
+		_, err := tdb.Exec(query)
+		require.NoError(t, err)
+	}
+
+	db := NewDB(tdb.DB, relationshipSchema())
+	ctx := context.Background()
+
+	alice, bob, carol := &relAuthor{Name: "alice"}, &relAuthor{Name: "bob"}, &relAuthor{Name: "carol"}
+	for _, author := range []*relAuthor{alice, bob, carol} {
+		res, err := db.InsertRow(ctx, author)
+		require.NoError(t, err)
+		author.Id, err = res.LastInsertId()
+		require.NoError(t, err)
+	}
+	for _, row := range []interface{}{
+		&relPost{AuthorId: &alice.Id, Title: "first"},
+		&relPost{AuthorId: &alice.Id, Title: "second"},
+		&relPost{AuthorId: &bob.Id, Title: "third"},
+		&relPost{Title: "anonymous"},
+		&relProfile{AuthorId: bob.Id, Bio: "bob's bio"},
+	} {
+		_, err := db.InsertRow(ctx, row)
+		require.NoError(t, err)
+	}
+
+	posts, err := db.LoadAll(ctx, []*relAuthor{alice, bob, carol, alice}, "posts")
+	require.NoError(t, err)
+	require.Len(t, posts, 4)
+	assert.Len(t, posts[0], 2)
+	assert.Len(t, posts[1], 1)
+	assert.Equal(t, "third", posts[1][0].(*relPost).Title)
+	assert.Len(t, posts[2], 0)
+	assert.Equal(t, posts[0], posts[3])
+
+	profiles, err := db.LoadAll(ctx, []*relAuthor{alice, bob}, "profile")
+	require.NoError(t, err)
+	assert.Len(t, profiles[0], 0)
+	assert.Equal(t, "bob's bio", profiles[1][0].(*relProfile).Bio)
+
+	var all []*relPost
+	require.NoError(t, db.Query(ctx, &all, nil, nil))
+	authors, err := db.LoadAll(ctx, all, "author")
+	require.NoError(t, err)
+	for i, post := range all {
+		if post.AuthorId == nil {
+			assert.Len(t, authors[i], 0)
+			continue
+		}
+		require.Len(t, authors[i], 1)
+		assert.Equal(t, *post.AuthorId, authors[i][0].(*relAuthor).Id)
+	}
+
+	// Concurrent loads of single parents are batched into one query.
+	ctx = batch.WithBatching(ctx)
+	results := make([][]*relPost, 3)
+	done := make(chan error, 3)
+	for i, author := range []*relAuthor{alice, bob, carol} {
+		go func(i int, author *relAuthor) {
+			done <- db.Load(ctx, author, "posts", &results[i])
+		}(i, author)
+	}
+	for i := 0; i < 3; i++ {
+		require.NoError(t, <-done)
+	}
+	assert.Len(t, results[0], 2)
+	assert.Len(t, results[1], 1)
+	assert.Len(t, results[2], 0)
+
+	var profile *relProfile
+	require.NoError(t, db.Load(ctx, bob, "profile", &profile))
+	assert.Equal(t, "bob's bio", profile.Bio)
+	require.NoError(t, db.Load(ctx, alice, "profile", &profile))
+	assert.Nil(t, profile)
+}
@@ -0,0 +1,112 @@
+package sqlgen
+
+// The functions below render the SQL statements and arguments that the
+// corresponding DB methods would execute, without executing them, so tests can
+// assert generated SQL and filters can be debugged.
+//
+// They do not check the DB's shard and dynamic limits.
+
+// RenderQuery renders the statement DB.Query runs for result, filter, and
+// options.
+func (s *Schema) RenderQuery(result interface{}, filter Filter, options *SelectOptions) (string, []interface{}, error) {
+	query, err := s.MakeSelect(result, filter, options)
+	if err != nil {
+		return "", nil, err
+	}
+	return renderSelect(query)
+}
+
+// RenderQueryRow renders the statement DB.QueryRow runs for result, filter,
+// and options.
+func (s *Schema) RenderQueryRow(result interface{}, filter Filter, options *SelectOptions) (string, []interface{}, error) {
+	query, err := s.MakeSelectRow(result, filter, options)
+	if err != nil {
+		return "", nil, err
+	}
+	return renderSelect(query)
+}
+
+func renderSelect(query *BaseSelectQuery) (string, []interface{}, error) {
+	selectQuery, err := query.MakeSelectQuery()
+	if err != nil {
+		return "", nil, err
+	}
+	clause, args := selectQuery.ToSQL()
+	return clause, args, nil
+}
+
+// RenderCount renders the statement DB.Count runs for model and filter.
+func (s *Schema) RenderCount(model interface{}, filter Filter) (string, []interface{}, error) {
+	query, err := s.makeCount(model, filter)
+	if err != nil {
+		return "", nil, err
+	}
+	countQuery, err := query.makeCountQuery()
+	if err != nil {
+		return "", nil, err
+	}
+	clause, args := countQuery.ToSQL()
+	return clause, args, nil
+}
+
+// RenderInsertRow renders the statement DB.InsertRow runs for row.
+func (s *Schema) RenderInsertRow(row interface{}) (string, []interface{}, error) {
+	query, err := s.MakeInsertRow(row)
+	if err != nil {
+		return "", nil, err
+	}
+	clause, args := query.ToSQL()
+	return clause, args, nil
+}
+
+// RenderUpsertRow renders the statement DB.UpsertRow runs for row.
+func (s *Schema) RenderUpsertRow(row interface{}) (string, []interface{}, error) {
+	query, err := s.MakeUpsertRow(row)
+	if err != nil {
+		return "", nil, err
+	}
+	clause, args := query.ToSQL()
+	return clause, args, nil
+}
+
+// RenderUpdateRow renders the statement DB.UpdateRow runs for row.
+func (s *Schema) RenderUpdateRow(row interface{}) (string, []interface{}, error) {
+	query, err := s.MakeUpdateRow(row)
+	if err != nil {
+		return "", nil, err
+	}
+	clause, args := query.ToSQL()
+	return clause, args, nil
+}
+
+// RenderDeleteRow renders the statement DB.DeleteRow runs for row.
+func (s *Schema) RenderDeleteRow(row interface{}) (string, []interface{}, error) {
+	query, err := s.MakeDeleteRow(row)
+	if err != nil {
+		return "", nil, err
+	}
+	clause, args := query.ToSQL()
+	return clause, args, nil
+}
+
+// RenderUpdateWhere renders the statement DB.UpdateWhere runs for model, set,
+// and filter.
+func (s *Schema) RenderUpdateWhere(model interface{}, set map[string]interface{}, filter Filter) (string, []interface{}, error) {
+	query, err := s.MakeUpdateWhere(model, set, filter)
+	if err != nil {
+		return "", nil, err
+	}
+	clause, args := query.ToSQL()
+	return clause, args, nil
+}
+
+// RenderDeleteWhere renders the statement DB.DeleteWhere runs for model and
+// filter.
+func (s *Schema) RenderDeleteWhere(model interface{}, filter Filter) (string, []interface{}, error) {
+	query, err := s.MakeDeleteWhere(model, filter)
+	if err != nil {
+		return "", nil, err
+	}
+	clause, args := query.ToSQL()
+	return clause, args, nil
+}
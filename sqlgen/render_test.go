@@ -0,0 +1,80 @@
+package sqlgen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type renderUser struct {
+	Id   int64 `sql:",primary"`
+	Name string
+	Age  int64
+}
+
+func TestRender(t *testing.T) {
+	s := NewSchema()
+	require.NoError(t, s.RegisterType("users", AutoIncrement, renderUser{}))
+
+	var users []*renderUser
+	clause, args, err := s.RenderQuery(&users, Filter{"name": "bob"}, &SelectOptions{
+		Where:   "age > ?",
+		Values:  []interface{}{int64(18)},
+		OrderBy: "id",
+		Limit:   10,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT id, name, age FROM users WHERE (name = ?) AND (age > ?) ORDER BY id LIMIT 10", clause)
+	assert.Equal(t, []interface{}{"bob", int64(18)}, args)
+
+	var user *renderUser
+	clause, args, err = s.RenderQueryRow(&user, Filter{"id": int64(1)}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT id, name, age FROM users WHERE id = ?", clause)
+	assert.Equal(t, []interface{}{int64(1)}, args)
+
+	clause, args, err = s.RenderCount(&renderUser{}, Filter{"age": int64(18)})
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT COUNT(*) FROM users WHERE age = ?", clause)
+	assert.Equal(t, []interface{}{int64(18)}, args)
+
+	row := &renderUser{Id: 1, Name: "bob", Age: 20}
+	clause, args, err = s.RenderInsertRow(row)
+	require.NoError(t, err)
+	assert.Equal(t, "INSERT INTO users (name, age) VALUES (?, ?)", clause)
+	assert.Len(t, args, 2)
+
+	clause, args, err = s.RenderUpdateRow(row)
+	require.NoError(t, err)
+	assert.Equal(t, "UPDATE users SET name = ?, age = ? WHERE id = ?", clause)
+	assert.Len(t, args, 3)
+
+	clause, args, err = s.RenderDeleteRow(row)
+	require.NoError(t, err)
+	assert.Equal(t, "DELETE FROM users WHERE id = ?", clause)
+	assert.Len(t, args, 1)
+
+	clause, args, err = s.RenderUpdateWhere(&renderUser{}, map[string]interface{}{"age": int64(21), "name": "alice"}, Filter{"name": "bob"})
+	require.NoError(t, err)
+	assert.Equal(t, "UPDATE users SET name = ?, age = ? WHERE name = ?", clause)
+	assert.Equal(t, []interface{}{"alice", int64(21), "bob"}, args)
+
+	clause, args, err = s.RenderDeleteWhere(&renderUser{}, Filter{"age": int64(18)})
+	require.NoError(t, err)
+	assert.Equal(t, "DELETE FROM users WHERE age = ?", clause)
+	assert.Equal(t, []interface{}{int64(18)}, args)
+
+	_, _, err = s.RenderUpdateWhere(&renderUser{}, map[string]interface{}{"id": int64(2)}, Filter{"name": "bob"})
+	assert.EqualError(t, err, "cannot set primary or generated column id")
+	_, _, err = s.RenderUpdateWhere(&renderUser{}, map[string]interface{}{"age": int64(2)}, nil)
+	assert.EqualError(t, err, "filter of a bulk update or delete should not be empty")
+	_, _, err = s.RenderDeleteWhere(&renderUser{}, Filter{})
+	assert.EqualError(t, err, "filter of a bulk update or delete should not be empty")
+
+	_, _, err = s.RenderUpsertRow(row)
+	assert.EqualError(t, err, "upsert only supports unique value primary keys")
+
+	_, _, err = s.RenderQuery(&users, Filter{"bogus": 1}, nil)
+	assert.Error(t, err)
+}
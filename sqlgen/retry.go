@@ -0,0 +1,130 @@
+package sqlgen
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// MySQL error numbers of transient errors, see IsTransientError.
+const (
+	mysqlErrLockWaitTimeout = 1205
+	mysqlErrDeadlock        = 1213
+)
+
+// RetryPolicy retries queries that fail with a transient error, see
+// IsTransientError and WithRetries.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times a query is run, including
+	// the first attempt. Values below 2 disable retries.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry. The delay doubles for
+	// every following retry, up to MaxDelay if it is set. Delays are
+	// jittered down by up to half, so concurrent retries spread out.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// DefaultRetryPolicy runs queries up to three times, waiting around 10ms and
+// 20ms between attempts.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   10 * time.Millisecond,
+	MaxDelay:    100 * time.Millisecond,
+}
+
+type retryPolicyKey struct{}
+
+// WithRetries makes queries run with ctx retry transient errors according to
+// policy. Without it, queries are not retried.
+//
+// Queries in a transaction are never retried on their own, as MySQL rolls
+// back the whole transaction on deadlocks; use RunInTx to retry transactions.
+// Writes are retried as well, so writes that failed with a bad connection
+// may have been applied before being retried.
+func WithRetries(ctx context.Context, policy RetryPolicy) context.Context {
+	return context.WithValue(ctx, retryPolicyKey{}, policy)
+}
+
+// IsTransientError reports whether err is a MySQL deadlock or lock wait
+// timeout, or a bad connection, after which running the query again may
+// succeed.
+func IsTransientError(err error) bool {
+	for err != nil {
+		switch e := err.(type) {
+		case *mysql.MySQLError:
+			return e.Number == mysqlErrDeadlock || e.Number == mysqlErrLockWaitTimeout
+		case *ErrorWithQuery:
+			err = e.Unwrap()
+			continue
+		}
+		return err == driver.ErrBadConn || err == mysql.ErrInvalidConn
+	}
+	return false
+}
+
+// delay returns the jittered delay before retry number retry, starting at 1.
+func (p RetryPolicy) delay(retry int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < retry && (p.MaxDelay <= 0 || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// retry calls f until it does not fail with a transient error, following the
+// retry policy of ctx. Queries in a transaction are not retried.
+func (db *DB) retry(ctx context.Context, f func() error) error {
+	policy, ok := ctx.Value(retryPolicyKey{}).(RetryPolicy)
+	if !ok || db.HasTx(ctx) {
+		return f()
+	}
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil || attempt >= policy.MaxAttempts || !IsTransientError(err) {
+			return err
+		}
+
+		timer := time.NewTimer(policy.delay(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// RunInTx runs f in a transaction, and commits it if f succeeds. Queries run
+// with the context passed to f use the transaction. If ctx has a retry
+// policy, see WithRetries, the whole transaction is retried when it fails with
+// a transient error, so f must be safe to call again.
+//
+// It is an error to call RunInTx with a Context that already contains a
+// transaction for this DB.
+func (db *DB) RunInTx(ctx context.Context, f func(ctx context.Context) error) error {
+	if db.HasTx(ctx) {
+		return errors.New("already in a tx")
+	}
+	return db.retry(ctx, func() error {
+		txCtx, tx, err := db.WithTx(ctx)
+		if err != nil {
+			return err
+		}
+		if err := f(txCtx); err != nil {
+			tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	})
+}
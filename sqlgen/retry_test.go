@@ -0,0 +1,77 @@
+package sqlgen
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsTransientError(t *testing.T) {
+	assert.True(t, IsTransientError(&mysql.MySQLError{Number: 1213, Message: "Deadlock found"}))
+	assert.True(t, IsTransientError(&mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"}))
+	assert.True(t, IsTransientError(driver.ErrBadConn))
+	assert.True(t, IsTransientError(mysql.ErrInvalidConn))
+	assert.True(t, IsTransientError(&ErrorWithQuery{err: &mysql.MySQLError{Number: 1213}}))
+
+	assert.False(t, IsTransientError(nil))
+	assert.False(t, IsTransientError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}))
+	assert.False(t, IsTransientError(errors.New("deadlock")))
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: 10 * time.Millisecond, MaxDelay: 30 * time.Millisecond}
+	for i := 0; i < 100; i++ {
+		delay := policy.delay(1)
+		assert.True(t, delay >= 5*time.Millisecond && delay <= 10*time.Millisecond, "delay %v", delay)
+		delay = policy.delay(2)
+		assert.True(t, delay >= 10*time.Millisecond && delay <= 20*time.Millisecond, "delay %v", delay)
+		delay = policy.delay(4)
+		assert.True(t, delay >= 15*time.Millisecond && delay <= 30*time.Millisecond, "delay %v", delay)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	db := &DB{}
+	deadlock := &mysql.MySQLError{Number: 1213, Message: "Deadlock found"}
+	failing := func(errs ...error) (func() error, *int) {
+		calls := 0
+		return func() error {
+			calls++
+			if calls <= len(errs) {
+				return errs[calls-1]
+			}
+			return nil
+		}, &calls
+	}
+
+	// Without a policy, queries are not retried.
+	f, calls := failing(deadlock)
+	assert.Equal(t, deadlock, db.retry(context.Background(), f))
+	assert.Equal(t, 1, *calls)
+
+	ctx := WithRetries(context.Background(), RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond})
+	f, calls = failing(deadlock, driver.ErrBadConn)
+	assert.NoError(t, db.retry(ctx, f))
+	assert.Equal(t, 3, *calls)
+
+	f, calls = failing(deadlock, deadlock, deadlock)
+	assert.Equal(t, deadlock, db.retry(ctx, f))
+	assert.Equal(t, 3, *calls)
+
+	// Other errors are not retried.
+	other := errors.New("syntax error")
+	f, calls = failing(other)
+	assert.Equal(t, other, db.retry(ctx, f))
+	assert.Equal(t, 1, *calls)
+
+	// Queries in a transaction are not retried.
+	txCtx := context.WithValue(ctx, txKey{db: db.Conn}, struct{}{})
+	f, calls = failing(deadlock)
+	assert.Equal(t, deadlock, db.retry(txCtx, f))
+	assert.Equal(t, 1, *calls)
+}
@@ -0,0 +1,73 @@
+package sqlgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync/atomic"
+)
+
+// Savepoints mark a point in a transaction that it can be rolled back to,
+// without rolling back the whole transaction. A mutation composed of
+// multiple steps can then undo a failed optional step, and still commit the
+// others.
+
+// savepointNameRe matches the savepoint names accepted by Savepoint, which
+// are interpolated into the statements.
+var savepointNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// savepointCounter numbers the savepoints created by RunInSavepoint.
+var savepointCounter int64
+
+// savepointStatement runs the savepoint statement verb on name in the
+// transaction of ctx.
+func (db *DB) savepointStatement(ctx context.Context, verb string, name string) error {
+	if !db.HasTx(ctx) {
+		return errors.New("savepoints require a tx")
+	}
+	if !savepointNameRe.MatchString(name) {
+		return fmt.Errorf("invalid savepoint name %q", name)
+	}
+	query := verb + " " + name
+	if _, err := db.QueryExecer(ctx).ExecContext(ctx, query); err != nil {
+		return &ErrorWithQuery{err: err, clause: query}
+	}
+	return nil
+}
+
+// Savepoint creates a savepoint called name in the transaction of ctx,
+// replacing any earlier savepoint with the same name.
+func (db *DB) Savepoint(ctx context.Context, name string) error {
+	return db.savepointStatement(ctx, "SAVEPOINT", name)
+}
+
+// RollbackTo rolls back the transaction of ctx to the savepoint called name,
+// undoing all writes since it was created. The savepoint is kept, while
+// savepoints created after it are removed.
+func (db *DB) RollbackTo(ctx context.Context, name string) error {
+	return db.savepointStatement(ctx, "ROLLBACK TO SAVEPOINT", name)
+}
+
+// ReleaseSavepoint removes the savepoint called name, and the savepoints
+// created after it, from the transaction of ctx without undoing any writes.
+func (db *DB) ReleaseSavepoint(ctx context.Context, name string) error {
+	return db.savepointStatement(ctx, "RELEASE SAVEPOINT", name)
+}
+
+// RunInSavepoint runs f in a savepoint of the transaction of ctx. If f
+// fails, the transaction is rolled back to the savepoint, undoing the writes
+// of f, and f's error is returned; the transaction itself remains usable.
+func (db *DB) RunInSavepoint(ctx context.Context, f func(ctx context.Context) error) error {
+	name := fmt.Sprintf("sqlgen_savepoint_%d", atomic.AddInt64(&savepointCounter, 1))
+	if err := db.Savepoint(ctx, name); err != nil {
+		return err
+	}
+	if err := f(ctx); err != nil {
+		if rollbackErr := db.RollbackTo(ctx, name); rollbackErr != nil {
+			return rollbackErr
+		}
+		return err
+	}
+	return db.ReleaseSavepoint(ctx, name)
+}
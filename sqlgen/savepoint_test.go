@@ -0,0 +1,63 @@
+package sqlgen
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSavepointRequiresTx(t *testing.T) {
+	db := NewDB(nil, NewSchema())
+	ctx := context.Background()
+	assert.EqualError(t, db.Savepoint(ctx, "step"), "savepoints require a tx")
+
+	txCtx, err := db.WithExistingTx(ctx, nil)
+	require.NoError(t, err)
+	assert.EqualError(t, db.RollbackTo(txCtx, "step; DROP TABLE users"), `invalid savepoint name "step; DROP TABLE users"`)
+}
+
+func TestSavepoints(t *testing.T) {
+	tdb, db, err := setup()
+	require.NoError(t, err)
+	defer tdb.Close()
+	ctx := context.Background()
+
+	optionalFailed := errors.New("optional step failed")
+	require.NoError(t, db.RunInTx(ctx, func(ctx context.Context) error {
+		if _, err := db.InsertRow(ctx, &User{Name: "Alice"}); err != nil {
+			return err
+		}
+
+		// A failed optional step is rolled back, keeping the other steps.
+		err := db.RunInSavepoint(ctx, func(ctx context.Context) error {
+			if _, err := db.InsertRow(ctx, &User{Name: "Bob"}); err != nil {
+				return err
+			}
+			return optionalFailed
+		})
+		assert.Equal(t, optionalFailed, err)
+
+		require.NoError(t, db.Savepoint(ctx, "carol"))
+		if _, err := db.InsertRow(ctx, &User{Name: "Carol"}); err != nil {
+			return err
+		}
+		require.NoError(t, db.RollbackTo(ctx, "carol"))
+		require.NoError(t, db.ReleaseSavepoint(ctx, "carol"))
+
+		return db.RunInSavepoint(ctx, func(ctx context.Context) error {
+			_, err := db.InsertRow(ctx, &User{Name: "Dave"})
+			return err
+		})
+	}))
+
+	var users []*User
+	require.NoError(t, db.Query(ctx, &users, nil, nil))
+	var names []string
+	for _, user := range users {
+		names = append(names, user.Name)
+	}
+	assert.Equal(t, []string{"Alice", "Dave"}, names)
+}
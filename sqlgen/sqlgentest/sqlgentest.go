@@ -0,0 +1,307 @@
+// Package sqlgentest seeds databases with fixtures and compares their
+// contents to expected rows, for tests of code using sqlgen. Fixtures are
+// rows keyed by the table names models are registered with, and are
+// typically read from files:
+//
+//	{
+//	  "users": [
+//	    {"id": 1, "name": "Alice", "team_id": 10},
+//	    {"id": 2, "name": "Bob", "team_id": 10}
+//	  ]
+//	}
+//
+// Every test seeds the database in its own transaction, which is rolled back
+// once the test finishes, so tests do not observe each other's writes:
+//
+//	ctx, rollback := sqlgentest.Seed(t, context.Background(), db, sqlgentest.MustReadFixtures(t, "testdata/users.json", nil))
+//	defer rollback()
+//
+//	require.NoError(t, renameUser(ctx, db, 1, "Carol"))
+//	sqlgentest.AssertRows(t, ctx, db, "users", []sqlgentest.Row{
+//		{"id": 1, "name": "Carol"},
+//		{"id": 2, "name": "Bob"},
+//	})
+package sqlgentest
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/sqlgen"
+)
+
+// A Row maps column names to values. Values are converted to the types of
+// their columns like values read from the database, so fixtures can hold
+// numbers, strings, booleans, and nil for columns of any type that can be
+// scanned from them.
+type Row map[string]interface{}
+
+// Fixtures are rows to seed tables with, by table name.
+type Fixtures map[string][]Row
+
+// Unmarshal decodes fixture files, such as json.Unmarshal or the Unmarshal
+// function of a YAML package.
+type Unmarshal func(data []byte, v interface{}) error
+
+// ParseFixtures decodes fixtures with unmarshal. A nil unmarshal decodes
+// JSON, keeping integers exact.
+func ParseFixtures(data []byte, unmarshal Unmarshal) (Fixtures, error) {
+	var fixtures Fixtures
+	if unmarshal == nil {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&fixtures); err != nil {
+			return nil, fmt.Errorf("parsing fixtures: %v", err)
+		}
+		return fixtures, nil
+	}
+	if err := unmarshal(data, &fixtures); err != nil {
+		return nil, fmt.Errorf("parsing fixtures: %v", err)
+	}
+	return fixtures, nil
+}
+
+// ReadFixtures reads the fixtures in the file at path, see ParseFixtures.
+func ReadFixtures(path string, unmarshal Unmarshal) (Fixtures, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	fixtures, err := ParseFixtures(data, unmarshal)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return fixtures, nil
+}
+
+// MustReadFixtures reads the fixtures in the file at path, and fails the
+// test if they cannot be read.
+func MustReadFixtures(t testing.TB, path string, unmarshal Unmarshal) Fixtures {
+	fixtures, err := ReadFixtures(path, unmarshal)
+	require.NoError(t, err)
+	return fixtures
+}
+
+// Seed starts a transaction on db, deletes all rows of the tables of
+// fixtures, and inserts the rows of fixtures, table by table in alphabetical
+// order. It returns a context carrying the transaction, so queries run with it
+// see the seeded rows, and a function rolling back the transaction, which the
+// test must call once it finishes. Rows are deleted rather than truncated,
+// because MySQL commits the transaction before truncating a table.
+//
+// Columns missing from rows are inserted with their zero value, except
+// auto-increment primary keys, which are assigned by the database.
+func Seed(t testing.TB, ctx context.Context, db *sqlgen.DB, fixtures Fixtures) (context.Context, func()) {
+	ctx, tx, err := db.WithTx(ctx)
+	require.NoError(t, err)
+	rollback := func() {
+		tx.Rollback()
+	}
+
+	if err := seed(ctx, db, fixtures); err != nil {
+		rollback()
+		require.NoError(t, err)
+	}
+	return ctx, rollback
+}
+
+func seed(ctx context.Context, db *sqlgen.DB, fixtures Fixtures) error {
+	tables := make([]string, 0, len(fixtures))
+	for table := range fixtures {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	execer := db.QueryExecer(ctx)
+	for _, name := range tables {
+		table, ok := db.Schema.ByName[name]
+		if !ok {
+			return fmt.Errorf("unknown table %s", name)
+		}
+		if _, err := execer.ExecContext(ctx, fmt.Sprintf("DELETE FROM `%s`", table.Name)); err != nil {
+			return fmt.Errorf("deleting rows of %s: %v", table.Name, err)
+		}
+
+		for i, row := range fixtures[name] {
+			query, err := makeInsert(db.Schema, table, row)
+			if err != nil {
+				return fmt.Errorf("%s row %d: %v", table.Name, i, err)
+			}
+			clause, args := query.ToSQL()
+			if _, err := execer.ExecContext(ctx, clause, args...); err != nil {
+				return fmt.Errorf("inserting %s row %d: %v", table.Name, i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// makeInsert builds a query inserting row into table.
+func makeInsert(schema *sqlgen.Schema, table *sqlgen.Table, row Row) (*sqlgen.InsertQuery, error) {
+	model, err := buildModel(table, row)
+	if err != nil {
+		return nil, err
+	}
+	// UnbuildStruct encrypts the values of encrypted columns.
+	values, err := schema.UnbuildStruct(table.Name, model)
+	if err != nil {
+		return nil, err
+	}
+
+	query := &sqlgen.InsertQuery{Table: table.Name}
+	for i, column := range table.Columns {
+		if _, ok := row[column.Name]; !ok && column.Primary && table.PrimaryKeyType == sqlgen.AutoIncrement {
+			continue
+		}
+		if column.Generated {
+			if _, ok := row[column.Name]; ok {
+				return nil, fmt.Errorf("generated column %s cannot be seeded", column.Name)
+			}
+			continue
+		}
+		query.Columns = append(query.Columns, column.Name)
+		query.Values = append(query.Values, values[i])
+	}
+	return query, nil
+}
+
+// buildModel builds a pointer to a model of table holding the values of row.
+func buildModel(table *sqlgen.Table, row Row) (interface{}, error) {
+	ptr := reflect.New(table.Type)
+	for name, value := range row {
+		column, ok := table.ColumnsByName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown column %s", name)
+		}
+		value, err := driverValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %v", name, err)
+		}
+
+		field := ptr.Elem().FieldByIndex(column.Index)
+		if field.Kind() != reflect.Ptr {
+			field = field.Addr()
+		}
+		scanner := column.Descriptor.Scanner()
+		scanner.Target(field)
+		if err := scanner.Scan(value); err != nil {
+			return nil, fmt.Errorf("column %s: %v", name, err)
+		}
+	}
+	return ptr.Interface(), nil
+}
+
+// driverValue converts a value decoded from fixtures into a value the
+// database driver could return.
+func driverValue(value interface{}) (interface{}, error) {
+	if number, ok := value.(json.Number); ok {
+		if i, err := number.Int64(); err == nil {
+			return i, nil
+		}
+		return number.Float64()
+	}
+	return driver.DefaultParameterConverter.ConvertValue(value)
+}
+
+// columnValues returns the values of the columns of row of model, which is a
+// pointer to a model of table.
+func columnValues(table *sqlgen.Table, model interface{}, row Row) (map[string]interface{}, error) {
+	elem := reflect.ValueOf(model).Elem()
+	values := make(map[string]interface{}, len(row))
+	for name := range row {
+		column, ok := table.ColumnsByName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown column %s", name)
+		}
+		value, err := column.Descriptor.Valuer(elem.FieldByIndex(column.Index)).Value()
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %v", name, err)
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+// AssertRows asserts that table holds exactly the expected rows, in any order.
+// Only the columns of the expected rows are compared, and values are
+// converted to the types of their columns first, so expected rows can be
+// written like fixtures.
+func AssertRows(t testing.TB, ctx context.Context, db *sqlgen.DB, table string, expected []Row) bool {
+	descriptor, ok := db.Schema.ByName[table]
+	if !ok {
+		return assert.Fail(t, fmt.Sprintf("unknown table %s", table))
+	}
+
+	var columns Row
+	expectedValues := make([]map[string]interface{}, 0, len(expected))
+	for i, row := range expected {
+		if i == 0 {
+			columns = row
+		} else if !sameColumns(columns, row) {
+			return assert.Fail(t, fmt.Sprintf("expected %s rows have different columns", table))
+		}
+		model, err := buildModel(descriptor, row)
+		if err == nil {
+			var values map[string]interface{}
+			values, err = columnValues(descriptor, model, row)
+			expectedValues = append(expectedValues, values)
+		}
+		if err != nil {
+			return assert.Fail(t, fmt.Sprintf("expected %s row %d: %v", table, i, err))
+		}
+	}
+
+	models := reflect.New(reflect.SliceOf(reflect.PtrTo(descriptor.Type)))
+	if err := db.Query(ctx, models.Interface(), nil, nil); err != nil {
+		return assert.NoError(t, err, "querying %s", table)
+	}
+	actualValues := make([]map[string]interface{}, 0, models.Elem().Len())
+	for i := 0; i < models.Elem().Len(); i++ {
+		values, err := columnValues(descriptor, models.Elem().Index(i).Interface(), columns)
+		if err != nil {
+			return assert.NoError(t, err, "reading %s", table)
+		}
+		actualValues = append(actualValues, values)
+	}
+
+	return assert.ElementsMatch(t, expectedValues, actualValues, "rows of %s", table)
+}
+
+// AssertFixtures asserts that the tables of expected hold exactly their rows,
+// see AssertRows.
+func AssertFixtures(t testing.TB, ctx context.Context, db *sqlgen.DB, expected Fixtures) bool {
+	tables := make([]string, 0, len(expected))
+	for table := range expected {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	ok := true
+	for _, table := range tables {
+		ok = AssertRows(t, ctx, db, table, expected[table]) && ok
+	}
+	return ok
+}
+
+// sameColumns returns whether a and b have the same columns.
+func sameColumns(a, b Row) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name := range a {
+		if _, ok := b[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
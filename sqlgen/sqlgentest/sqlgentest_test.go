@@ -0,0 +1,107 @@
+package sqlgentest
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denkhaus/thunder/internal/testfixtures"
+	"github.com/denkhaus/thunder/sqlgen"
+)
+
+type user struct {
+	Id       int64 `sql:",primary"`
+	Name     string
+	TeamId   int64
+	Score    float64
+	Nickname *string
+}
+
+func testSchema() *sqlgen.Schema {
+	schema := sqlgen.NewSchema()
+	schema.MustRegisterType("users", sqlgen.AutoIncrement, user{})
+	return schema
+}
+
+func TestParseFixtures(t *testing.T) {
+	fixtures, err := ReadFixtures("testdata/users.json", nil)
+	require.NoError(t, err)
+	require.Len(t, fixtures["users"], 2)
+	assert.Equal(t, json.Number("1"), fixtures["users"][0]["id"])
+
+	// Fixtures can be decoded by other formats.
+	fixtures, err = ParseFixtures([]byte(`{"users": [{"id": 3}]}`), json.Unmarshal)
+	require.NoError(t, err)
+	assert.Equal(t, Fixtures{"users": {{"id": float64(3)}}}, fixtures)
+
+	_, err = ParseFixtures([]byte(`{"users": {}}`), nil)
+	assert.Error(t, err)
+}
+
+func TestMakeInsert(t *testing.T) {
+	schema := testSchema()
+	table := schema.ByName["users"]
+	fixtures, err := ReadFixtures("testdata/users.json", nil)
+	require.NoError(t, err)
+
+	query, err := makeInsert(schema, table, fixtures["users"][1])
+	require.NoError(t, err)
+	clause, args := query.ToSQL()
+	assert.Equal(t, "INSERT INTO users (id, name, team_id, score, nickname) VALUES (?, ?, ?, ?, ?)", clause)
+	assert.Equal(t, []interface{}{int64(2), "Bob", int64(10), float64(0), "bobby"}, args)
+
+	// Auto-increment primary keys are assigned by the database, unless
+	// fixtures set them.
+	query, err = makeInsert(schema, table, Row{"name": "Carol", "nickname": nil})
+	require.NoError(t, err)
+	clause, args = query.ToSQL()
+	assert.Equal(t, "INSERT INTO users (name, team_id, score, nickname) VALUES (?, ?, ?, ?)", clause)
+	assert.Equal(t, []interface{}{"Carol", int64(0), float64(0), nil}, args)
+
+	_, err = makeInsert(schema, table, Row{"age": 3})
+	assert.EqualError(t, err, "unknown column age")
+	_, err = makeInsert(schema, table, Row{"team_id": "ten"})
+	if assert.Error(t, err) {
+		assert.True(t, strings.HasPrefix(err.Error(), "column team_id: "))
+	}
+}
+
+func TestSeed(t *testing.T) {
+	testDb, err := testfixtures.NewTestDatabase()
+	require.NoError(t, err)
+	defer testDb.Close()
+	_, err = testDb.Exec(`
+		CREATE TABLE users (
+			id       BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+			name     VARCHAR(255) NOT NULL,
+			team_id  BIGINT NOT NULL,
+			score    DOUBLE NOT NULL,
+			nickname VARCHAR(255)
+		)
+	`)
+	require.NoError(t, err)
+	db := sqlgen.NewDB(testDb.DB, testSchema())
+	_, err = db.InsertRow(context.Background(), &user{Name: "Outside"})
+	require.NoError(t, err)
+
+	fixtures := MustReadFixtures(t, "testdata/users.json", nil)
+	ctx, rollback := Seed(t, context.Background(), db, fixtures)
+	AssertFixtures(t, ctx, db, fixtures)
+
+	_, err = db.InsertRow(ctx, &user{Name: "Carol", TeamId: 20})
+	require.NoError(t, err)
+	AssertRows(t, ctx, db, "users", []Row{
+		{"name": "Alice", "team_id": 10},
+		{"name": "Bob", "team_id": 10},
+		{"name": "Carol", "team_id": 20},
+	})
+
+	// Seeded rows are rolled back once the test finishes.
+	rollback()
+	AssertRows(t, context.Background(), db, "users", []Row{{"name": "Outside"}})
+}